@@ -54,14 +54,14 @@ func Usage() {
 		Description:   "Options controlling the HTTP request and its parts.",
 		Flags:         make([]UsageFlag, 0),
 		Hidden:        false,
-		ExpectedFlags: []string{"cc", "ck", "H", "X", "b", "d", "r", "u", "raw", "recursion", "recursion-depth", "recursion-strategy", "replay-proxy", "timeout", "ignore-body", "x", "sni", "http2"},
+		ExpectedFlags: []string{"cc", "ck", "client-ca", "client-cert", "client-key", "cookie-jar", "H", "X", "b", "d", "r", "u", "rate-limit-max-wait", "raw", "recursion", "recursion-depth", "recursion-strategy", "replay-proxy", "response-size-limit", "retries", "retry-delay", "timeout", "ignore-body", "x", "x-list", "header-pool", "sni", "structural-hash", "http2"},
 	}
 	u_general := UsageSection{
 		Name:          "GENERAL OPTIONS",
 		Description:   "",
 		Flags:         make([]UsageFlag, 0),
 		Hidden:        false,
-		ExpectedFlags: []string{"ac", "acc", "ack", "ach", "acs", "c", "config", "json", "maxtime", "maxtime-job", "noninteractive", "p", "rate", "scraperfile", "scrapers", "search", "s", "sa", "se", "sf", "t", "v", "V"},
+		ExpectedFlags: []string{"ac", "acc", "ack", "ach", "acr", "acs", "c", "checkpoint-dir", "checkpoint-interval", "config", "json", "maxtime", "maxtime-job", "noninteractive", "p", "rate", "request-budget", "request-budget-explore", "resume-checkpoint", "scraperfile", "scrapers", "search", "s", "sa", "se", "sf", "status-addr", "t", "v", "V"},
 	}
 	u_compat := UsageSection{
 		Name:          "COMPATIBILITY OPTIONS",
@@ -75,30 +75,37 @@ func Usage() {
 		Description:   "Matchers for the response filtering.",
 		Flags:         make([]UsageFlag, 0),
 		Hidden:        false,
-		ExpectedFlags: []string{"mmode", "mc", "ml", "mr", "ms", "mt", "mw"},
+		ExpectedFlags: []string{"mmode", "mc", "mhash", "ml", "mr", "mreflect", "ms", "mt", "mw"},
 	}
 	u_filter := UsageSection{
 		Name:          "FILTER OPTIONS",
 		Description:   "Filters for the response filtering.",
 		Flags:         make([]UsageFlag, 0),
 		Hidden:        false,
-		ExpectedFlags: []string{"fmode", "fc", "fl", "fr", "fs", "ft", "fw"},
+		ExpectedFlags: []string{"fmode", "fc", "fhash", "fl", "fr", "fs", "ft", "fw"},
 	}
 	u_input := UsageSection{
 		Name:          "INPUT OPTIONS",
 		Description:   "Options for input data for fuzzing. Wordlists and input generators.",
 		Flags:         make([]UsageFlag, 0),
 		Hidden:        false,
-		ExpectedFlags: []string{"D", "enc", "ic", "input-cmd", "input-num", "input-shell", "mode", "request", "request-proto", "e", "w"},
+		ExpectedFlags: []string{"D", "enc", "ic", "input-cmd", "input-num", "input-shard", "input-shell", "mode", "replay-from", "replay-min-reward", "request", "request-proto", "e", "w"},
 	}
 	u_output := UsageSection{
 		Name:          "OUTPUT OPTIONS",
 		Description:   "Options for output. Output file formats, file names and debug file locations.",
 		Flags:         make([]UsageFlag, 0),
 		Hidden:        false,
-		ExpectedFlags: []string{"audit-log", "debug-log", "o", "of", "od", "or"},
+		ExpectedFlags: []string{"audit-log", "debug-log", "o", "of", "od", "od-max-files", "od-min-reward", "od-status", "or", "sort"},
 	}
-	sections := []UsageSection{u_http, u_general, u_compat, u_matcher, u_filter, u_input, u_output}
+	u_markov := UsageSection{
+		Name:          "MARKOV OPTIONS",
+		Description:   "Options for the Markov chain feedback loop prioritizing inputs based on learned response patterns.",
+		Flags:         make([]UsageFlag, 0),
+		Hidden:        false,
+		ExpectedFlags: []string{"markov", "markov-class-quota", "markov-cold-budget", "markov-conn-error-reward", "markov-cookie", "markov-cookie-reward", "markov-cooldown", "markov-cooldown-status", "markov-cooldown-ua", "markov-dry-run", "markov-dry-run-out", "markov-duration-cv", "markov-export-aggregate", "markov-export-weights", "markov-final-pass", "markov-granularity", "markov-headers", "markov-known-good", "markov-load", "markov-max-memory", "markov-neighbors", "markov-normalize", "markov-pattern-max", "markov-phases", "markov-prefix-recursion", "markov-prefix-threshold", "markov-proto", "markov-redirect-reward", "markov-reflect-reward", "markov-reward-expr", "markov-save", "markov-seed-history", "markov-seed-target", "markov-size-buckets", "markov-stack-filter", "markov-sync", "markov-sync-interval", "markov-threshold", "markov-timeout-reward", "markov-top", "markov-unique-entropy", "markov-update-buffer", "markov-wordlist-out"},
+	}
+	sections := []UsageSection{u_http, u_general, u_compat, u_matcher, u_filter, u_input, u_output, u_markov}
 
 	// Populate the flag sections
 	max_length := 0
@@ -147,5 +154,8 @@ func Usage() {
 	fmt.Printf("  Fuzz multiple locations. Match only responses reflecting the value of \"VAL\" keyword. Colored.\n")
 	fmt.Printf("    ffuf -w params.txt:PARAM -w values.txt:VAL -u https://example.org/?PARAM=VAL -mr \"VAL\" -c\n\n")
 
+	fmt.Printf("  Compare the Markov chains saved with -markov-save by two scans of the same target.\n")
+	fmt.Printf("    ffuf markov diff last-week.json today.json\n\n")
+
 	fmt.Printf("  More information and examples: https://github.com/ffuf/ffuf\n\n")
 }
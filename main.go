@@ -67,6 +67,7 @@ func ParseFlags(opts *ffuf.ConfigOptions) *ffuf.ConfigOptions {
 	flag.BoolVar(&opts.Output.OutputSkipEmptyFile, "or", opts.Output.OutputSkipEmptyFile, "Don't create the output file if we don't have results")
 	flag.BoolVar(&opts.General.AutoCalibration, "ac", opts.General.AutoCalibration, "Automatically calibrate filtering options")
 	flag.BoolVar(&opts.General.AutoCalibrationPerHost, "ach", opts.General.AutoCalibration, "Per host autocalibration")
+	flag.BoolVar(&opts.General.AutoCalibrationPerRoot, "acr", opts.General.AutoCalibrationPerRoot, "Per recursion root autocalibration, the filters applying to the recursion jobs under the root only")
 	flag.BoolVar(&opts.General.Colors, "c", opts.General.Colors, "Colorize output.")
 	flag.BoolVar(&opts.General.Json, "json", opts.General.Json, "JSON output, printing newline-delimited JSON records")
 	flag.BoolVar(&opts.General.Noninteractive, "noninteractive", opts.General.Noninteractive, "Disable the interactive console functionality")
@@ -83,20 +84,66 @@ func ParseFlags(opts *ffuf.ConfigOptions) *ffuf.ConfigOptions {
 	flag.BoolVar(&opts.HTTP.Http2, "http2", opts.HTTP.Http2, "Use HTTP2 protocol")
 	flag.BoolVar(&opts.Input.DirSearchCompat, "D", opts.Input.DirSearchCompat, "DirSearch wordlist compatibility mode. Used in conjunction with -e flag.")
 	flag.BoolVar(&opts.Input.IgnoreWordlistComments, "ic", opts.Input.IgnoreWordlistComments, "Ignore wordlist comments")
+	flag.BoolVar(&opts.Markov.Enabled, "markov", opts.Markov.Enabled, "Enable Markov chain feedback to learn from responses and reward the inputs producing them")
+	flag.BoolVar(&opts.Markov.Proto, "markov-proto", opts.Markov.Proto, "Include the negotiated HTTP protocol version in the Markov chain state")
+	flag.BoolVar(&opts.Markov.Headers, "markov-headers", opts.Markov.Headers, "Include the number of response headers, bucketed to 0-5, 6-15 and 16+, in the Markov chain state")
+	flag.BoolVar(&opts.Markov.SeedTarget, "markov-seed-target", opts.Markov.SeedTarget, "Seed the Markov chain with the paths found in robots.txt and sitemap.xml of the target before starting")
+	flag.BoolVar(&opts.Markov.PrefixRecursion, "markov-prefix-recursion", opts.Markov.PrefixRecursion, "Queue a job fuzzing under each path prefix suggested by the Markov chain, within the recursion depth")
+	flag.BoolVar(&opts.Markov.Normalize, "markov-normalize", opts.Markov.Normalize, "Normalize the tokens before the Markov chain learns and ranks them: invalid UTF-8 replaced, control and zero-width characters removed and fullwidth characters folded to ASCII")
+	flag.BoolVar(&opts.Markov.StackFilter, "markov-stack-filter", opts.Markov.StackFilter, "Send the wordlist tokens specific to another stack than the one the target is guessed to run later, the more so the more confident the guess. They are never left out")
+	flag.BoolVar(&opts.Markov.Cookie, "markov-cookie", opts.Markov.Cookie, "Include the presence of a Set-Cookie response header in the Markov chain state")
 	flag.IntVar(&opts.General.MaxTime, "maxtime", opts.General.MaxTime, "Maximum running time in seconds for entire process.")
 	flag.IntVar(&opts.General.MaxTimeJob, "maxtime-job", opts.General.MaxTimeJob, "Maximum running time in seconds per job.")
 	flag.IntVar(&opts.General.Rate, "rate", opts.General.Rate, "Rate of requests per second")
+	flag.IntVar(&opts.General.RequestBudget, "request-budget", opts.General.RequestBudget, "Maximum number of requests sent to the target, including the calibration, probes and retries, stopping once spent. 0 for no limit")
+	flag.Float64Var(&opts.General.RequestBudgetExplore, "request-budget-explore", opts.General.RequestBudgetExplore, "Fraction of -request-budget spent on calibration and Markov cold state exploration before the chain ranks the inputs")
 	flag.IntVar(&opts.General.Threads, "t", opts.General.Threads, "Number of concurrent threads.")
 	flag.IntVar(&opts.HTTP.RecursionDepth, "recursion-depth", opts.HTTP.RecursionDepth, "Maximum recursion depth.")
+	flag.BoolVar(&opts.HTTP.StructuralHash, "structural-hash", opts.HTTP.StructuralHash, "Hash only the skeleton of the HTML bodies, the tags with their ids and classes, for the hash filters and the Markov chain rewards. Pages differing by a CSRF token or a timestamp get the same hash")
+	flag.IntVar(&opts.HTTP.ResponseSizeLimit, "response-size-limit", opts.HTTP.ResponseSizeLimit, "Maximum number of response body bytes to read, the rest of the body is discarded. 0 for no limit")
+	flag.IntVar(&opts.HTTP.Retries, "retries", opts.HTTP.Retries, "Number of times to retry a request failing on a transport error, eg. connection reset or timeout")
 	flag.IntVar(&opts.HTTP.Timeout, "timeout", opts.HTTP.Timeout, "HTTP request timeout in seconds.")
+	flag.IntVar(&opts.Markov.Neighbors, "markov-neighbors", opts.Markov.Neighbors, "Number of the most similar words of the wordlist sent right after each match, like backups for backup. 0 disables the neighbors")
+	flag.StringVar(&opts.Markov.Sync, "markov-sync", opts.Markov.Sync, "Chain file shared with other instances, merging the learning of the Markov chain into it periodically and importing the learning of the others")
+	flag.StringVar(&opts.General.CheckpointDir, "checkpoint-dir", opts.General.CheckpointDir, "Directory to periodically write complete checkpoints of the scan to, resumable with -resume-checkpoint")
+	flag.StringVar(&opts.General.CheckpointInterval, "checkpoint-interval", opts.General.CheckpointInterval, "Interval between the checkpoints of -checkpoint-dir. For example \"30s\" or \"5m\"")
+	flag.StringVar(&opts.General.ResumeCheckpoint, "resume-checkpoint", opts.General.ResumeCheckpoint, "Resume the scan from a checkpoint: a directory of -checkpoint-dir, resuming from its latest checkpoint, or a checkpoint in it")
+	flag.StringVar(&opts.Markov.SyncInterval, "markov-sync-interval", opts.Markov.SyncInterval, "Interval between the syncs of the Markov chain with -markov-sync. For example \"30s\" or \"5m\"")
+	flag.IntVar(&opts.Markov.FinalPass, "markov-final-pass", opts.Markov.FinalPass, "Number of the never sent candidates generated from the matches sent once the wordlist is exhausted, best expected reward first. 0 disables the final pass")
+	flag.StringVar(&opts.Markov.MaxMemory, "markov-max-memory", opts.Markov.MaxMemory, "Approximate memory limit of the sent input cache, the matched inputs and the Markov chain, for example \"256MB\". Past it the bloom filter of the cache, then the oldest matched inputs, then the least visited states are evicted")
+	flag.IntVar(&opts.Markov.ClassQuota, "markov-class-quota", opts.Markov.ClassQuota, "Number of the most visited states of each status code class kept when -markov-max-memory evicts the least visited states, so that rare classes are not evicted by the common ones")
+	flag.IntVar(&opts.Markov.ColdBudget, "markov-cold-budget", opts.Markov.ColdBudget, "Number of the inputs sent in wordlist order when the Markov chain ranks the inputs in a state it has never seen, before reordering them. 0 reorders at once")
+	flag.IntVar(&opts.Markov.UpdateBuffer, "markov-update-buffer", opts.Markov.UpdateBuffer, "Number of the Markov chain updates queued to the goroutine applying them, so that the workers never wait for the chain. Updates arriving when the queue is full are dropped and counted. 0 applies the updates inline")
+	flag.IntVar(&opts.Markov.DryRun, "markov-dry-run", opts.Markov.DryRun, "Print the first N inputs that would be sent, in order with their Markov chain scores and decisions, after loading and seeding the chain, then exit without sending any fuzz request. 0 to disable")
+	flag.StringVar(&opts.Markov.DryRunOut, "markov-dry-run-out", opts.Markov.DryRunOut, "Write the plan of -markov-dry-run to a file, one rank<TAB>position<TAB>score<TAB>input<TAB>decision line per input, rather than printing it")
+	flag.IntVar(&opts.Markov.Top, "markov-top", opts.Markov.Top, "Number of the inputs ranked by the chain sent first in each batch of 100, the others keeping the wordlist order. 0 reorders the whole batch")
+	flag.IntVar(&opts.Markov.PatternMax, "markov-pattern-max", opts.Markov.PatternMax, "Largest number of tokens generated from each pattern the Markov chain induces from the matches, like api_v\\d+ from api_v1 and api_v2. 0 disables the patterns")
 	flag.IntVar(&opts.Input.InputNum, "input-num", opts.Input.InputNum, "Number of inputs to test. Used in conjunction with --input-cmd.")
+	flag.Float64Var(&opts.Markov.CookieReward, "markov-cookie-reward", opts.Markov.CookieReward, "Markov chain reward bonus for the first response setting a cookie of a given name under a path")
+	flag.StringVar(&opts.Markov.SizeBuckets, "markov-size-buckets", opts.Markov.SizeBuckets, "Size buckets of the Markov chain states: \"log\" for log-scale buckets, or \"adaptive\" for buckets learned from the quantiles of the first 200 response sizes, for targets whose responses have similar sizes")
+	flag.StringVar(&opts.Markov.RewardExpr, "markov-reward-expr", opts.Markov.RewardExpr, "Expression computing the Markov chain reward of each response in place of the built-in rewards, for example: builtin + 2 * body_contains(\"X-Internal\"). Primitives: status, size, words, lines, duration_ms, builtin, header(\"name\"), body_contains(\"text\")")
+	flag.Float64Var(&opts.Markov.PrefixThreshold, "markov-prefix-threshold", opts.Markov.PrefixThreshold, "Mean Markov chain reward the words of a path prefix, like api/ of api/users, need over 10 requests for the prefix to be suggested as a recursion root. 0 to disable")
+	flag.Float64Var(&opts.Markov.Threshold, "markov-threshold", opts.Markov.Threshold, "Noise gate of the Markov chain: the value of a word is not updated when its reward differs from the expected one by less than this. 0 to update on every response")
+	flag.StringVar(&opts.Markov.Phases, "markov-phases", opts.Markov.Phases, "Run the Markov chain in two phases, like learn=20%,exploit=80%: the learn share of the wordlist is sent in its order while the chain learns, and the rest is then ranked once and sent in that order")
+	flag.Float64Var(&opts.Markov.UniqueEntropy, "markov-unique-entropy", opts.Markov.UniqueEntropy, "Entropy in bits per character from which a long alphanumeric part of a token looks random, the tokens looking unique like UUIDs, hashes and random ids being sent but not learned by the Markov chain, 3.9 being a good start. 0 learns every token")
+	flag.Float64Var(&opts.Markov.DurationCV, "markov-duration-cv", opts.Markov.DurationCV, "Largest coefficient of variation of the durations of the responses like the baseline before duration_ms of -markov-reward-expr is considered noise and set to 0 for the rest of the run, as behind a CDN cache. 0 disables the check")
+	flag.Float64Var(&opts.Markov.ReflectReward, "markov-reflect-reward", opts.Markov.ReflectReward, "Markov chain reward bonus for the responses reflecting a fuzzed value in their body or headers, as matched by -mreflect. 0 to disable")
+	flag.Float64Var(&opts.Markov.RedirectReward, "markov-redirect-reward", opts.Markov.RedirectReward, "Markov chain reward bonus for the responses reached through up to 2 redirects on the same host with -r. Negative to rank them below the direct responses")
+	flag.Float64Var(&opts.Markov.Cooldown, "markov-cooldown", opts.Markov.Cooldown, "Pause the requests for this many seconds when the Markov chain detects blocking, doubled on each consecutive detection. 0 disables the detection")
+	flag.Float64Var(&opts.Markov.ConnErrorReward, "markov-conn-error-reward", opts.Markov.ConnErrorReward, "Markov chain reward for inputs failing on a connection error")
+	flag.Float64Var(&opts.Markov.TimeoutReward, "markov-timeout-reward", opts.Markov.TimeoutReward, "Markov chain reward for inputs causing the request to time out")
 	flag.StringVar(&opts.General.AutoCalibrationKeyword, "ack", opts.General.AutoCalibrationKeyword, "Autocalibration keyword")
-	flag.StringVar(&opts.HTTP.ClientCert, "cc", "", "Client cert for authentication. Client key needs to be defined as well for this to work")
-	flag.StringVar(&opts.HTTP.ClientKey, "ck", "", "Client key for authentication. Client certificate needs to be defined as well for this to work")
+	flag.StringVar(&opts.HTTP.ClientCert, "cc", opts.HTTP.ClientCert, "Client cert for authentication. Client key needs to be defined as well for this to work")
+	flag.StringVar(&opts.HTTP.ClientKey, "ck", opts.HTTP.ClientKey, "Client key for authentication. Client certificate needs to be defined as well for this to work")
+	flag.StringVar(&opts.HTTP.ClientCert, "client-cert", opts.HTTP.ClientCert, "Client cert for authentication, same as -cc")
+	flag.StringVar(&opts.HTTP.ClientKey, "client-key", opts.HTTP.ClientKey, "Client key for authentication, same as -ck")
+	flag.StringVar(&opts.HTTP.CookieJar, "cookie-jar", opts.HTTP.CookieJar, "Cookie file in the Netscape format sent with the requests, updated with the cookies set by the matched responses")
+	flag.StringVar(&opts.HTTP.ClientCA, "client-ca", opts.HTTP.ClientCA, "PEM file of the intermediate certificates sent along with the client cert")
 	flag.StringVar(&opts.General.ConfigFile, "config", "", "Load configuration from a file")
 	flag.StringVar(&opts.General.ScraperFile, "scraperfile", "", "Custom scraper file path")
 	flag.StringVar(&opts.General.Scrapers, "scrapers", opts.General.Scrapers, "Active scraper groups")
 	flag.StringVar(&opts.Filter.Mode, "fmode", opts.Filter.Mode, "Filter set operator. Either of: and, or")
+	flag.StringVar(&opts.Filter.Hash, "fhash", opts.Filter.Hash, "Filter by hash of the response body, as in the body_hash of the JSON output. Comma separated list of hashes")
 	flag.StringVar(&opts.Filter.Lines, "fl", opts.Filter.Lines, "Filter by amount of lines in response. Comma separated list of line counts and ranges")
 	flag.StringVar(&opts.Filter.Regexp, "fr", opts.Filter.Regexp, "Filter regexp")
 	flag.StringVar(&opts.Filter.Size, "fs", opts.Filter.Size, "Filter HTTP response size. Comma separated list of sizes and ranges")
@@ -105,23 +152,43 @@ func ParseFlags(opts *ffuf.ConfigOptions) *ffuf.ConfigOptions {
 	flag.StringVar(&opts.Filter.Words, "fw", opts.Filter.Words, "Filter by amount of words in response. Comma separated list of word counts and ranges")
 	flag.StringVar(&opts.General.Delay, "p", opts.General.Delay, "Seconds of `delay` between requests, or a range of random delay. For example \"0.1\" or \"0.1-2.0\"")
 	flag.StringVar(&opts.General.Searchhash, "search", opts.General.Searchhash, "Search for a FFUFHASH payload from ffuf history")
+	flag.StringVar(&opts.General.StatusAddr, "status-addr", opts.General.StatusAddr, "Listen `address` for the status endpoint serving Prometheus metrics at /metrics, for example \"127.0.0.1:9090\"")
 	flag.StringVar(&opts.HTTP.Data, "d", opts.HTTP.Data, "POST data")
 	flag.StringVar(&opts.HTTP.Data, "data", opts.HTTP.Data, "POST data (alias of -d)")
 	flag.StringVar(&opts.HTTP.Data, "data-ascii", opts.HTTP.Data, "POST data (alias of -d)")
 	flag.StringVar(&opts.HTTP.Data, "data-binary", opts.HTTP.Data, "POST data (alias of -d)")
 	flag.StringVar(&opts.HTTP.Method, "X", opts.HTTP.Method, "HTTP method to use")
+	flag.StringVar(&opts.Markov.Granularity, "markov-granularity", opts.Markov.Granularity, "Markov chain state granularity: coarse (status class only), default or fine (all the optional state dimensions and the presence of an ETag or Last-Modified validator)")
+	flag.StringVar(&opts.Markov.CooldownStatus, "markov-cooldown-status", opts.Markov.CooldownStatus, "Comma separated list of the status codes of the block pages detected by -markov-cooldown")
+	flag.StringVar(&opts.Markov.CooldownUA, "markov-cooldown-ua", opts.Markov.CooldownUA, "File of User-Agents, one per line, to rotate through after each -markov-cooldown")
+	flag.StringVar(&opts.Markov.Load, "markov-load", opts.Markov.Load, "Load a Markov chain saved with -markov-save to continue learning from it")
+	flag.StringVar(&opts.Markov.Save, "markov-save", opts.Markov.Save, "Save the Markov chain to a file at the end of the run, as JSON if the file name ends in .json and gob otherwise")
+	flag.StringVar(&opts.Markov.KnownGood, "markov-known-good", opts.Markov.KnownGood, "File of the paths known to exist on the target, one per line: each is requested once before fuzzing and its response taught to the Markov chain as a success. The paths answering with a 404 are reported and left out")
+	flag.StringVar(&opts.Markov.SeedHistory, "markov-seed-history", opts.Markov.SeedHistory, "Warm-start the Markov chain from a proxy history export, either Burp XML or HAR (.har)")
+	flag.StringVar(&opts.Markov.ExportWeights, "markov-export-weights", opts.Markov.ExportWeights, "Write the value the Markov chain learned for each token to a weighted wordlist file at the end of the run, one token<TAB>weight line per token with the weights normalized to [0,1]")
+	flag.StringVar(&opts.Markov.ExportAggregate, "markov-export-aggregate", opts.Markov.ExportAggregate, "Aggregation of the values of a token across the states of the chain for -markov-export-weights: \"max\" or \"mean\"")
+	flag.StringVar(&opts.Markov.WordlistOut, "markov-wordlist-out", opts.Markov.WordlistOut, "Write the unique matched tokens, sorted by reward, to a wordlist file at the end of the run")
 	flag.StringVar(&opts.HTTP.ProxyURL, "x", opts.HTTP.ProxyURL, "Proxy URL (SOCKS5 or HTTP). For example: http://127.0.0.1:8080 or socks5://127.0.0.1:8080")
+	flag.StringVar(&opts.HTTP.HeaderPool, "header-pool", opts.HTTP.HeaderPool, "JSON file of header presets, like [{\"name\": \"firefox\", \"headers\": {\"User-Agent\": \"...\"}}]. Requests favor the presets blocked the least")
+	flag.StringVar(&opts.HTTP.ProxyList, "x-list", opts.HTTP.ProxyList, "File containing proxy URLs, one per line. Requests are rotated across the healthy proxies")
 	flag.StringVar(&opts.HTTP.ReplayProxyURL, "replay-proxy", opts.HTTP.ReplayProxyURL, "Replay matched requests using this proxy.")
 	flag.StringVar(&opts.HTTP.RecursionStrategy, "recursion-strategy", opts.HTTP.RecursionStrategy, "Recursion strategy: \"default\" for a redirect based, and \"greedy\" to recurse on all matches")
+	flag.StringVar(&opts.HTTP.RateLimitMaxWait, "rate-limit-max-wait", opts.HTTP.RateLimitMaxWait, "Longest pause honoring the Retry-After or X-RateLimit-Reset header of a 429 response. For example \"30s\" or \"2m\", 0 not to pause")
+	flag.StringVar(&opts.HTTP.RetryDelay, "retry-delay", opts.HTTP.RetryDelay, "Delay between the retries of a failed request. For example \"500ms\" or \"2s\"")
 	flag.StringVar(&opts.HTTP.URL, "u", opts.HTTP.URL, "Target URL")
 	flag.StringVar(&opts.HTTP.SNI, "sni", opts.HTTP.SNI, "Target TLS SNI, does not support FUZZ keyword")
 	flag.StringVar(&opts.Input.Extensions, "e", opts.Input.Extensions, "Comma separated list of extensions. Extends FUZZ keyword.")
-	flag.StringVar(&opts.Input.InputMode, "mode", opts.Input.InputMode, "Multi-wordlist operation mode. Available modes: clusterbomb, pitchfork, sniper")
+	flag.StringVar(&opts.Input.InputMode, "mode", opts.Input.InputMode, "Multi-wordlist operation mode. Available modes: clusterbomb, pitchfork, sniper, bandit (split the requests across wordlists of the same keyword, favoring the productive ones)")
+	flag.StringVar(&opts.Input.InputShard, "input-shard", opts.Input.InputShard, "Take only the k-th of every n words of the wordlists, in the format k/n, to split a wordlist across instances. For example \"2/3\"")
 	flag.StringVar(&opts.Input.InputShell, "input-shell", opts.Input.InputShell, "Shell to be used for running command")
+	flag.StringVar(&opts.Input.ReplayFrom, "replay-from", opts.Input.ReplayFrom, "JSON report (-of json) of a previous run to replay the results of, instead of the wordlists. The request of the run is used unless -u or -request is given")
+	flag.Float64Var(&opts.Input.ReplayMinReward, "replay-min-reward", opts.Input.ReplayMinReward, "Smallest Markov reward of the results replayed with -replay-from")
 	flag.StringVar(&opts.Input.Request, "request", opts.Input.Request, "File containing the raw http request")
 	flag.StringVar(&opts.Input.RequestProto, "request-proto", opts.Input.RequestProto, "Protocol to use along with raw request")
 	flag.StringVar(&opts.Matcher.Mode, "mmode", opts.Matcher.Mode, "Matcher set operator. Either of: and, or")
 	flag.StringVar(&opts.Matcher.Lines, "ml", opts.Matcher.Lines, "Match amount of lines in response")
+	flag.StringVar(&opts.Matcher.Hash, "mhash", opts.Matcher.Hash, "Match hash of the response body. Comma separated list of hashes")
+	flag.BoolVar(&opts.Matcher.Reflect, "mreflect", opts.Matcher.Reflect, "Match the responses reflecting a fuzzed value of 4 characters or more in their body or headers")
 	flag.StringVar(&opts.Matcher.Regexp, "mr", opts.Matcher.Regexp, "Match regexp")
 	flag.StringVar(&opts.Matcher.Size, "ms", opts.Matcher.Size, "Match HTTP response size")
 	flag.StringVar(&opts.Matcher.Status, "mc", opts.Matcher.Status, "Match HTTP status codes, or \"all\" for everything.")
@@ -130,8 +197,12 @@ func ParseFlags(opts *ffuf.ConfigOptions) *ffuf.ConfigOptions {
 	flag.StringVar(&opts.Output.AuditLog, "audit-log", opts.Output.AuditLog, "Write audit log containing all requests, responses and config")
 	flag.StringVar(&opts.Output.DebugLog, "debug-log", opts.Output.DebugLog, "Write all of the internal logging to the specified file.")
 	flag.StringVar(&opts.Output.OutputDirectory, "od", opts.Output.OutputDirectory, "Directory path to store matched results to.")
+	flag.IntVar(&opts.Output.OutputDirectoryMaxFiles, "od-max-files", opts.Output.OutputDirectoryMaxFiles, "Maximum number of results stored with -od, 0 for no limit")
+	flag.Float64Var(&opts.Output.OutputDirectoryMinReward, "od-min-reward", opts.Output.OutputDirectoryMinReward, "Smallest Markov reward of the results stored with -od")
+	flag.StringVar(&opts.Output.OutputDirectoryStatus, "od-status", opts.Output.OutputDirectoryStatus, "HTTP status codes of the results stored with -od when -markov is not set, or \"all\"")
 	flag.StringVar(&opts.Output.OutputFile, "o", opts.Output.OutputFile, "Write output to file")
-	flag.StringVar(&opts.Output.OutputFormat, "of", opts.Output.OutputFormat, "Output file format. Available formats: json, ejson, html, md, csv, ecsv (or, 'all' for all formats)")
+	flag.StringVar(&opts.Output.OutputFormat, "of", opts.Output.OutputFormat, "Output file format. Available formats: json, ejson, html, md, csv, ecsv, curl, hurl, tree, tree-json (or, 'all' for all formats)")
+	flag.StringVar(&opts.Output.Sort, "sort", opts.Output.Sort, "Sort the results at the end of the run. Available keys: reward, status, size, url")
 	flag.Var(&autocalibrationstrings, "acc", "Custom auto-calibration string. Can be used multiple times. Implies -ac")
 	flag.Var(&autocalibrationstrategies, "acs", "Custom auto-calibration strategies. Can be used multiple times. Implies -ac")
 	flag.Var(&cookies, "b", "Cookie data `\"NAME1=VALUE1; NAME2=VALUE2\"` for copy as curl functionality.")
@@ -159,6 +230,10 @@ func ParseFlags(opts *ffuf.ConfigOptions) *ffuf.ConfigOptions {
 }
 
 func main() {
+	// The subcommands are handled before the flags of a fuzzing run are parsed
+	if len(os.Args) > 1 && os.Args[1] == "markov" {
+		os.Exit(runMarkovCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
 
 	var err, optserr error
 	ctx, cancel := context.WithCancel(context.Background())
@@ -339,6 +414,9 @@ func SetupFilters(parseOpts *ffuf.ConfigOptions, conf *ffuf.Config) error {
 		if f.Name == "mt" {
 			matcherSet = true
 		}
+		if f.Name == "mreflect" {
+			matcherSet = true
+		}
 		if f.Name == "mw" {
 			matcherSet = true
 			warningIgnoreBody = true
@@ -384,6 +462,12 @@ func SetupFilters(parseOpts *ffuf.ConfigOptions, conf *ffuf.Config) error {
 			errs.Add(err)
 		}
 	}
+	if parseOpts.Filter.Hash != "" {
+		warningIgnoreBody = true
+		if err := conf.MatcherManager.AddFilter("hash", parseOpts.Filter.Hash, false); err != nil {
+			errs.Add(err)
+		}
+	}
 	if parseOpts.Matcher.Size != "" {
 		if err := conf.MatcherManager.AddMatcher("size", parseOpts.Matcher.Size); err != nil {
 			errs.Add(err)
@@ -409,8 +493,18 @@ func SetupFilters(parseOpts *ffuf.ConfigOptions, conf *ffuf.Config) error {
 			errs.Add(err)
 		}
 	}
+	if parseOpts.Matcher.Hash != "" {
+		if err := conf.MatcherManager.AddMatcher("hash", parseOpts.Matcher.Hash); err != nil {
+			errs.Add(err)
+		}
+	}
+	if parseOpts.Matcher.Reflect {
+		if err := conf.MatcherManager.AddMatcher("reflect", ""); err != nil {
+			errs.Add(err)
+		}
+	}
 	if conf.IgnoreBody && warningIgnoreBody {
-		fmt.Printf("*** Warning: possible undesired combination of -ignore-body and the response options: fl,fs,fw,ml,ms and mw.\n")
+		fmt.Printf("*** Warning: possible undesired combination of -ignore-body and the response options: fhash,fl,fs,fw,ml,ms and mw.\n")
 	}
 	return errs.ErrorOrNil()
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+// runMarkovCommand runs the "ffuf markov" subcommands working on saved chain files, and returns the exit code
+func runMarkovCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "diff" {
+		fmt.Fprintf(stderr, "Usage: ffuf markov diff [-json] [-threshold 0.1] [-min-reward 1.0] old.json new.json\n")
+		return 2
+	}
+	return runMarkovDiff(args[1:], stdout, stderr)
+}
+
+// runMarkovDiff compares two chain files saved with -markov-save, for example by two scans of the same target
+func runMarkovDiff(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("markov diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJSON := fs.Bool("json", false, "Write the diff as JSON")
+	threshold := fs.Float64("threshold", markov.DefaultDiffThreshold, "Report the actions whose Q-value changed by more than this")
+	minReward := fs.Float64("min-reward", markov.DefaultDiffMinReward, "Report the new transitions whose action has at least this mean reward")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: ffuf markov diff [options] old.json new.json\n\nCompare two chain files saved with -markov-save.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	before, err := markov.LoadSnapshot(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not load the old chain: %s\n", err)
+		return 1
+	}
+	after, err := markov.LoadSnapshot(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not load the new chain: %s\n", err)
+		return 1
+	}
+	diff, err := markov.DiffSnapshots(before, after, *threshold, *minReward)
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not compare %s and %s: %s\n", fs.Arg(0), fs.Arg(1), err)
+		return 1
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(diff)
+	} else {
+		err = diff.WriteTable(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not write the diff: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -89,6 +89,10 @@ func (j *Job) calibrationRequest(inputs map[string][]byte) (Response, error) {
 		log.Printf("%s", err)
 		return Response{}, err
 	}
+	j.applyCookieJar(&req)
+	if !j.spendBudget(&req, budgetCalibration) {
+		return Response{}, fmt.Errorf("Request budget spent")
+	}
 	resp, err := j.Runner.Execute(&req)
 	if err != nil {
 		j.Output.Error(fmt.Sprintf("Encountered an error while executing autocalibration request: %s\n", err))
@@ -96,6 +100,10 @@ func (j *Job) calibrationRequest(inputs map[string][]byte) (Response, error) {
 		log.Printf("%s", err)
 		return Response{}, err
 	}
+	// The counts of a body cut off would make a filter missing the complete responses
+	if !resp.Complete {
+		return resp, fmt.Errorf("Response was cut off")
+	}
 	// Only calibrate on responses that would be matched otherwise
 	if j.isMatch(resp) {
 		return resp, nil
@@ -135,6 +143,38 @@ func (j *Job) CalibrateForHost(host string, baseinput map[string][]byte) error {
 	return nil
 }
 
+// CalibrateForRoot runs autocalibration for a recursion root, the filters applying to the recursion jobs under it
+func (j *Job) CalibrateForRoot(root string, baseinput map[string][]byte) error {
+	if j.calibratedRoots[root] {
+		return nil
+	}
+	if baseinput[j.Config.AutoCalibrationKeyword] == nil {
+		return fmt.Errorf("Autocalibration keyword \"%s\" not found in the request.", j.Config.AutoCalibrationKeyword)
+	}
+	cStrings := j.autoCalibrationStrings()
+	input := make(map[string][]byte)
+	for k, v := range baseinput {
+		input[k] = v
+	}
+	for _, v := range cStrings {
+		responses := make([]Response, 0)
+		for _, cs := range v {
+			input[j.Config.AutoCalibrationKeyword] = []byte(cs)
+			resp, err := j.calibrationRequest(input)
+			if err != nil {
+				continue
+			}
+			responses = append(responses, resp)
+		}
+		_ = j.calibrateScopedFilters(responses, false, root)
+	}
+	if j.calibratedRoots == nil {
+		j.calibratedRoots = make(map[string]bool)
+	}
+	j.calibratedRoots[root] = true
+	return nil
+}
+
 // CalibrateResponses returns slice of Responses for randomly generated filter autocalibration requests
 func (j *Job) Calibrate(input map[string][]byte) error {
 	if j.Config.MatcherManager.Calibrated() {
@@ -170,10 +210,18 @@ func (j *Job) CalibrateIfNeeded(host string, input map[string][]byte) error {
 	if j.Config.AutoCalibrationPerHost {
 		return j.CalibrateForHost(host, input)
 	}
+	if j.Config.AutoCalibrationPerRoot {
+		return j.CalibrateForRoot(j.recursionRoot, input)
+	}
 	return j.Calibrate(input)
 }
 
 func (j *Job) calibrateFilters(responses []Response, perHost bool) error {
+	return j.calibrateScopedFilters(responses, perHost, "")
+}
+
+// calibrateScopedFilters calibrates the filters from the responses, adding them to the recursion root if given
+func (j *Job) calibrateScopedFilters(responses []Response, perHost bool, root string) error {
 	// Work down from the most specific common denominator
 	if len(responses) > 0 {
 		// Content length
@@ -185,29 +233,8 @@ func (j *Job) calibrateFilters(responses []Response, perHost bool) error {
 			}
 		}
 		if sizeMatch {
-			if perHost {
-				// Check if already filtered
-				for _, f := range j.Config.MatcherManager.FiltersForDomain(HostURLFromRequest(*responses[0].Request)) {
-					match, _ := f.Filter(&responses[0])
-					if match {
-						// Already filtered
-						return nil
-					}
-				}
-				_ = j.Config.MatcherManager.AddPerDomainFilter(HostURLFromRequest(*responses[0].Request), "size", strconv.FormatInt(baselineSize, 10))
-				return nil
-			} else {
-				// Check if already filtered
-				for _, f := range j.Config.MatcherManager.GetFilters() {
-					match, _ := f.Filter(&responses[0])
-					if match {
-						// Already filtered
-						return nil
-					}
-				}
-				_ = j.Config.MatcherManager.AddFilter("size", strconv.FormatInt(baselineSize, 10), false)
-				return nil
-			}
+			j.addCalibrationFilter(responses[0], perHost, root, "size", strconv.FormatInt(baselineSize, 10))
+			return nil
 		}
 
 		// Content words
@@ -219,29 +246,8 @@ func (j *Job) calibrateFilters(responses []Response, perHost bool) error {
 			}
 		}
 		if wordsMatch {
-			if perHost {
-				// Check if already filtered
-				for _, f := range j.Config.MatcherManager.FiltersForDomain(HostURLFromRequest(*responses[0].Request)) {
-					match, _ := f.Filter(&responses[0])
-					if match {
-						// Already filtered
-						return nil
-					}
-				}
-				_ = j.Config.MatcherManager.AddPerDomainFilter(HostURLFromRequest(*responses[0].Request), "word", strconv.FormatInt(baselineWords, 10))
-				return nil
-			} else {
-				// Check if already filtered
-				for _, f := range j.Config.MatcherManager.GetFilters() {
-					match, _ := f.Filter(&responses[0])
-					if match {
-						// Already filtered
-						return nil
-					}
-				}
-				_ = j.Config.MatcherManager.AddFilter("word", strconv.FormatInt(baselineWords, 10), false)
-				return nil
-			}
+			j.addCalibrationFilter(responses[0], perHost, root, "word", strconv.FormatInt(baselineWords, 10))
+			return nil
 		}
 
 		// Content lines
@@ -253,30 +259,41 @@ func (j *Job) calibrateFilters(responses []Response, perHost bool) error {
 			}
 		}
 		if linesMatch {
-			if perHost {
-				// Check if already filtered
-				for _, f := range j.Config.MatcherManager.FiltersForDomain(HostURLFromRequest(*responses[0].Request)) {
-					match, _ := f.Filter(&responses[0])
-					if match {
-						// Already filtered
-						return nil
-					}
-				}
-				_ = j.Config.MatcherManager.AddPerDomainFilter(HostURLFromRequest(*responses[0].Request), "line", strconv.FormatInt(baselineLines, 10))
-				return nil
-			} else {
-				// Check if already filtered
-				for _, f := range j.Config.MatcherManager.GetFilters() {
-					match, _ := f.Filter(&responses[0])
-					if match {
-						// Already filtered
-						return nil
-					}
-				}
-				_ = j.Config.MatcherManager.AddFilter("line", strconv.FormatInt(baselineLines, 10), false)
-				return nil
-			}
+			j.addCalibrationFilter(responses[0], perHost, root, "line", strconv.FormatInt(baselineLines, 10))
+			return nil
 		}
 	}
 	return fmt.Errorf("No common filtering values found")
 }
+
+// addCalibrationFilter adds a calibrated filter globally, for the host of the response or to the recursion root,
+// unless the response is already filtered there
+func (j *Job) addCalibrationFilter(resp Response, perHost bool, root string, name string, value string) {
+	var filters map[string]FilterProvider
+	switch {
+	case root != "":
+		filters = j.Config.MatcherManager.FiltersForRoot(root)
+		for n, f := range j.Config.MatcherManager.GetFilters() {
+			filters[n] = f
+		}
+	case perHost:
+		filters = j.Config.MatcherManager.FiltersForDomain(HostURLFromRequest(*resp.Request))
+	default:
+		filters = j.Config.MatcherManager.GetFilters()
+	}
+	// Check if already filtered
+	for _, f := range filters {
+		match, _ := f.Filter(&resp)
+		if match {
+			return
+		}
+	}
+	switch {
+	case root != "":
+		_ = j.Config.MatcherManager.AddScopedFilter(root, name, value, false)
+	case perHost:
+		_ = j.Config.MatcherManager.AddPerDomainFilter(HostURLFromRequest(*resp.Request), name, value)
+	default:
+		_ = j.Config.MatcherManager.AddFilter(name, value, false)
+	}
+}
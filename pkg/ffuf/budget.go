@@ -0,0 +1,131 @@
+package ffuf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+// Categories the requests of -request-budget are spent on, the requeued inputs being spent on their origin
+const (
+	budgetCalibration = "calibration"
+	budgetSeeding     = "seeding"
+	budgetExploration = "exploration"
+	budgetRanked      = "ranked"
+	budgetRetries     = "retries"
+	budgetReplay      = "replay"
+)
+
+// requestBudget is the number of requests of -request-budget, including the retries, and how they were spent
+type requestBudget struct {
+	mutex      sync.Mutex
+	limit      int
+	explore    int // requests of the limit for the calibration and the cold state exploration
+	spent      int
+	byCategory map[string]int
+}
+
+func newRequestBudget(limit int, exploreShare float64) *requestBudget {
+	return &requestBudget{limit: limit, explore: int(float64(limit) * exploreShare), byCategory: make(map[string]int)}
+}
+
+// reserve spends a request of the budget on a category before it is sent, false once the budget is spent
+func (b *requestBudget) reserve(category string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.spent >= b.limit {
+		return false
+	}
+	b.spent++
+	b.byCategory[category]++
+	return true
+}
+
+// allowRetry spends a request of the budget on a retry, see Request.AllowRetry
+func (b *requestBudget) allowRetry() bool {
+	return b.reserve(budgetRetries)
+}
+
+// exploreSpent returns true once the calibration and the cold state exploration have spent their share of the budget
+func (b *requestBudget) exploreSpent() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.byCategory[budgetCalibration]+b.byCategory[budgetExploration] >= b.explore
+}
+
+// Spent returns the number of requests spent, and their number by category
+func (b *requestBudget) Spent() (int, map[string]int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	byCategory := make(map[string]int, len(b.byCategory))
+	for c, n := range b.byCategory {
+		byCategory[c] = n
+	}
+	return b.spent, byCategory
+}
+
+// Summary returns how the budget was spent, the categories spending the most first
+func (b *requestBudget) Summary() string {
+	spent, byCategory := b.Spent()
+	categories := make([]string, 0, len(byCategory))
+	for c := range byCategory {
+		categories = append(categories, c)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if byCategory[categories[i]] != byCategory[categories[j]] {
+			return byCategory[categories[i]] > byCategory[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	parts := make([]string, 0, len(categories))
+	for _, c := range categories {
+		parts = append(parts, fmt.Sprintf("%d %s", byCategory[c], c))
+	}
+	return fmt.Sprintf("Request budget: spent %d of %d requests: %s", spent, b.limit, strings.Join(parts, ", "))
+}
+
+// budgetCategory returns the category an input spends the budget on: the origin of a requeued input, without the
+// method of a method probe, or whether the Markov chain sent it exploring a never seen state or ranked
+func budgetCategory(origin string, decision string) string {
+	if origin != "" {
+		return strings.SplitN(origin, " ", 2)[0]
+	}
+	if strings.HasPrefix(decision, markov.ColdStateDecision) {
+		return budgetExploration
+	}
+	return budgetRanked
+}
+
+// spendBudget spends a request of the -request-budget on a category before it is sent, its retries spending the
+// budget too. Returns false, the request not to be sent, once the budget is spent. Always true without a budget.
+func (j *Job) spendBudget(req *Request, category string) bool {
+	if j.budget == nil {
+		return true
+	}
+	if !j.budget.reserve(category) {
+		return false
+	}
+	req.AllowRetry = j.budget.allowRetry
+	return true
+}
+
+// spendInputBudget spends a request of the -request-budget on the next input of the job, stopping the job once the
+// budget is spent. The cold state exploration of the Markov chain ends once it has spent its share of the budget.
+func (j *Job) spendInputBudget(origin string, decision string) bool {
+	if j.budget == nil {
+		return true
+	}
+	category := budgetCategory(origin, decision)
+	if !j.budget.reserve(category) {
+		j.Error = fmt.Sprintf("Request budget of %d requests spent, exiting.", j.Config.RequestBudget)
+		j.Running = false
+		return false
+	}
+	if category == budgetExploration && j.budget.exploreSpent() {
+		j.MarkovChain.SetColdStateBudget(0)
+	}
+	return true
+}
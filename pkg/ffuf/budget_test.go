@@ -0,0 +1,128 @@
+package ffuf
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// runBudgetJob runs a job spending at most the budget, the upload path answered like answerMethods does and the
+// requests of the flaky paths failing on a transport error once, retried like the runner does when the request
+// allows it. It returns the number of requests sent including the retries, and the number of retries.
+func runBudgetJob(t *testing.T, budget int, words []string) (*Job, int, int) {
+	var retries int32
+	answer := answerMethods(map[string]string{"upload": "GET, POST, PUT"})
+	runner := newFakeRunner(func(req *Request, resp *Response) error {
+		if strings.HasPrefix(fuzzToken(req), "flaky") {
+			if req.AllowRetry != nil && !req.AllowRetry() {
+				return fmt.Errorf("connection reset")
+			}
+			atomic.AddInt32(&retries, 1)
+		}
+		return answer(req, resp)
+	})
+	j := newFakeJob(t, runner, words, func(conf *Config) {
+		conf.Threads = 4
+		conf.MarkovColdBudget = 20
+		conf.RequestBudget = budget
+		conf.RequestBudgetExplore = 0.1
+		conf.MatcherManager = matchStatus(200, 301)
+	})
+	j.Start()
+	return j, len(runner.sent()) + int(retries), int(retries)
+}
+
+// budgetWords returns n words, one of them answered with 405 and every tenth flaky
+func budgetWords(n int) []string {
+	words := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if i == 5 {
+			words = append(words, "upload")
+		} else if i%10 == 0 {
+			words = append(words, fmt.Sprintf("flaky%d", i))
+		} else {
+			words = append(words, fmt.Sprintf("word%d", i))
+		}
+	}
+	return words
+}
+
+func TestRequestBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		budget  int
+		words   int
+		stopped bool
+	}{
+		{"hard-stop", 50, 200, true},
+		{"not-spent", 1000, 20, false},
+	}
+	for _, tt := range tests {
+		j, attempts, _ := runBudgetJob(t, tt.budget, budgetWords(tt.words))
+		spent, _ := j.budget.Spent()
+		if spent != attempts {
+			t.Errorf("%s: expected the %d requests sent including the retries spent, got %d", tt.name, attempts, spent)
+		}
+		if !tt.stopped {
+			if spent >= tt.budget || j.Error != "" {
+				t.Errorf("%s: expected the job to run to the end of the wordlist, got %d requests spent and %q", tt.name, spent, j.Error)
+			}
+			continue
+		}
+		if attempts != tt.budget {
+			t.Errorf("%s: expected exactly %d requests sent including the retries, got %d", tt.name, tt.budget, attempts)
+		}
+		if !strings.HasPrefix(j.Error, fmt.Sprintf("Request budget of %d requests spent", tt.budget)) {
+			t.Errorf("%s: expected the job stopped by the budget, got %q", tt.name, j.Error)
+		}
+		if j.Running {
+			t.Errorf("%s: expected the job not to go on with the next queued jobs", tt.name)
+		}
+	}
+}
+
+func TestRequestBudgetAccounting(t *testing.T) {
+	j, attempts, retries := runBudgetJob(t, 60, budgetWords(200))
+	spent, byCategory := j.budget.Spent()
+	sum := 0
+	for _, n := range byCategory {
+		sum += n
+	}
+	if sum != spent || spent != attempts {
+		t.Errorf("Expected the categories to add up to the %d requests sent, got %d of %v", attempts, sum, byCategory)
+	}
+	// The cold state exploration stops at 10% of the budget, the upload path is probed with POST and PUT, and every
+	// flaky path sent is retried once
+	if byCategory[budgetExploration] != 6 {
+		t.Errorf("Expected 6 requests exploring the cold state, got %d", byCategory[budgetExploration])
+	}
+	if byCategory[methodProbeOrigin] != 2 {
+		t.Errorf("Expected the 2 method probes accounted for, got %d", byCategory[methodProbeOrigin])
+	}
+	if retries == 0 || byCategory[budgetRetries] != retries {
+		t.Errorf("Expected the %d retries of the flaky paths accounted for, got %d", retries, byCategory[budgetRetries])
+	}
+	if summary := j.budget.Summary(); !strings.HasPrefix(summary, "Request budget: spent 60 of 60 requests: ") || !strings.Contains(summary, "2 method-probe") {
+		t.Errorf("Unexpected budget summary: %s", summary)
+	}
+}
+
+func TestBudgetCategory(t *testing.T) {
+	tests := []struct {
+		origin   string
+		decision string
+		expected string
+	}{
+		{"", "", budgetRanked},
+		{"", "wordlist order", budgetRanked},
+		{"", "cold state 4xx_100_0, wordlist order", budgetExploration},
+		{"method-probe POST", "", "method-probe"},
+		{"dir-probe", "", "dir-probe"},
+	}
+	for _, tt := range tests {
+		if got := budgetCategory(tt.origin, tt.decision); got != tt.expected {
+			t.Errorf("budgetCategory(%q, %q): expected %s, got %s", tt.origin, tt.decision, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package ffuf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// caseProbeOrigin is the origin of the requests probing whether a target is case-insensitive
+const caseProbeOrigin = "case-probe"
+
+// caseProbe is the probe of a recursion root for a case-insensitive target: another casing of a matched token, the
+// target being case-insensitive if it returns the same response
+type caseProbe struct {
+	token       string // casing of the matched token sent as the probe
+	matched     string // matched token
+	status      int64
+	hash        string
+	done        bool
+	insensitive bool
+}
+
+// otherCasing returns the token in lower case, or in upper case if it is in lower case already. Empty for the tokens
+// without letters.
+func otherCasing(token string) string {
+	if lower := strings.ToLower(token); lower != token {
+		return lower
+	}
+	if upper := strings.ToUpper(token); upper != token {
+		return upper
+	}
+	return ""
+}
+
+// requeueCaseProbe queues another casing of the first matched FUZZ token of a path, once for each recursion root, to
+// find out whether the target is case-insensitive
+func (j *Job) requeueCaseProbe(input map[string][]byte, resp Response) {
+	token, ok := input["FUZZ"]
+	if !ok || j.Config.KeywordLocations["FUZZ"] != "path" || resp.BodyHash == "" {
+		return
+	}
+	other := otherCasing(string(token))
+	if other == "" {
+		return
+	}
+	j.requeueMutex.Lock()
+	if _, probed := j.caseProbes[j.recursionRoot]; probed {
+		j.requeueMutex.Unlock()
+		return
+	}
+	if j.caseProbes == nil {
+		j.caseProbes = make(map[string]caseProbe)
+	}
+	j.caseProbes[j.recursionRoot] = caseProbe{token: other, matched: string(token), status: resp.StatusCode, hash: resp.BodyHash}
+	j.requeueMutex.Unlock()
+
+	generated := make(map[string][]byte, len(input))
+	for k, v := range input {
+		if k != "FFUFHASH" {
+			generated[k] = v
+		}
+	}
+	generated["FUZZ"] = []byte(other)
+	j.requeue(generated, caseProbeOrigin)
+}
+
+// checkCaseProbe compares the response to a case probe with the one of the matched token it is a casing of. The
+// target is only found case-insensitive when both have the same status and body hash.
+func (j *Job) checkCaseProbe(input map[string][]byte, resp Response) {
+	j.requeueMutex.Lock()
+	probe, ok := j.caseProbes[j.recursionRoot]
+	if !ok || probe.done || probe.token != string(input["FUZZ"]) {
+		j.requeueMutex.Unlock()
+		return
+	}
+	probe.done = true
+	probe.insensitive = resp.StatusCode == probe.status && resp.BodyHash == probe.hash
+	j.caseProbes[j.recursionRoot] = probe
+	j.requeueMutex.Unlock()
+
+	if probe.insensitive {
+		j.MarkovChain.SetCaseInsensitive(true)
+		j.Output.Info(fmt.Sprintf("The target is case-insensitive, %s and %s returning the same response: skipping the words differing from the ones sent only by their case", probe.matched, probe.token))
+	}
+}
+
+// caseFolded reports whether an input has upper case letters in a path keyword, making it a case duplicate of its
+// lower case version on a case-insensitive target
+func (j *Job) caseFolded(input map[string][]byte) bool {
+	for k, v := range input {
+		if j.Config.KeywordLocations[k] == "path" && strings.ToLower(string(v)) != string(v) {
+			return true
+		}
+	}
+	return false
+}
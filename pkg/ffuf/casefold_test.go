@@ -0,0 +1,76 @@
+package ffuf
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOtherCasing(t *testing.T) {
+	tests := map[string]string{"Admin": "admin", "admin": "ADMIN", "ADMIN": "admin", "1234": "", "": ""}
+	for token, expected := range tests {
+		if got := otherCasing(token); got != expected {
+			t.Errorf("%s: expected %q, got %q", token, expected, got)
+		}
+	}
+}
+
+// answerPaths returns a handler serving the existing paths, ignoring their case when insensitive, each with a body
+// of its own
+func answerPaths(existing []string, insensitive bool) fakeHandler {
+	return func(req *Request, resp *Response) error {
+		resp.BodyHash = "notfound"
+		token := fuzzToken(req)
+		for _, path := range existing {
+			if token == path || (insensitive && strings.EqualFold(token, path)) {
+				resp.StatusCode = 200
+				resp.BodyHash = "page-" + path
+			}
+		}
+		return nil
+	}
+}
+
+func TestCaseFolding(t *testing.T) {
+	words := []string{"Admin", "login", "ADMIN", "Login", "LOGIN", "other", "Other"}
+	tests := []struct {
+		name        string
+		existing    []string
+		insensitive bool
+		words       []string
+		once        []string
+		skipped     []string
+		duplicates  int64
+	}{
+		{"insensitive", []string{"admin", "login"}, true, words, []string{"admin"}, []string{"ADMIN", "Login", "LOGIN", "Other"}, 4},
+		{"sensitive", []string{"Admin", "login"}, false, words, append([]string{"admin"}, words...), nil, 0},
+		// The pages echo the requested path, the probe is not the same response
+		{"different-hashes", []string{"admin", "Admin"}, false, []string{"Admin", "ADMIN"}, []string{"ADMIN"}, nil, 0},
+	}
+	for _, tt := range tests {
+		runner := newFakeRunner(answerPaths(tt.existing, tt.insensitive))
+		j := newFakeJob(t, runner, tt.words, func(conf *Config) {
+			conf.KeywordLocations["FUZZ"] = "path"
+			conf.MatcherManager = matchStatus(200, 301)
+		})
+		j.Start()
+
+		if j.MarkovChain.CaseInsensitive() != tt.insensitive {
+			t.Errorf("%s: expected the target found case-insensitive to be %t", tt.name, tt.insensitive)
+		}
+		sent := runner.counts(false)
+		for _, word := range tt.once {
+			if sent[word] != 1 {
+				t.Errorf("%s: expected %s to be sent once, got %d", tt.name, word, sent[word])
+			}
+		}
+		for _, word := range tt.skipped {
+			if sent[word] != 0 {
+				t.Errorf("%s: expected the case duplicate %s to be skipped", tt.name, word)
+			}
+		}
+		if got := atomic.LoadInt64(&j.metrics.caseDuplicates); got != tt.duplicates {
+			t.Errorf("%s: expected %d case duplicates, got %d", tt.name, tt.duplicates, got)
+		}
+	}
+}
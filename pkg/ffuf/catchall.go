@@ -0,0 +1,84 @@
+package ffuf
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	// catchAllSamples is the number of responses the catch-all detection looks at
+	catchAllSamples = 200
+	// catchAllDominance is the share of the responses that need to be the same matched 2xx body
+	catchAllDominance = 0.95
+)
+
+// catchAllSample is a response seen by the catch-all detection
+type catchAllSample struct {
+	hash    string
+	matched bool
+}
+
+// catchAllDetector recognizes a target answering every path with the same page, like the shell of a single page
+// application, from the matched 2xx responses of a window being dominated by a single body hash
+type catchAllDetector struct {
+	window   [catchAllSamples]catchAllSample
+	next     int
+	fired    bool
+	evidence string
+	mutex    sync.Mutex
+}
+
+// record adds a response to the window, and reports whether it completed a window showing a catch-all target. A
+// detection is only reported once.
+func (c *catchAllDetector) record(resp *Response, matched bool) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.fired {
+		return false
+	}
+	sample := catchAllSample{matched: matched && resp.StatusCode >= 200 && resp.StatusCode < 300}
+	if sample.matched {
+		sample.hash = resp.BodyHash
+		if sample.hash == "" {
+			sample.hash = fmt.Sprintf("size %d", resp.ContentLength)
+		}
+	}
+	c.window[c.next] = sample
+	c.next = (c.next + 1) % len(c.window)
+	if c.next != 0 {
+		return false
+	}
+
+	counts := make(map[string]int)
+	dominant := ""
+	for _, s := range c.window {
+		if !s.matched {
+			continue
+		}
+		counts[s.hash]++
+		if counts[s.hash] > counts[dominant] {
+			dominant = s.hash
+		}
+	}
+	if float64(counts[dominant]) <= catchAllDominance*float64(len(c.window)) {
+		return false
+	}
+	c.fired = true
+	c.evidence = fmt.Sprintf("%d of %d responses were matched 2xx responses with the same body (%s)", counts[dominant], len(c.window), dominant)
+	return true
+}
+
+// Evidence describes the responses that triggered the detection, empty if it did not fire
+func (c *catchAllDetector) Evidence() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.evidence
+}
+
+// catchAllWarning returns the warning printed when a catch-all target is detected
+func catchAllWarning(evidence string) string {
+	return fmt.Sprintf("The target looks like a catch-all answering every path with the same page, like a single page application: %s. "+
+		"Nothing can be learned from these responses, filter them out with -fs or -fhash, or use a wordlist aimed at its API", evidence)
+}
@@ -0,0 +1,73 @@
+package ffuf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordCatchAll records n responses given by the response function, reporting whether the detection fired
+func recordCatchAll(c *catchAllDetector, n int, response func(i int) (Response, bool)) int {
+	fired := 0
+	for i := 0; i < n; i++ {
+		resp, matched := response(i)
+		if c.record(&resp, matched) {
+			fired++
+		}
+	}
+	return fired
+}
+
+func TestCatchAllDetectorFires(t *testing.T) {
+	var c catchAllDetector
+	// Every path gets the same application shell, a few assets differing
+	fired := recordCatchAll(&c, 3*catchAllSamples, func(i int) (Response, bool) {
+		if i%50 == 0 {
+			return Response{StatusCode: 200, BodyHash: fmt.Sprintf("asset-%d", i)}, true
+		}
+		return Response{StatusCode: 200, BodyHash: "shell"}, true
+	})
+	if fired != 1 {
+		t.Fatalf("Expected the catch-all to be reported once, got %d", fired)
+	}
+	if evidence := c.Evidence(); !strings.Contains(evidence, "shell") {
+		t.Errorf("Expected the evidence to name the dominant body, got %q", evidence)
+	}
+}
+
+func TestCatchAllDetectorDoesNotFire(t *testing.T) {
+	tests := []struct {
+		name     string
+		response func(i int) (Response, bool)
+	}{
+		{"soft 404", func(i int) (Response, bool) {
+			// The same not found page with a 404 status, the target being fine to fuzz
+			return Response{StatusCode: 404, BodyHash: "not found"}, true
+		}},
+		{"match rich", func(i int) (Response, bool) {
+			return Response{StatusCode: 200, BodyHash: fmt.Sprintf("page-%d", i)}, true
+		}},
+		{"filtered shell", func(i int) (Response, bool) {
+			// The shell is filtered out already, the few matches being real
+			if i%10 == 0 {
+				return Response{StatusCode: 200, BodyHash: fmt.Sprintf("page-%d", i)}, true
+			}
+			return Response{StatusCode: 200, BodyHash: "shell"}, false
+		}},
+		{"mostly shell", func(i int) (Response, bool) {
+			// 90% of the responses being the shell is not enough
+			if i%10 == 0 {
+				return Response{StatusCode: 200, BodyHash: fmt.Sprintf("page-%d", i)}, true
+			}
+			return Response{StatusCode: 200, BodyHash: "shell"}, true
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c catchAllDetector
+			if fired := recordCatchAll(&c, 3*catchAllSamples, tt.response); fired != 0 {
+				t.Errorf("Expected no catch-all detection, got %d: %s", fired, c.Evidence())
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package ffuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// checkpointKeep is the number of checkpoints kept in -checkpoint-dir, the older ones being removed
+	checkpointKeep = 3
+	// checkpointPrefix is the prefix of the names of the checkpoints in -checkpoint-dir
+	checkpointPrefix = "checkpoint-"
+	// checkpointFile is the file of a checkpoint holding the state of the job
+	checkpointFile = "checkpoint.json"
+	// checkpointChainFile is the file of a checkpoint holding the Markov chain
+	checkpointChainFile = "chain.gob"
+)
+
+// Checkpoint is the complete state of a scan written to -checkpoint-dir: the queue jobs and the position in the
+// current one, the inputs the job queued itself or was sending, the inputs sent so far and the results. The Markov
+// chain is saved next to it.
+type Checkpoint struct {
+	Time        string               `json:"time"`
+	CommandLine string               `json:"commandline"`
+	QueueJobs   []CheckpointQueueJob `json:"queue_jobs"`
+	QueuePos    int                  `json:"queue_pos"` // index of the current queue job
+	Position    int                  `json:"position"`  // position of the input provider in the current queue job
+	Total       int                  `json:"total"`     // total of the input provider, to detect a changed wordlist
+	Counter     int                  `json:"counter"`
+	Pending     []CheckpointInput    `json:"pending"`
+	Sent        CheckpointSentCache  `json:"sent"`
+	Results     []Result             `json:"results"`
+}
+
+// CheckpointQueueJob is a recursion or sniper queue job of a checkpoint
+type CheckpointQueueJob struct {
+	Url     string  `json:"url"`
+	Depth   int     `json:"depth"`
+	Request Request `json:"request"`
+}
+
+// CheckpointInput is an input of a checkpoint waiting to be sent: requeued by the job, being sent when the checkpoint
+// was written or left in the current batch of the Markov chain, in the order they are sent after resuming
+type CheckpointInput struct {
+	Input    map[string][]byte `json:"input"`
+	Position int               `json:"position"`
+	Origin   string            `json:"origin"`
+}
+
+// CheckpointSentCache is the content of the cache of the inputs sent by the current queue job
+type CheckpointSentCache struct {
+	Hashes []uint64 `json:"hashes"`
+	Bloom  []uint64 `json:"bloom,omitempty"`
+}
+
+// LoadCheckpoint reads a checkpoint, either a directory of -checkpoint-dir, the latest checkpoint in it being read, or
+// a single checkpoint in it. Returns the checkpoint along with its directory.
+func LoadCheckpoint(path string) (*Checkpoint, string, error) {
+	dir := path
+	if _, err := os.Stat(filepath.Join(path, checkpointFile)); err != nil {
+		checkpoints, err := listCheckpoints(path)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(checkpoints) == 0 {
+			return nil, "", fmt.Errorf("no checkpoint found in %s", path)
+		}
+		dir = checkpoints[len(checkpoints)-1]
+	}
+	data, err := os.ReadFile(filepath.Join(dir, checkpointFile))
+	if err != nil {
+		return nil, "", err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, "", fmt.Errorf("invalid checkpoint %s: %s", dir, err)
+	}
+	if cp.QueuePos < 0 || cp.QueuePos >= len(cp.QueueJobs) {
+		return nil, "", fmt.Errorf("invalid checkpoint %s: no current queue job", dir)
+	}
+	return &cp, dir, nil
+}
+
+// listCheckpoints returns the checkpoints of a -checkpoint-dir directory, the oldest first
+func listCheckpoints(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := make([]string, 0)
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), checkpointPrefix) {
+			checkpoints = append(checkpoints, filepath.Join(dir, e.Name()))
+		}
+	}
+	// The names are zero padded timestamps
+	sort.Strings(checkpoints)
+	return checkpoints, nil
+}
+
+// startCheckpoints writes a checkpoint to -checkpoint-dir every -checkpoint-interval. The returned function stops the
+// checkpoints after a last one, written whether the scan was completed or stopped.
+func (j *Job) startCheckpoints() func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(j.Config.CheckpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				j.writeCheckpoint()
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		j.writeCheckpoint()
+	}
+}
+
+// writeCheckpoint writes a checkpoint of the job to -checkpoint-dir, logging the failures
+func (j *Job) writeCheckpoint() {
+	dir, err := saveCheckpoint(j.Config.CheckpointDir, j.checkpoint(), j.saveCheckpointChain)
+	if err != nil {
+		j.Output.Warning(fmt.Sprintf("Could not write the checkpoint to %s: %s", j.Config.CheckpointDir, err))
+		return
+	}
+	if j.Config.Verbose {
+		j.Output.Info(fmt.Sprintf("Checkpoint written to %s", dir))
+	}
+}
+
+func (j *Job) saveCheckpointChain(filename string) error {
+	if j.MarkovChain == nil {
+		return nil
+	}
+	return j.MarkovChain.SaveChain(filename)
+}
+
+// checkpoint returns the current state of the job. The inputs are not drawn from the input provider meanwhile, for
+// every input taken from it to either be pending or sent.
+func (j *Job) checkpoint() *Checkpoint {
+	j.checkpointMutex.Lock()
+	defer j.checkpointMutex.Unlock()
+
+	cp := &Checkpoint{
+		Time:        time.Now().Format(time.RFC3339),
+		CommandLine: j.Config.CommandLine,
+		Position:    j.Input.Position(),
+		Total:       j.Input.Total(),
+		Counter:     j.requestCount(),
+		Pending:     make([]CheckpointInput, 0),
+		Sent:        j.sent.snapshot(),
+	}
+	j.queueMutex.Lock()
+	cp.QueueJobs = make([]CheckpointQueueJob, 0, len(j.queuejobs))
+	cp.QueuePos = j.queuepos - 1
+	for _, qj := range j.queuejobs {
+		cp.QueueJobs = append(cp.QueueJobs, CheckpointQueueJob{Url: qj.Url, Depth: qj.depth, Request: qj.req})
+	}
+	j.queueMutex.Unlock()
+	j.requeueMutex.Lock()
+	// The inputs being sent first, they were taken before the requeued ones
+	ids := make([]int, 0, len(j.inflight))
+	for id := range j.inflight {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		r := j.inflight[id]
+		cp.Pending = append(cp.Pending, CheckpointInput{Input: r.input, Position: r.position, Origin: r.origin})
+	}
+	for _, r := range j.requeued {
+		cp.Pending = append(cp.Pending, CheckpointInput{Input: r.input, Position: r.position, Origin: r.origin})
+	}
+	j.requeueMutex.Unlock()
+	if j.MarkovChain != nil {
+		inputs, _ := j.MarkovChain.Pending(j.MarkovChain.Total())
+		for _, input := range inputs {
+			cp.Pending = append(cp.Pending, CheckpointInput{Input: input})
+		}
+	}
+	cp.Results = append(cp.Results, j.checkpointResults()...)
+	return cp
+}
+
+// checkpointResults returns the results of all the queue jobs so far
+func (j *Job) checkpointResults() []Result {
+	if rp, ok := j.Output.(ResultsProvider); ok {
+		return rp.GetResults()
+	}
+	return j.Output.GetCurrentResults()
+}
+
+// saveCheckpoint writes a checkpoint to a new directory of dir, removing the oldest ones past checkpointKeep. The
+// checkpoint is written to a temporary directory renamed once complete, for a stopped or killed process to never
+// leave a partial checkpoint behind. Returns the directory of the checkpoint.
+func saveCheckpoint(dir string, cp *Checkpoint, saveChain func(string) error) (string, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(dir, ".checkpoint-")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, checkpointFile), data, 0640); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	if err := saveChain(filepath.Join(tmp, checkpointChainFile)); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	target := filepath.Join(dir, fmt.Sprintf("%s%020d", checkpointPrefix, time.Now().UnixNano()))
+	if err := os.Rename(tmp, target); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	checkpoints, err := listCheckpoints(dir)
+	if err != nil {
+		return target, err
+	}
+	for len(checkpoints) > checkpointKeep {
+		if err := os.RemoveAll(checkpoints[0]); err != nil {
+			return target, err
+		}
+		checkpoints = checkpoints[1:]
+	}
+	return target, nil
+}
+
+// restoreCheckpoint restores the queue jobs, the Markov chain and the results of the checkpoint of
+// -resume-checkpoint. The state of the current queue job is restored by resumeQueueJob once it is started.
+func (j *Job) restoreCheckpoint() {
+	cp, dir, err := LoadCheckpoint(j.Config.ResumeCheckpoint)
+	if err != nil {
+		j.Output.Error(fmt.Sprintf("Could not read the checkpoint, starting from scratch: %s", err))
+		return
+	}
+	if cp.Total != j.Input.Total() {
+		j.Output.Warning(fmt.Sprintf("The checkpoint was written with %d inputs, the run has %d", cp.Total, j.Input.Total()))
+	}
+	j.queueMutex.Lock()
+	j.queuejobs = make([]QueueJob, 0, len(cp.QueueJobs))
+	for _, qj := range cp.QueueJobs {
+		j.queuejobs = append(j.queuejobs, QueueJob{Url: qj.Url, depth: qj.Depth, req: qj.Request})
+	}
+	j.queuepos = cp.QueuePos
+	j.queueMutex.Unlock()
+	if j.MarkovChain != nil {
+		chain := filepath.Join(dir, checkpointChainFile)
+		if _, err := os.Stat(chain); err == nil {
+			warnings, err := j.MarkovChain.LoadChain(chain)
+			if err != nil {
+				j.Output.Warning(fmt.Sprintf("Could not restore the Markov chain of the checkpoint: %s", err))
+			}
+			for _, w := range warnings {
+				j.Output.Warning(w)
+			}
+		}
+	}
+	// Moved to the results of the previous queue jobs when the current one starts
+	j.Output.SetCurrentResults(cp.Results)
+	j.resume = cp
+	if !j.Config.Quiet {
+		j.Output.Info(fmt.Sprintf("Resuming from the checkpoint %s, queue job %d of %d at position %d", dir, cp.QueuePos+1, len(cp.QueueJobs), cp.Position))
+	}
+}
+
+// resumeQueueJob restores the position, the pending inputs and the sent inputs of the current queue job of the
+// checkpoint being resumed from, once
+func (j *Job) resumeQueueJob() {
+	if j.resume == nil {
+		return
+	}
+	cp := j.resume
+	j.resume = nil
+	for j.Input.Position() < cp.Position && j.Input.Next() {
+		j.Input.Value()
+	}
+	j.sent.restore(cp.Sent)
+	j.requeueMutex.Lock()
+	for _, p := range cp.Pending {
+		if j.MarkovChain != nil {
+			// Sent ahead of the inputs the job may queue meanwhile, like the ones generated from the patterns
+			j.sent.add(j.sentKey(p.Input))
+		}
+		j.requeued = append(j.requeued, requeuedInput{input: p.Input, position: p.Position, origin: p.Origin})
+	}
+	j.requeueMutex.Unlock()
+	j.setCounter(cp.Counter)
+}
+
+// trackInflight records an input being sent, for the checkpoints to send it again after resuming if the request is
+// not completed. Returns the id of the input, -1 if not tracked without -checkpoint-dir.
+func (j *Job) trackInflight(input map[string][]byte, position int, origin string) int {
+	if j.Config.CheckpointDir == "" {
+		return -1
+	}
+	copied := make(map[string][]byte, len(input))
+	for k, v := range input {
+		if k != "FFUFHASH" {
+			copied[k] = v
+		}
+	}
+	j.requeueMutex.Lock()
+	defer j.requeueMutex.Unlock()
+	if j.inflight == nil {
+		j.inflight = make(map[int]requeuedInput)
+	}
+	j.inflightID++
+	j.inflight[j.inflightID] = requeuedInput{input: copied, position: position, origin: origin}
+	return j.inflightID
+}
+
+// untrackInflight forgets an input once its request is completed. The requests cancelled by a stopped job are kept,
+// to be sent again after resuming.
+func (j *Job) untrackInflight(id int) {
+	if id < 0 || j.Config.Context.Err() != nil {
+		return
+	}
+	j.requeueMutex.Lock()
+	defer j.requeueMutex.Unlock()
+	delete(j.inflight, id)
+}
@@ -0,0 +1,155 @@
+package ffuf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// resultsOutput keeps the results of all the queue jobs like the standard output
+type resultsOutput struct {
+	NullOutput
+	results []Result
+	current []Result
+}
+
+func (o *resultsOutput) Result(resp Response) {
+	o.current = append(o.current, Result{Input: resp.Request.Input, Url: resp.Request.Url, StatusCode: resp.StatusCode})
+}
+func (o *resultsOutput) GetCurrentResults() []Result        { return o.current }
+func (o *resultsOutput) SetCurrentResults(results []Result) { o.current = results }
+func (o *resultsOutput) GetResults() []Result {
+	return append(append([]Result{}, o.results...), o.current...)
+}
+func (o *resultsOutput) Cycle() {
+	o.results = append(o.results, o.current...)
+	o.current = nil
+}
+
+// newCheckpointJob returns a job answering 200 to every request, stopping once it sent the stop input
+func newCheckpointJob(t *testing.T, words []string, stop string) (*Job, *fakeRunner, *resultsOutput) {
+	t.Helper()
+	var j *Job
+	runner := newFakeRunner(func(req *Request, resp *Response) error {
+		resp.StatusCode = 200
+		if fuzzToken(req) == stop {
+			j.Stop()
+		}
+		return nil
+	})
+	j = newFakeJob(t, runner, words, nil)
+	out := &resultsOutput{}
+	j.Output = out
+	return j, runner, out
+}
+
+func TestCheckpointResume(t *testing.T) {
+	dir := t.TempDir()
+	words := make([]string, 0)
+	for i := 0; i < 250; i++ {
+		words = append(words, fmt.Sprintf("word%03d", i))
+	}
+
+	// Stopped in the middle of a batch of the Markov chain, the rest of the batch is pending
+	j, runner, _ := newCheckpointJob(t, words, "word150")
+	j.Config.CheckpointDir = dir
+	j.Start()
+	checkpoints, _ := listCheckpoints(dir)
+	if len(checkpoints) != 1 {
+		t.Fatalf("Expected a checkpoint to be written when the job stopped, got %d", len(checkpoints))
+	}
+	if _, err := os.Stat(filepath.Join(checkpoints[0], checkpointChainFile)); err != nil {
+		t.Errorf("Expected the Markov chain to be saved with the checkpoint: %s", err)
+	}
+	cp, _, err := LoadCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("Could not load the checkpoint: %s", err)
+	}
+	first := runner.tokens()
+	if len(cp.Pending) == 0 || len(cp.Sent.Hashes) != len(first) || len(cp.Results) != len(first) {
+		t.Errorf("Expected pending inputs, %d sent inputs and results, got %d pending, %d sent and %d results", len(first), len(cp.Pending), len(cp.Sent.Hashes), len(cp.Results))
+	}
+
+	j, runner, out := newCheckpointJob(t, words, "")
+	j.Config.ResumeCheckpoint = dir
+	j.Start()
+	second := runner.tokens()
+	if j.MarkovChain.MarkovChain.Summary() == "" {
+		t.Errorf("Expected the Markov chain to be restored")
+	}
+
+	// The requests cancelled by the stop are sent again first, every other input exactly once
+	stopped := 0
+	for stopped < len(first) && first[stopped] != "word150" {
+		stopped++
+	}
+	cancelled := first[stopped:]
+	if strings.Join(second[:len(cancelled)], ",") != strings.Join(cancelled, ",") {
+		t.Errorf("Expected the cancelled requests %v to be sent first after resuming, got %v", cancelled, second[:len(cancelled)])
+	}
+	sent := append(append([]string{}, first[:stopped]...), second...)
+	sort.Strings(sent)
+	if strings.Join(sent, ",") != strings.Join(words, ",") {
+		t.Errorf("Expected every input to be sent once across the runs, got %d inputs: %v", len(sent), sent)
+	}
+	if results := out.GetResults(); len(results) != len(first)+len(second) {
+		t.Errorf("Expected the results of the checkpoint to be kept, got %d results", len(results))
+	}
+}
+
+func TestSaveCheckpointRotation(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < checkpointKeep+2; i++ {
+		cp := &Checkpoint{QueueJobs: []CheckpointQueueJob{{Url: "http://localhost/FUZZ"}}, Counter: i}
+		if _, err := saveCheckpoint(dir, cp, func(string) error { return nil }); err != nil {
+			t.Fatalf("Could not save the checkpoint: %s", err)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != checkpointKeep {
+		t.Errorf("Expected %d checkpoints to be kept and no temporary directory left, got %d entries", checkpointKeep, len(entries))
+	}
+	cp, cpDir, err := LoadCheckpoint(dir)
+	if err != nil || cp.Counter != checkpointKeep+1 {
+		t.Fatalf("Expected the latest checkpoint to be loaded, got %v (%v)", cp, err)
+	}
+	// A single checkpoint of the directory can be resumed from as well
+	if cp, _, err := LoadCheckpoint(cpDir); err != nil || cp.Counter != checkpointKeep+1 {
+		t.Errorf("Expected the checkpoint to be loaded from its directory, got %v (%v)", cp, err)
+	}
+
+	// A failure leaves the previous checkpoints untouched
+	failing := func(string) error { return fmt.Errorf("disk full") }
+	if _, err := saveCheckpoint(dir, &Checkpoint{}, failing); err == nil {
+		t.Errorf("Expected the failure to save the chain to be returned")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != checkpointKeep {
+		t.Errorf("Expected the failed checkpoint to be removed, got %d entries", len(entries))
+	}
+	if _, _, err := LoadCheckpoint(t.TempDir()); err == nil {
+		t.Errorf("Expected an error for a directory without checkpoints")
+	}
+}
+
+func TestSentCacheSnapshot(t *testing.T) {
+	c := newSentCache(2, 1024)
+	for h := uint64(1); h <= 5; h++ {
+		c.add(h)
+	}
+	restored := newSentCache(2, 1024)
+	restored.restore(c.snapshot())
+	for h := uint64(1); h <= 5; h++ {
+		if !restored.contains(h) {
+			t.Errorf("Expected the restored cache to contain %d", h)
+		}
+	}
+	// A bloom filter of another size is left out
+	other := newSentCache(2, 2048)
+	other.restore(c.snapshot())
+	if !other.contains(1) || other.bloom != nil {
+		t.Errorf("Expected only the exact hashes to be restored into a cache of another size")
+	}
+}
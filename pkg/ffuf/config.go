@@ -2,6 +2,7 @@ package ffuf
 
 import (
 	"context"
+	"time"
 )
 
 type Config struct {
@@ -9,6 +10,7 @@ type Config struct {
 	AutoCalibration           bool                  `json:"autocalibration"`
 	AutoCalibrationKeyword    string                `json:"autocalibration_keyword"`
 	AutoCalibrationPerHost    bool                  `json:"autocalibration_perhost"`
+	AutoCalibrationPerRoot    bool                  `json:"autocalibration_perroot"`
 	AutoCalibrationStrategies []string              `json:"autocalibration_strategies"`
 	AutoCalibrationStrings    []string              `json:"autocalibration_strings"`
 	Cancel                    context.CancelFunc    `json:"-"`
@@ -26,12 +28,15 @@ type Config struct {
 	FilterMode                string                `json:"fmode"`
 	FollowRedirects           bool                  `json:"follow_redirects"`
 	Headers                   map[string]string     `json:"headers"`
+	HeaderPool                []HeaderPreset        `json:"header_pool"`
 	IgnoreBody                bool                  `json:"ignorebody"`
 	IgnoreWordlistComments    bool                  `json:"ignore_wordlist_comments"`
 	InputMode                 string                `json:"inputmode"`
 	InputNum                  int                   `json:"cmd_inputnum"`
 	InputProviders            []InputProviderConfig `json:"inputproviders"`
 	InputShell                string                `json:"inputshell"`
+	InputShard                int                   `json:"input_shard"`
+	InputShards               int                   `json:"input_shards"`
 	Json                      bool                  `json:"json"`
 	MatcherManager            MatcherManager        `json:"matchers"`
 	MatcherMode               string                `json:"mmode"`
@@ -40,11 +45,15 @@ type Config struct {
 	Method                    string                `json:"method"`
 	Noninteractive            bool                  `json:"noninteractive"`
 	OutputDirectory           string                `json:"outputdirectory"`
+	OutputDirectoryMaxFiles   int                   `json:"outputdirectory_maxfiles"`
+	OutputDirectoryMinReward  float64               `json:"outputdirectory_minreward"`
+	OutputDirectoryStatus     string                `json:"outputdirectory_status"`
 	OutputFile                string                `json:"outputfile"`
 	OutputFormat              string                `json:"outputformat"`
 	OutputSkipEmptyFile       bool                  `json:"OutputSkipEmptyFile"`
 	ProgressFrequency         int                   `json:"-"`
 	ProxyURL                  string                `json:"proxyurl"`
+	ProxyList                 []string              `json:"proxylist"`
 	Quiet                     bool                  `json:"quiet"`
 	Rate                      int64                 `json:"rate"`
 	Raw                       bool                  `json:"raw"`
@@ -52,11 +61,17 @@ type Config struct {
 	RecursionDepth            int                   `json:"recursion_depth"`
 	RecursionStrategy         string                `json:"recursion_strategy"`
 	ReplayProxyURL            string                `json:"replayproxyurl"`
+	ReplayFrom                string                `json:"replay_from"`
+	ReplayMinReward           float64               `json:"replay_min_reward"`
+	RequestBudget             int                   `json:"request_budget"`
+	RequestBudgetExplore      float64               `json:"request_budget_explore"`
 	RequestFile               string                `json:"requestfile"`
 	RequestProto              string                `json:"requestproto"`
+	KeywordLocations          map[string]string     `json:"keyword_locations"`
 	ScraperFile               string                `json:"scraperfile"`
 	Scrapers                  string                `json:"scrapers"`
 	SNI                       string                `json:"sni"`
+	StatusAddr                string                `json:"status_addr"`
 	StopOn403                 bool                  `json:"stop_403"`
 	StopOnAll                 bool                  `json:"stop_all"`
 	StopOnErrors              bool                  `json:"stop_errors"`
@@ -68,6 +83,60 @@ type Config struct {
 	Http2                     bool                  `json:"http2"`
 	ClientCert                string                `json:"client-cert"`
 	ClientKey                 string                `json:"client-key"`
+	ClientCA                  string                `json:"client-ca"`
+	CookieJar                 string                `json:"cookie_jar"`
+	Markov                    bool                  `json:"markov"`
+	MarkovProto               bool                  `json:"markov_proto"`
+	MarkovHeaders             bool                  `json:"markov_headers"`
+	MarkovCookie              bool                  `json:"markov_cookie"`
+	MarkovCookieReward        float64               `json:"markov_cookie_reward"`
+	MarkovGranularity         string                `json:"markov_granularity"`
+	MarkovWordlistOut         string                `json:"markov_wordlist_out"`
+	MarkovSeedHistory         string                `json:"markov_seed_history"`
+	MarkovSeedTarget          bool                  `json:"markov_seed_target"`
+	MarkovTimeoutReward       float64               `json:"markov_timeout_reward"`
+	MarkovConnErrorReward     float64               `json:"markov_conn_error_reward"`
+	MarkovSave                string                `json:"markov_save"`
+	MarkovLoad                string                `json:"markov_load"`
+	MarkovCooldown            float64               `json:"markov_cooldown"`
+	MarkovCooldownStatus      string                `json:"markov_cooldown_status"`
+	MarkovCooldownUA          string                `json:"markov_cooldown_ua"`
+	MarkovPatternMax          int                   `json:"markov_pattern_max"`
+	MarkovNeighbors           int                   `json:"markov_neighbors"`
+	MarkovTop                 int                   `json:"markov_top"`
+	MarkovFinalPass           int                   `json:"markov_final_pass"`
+	MarkovSync                string                `json:"markov_sync"`
+	MarkovSyncInterval        time.Duration         `json:"markov_sync_interval"`
+	MarkovColdBudget          int                   `json:"markov_cold_budget"`
+	MarkovMaxMemory           int64                 `json:"markov_max_memory"`
+	MarkovRedirectReward      float64               `json:"markov_redirect_reward"`
+	MarkovClassQuota          int                   `json:"markov_class_quota"`
+	MarkovRewardExpr          string                `json:"markov_reward_expr"`
+	MarkovSizeBuckets         string                `json:"markov_size_buckets"`
+	MarkovPrefixThreshold     float64               `json:"markov_prefix_threshold"`
+	MarkovPrefixRecursion     bool                  `json:"markov_prefix_recursion"`
+	MarkovThreshold           float64               `json:"markov_threshold"`
+	MarkovReflectReward       float64               `json:"markov_reflect_reward"`
+	MarkovPhases              string                `json:"markov_phases"`
+	MarkovDurationCV          float64               `json:"markov_duration_cv"`
+	MarkovKnownGood           string                `json:"markov_known_good"`
+	MarkovExportWeights       string                `json:"markov_export_weights"`
+	MarkovExportAggregate     string                `json:"markov_export_aggregate"`
+	MarkovUpdateBuffer        int                   `json:"markov_update_buffer"`
+	MarkovUniqueEntropy       float64               `json:"markov_unique_entropy"`
+	MarkovNormalize           bool                  `json:"markov_normalize"`
+	MarkovDryRun              int                   `json:"markov_dry_run"`
+	MarkovDryRunOut           string                `json:"markov_dry_run_out"`
+	MarkovStackFilter         bool                  `json:"markov_stack_filter"`
+	Sort                      string                `json:"sort"`
+	Retries                   int                   `json:"retries"`
+	RetryDelay                time.Duration         `json:"retry_delay"`
+	RateLimitMaxWait          time.Duration         `json:"rate_limit_max_wait"`
+	ResponseSizeLimit         int64                 `json:"response_size_limit"`
+	CheckpointDir             string                `json:"checkpoint_dir"`
+	CheckpointInterval        time.Duration         `json:"checkpoint_interval"`
+	ResumeCheckpoint          string                `json:"resume_checkpoint"`
+	StructuralHash            bool                  `json:"structural_hash"`
 }
 
 type InputProviderConfig struct {
@@ -78,6 +147,12 @@ type InputProviderConfig struct {
 	Template string `json:"template"` // the templating string used for sniper mode (usually "§")
 }
 
+// HeaderPreset is a named set of headers of the -header-pool, sent along with the request headers
+type HeaderPreset struct {
+	Name    string            `json:"name"`
+	Headers map[string]string `json:"headers"`
+}
+
 func NewConfig(ctx context.Context, cancel context.CancelFunc) Config {
 	var conf Config
 	conf.AutoCalibrationKeyword = "FUZZ"
@@ -95,10 +170,13 @@ func NewConfig(ctx context.Context, cancel context.CancelFunc) Config {
 	conf.FilterMode = "or"
 	conf.FollowRedirects = false
 	conf.Headers = make(map[string]string)
+	conf.HeaderPool = []HeaderPreset{}
 	conf.IgnoreWordlistComments = false
 	conf.InputMode = "clusterbomb"
 	conf.InputNum = 0
 	conf.InputShell = ""
+	conf.InputShard = 0
+	conf.InputShards = 0
 	conf.InputProviders = make([]InputProviderConfig, 0)
 	conf.Json = false
 	conf.MatcherMode = "or"
@@ -106,19 +184,29 @@ func NewConfig(ctx context.Context, cancel context.CancelFunc) Config {
 	conf.MaxTimeJob = 0
 	conf.Method = "GET"
 	conf.Noninteractive = false
+	conf.OutputDirectoryMaxFiles = 0
+	conf.OutputDirectoryMinReward = 0
+	conf.OutputDirectoryStatus = "all"
 	conf.ProgressFrequency = 125
 	conf.ProxyURL = ""
+	conf.ProxyList = []string{}
 	conf.Quiet = false
 	conf.Rate = 0
 	conf.Raw = false
 	conf.Recursion = false
 	conf.RecursionDepth = 0
 	conf.RecursionStrategy = "default"
+	conf.ReplayFrom = ""
+	conf.ReplayMinReward = 0
+	conf.RequestBudget = 0
+	conf.RequestBudgetExplore = 0.1
 	conf.RequestFile = ""
 	conf.RequestProto = "https"
+	conf.KeywordLocations = make(map[string]string)
 	conf.SNI = ""
 	conf.ScraperFile = ""
 	conf.Scrapers = "all"
+	conf.StatusAddr = ""
 	conf.StopOn403 = false
 	conf.StopOnAll = false
 	conf.StopOnErrors = false
@@ -127,6 +215,58 @@ func NewConfig(ctx context.Context, cancel context.CancelFunc) Config {
 	conf.Verbose = false
 	conf.Wordlists = []string{}
 	conf.Http2 = false
+	conf.Markov = false
+	conf.MarkovProto = false
+	conf.MarkovHeaders = false
+	conf.MarkovCookie = false
+	conf.MarkovCookieReward = 0
+	conf.MarkovGranularity = "default"
+	conf.MarkovWordlistOut = ""
+	conf.MarkovSeedHistory = ""
+	conf.MarkovSeedTarget = false
+	conf.MarkovTimeoutReward = 0.2
+	conf.MarkovConnErrorReward = 0
+	conf.MarkovSave = ""
+	conf.MarkovLoad = ""
+	conf.MarkovCooldown = 0
+	conf.MarkovCooldownStatus = "403,429"
+	conf.MarkovCooldownUA = ""
+	conf.MarkovPatternMax = 50
+	conf.MarkovNeighbors = 5
+	conf.MarkovTop = 0
+	conf.MarkovFinalPass = 0
+	conf.MarkovSync = ""
+	conf.MarkovSyncInterval = 60 * time.Second
+	conf.MarkovColdBudget = 50
+	conf.MarkovMaxMemory = 0
+	conf.MarkovRedirectReward = 0
+	conf.MarkovClassQuota = 4
+	conf.MarkovRewardExpr = ""
+	conf.MarkovSizeBuckets = "log"
+	conf.MarkovPrefixThreshold = 1.0
+	conf.MarkovPrefixRecursion = false
+	conf.MarkovThreshold = 0.01
+	conf.MarkovReflectReward = 0.5
+	conf.MarkovPhases = ""
+	conf.MarkovDurationCV = 1.0
+	conf.MarkovKnownGood = ""
+	conf.MarkovExportWeights = ""
+	conf.MarkovExportAggregate = "max"
+	conf.MarkovUpdateBuffer = 4096
+	conf.MarkovUniqueEntropy = 0
+	conf.MarkovNormalize = false
+	conf.MarkovDryRun = 0
+	conf.MarkovDryRunOut = ""
+	conf.MarkovStackFilter = false
+	conf.Sort = ""
+	conf.Retries = 1
+	conf.RetryDelay = 0
+	conf.RateLimitMaxWait = 60 * time.Second
+	conf.ResponseSizeLimit = 5242880
+	conf.CheckpointDir = ""
+	conf.CheckpointInterval = 60 * time.Second
+	conf.ResumeCheckpoint = ""
+	conf.StructuralHash = false
 	return conf
 }
 
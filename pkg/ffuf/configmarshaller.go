@@ -23,16 +23,36 @@ func (c *Config) ToOptions() ConfigOptions {
 	o.HTTP.RecursionDepth = c.RecursionDepth
 	o.HTTP.RecursionStrategy = c.RecursionStrategy
 	o.HTTP.ReplayProxyURL = c.ReplayProxyURL
+	o.HTTP.ResponseSizeLimit = int(c.ResponseSizeLimit)
+	o.HTTP.Retries = c.Retries
+	if c.RetryDelay > 0 {
+		o.HTTP.RetryDelay = c.RetryDelay.String()
+	} else {
+		o.HTTP.RetryDelay = ""
+	}
+	if c.RateLimitMaxWait > 0 {
+		o.HTTP.RateLimitMaxWait = c.RateLimitMaxWait.String()
+	} else {
+		o.HTTP.RateLimitMaxWait = ""
+	}
 	o.HTTP.SNI = c.SNI
+	o.HTTP.StructuralHash = c.StructuralHash
 	o.HTTP.Timeout = c.Timeout
 	o.HTTP.URL = c.Url
 	o.HTTP.Http2 = c.Http2
+	o.HTTP.ClientCert = c.ClientCert
+	o.HTTP.ClientKey = c.ClientKey
+	o.HTTP.ClientCA = c.ClientCA
+	o.HTTP.CookieJar = c.CookieJar
 
 	o.General.AutoCalibration = c.AutoCalibration
 	o.General.AutoCalibrationKeyword = c.AutoCalibrationKeyword
 	o.General.AutoCalibrationPerHost = c.AutoCalibrationPerHost
+	o.General.AutoCalibrationPerRoot = c.AutoCalibrationPerRoot
 	o.General.AutoCalibrationStrategies = c.AutoCalibrationStrategies
 	o.General.AutoCalibrationStrings = c.AutoCalibrationStrings
+	o.General.CheckpointDir = c.CheckpointDir
+	o.General.CheckpointInterval = c.CheckpointInterval.String()
 	o.General.Colors = c.Colors
 	o.General.ConfigFile = ""
 	if c.Delay.HasDelay {
@@ -50,8 +70,12 @@ func (c *Config) ToOptions() ConfigOptions {
 	o.General.Noninteractive = c.Noninteractive
 	o.General.Quiet = c.Quiet
 	o.General.Rate = int(c.Rate)
+	o.General.RequestBudget = c.RequestBudget
+	o.General.RequestBudgetExplore = c.RequestBudgetExplore
+	o.General.ResumeCheckpoint = c.ResumeCheckpoint
 	o.General.ScraperFile = c.ScraperFile
 	o.General.Scrapers = c.Scrapers
+	o.General.StatusAddr = c.StatusAddr
 	o.General.StopOn403 = c.StopOn403
 	o.General.StopOnAll = c.StopOnAll
 	o.General.StopOnErrors = c.StopOnErrors
@@ -64,12 +88,19 @@ func (c *Config) ToOptions() ConfigOptions {
 	o.Input.InputMode = c.InputMode
 	o.Input.InputNum = c.InputNum
 	o.Input.InputShell = c.InputShell
+	if c.InputShards > 0 {
+		o.Input.InputShard = fmt.Sprintf("%d/%d", c.InputShard, c.InputShards)
+	} else {
+		o.Input.InputShard = ""
+	}
 	o.Input.Inputcommands = []string{}
 	for _, v := range c.InputProviders {
 		if v.Name == "command" {
 			o.Input.Inputcommands = append(o.Input.Inputcommands, fmt.Sprintf("%s:%s", v.Value, v.Keyword))
 		}
 	}
+	o.Input.ReplayFrom = c.ReplayFrom
+	o.Input.ReplayMinReward = c.ReplayMinReward
 	o.Input.Request = c.RequestFile
 	o.Input.RequestProto = c.RequestProto
 	o.Input.Wordlists = c.Wordlists
@@ -77,11 +108,63 @@ func (c *Config) ToOptions() ConfigOptions {
 	o.Output.AuditLog = c.AuditLog
 	o.Output.DebugLog = c.Debuglog
 	o.Output.OutputDirectory = c.OutputDirectory
+	o.Output.OutputDirectoryMaxFiles = c.OutputDirectoryMaxFiles
+	o.Output.OutputDirectoryMinReward = c.OutputDirectoryMinReward
+	o.Output.OutputDirectoryStatus = c.OutputDirectoryStatus
 	o.Output.OutputFile = c.OutputFile
 	o.Output.OutputFormat = c.OutputFormat
 	o.Output.OutputSkipEmptyFile = c.OutputSkipEmptyFile
+	o.Output.Sort = c.Sort
+
+	o.Markov.Enabled = c.Markov
+	o.Markov.Proto = c.MarkovProto
+	o.Markov.Headers = c.MarkovHeaders
+	o.Markov.Cookie = c.MarkovCookie
+	o.Markov.CookieReward = c.MarkovCookieReward
+	o.Markov.RedirectReward = c.MarkovRedirectReward
+	o.Markov.Granularity = c.MarkovGranularity
+	o.Markov.WordlistOut = c.MarkovWordlistOut
+	o.Markov.SeedHistory = c.MarkovSeedHistory
+	o.Markov.SeedTarget = c.MarkovSeedTarget
+	o.Markov.TimeoutReward = c.MarkovTimeoutReward
+	o.Markov.ConnErrorReward = c.MarkovConnErrorReward
+	o.Markov.Save = c.MarkovSave
+	o.Markov.Load = c.MarkovLoad
+	o.Markov.Cooldown = c.MarkovCooldown
+	o.Markov.CooldownStatus = c.MarkovCooldownStatus
+	o.Markov.CooldownUA = c.MarkovCooldownUA
+	o.Markov.PatternMax = c.MarkovPatternMax
+	o.Markov.Neighbors = c.MarkovNeighbors
+	o.Markov.Top = c.MarkovTop
+	o.Markov.FinalPass = c.MarkovFinalPass
+	o.Markov.Sync = c.MarkovSync
+	o.Markov.SyncInterval = c.MarkovSyncInterval.String()
+	o.Markov.ColdBudget = c.MarkovColdBudget
+	o.Markov.MaxMemory = ""
+	if c.MarkovMaxMemory > 0 {
+		o.Markov.MaxMemory = formatByteSize(c.MarkovMaxMemory)
+	}
+	o.Markov.ClassQuota = c.MarkovClassQuota
+	o.Markov.RewardExpr = c.MarkovRewardExpr
+	o.Markov.SizeBuckets = c.MarkovSizeBuckets
+	o.Markov.PrefixThreshold = c.MarkovPrefixThreshold
+	o.Markov.PrefixRecursion = c.MarkovPrefixRecursion
+	o.Markov.Threshold = c.MarkovThreshold
+	o.Markov.ReflectReward = c.MarkovReflectReward
+	o.Markov.Phases = c.MarkovPhases
+	o.Markov.DurationCV = c.MarkovDurationCV
+	o.Markov.KnownGood = c.MarkovKnownGood
+	o.Markov.ExportWeights = c.MarkovExportWeights
+	o.Markov.ExportAggregate = c.MarkovExportAggregate
+	o.Markov.UpdateBuffer = c.MarkovUpdateBuffer
+	o.Markov.UniqueEntropy = c.MarkovUniqueEntropy
+	o.Markov.Normalize = c.MarkovNormalize
+	o.Markov.DryRun = c.MarkovDryRun
+	o.Markov.DryRunOut = c.MarkovDryRunOut
+	o.Markov.StackFilter = c.MarkovStackFilter
 
 	o.Filter.Mode = c.FilterMode
+	o.Filter.Hash = ""
 	o.Filter.Lines = ""
 	o.Filter.Regexp = ""
 	o.Filter.Size = ""
@@ -90,6 +173,8 @@ func (c *Config) ToOptions() ConfigOptions {
 	o.Filter.Words = ""
 	for name, filter := range c.MatcherManager.GetFilters() {
 		switch name {
+		case "hash":
+			o.Filter.Hash = filter.Repr()
 		case "line":
 			o.Filter.Lines = filter.Repr()
 		case "regexp":
@@ -105,7 +190,9 @@ func (c *Config) ToOptions() ConfigOptions {
 		}
 	}
 	o.Matcher.Mode = c.MatcherMode
+	o.Matcher.Hash = ""
 	o.Matcher.Lines = ""
+	o.Matcher.Reflect = false
 	o.Matcher.Regexp = ""
 	o.Matcher.Size = ""
 	o.Matcher.Status = ""
@@ -113,8 +200,12 @@ func (c *Config) ToOptions() ConfigOptions {
 	o.Matcher.Words = ""
 	for name, filter := range c.MatcherManager.GetMatchers() {
 		switch name {
+		case "hash":
+			o.Matcher.Hash = filter.Repr()
 		case "line":
 			o.Matcher.Lines = filter.Repr()
+		case "reflect":
+			o.Matcher.Reflect = true
 		case "regexp":
 			o.Matcher.Regexp = filter.Repr()
 		case "size":
@@ -0,0 +1,321 @@
+package ffuf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpOnlyPrefix marks the HttpOnly cookies of a cookie jar file, as written by curl
+const httpOnlyPrefix = "#HttpOnly_"
+
+// CookieJar keeps the cookies of -cookie-jar in the Netscape cookie file format used by curl and browsers. The
+// cookies set by the matched responses are added to it and sent with the following requests.
+type CookieJar struct {
+	path    string
+	cookies []*jarCookie
+	mutex   sync.Mutex
+}
+
+type jarCookie struct {
+	Domain   string
+	HostOnly bool // only sent to the host of the domain, not to its subdomains
+	Path     string
+	Secure   bool
+	HttpOnly bool
+	Expires  time.Time // zero for a session cookie
+	Name     string
+	Value    string
+}
+
+// LoadCookieJar reads a cookie jar file, a file not existing yet being an empty jar
+func LoadCookieJar(path string) (*CookieJar, error) {
+	jar := &CookieJar{path: path, cookies: make([]*jarCookie, 0)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return jar, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		httpOnly := strings.HasPrefix(text, httpOnlyPrefix)
+		text = strings.TrimPrefix(text, httpOnlyPrefix)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("invalid cookie on line %d of %s, expected 7 tab separated fields", line, path)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiry on line %d of %s: %s", line, path, fields[4])
+		}
+		c := &jarCookie{
+			Domain:   strings.ToLower(strings.TrimPrefix(fields[0], ".")),
+			HostOnly: strings.ToUpper(fields[1]) != "TRUE",
+			Path:     fields[2],
+			Secure:   strings.ToUpper(fields[3]) == "TRUE",
+			HttpOnly: httpOnly,
+			Name:     fields[5],
+			Value:    fields[6],
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		jar.cookies = append(jar.cookies, c)
+	}
+	return jar, scanner.Err()
+}
+
+// Cookies returns the cookies of the jar to send to the URL, the ones with the longest path first
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	now := time.Now()
+	host := strings.ToLower(u.Hostname())
+	matching := make([]*jarCookie, 0)
+	for _, c := range j.cookies {
+		if c.expired(now) || !c.domainMatch(host) || !pathMatch(u.EscapedPath(), c.Path) {
+			continue
+		}
+		if c.Secure && u.Scheme != "https" {
+			continue
+		}
+		matching = append(matching, c)
+	}
+	sort.SliceStable(matching, func(a, b int) bool { return len(matching[a].Path) > len(matching[b].Path) })
+	cookies := make([]*http.Cookie, 0, len(matching))
+	for _, c := range matching {
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return cookies
+}
+
+// CookieHeader merges the cookies of the jar for the URL into the value of a Cookie header, the ones of the jar
+// replacing the cookies of the header having the same name
+func (j *CookieJar) CookieHeader(u *url.URL, header string) string {
+	values := make(map[string]string)
+	names := make([]string, 0)
+	for _, pair := range strings.Split(header, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name := strings.SplitN(pair, "=", 2)[0]
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+		values[name] = pair
+	}
+	for _, c := range j.Cookies(u) {
+		if _, ok := values[c.Name]; !ok {
+			names = append(names, c.Name)
+		}
+		values[c.Name] = c.Name + "=" + c.Value
+	}
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, values[name])
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// SetCookies adds the cookies set by a response of the URL to the jar, replacing the ones with the same name, domain
+// and path and removing the expired ones. Cookies for a domain the URL is not part of are ignored. Returns true if
+// the jar changed.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	now := time.Now()
+	host := strings.ToLower(u.Hostname())
+	changed := false
+	for _, cookie := range cookies {
+		c := &jarCookie{
+			Domain:   host,
+			HostOnly: true,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+		}
+		if cookie.Domain != "" {
+			c.Domain = strings.ToLower(strings.TrimPrefix(cookie.Domain, "."))
+			c.HostOnly = false
+			if !c.domainMatch(host) {
+				continue
+			}
+		}
+		if !strings.HasPrefix(c.Path, "/") {
+			c.Path = defaultCookiePath(u.EscapedPath())
+		}
+		switch {
+		case cookie.MaxAge < 0:
+			c.Expires = time.Unix(1, 0)
+		case cookie.MaxAge > 0:
+			c.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		default:
+			c.Expires = cookie.Expires
+		}
+		if j.set(c, now) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// set replaces the cookie with the same name, domain and path, or removes it if the new one is expired
+func (j *CookieJar) set(c *jarCookie, now time.Time) bool {
+	for i, old := range j.cookies {
+		if old.Name != c.Name || old.Domain != c.Domain || old.Path != c.Path {
+			continue
+		}
+		if c.expired(now) {
+			j.cookies = append(j.cookies[:i], j.cookies[i+1:]...)
+			return true
+		}
+		if old.same(c) {
+			return false
+		}
+		j.cookies[i] = c
+		return true
+	}
+	if c.expired(now) {
+		return false
+	}
+	j.cookies = append(j.cookies, c)
+	return true
+}
+
+// Save writes the cookies of the jar to its file, the expired ones left out. The file is replaced at once, never
+// being partially written.
+func (j *CookieJar) Save() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	now := time.Now()
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n")
+	for _, c := range j.cookies {
+		if c.expired(now) {
+			continue
+		}
+		domain, subdomains := c.Domain, "FALSE"
+		if !c.HostOnly {
+			domain, subdomains = "."+c.Domain, "TRUE"
+		}
+		if c.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		secure := "FALSE"
+		if c.Secure {
+			secure = "TRUE"
+		}
+		expires := int64(0)
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, subdomains, c.Path, secure, expires, c.Name, c.Value)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), "."+filepath.Base(j.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), j.path)
+}
+
+// same returns true if the cookies would be written the same to the jar file
+func (c *jarCookie) same(other *jarCookie) bool {
+	return c.Value == other.Value && c.HostOnly == other.HostOnly && c.Secure == other.Secure &&
+		c.HttpOnly == other.HttpOnly && c.Expires.Unix() == other.Expires.Unix()
+}
+
+func (c *jarCookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// domainMatch returns true if the cookie is sent to the host
+func (c *jarCookie) domainMatch(host string) bool {
+	if c.HostOnly {
+		return host == c.Domain
+	}
+	return host == c.Domain || strings.HasSuffix(host, "."+c.Domain)
+}
+
+// pathMatch returns true if the request path is the cookie path or beneath it
+func pathMatch(requestPath string, cookiePath string) bool {
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// defaultCookiePath returns the path of a cookie set without one: the directory of the request path
+func defaultCookiePath(requestPath string) string {
+	idx := strings.LastIndex(requestPath, "/")
+	if idx <= 0 {
+		return "/"
+	}
+	return requestPath[:idx]
+}
+
+// applyCookieJar adds the cookies of the jar for the URL of the request to its Cookie header
+func (j *Job) applyCookieJar(req *Request) {
+	if j.cookieJar == nil || req.Headers == nil {
+		return
+	}
+	u, err := url.Parse(req.Url)
+	if err != nil {
+		return
+	}
+	if cookie := j.cookieJar.CookieHeader(u, req.Headers["Cookie"]); cookie != "" {
+		req.Headers["Cookie"] = cookie
+	}
+}
+
+// storeCookies adds the cookies set by a matched response to the jar, saving it if it changed
+func (j *Job) storeCookies(resp Response) {
+	if j.cookieJar == nil || resp.Request == nil {
+		return
+	}
+	u, err := url.Parse(resp.Request.Url)
+	if err != nil {
+		return
+	}
+	cookies := (&http.Response{Header: http.Header(resp.Headers)}).Cookies()
+	if len(cookies) == 0 || !j.cookieJar.SetCookies(u, cookies) {
+		return
+	}
+	if err := j.cookieJar.Save(); err != nil {
+		j.Output.Warning(fmt.Sprintf("Could not save the cookie jar: %s", err))
+	}
+}
@@ -0,0 +1,166 @@
+package ffuf
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParseURL(t *testing.T, rawurl string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		t.Fatalf("Could not parse %s: %s", rawurl, err)
+	}
+	return u
+}
+
+func cookieNames(cookies []*http.Cookie) string {
+	names := make([]string, 0, len(cookies))
+	for _, c := range cookies {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestCookieJarFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jar.txt")
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+	content := fmt.Sprintf("# Netscape HTTP Cookie File\n"+
+		".example.com\tTRUE\t/\tFALSE\t%d\tdomain\t1\n"+
+		"example.com\tFALSE\t/\tFALSE\t0\thostonly\t2\n"+
+		"#HttpOnly_example.com\tFALSE\t/admin\tTRUE\t%d\tsecure\t3\n"+
+		"example.com\tFALSE\t/\tFALSE\t%d\texpired\t4\n", future, future, past)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Could not write the jar: %s", err)
+	}
+	jar, err := LoadCookieJar(path)
+	if err != nil {
+		t.Fatalf("Could not load the jar: %s", err)
+	}
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"http://example.com/", "domain,hostonly"},
+		{"http://www.example.com/", "domain"},
+		{"https://example.com/admin/users", "secure,domain,hostonly"},
+		{"http://example.com/admin/users", "domain,hostonly"},
+		{"https://example.com/administrator", "domain,hostonly"},
+		{"http://example.org/", ""},
+		{"http://notexample.com/", ""},
+	}
+	for _, test := range tests {
+		if got := cookieNames(jar.Cookies(mustParseURL(t, test.url))); got != test.expected {
+			t.Errorf("Expected the cookies %q for %s, got %q", test.expected, test.url, got)
+		}
+	}
+
+	// Saving leaves the expired cookie out and keeps the others as they were
+	if err := jar.Save(); err != nil {
+		t.Fatalf("Could not save the jar: %s", err)
+	}
+	saved, _ := os.ReadFile(path)
+	if strings.Contains(string(saved), "expired") || !strings.Contains(string(saved), fmt.Sprintf("#HttpOnly_example.com\tFALSE\t/admin\tTRUE\t%d\tsecure\t3", future)) {
+		t.Errorf("Unexpected saved jar:\n%s", saved)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Expected no temporary file left next to the jar, got %d files", len(entries))
+	}
+
+	if err := os.WriteFile(path, []byte("example.com\tFALSE\t/\n"), 0600); err != nil {
+		t.Fatalf("Could not write the jar: %s", err)
+	}
+	if _, err := LoadCookieJar(path); err == nil {
+		t.Errorf("Expected an error for an invalid jar")
+	}
+	if jar, err := LoadCookieJar(filepath.Join(t.TempDir(), "missing.txt")); err != nil || len(jar.cookies) != 0 {
+		t.Errorf("Expected a missing jar to be empty, got %v", err)
+	}
+}
+
+func TestCookieJarSetCookies(t *testing.T) {
+	jar, _ := LoadCookieJar(filepath.Join(t.TempDir(), "jar.txt"))
+	login := mustParseURL(t, "https://app.example.com/auth/login")
+	changed := jar.SetCookies(login, []*http.Cookie{
+		{Name: "session", Value: "abc"},
+		{Name: "shared", Value: "1", Domain: ".example.com", Path: "/"},
+		{Name: "foreign", Value: "1", Domain: "example.org"},
+		{Name: "gone", Value: "1", MaxAge: -1},
+		{Name: "old", Value: "1", Expires: time.Now().Add(-time.Minute)},
+	})
+	if !changed {
+		t.Errorf("Expected the jar to change")
+	}
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		// A cookie without a path is scoped to the directory of the URL setting it
+		{"https://app.example.com/auth/logout", "session,shared"},
+		{"https://app.example.com/", "shared"},
+		{"https://api.example.com/auth/x", "shared"},
+		{"https://example.org/", ""},
+	}
+	for _, test := range tests {
+		if got := cookieNames(jar.Cookies(mustParseURL(t, test.url))); got != test.expected {
+			t.Errorf("Expected the cookies %q for %s, got %q", test.expected, test.url, got)
+		}
+	}
+	if jar.SetCookies(login, []*http.Cookie{{Name: "session", Value: "abc"}}) {
+		t.Errorf("Expected setting the same cookie again not to change the jar")
+	}
+	// Expiring a cookie removes it
+	jar.SetCookies(login, []*http.Cookie{{Name: "session", Value: "", Path: "/auth", MaxAge: -1}})
+	if got := cookieNames(jar.Cookies(mustParseURL(t, "https://app.example.com/auth/logout"))); got != "shared" {
+		t.Errorf("Expected the expired session cookie to be removed, got %q", got)
+	}
+}
+
+func TestCookieJarHeader(t *testing.T) {
+	jar, _ := LoadCookieJar(filepath.Join(t.TempDir(), "jar.txt"))
+	u := mustParseURL(t, "http://example.com/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "fromjar", Path: "/"}, {Name: "extra", Value: "1", Path: "/"}})
+	if got := jar.CookieHeader(u, "lang=en; session=static"); got != "lang=en; session=fromjar; extra=1" {
+		t.Errorf("Expected the jar to win over the static cookies, got %q", got)
+	}
+}
+
+func TestJobCookieJar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jar.txt")
+	// The login sets the session cookie
+	runner := newFakeRunner(func(req *Request, resp *Response) error {
+		resp.StatusCode = 200
+		if fuzzToken(req) == "login" {
+			resp.Headers["Set-Cookie"] = []string{"session=authenticated; Path=/"}
+		}
+		return nil
+	})
+	j := newFakeJob(t, runner, []string{"before", "login", "after"}, func(conf *Config) {
+		conf.Headers["Cookie"] = "lang=en; session=anonymous"
+		conf.CookieJar = path
+		conf.Markov = false
+	})
+	j.Start()
+
+	sent := make(map[string]string)
+	for _, req := range runner.sent() {
+		sent[fuzzToken(&req)] = req.Headers["Cookie"]
+	}
+	if sent["before"] != "lang=en; session=anonymous" {
+		t.Errorf("Expected the static cookies before the login, got %q", sent["before"])
+	}
+	if sent["after"] != "lang=en; session=authenticated" {
+		t.Errorf("Expected the cookie of the jar to replace the static one after the login, got %q", sent["after"])
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(saved), "localhost\tFALSE\t/\tFALSE\t0\tsession\tauthenticated") {
+		t.Errorf("Expected the session cookie to be saved to the jar, got %q (%v)", saved, err)
+	}
+}
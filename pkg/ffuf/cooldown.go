@@ -0,0 +1,48 @@
+package ffuf
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// markovBlockWindow is the number of recent responses the Markov chain looks at to detect blocking
+const markovBlockWindow = 20
+
+// cooldown pauses the job for the backoff period after the Markov chain detected blocking, and switches to the next
+// User-Agent of the -markov-cooldown-ua pool, if any, before resuming
+func (j *Job) cooldown(backoff time.Duration) {
+	j.Output.Warning(fmt.Sprintf("Blocking detected, cooling down for %s: %s", backoff, j.MarkovChain.BlockingEvidence()))
+	j.Pause()
+	select {
+	case <-time.After(backoff):
+	case <-j.Config.Context.Done():
+	}
+	if len(j.cooldownAgents) > 0 {
+		j.cooldownAgent = (j.cooldownAgent + 1) % len(j.cooldownAgents)
+		j.userAgent.Store(j.cooldownAgents[j.cooldownAgent])
+		j.Output.Info(fmt.Sprintf("Switched the User-Agent to %s", j.cooldownAgents[j.cooldownAgent]))
+	}
+	j.Resume()
+}
+
+// readUserAgents reads the User-Agents from a file, one per line. Empty lines and comments are skipped.
+func readUserAgents(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	agents := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		agents = append(agents, line)
+	}
+	return agents, scanner.Err()
+}
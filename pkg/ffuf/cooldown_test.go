@@ -0,0 +1,79 @@
+package ffuf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+func TestCooldownOnBlocking(t *testing.T) {
+	conf := NewConfig(context.Background(), func() {})
+	conf.MatcherManager = &fakeMatcherManager{}
+	j := NewJob(&conf)
+	out := &logOutput{}
+	j.Output = out
+	// The target answers the first 5 requests and a constant 403 block page afterwards
+	sent := 0
+	runner := newFakeRunner(func(req *Request, resp *Response) error {
+		sent++
+		resp.Data = []byte("ok")
+		resp.StatusCode, resp.ContentLength = 200, int64(1000+sent*100)
+		if sent > 5 {
+			resp.StatusCode, resp.ContentLength = 403, 512
+		}
+		return nil
+	})
+	j.Runner = runner
+	j.queuejobs = append(j.queuejobs, QueueJob{req: BaseRequest(&conf)})
+	j.queuepos = 1
+	baseline := markov.State{CodeClass: "4xx", SizeBucket: markov.QuantizeSize(139)}
+	j.MarkovChain = markov.NewMarkovInputProvider(nil, baseline, markov.GetSizeHash([]byte("404 not found")), 0)
+	j.MarkovChain.SetBlockDetection([]int64{403}, 5, 10*time.Millisecond)
+
+	path := filepath.Join(t.TempDir(), "agents.txt")
+	if err := os.WriteFile(path, []byte("# pool\nagent-one\n\nagent-two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	agents, err := readUserAgents(path)
+	if err != nil || len(agents) != 2 {
+		t.Fatalf("Unexpected User-Agents %v: %s", agents, err)
+	}
+	j.cooldownAgents = agents
+	j.cooldownAgent = -1
+
+	for i := 0; i < 15; i++ {
+		j.runTask(map[string][]byte{"FUZZ": []byte("word")}, i+1, "", "")
+	}
+
+	// The block phase starts at the 6th request, detected at the 10th and 15th
+	expected := []string{"Blocking detected", "PAUSING", "RESUMING", "Blocking detected", "PAUSING", "RESUMING"}
+	sequence := make([]string, 0)
+	for _, msg := range out.messages {
+		for _, e := range []string{"Blocking detected", "PAUSING", "RESUMING"} {
+			if strings.Contains(msg, e) {
+				sequence = append(sequence, e)
+			}
+		}
+	}
+	if strings.Join(sequence, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected cooldown sequence %v, messages: %v", sequence, out.messages)
+	}
+	if !strings.Contains(out.messages[0], "5 of the last 5 responses were 403") {
+		t.Errorf("Expected the evidence to be logged, got %s", out.messages[0])
+	}
+	if j.Paused {
+		t.Errorf("Expected the job to be resumed after the cooldown")
+	}
+	agents = make([]string, 0)
+	for _, req := range runner.sent() {
+		agents = append(agents, req.Headers["User-Agent"])
+	}
+	if agents[9] != "" || agents[10] != "agent-one" {
+		t.Errorf("Expected the User-Agent to be switched after the first cooldown, got %v", agents)
+	}
+}
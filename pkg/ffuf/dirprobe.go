@@ -0,0 +1,55 @@
+package ffuf
+
+import (
+	"bytes"
+	"strings"
+)
+
+// dirProbeFiles are the index files probed in the directories found by the matches
+var dirProbeFiles = []string{"index.php", "index.html", "web.config"}
+
+// isDirectoryResponse returns true if the response looks like a directory: a permanent redirect to the same path
+// with a trailing slash, or a directory listing
+func isDirectoryResponse(resp Response) bool {
+	if resp.StatusCode == 301 && strings.HasSuffix(resp.GetRedirectLocation(false), "/") {
+		return true
+	}
+	return bytes.Contains(resp.Data, []byte("Index of /"))
+}
+
+// requeueDirProbes queues the matched FUZZ token with a trailing slash and the index files within it when the match
+// looks like a directory, once for each directory of the queue job
+func (j *Job) requeueDirProbes(input map[string][]byte, resp Response) {
+	token, ok := input["FUZZ"]
+	if !ok || !isDirectoryResponse(resp) || j.MarkovChain.SeedRetired(string(token)) {
+		return
+	}
+	dir := strings.TrimSuffix(string(token), "/")
+	if dir == "" {
+		return
+	}
+	j.requeueMutex.Lock()
+	if j.dirProbed == nil {
+		j.dirProbed = make(map[string]bool)
+	}
+	probed := j.dirProbed[dir]
+	j.dirProbed[dir] = true
+	j.requeueMutex.Unlock()
+	if probed {
+		return
+	}
+	probes := []string{dir + "/"}
+	for _, file := range dirProbeFiles {
+		probes = append(probes, dir+"/"+file)
+	}
+	for _, probe := range probes {
+		generated := make(map[string][]byte, len(input))
+		for k, v := range input {
+			if k != "FFUFHASH" {
+				generated[k] = v
+			}
+		}
+		generated["FUZZ"] = []byte(probe)
+		j.requeue(generated, "dir-probe", string(token))
+	}
+}
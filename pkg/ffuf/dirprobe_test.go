@@ -0,0 +1,69 @@
+package ffuf
+
+import (
+	"testing"
+)
+
+func TestIsDirectoryResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		resp     Response
+		expected bool
+	}{
+		{"redirect to slash", Response{StatusCode: 301, Headers: map[string][]string{"Location": {"/admin/"}}}, true},
+		{"redirect elsewhere", Response{StatusCode: 301, Headers: map[string][]string{"Location": {"/login"}}}, false},
+		{"found to slash", Response{StatusCode: 302, Headers: map[string][]string{"Location": {"/admin/"}}}, false},
+		{"directory listing", Response{StatusCode: 200, Data: []byte("<title>Index of /files</title>")}, true},
+		{"page", Response{StatusCode: 200, Data: []byte("<title>Welcome</title>")}, false},
+	}
+	for _, tt := range tests {
+		if got := isDirectoryResponse(tt.resp); got != tt.expected {
+			t.Errorf("%s: expected %t, got %t", tt.name, tt.expected, got)
+		}
+	}
+}
+
+// answerTree returns a handler serving a small tree of paths
+func answerTree() fakeHandler {
+	return func(req *Request, resp *Response) error {
+		switch token := fuzzToken(req); token {
+		case "admin":
+			resp.StatusCode = 301
+			resp.Headers["Location"] = []string{"/admin/"}
+		case "admin/", "files":
+			resp.StatusCode = 200
+			resp.Data = []byte("<title>Index of /" + token + "</title>")
+		case "admin/index.php", "login":
+			resp.StatusCode = 200
+		}
+		return nil
+	}
+}
+
+func TestDirProbes(t *testing.T) {
+	runner := newFakeRunner(answerTree())
+	j := newFakeJob(t, runner, []string{"admin", "login", "files", "admin/", "files/"}, func(conf *Config) {
+		conf.MatcherManager = matchStatus(200, 301)
+	})
+	j.Start()
+
+	// Both directories are probed once, admin when redirecting to admin/ and again when admin/ lists the directory
+	sent := runner.counts(false)
+	for _, dir := range []string{"admin", "files"} {
+		for _, probe := range []string{"/", "/index.php", "/index.html", "/web.config"} {
+			if count := sent[dir+probe]; count != 1 {
+				t.Errorf("Expected %s%s to be sent once, got %d", dir, probe, count)
+			}
+		}
+	}
+	if count := sent["login/"]; count != 0 {
+		t.Errorf("Expected no probes for a match not looking like a directory, got %d", count)
+	}
+	origins := make(map[string]string)
+	for _, resp := range j.Output.(*recordingOutput).responses {
+		origins[fuzzToken(resp.Request)] = resp.Request.Origin
+	}
+	if origins["admin/index.php"] != "dir-probe" || origins["admin/"] != "dir-probe" {
+		t.Errorf("Expected the probes to have the dir-probe origin, got %v", origins)
+	}
+}
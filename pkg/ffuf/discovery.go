@@ -0,0 +1,106 @@
+package ffuf
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	// discoveryWindow is the number of requests of the windows the rate of discovery is measured over
+	discoveryWindow = 1000
+	// discoveryHashLimit is the number of body hashes remembered to tell the new content apart. Past it the hashes
+	// not remembered count as seen, the stall score erring on the side of a stall.
+	discoveryHashLimit = 1 << 16
+	// discoveryFullRate is the share of the requests of a window matching, or getting new content, at which the
+	// window is not stalled at all
+	discoveryFullRate = 0.01
+)
+
+const (
+	discoveryMatch uint8 = 1 << iota
+	discoveryNewContent
+)
+
+// DiscoveryRates are the trends of the discoveries over the latest requests, the last window of discoveryWindow
+// requests being compared to the one before it
+type DiscoveryRates struct {
+	Requests        int     // requests of the last window, fewer than discoveryWindow early in the run
+	Matches         int     // matches in the last window
+	PreviousMatches int     // matches in the window before it
+	NewContentRate  float64 // share of the requests of the last window getting a body never seen before
+	// StallScore goes from 0 to 1 as both the matches and the new content of the last window run dry, weighted by
+	// how full the window is
+	StallScore float64
+}
+
+// discoveryStats records whether the latest requests matched or got new content, in a ring of two windows
+type discoveryStats struct {
+	events [2 * discoveryWindow]uint8
+	next   int // index the next event is recorded at
+	count  int // events recorded, up to the size of the ring
+	hashes map[string]struct{}
+	mutex  sync.Mutex
+}
+
+func newDiscoveryStats() *discoveryStats {
+	return &discoveryStats{hashes: make(map[string]struct{})}
+}
+
+// record records a response, matched or not, with the hash of its body. Responses without a body hash never count
+// as new content.
+func (d *discoveryStats) record(matched bool, bodyHash string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	event := uint8(0)
+	if matched {
+		event |= discoveryMatch
+	}
+	if _, seen := d.hashes[bodyHash]; bodyHash != "" && !seen && len(d.hashes) < discoveryHashLimit {
+		d.hashes[bodyHash] = struct{}{}
+		event |= discoveryNewContent
+	}
+	d.events[d.next] = event
+	d.next = (d.next + 1) % len(d.events)
+	if d.count < len(d.events) {
+		d.count++
+	}
+}
+
+// rates returns the discovery trends of the latest requests
+func (d *discoveryStats) rates() DiscoveryRates {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var r DiscoveryRates
+	newContent := 0
+	for i := 0; i < d.count; i++ {
+		// Walk back from the latest event
+		event := d.events[(d.next-1-i+len(d.events))%len(d.events)]
+		if i < discoveryWindow {
+			r.Requests++
+			if event&discoveryMatch != 0 {
+				r.Matches++
+			}
+			if event&discoveryNewContent != 0 {
+				newContent++
+			}
+		} else if event&discoveryMatch != 0 {
+			r.PreviousMatches++
+		}
+	}
+	if r.Requests == 0 {
+		return r
+	}
+	r.NewContentRate = float64(newContent) / float64(r.Requests)
+	matchShare := math.Min(1, float64(r.Matches)/float64(r.Requests)/discoveryFullRate)
+	contentShare := math.Min(1, r.NewContentRate/discoveryFullRate)
+	r.StallScore = (1 - matchShare) * (1 - contentShare) * float64(r.Requests) / discoveryWindow
+	return r
+}
+
+// DiscoveryRates returns the trends of the matches and of the new content over the latest requests of the run,
+// along with the stall score rising as both run dry
+func (j *Job) DiscoveryRates() DiscoveryRates {
+	return j.discovery.rates()
+}
@@ -0,0 +1,90 @@
+package ffuf
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// recordSequence records n responses, matching every matchEvery one and with a new body every newEvery one, a zero
+// period meaning never
+func recordSequence(d *discoveryStats, n, matchEvery, newEvery int, offset *int) {
+	d.hashes["seen"] = struct{}{}
+	for i := 0; i < n; i++ {
+		*offset++
+		hash := "seen"
+		if newEvery > 0 && i%newEvery == 0 {
+			hash = fmt.Sprintf("new-%d", *offset)
+		}
+		d.record(matchEvery > 0 && i%matchEvery == 0, hash)
+	}
+}
+
+func TestDiscoveryRatesWindows(t *testing.T) {
+	d := newDiscoveryStats()
+	offset := 0
+	if r := d.rates(); r.Requests != 0 || r.StallScore != 0 {
+		t.Errorf("Expected empty rates before any request, got %+v", r)
+	}
+
+	// A productive first window: every 10th request matches
+	recordSequence(d, discoveryWindow, 10, 0, &offset)
+	r := d.rates()
+	if r.Requests != discoveryWindow || r.Matches != 100 || r.PreviousMatches != 0 {
+		t.Errorf("Unexpected rates after the first window: %+v", r)
+	}
+	if r.StallScore != 0 {
+		t.Errorf("Expected no stall while matching, got %f", r.StallScore)
+	}
+
+	// A dry second window moves the matches to the previous window
+	recordSequence(d, discoveryWindow, 0, 0, &offset)
+	r = d.rates()
+	if r.Matches != 0 || r.PreviousMatches != 100 {
+		t.Errorf("Unexpected rates after the dry window: %+v", r)
+	}
+	if r.StallScore != 1 {
+		t.Errorf("Expected a full stall after a dry window, got %f", r.StallScore)
+	}
+
+	// The ring slides half a window: the last window holds the new matches, the previous one the latest half of the
+	// productive window
+	recordSequence(d, discoveryWindow/2, 50, 0, &offset)
+	r = d.rates()
+	if r.Matches != 10 || r.PreviousMatches != 50 {
+		t.Errorf("Unexpected rates after sliding half a window: %+v", r)
+	}
+}
+
+func TestDiscoveryRatesNewContent(t *testing.T) {
+	d := newDiscoveryStats()
+	offset := 0
+	// No matches, but a new body every 200th request keeps half the stall away
+	recordSequence(d, discoveryWindow, 0, 200, &offset)
+	r := d.rates()
+	if math.Abs(r.NewContentRate-0.005) > 1e-9 {
+		t.Errorf("Expected a new content rate of 0.005, got %f", r.NewContentRate)
+	}
+	if math.Abs(r.StallScore-0.5) > 1e-9 {
+		t.Errorf("Expected a stall score of 0.5, got %f", r.StallScore)
+	}
+
+	// Bodies seen before do not count as new, nor do missing hashes
+	d = newDiscoveryStats()
+	d.record(false, "a")
+	d.record(false, "a")
+	d.record(false, "")
+	if r := d.rates(); math.Abs(r.NewContentRate-1.0/3) > 1e-9 {
+		t.Errorf("Expected one new body out of three, got %f", r.NewContentRate)
+	}
+}
+
+func TestDiscoveryRatesPartialWindow(t *testing.T) {
+	d := newDiscoveryStats()
+	offset := 0
+	// A dry start only stalls as far as the window is filled
+	recordSequence(d, discoveryWindow/4, 0, 0, &offset)
+	if r := d.rates(); math.Abs(r.StallScore-0.25) > 1e-9 {
+		t.Errorf("Expected a stall score of 0.25 for a quarter window, got %f", r.StallScore)
+	}
+}
@@ -0,0 +1,92 @@
+package ffuf
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// PlannedInput is an input of the plan of -markov-dry-run, in the order the job would send it
+type PlannedInput struct {
+	Input    map[string][]byte
+	Position int     // position in the input provider, 0 for the requeued inputs
+	Score    float64 // score the Markov chain ranks the FUZZ value by, 0 without -markov
+	Priority string  // origin of a requeued input, or why the Markov chain placed the input there
+}
+
+// DryRunPlan returns up to n of the inputs the job would send first, drawn from the input source like the job does,
+// without sending them. The input source is consumed, it has to be reset before the job runs.
+func (j *Job) DryRunPlan(n int) []PlannedInput {
+	plan := make([]PlannedInput, 0)
+	for len(plan) < n {
+		input, position, origin, decision, ok := j.nextInput()
+		if !ok {
+			break
+		}
+		p := PlannedInput{Input: make(map[string][]byte, len(input)), Position: position, Priority: decision}
+		for k, v := range input {
+			p.Input[k] = append([]byte{}, v...)
+		}
+		if origin != "" {
+			p.Priority = origin
+		}
+		if token, ok := input["FUZZ"]; ok && j.MarkovChain != nil {
+			p.Score = j.MarkovChain.ExplainRanking(string(token)).Score
+		}
+		plan = append(plan, p)
+	}
+	return plan
+}
+
+// dryRun shows the plan of the first -markov-dry-run inputs of the first queued job, or writes it to
+// -markov-dry-run-out, without sending any of them
+func (j *Job) dryRun() {
+	j.prepareQueueJob()
+	j.Reset(true)
+	plan := j.DryRunPlan(j.Config.MarkovDryRun)
+	if len(j.Config.MarkovDryRunOut) > 0 {
+		if err := writeDryRunPlan(j.Config.MarkovDryRunOut, plan); err != nil {
+			j.Output.Error(fmt.Sprintf("Could not write the dry run plan: %s", err))
+			return
+		}
+		j.Output.Info(fmt.Sprintf("Dry run: wrote the plan of the first %d inputs to %s, no request sent", len(plan), j.Config.MarkovDryRunOut))
+		return
+	}
+	j.Output.Info(fmt.Sprintf("Dry run: the first %d inputs that would be sent, no request sent", len(plan)))
+	for rank, p := range plan {
+		j.Output.Raw(fmt.Sprintf(" [%d] %s (score %.4f, %s)\n", rank+1, plannedInputString(p.Input, ": ", ", "), p.Score, plannedPriority(p)))
+	}
+}
+
+// writeDryRunPlan writes a plan as rank<TAB>position<TAB>score<TAB>input<TAB>decision lines
+func writeDryRunPlan(filename string, plan []PlannedInput) error {
+	var b strings.Builder
+	for rank, p := range plan {
+		fmt.Fprintf(&b, "%d\t%d\t%.4f\t%s\t%s\n", rank+1, p.Position, p.Score, plannedInputString(p.Input, "=", ","), plannedPriority(p))
+	}
+	return os.WriteFile(filename, []byte(b.String()), 0644)
+}
+
+// plannedInputString formats the values of an input by keyword, sorted by keyword
+func plannedInputString(input map[string][]byte, assign string, sep string) string {
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, k+assign+string(input[k]))
+	}
+	return strings.Join(values, sep)
+}
+
+// plannedPriority returns why a planned input is at its place, the inputs without a decision keeping the order of
+// the input provider
+func plannedPriority(p PlannedInput) string {
+	if p.Priority == "" {
+		return "wordlist order"
+	}
+	return p.Priority
+}
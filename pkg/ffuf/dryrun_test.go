@@ -0,0 +1,70 @@
+package ffuf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	known := filepath.Join(dir, "known.txt")
+	if err := os.WriteFile(known, []byte("/backup\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runner := newFakeRunner(answerVersions())
+	j := newFakeJob(t, runner, []string{"admin", "index", "backup", "login", "api_v1"}, func(conf *Config) {
+		conf.MarkovKnownGood = known
+		conf.MarkovDryRun = 3
+		conf.MarkovDryRunOut = filepath.Join(dir, "plan.tsv")
+	})
+	j.Start()
+
+	if sent := runner.tokens(); len(sent) != 0 {
+		t.Fatalf("Expected no request in a dry run, got %v", sent)
+	}
+	data, err := os.ReadFile(j.Config.MarkovDryRunOut)
+	if err != nil {
+		t.Fatalf("Could not read the plan: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected the plan of 3 inputs, got %q", lines)
+	}
+	// The known good path is ranked first with the score the chain gives it
+	score := j.MarkovChain.ExplainRanking("backup").Score
+	if score <= 0 {
+		t.Fatalf("Expected the known good path to have a positive score, got %f", score)
+	}
+	if expected := fmt.Sprintf("1\t3\t%.4f\tFUZZ=backup\t", score); !strings.HasPrefix(lines[0], expected) {
+		t.Errorf("Expected the known good path first, got %q", lines[0])
+	}
+	if lines[1] != "2\t1\t0.0000\tFUZZ=admin\twordlist order" || lines[2] != "3\t2\t0.0000\tFUZZ=index\twordlist order" {
+		t.Errorf("Expected the other inputs in wordlist order, got %q", lines[1:])
+	}
+}
+
+func TestDryRunPlanMatchesPending(t *testing.T) {
+	j, _ := newSeedJob("http://localhost/FUZZ")
+	j.Input = &sliceInput{words: []string{"admin", "index", "backup", "login"}}
+	j.MarkovChain.OriginalProvider = j.Input
+	j.MarkovChain.SeedActions([]string{"login"}, 1.0)
+
+	first := j.DryRunPlan(1)
+	if len(first) != 1 || string(first[0].Input["FUZZ"]) != "login" || first[0].Score != j.MarkovChain.ExplainRanking("login").Score {
+		t.Fatalf("Expected the seeded input first with its ranking score, got %+v", first)
+	}
+	// The rest of the plan is the rest of the batch the chain ranked
+	pending := j.PendingInputs(10)
+	rest := j.DryRunPlan(10)
+	if len(rest) != 3 || len(pending) != 3 {
+		t.Fatalf("Expected the 3 other inputs planned, got %d planned and %d pending", len(rest), len(pending))
+	}
+	for i := range rest {
+		if string(rest[i].Input["FUZZ"]) != string(pending[i].Input["FUZZ"]) || rest[i].Priority != pending[i].Priority {
+			t.Errorf("Input %d: planned %s (%s), pending %s (%s)", i, rest[i].Input["FUZZ"], rest[i].Priority, pending[i].Input["FUZZ"], pending[i].Priority)
+		}
+	}
+}
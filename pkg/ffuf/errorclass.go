@@ -0,0 +1,87 @@
+package ffuf
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+// ErrorClass is the class of the error of a request that failed without a response
+type ErrorClass string
+
+const (
+	ErrorDNS              ErrorClass = "dns"
+	ErrorConnRefused      ErrorClass = "conn-refused"
+	ErrorTLSHandshake     ErrorClass = "tls-handshake"
+	ErrorTimeout          ErrorClass = "timeout"
+	ErrorTooManyRedirects ErrorClass = "too-many-redirects"
+	ErrorResponseTooLarge ErrorClass = "response-too-large"
+	ErrorOther            ErrorClass = "other"
+)
+
+// errorClasses are the error classes in the order they are reported in
+var errorClasses = [...]ErrorClass{ErrorDNS, ErrorConnRefused, ErrorTLSHandshake, ErrorTimeout, ErrorTooManyRedirects, ErrorResponseTooLarge, ErrorOther}
+
+// ClassifyError returns the class of the error of a request that failed without a response. A name resolution
+// failing on a timeout is a DNS error, the host being the one at fault rather than the request.
+func ClassifyError(err error) ErrorClass {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorDNS
+	}
+	if os.IsTimeout(err) {
+		return ErrorTimeout
+	}
+	// The runner wraps the handshake errors in a TLSHandshakeError, the ones of other clients are told by their type
+	// or their message
+	var handshakeErr *TLSHandshakeError
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &handshakeErr) || errors.As(err, &recordErr) || strings.Contains(err.Error(), "tls: ") {
+		return ErrorTLSHandshake
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorConnRefused
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "connection refused"):
+		return ErrorConnRefused
+	case strings.Contains(msg, "server gave HTTP response to HTTPS client"):
+		// A plain HTTP server answering the handshake
+		return ErrorTLSHandshake
+	case strings.Contains(msg, "stopped after") && strings.Contains(msg, "redirects"):
+		// The error of the default redirect policy of net/http
+		return ErrorTooManyRedirects
+	case strings.Contains(msg, "response headers exceeded"), strings.Contains(msg, "header list larger than"):
+		// The response headers over the limit of the HTTP/1.x and HTTP/2 transports
+		return ErrorResponseTooLarge
+	}
+	return ErrorOther
+}
+
+// markovState returns the code class of the Markov chain terminal state of the requests failing on an error of the
+// class
+func (c ErrorClass) markovState() string {
+	switch c {
+	case ErrorTimeout:
+		return markov.CodeClassTimeout
+	case ErrorOther:
+		return markov.CodeClassConnError
+	}
+	return markov.ConnErrorClass(string(c))
+}
+
+// errorClassIndex returns the index of the class in errorClasses, the one of ErrorOther for an unknown class
+func errorClassIndex(class ErrorClass) int {
+	for i, c := range errorClasses {
+		if c == class {
+			return i
+		}
+	}
+	return len(errorClasses) - 1
+}
@@ -0,0 +1,134 @@
+package ffuf
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc is a fake transport failing the requests the way its function does
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// closedAddr returns the address of a port nothing listens on anymore
+func closedAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestClassifyError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Padding", strings.Repeat("a", 4096))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	// A server requiring a client certificate
+	tlsServer := httptest.NewUnstartedServer(mux)
+	tlsServer.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	tests := []struct {
+		name     string
+		client   *http.Client
+		url      string
+		expected ErrorClass
+	}{
+		{"dns", &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: &net.DNSError{Err: "no such host", Name: "nonexistent.invalid", IsNotFound: true}}
+		}}}, "http://nonexistent.invalid/", ErrorDNS},
+		{"dns-timeout", &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: &net.DNSError{Err: "i/o timeout", Name: "slow.invalid", IsTimeout: true}}
+		}}}, "http://slow.invalid/", ErrorDNS},
+		{"conn-refused", &http.Client{Transport: &http.Transport{}}, "http://" + closedAddr(t) + "/", ErrorConnRefused},
+		{"tls-handshake", &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}, tlsServer.URL + "/", ErrorTLSHandshake},
+		{"tls-plain-server", &http.Client{Transport: &http.Transport{}}, strings.Replace(ts.URL, "http://", "https://", 1) + "/", ErrorTLSHandshake},
+		{"timeout", &http.Client{Transport: &http.Transport{}, Timeout: 50 * time.Millisecond}, ts.URL + "/slow", ErrorTimeout},
+		{"too-many-redirects", &http.Client{Transport: &http.Transport{}}, ts.URL + "/loop", ErrorTooManyRedirects},
+		{"response-too-large", &http.Client{Transport: &http.Transport{MaxResponseHeaderBytes: 1024}}, ts.URL + "/large", ErrorResponseTooLarge},
+		{"other", &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("read: connection reset by peer")
+		})}, ts.URL + "/", ErrorOther},
+	}
+	for _, tt := range tests {
+		resp, err := tt.client.Get(tt.url)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("%s: expected the request to fail", tt.name)
+			continue
+		}
+		if class := ClassifyError(err); class != tt.expected {
+			t.Errorf("%s: expected %s, got %s for %s", tt.name, tt.expected, class, err)
+		}
+	}
+
+	// The runner wraps the failed handshakes
+	wrapped := &TLSHandshakeError{Err: &url.Error{Op: "Get", URL: "https://localhost/", Err: errors.New("remote error: certificate required")}}
+	if class := ClassifyError(wrapped); class != ErrorTLSHandshake {
+		t.Errorf("Expected a wrapped handshake error classified as %s, got %s", ErrorTLSHandshake, class)
+	}
+}
+
+func TestErrorClassCounts(t *testing.T) {
+	j, _ := newErrorJob(nil)
+	dnsErr := &url.Error{Op: "Get", URL: "http://nonexistent.invalid/", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "nonexistent.invalid"}}}
+	failures := map[string]error{
+		"dns":     dnsErr,
+		"hang":    &url.Error{Op: "Get", URL: "http://localhost/hang", Err: context.DeadlineExceeded},
+		"refused": &url.Error{Op: "Get", URL: "http://localhost/refused", Err: errors.New("dial tcp 127.0.0.1:80: connect: connection refused")},
+		"reset":   &url.Error{Op: "Get", URL: "http://localhost/reset", Err: errors.New("connection reset by peer")},
+	}
+	j.Runner = newFakeRunner(func(req *Request, resp *Response) error { return failures[fuzzToken(req)] })
+	for i, word := range []string{"dns", "dns", "hang", "refused", "reset"} {
+		j.runTask(map[string][]byte{"FUZZ": []byte(word)}, i+1, "", "")
+	}
+
+	counts := j.metrics.errorCounts()
+	if len(counts) != 4 || counts["dns"] != 2 || counts["timeout"] != 1 || counts["conn-refused"] != 1 || counts["other"] != 1 {
+		t.Errorf("Unexpected failed requests by class: %v", counts)
+	}
+	if summary := j.metrics.errorSummary(); summary != "dns: 2, conn-refused: 1, timeout: 1, other: 1" {
+		t.Errorf("Unexpected error summary: %s", summary)
+	}
+	var metrics strings.Builder
+	j.WriteMetrics(&metrics)
+	for _, e := range []string{`ffuf_errors_total{class="dns"} 2`, `ffuf_errors_total{class="tls-handshake"} 0`, `ffuf_errors_total{class="other"} 1`} {
+		if !strings.Contains(metrics.String(), e) {
+			t.Errorf("Expected %s in the metrics", e)
+		}
+	}
+
+	// The chain tells the failures of the host from the ones of the token
+	transitions := j.MarkovChain.MarkovChain.TransitionCounts["4xx_100_0"]
+	for word, state := range map[string]string{"dns": "conn-error-dns_0_0", "hang": "timeout_0_0", "refused": "conn-error-conn-refused_0_0", "reset": "conn-error_0_0"} {
+		if transitions[word][state] == 0 {
+			t.Errorf("%s: expected a transition to %s, got %v", word, state, transitions[word])
+		}
+	}
+	if summary := j.MarkovChain.MarkovChain.Summary(); !strings.Contains(summary, "connection errors: 4 [conn-refused: 1, dns: 2]") {
+		t.Errorf("Expected the connection errors by class in the chain summary, got %s", summary)
+	}
+}
@@ -0,0 +1,230 @@
+package ffuf
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeHandler sets up the response to a request sent through a fakeRunner, starting from a 404 without a body. A
+// request failing without a response returns the error.
+type fakeHandler func(req *Request, resp *Response) error
+
+// fakeRunner answers the requests with its handler, recording every request sent. The requests are prepared like
+// the SimpleRunner does, the keywords being replaced in the url and the headers.
+type fakeRunner struct {
+	handler  fakeHandler
+	requests []Request
+	mutex    sync.Mutex
+}
+
+// newFakeRunner returns a runner answering with the handler, a nil handler answering every request with a 404
+func newFakeRunner(handler fakeHandler) *fakeRunner {
+	return &fakeRunner{handler: handler}
+}
+
+// answerTokens returns a handler answering the FUZZ tokens with their status, and the other ones with a 404
+func answerTokens(statuses map[string]int64) fakeHandler {
+	return func(req *Request, resp *Response) error {
+		if status, ok := statuses[fuzzToken(req)]; ok {
+			resp.StatusCode = status
+		}
+		return nil
+	}
+}
+
+// fuzzToken returns the value of the FUZZ keyword of a request
+func fuzzToken(req *Request) string {
+	return string(req.Input["FUZZ"])
+}
+
+func (r *fakeRunner) Prepare(input map[string][]byte, basereq *Request) (Request, error) {
+	req := CopyRequest(basereq)
+	for keyword, value := range input {
+		req.Url = strings.ReplaceAll(req.Url, keyword, string(value))
+		for k, v := range req.Headers {
+			req.Headers[k] = strings.ReplaceAll(v, keyword, string(value))
+		}
+	}
+	req.Input = input
+	return req, nil
+}
+
+func (r *fakeRunner) Execute(req *Request) (Response, error) {
+	r.mutex.Lock()
+	r.requests = append(r.requests, *req)
+	r.mutex.Unlock()
+	resp := Response{StatusCode: 404, Request: req, Headers: map[string][]string{}, ScraperData: map[string][]string{}}
+	if r.handler == nil {
+		return resp, nil
+	}
+	if err := r.handler(req, &resp); err != nil {
+		return Response{}, err
+	}
+	return resp, nil
+}
+
+func (r *fakeRunner) Dump(req *Request) ([]byte, error) { return []byte{}, nil }
+
+// sent returns the requests sent so far, in order
+func (r *fakeRunner) sent() []Request {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Request(nil), r.requests...)
+}
+
+// urls returns the urls of the requests sent so far, in order
+func (r *fakeRunner) urls() []string {
+	sent := r.sent()
+	urls := make([]string, 0, len(sent))
+	for _, req := range sent {
+		urls = append(urls, req.Url)
+	}
+	return urls
+}
+
+// tokens returns the FUZZ tokens of the requests sent so far, in order
+func (r *fakeRunner) tokens() []string {
+	sent := r.sent()
+	tokens := make([]string, 0, len(sent))
+	for i := range sent {
+		tokens = append(tokens, fuzzToken(&sent[i]))
+	}
+	return tokens
+}
+
+// origins returns the origin of the requests sent so far by FUZZ token
+func (r *fakeRunner) origins() map[string]string {
+	origins := make(map[string]string)
+	for _, req := range r.sent() {
+		origins[fuzzToken(&req)] = req.Origin
+	}
+	return origins
+}
+
+// counts returns the number of requests sent by FUZZ token, prefixed with the method when withMethod is set
+func (r *fakeRunner) counts(withMethod bool) map[string]int {
+	counts := make(map[string]int)
+	for _, req := range r.sent() {
+		key := fuzzToken(&req)
+		if withMethod {
+			key = req.Method + " " + key
+		}
+		counts[key]++
+	}
+	return counts
+}
+
+// fakeMatcher matches the responses its function matches
+type fakeMatcher struct {
+	match func(*Response) bool
+}
+
+func (m fakeMatcher) Filter(response *Response) (bool, error) { return m.match(response), nil }
+func (m fakeMatcher) Repr() string                            { return "fake" }
+func (m fakeMatcher) ReprVerbose() string                     { return "fake" }
+
+// fakeMatcherManager has a single matcher matching the responses its function matches, and no filter. A nil
+// function configures no matcher at all.
+type fakeMatcherManager struct {
+	MatcherManager
+	match func(*Response) bool
+}
+
+// matchStatus returns a matcher manager matching the responses with one of the status codes
+func matchStatus(codes ...int64) *fakeMatcherManager {
+	return &fakeMatcherManager{match: func(resp *Response) bool {
+		for _, code := range codes {
+			if resp.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// matchAll returns a matcher manager matching every response
+func matchAll() *fakeMatcherManager {
+	return &fakeMatcherManager{match: func(*Response) bool { return true }}
+}
+
+func (m *fakeMatcherManager) GetMatchers() map[string]FilterProvider {
+	if m.match == nil {
+		return map[string]FilterProvider{}
+	}
+	return map[string]FilterProvider{"status": fakeMatcher{match: m.match}}
+}
+func (m *fakeMatcherManager) GetFilters() map[string]FilterProvider {
+	return map[string]FilterProvider{}
+}
+func (m *fakeMatcherManager) FiltersForRoot(root string) map[string]FilterProvider {
+	return map[string]FilterProvider{}
+}
+func (m *fakeMatcherManager) ScopedFilters() map[string]map[string]FilterProvider {
+	return map[string]map[string]FilterProvider{}
+}
+
+// sliceInput is an InputProvider returning the words of a slice for the FUZZ keyword
+type sliceInput struct {
+	words []string
+	pos   int
+}
+
+func (s *sliceInput) ActivateKeywords([]string)             {}
+func (s *sliceInput) AddProvider(InputProviderConfig) error { return nil }
+func (s *sliceInput) Keywords() []string                    { return []string{"FUZZ"} }
+func (s *sliceInput) Next() bool                            { return s.pos < len(s.words) }
+func (s *sliceInput) Position() int                         { return s.pos }
+func (s *sliceInput) SetPosition(pos int)                   { s.pos = pos }
+func (s *sliceInput) Reset()                                { s.pos = 0 }
+func (s *sliceInput) Total() int                            { return len(s.words) }
+func (s *sliceInput) Value() map[string][]byte {
+	s.pos++
+	return map[string][]byte{"FUZZ": []byte(s.words[s.pos-1])}
+}
+
+// recordingOutput keeps track of the responses passed on as results
+type recordingOutput struct {
+	NullOutput
+	responses []Response
+}
+
+func (o *recordingOutput) Result(resp Response) { o.responses = append(o.responses, resp) }
+
+// logOutput records the messages shown to the user, in order
+type logOutput struct {
+	NullOutput
+	messages []string
+	mutex    sync.Mutex
+}
+
+func (o *logOutput) Info(msg string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.messages = append(o.messages, msg)
+}
+func (o *logOutput) Warning(msg string) { o.Info(msg) }
+
+// newFakeJob returns a quiet single threaded job fuzzing http://localhost/FUZZ with the words through the runner,
+// the Markov chain enabled and the 200 responses matched, once configure changed the configuration. The job keeps
+// its results in a recordingOutput.
+func newFakeJob(t *testing.T, runner *fakeRunner, words []string, configure func(conf *Config)) *Job {
+	HISTORYDIR = t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	conf := NewConfig(ctx, cancel)
+	conf.Url = "http://localhost/FUZZ"
+	conf.Method = "GET"
+	conf.Quiet = true
+	conf.Threads = 1
+	conf.Markov = true
+	conf.MatcherManager = matchStatus(200)
+	if configure != nil {
+		configure(&conf)
+	}
+	j := NewJob(&conf)
+	j.Input = &sliceInput{words: words}
+	j.Output = &recordingOutput{}
+	j.Runner = runner
+	return j
+}
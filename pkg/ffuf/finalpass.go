@@ -0,0 +1,71 @@
+package ffuf
+
+import (
+	"fmt"
+)
+
+// startFinalPass queues the final pass of -markov-final-pass once the inputs of the current queue job are
+// exhausted: up to MarkovFinalPass tokens generated from the patterns of the matches that were never sent, the
+// ones the chain expects the best reward from first. Returns false if there is nothing to send, or the final pass
+// of the queue job was run already.
+func (j *Job) startFinalPass() bool {
+	if j.MarkovChain == nil || j.Config.MarkovFinalPass == 0 || j.finalPassStarted {
+		return false
+	}
+	j.finalPassStarted = true
+	if keywords := j.Input.Keywords(); len(keywords) != 1 || keywords[0] != "FUZZ" {
+		j.Output.Warning("The Markov final pass (-markov-final-pass) is only run with the single keyword FUZZ")
+		return false
+	}
+	sent := func(token string) bool {
+		return j.sent.contains(j.sentKey(map[string][]byte{"FUZZ": []byte(token)}))
+	}
+	candidates := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, token := range j.MarkovChain.PatternExpansions(sent) {
+		if !seen[token] {
+			seen[token] = true
+			candidates = append(candidates, token)
+		}
+	}
+	// The tokens the chain expects a reward from come first, the rest in the order of the patterns
+	tokens := j.MarkovChain.RankTokens(candidates, j.Config.MarkovFinalPass)
+	ranked := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		ranked[token] = true
+	}
+	for _, token := range candidates {
+		if len(tokens) == j.Config.MarkovFinalPass {
+			break
+		}
+		if !ranked[token] {
+			tokens = append(tokens, token)
+		}
+	}
+	for _, token := range tokens {
+		j.requeue(map[string][]byte{"FUZZ": []byte(token)}, "final-pass")
+	}
+	j.requeueMutex.Lock()
+	j.finalPassTotal = len(j.requeued)
+	j.requeueMutex.Unlock()
+	if j.finalPassTotal == 0 {
+		j.Output.Info("No candidate left for the Markov final pass")
+		return false
+	}
+	j.finalPassStart = j.requestCount()
+	j.Output.Info(fmt.Sprintf("Starting the Markov final pass of %d inputs", j.finalPassTotal))
+	return true
+}
+
+// inFinalPass returns true once the final pass of the current queue job is queued. No more inputs are queued from
+// the matches of the final pass, for it to end after its inputs.
+func (j *Job) inFinalPass() bool {
+	return j.finalPassTotal > 0
+}
+
+// resetFinalPass allows the final pass to run again for the next queue job
+func (j *Job) resetFinalPass() {
+	j.finalPassStarted = false
+	j.finalPassTotal = 0
+	j.finalPassStart = 0
+}
@@ -0,0 +1,45 @@
+package ffuf
+
+import (
+	"testing"
+)
+
+func runFinalPass(t *testing.T, finalPass int) (*fakeRunner, int) {
+	runner := newFakeRunner(answerVersions())
+	j := newFakeJob(t, runner, []string{"admin", "api_v1", "index", "api_v2", "login"}, func(conf *Config) {
+		conf.MarkovPatternMax = 2
+		conf.MarkovFinalPass = finalPass
+	})
+	j.Start()
+	return runner, j.finalPassTotal
+}
+
+func TestFinalPass(t *testing.T) {
+	baseRunner, _ := runFinalPass(t, 0)
+	base := baseRunner.tokens()
+	for _, origin := range baseRunner.origins() {
+		if origin == "final-pass" {
+			t.Fatalf("Expected no final pass with -markov-final-pass 0")
+		}
+	}
+
+	runner, total := runFinalPass(t, 4)
+	sent, origins := runner.tokens(), runner.origins()
+	if total != 4 {
+		t.Fatalf("Expected the final pass to queue 4 inputs, got %d", total)
+	}
+	// The final pass comes last, sends only inputs never sent before, and stops after its inputs
+	if len(sent) != len(base)+4 {
+		t.Fatalf("Expected the final pass to add 4 requests to %v, got %v", base, sent)
+	}
+	seen := make(map[string]bool)
+	for idx, token := range sent {
+		if seen[token] {
+			t.Errorf("Expected %s to be sent once", token)
+		}
+		seen[token] = true
+		if finalPass := origins[token] == "final-pass"; finalPass != (idx >= len(base)) {
+			t.Errorf("Unexpected origin %q of %s at %d", origins[token], token, idx)
+		}
+	}
+}
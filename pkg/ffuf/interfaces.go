@@ -15,6 +15,10 @@ type MatcherManager interface {
 	GetFilters() map[string]FilterProvider
 	GetMatchers() map[string]FilterProvider
 	FiltersForDomain(domain string) map[string]FilterProvider
+	AddScopedFilter(root string, name string, option string, replace bool) error
+	RemoveScopedFilter(root string, name string)
+	FiltersForRoot(root string) map[string]FilterProvider
+	ScopedFilters() map[string]map[string]FilterProvider
 	CalibratedForDomain(domain string) bool
 	Calibrated() bool
 }
@@ -26,6 +30,19 @@ type FilterProvider interface {
 	ReprVerbose() string
 }
 
+// StreamingFilter is implemented by the filters able to decide while the response body is read, for the runner to
+// stop reading the body of the responses known to be filtered
+type StreamingFilter interface {
+	// BodyScanner returns a scanner of the body of the response, nil if the filter needs the whole body
+	BodyScanner(response *Response) BodyScanner
+}
+
+// BodyScanner is fed the body of a response as it is read
+type BodyScanner interface {
+	// Scan scans the next chunk of the body, returning true once the filter matches
+	Scan(p []byte) bool
+}
+
 // RunnerProvider is an interface for request executors
 type RunnerProvider interface {
 	Prepare(input map[string][]byte, basereq *Request) (Request, error)
@@ -33,6 +50,82 @@ type RunnerProvider interface {
 	Dump(req *Request) ([]byte, error)
 }
 
+// SummaryProvider is implemented by the providers having statistics to show at the end of the run
+type SummaryProvider interface {
+	Summary() []string
+}
+
+// ResultsProvider is implemented by the output providers keeping the results of the previous queue jobs
+type ResultsProvider interface {
+	// GetResults returns the results of all the queue jobs so far
+	GetResults() []Result
+}
+
+// TechnologyReporter is implemented by the output providers writing the technology guess of the target to the
+// report files
+type TechnologyReporter interface {
+	SetTechnology(guess string)
+}
+
+// ParamChange is a Markov learning parameter or reward weight changed during the run
+type ParamChange struct {
+	Time string  `json:"time"`
+	Name string  `json:"name"`
+	Old  float64 `json:"old"`
+	New  float64 `json:"new"`
+}
+
+// ParamChangeReporter is implemented by the output providers writing the Markov parameters changed during the run
+// to the report files
+type ParamChangeReporter interface {
+	SetParamChanges(changes []ParamChange)
+}
+
+// ErrorReporter is implemented by the output providers writing the number of failed requests by error class to the
+// report files
+type ErrorReporter interface {
+	SetErrorClasses(counts map[string]int64)
+}
+
+// FeedbackProvider is implemented by the input providers adapting to the outcome of the requests
+type FeedbackProvider interface {
+	// Feedback records the reward of the request sent with the input, between 0 and 1
+	Feedback(input map[string][]byte, reward float64)
+	// Allocation returns a short description of how the inputs are currently chosen, empty if not adapting
+	Allocation() string
+}
+
+// WordsProvider is implemented by the input providers able to list the words of a keyword in advance
+type WordsProvider interface {
+	// Words returns the words of the wordlists of the keyword, nil if the keyword is not backed by a wordlist
+	Words(keyword string) [][]byte
+}
+
+// ExtensionRanker ranks the extensions the %EXT% placeholder of the wordlists is expanded with in DirSearch
+// compatibility mode, leaving out the ones the target clearly does not serve
+type ExtensionRanker interface {
+	// RankExtensions returns the extensions to expand the next placeholder with, unchanged until there is enough
+	// evidence to rank them
+	RankExtensions(extensions []string) []string
+}
+
+// ExtensionExpander is implemented by the input providers expanding the %EXT% placeholder of the wordlists
+type ExtensionExpander interface {
+	SetExtensionRanker(ranker ExtensionRanker)
+}
+
+// ValueRanker gives the expected reward of the values of a keyword, for the input providers enumerating the
+// combinations of several wordlists best first
+type ValueRanker interface {
+	// ValueRewards returns the expected reward of each of the values of the keyword, 0 for the values not seen
+	ValueRewards(keyword string, values [][]byte) []float64
+}
+
+// ValueOrderer is implemented by the input providers enumerating the combinations of the wordlists best first
+type ValueOrderer interface {
+	SetValueRanker(ranker ValueRanker)
+}
+
 // InputProvider interface handles the input data for RunnerProvider
 type InputProvider interface {
 	ActivateKeywords([]string)
@@ -106,11 +199,31 @@ type Result struct {
 	ContentWords     int64               `json:"words"`
 	ContentLines     int64               `json:"lines"`
 	ContentType      string              `json:"content-type"`
+	ContentEncoding  string              `json:"content-encoding"`
+	WireLength       int64               `json:"wire_length"`
 	RedirectLocation string              `json:"redirectlocation"`
 	Url              string              `json:"url"`
 	Duration         time.Duration       `json:"duration"`
+	DNSMs            int64               `json:"dns_ms"`
+	ConnectMs        int64               `json:"connect_ms"`
+	TLSMs            int64               `json:"tls_ms"`
+	TTFBMs           int64               `json:"ttfb_ms"`
+	BodyHash         string              `json:"body_hash"`
+	Redirects        []RedirectHop       `json:"redirects,omitempty"`
 	ScraperData      map[string][]string `json:"scraper"`
 	ResultFile       string              `json:"resultfile"`
 	Host             string              `json:"host"`
+	Retries          int                 `json:"retries"`
+	Reward           float64             `json:"reward"`
+	CertMismatch     bool                `json:"cert_mismatch"`
+	Reflected        bool                `json:"reflected"`
+	Locations        map[string]string   `json:"locations"`
+	HeaderPreset     string              `json:"header_preset"`
+	Origin           string              `json:"origin"`
+	Allow            string              `json:"allow,omitempty"` // Allow header of a 405 or 501 response
+	Method           string              `json:"-"`
+	RequestHeaders   map[string]string   `json:"-"`
+	RequestData      []byte              `json:"-"`
+	Decision         string              `json:"-"`
 	HTMLColor        string              `json:"-"`
 }
@@ -1,18 +1,24 @@
 package ffuf
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	
 	"github.com/ffuf/ffuf/v2/pkg/markov"
 )
 
+// markovTopActions is the number of best, and slowest, Markov chain actions listed at the end of a verbose run
+const markovTopActions = 5
+
 // Job ties together Config, Runner, Input and Output
 type Job struct {
 	AuditLogger          AuditLogger
@@ -25,6 +31,7 @@ type Job struct {
 	Output               OutputProvider
 	Jobhash              string
 	Counter              int
+	counterMutex         sync.Mutex // held while the request counter changes, for the progress and checkpoints to read it
 	ErrorCounter         int
 	SpuriousErrorCounter int
 	Total                int
@@ -39,9 +46,42 @@ type Job struct {
 	startTimeJob         time.Time
 	queuejobs            []QueueJob
 	queuepos             int
+	queueMutex           sync.Mutex // held while the queue changes, for the status server to read it
 	skipQueue            bool
 	currentDepth         int
 	MarkovChain          *markov.MarkovInputProvider
+	cooldownAgents       []string     // User-Agents rotated through after each cooldown
+	cooldownAgent        int          // index of the User-Agent in use
+	userAgent            atomic.Value // User-Agent overriding the one of the requests, set after a cooldown
+	headerPool           *markov.HeaderPool
+	requeued             []requeuedInput
+	requeueMutex         sync.Mutex
+	derivedSeeds         map[uint64][]string             // matched tokens the requeued inputs were derived from, by sent key
+	neighbors            map[string]*markov.TrigramIndex // trigram index of the wordlist of each keyword
+	neighborsMutex       sync.Mutex
+	sent                 *sentCache      // inputs sent by the current queue job
+	finalPassStarted     bool            // whether the final pass of the current queue job was considered
+	finalPassTotal       int             // number of inputs queued by the final pass
+	finalPassStart       int             // request counter when the final pass started
+	markovCold           bool            // whether the last input was drawn under the cold state policy of the chain
+	dirProbed            map[string]bool // directories of the current queue job the index files were probed in
+	methodProbed         map[string]bool // inputs of the current queue job resent with a method of an Allow header, by method
+	budget               *requestBudget  // requests left of -request-budget, nil without a budget
+	rateLimit            rateLimitPause  // pause of the job asked for by the rate limited responses
+	recursionRoot        string          // URL of the current queue job up to the keyword, the scope of the scoped filters
+	calibratedRoots      map[string]bool // recursion roots calibrated with per root autocalibration
+	handshakeWarning     sync.Once       // warns of the first failed TLS handshake
+	cookieJar            *CookieJar      // cookies of -cookie-jar, updated by the matched responses
+	inflightID           int
+	inflight             map[int]requeuedInput // inputs being sent, by id, tracked for the checkpoints
+	checkpointMutex      sync.Mutex            // held while drawing an input, for a checkpoint to see it as pending or sent
+	resume               *Checkpoint           // checkpoint of -resume-checkpoint, until the current queue job is restored
+	caseProbes           map[string]caseProbe  // case probes of each recursion root
+	metrics              Metrics
+	discovery            *discoveryStats // rate of discovery over the latest requests of the run
+	catchAll             catchAllDetector
+	techFingerprint      techFingerprint
+	stackFilter          stackFilter // platform of the technology guess the chain deprioritizes the other stacks for
 	calibMutex           sync.Mutex
 	pauseWg              sync.WaitGroup
 }
@@ -67,9 +107,32 @@ func NewJob(conf *Config) *Job {
 	j.Rate = NewRateThrottle(conf)
 	j.skipQueue = false
 	j.MarkovChain = nil
+	j.sent = newSentCache(sentCacheExact, sentCacheBloomBits)
+	j.discovery = newDiscoveryStats()
 	return &j
 }
 
+// incCounter increments the request counter
+func (j *Job) incCounter() {
+	j.counterMutex.Lock()
+	defer j.counterMutex.Unlock()
+	j.Counter++
+}
+
+// setCounter sets the request counter
+func (j *Job) setCounter(counter int) {
+	j.counterMutex.Lock()
+	defer j.counterMutex.Unlock()
+	j.Counter = counter
+}
+
+// requestCount returns the request counter
+func (j *Job) requestCount() int {
+	j.counterMutex.Lock()
+	defer j.counterMutex.Unlock()
+	return j.Counter
+}
+
 // incError increments the error counter
 func (j *Job) incError() {
 	j.ErrorMutex.Lock()
@@ -101,10 +164,29 @@ func (j *Job) resetSpuriousErrors() {
 
 // DeleteQueueItem deletes a recursion job from the queue by its index in the slice
 func (j *Job) DeleteQueueItem(index int) {
+	j.queueMutex.Lock()
+	defer j.queueMutex.Unlock()
 	index = j.queuepos + index - 1
 	j.queuejobs = append(j.queuejobs[:index], j.queuejobs[index+1:]...)
 }
 
+// enqueue adds a job to the end of the queue
+func (j *Job) enqueue(job QueueJob) {
+	j.queueMutex.Lock()
+	defer j.queueMutex.Unlock()
+	j.queuejobs = append(j.queuejobs, job)
+}
+
+// queueDepth returns the number of queued jobs waiting to be started
+func (j *Job) queueDepth() int {
+	j.queueMutex.Lock()
+	defer j.queueMutex.Unlock()
+	if depth := len(j.queuejobs) - j.queuepos; depth > 0 {
+		return depth
+	}
+	return 0
+}
+
 // QueuedJobs returns the slice of queued recursive jobs
 func (j *Job) QueuedJobs() []QueueJob {
 	return j.queuejobs[j.queuepos-1:]
@@ -115,52 +197,305 @@ func (j *Job) Start() {
 	if j.startTime.IsZero() {
 		j.startTime = time.Now()
 	}
+	if j.Config.CookieJar != "" && j.cookieJar == nil {
+		jar, err := LoadCookieJar(j.Config.CookieJar)
+		if err != nil {
+			j.Output.Error(fmt.Sprintf("Could not read the cookie jar: %s", err))
+		} else {
+			j.cookieJar = jar
+		}
+	}
 
 	basereq := BaseRequest(j.Config)
 
 	// Initialize Markov chain if markov mode is enabled
-	// For now, we'll create a basic baseline state. In a full implementation,
-	// we would establish a baseline by sending a request to a known non-existent path
-	baselineState := markov.State{
-		CodeClass:  "4xx", // Assuming baseline is 404
-		SizeBucket: markov.QuantizeSize(139), // Common 404 response size
-		Depth:      j.currentDepth,
-	}
-	baselineSizeHash := markov.GetSizeHash([]byte("404 not found")) // Placeholder
-	
-	j.MarkovChain = markov.NewMarkovInputProvider(j.Input, baselineState, baselineSizeHash, j.currentDepth)
+	if j.Config.Markov {
+		// For now, we'll create a basic baseline state. In a full implementation,
+		// we would establish a baseline by sending a request to a known non-existent path
+		baselineState := markov.State{
+			CodeClass:  "4xx",                    // Assuming baseline is 404
+			SizeBucket: markov.QuantizeSize(139), // Common 404 response size
+			Depth:      j.currentDepth,
+		}
+		baselineSizeHash := markov.GetSizeHash([]byte("404 not found")) // Placeholder
+
+		j.MarkovChain = markov.NewMarkovInputProvider(j.Input, baselineState, baselineSizeHash, j.currentDepth)
+		j.MarkovChain.SetProtocolState(j.Config.MarkovProto)
+		j.MarkovChain.SetHeaderState(j.Config.MarkovHeaders, j.Config.MarkovCookie)
+		j.MarkovChain.SetCookieReward(j.Config.MarkovCookieReward)
+		j.MarkovChain.SetRedirectReward(j.Config.MarkovRedirectReward)
+		j.MarkovChain.SetClassQuota(j.Config.MarkovClassQuota)
+		j.MarkovChain.SetThreshold(j.Config.MarkovThreshold)
+		j.MarkovChain.SetReflectionReward(j.Config.MarkovReflectReward)
+		j.MarkovChain.SetDurationCheck(j.Config.MarkovDurationCV)
+		j.MarkovChain.SetTokenHygiene(j.Config.MarkovUniqueEntropy, j.Config.MarkovNormalize)
+		if j.Config.MarkovRewardExpr != "" {
+			expr, err := markov.CompileRewardExpr(j.Config.MarkovRewardExpr)
+			if err != nil {
+				j.Output.Warning(fmt.Sprintf("Invalid Markov reward expression, using the built-in rewards: %s", err))
+			} else {
+				j.MarkovChain.SetRewardExpr(expr)
+			}
+		}
+		j.MarkovChain.SetGranularity(markov.StateGranularity(j.Config.MarkovGranularity))
+		if buckets, err := markov.ParseBucketizer(j.Config.MarkovSizeBuckets); err == nil {
+			j.MarkovChain.SetBucketizer(buckets)
+		}
+		j.MarkovChain.SetErrorRewards(j.Config.MarkovTimeoutReward, j.Config.MarkovConnErrorReward)
+		j.MarkovChain.SetKeywordLocations(j.Config.KeywordLocations)
+		j.MarkovChain.SetTopActions(j.Config.MarkovTop)
+		j.MarkovChain.SetColdStateBudget(j.Config.MarkovColdBudget)
+		if share, err := markov.ParsePhases(j.Config.MarkovPhases); err == nil {
+			j.MarkovChain.SetLearnPhase(share)
+		}
+		j.MarkovChain.SetDebugLog(j.Config.Debuglog != "")
+		j.MarkovChain.SetPrefixThreshold(j.Config.MarkovPrefixThreshold)
+		if ee, ok := j.Input.(ExtensionExpander); ok {
+			ee.SetExtensionRanker(j.MarkovChain)
+		}
+		if vo, ok := j.Input.(ValueOrderer); ok {
+			vo.SetValueRanker(j.MarkovChain)
+		}
+		if j.Config.MarkovCooldown > 0 {
+			statuses, _ := markov.ParseStatusSet(j.Config.MarkovCooldownStatus)
+			j.MarkovChain.SetBlockDetection(statuses, markovBlockWindow, time.Duration(j.Config.MarkovCooldown*float64(time.Second)))
+		}
+		if len(j.Config.MarkovCooldownUA) > 0 {
+			agents, err := readUserAgents(j.Config.MarkovCooldownUA)
+			if err != nil {
+				j.Output.Warning(fmt.Sprintf("Could not read the cooldown User-Agents: %s", err))
+			}
+			// Start with the User-Agent of the request, switching to the pool after the first cooldown
+			j.cooldownAgents = agents
+			j.cooldownAgent = -1
+		}
+	}
+
+	if len(j.Config.HeaderPool) > 0 {
+		names := make([]string, 0, len(j.Config.HeaderPool))
+		for _, p := range j.Config.HeaderPool {
+			names = append(names, p.Name)
+		}
+		statuses, _ := markov.ParseStatusSet(j.Config.MarkovCooldownStatus)
+		j.headerPool = markov.NewHeaderPool(names, statuses)
+	}
 
 	if j.Config.InputMode == "sniper" {
 		// process multiple payload locations and create a queue job for each location
 		reqs := SniperRequests(&basereq, j.Config.InputProviders[0].Template)
 		for _, r := range reqs {
-			j.queuejobs = append(j.queuejobs, QueueJob{Url: j.Config.Url, depth: 0, req: r})
+			j.enqueue(QueueJob{Url: j.Config.Url, depth: 0, req: r})
 		}
 		j.Total = j.Input.Total() * len(reqs)
 	} else {
 		// Add the default job to job queue
-		j.queuejobs = append(j.queuejobs, QueueJob{Url: j.Config.Url, depth: 0, req: BaseRequest(j.Config)})
+		j.enqueue(QueueJob{Url: j.Config.Url, depth: 0, req: BaseRequest(j.Config)})
 		j.Total = j.Input.Total()
 	}
 
 	rand.Seed(time.Now().UnixNano())
 	defer j.Stop()
 
+	if j.Config.RequestBudget > 0 {
+		j.budget = newRequestBudget(j.Config.RequestBudget, j.Config.RequestBudgetExplore)
+		// The never seen states are not explored for longer than the share of the budget allows
+		if j.MarkovChain != nil && j.budget.explore < j.Config.MarkovColdBudget {
+			j.MarkovChain.SetColdStateBudget(j.budget.explore)
+		}
+	}
+
 	j.Running = true
 	j.RunningJob = true
 	//Show banner if not running in silent mode
 	if !j.Config.Quiet {
 		j.Output.Banner()
 	}
+	// Continue learning from a saved chain, the seeds below are added on top of it
+	if j.MarkovChain != nil && len(j.Config.MarkovLoad) > 0 {
+		warnings, err := j.MarkovChain.LoadChain(j.Config.MarkovLoad)
+		if err != nil {
+			j.Output.Warning(fmt.Sprintf("Could not load the Markov chain, starting from scratch: %s", err))
+		}
+		for _, w := range warnings {
+			j.Output.Warning(w)
+		}
+	}
+	// Warm-start the chain from the proxy history before sending any requests
+	if j.MarkovChain != nil && len(j.Config.MarkovSeedHistory) > 0 {
+		count, err := j.MarkovChain.SeedFromHistory(j.Config.MarkovSeedHistory)
+		if err != nil {
+			j.Output.Warning(fmt.Sprintf("Could not fully read the Markov seed history: %s", err))
+		}
+		if !j.Config.Quiet {
+			j.Output.Info(fmt.Sprintf("Markov chain seeded with %d entries from %s", count, j.Config.MarkovSeedHistory))
+		}
+	}
+	if len(j.Config.ResumeCheckpoint) > 0 {
+		j.restoreCheckpoint()
+	}
+	if j.MarkovChain != nil && j.Config.MarkovSeedTarget {
+		j.seedFromTarget()
+	}
+	if j.MarkovChain != nil && len(j.Config.MarkovKnownGood) > 0 {
+		j.seedKnownGood()
+	}
+	// The plan of a dry run comes from the loaded and seeded chain alone, without calibration
+	if j.Config.MarkovDryRun > 0 {
+		j.dryRun()
+		return
+	}
+	if j.MarkovChain != nil && j.fuzzesHost() {
+		j.calibrateVhost()
+	}
 	// Monitor for SIGTERM and do cleanup properly (writing the output files etc)
 	j.interruptMonitor()
-	for j.jobsInQueue() {
+	if j.MarkovChain != nil {
+		j.reloadMonitor()
+	}
+	if len(j.Config.StatusAddr) > 0 {
+		stopStatus, err := j.startStatusServer()
+		if err != nil {
+			j.Output.Error(fmt.Sprintf("Could not start the status endpoint: %s", err))
+		} else {
+			defer stopStatus()
+		}
+	}
+	stopSync := func() {}
+	if j.MarkovChain != nil && len(j.Config.MarkovSync) > 0 {
+		stopSync = j.startMarkovSync()
+	}
+	stopCheckpoints := func() {}
+	if len(j.Config.CheckpointDir) > 0 {
+		stopCheckpoints = j.startCheckpoints()
+	}
+	stopMemoryLimit := func() {}
+	if j.Config.MarkovMaxMemory > 0 {
+		stopMemoryLimit = j.startMemoryLimit()
+	}
+	// The workers queue the chain updates to a goroutine of their own rather than waiting for its lock
+	if j.MarkovChain != nil && j.Config.MarkovUpdateBuffer > 0 {
+		j.MarkovChain.MarkovChain.StartAsyncUpdates(j.Config.MarkovUpdateBuffer)
+	}
+	// A stopped job keeps its position for the checkpoint rather than moving through the rest of the queue
+	for j.jobsInQueue() && j.Running {
 		j.prepareQueueJob()
 		j.Reset(true)
+		j.resumeQueueJob()
+		j.requeueJSONValues()
+		j.requeueQueryValues()
 		j.RunningJob = true
 		j.startExecution()
 	}
+	if j.MarkovChain != nil {
+		// Apply the queued updates before the chain is synced, summarized and saved
+		j.MarkovChain.MarkovChain.StopAsyncUpdates()
+	}
+	stopMemoryLimit()
+	stopCheckpoints()
+	stopSync()
+
+	if !j.Config.Quiet {
+		if j.budget != nil {
+			j.Output.Info(j.budget.Summary())
+		}
+		if limited := atomic.LoadInt64(&j.metrics.rateLimited); limited > 0 {
+			j.Output.Info(fmt.Sprintf("Rate limited %d times, paused for %s as asked by the target", limited, time.Duration(atomic.LoadInt64(&j.metrics.rateLimitWait))))
+		}
+		if summary := j.metrics.errorSummary(); summary != "" {
+			j.Output.Info(fmt.Sprintf("Failed requests by class: %s", summary))
+		}
+		if j.MarkovChain != nil {
+			j.Output.Info(j.MarkovChain.MarkovChain.Summary())
+			if duplicates := atomic.LoadInt64(&j.metrics.duplicates); duplicates > 0 {
+				j.Output.Info(fmt.Sprintf("Skipped %d inputs sent before", duplicates))
+			}
+			if duplicates := atomic.LoadInt64(&j.metrics.caseDuplicates); duplicates > 0 {
+				j.Output.Info(fmt.Sprintf("Skipped %d inputs differing only by their case from the ones sent before", duplicates))
+			}
+			for _, m := range j.MarkovChain.MarkovChain.MethodBreakdown() {
+				j.Output.Info(fmt.Sprintf("Markov method %s", m))
+			}
+			if summary := j.MarkovChain.StackSummary(); summary != "" {
+				j.Output.Info(fmt.Sprintf("Markov %s", summary))
+			}
+			for _, r := range j.MarkovChain.RetiredSeeds() {
+				j.Output.Info(fmt.Sprintf("Markov seed retired: %s", r))
+			}
+			for _, s := range j.MarkovChain.PrefixSuggestions() {
+				j.Output.Info(fmt.Sprintf("Markov prefix suggestion: %s", s))
+			}
+			for _, c := range j.MarkovChain.ParamChanges() {
+				j.Output.Info(fmt.Sprintf("Markov parameter %s", c))
+			}
+			j.Output.Info(fmt.Sprintf("Memory used: %s", j.MemoryUsage()))
+			bloom := atomic.LoadInt64(&j.metrics.bloomEvictions)
+			matched := atomic.LoadInt64(&j.metrics.matchedEvictions)
+			states := atomic.LoadInt64(&j.metrics.stateEvictions)
+			if bloom+matched+states > 0 {
+				j.Output.Info(fmt.Sprintf("Evicted to stay under the memory limit: %d bloom filter drops, %d matched inputs, %d chain states", bloom, matched, states))
+			}
+			if j.Config.Verbose {
+				for _, a := range j.MarkovChain.MarkovChain.TopActions(markovTopActions) {
+					j.Output.Info(fmt.Sprintf("Markov top action: %s", a))
+				}
+				for _, a := range j.MarkovChain.SlowestActions(markovTopActions) {
+					j.Output.Info(fmt.Sprintf("Markov slowest action: %s", a))
+				}
+			}
+		}
+		if evidence := j.catchAll.Evidence(); evidence != "" {
+			j.Output.Info(fmt.Sprintf("Catch-all target detected: %s", evidence))
+		}
+		if guess, ok := j.techFingerprint.Guess(); ok {
+			j.Output.Info(fmt.Sprintf("Technology guess: %s", guess))
+		}
+		if j.Config.Verbose {
+			for _, line := range j.techFingerprint.ExtensionSummary() {
+				j.Output.Info(line)
+			}
+		}
+		if sp, ok := j.Runner.(SummaryProvider); ok {
+			for _, line := range sp.Summary() {
+				j.Output.Info(line)
+			}
+		}
+		if sp, ok := j.Input.(SummaryProvider); ok {
+			for _, line := range sp.Summary() {
+				j.Output.Info(line)
+			}
+		}
+		if j.headerPool != nil {
+			for _, line := range j.headerPool.Summary() {
+				j.Output.Info(line)
+			}
+		}
+		for _, line := range j.ScopedFilterSummary() {
+			j.Output.Info(line)
+		}
+	}
+
+	if j.MarkovChain != nil && len(j.Config.MarkovSave) > 0 {
+		if err := j.MarkovChain.SaveChain(j.Config.MarkovSave); err != nil {
+			j.Output.Error(fmt.Sprintf("Could not save the Markov chain: %s", err))
+		}
+	}
+	if j.MarkovChain != nil && len(j.Config.MarkovExportWeights) > 0 {
+		if err := j.MarkovChain.ExportWeights(j.Config.MarkovExportWeights, j.Config.MarkovExportAggregate); err != nil {
+			j.Output.Error(fmt.Sprintf("Could not export the Markov chain weights: %s", err))
+		}
+	}
 
+	if pr, ok := j.Output.(ParamChangeReporter); ok && j.MarkovChain != nil {
+		pr.SetParamChanges(j.MarkovParamChanges())
+	}
+	if er, ok := j.Output.(ErrorReporter); ok {
+		er.SetErrorClasses(j.metrics.errorCounts())
+	}
+	if tr, ok := j.Output.(TechnologyReporter); ok {
+		if guess, ok := j.techFingerprint.Guess(); ok {
+			tr.SetTechnology(guess.String())
+		}
+	}
 	err := j.Output.Finalize()
 	if err != nil {
 		j.Output.Error(err.Error())
@@ -169,8 +504,26 @@ func (j *Job) Start() {
 
 // Reset resets the counters and wordlist position for a job
 func (j *Job) Reset(cycle bool) {
-	j.Input.Reset()
-	j.Counter = 0
+	j.inputSource().Reset()
+	j.requeueMutex.Lock()
+	j.requeued = nil
+	j.dirProbed = nil
+	j.methodProbed = nil
+	j.derivedSeeds = nil
+	if j.MarkovChain != nil {
+		j.MarkovChain.SetCaseInsensitive(j.caseProbes[j.recursionRoot].insensitive)
+	}
+	j.requeueMutex.Unlock()
+	j.neighborsMutex.Lock()
+	for _, ix := range j.neighbors {
+		if ix != nil {
+			ix.ResetSent()
+		}
+	}
+	j.neighborsMutex.Unlock()
+	j.sent.reset()
+	j.resetFinalPass()
+	j.setCounter(0)
 	j.skipQueue = false
 	j.startTimeJob = time.Now()
 	if cycle {
@@ -187,6 +540,7 @@ func (j *Job) jobsInQueue() bool {
 func (j *Job) prepareQueueJob() {
 	j.Config.Url = j.queuejobs[j.queuepos].Url
 	j.currentDepth = j.queuejobs[j.queuepos].depth
+	j.recursionRoot = recursionRoot(j.Config.Url)
 
 	//Find all keywords present in new queued job
 	kws := j.Input.Keywords()
@@ -198,7 +552,9 @@ func (j *Job) prepareQueueJob() {
 	}
 	//And activate / disable inputproviders as needed
 	j.Input.ActivateKeywords(found_kws)
+	j.queueMutex.Lock()
 	j.queuepos += 1
+	j.queueMutex.Unlock()
 	j.Jobhash, _ = WriteHistoryEntry(j.Config)
 }
 
@@ -246,7 +602,9 @@ func (j *Job) Resume() {
 func (j *Job) startExecution() {
 	var wg sync.WaitGroup
 	wg.Add(1)
-	go j.runBackgroundTasks(&wg)
+	// Closed once all the inputs were sent, the ones skipped as sent before leaving the counter short of the total
+	done := make(chan struct{})
+	go j.runBackgroundTasks(&wg, done)
 
 	// Print the base URL when starting a new recursion or sniper queue job
 	if j.queuepos > 1 {
@@ -260,7 +618,9 @@ func (j *Job) startExecution() {
 	//Limiter blocks after reaching the buffer, ensuring limited concurrency
 	threadlimiter := make(chan bool, j.Config.Threads)
 
-	for j.Input.Next() && !j.skipQueue {
+	// Tasks of the loop, which may requeue inputs
+	var tasks sync.WaitGroup
+	for !j.skipQueue {
 		// Check if we should stop the process
 		j.CheckStop()
 
@@ -269,23 +629,53 @@ func (j *Job) startExecution() {
 			break
 		}
 		j.pauseWg.Wait()
+		j.rateLimit.wait(j.Config.Context)
 		// Handle the rate & thread limiting
 		threadlimiter <- true
 		// Ratelimiter handles the rate ticker
 		<-j.Rate.RateLimiter.C
-		nextInput := j.Input.Value()
-		nextPosition := j.Input.Position()
-		// Add FFUFHASH and its value
+		// Take the next input only once a thread is free, for the inputs requeued by the running tasks to come first
+		j.checkpointMutex.Lock()
+		nextInput, nextPosition, origin, decision, ok := j.nextInput()
+		if !ok {
+			j.checkpointMutex.Unlock()
+			<-threadlimiter
+			tasks.Wait()
+			j.checkpointMutex.Lock()
+			nextInput, nextPosition, origin, decision, ok = j.nextInput()
+			if !ok && j.startFinalPass() {
+				nextInput, nextPosition, origin, decision, ok = j.nextInput()
+			}
+			if !ok {
+				j.checkpointMutex.Unlock()
+				break
+			}
+			threadlimiter <- true
+		}
+		if !j.spendInputBudget(origin, decision) {
+			j.checkpointMutex.Unlock()
+			<-threadlimiter
+			defer j.Output.Warning(j.Error)
+			break
+		}
+		inflight := j.trackInflight(nextInput, nextPosition, origin)
+		j.checkpointMutex.Unlock()
+		// Add FFUFHASH and its value to a copy of the input, the input provider and the checkpoint keeping the map
+		// they returned
+		nextInput = copyInput(nextInput)
 		nextInput["FFUFHASH"] = j.ffufHash(nextPosition)
 
 		wg.Add(1)
-		j.Counter++
+		tasks.Add(1)
+		j.incCounter()
 
 		go func() {
 			defer func() { <-threadlimiter }()
 			defer wg.Done()
+			defer tasks.Done()
+			defer j.untrackInflight(inflight)
 			threadStart := time.Now()
-			j.runTask(nextInput, nextPosition, false)
+			j.runTask(nextInput, nextPosition, origin, decision)
 			j.sleepIfNeeded()
 			threadEnd := time.Now()
 			j.Rate.Tick(threadStart, threadEnd)
@@ -295,6 +685,7 @@ func (j *Job) startExecution() {
 			return
 		}
 	}
+	close(done)
 	wg.Wait()
 	j.updateProgress()
 }
@@ -315,34 +706,107 @@ func (j *Job) interruptMonitor() {
 	}()
 }
 
-func (j *Job) runBackgroundTasks(wg *sync.WaitGroup) {
+// ReloadMarkovChain merges a saved chain file into the live Markov chain, logging the amount of learning added
+func (j *Job) ReloadMarkovChain(filename string) {
+	if j.MarkovChain == nil {
+		j.Output.Error("The Markov chain is not enabled (-markov)")
+		return
+	}
+	states, transitions, err := j.MarkovChain.ReloadChain(filename)
+	if err != nil {
+		j.Output.Error(fmt.Sprintf("Could not reload the Markov chain: %s", err))
+		return
+	}
+	j.Output.Info(fmt.Sprintf("Markov chain reloaded from %s: %d new states, %d transitions added", filename, states, transitions))
+}
+
+// ExplainMarkovRanking logs how the Markov chain scores a FUZZ token when ranking the inputs
+func (j *Job) ExplainMarkovRanking(token string) {
+	if j.MarkovChain == nil {
+		j.Output.Error("The Markov chain is not enabled (-markov)")
+		return
+	}
+	j.Output.Info(fmt.Sprintf("Markov ranking of %s", j.MarkovChain.ExplainRanking(token)))
+	for _, t := range j.MarkovChain.RecentTransitionsOf(token, 5) {
+		j.Output.Info(fmt.Sprintf("Learned from request %s", t))
+	}
+}
+
+// SetMarkovParam changes a learning parameter or a reward weight of the Markov chain while the job runs. The change
+// is logged, and written to the JSON reports at the end of the run.
+func (j *Job) SetMarkovParam(name string, value float64) {
+	if j.MarkovChain == nil {
+		j.Output.Error("The Markov chain is not enabled (-markov)")
+		return
+	}
+	change, err := j.MarkovChain.SetParam(name, value)
+	if err != nil {
+		j.Output.Error(fmt.Sprintf("Could not set the Markov parameter: %s", err))
+		return
+	}
+	j.Output.Info(fmt.Sprintf("Markov parameter %s", change))
+	log.Printf("Markov parameter %s", change)
+}
+
+// MarkovParamChanges returns the Markov parameters changed during the run with SetMarkovParam, oldest first
+func (j *Job) MarkovParamChanges() []ParamChange {
+	changes := make([]ParamChange, 0)
+	if j.MarkovChain == nil {
+		return changes
+	}
+	for _, c := range j.MarkovChain.ParamChanges() {
+		changes = append(changes, ParamChange{Time: c.Time.Format(time.RFC3339), Name: c.Name, Old: c.Old, New: c.New})
+	}
+	return changes
+}
+
+func (j *Job) runBackgroundTasks(wg *sync.WaitGroup, done chan struct{}) {
 	defer wg.Done()
-	totalProgress := j.Input.Total()
-	for j.Counter <= totalProgress && !j.skipQueue {
+	for j.requestCount() <= j.inputSource().Total() && !j.skipQueue {
 		j.pauseWg.Wait()
 		if !j.Running {
 			break
 		}
 		j.updateProgress()
-		if j.Counter == totalProgress {
+		if j.requestCount() == j.inputSource().Total() {
 			return
 		}
 		if !j.RunningJob {
 			return
 		}
-		time.Sleep(time.Millisecond * time.Duration(j.Config.ProgressFrequency))
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Millisecond * time.Duration(j.Config.ProgressFrequency)):
+		}
 	}
 }
 
 func (j *Job) updateProgress() {
+	counter := j.requestCount()
+	j.queueMutex.Lock()
+	queuePos, queueTotal := j.queuepos, len(j.queuejobs)
+	j.queueMutex.Unlock()
 	prog := Progress{
 		StartedAt:  j.startTimeJob,
-		ReqCount:   j.Counter,
-		ReqTotal:   j.Input.Total(),
+		ReqCount:   counter,
+		ReqTotal:   j.inputSource().Total(),
 		ReqSec:     j.Rate.CurrentRate(),
-		QueuePos:   j.queuepos,
-		QueueTotal: len(j.queuejobs),
+		QueuePos:   queuePos,
+		QueueTotal: queueTotal,
 		ErrorCount: j.ErrorCounter,
+		Duplicates: atomic.LoadInt64(&j.metrics.duplicates),
+		Stall:      j.discovery.rates().StallScore,
+	}
+	if j.inFinalPass() {
+		prog.FinalPass = counter - j.finalPassStart
+		prog.FinalPassTotal = j.finalPassTotal
+	}
+	if fp, ok := j.Input.(FeedbackProvider); ok {
+		prog.Allocation = fp.Allocation()
+	}
+	if j.MarkovChain != nil {
+		prog.Phase = j.MarkovChain.Phase()
 	}
 	j.Output.Progress(prog)
 }
@@ -356,6 +820,16 @@ func (j *Job) isMatch(resp Response) bool {
 	} else {
 		filters = j.Config.MatcherManager.GetFilters()
 	}
+	if scoped := j.Config.MatcherManager.FiltersForRoot(j.recursionRoot); len(scoped) > 0 {
+		merged := make(map[string]FilterProvider, len(filters)+len(scoped))
+		for name, f := range filters {
+			merged[name] = f
+		}
+		for name, f := range scoped {
+			merged[name] = f
+		}
+		filters = merged
+	}
 	matchers = j.Config.MatcherManager.GetMatchers()
 	for _, m := range matchers {
 		match, err := m.Filter(&resp)
@@ -409,22 +883,50 @@ func (j *Job) ffufHash(pos int) []byte {
 	return []byte(hashstring)
 }
 
-func (j *Job) runTask(input map[string][]byte, position int, retried bool) {
+func (j *Job) runTask(input map[string][]byte, position int, origin string, decision string) {
+	matched := false
+	if origin == "" {
+		// Requeued inputs are not the input provider's to learn from
+		defer func() { j.inputFeedback(input, matched) }()
+	}
 	basereq := j.queuejobs[j.queuepos-1].req
 	req, err := j.Runner.Prepare(input, &basereq)
 	req.Timestamp = time.Now()
 
 	req.Position = position
+	req.Origin = origin
+	req.Decision = decision
+	if method := probedMethod(origin); method != "" {
+		req.Method = method
+	}
 	if err != nil {
 		j.Output.Error(fmt.Sprintf("Encountered an error while preparing request: %s\n", err))
 		j.incError()
 		log.Printf("%s", err)
 		return
 	}
+	preset := -1
+	if j.headerPool != nil && req.Headers != nil {
+		preset = j.headerPool.Choose()
+		for k, v := range j.Config.HeaderPool[preset].Headers {
+			req.Headers[k] = v
+		}
+		req.Preset = j.Config.HeaderPool[preset].Name
+	}
+	if ua, ok := j.userAgent.Load().(string); ok && req.Headers != nil {
+		req.Headers["User-Agent"] = ua
+	}
+	j.applyCookieJar(&req)
+	if j.budget != nil {
+		// The request was spent on the budget when its input was drawn, its retries are spent here
+		req.AllowRetry = j.budget.allowRetry
+	}
 
 	resp, err := j.Runner.Execute(&req)
+	j.metrics.incRequests()
 	if err != nil {
 		req.Error = err.Error()
+		req.ErrorClass = ClassifyError(err)
 	}
 
 	// Audit the request after sending to the runner so we get any changes
@@ -436,11 +938,18 @@ func (j *Job) runTask(input map[string][]byte, position int, retried bool) {
 	}
 
 	if err != nil {
-		if retried {
-			j.incError()
-			log.Printf("%s", err)
-		} else {
-			j.runTask(input, position, true)
+		// Transport errors have already been retried by the runner
+		j.incError()
+		j.metrics.incErrors(req.ErrorClass)
+		log.Printf("%s", err)
+		var handshakeErr *TLSHandshakeError
+		if errors.As(err, &handshakeErr) {
+			j.handshakeWarning.Do(func() { j.Output.Warning(err.Error()) })
+		}
+		if j.MarkovChain != nil && !errors.Is(err, context.Canceled) {
+			// Feed the failure to the chain as a terminal state, these never reach the matchers or filters
+			reward := j.MarkovChain.UpdateWithResponse(input, &markov.Response{Error: req.ErrorClass.markovState(), Position: position})
+			j.creditSeeds(input, reward)
 		}
 		if os.IsTimeout(err) {
 			for name := range j.Config.MatcherManager.GetMatchers() {
@@ -475,6 +984,13 @@ func (j *Job) runTask(input map[string][]byte, position int, retried bool) {
 		}
 	}
 
+	j.metrics.incResponses(resp.StatusCode)
+	if resp.StatusCode == 429 {
+		j.honorRateLimit(resp)
+	}
+	if preset >= 0 {
+		j.headerPool.Feedback(preset, resp.StatusCode)
+	}
 	if j.SpuriousErrorCounter > 0 {
 		j.resetSpuriousErrors()
 	}
@@ -490,30 +1006,31 @@ func (j *Job) runTask(input map[string][]byte, position int, retried bool) {
 			j.inc429()
 		}
 	}
-	
+
+	// Look for the fuzzed values in the response, for the reflection matcher and the Markov reward
+	resp.Reflected = resp.Reflects(input)
+
 	// Update Markov chain with the response if enabled
 	if j.MarkovChain != nil {
-		// Convert ffuf.Response to markov.Response
-		markovResp := &markov.Response{
-			StatusCode:    resp.StatusCode,
-			Headers:       resp.Headers,
-			Data:          resp.Data,
-			ContentLength: resp.ContentLength,
-			ContentWords:  resp.ContentWords,
-			ContentLines:  resp.ContentLines,
-			ContentType:   resp.ContentType,
-			Cancelled:     resp.Cancelled,
-			Request:       resp.Request,
-			Raw:           resp.Raw,
-			ResultFile:    resp.ResultFile,
-			ScraperData:   resp.ScraperData,
-			Duration:      resp.Duration,
-			Timestamp:     resp.Timestamp,
-		}
-		j.MarkovChain.UpdateWithResponse(input, markovResp)
+		resp.Reward = j.MarkovChain.UpdateWithResponse(input, j.markovResponse(resp))
+		j.creditSeeds(input, resp.Reward)
+		j.handlePrefixSuggestions()
+		if phase := j.MarkovChain.NewPhase(); phase != "" {
+			j.Output.Info(fmt.Sprintf("Markov chain in the %s phase of -markov-phases %s", phase, j.Config.MarkovPhases))
+		}
+		if blocked, backoff := j.MarkovChain.DetectBlocking(); blocked {
+			j.cooldown(backoff)
+		}
+		if origin == caseProbeOrigin {
+			j.checkCaseProbe(input, resp)
+		}
+		if probedMethod(origin) == "" && !j.inFinalPass() {
+			j.requeueMethodProbes(input, resp)
+		}
 	}
 	
 	j.pauseWg.Wait()
+	j.rateLimit.wait(j.Config.Context)
 
 	// Handle autocalibration, must be done after the actual request to ensure sane value in req.Host
 	_ = j.CalibrateIfNeeded(HostURLFromRequest(req), input)
@@ -527,19 +1044,35 @@ func (j *Job) runTask(input map[string][]byte, position int, retried bool) {
 	}
 
 	if j.isMatch(resp) {
+		matched = true
+		j.metrics.incMatches()
 		// Re-send request through replay-proxy if needed
 		if j.ReplayRunner != nil {
 			replayreq, err := j.ReplayRunner.Prepare(input, &basereq)
 			replayreq.Position = position
+			if method := probedMethod(origin); method != "" {
+				replayreq.Method = method
+			}
 			if err != nil {
 				j.Output.Error(fmt.Sprintf("Encountered an error while preparing replayproxy request: %s\n", err))
 				j.incError()
 				log.Printf("%s", err)
-			} else {
+			} else if j.spendBudget(&replayreq, budgetReplay) {
 				_, _ = j.ReplayRunner.Execute(&replayreq)
 			}
 		}
 		j.Output.Result(resp)
+		j.storeCookies(resp)
+		if j.MarkovChain != nil && (j.Config.MarkovPatternMax > 0 || j.Config.MarkovFinalPass > 0) {
+			j.requeuePatterns(input, resp.Reward)
+		}
+		if j.MarkovChain != nil && j.Config.MarkovNeighbors > 0 && !j.inFinalPass() {
+			j.requeueNeighbors(input)
+		}
+		if j.MarkovChain != nil && !j.inFinalPass() {
+			j.requeueDirProbes(input, resp)
+			j.requeueCaseProbe(input, resp)
+		}
 
 		// Refresh the progress indicator as we printed something out
 		j.updateProgress()
@@ -552,12 +1085,75 @@ func (j *Job) runTask(input map[string][]byte, position int, retried bool) {
 			j.Output.Result(resp)
 		}
 	}
+	j.discovery.record(matched, resp.BodyHash)
+	if j.catchAll.record(&resp, matched) {
+		j.Output.Warning(catchAllWarning(j.catchAll.Evidence()))
+	}
+	j.techFingerprint.record(&resp, input, matched)
+	j.applyStackFilter()
 
 	if j.Config.Recursion && j.Config.RecursionStrategy == "default" && len(resp.GetRedirectLocation(false)) > 0 {
 		j.handleDefaultRecursionJob(resp)
 	}
 }
 
+// markovResponse converts a response to the response the Markov chain learns from
+func (j *Job) markovResponse(resp Response) *markov.Response {
+	mresp := &markov.Response{
+		StatusCode:    resp.StatusCode,
+		Headers:       resp.Headers,
+		Data:          resp.Data,
+		ContentLength: resp.ContentLength,
+		ContentWords:  resp.ContentWords,
+		ContentLines:  resp.ContentLines,
+		ContentType:   resp.ContentType,
+		Cancelled:     resp.Cancelled,
+		Request:       resp.Request,
+		Raw:           resp.Raw,
+		ResultFile:    resp.ResultFile,
+		ScraperData:   resp.ScraperData,
+		Proto:         resp.Proto,
+		CertMismatch:  resp.CertMismatch(),
+		CertHash:      resp.CertHash,
+		BodyHash:      resp.BodyHash,
+		Incomplete:    !resp.Complete,
+		Path:          HostURLFromRequest(*resp.Request),
+		URL:           resp.Request.Url,
+		JSONField:     j.fuzzesJSONField(),
+		Elapsed:       resp.Duration,
+		Position:      resp.Request.Position,
+		Reflected:     resp.Reflected,
+	}
+	if len(resp.Redirects) > 0 {
+		mresp.RedirectStatus = resp.Redirects[0].StatusCode
+		mresp.Redirects = len(resp.Redirects)
+		mresp.InternalRedirects = resp.InternalRedirects()
+	}
+	return mresp
+}
+
+// copyInput returns a copy of an input map, the values being shared
+func copyInput(input map[string][]byte) map[string][]byte {
+	c := make(map[string][]byte, len(input)+1)
+	for k, v := range input {
+		c[k] = v
+	}
+	return c
+}
+
+// inputFeedback lets the input provider know whether the request sent with the input was a match
+func (j *Job) inputFeedback(input map[string][]byte, matched bool) {
+	fp, ok := j.Input.(FeedbackProvider)
+	if !ok {
+		return
+	}
+	if matched {
+		fp.Feedback(input, 1)
+	} else {
+		fp.Feedback(input, 0)
+	}
+}
+
 func (j *Job) handleScraperResult(resp *Response, sres ScraperResult) {
 	for _, a := range sres.Action {
 		switch a {
@@ -573,7 +1169,7 @@ func (j *Job) handleGreedyRecursionJob(resp Response) {
 	if j.Config.RecursionDepth == 0 || j.currentDepth < j.Config.RecursionDepth {
 		recUrl := resp.Request.Url + "/" + "FUZZ"
 		newJob := QueueJob{Url: recUrl, depth: j.currentDepth + 1, req: RecursionRequest(j.Config, recUrl)}
-		j.queuejobs = append(j.queuejobs, newJob)
+		j.enqueue(newJob)
 		j.Output.Info(fmt.Sprintf("Adding a new job to the queue: %s", recUrl))
 	} else {
 		j.Output.Warning(fmt.Sprintf("Maximum recursion depth reached. Ignoring: %s", resp.Request.Url))
@@ -591,7 +1187,7 @@ func (j *Job) handleDefaultRecursionJob(resp Response) {
 	if j.Config.RecursionDepth == 0 || j.currentDepth < j.Config.RecursionDepth {
 		// We have yet to reach the maximum recursion depth
 		newJob := QueueJob{Url: recUrl, depth: j.currentDepth + 1, req: RecursionRequest(j.Config, recUrl)}
-		j.queuejobs = append(j.queuejobs, newJob)
+		j.enqueue(newJob)
 		j.Output.Info(fmt.Sprintf("Adding a new job to the queue: %s", recUrl))
 	} else {
 		j.Output.Warning(fmt.Sprintf("Directory found, but recursion depth exceeded. Ignoring: %s", resp.GetRedirectLocation(true)))
@@ -600,10 +1196,10 @@ func (j *Job) handleDefaultRecursionJob(resp Response) {
 
 // CheckStop stops the job if stopping conditions are met
 func (j *Job) CheckStop() {
-	if j.Counter > 50 {
+	if counter := j.requestCount(); counter > 50 {
 		// We have enough samples
 		if j.Config.StopOn403 || j.Config.StopOnAll {
-			if float64(j.Count403)/float64(j.Counter) > 0.95 {
+			if float64(j.Count403)/float64(counter) > 0.95 {
 				// Over 95% of requests are 403
 				j.Error = "Getting an unusual amount of 403 responses, exiting."
 				j.Stop()
@@ -617,7 +1213,7 @@ func (j *Job) CheckStop() {
 			}
 
 		}
-		if j.Config.StopOnAll && (float64(j.Count429)/float64(j.Counter) > 0.2) {
+		if j.Config.StopOnAll && (float64(j.Count429)/float64(counter) > 0.2) {
 			// Over 20% of responses are 429
 			j.Error = "Getting an unusual amount of 429 responses, exiting."
 			j.Stop()
@@ -0,0 +1,120 @@
+package ffuf
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+// newErrorJob returns a job failing every request with the error, its tasks being run by hand
+func newErrorJob(err error) (*Job, *recordingOutput) {
+	conf := NewConfig(context.Background(), func() {})
+	conf.MatcherManager = &fakeMatcherManager{}
+	j := NewJob(&conf)
+	out := &recordingOutput{}
+	j.Output = out
+	j.Runner = newFakeRunner(func(req *Request, resp *Response) error { return err })
+	j.queuejobs = append(j.queuejobs, QueueJob{req: BaseRequest(&conf)})
+	j.queuepos = 1
+	baseline := markov.State{CodeClass: "4xx", SizeBucket: markov.QuantizeSize(139)}
+	j.MarkovChain = markov.NewMarkovInputProvider(nil, baseline, markov.GetSizeHash([]byte("404 not found")), 0)
+	j.MarkovChain.SetErrorRewards(0.2, 0)
+	return j, out
+}
+
+func TestRunTaskErrorStates(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		state string
+	}{
+		{"timeout", &url.Error{Op: "Get", URL: "http://localhost/", Err: context.DeadlineExceeded}, "timeout_0_0"},
+		{"conn-error", &url.Error{Op: "Get", URL: "http://localhost/", Err: errors.New("connection reset by peer")}, "conn-error_0_0"},
+		{"tls-handshake", &TLSHandshakeError{Err: &url.Error{Op: "Get", URL: "https://localhost/", Err: errors.New("remote error: tls: certificate required")}}, "conn-error-tls-handshake_0_0"},
+	}
+	for _, test := range tests {
+		j, out := newErrorJob(test.err)
+		j.runTask(map[string][]byte{"FUZZ": []byte("foo")}, 1, "", "")
+
+		counts := j.MarkovChain.MarkovChain.TransitionCounts["4xx_100_0"]["foo"]
+		if len(counts) != 1 || counts[test.state] != 1 {
+			t.Errorf("%s: expected a transition to %s, got %v", test.name, test.state, counts)
+		}
+		if len(out.responses) != 0 {
+			t.Errorf("%s: failed requests should never be shown as results", test.name)
+		}
+		if j.ErrorCounter != 1 {
+			t.Errorf("%s: expected the error to be counted, got %d", test.name, j.ErrorCounter)
+		}
+	}
+}
+
+func TestRunTaskCancelledNotLearned(t *testing.T) {
+	j, _ := newErrorJob(&url.Error{Op: "Get", URL: "http://localhost/", Err: context.Canceled})
+	j.runTask(map[string][]byte{"FUZZ": []byte("foo")}, 1, "", "")
+	if len(j.MarkovChain.MarkovChain.TransitionCounts) != 0 {
+		t.Errorf("Cancelled requests should not be fed to the chain, got %v", j.MarkovChain.MarkovChain.TransitionCounts)
+	}
+}
+
+func TestRunTaskHeaderPool(t *testing.T) {
+	j, _ := newErrorJob(nil)
+	j.Config.Url = "http://localhost/FUZZ"
+	j.Config.HeaderPool = []HeaderPreset{
+		{Name: "curl", Headers: map[string]string{"User-Agent": "curl/8.0"}},
+		{Name: "firefox", Headers: map[string]string{"User-Agent": "Mozilla/5.0 Firefox/128.0"}},
+	}
+	j.queuejobs = []QueueJob{{req: BaseRequest(j.Config)}}
+	j.headerPool = markov.NewHeaderPool([]string{"curl", "firefox"}, []int64{403})
+	// The target blocks curl
+	runner := newFakeRunner(func(req *Request, resp *Response) error {
+		if req.Headers["User-Agent"] == "curl/8.0" {
+			resp.StatusCode = 403
+		}
+		return nil
+	})
+	j.Runner = runner
+	for i := 0; i < 100; i++ {
+		j.runTask(map[string][]byte{"FUZZ": []byte("foo")}, i+1, "", "")
+	}
+
+	firefox := 0
+	for i, req := range runner.sent() {
+		if (req.Preset == "curl") != (req.Headers["User-Agent"] == "curl/8.0") {
+			t.Fatalf("Request %d was recorded with the preset %s but sent with %s", i, req.Preset, req.Headers["User-Agent"])
+		}
+		if req.Preset == "firefox" {
+			firefox++
+		}
+	}
+	if firefox < 80 {
+		t.Errorf("Expected the requests to favor the preset that is not blocked, got %d of 100", firefox)
+	}
+	for state, actions := range j.MarkovChain.MarkovChain.TransitionCounts {
+		for _, next := range actions {
+			for s := range next {
+				if strings.Contains(state+s, "curl") || strings.Contains(state+s, "firefox") {
+					t.Errorf("The header preset should not be part of the chain states, got %s -> %s", state, s)
+				}
+			}
+		}
+	}
+}
+
+func TestMarkovInputsNotMutated(t *testing.T) {
+	j := newFakeJob(t, newFakeRunner(nil), []string{"admin", "login", "backup"}, nil)
+	j.Start()
+
+	// The job adds FFUFHASH to its own copy of the input returned by the provider
+	previous := j.MarkovChain.PreviousInputs()
+	if len(previous) == 0 {
+		t.Fatalf("Expected the last input of the provider kept")
+	}
+	if _, ok := previous["FFUFHASH"]; ok || len(previous) != 1 {
+		t.Errorf("Expected the inputs of the provider unchanged by the job, got %v", previous)
+	}
+}
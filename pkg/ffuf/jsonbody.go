@@ -0,0 +1,127 @@
+package ffuf
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+const (
+	// jsonKeywordMarker replaces the keyword occurrence located in a JSON body, written as JSON escapes for the body
+	// to stay valid JSON whether the keyword is inside a string or not
+	jsonKeywordMarker = `\u0000ffuf\u0000`
+	// jsonKeywordDecoded is jsonKeywordMarker once decoded
+	jsonKeywordDecoded = "\x00ffuf\x00"
+)
+
+// jsonKeywordPointer returns the JSON pointer of the field of a JSON body the first occurrence of the keyword sits in
+// the value of, and whether the keyword is the raw value of the field rather than inside a string, like
+// {"id": FUZZ}. Returns false if the body is not JSON, or the keyword is not in a field value.
+func jsonKeywordPointer(keyword string, body string) (string, bool, bool) {
+	trimmed := strings.TrimSpace(body)
+	if len(keyword) == 0 || (!strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[")) {
+		return "", false, false
+	}
+	// Replace the keyword occurrences by valid JSON, telling the first one apart, and tracking whether each of them
+	// is inside a string
+	var b strings.Builder
+	inString := false
+	escaped := false
+	found := false
+	raw := false
+	for i := 0; i < len(trimmed); {
+		if strings.HasPrefix(trimmed[i:], keyword) {
+			switch {
+			case !found && inString:
+				b.WriteString(jsonKeywordMarker)
+			case !found:
+				b.WriteString(`"` + jsonKeywordMarker + `"`)
+				raw = true
+			case inString:
+				b.WriteString(keyword)
+			default:
+				b.WriteString("null")
+			}
+			found = true
+			i += len(keyword)
+			escaped = false
+			continue
+		}
+		c := trimmed[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && inString:
+			escaped = true
+		case c == '"':
+			inString = !inString
+		}
+		b.WriteByte(c)
+		i++
+	}
+	if !found {
+		return "", false, false
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(b.String())))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return "", false, false
+	}
+	pointer, ok := jsonFindMarker(doc, "")
+	return pointer, raw, ok
+}
+
+// jsonFindMarker returns the JSON pointer of the string value containing the keyword marker within the value
+func jsonFindMarker(value interface{}, pointer string) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return pointer, strings.Contains(v, jsonKeywordDecoded)
+	case []interface{}:
+		for idx, item := range v {
+			if p, ok := jsonFindMarker(item, pointer+"/"+strconv.Itoa(idx)); ok {
+				return p, true
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			escapedKey := strings.ReplaceAll(strings.ReplaceAll(k, "~", "~0"), "/", "~1")
+			if p, ok := jsonFindMarker(v[k], pointer+"/"+escapedKey); ok {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+// fuzzesJSONField returns true if FUZZ sits in a field value of the JSON body of the raw request
+func (j *Job) fuzzesJSONField() bool {
+	return strings.HasPrefix(j.Config.KeywordLocations["FUZZ"], "body:")
+}
+
+// requeueJSONValues queues the typed JSON values of the Markov chain ahead of the wordlist when FUZZ is the raw
+// value of a field of the JSON body, like {"id": FUZZ}, for the chain to learn how the field takes the numbers,
+// booleans and null that a wordlist of strings does not have
+func (j *Job) requeueJSONValues() {
+	if j.MarkovChain == nil || !j.fuzzesJSONField() {
+		return
+	}
+	if keywords := j.Input.Keywords(); len(keywords) != 1 || keywords[0] != "FUZZ" {
+		return
+	}
+	if _, raw, ok := jsonKeywordPointer("FUZZ", j.Config.Data); !ok || !raw {
+		return
+	}
+	for _, value := range markov.JSONValueCandidates {
+		j.requeue(map[string][]byte{"FUZZ": []byte(value)}, "json-value")
+	}
+}
@@ -0,0 +1,74 @@
+package ffuf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+func TestJSONKeywordPointer(t *testing.T) {
+	tests := []struct {
+		body    string
+		pointer string
+		raw     bool
+		found   bool
+	}{
+		{`{"user": {"name": "FUZZ", "id": 1}}`, "/user/name", false, true},
+		{`{"user": {"name": "admin-FUZZ"}}`, "/user/name", false, true},
+		{`{"id": FUZZ, "active": true}`, "/id", true, true},
+		{`  {"items": [1, {"qty": FUZZ}]}`, "/items/1/qty", true, true},
+		{`{"a/b": {"c~d": "FUZZ"}}`, "/a~1b/c~0d", false, true},
+		{`{"note": "escaped \" FUZZ"}`, "/note", false, true},
+		{`{"first": "FUZZ", "second": FUZZ}`, "/first", false, true},
+		{`{"first": FUZZ, "second": "FUZZ"}`, "/first", true, true},
+		{`["FUZZ"]`, "/0", false, true},
+		{`{"FUZZ": 1}`, "", false, false},
+		{`{"id": 12FUZZ}`, "", false, false},
+		{`id=FUZZ&name=admin`, "", false, false},
+		{`{"id": 1}`, "", false, false},
+	}
+	for _, test := range tests {
+		pointer, raw, found := jsonKeywordPointer("FUZZ", test.body)
+		if pointer != test.pointer || raw != test.raw || found != test.found {
+			t.Errorf("%s: expected (%q, %t, %t), got (%q, %t, %t)", test.body, test.pointer, test.raw, test.found, pointer, raw, found)
+		}
+	}
+}
+
+func TestKeywordLocationJSONBody(t *testing.T) {
+	conf := &Config{Headers: map[string]string{"Content-Type": "application/json"}, Data: `{"user": {"id": FUZZ}}`}
+	if loc := keywordLocation("FUZZ", "/api/users", conf); loc != "body:/user/id" {
+		t.Errorf("Expected the JSON pointer of the field as location, got %s", loc)
+	}
+	conf.Data = "id=FUZZ"
+	if loc := keywordLocation("FUZZ", "/api/users", conf); loc != "body" {
+		t.Errorf("Expected the body location for a form body, got %s", loc)
+	}
+}
+
+func TestRequeueJSONValues(t *testing.T) {
+	for _, test := range []struct {
+		data     string
+		expected int
+	}{
+		{`{"id": FUZZ}`, len(markov.JSONValueCandidates)},
+		// A string field only gets the wordlist
+		{`{"id": "FUZZ"}`, 0},
+	} {
+		conf := NewConfig(context.Background(), func() {})
+		conf.Markov = true
+		conf.Data = test.data
+		conf.KeywordLocations["FUZZ"] = "body:/id"
+		j := NewJob(&conf)
+		j.Input = &sliceInput{words: []string{"admin"}}
+		j.MarkovChain = markov.NewMarkovInputProvider(j.Input, markov.State{CodeClass: "4xx"}, "", 0)
+		j.requeueJSONValues()
+		pending := j.PendingInputs(100)
+		if len(pending) != test.expected {
+			t.Errorf("%s: expected %d pending inputs, got %d", test.data, test.expected, len(pending))
+		} else if len(pending) > 0 && (string(pending[0].Input["FUZZ"]) != "0" || pending[0].Priority != "json-value") {
+			t.Errorf("%s: expected the typed JSON values to be queued, got %v", test.data, pending[0])
+		}
+	}
+}
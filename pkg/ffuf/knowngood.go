@@ -0,0 +1,87 @@
+package ffuf
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// seedKnownGood requests the paths of the -markov-known-good file on the target, and teaches the Markov chain their
+// responses as successes before the wordlist is fuzzed. The paths answering with a 404 or like the baseline are
+// reported and left out. A dry run biases the chain towards the paths without requesting them.
+func (j *Job) seedKnownGood() {
+	u, err := url.Parse(j.Config.Url)
+	if err != nil || u.Host == "" || strings.Contains(u.Host, "FUZZ") {
+		j.Output.Warning("Cannot pre-train the Markov chain with the known good paths, the host is fuzzed or not valid")
+		return
+	}
+	root := u.Scheme + "://" + u.Host
+	paths, err := readKnownGood(j.Config.MarkovKnownGood)
+	if err != nil {
+		j.Output.Warning(fmt.Sprintf("Could not read the known good paths: %s", err))
+		return
+	}
+
+	if j.Config.MarkovDryRun > 0 {
+		for _, p := range paths {
+			j.MarkovChain.BiasKnownGood(root + p)
+		}
+		if !j.Config.Quiet {
+			j.Output.Info(fmt.Sprintf("Markov chain biased towards the %d known good paths from %s, not requested in a dry run", len(paths), j.Config.MarkovKnownGood))
+		}
+		return
+	}
+
+	basereq := BaseRequest(j.Config)
+	learned := 0
+	for _, p := range paths {
+		req := CopyRequest(&basereq)
+		req.Method = "GET"
+		req.Url = root + p
+		req.Data = []byte{}
+		if !j.spendBudget(&req, budgetSeeding) {
+			break
+		}
+		resp, err := j.Runner.Execute(&req)
+		if err != nil {
+			j.Output.Warning(fmt.Sprintf("Known good path %s failed, left out of the Markov chain: %s", p, err))
+			continue
+		}
+		if _, ok := j.MarkovChain.AddKnownGood(req.Url, j.markovResponse(resp)); !ok {
+			j.Output.Warning(fmt.Sprintf("Known good path %s answered with %d like a missing path, left out of the Markov chain", p, resp.StatusCode))
+			continue
+		}
+		learned++
+	}
+	if !j.Config.Quiet {
+		j.Output.Info(fmt.Sprintf("Markov chain pre-trained with %d of the %d known good paths from %s", learned, len(paths), j.Config.MarkovKnownGood))
+	}
+}
+
+// readKnownGood reads the paths known to exist on the target, one per line, as paths or full URLs of which the path
+// and query are kept. Empty lines and comments are skipped.
+func readKnownGood(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	paths := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if u, err := url.Parse(line); err == nil && u.Host != "" {
+			line = u.RequestURI()
+		}
+		if !strings.HasPrefix(line, "/") {
+			line = "/" + line
+		}
+		paths = append(paths, line)
+	}
+	return paths, scanner.Err()
+}
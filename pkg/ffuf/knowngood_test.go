@@ -0,0 +1,59 @@
+package ffuf
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+func TestSeedKnownGood(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin":
+			fmt.Fprint(w, strings.Repeat("<p>admin panel</p>", 100))
+		case "/api/v1":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"version":1}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	known := filepath.Join(t.TempDir(), "known.txt")
+	if err := os.WriteFile(known, []byte("# from the last scan\n/admin\n\n"+ts.URL+"/api/v1\nremoved-page\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	j, runner := newSeedJob(ts.URL + "/FUZZ")
+	out := &logOutput{}
+	j.Output = out
+	j.Config.MarkovKnownGood = known
+	j.Config.Quiet = false
+	j.seedKnownGood()
+
+	if requested := runner.urls(); len(requested) != 3 || requested[1] != ts.URL+"/api/v1" || requested[2] != ts.URL+"/removed-page" {
+		t.Errorf("Expected each known path to be requested once, got %v", requested)
+	}
+	state := markov.State{CodeClass: "4xx", SizeBucket: markov.QuantizeSize(139)}
+	for _, token := range []string{"admin", "v1"} {
+		if q := j.MarkovChain.MarkovChain.GetExpectedReward(state, token); q <= 0 {
+			t.Errorf("Expected %s to be learned as a success, got a Q-value of %f", token, q)
+		}
+	}
+	if q := j.MarkovChain.MarkovChain.GetExpectedReward(state, "removed-page"); q != 0 {
+		t.Errorf("Expected the missing path to be left out of the chain, got a Q-value of %f", q)
+	}
+	matched := j.MarkovChain.MatchedTokens()
+	if len(matched) != 2 || !StrInSlice("admin", matched) || !StrInSlice("v1", matched) {
+		t.Errorf("Expected the known paths to be recorded as matches, got %v", matched)
+	}
+	if len(out.messages) != 2 || !strings.Contains(out.messages[0], "/removed-page answered with 404") || !strings.Contains(out.messages[1], "2 of the 3 known good paths") {
+		t.Errorf("Expected the missing path to be reported, got %v", out.messages)
+	}
+}
@@ -0,0 +1,108 @@
+package ffuf
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+const (
+	// markovSeedBias is the initial Q-value of the paths found in robots.txt and sitemaps
+	markovSeedBias = 1.0
+	// markovDisallowBias is the initial Q-value of the paths disallowed in robots.txt, as they are often sensitive
+	markovDisallowBias = 1.5
+	// markovMaxSitemaps limits the number of sitemaps fetched when following sitemap index files
+	markovMaxSitemaps = 10
+)
+
+// seedFromTarget fetches robots.txt and sitemap.xml from the target and seeds the Markov chain with the path segments
+// found in them. Directories are added to the job queue when recursion is enabled. Missing files are silently ignored.
+func (j *Job) seedFromTarget() {
+	u, err := url.Parse(j.Config.Url)
+	if err != nil || u.Host == "" || strings.Contains(u.Host, "FUZZ") {
+		j.Output.Warning("Cannot seed the Markov chain from the target, the host is fuzzed or not valid")
+		return
+	}
+	root := u.Scheme + "://" + u.Host
+
+	seeds := make([]string, 0)
+	sensitive := make([]string, 0)
+	dirs := make([]string, 0)
+	sitemaps := []string{root + "/sitemap.xml"}
+
+	if data, ok := j.fetchSeedFile(root + "/robots.txt"); ok {
+		allowed, disallowed, robotsSitemaps := markov.ParseRobots(data)
+		for _, p := range allowed {
+			seeds = append(seeds, markov.PathSegments(p)...)
+			dirs = appendSeedDir(dirs, p)
+		}
+		for _, p := range disallowed {
+			sensitive = append(sensitive, markov.PathSegments(p)...)
+			dirs = appendSeedDir(dirs, p)
+		}
+		sitemaps = append(sitemaps, robotsSitemaps...)
+	}
+
+	// Follow the sitemap index files, staying on the target host
+	fetched := make(map[string]bool)
+	for i := 0; i < len(sitemaps) && len(fetched) < markovMaxSitemaps; i++ {
+		sm, err := url.Parse(sitemaps[i])
+		if err != nil || fetched[sitemaps[i]] || (sm.Host != "" && sm.Host != u.Host) {
+			continue
+		}
+		fetched[sitemaps[i]] = true
+		data, ok := j.fetchSeedFile(root + sm.RequestURI())
+		if !ok {
+			continue
+		}
+		pages, nested, _ := markov.ParseSitemap(data)
+		for _, p := range pages {
+			seeds = append(seeds, markov.PathSegments(p)...)
+			if pu, err := url.Parse(p); err == nil {
+				dirs = appendSeedDir(dirs, pu.Path)
+			}
+		}
+		sitemaps = append(sitemaps, nested...)
+	}
+
+	count := j.MarkovChain.SeedActions(seeds, markovSeedBias)
+	count += j.MarkovChain.SeedActions(sensitive, markovDisallowBias)
+	if count > 0 && !j.Config.Quiet {
+		j.Output.Info(fmt.Sprintf("Markov chain seeded with %d paths from robots.txt and sitemaps", count))
+	}
+
+	if j.Config.Recursion {
+		for _, d := range dirs {
+			recUrl := root + d + "FUZZ"
+			j.enqueue(QueueJob{Url: recUrl, depth: 1, req: RecursionRequest(j.Config, recUrl)})
+			j.Output.Info(fmt.Sprintf("Adding a new job to the queue: %s", recUrl))
+		}
+	}
+}
+
+// fetchSeedFile requests a file from the target using the configured runner, returning its body on success
+func (j *Job) fetchSeedFile(fileUrl string) ([]byte, bool) {
+	basereq := BaseRequest(j.Config)
+	req := CopyRequest(&basereq)
+	req.Method = "GET"
+	req.Url = fileUrl
+	req.Data = []byte{}
+	if !j.spendBudget(&req, budgetSeeding) {
+		return nil, false
+	}
+	resp, err := j.Runner.Execute(&req)
+	if err != nil || resp.StatusCode != 200 {
+		return nil, false
+	}
+	return resp.Data, true
+}
+
+// appendSeedDir appends the path to the list of directories if it looks like one and is not yet in the list
+func appendSeedDir(dirs []string, p string) []string {
+	if !strings.HasPrefix(p, "/") || !strings.HasSuffix(p, "/") || p == "/" || StrInSlice(p, dirs) {
+		return dirs
+	}
+	return append(dirs, p)
+}
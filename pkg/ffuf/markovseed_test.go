@@ -0,0 +1,98 @@
+package ffuf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+// answerHTTP returns a handler sending the requests with the default http client
+func answerHTTP() fakeHandler {
+	return func(req *Request, resp *Response) error {
+		httpresp, err := http.Get(req.Url)
+		if err != nil {
+			return err
+		}
+		defer httpresp.Body.Close()
+		*resp = NewResponse(httpresp, req)
+		resp.Data, _ = io.ReadAll(httpresp.Body)
+		resp.ContentLength = int64(len(resp.Data))
+		resp.Complete = true
+		return nil
+	}
+}
+
+func newSeedJob(url string) (*Job, *fakeRunner) {
+	conf := NewConfig(context.Background(), func() {})
+	conf.Url = url
+	conf.Quiet = true
+	j := NewJob(&conf)
+	j.Output = &recordingOutput{}
+	runner := newFakeRunner(answerHTTP())
+	j.Runner = runner
+	baseline := markov.State{CodeClass: "4xx", SizeBucket: markov.QuantizeSize(139)}
+	j.MarkovChain = markov.NewMarkovInputProvider(nil, baseline, markov.GetSizeHash([]byte("404 not found")), 0)
+	return j, runner
+}
+
+func TestSeedFromTarget(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nDisallow: /secret-admin/\nAllow: /docs/\nSitemap: %s/sitemap_index.xml\n", ts.URL)
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/blog/hello-world</loc></url></urlset>`, ts.URL)
+		case "/sitemap_index.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/sitemap-products.xml</loc></sitemap><sitemap><loc>http://other.example.com/sitemap.xml</loc></sitemap></sitemapindex>`, ts.URL)
+		case "/sitemap-products.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/products/widget</loc></url></urlset>`, ts.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	j, runner := newSeedJob(ts.URL + "/FUZZ")
+	j.Config.Recursion = true
+	j.seedFromTarget()
+
+	baseline := j.MarkovChain.MarkovChain
+	state := markov.State{CodeClass: "4xx", SizeBucket: markov.QuantizeSize(139)}
+	for _, token := range []string{"docs", "blog", "hello-world", "products", "widget"} {
+		if q := baseline.GetExpectedReward(state, token); q != markovSeedBias {
+			t.Errorf("Expected %s to be seeded with %f, got %f", token, markovSeedBias, q)
+		}
+	}
+	if q := baseline.GetExpectedReward(state, "secret-admin"); q != markovDisallowBias {
+		t.Errorf("Expected the disallowed path to get the extra bias, got %f", q)
+	}
+	for _, u := range runner.urls() {
+		if u == "http://other.example.com/sitemap.xml" {
+			t.Errorf("Sitemaps on other hosts should not be fetched")
+		}
+	}
+	if len(j.queuejobs) != 2 || j.queuejobs[0].Url != ts.URL+"/docs/FUZZ" || j.queuejobs[1].Url != ts.URL+"/secret-admin/FUZZ" {
+		t.Errorf("Expected the directories to be queued, got %v", j.queuejobs)
+	}
+}
+
+func TestSeedFromTargetMissingFiles(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	j, runner := newSeedJob(ts.URL + "/FUZZ")
+	j.Config.Recursion = true
+	j.seedFromTarget()
+	if len(runner.urls()) != 2 {
+		t.Errorf("Expected robots.txt and sitemap.xml to be requested, got %v", runner.urls())
+	}
+	if len(j.MarkovChain.MarkovChain.QTable) != 0 || len(j.queuejobs) != 0 {
+		t.Errorf("Missing files should not seed anything")
+	}
+}
@@ -0,0 +1,47 @@
+package ffuf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+// startMarkovSync syncs the Markov chain through the shared chain file of -markov-sync every
+// -markov-sync-interval, in the background for a slow lock of the file to never hold the requests back. The
+// returned function stops the syncs after a last one.
+func (j *Job) startMarkovSync() func() {
+	chainSync := markov.NewChainSync(j.Config.MarkovSync, j.MarkovChain)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(j.Config.MarkovSyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				j.syncMarkovChain(chainSync)
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		j.syncMarkovChain(chainSync)
+	}
+}
+
+// syncMarkovChain runs a sync of the Markov chain, logging its outcome
+func (j *Job) syncMarkovChain(chainSync *markov.ChainSync) {
+	states, transitions, err := chainSync.Sync()
+	if err != nil {
+		j.Output.Warning(fmt.Sprintf("Could not sync the Markov chain with %s: %s", j.Config.MarkovSync, err))
+		return
+	}
+	if j.Config.Verbose {
+		j.Output.Info(fmt.Sprintf("Markov chain synced with %s: %d new states, %d transitions imported", j.Config.MarkovSync, states, transitions))
+	}
+}
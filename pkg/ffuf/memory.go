@@ -0,0 +1,108 @@
+package ffuf
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// markovMemoryInterval is the interval between the checks of the memory used against -markov-max-memory
+const markovMemoryInterval = time.Second
+
+// MemoryUsage is the approximate memory used by the structures growing with the run, in bytes
+type MemoryUsage struct {
+	SentCache int64 // hashes of the sent inputs and the bloom filter past them
+	Matched   int64 // matched inputs and the tokens generated from their patterns
+	Chain     int64 // tables of the Markov chain
+}
+
+// Total returns the memory used by all the structures
+func (m MemoryUsage) Total() int64 {
+	return m.SentCache + m.Matched + m.Chain
+}
+
+func (m MemoryUsage) String() string {
+	return fmt.Sprintf("%s, sent input cache %s, matched inputs %s, chain %s", formatBytes(m.Total()),
+		formatBytes(m.SentCache), formatBytes(m.Matched), formatBytes(m.Chain))
+}
+
+// formatBytes formats an approximate size in bytes for display
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// MemoryUsage returns the approximate memory used by the sent input cache, the matched inputs and the Markov chain
+func (j *Job) MemoryUsage() MemoryUsage {
+	usage := MemoryUsage{SentCache: j.sent.bytes()}
+	if j.MarkovChain != nil {
+		usage.Matched = j.MarkovChain.MatchedBytes()
+		usage.Chain = j.MarkovChain.MarkovChain.MemoryBytes()
+	}
+	return usage
+}
+
+// enforceMemoryLimit evicts from the least valuable structure first until the memory used is back under
+// -markov-max-memory: the bloom filter of the sent input cache, then the oldest matched inputs, then the least
+// visited states of the chain
+func (j *Job) enforceMemoryLimit() {
+	excess := j.MemoryUsage().Total() - j.Config.MarkovMaxMemory
+	if excess <= 0 {
+		return
+	}
+	if freed := j.sent.dropBloom(); freed > 0 {
+		atomic.AddInt64(&j.metrics.bloomEvictions, 1)
+		excess -= freed
+		if j.Config.Verbose {
+			j.Output.Info("Memory limit reached, dropped the bloom filter of the sent input cache")
+		}
+	}
+	if excess <= 0 || j.MarkovChain == nil {
+		return
+	}
+	if evicted, freed := j.MarkovChain.EvictMatched(excess); evicted > 0 {
+		atomic.AddInt64(&j.metrics.matchedEvictions, int64(evicted))
+		excess -= freed
+		if j.Config.Verbose {
+			j.Output.Info(fmt.Sprintf("Memory limit reached, evicted the %d oldest matched inputs", evicted))
+		}
+	}
+	if excess <= 0 {
+		return
+	}
+	if evicted, _ := j.MarkovChain.EvictColdStates(excess); evicted > 0 {
+		atomic.AddInt64(&j.metrics.stateEvictions, int64(evicted))
+		if j.Config.Verbose {
+			j.Output.Info(fmt.Sprintf("Memory limit reached, evicted %d cold states of the Markov chain", evicted))
+		}
+	}
+}
+
+// startMemoryLimit checks the memory used against -markov-max-memory periodically. The returned function stops
+// the checks.
+func (j *Job) startMemoryLimit() func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(markovMemoryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				j.enforceMemoryLimit()
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,70 @@
+package ffuf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	conf := NewConfig(context.Background(), func() {})
+	j := NewJob(&conf)
+	j.Output = &recordingOutput{}
+	j.sent = newSentCache(100, 1<<16)
+	baseline := markov.State{CodeClass: "4xx"}
+	j.MarkovChain = markov.NewMarkovInputProvider(nil, baseline, "", 0)
+	for i := 0; i < 1000; i++ {
+		j.sent.add(uint64(i) * 7919)
+	}
+	for i := 0; i < 200; i++ {
+		j.MarkovChain.RecordMatch(fmt.Sprintf("match%d", i), 1.0)
+	}
+	for s := 0; s < 100; s++ {
+		from := markov.State{CodeClass: "2xx", SizeBucket: fmt.Sprint(s)}
+		for w := 0; w < 10; w++ {
+			j.MarkovChain.MarkovChain.UpdateTransition(markov.Transition{FromState: from, Action: markov.Action{Token: fmt.Sprintf("w%d", w)}, ToState: baseline, Reward: 1.0})
+		}
+	}
+	usage := j.MemoryUsage()
+	if usage.SentCache < 8*(1<<16)/64 || usage.Matched == 0 || usage.Chain == 0 {
+		t.Fatalf("Expected every structure to account for its memory, got %s", usage)
+	}
+
+	// Dropping the bloom filter is enough
+	conf.MarkovMaxMemory = usage.Total() - 1024
+	j.enforceMemoryLimit()
+	if j.metrics.bloomEvictions != 1 || j.metrics.matchedEvictions != 0 || j.metrics.stateEvictions != 0 {
+		t.Errorf("Expected only the bloom filter to be dropped, got %d, %d, %d", j.metrics.bloomEvictions, j.metrics.matchedEvictions, j.metrics.stateEvictions)
+	}
+	if !j.sent.add(999 * 7919) {
+		t.Errorf("Expected the inputs past the exact set to go untracked once the bloom filter is dropped")
+	}
+
+	// Then the oldest matched inputs, then the cold states
+	usage = j.MemoryUsage()
+	conf.MarkovMaxMemory = usage.SentCache + usage.Chain/2
+	j.enforceMemoryLimit()
+	if j.metrics.matchedEvictions != 200 || j.metrics.stateEvictions == 0 {
+		t.Errorf("Expected the matched inputs then the cold states to be evicted, got %d, %d", j.metrics.matchedEvictions, j.metrics.stateEvictions)
+	}
+	total := j.MemoryUsage().Total()
+	if total > conf.MarkovMaxMemory || total < conf.MarkovMaxMemory*9/10 {
+		t.Errorf("Expected the memory to be brought just under the limit of %d, got %d", conf.MarkovMaxMemory, total)
+	}
+
+	var buf bytes.Buffer
+	j.WriteMetrics(&buf)
+	for _, metric := range []string{
+		fmt.Sprintf("ffuf_memory_bytes{structure=\"chain\"} %d", j.MemoryUsage().Chain),
+		"ffuf_memory_evictions_total{structure=\"sent_cache\"} 1",
+		"ffuf_memory_evictions_total{structure=\"matched\"} 200",
+	} {
+		if !strings.Contains(buf.String(), metric) {
+			t.Errorf("Expected the metrics to contain %s", metric)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ffuf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// methodProbeOrigin is the origin of the requests resending the input of a 405 or 501 response with a method of its
+// Allow header. The origin of a probe is followed by the method it is sent with, eg. "method-probe POST".
+const methodProbeOrigin = "method-probe"
+
+// maxMethodProbes is the number of methods of an Allow header an input is resent with
+const maxMethodProbes = 4
+
+// unprobedMethods are the methods of an Allow header telling nothing about the content of a path
+var unprobedMethods = map[string]bool{"HEAD": true, "OPTIONS": true, "TRACE": true, "CONNECT": true}
+
+// isMethodSignal returns true for the status codes telling the path exists but not for the method it was requested
+// with
+func isMethodSignal(status int64) bool {
+	return status == 405 || status == 501
+}
+
+// AllowedMethods returns the contents of the Allow header of a 405 or 501 response, empty for the other responses
+func (resp *Response) AllowedMethods() string {
+	if !isMethodSignal(resp.StatusCode) {
+		return ""
+	}
+	return strings.Join(resp.Headers["Allow"], ", ")
+}
+
+// parseAllow returns the methods of an Allow header in upper case, in order and without duplicates
+func parseAllow(allow string) []string {
+	methods := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, m := range strings.Split(allow, ",") {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if m == "" || seen[m] || strings.ContainsAny(m, " \t\"") {
+			continue
+		}
+		seen[m] = true
+		methods = append(methods, m)
+	}
+	return methods
+}
+
+// probedMethod returns the method a method probe is sent with from its origin, empty for the other origins
+func probedMethod(origin string) string {
+	if !strings.HasPrefix(origin, methodProbeOrigin+" ") {
+		return ""
+	}
+	return strings.TrimPrefix(origin, methodProbeOrigin+" ")
+}
+
+// requeueMethodProbes queues the input of a 405 or 501 response again with each method of its Allow header, up to
+// maxMethodProbes, once for each input and method of the queue job. The method the response was got with is not
+// probed again.
+func (j *Job) requeueMethodProbes(input map[string][]byte, resp Response) {
+	methods := parseAllow(resp.AllowedMethods())
+	if len(methods) == 0 || resp.Request == nil {
+		return
+	}
+	generated := make(map[string][]byte, len(input))
+	for k, v := range input {
+		if k != "FFUFHASH" {
+			generated[k] = v
+		}
+	}
+	key := j.sentKey(generated)
+
+	j.requeueMutex.Lock()
+	defer j.requeueMutex.Unlock()
+	if j.methodProbed == nil {
+		j.methodProbed = make(map[string]bool)
+	}
+	j.methodProbed[fmt.Sprintf("%x %s", key, resp.Request.Method)] = true
+	probes := 0
+	for _, method := range methods {
+		if probes == maxMethodProbes {
+			break
+		}
+		probe := fmt.Sprintf("%x %s", key, method)
+		if unprobedMethods[method] || j.methodProbed[probe] {
+			continue
+		}
+		j.methodProbed[probe] = true
+		// The input was sent already with another method, it is queued without checking it was sent
+		j.requeued = append(j.requeued, requeuedInput{input: generated, origin: methodProbeOrigin + " " + method})
+		probes++
+	}
+}
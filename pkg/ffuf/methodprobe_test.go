@@ -0,0 +1,87 @@
+package ffuf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAllow(t *testing.T) {
+	tests := map[string][]string{
+		"GET, POST":             {"GET", "POST"},
+		" get,Post ,GET,, put ": {"GET", "POST", "PUT"},
+		"":                      {},
+		"GET, \"bogus method\"": {"GET"},
+	}
+	for allow, expected := range tests {
+		if got := parseAllow(allow); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%q: expected %v, got %v", allow, expected, got)
+		}
+	}
+	if m := probedMethod("method-probe PUT"); m != "PUT" {
+		t.Errorf("Expected the method of the probe origin, got %q", m)
+	}
+	if m := probedMethod("dir-probe"); m != "" {
+		t.Errorf("Expected no method for another origin, got %q", m)
+	}
+}
+
+// answerMethods returns a handler answering the GET requests of the paths with 405 and their Allow header, and the
+// other methods with 200
+func answerMethods(allow map[string]string) fakeHandler {
+	return func(req *Request, resp *Response) error {
+		resp.BodyHash = "notfound"
+		token := fuzzToken(req)
+		if allowed, ok := allow[token]; ok {
+			resp.StatusCode, resp.BodyHash = 200, req.Method+"-"+token
+			if req.Method == "GET" {
+				resp.StatusCode = 405
+				if allowed != "" {
+					resp.Headers["Allow"] = []string{allowed}
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func TestMethodProbes(t *testing.T) {
+	runner := newFakeRunner(answerMethods(map[string]string{
+		"upload": "GET, HEAD, OPTIONS, POST, PUT",
+		"api":    "GET, POST, PUT, PATCH, DELETE, PROPFIND",
+		"legacy": "",
+	}))
+	j := newFakeJob(t, runner, []string{"upload", "api", "legacy", "other"}, func(conf *Config) {
+		conf.MatcherManager = matchStatus(200, 301)
+	})
+	j.Start()
+
+	expected := map[string]int{
+		"GET upload": 1, "POST upload": 1, "PUT upload": 1,
+		"GET api": 1, "POST api": 1, "PUT api": 1, "PATCH api": 1, "DELETE api": 1,
+		"GET legacy": 1, "GET other": 1,
+	}
+	if sent := runner.counts(true); !reflect.DeepEqual(sent, expected) {
+		t.Errorf("Expected the allowed methods probed once each, up to %d, got %v", maxMethodProbes, sent)
+	}
+	origins := make(map[string]bool)
+	responses := j.Output.(*recordingOutput).responses
+	for _, resp := range responses {
+		if resp.Request.Origin != methodProbeOrigin+" "+resp.Request.Method {
+			t.Errorf("Expected the probe sent with %s attributed to it, got %q", resp.Request.Method, resp.Request.Origin)
+		}
+		origins[resp.Request.Origin] = true
+	}
+	if len(responses) != 6 || !origins["method-probe PUT"] {
+		t.Errorf("Expected the 6 probes matched, got %d with %v", len(responses), origins)
+	}
+}
+
+func TestAllowedMethods(t *testing.T) {
+	headers := map[string][]string{"Allow": {"GET, POST"}}
+	for status, expected := range map[int64]string{405: "GET, POST", 501: "GET, POST", 200: "", 403: ""} {
+		resp := Response{StatusCode: status, Headers: headers}
+		if got := resp.AllowedMethods(); got != expected {
+			t.Errorf("%d: expected %q, got %q", status, expected, got)
+		}
+	}
+}
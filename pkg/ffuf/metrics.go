@@ -0,0 +1,151 @@
+package ffuf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// statusClasses are the labels of the per status class response counters
+var statusClasses = [...]string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// Metrics holds the counters of the whole run, including the queued jobs, exposed by the status endpoint.
+// They are updated atomically from the request goroutines.
+type Metrics struct {
+	requests   int64
+	matches    int64
+	responses  [len(statusClasses)]int64
+	duplicates int64 // inputs skipped as they were sent before
+	// inputs skipped as they differ only by their case from an input sent before to a case-insensitive target
+	caseDuplicates int64
+	rateLimited    int64                    // 429 responses telling when to come back
+	rateLimitWait  int64                    // nanoseconds the job paused for the rate limited responses
+	errors         [len(errorClasses)]int64 // requests failed without a response by error class
+
+	// Evictions to stay under -markov-max-memory
+	bloomEvictions   int64 // drops of the bloom filter of the sent input cache
+	matchedEvictions int64 // matched inputs evicted
+	stateEvictions   int64 // states evicted from the Markov chain
+}
+
+func (m *Metrics) incRequests() {
+	atomic.AddInt64(&m.requests, 1)
+}
+
+func (m *Metrics) incMatches() {
+	atomic.AddInt64(&m.matches, 1)
+}
+
+// incRateLimited counts a rate limited response and the pause it asked for
+func (m *Metrics) incRateLimited(paused time.Duration) {
+	atomic.AddInt64(&m.rateLimited, 1)
+	atomic.AddInt64(&m.rateLimitWait, int64(paused))
+}
+
+// incErrors counts a request failed without a response in its error class
+func (m *Metrics) incErrors(class ErrorClass) {
+	atomic.AddInt64(&m.errors[errorClassIndex(class)], 1)
+}
+
+// errorCounts returns the number of failed requests by error class, the classes without any left out
+func (m *Metrics) errorCounts() map[string]int64 {
+	counts := make(map[string]int64)
+	for i, class := range errorClasses {
+		if n := atomic.LoadInt64(&m.errors[i]); n > 0 {
+			counts[string(class)] = n
+		}
+	}
+	return counts
+}
+
+// errorSummary returns the number of failed requests by error class, empty if none failed
+func (m *Metrics) errorSummary() string {
+	parts := make([]string, 0)
+	for i, class := range errorClasses {
+		if n := atomic.LoadInt64(&m.errors[i]); n > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", class, n))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// incResponses counts a response in its status class, other status codes are not counted
+func (m *Metrics) incResponses(status int64) {
+	class := status/100 - 1
+	if class >= 0 && class < int64(len(statusClasses)) {
+		atomic.AddInt64(&m.responses[class], 1)
+	}
+}
+
+// writeMetric writes a single metric in the Prometheus text exposition format
+func writeMetric(w io.Writer, name string, mtype string, help string, value interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, mtype, name, value)
+}
+
+// WriteMetrics writes the metrics of the job in the Prometheus text exposition format. The Markov chain
+// statistics are read without locking the chain, unlike its memory use.
+func (j *Job) WriteMetrics(w io.Writer) {
+	writeMetric(w, "ffuf_requests_total", "counter", "Total number of requests sent.", atomic.LoadInt64(&j.metrics.requests))
+	writeMetric(w, "ffuf_matches_total", "counter", "Total number of matched responses.", atomic.LoadInt64(&j.metrics.matches))
+	fmt.Fprintf(w, "# HELP ffuf_responses_total Total number of responses by status class.\n# TYPE ffuf_responses_total counter\n")
+	for i, class := range statusClasses {
+		fmt.Fprintf(w, "ffuf_responses_total{class=\"%s\"} %d\n", class, atomic.LoadInt64(&j.metrics.responses[i]))
+	}
+	fmt.Fprintf(w, "# HELP ffuf_errors_total Total number of requests failed without a response by error class.\n# TYPE ffuf_errors_total counter\n")
+	for i, class := range errorClasses {
+		fmt.Fprintf(w, "ffuf_errors_total{class=\"%s\"} %d\n", class, atomic.LoadInt64(&j.metrics.errors[i]))
+	}
+	writeMetric(w, "ffuf_duplicates_skipped_total", "counter", "Total number of inputs skipped as they were sent before.", atomic.LoadInt64(&j.metrics.duplicates))
+	writeMetric(w, "ffuf_case_duplicates_skipped_total", "counter", "Total number of inputs skipped as they differ only by their case from the ones sent before to a case-insensitive target.", atomic.LoadInt64(&j.metrics.caseDuplicates))
+	writeMetric(w, "ffuf_rate_limited_total", "counter", "Total number of 429 responses telling when to come back in a Retry-After or X-RateLimit-Reset header.", atomic.LoadInt64(&j.metrics.rateLimited))
+	writeMetric(w, "ffuf_rate_limit_wait_seconds_total", "counter", "Total time paused for the rate limited responses in seconds.", time.Duration(atomic.LoadInt64(&j.metrics.rateLimitWait)).Seconds())
+	writeMetric(w, "ffuf_current_rate", "gauge", "Current request rate in requests per second.", j.Rate.CurrentRate())
+	writeMetric(w, "ffuf_queue_depth", "gauge", "Number of queued jobs waiting to be started.", j.queueDepth())
+	if j.MarkovChain != nil {
+		stats := j.MarkovChain.MarkovChain.Stats()
+		writeMetric(w, "ffuf_markov_states", "gauge", "Number of states known by the Markov chain.", stats.States)
+		writeMetric(w, "ffuf_markov_transitions", "counter", "Total number of transitions observed by the Markov chain.", stats.Transitions)
+		writeMetric(w, "ffuf_markov_mean_reward", "gauge", "Mean reward of the transitions observed by the Markov chain.", stats.MeanReward)
+	}
+	discovery := j.DiscoveryRates()
+	fmt.Fprintf(w, "# HELP ffuf_discovery_matches Number of matches in the last window of %d requests and in the one before it.\n# TYPE ffuf_discovery_matches gauge\n", discoveryWindow)
+	fmt.Fprintf(w, "ffuf_discovery_matches{window=\"last\"} %d\n", discovery.Matches)
+	fmt.Fprintf(w, "ffuf_discovery_matches{window=\"previous\"} %d\n", discovery.PreviousMatches)
+	writeMetric(w, "ffuf_discovery_new_content_ratio", "gauge", "Share of the requests of the last window getting a response body never seen before.", discovery.NewContentRate)
+	writeMetric(w, "ffuf_discovery_stall_score", "gauge", "Stall score from 0 to 1, rising as the matches and the new content of the last window run dry.", discovery.StallScore)
+	usage := j.MemoryUsage()
+	fmt.Fprintf(w, "# HELP ffuf_memory_bytes Approximate memory used by structure.\n# TYPE ffuf_memory_bytes gauge\n")
+	fmt.Fprintf(w, "ffuf_memory_bytes{structure=\"sent_cache\"} %d\n", usage.SentCache)
+	fmt.Fprintf(w, "ffuf_memory_bytes{structure=\"matched\"} %d\n", usage.Matched)
+	fmt.Fprintf(w, "ffuf_memory_bytes{structure=\"chain\"} %d\n", usage.Chain)
+	fmt.Fprintf(w, "# HELP ffuf_memory_evictions_total Total number of evictions to stay under the memory limit by structure.\n# TYPE ffuf_memory_evictions_total counter\n")
+	fmt.Fprintf(w, "ffuf_memory_evictions_total{structure=\"sent_cache\"} %d\n", atomic.LoadInt64(&j.metrics.bloomEvictions))
+	fmt.Fprintf(w, "ffuf_memory_evictions_total{structure=\"matched\"} %d\n", atomic.LoadInt64(&j.metrics.matchedEvictions))
+	fmt.Fprintf(w, "ffuf_memory_evictions_total{structure=\"chain\"} %d\n", atomic.LoadInt64(&j.metrics.stateEvictions))
+}
+
+// startStatusServer starts listening on the configured status address, serving the metrics at /metrics.
+// The returned function shuts the listener down.
+func (j *Job) startStatusServer() (func(), error) {
+	listener, err := net.Listen("tcp", j.Config.StatusAddr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		j.WriteMetrics(w)
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = server.Serve(listener) }()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}, nil
+}
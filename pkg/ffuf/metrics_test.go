@@ -0,0 +1,136 @@
+package ffuf
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrapeMetrics fetches the metrics endpoint, returning the values by metric name including the labels
+func scrapeMetrics(addr string) (map[string]float64, error) {
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	metrics := make(map[string]float64)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("malformed metric line: %s", line)
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		metrics[fields[0]] = value
+	}
+	return metrics, scanner.Err()
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	words := make([]string, 100)
+	for i := range words {
+		words[i] = fmt.Sprintf("word%d", i)
+	}
+	// The target answers every request after a delay, every tenth one with a 200
+	runner := newFakeRunner(func(req *Request, resp *Response) error {
+		time.Sleep(5 * time.Millisecond)
+		if req.Position%10 == 0 {
+			resp.StatusCode = 200
+		}
+		return nil
+	})
+	j := newFakeJob(t, runner, words, func(conf *Config) {
+		conf.Threads = 2
+		// The matched words form a pattern, keep the number of requests to the wordlist
+		conf.MarkovPatternMax = 0
+		conf.StatusAddr = addr
+	})
+
+	done := make(chan bool)
+	go func() {
+		j.Start()
+		close(done)
+	}()
+
+	names := []string{"ffuf_requests_total", "ffuf_matches_total", "ffuf_current_rate", "ffuf_queue_depth",
+		"ffuf_markov_states", "ffuf_markov_transitions", "ffuf_markov_mean_reward",
+		`ffuf_responses_total{class="2xx"}`, `ffuf_responses_total{class="4xx"}`}
+	counters := []string{"ffuf_requests_total", "ffuf_matches_total", "ffuf_markov_transitions",
+		`ffuf_responses_total{class="2xx"}`, `ffuf_responses_total{class="4xx"}`}
+	scrapes := 0
+	var previous map[string]float64
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-time.After(20 * time.Millisecond):
+			metrics, err := scrapeMetrics(addr)
+			if err != nil {
+				// the listener may not be up yet, or already shut down
+				continue
+			}
+			scrapes++
+			for _, name := range names {
+				if _, ok := metrics[name]; !ok {
+					t.Errorf("Metric %s missing from the scrape", name)
+				}
+			}
+			for _, name := range counters {
+				if previous != nil && metrics[name] < previous[name] {
+					t.Errorf("Counter %s decreased from %f to %f", name, previous[name], metrics[name])
+				}
+			}
+			previous = metrics
+		}
+	}
+	if scrapes < 2 {
+		t.Fatalf("Expected to scrape the endpoint during the run, got %d scrapes", scrapes)
+	}
+
+	var sb strings.Builder
+	j.WriteMetrics(&sb)
+	for _, expected := range []string{"ffuf_requests_total 100\n", "ffuf_matches_total 10\n", "ffuf_markov_transitions 100\n",
+		`ffuf_responses_total{class="2xx"} 10` + "\n", `ffuf_responses_total{class="4xx"} 90` + "\n"} {
+		if !strings.Contains(sb.String(), expected) {
+			t.Errorf("Expected %q in the final metrics, got:\n%s", expected, sb.String())
+		}
+	}
+	if _, err := http.Get("http://" + addr + "/metrics"); err == nil {
+		t.Errorf("The status endpoint should be shut down after the run")
+	}
+}
+
+func TestMetricsWithoutMarkov(t *testing.T) {
+	conf := NewConfig(context.Background(), func() {})
+	j := NewJob(&conf)
+	j.metrics.incResponses(200)
+	j.metrics.incResponses(999)
+	var sb strings.Builder
+	j.WriteMetrics(&sb)
+	if strings.Contains(sb.String(), "ffuf_markov_") {
+		t.Errorf("Markov metrics should only be exposed when the chain is enabled")
+	}
+	if !strings.Contains(sb.String(), `ffuf_responses_total{class="2xx"} 1`) {
+		t.Errorf("Expected the response to be counted, got:\n%s", sb.String())
+	}
+}
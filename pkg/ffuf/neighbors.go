@@ -0,0 +1,70 @@
+package ffuf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+// inputKeyword returns the keyword and value of an input of a single keyword, the only inputs the trigram
+// neighbors are looked up for
+func inputKeyword(input map[string][]byte) (string, []byte, bool) {
+	keyword := ""
+	for k := range input {
+		if k == "FFUFHASH" {
+			continue
+		}
+		if keyword != "" {
+			return "", nil, false
+		}
+		keyword = k
+	}
+	return keyword, input[keyword], keyword != ""
+}
+
+// neighborIndex returns the trigram index of the wordlist of the keyword, building it on first use. Returns nil
+// when the neighbors are disabled, or the keyword is not backed by a wordlist that can be indexed.
+func (j *Job) neighborIndex(keyword string) *markov.TrigramIndex {
+	if j.MarkovChain == nil || j.Config.MarkovNeighbors == 0 {
+		return nil
+	}
+	j.neighborsMutex.Lock()
+	defer j.neighborsMutex.Unlock()
+
+	if ix, ok := j.neighbors[keyword]; ok {
+		return ix
+	}
+	var ix *markov.TrigramIndex
+	if wp, ok := j.Input.(WordsProvider); ok && len(j.Input.Keywords()) == 1 {
+		if words := wp.Words(keyword); len(words) > 0 {
+			start := time.Now()
+			ix = markov.NewTrigramIndex(words)
+			if ix == nil {
+				j.Output.Warning(fmt.Sprintf("The wordlist of %s is too large to look up the neighbors of the matches", keyword))
+			} else if j.Config.Verbose {
+				j.Output.Info(fmt.Sprintf("Indexed the %d words of %s for the neighbors of the matches in %s", len(words), keyword, time.Since(start).Round(time.Millisecond)))
+			}
+		}
+	}
+	if j.neighbors == nil {
+		j.neighbors = make(map[string]*markov.TrigramIndex)
+	}
+	j.neighbors[keyword] = ix
+	return ix
+}
+
+// requeueNeighbors requeues the words of the wordlist closest to a matched value that were not sent yet
+func (j *Job) requeueNeighbors(input map[string][]byte) {
+	keyword, value, ok := inputKeyword(input)
+	if !ok || j.MarkovChain.SeedRetired(string(value)) {
+		return
+	}
+	ix := j.neighborIndex(keyword)
+	if ix == nil {
+		return
+	}
+	for _, word := range ix.Similar(string(value), j.Config.MarkovNeighbors) {
+		j.requeue(map[string][]byte{keyword: []byte(word)}, "trigram-neighbor", string(value))
+	}
+}
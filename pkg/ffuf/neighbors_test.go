@@ -0,0 +1,32 @@
+package ffuf
+
+import (
+	"reflect"
+	"testing"
+)
+
+// wordsInput is a sliceInput able to list its words in advance
+type wordsInput struct {
+	sliceInput
+}
+
+func (w *wordsInput) Words(keyword string) [][]byte {
+	words := make([][]byte, 0, len(w.words))
+	for _, word := range w.words {
+		words = append(words, []byte(word))
+	}
+	return words
+}
+
+func TestRequeueNeighbors(t *testing.T) {
+	runner := newFakeRunner(answerTokens(map[string]int64{"backup": 200}))
+	j := newFakeJob(t, runner, nil, func(conf *Config) { conf.MarkovNeighbors = 2 })
+	j.Input = &wordsInput{sliceInput{words: []string{"admin", "backup", "index", "login", "db-backup", "contact", "backups", "backup_old"}}}
+	j.Start()
+
+	// The two closest neighbors of backup are sent right after it, and skipped when the wordlist reaches them
+	expected := []string{"admin", "backup", "backups", "db-backup", "index", "login", "contact", "backup_old"}
+	if sent := runner.tokens(); !reflect.DeepEqual(sent, expected) {
+		t.Errorf("Expected the tokens to be sent in the order %v, got %v", expected, sent)
+	}
+}
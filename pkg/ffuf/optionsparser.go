@@ -3,6 +3,7 @@ package ffuf
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/textproto"
@@ -10,9 +11,12 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ffuf/ffuf/v2/pkg/markov"
 	"github.com/pelletier/go-toml"
 )
 
@@ -21,6 +25,7 @@ type ConfigOptions struct {
 	General GeneralOptions `json:"general"`
 	HTTP    HTTPOptions    `json:"http"`
 	Input   InputOptions   `json:"input"`
+	Markov  MarkovOptions  `json:"markov"`
 	Matcher MatcherOptions `json:"matchers"`
 	Output  OutputOptions  `json:"output"`
 }
@@ -30,28 +35,40 @@ type HTTPOptions struct {
 	Data              string   `json:"data"`
 	FollowRedirects   bool     `json:"follow_redirects"`
 	Headers           []string `json:"headers"`
+	HeaderPool        string   `json:"header_pool"`
 	IgnoreBody        bool     `json:"ignore_body"`
 	Method            string   `json:"method"`
 	ProxyURL          string   `json:"proxy_url"`
+	ProxyList         string   `json:"proxy_list"`
+	RateLimitMaxWait  string   `json:"rate_limit_max_wait"`
 	Raw               bool     `json:"raw"`
 	Recursion         bool     `json:"recursion"`
 	RecursionDepth    int      `json:"recursion_depth"`
 	RecursionStrategy string   `json:"recursion_strategy"`
 	ReplayProxyURL    string   `json:"replay_proxy_url"`
+	ResponseSizeLimit int      `json:"response_size_limit"`
+	Retries           int      `json:"retries"`
+	RetryDelay        string   `json:"retry_delay"`
 	SNI               string   `json:"sni"`
+	StructuralHash    bool     `json:"structural_hash"`
 	Timeout           int      `json:"timeout"`
 	URL               string   `json:"url"`
 	Http2             bool     `json:"http2"`
 	ClientCert        string   `json:"client-cert"`
 	ClientKey         string   `json:"client-key"`
+	ClientCA          string   `json:"client-ca"`
+	CookieJar         string   `json:"cookie_jar"`
 }
 
 type GeneralOptions struct {
 	AutoCalibration           bool     `json:"autocalibration"`
 	AutoCalibrationKeyword    string   `json:"autocalibration_keyword"`
 	AutoCalibrationPerHost    bool     `json:"autocalibration_per_host"`
+	AutoCalibrationPerRoot    bool     `json:"autocalibration_per_root"`
 	AutoCalibrationStrategies []string `json:"autocalibration_strategies"`
 	AutoCalibrationStrings    []string `json:"autocalibration_strings"`
+	CheckpointDir             string   `json:"checkpoint_dir"`
+	CheckpointInterval        string   `json:"checkpoint_interval"`
 	Colors                    bool     `json:"colors"`
 	ConfigFile                string   `toml:"-" json:"config_file"`
 	Delay                     string   `json:"delay"`
@@ -61,10 +78,14 @@ type GeneralOptions struct {
 	Noninteractive            bool     `json:"noninteractive"`
 	Quiet                     bool     `json:"quiet"`
 	Rate                      int      `json:"rate"`
+	RequestBudget             int      `json:"request_budget"`
+	RequestBudgetExplore      float64  `json:"request_budget_explore"`
+	ResumeCheckpoint          string   `json:"resume_checkpoint"`
 	ScraperFile               string   `json:"scraperfile"`
 	Scrapers                  string   `json:"scrapers"`
 	Searchhash                string   `json:"-"`
 	ShowVersion               bool     `toml:"-" json:"-"`
+	StatusAddr                string   `json:"status_addr"`
 	StopOn403                 bool     `json:"stop_on_403"`
 	StopOnAll                 bool     `json:"stop_on_all"`
 	StopOnErrors              bool     `json:"stop_on_errors"`
@@ -80,23 +101,77 @@ type InputOptions struct {
 	InputMode              string   `json:"input_mode"`
 	InputNum               int      `json:"input_num"`
 	InputShell             string   `json:"input_shell"`
+	InputShard             string   `json:"input_shard"`
 	Inputcommands          []string `json:"input_commands"`
+	ReplayFrom             string   `json:"replay_from"`
+	ReplayMinReward        float64  `json:"replay_min_reward"`
 	Request                string   `json:"request_file"`
 	RequestProto           string   `json:"request_proto"`
 	Wordlists              []string `json:"wordlists"`
 }
 
 type OutputOptions struct {
-	AuditLog            string `json:"audit_log"`
-	DebugLog            string `json:"debug_log"`
-	OutputDirectory     string `json:"output_directory"`
-	OutputFile          string `json:"output_file"`
-	OutputFormat        string `json:"output_format"`
-	OutputSkipEmptyFile bool   `json:"output_skip_empty"`
+	AuditLog                 string  `json:"audit_log"`
+	DebugLog                 string  `json:"debug_log"`
+	OutputDirectory          string  `json:"output_directory"`
+	OutputDirectoryMaxFiles  int     `json:"output_directory_max_files"`
+	OutputDirectoryMinReward float64 `json:"output_directory_min_reward"`
+	OutputDirectoryStatus    string  `json:"output_directory_status"`
+	OutputFile               string  `json:"output_file"`
+	OutputFormat             string  `json:"output_format"`
+	OutputSkipEmptyFile      bool    `json:"output_skip_empty"`
+	Sort                     string  `json:"sort"`
+}
+
+type MarkovOptions struct {
+	Enabled         bool    `json:"enabled"`
+	Proto           bool    `json:"proto"`
+	Headers         bool    `json:"headers"`
+	Cookie          bool    `json:"cookie"`
+	CookieReward    float64 `json:"cookie_reward"`
+	RedirectReward  float64 `json:"redirect_reward"`
+	Granularity     string  `json:"granularity"`
+	WordlistOut     string  `json:"wordlist_out"`
+	SeedHistory     string  `json:"seed_history"`
+	SeedTarget      bool    `json:"seed_target"`
+	TimeoutReward   float64 `json:"timeout_reward"`
+	ConnErrorReward float64 `json:"conn_error_reward"`
+	Save            string  `json:"save"`
+	Load            string  `json:"load"`
+	Cooldown        float64 `json:"cooldown"`
+	CooldownStatus  string  `json:"cooldown_status"`
+	CooldownUA      string  `json:"cooldown_ua"`
+	PatternMax      int     `json:"pattern_max"`
+	Neighbors       int     `json:"neighbors"`
+	Top             int     `json:"top"`
+	FinalPass       int     `json:"final_pass"`
+	Sync            string  `json:"sync"`
+	SyncInterval    string  `json:"sync_interval"`
+	ColdBudget      int     `json:"cold_budget"`
+	MaxMemory       string  `json:"max_memory"`
+	ClassQuota      int     `json:"class_quota"`
+	RewardExpr      string  `json:"reward_expr"`
+	SizeBuckets     string  `json:"size_buckets"`
+	PrefixThreshold float64 `json:"prefix_threshold"`
+	PrefixRecursion bool    `json:"prefix_recursion"`
+	Threshold       float64 `json:"threshold"`
+	ReflectReward   float64 `json:"reflect_reward"`
+	Phases          string  `json:"phases"`
+	DurationCV      float64 `json:"duration_cv"`
+	KnownGood       string  `json:"known_good"`
+	ExportWeights   string  `json:"export_weights"`
+	ExportAggregate string  `json:"export_aggregate"`
+	UpdateBuffer    int     `json:"update_buffer"`
+	UniqueEntropy   float64 `json:"unique_entropy"`
+	Normalize       bool    `json:"normalize"`
+	DryRun          int     `json:"dry_run"`
+	DryRunOut       string  `json:"dry_run_out"`
+	StackFilter     bool    `json:"stack_filter"`
 }
 
 type FilterOptions struct {
 	Mode   string `json:"mode"`
+	Hash   string `json:"hash"`
 	Lines  string `json:"lines"`
 	Regexp string `json:"regexp"`
 	Size   string `json:"size"`
@@ -106,19 +181,22 @@ type FilterOptions struct {
 }
 
 type MatcherOptions struct {
-	Mode   string `json:"mode"`
-	Lines  string `json:"lines"`
-	Regexp string `json:"regexp"`
-	Size   string `json:"size"`
-	Status string `json:"status"`
-	Time   string `json:"time"`
-	Words  string `json:"words"`
+	Mode    string `json:"mode"`
+	Hash    string `json:"hash"`
+	Lines   string `json:"lines"`
+	Reflect bool   `json:"reflect"`
+	Regexp  string `json:"regexp"`
+	Size    string `json:"size"`
+	Status  string `json:"status"`
+	Time    string `json:"time"`
+	Words   string `json:"words"`
 }
 
 // NewConfigOptions returns a newly created ConfigOptions struct with default values
 func NewConfigOptions() *ConfigOptions {
 	c := &ConfigOptions{}
 	c.Filter.Mode = "or"
+	c.Filter.Hash = ""
 	c.Filter.Lines = ""
 	c.Filter.Regexp = ""
 	c.Filter.Size = ""
@@ -128,6 +206,8 @@ func NewConfigOptions() *ConfigOptions {
 	c.General.AutoCalibration = false
 	c.General.AutoCalibrationKeyword = "FUZZ"
 	c.General.AutoCalibrationStrategies = []string{"basic"}
+	c.General.CheckpointDir = ""
+	c.General.CheckpointInterval = "60s"
 	c.General.Colors = false
 	c.General.Delay = ""
 	c.General.Json = false
@@ -136,10 +216,14 @@ func NewConfigOptions() *ConfigOptions {
 	c.General.Noninteractive = false
 	c.General.Quiet = false
 	c.General.Rate = 0
+	c.General.RequestBudget = 0
+	c.General.RequestBudgetExplore = 0.1
+	c.General.ResumeCheckpoint = ""
 	c.General.Searchhash = ""
 	c.General.ScraperFile = ""
 	c.General.Scrapers = "all"
 	c.General.ShowVersion = false
+	c.General.StatusAddr = ""
 	c.General.StopOn403 = false
 	c.General.StopOnAll = false
 	c.General.StopOnErrors = false
@@ -150,13 +234,20 @@ func NewConfigOptions() *ConfigOptions {
 	c.HTTP.IgnoreBody = false
 	c.HTTP.Method = ""
 	c.HTTP.ProxyURL = ""
+	c.HTTP.ProxyList = ""
+	c.HTTP.HeaderPool = ""
 	c.HTTP.Raw = false
 	c.HTTP.Recursion = false
 	c.HTTP.RecursionDepth = 0
 	c.HTTP.RecursionStrategy = "default"
 	c.HTTP.ReplayProxyURL = ""
+	c.HTTP.Retries = 1
+	c.HTTP.RetryDelay = ""
+	c.HTTP.RateLimitMaxWait = "60s"
+	c.HTTP.ResponseSizeLimit = 5242880
 	c.HTTP.Timeout = 10
 	c.HTTP.SNI = ""
+	c.HTTP.StructuralHash = false
 	c.HTTP.URL = ""
 	c.HTTP.Http2 = false
 	c.Input.DirSearchCompat = false
@@ -165,9 +256,56 @@ func NewConfigOptions() *ConfigOptions {
 	c.Input.IgnoreWordlistComments = false
 	c.Input.InputMode = "clusterbomb"
 	c.Input.InputNum = 100
+	c.Input.ReplayFrom = ""
+	c.Input.ReplayMinReward = 0
 	c.Input.Request = ""
 	c.Input.RequestProto = "https"
+	c.Markov.Enabled = false
+	c.Markov.Proto = false
+	c.Markov.Headers = false
+	c.Markov.Cookie = false
+	c.Markov.CookieReward = 0
+	c.Markov.RedirectReward = 0
+	c.Markov.Granularity = "default"
+	c.Markov.WordlistOut = ""
+	c.Markov.SeedHistory = ""
+	c.Markov.SeedTarget = false
+	c.Markov.TimeoutReward = 0.2
+	c.Markov.ConnErrorReward = 0
+	c.Markov.Save = ""
+	c.Markov.Load = ""
+	c.Markov.Cooldown = 0
+	c.Markov.CooldownStatus = "403,429"
+	c.Markov.CooldownUA = ""
+	c.Markov.PatternMax = 50
+	c.Markov.Neighbors = 5
+	c.Markov.Top = 0
+	c.Markov.FinalPass = 0
+	c.Markov.Sync = ""
+	c.Markov.SyncInterval = "60s"
+	c.Markov.ColdBudget = 50
+	c.Markov.MaxMemory = ""
+	c.Markov.ClassQuota = 4
+	c.Markov.RewardExpr = ""
+	c.Markov.SizeBuckets = "log"
+	c.Markov.PrefixThreshold = 1.0
+	c.Markov.PrefixRecursion = false
+	c.Markov.Threshold = 0.01
+	c.Markov.ReflectReward = 0.5
+	c.Markov.Phases = ""
+	c.Markov.DurationCV = markov.DefaultDurationMaxCV
+	c.Markov.KnownGood = ""
+	c.Markov.ExportWeights = ""
+	c.Markov.ExportAggregate = markov.WeightsMax
+	c.Markov.UpdateBuffer = markov.DefaultUpdateBuffer
+	c.Markov.UniqueEntropy = 0
+	c.Markov.Normalize = false
+	c.Markov.DryRun = 0
+	c.Markov.DryRunOut = ""
+	c.Markov.StackFilter = false
 	c.Matcher.Mode = "or"
+	c.Matcher.Hash = ""
+	c.Matcher.Reflect = false
 	c.Matcher.Lines = ""
 	c.Matcher.Regexp = ""
 	c.Matcher.Size = ""
@@ -177,9 +315,13 @@ func NewConfigOptions() *ConfigOptions {
 	c.Output.AuditLog = ""
 	c.Output.DebugLog = ""
 	c.Output.OutputDirectory = ""
+	c.Output.OutputDirectoryMaxFiles = 0
+	c.Output.OutputDirectoryMinReward = 0
+	c.Output.OutputDirectoryStatus = "all"
 	c.Output.OutputFile = ""
 	c.Output.OutputFormat = "json"
 	c.Output.OutputSkipEmptyFile = false
+	c.Output.Sort = ""
 	return c
 }
 
@@ -192,7 +334,7 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 
 	var err error
 	var err2 error
-	if len(parseOpts.HTTP.URL) == 0 && parseOpts.Input.Request == "" {
+	if len(parseOpts.HTTP.URL) == 0 && parseOpts.Input.Request == "" && parseOpts.Input.ReplayFrom == "" {
 		errs.Add(fmt.Errorf("-u flag or -request flag is required"))
 	}
 
@@ -211,7 +353,7 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 	conf.InputMode = parseOpts.Input.InputMode
 
 	validmode := false
-	for _, mode := range []string{"clusterbomb", "pitchfork", "sniper"} {
+	for _, mode := range []string{"clusterbomb", "pitchfork", "sniper", "bandit"} {
 		if conf.InputMode == mode {
 			validmode = true
 		}
@@ -344,9 +486,25 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 		}
 	}
 
-	if len(conf.InputProviders) == 0 {
+	// Replay the results of a previous run instead of the wordlists
+	if parseOpts.Input.ReplayFrom != "" {
+		if err := prepareReplay(parseOpts, &conf); err != nil {
+			errs.Add(err)
+		}
+	}
+
+	if len(conf.InputProviders) == 0 && conf.ReplayFrom == "" {
 		errs.Add(fmt.Errorf("Either -w or --input-cmd flag is required"))
 	}
+	// bandit mode splits the requests of a single keyword across the wordlists
+	if conf.InputMode == "bandit" {
+		for _, p := range conf.InputProviders {
+			if p.Keyword != conf.InputProviders[0].Keyword {
+				errs.Add(fmt.Errorf("bandit mode requires all the wordlists to use the same keyword"))
+				break
+			}
+		}
+	}
 
 	// Prepare the request using body
 	if parseOpts.Input.Request != "" {
@@ -360,6 +518,17 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 	//Prepare URL
 	if parseOpts.HTTP.URL != "" {
 		conf.Url = parseOpts.HTTP.URL
+		// Tell apart the keywords fuzzing the names and the values of the query parameters
+		if parts := strings.SplitN(conf.Url, "?", 2); len(parts) == 2 {
+			for _, provider := range conf.InputProviders {
+				if strings.Contains(parts[0], provider.Keyword) {
+					continue
+				}
+				if loc := queryKeywordLocation(provider.Keyword, parts[1]); loc != "" {
+					conf.KeywordLocations[provider.Keyword] = loc
+				}
+			}
+		}
 	}
 
 	// Prepare SNI
@@ -374,6 +543,20 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 	if parseOpts.HTTP.ClientKey != "" {
 		conf.ClientKey = parseOpts.HTTP.ClientKey
 	}
+	conf.ClientCA = parseOpts.HTTP.ClientCA
+	if parseOpts.HTTP.CookieJar != "" {
+		if _, err := LoadCookieJar(parseOpts.HTTP.CookieJar); err != nil {
+			errs.Add(fmt.Errorf("Could not read the cookie jar (-cookie-jar): %s", err))
+		}
+		conf.CookieJar = parseOpts.HTTP.CookieJar
+	}
+	if conf.ClientCert != "" || conf.ClientKey != "" || conf.ClientCA != "" {
+		if conf.ClientCert == "" || conf.ClientKey == "" {
+			errs.Add(fmt.Errorf("Client certificate (-cc) and client key (-ck) need to be defined together"))
+		} else if _, err := ClientCertificate(&conf); err != nil {
+			errs.Add(fmt.Errorf("Could not load the client certificate: %s", err))
+		}
+	}
 
 	//Prepare headers and make canonical
 	for _, v := range parseOpts.HTTP.Headers {
@@ -427,6 +610,33 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 		}
 	}
 
+	// Prepare transport error retries
+	if parseOpts.HTTP.Retries < 0 {
+		errs.Add(fmt.Errorf("Number of retries (-retries) cannot be negative"))
+	} else {
+		conf.Retries = parseOpts.HTTP.Retries
+	}
+	if len(parseOpts.HTTP.RetryDelay) > 0 {
+		conf.RetryDelay, err = time.ParseDuration(parseOpts.HTTP.RetryDelay)
+		if err != nil || conf.RetryDelay < 0 {
+			errs.Add(fmt.Errorf("Retry delay (-retry-delay) needs to be a valid duration, for example: 500ms or 2s"))
+		}
+	}
+	if len(parseOpts.HTTP.RateLimitMaxWait) > 0 {
+		conf.RateLimitMaxWait, err = time.ParseDuration(parseOpts.HTTP.RateLimitMaxWait)
+		if err != nil || conf.RateLimitMaxWait < 0 {
+			errs.Add(fmt.Errorf("Maximum rate limit wait (-rate-limit-max-wait) needs to be a valid duration, for example: 30s or 2m"))
+		}
+	}
+
+	// Response body size limit, 0 disables the limit
+	if parseOpts.HTTP.ResponseSizeLimit < 0 {
+		errs.Add(fmt.Errorf("Response size limit (-response-size-limit) cannot be negative"))
+	} else {
+		conf.ResponseSizeLimit = int64(parseOpts.HTTP.ResponseSizeLimit)
+	}
+	conf.StructuralHash = parseOpts.HTTP.StructuralHash
+
 	// Verify proxy url format
 	if len(parseOpts.HTTP.ProxyURL) > 0 {
 		u, err := url.Parse(parseOpts.HTTP.ProxyURL)
@@ -437,6 +647,29 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 		}
 	}
 
+	// Read and verify the proxy list
+	if len(parseOpts.HTTP.ProxyList) > 0 {
+		if len(parseOpts.HTTP.ProxyURL) > 0 {
+			errs.Add(fmt.Errorf("Proxy (-x) and proxy list (-x-list) cannot be used at the same time"))
+		}
+		proxies, err := readProxyList(parseOpts.HTTP.ProxyList)
+		if err != nil {
+			errs.Add(err)
+		} else {
+			conf.ProxyList = proxies
+		}
+	}
+
+	// Read the header presets
+	if len(parseOpts.HTTP.HeaderPool) > 0 {
+		presets, err := readHeaderPool(parseOpts.HTTP.HeaderPool)
+		if err != nil {
+			errs.Add(err)
+		} else {
+			conf.HeaderPool = presets
+		}
+	}
+
 	// Verify replayproxy url format
 	if len(parseOpts.HTTP.ReplayProxyURL) > 0 {
 		u, err := url.Parse(parseOpts.HTTP.ReplayProxyURL)
@@ -450,7 +683,7 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 	//Check the output file format option
 	if parseOpts.Output.OutputFile != "" {
 		//No need to check / error out if output file isn't defined
-		outputFormats := []string{"all", "json", "ejson", "html", "md", "csv", "ecsv"}
+		outputFormats := []string{"all", "json", "ejson", "html", "md", "csv", "ecsv", "curl", "hurl", "tree", "tree-json"}
 		found := false
 		for _, f := range outputFormats {
 			if f == parseOpts.Output.OutputFormat {
@@ -463,6 +696,15 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 		}
 	}
 
+	// Check the result sorting key
+	if parseOpts.Output.Sort != "" {
+		if !StrInSlice(parseOpts.Output.Sort, []string{"reward", "status", "size", "url"}) {
+			errs.Add(fmt.Errorf("Unknown result sorting key (-sort): %s, valid values are: reward, status, size, url", parseOpts.Output.Sort))
+		} else {
+			conf.Sort = parseOpts.Output.Sort
+		}
+	}
+
 	// Auto-calibration strings
 	if len(parseOpts.General.AutoCalibrationStrings) > 0 {
 		conf.AutoCalibrationStrings = parseOpts.General.AutoCalibrationStrings
@@ -485,6 +727,16 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 	} else {
 		conf.Rate = int64(parseOpts.General.Rate)
 	}
+	if parseOpts.General.RequestBudget < 0 {
+		errs.Add(fmt.Errorf("Request budget (-request-budget) must not be negative"))
+	} else {
+		conf.RequestBudget = parseOpts.General.RequestBudget
+	}
+	if parseOpts.General.RequestBudgetExplore < 0 || parseOpts.General.RequestBudgetExplore > 1 {
+		errs.Add(fmt.Errorf("Request budget exploration share (-request-budget-explore) must be between 0 and 1"))
+	} else {
+		conf.RequestBudgetExplore = parseOpts.General.RequestBudgetExplore
+	}
 
 	if conf.Method == "" {
 		if parseOpts.HTTP.Method == "" {
@@ -500,6 +752,12 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 			conf.Method = parseOpts.HTTP.Method
 		}
 	}
+	// Keywords fuzzing the method are told apart in the Markov chain actions
+	for _, provider := range conf.InputProviders {
+		if strings.Contains(conf.Method, provider.Keyword) {
+			conf.KeywordLocations[provider.Keyword] = "method"
+		}
+	}
 
 	if parseOpts.HTTP.Data != "" {
 		// Only set if defined on command line, because we might be reparsing the CLI after
@@ -514,14 +772,34 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 	conf.InputNum = parseOpts.Input.InputNum
 
 	conf.InputShell = parseOpts.Input.InputShell
+	if len(parseOpts.Input.InputShard) > 0 {
+		conf.InputShard, conf.InputShards, err = parseShard(parseOpts.Input.InputShard)
+		if err != nil {
+			errs.Add(err)
+		} else if len(parseOpts.Input.Inputcommands) > 0 {
+			errs.Add(fmt.Errorf("Input shard (-input-shard) only applies to wordlists and cannot be combined with -input-cmd"))
+		}
+	}
 	conf.AuditLog = parseOpts.Output.AuditLog
 	conf.OutputFile = parseOpts.Output.OutputFile
 	conf.OutputDirectory = parseOpts.Output.OutputDirectory
+	if parseOpts.Output.OutputDirectoryMaxFiles < 0 {
+		errs.Add(fmt.Errorf("Maximum number of stored results (-od-max-files) cannot be negative"))
+	}
+	conf.OutputDirectoryMaxFiles = parseOpts.Output.OutputDirectoryMaxFiles
+	conf.OutputDirectoryMinReward = parseOpts.Output.OutputDirectoryMinReward
+	for _, sv := range strings.Split(parseOpts.Output.OutputDirectoryStatus, ",") {
+		if _, err := ValueRangeFromString(sv); err != nil && sv != "all" {
+			errs.Add(fmt.Errorf("Status codes of the stored results (-od-status): invalid value %s", sv))
+		}
+	}
+	conf.OutputDirectoryStatus = parseOpts.Output.OutputDirectoryStatus
 	conf.OutputSkipEmptyFile = parseOpts.Output.OutputSkipEmptyFile
 	conf.IgnoreBody = parseOpts.HTTP.IgnoreBody
 	conf.Quiet = parseOpts.General.Quiet
 	conf.ScraperFile = parseOpts.General.ScraperFile
 	conf.Scrapers = parseOpts.General.Scrapers
+	conf.StatusAddr = parseOpts.General.StatusAddr
 	conf.StopOn403 = parseOpts.General.StopOn403
 	conf.StopOnAll = parseOpts.General.StopOnAll
 	conf.StopOnErrors = parseOpts.General.StopOnErrors
@@ -532,6 +810,7 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 	conf.RecursionStrategy = parseOpts.HTTP.RecursionStrategy
 	conf.AutoCalibration = parseOpts.General.AutoCalibration
 	conf.AutoCalibrationPerHost = parseOpts.General.AutoCalibrationPerHost
+	conf.AutoCalibrationPerRoot = parseOpts.General.AutoCalibrationPerRoot
 	conf.AutoCalibrationStrategies = parseOpts.General.AutoCalibrationStrategies
 	conf.Threads = parseOpts.General.Threads
 	conf.Timeout = parseOpts.HTTP.Timeout
@@ -541,6 +820,161 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 	conf.Verbose = parseOpts.General.Verbose
 	conf.Json = parseOpts.General.Json
 	conf.Http2 = parseOpts.HTTP.Http2
+	conf.Markov = parseOpts.Markov.Enabled
+	conf.MarkovProto = parseOpts.Markov.Proto
+	conf.MarkovHeaders = parseOpts.Markov.Headers
+	conf.MarkovCookie = parseOpts.Markov.Cookie
+	conf.MarkovCookieReward = parseOpts.Markov.CookieReward
+	conf.MarkovRedirectReward = parseOpts.Markov.RedirectReward
+	if _, err := markov.ParseStateGranularity(parseOpts.Markov.Granularity); err != nil {
+		errs.Add(fmt.Errorf("Unknown Markov state granularity (-markov-granularity): %s, valid values are: coarse, default, fine", parseOpts.Markov.Granularity))
+	} else {
+		conf.MarkovGranularity = parseOpts.Markov.Granularity
+	}
+	conf.MarkovWordlistOut = parseOpts.Markov.WordlistOut
+	conf.MarkovSeedTarget = parseOpts.Markov.SeedTarget
+	if len(parseOpts.Markov.SeedHistory) > 0 {
+		if !FileExists(parseOpts.Markov.SeedHistory) {
+			errs.Add(fmt.Errorf("Markov seed history file (-markov-seed-history) does not exist: %s", parseOpts.Markov.SeedHistory))
+		} else {
+			conf.MarkovSeedHistory = parseOpts.Markov.SeedHistory
+		}
+	}
+	if len(parseOpts.Markov.KnownGood) > 0 {
+		if !FileExists(parseOpts.Markov.KnownGood) {
+			errs.Add(fmt.Errorf("Markov known good paths file (-markov-known-good) does not exist: %s", parseOpts.Markov.KnownGood))
+		} else {
+			conf.MarkovKnownGood = parseOpts.Markov.KnownGood
+		}
+	}
+	conf.MarkovExportWeights = parseOpts.Markov.ExportWeights
+	if parseOpts.Markov.ExportAggregate != markov.WeightsMax && parseOpts.Markov.ExportAggregate != markov.WeightsMean {
+		errs.Add(fmt.Errorf("Markov weights aggregation (-markov-export-aggregate) must be %s or %s", markov.WeightsMax, markov.WeightsMean))
+	} else {
+		conf.MarkovExportAggregate = parseOpts.Markov.ExportAggregate
+	}
+	conf.MarkovTimeoutReward = parseOpts.Markov.TimeoutReward
+	conf.MarkovConnErrorReward = parseOpts.Markov.ConnErrorReward
+	conf.MarkovSave = parseOpts.Markov.Save
+	if len(parseOpts.Markov.Load) > 0 {
+		if !FileExists(parseOpts.Markov.Load) {
+			errs.Add(fmt.Errorf("Markov chain file (-markov-load) does not exist: %s", parseOpts.Markov.Load))
+		} else {
+			conf.MarkovLoad = parseOpts.Markov.Load
+		}
+	}
+	if parseOpts.Markov.Cooldown < 0 {
+		errs.Add(fmt.Errorf("Markov cooldown (-markov-cooldown) must not be negative"))
+	} else {
+		conf.MarkovCooldown = parseOpts.Markov.Cooldown
+	}
+	if _, err := markov.ParseStatusSet(parseOpts.Markov.CooldownStatus); err != nil {
+		errs.Add(fmt.Errorf("Bad Markov cooldown status codes (-markov-cooldown-status): %s", err))
+	} else {
+		conf.MarkovCooldownStatus = parseOpts.Markov.CooldownStatus
+	}
+	if len(parseOpts.Markov.CooldownUA) > 0 {
+		if !FileExists(parseOpts.Markov.CooldownUA) {
+			errs.Add(fmt.Errorf("Markov cooldown User-Agent file (-markov-cooldown-ua) does not exist: %s", parseOpts.Markov.CooldownUA))
+		} else {
+			conf.MarkovCooldownUA = parseOpts.Markov.CooldownUA
+		}
+	}
+	if parseOpts.Markov.PatternMax < 0 {
+		errs.Add(fmt.Errorf("Markov pattern expansion (-markov-pattern-max) must not be negative"))
+	} else {
+		conf.MarkovPatternMax = parseOpts.Markov.PatternMax
+	}
+	if parseOpts.Markov.Neighbors < 0 {
+		errs.Add(fmt.Errorf("Markov neighbors (-markov-neighbors) must not be negative"))
+	} else {
+		conf.MarkovNeighbors = parseOpts.Markov.Neighbors
+	}
+	if parseOpts.Markov.Top < 0 || parseOpts.Markov.Top > markov.DefaultBatchSize {
+		errs.Add(fmt.Errorf("Markov top (-markov-top) must be between 0 and the batch size of %d", markov.DefaultBatchSize))
+	} else {
+		conf.MarkovTop = parseOpts.Markov.Top
+	}
+	if parseOpts.Markov.ColdBudget < 0 {
+		errs.Add(fmt.Errorf("Markov cold state budget (-markov-cold-budget) must not be negative"))
+	} else {
+		conf.MarkovColdBudget = parseOpts.Markov.ColdBudget
+	}
+	if len(parseOpts.Markov.MaxMemory) > 0 {
+		conf.MarkovMaxMemory, err = parseByteSize(parseOpts.Markov.MaxMemory)
+		if err != nil {
+			errs.Add(fmt.Errorf("Markov memory limit (-markov-max-memory) needs to be a size, for example: 256MB or 1GB"))
+		}
+	}
+	if parseOpts.Markov.ClassQuota < 0 {
+		errs.Add(fmt.Errorf("Markov class quota (-markov-class-quota) must not be negative"))
+	} else {
+		conf.MarkovClassQuota = parseOpts.Markov.ClassQuota
+	}
+	if parseOpts.Markov.UpdateBuffer < 0 {
+		errs.Add(fmt.Errorf("Markov update buffer (-markov-update-buffer) must not be negative"))
+	} else {
+		conf.MarkovUpdateBuffer = parseOpts.Markov.UpdateBuffer
+	}
+	if len(parseOpts.Markov.RewardExpr) > 0 {
+		if _, err := markov.CompileRewardExpr(parseOpts.Markov.RewardExpr); err != nil {
+			errs.Add(fmt.Errorf("Invalid Markov reward expression (-markov-reward-expr): %s", err))
+		} else {
+			conf.MarkovRewardExpr = parseOpts.Markov.RewardExpr
+		}
+	}
+	if _, err := markov.ParseBucketizer(parseOpts.Markov.SizeBuckets); err != nil {
+		errs.Add(fmt.Errorf("Unknown Markov size buckets (-markov-size-buckets): %s, valid values are: log, adaptive", parseOpts.Markov.SizeBuckets))
+	} else {
+		conf.MarkovSizeBuckets = parseOpts.Markov.SizeBuckets
+	}
+	if parseOpts.Markov.PrefixThreshold < 0 {
+		errs.Add(fmt.Errorf("Markov prefix threshold (-markov-prefix-threshold) must not be negative"))
+	} else {
+		conf.MarkovPrefixThreshold = parseOpts.Markov.PrefixThreshold
+	}
+	conf.MarkovPrefixRecursion = parseOpts.Markov.PrefixRecursion
+	if parseOpts.Markov.Threshold < 0 {
+		errs.Add(fmt.Errorf("Markov threshold (-markov-threshold) must not be negative"))
+	} else {
+		conf.MarkovThreshold = parseOpts.Markov.Threshold
+	}
+	conf.MarkovReflectReward = parseOpts.Markov.ReflectReward
+	if _, err := markov.ParsePhases(parseOpts.Markov.Phases); err != nil {
+		errs.Add(fmt.Errorf("Invalid Markov phases (-markov-phases): %s", err))
+	} else {
+		conf.MarkovPhases = parseOpts.Markov.Phases
+	}
+	if parseOpts.Markov.DurationCV < 0 {
+		errs.Add(fmt.Errorf("Markov duration variation (-markov-duration-cv) must not be negative"))
+	} else {
+		conf.MarkovDurationCV = parseOpts.Markov.DurationCV
+	}
+	if parseOpts.Markov.UniqueEntropy < 0 {
+		errs.Add(fmt.Errorf("Markov unique token entropy (-markov-unique-entropy) must not be negative"))
+	} else {
+		conf.MarkovUniqueEntropy = parseOpts.Markov.UniqueEntropy
+	}
+	conf.MarkovNormalize = parseOpts.Markov.Normalize
+	if parseOpts.Markov.DryRun < 0 {
+		errs.Add(fmt.Errorf("Markov dry run (-markov-dry-run) must not be negative"))
+	} else {
+		conf.MarkovDryRun = parseOpts.Markov.DryRun
+	}
+	conf.MarkovDryRunOut = parseOpts.Markov.DryRunOut
+	conf.MarkovStackFilter = parseOpts.Markov.StackFilter
+	if parseOpts.Markov.FinalPass < 0 {
+		errs.Add(fmt.Errorf("Markov final pass (-markov-final-pass) must not be negative"))
+	} else {
+		conf.MarkovFinalPass = parseOpts.Markov.FinalPass
+	}
+	conf.MarkovSync = parseOpts.Markov.Sync
+	if len(parseOpts.Markov.SyncInterval) > 0 {
+		conf.MarkovSyncInterval, err = time.ParseDuration(parseOpts.Markov.SyncInterval)
+		if err != nil || conf.MarkovSyncInterval <= 0 {
+			errs.Add(fmt.Errorf("Markov sync interval (-markov-sync-interval) needs to be a valid duration, for example: 30s or 5m"))
+		}
+	}
 
 	// Check that fmode and mmode have sane values
 	valid_opmodes := []string{"and", "or"}
@@ -565,10 +999,31 @@ func ConfigFromOptions(parseOpts *ConfigOptions, ctx context.Context, cancel con
 	conf.FilterMode = parseOpts.Filter.Mode
 	conf.MatcherMode = parseOpts.Matcher.Mode
 
+	conf.CheckpointDir = parseOpts.General.CheckpointDir
+	if len(parseOpts.General.CheckpointInterval) > 0 {
+		conf.CheckpointInterval, err = time.ParseDuration(parseOpts.General.CheckpointInterval)
+		if err != nil || conf.CheckpointInterval <= 0 {
+			errs.Add(fmt.Errorf("Checkpoint interval (-checkpoint-interval) needs to be a valid duration, for example: 30s or 5m"))
+		}
+	}
+	if len(parseOpts.General.ResumeCheckpoint) > 0 {
+		if _, _, err := LoadCheckpoint(parseOpts.General.ResumeCheckpoint); err != nil {
+			errs.Add(fmt.Errorf("Could not read the checkpoint to resume (-resume-checkpoint): %s", err))
+		}
+	}
+	conf.ResumeCheckpoint = parseOpts.General.ResumeCheckpoint
+
 	if conf.AutoCalibrationPerHost {
 		// AutoCalibrationPerHost implies AutoCalibration
 		conf.AutoCalibration = true
 	}
+	if conf.AutoCalibrationPerRoot {
+		// AutoCalibrationPerRoot implies AutoCalibration
+		conf.AutoCalibration = true
+		if conf.AutoCalibrationPerHost {
+			errs.Add(fmt.Errorf("Per host (-ach) and per recursion root (-acr) autocalibration cannot be used together"))
+		}
+	}
 
 	// Handle copy as curl situation where POST method is implied by --data flag. If method is set to anything but GET, NOOP
 	if len(conf.Data) > 0 &&
@@ -693,9 +1148,136 @@ func parseRawRequest(parseOpts *ConfigOptions, conf *Config) error {
 	} else if strings.HasSuffix(conf.Data, "\n") {
 		conf.Data = conf.Data[:len(conf.Data)-1]
 	}
+
+	// Detect where in the request the keywords are injected
+	for _, provider := range conf.InputProviders {
+		if loc := keywordLocation(provider.Keyword, parts[1], conf); loc != "" {
+			conf.KeywordLocations[provider.Keyword] = loc
+		}
+	}
 	return nil
 }
 
+// keywordLocation returns the location of the first injection point of the keyword in a raw request:
+// "path", "query-name", "query-value", "header:<name>", "body:<JSON pointer>" for a field value of a JSON body or "body". Returns an
+// empty string if the keyword is not found.
+func keywordLocation(keyword string, target string, conf *Config) string {
+	// Strip the scheme and host of a full URL in the request line
+	if strings.HasPrefix(target, "http") {
+		if i := strings.Index(target, "://"); i >= 0 {
+			target = target[i+3:]
+			if j := strings.Index(target, "/"); j >= 0 {
+				target = target[j:]
+			} else {
+				target = "/"
+			}
+		}
+	}
+	pathquery := strings.SplitN(target, "?", 2)
+	if strings.Contains(pathquery[0], keyword) {
+		return "path"
+	}
+	if len(pathquery) == 2 {
+		if loc := queryKeywordLocation(keyword, pathquery[1]); loc != "" {
+			return loc
+		}
+	}
+	headers := make([]string, 0, len(conf.Headers))
+	for name := range conf.Headers {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	for _, name := range headers {
+		if strings.Contains(name, keyword) || strings.Contains(conf.Headers[name], keyword) {
+			return "header:" + name
+		}
+	}
+	if strings.Contains(conf.Data, keyword) {
+		if pointer, _, ok := jsonKeywordPointer(keyword, conf.Data); ok && pointer != "" {
+			return "body:" + pointer
+		}
+		return "body"
+	}
+	return ""
+}
+
+// queryKeywordLocation returns "query-name" if the keyword is first found in the name of a parameter of the query
+// string, "query-value" if it is first found in a value, and an empty string if it is not in the query string
+func queryKeywordLocation(keyword string, query string) string {
+	for _, param := range strings.Split(query, "&") {
+		nameValue := strings.SplitN(param, "=", 2)
+		if strings.Contains(nameValue[0], keyword) {
+			return "query-name"
+		}
+		if len(nameValue) == 2 && strings.Contains(nameValue[1], keyword) {
+			return "query-value"
+		}
+	}
+	return ""
+}
+
+// readProxyList reads the proxy urls from a file, one per line. Empty lines and comments are skipped.
+func readProxyList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open proxy list (-x-list): %s", err)
+	}
+	defer file.Close()
+
+	proxies := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		u, err := url.Parse(line)
+		if err != nil || u.Opaque != "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+			return nil, fmt.Errorf("Bad proxy url in the proxy list (-x-list): %s. Expected http, https or socks5 url", line)
+		}
+		proxies = append(proxies, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Could not read proxy list (-x-list): %s", err)
+	}
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("Proxy list (-x-list) does not contain any proxies")
+	}
+	return proxies, nil
+}
+
+func readHeaderPool(path string) ([]HeaderPreset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open header pool (-header-pool): %s", err)
+	}
+	presets := make([]HeaderPreset, 0)
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return nil, fmt.Errorf("Could not parse header pool (-header-pool): %s", err)
+	}
+	if len(presets) < 2 {
+		return nil, fmt.Errorf("Header pool (-header-pool) needs at least two presets to choose from")
+	}
+	for i := range presets {
+		if len(presets[i].Headers) == 0 {
+			return nil, fmt.Errorf("Header pool (-header-pool) preset %d does not set any headers", i+1)
+		}
+		if presets[i].Name == "" {
+			presets[i].Name = fmt.Sprintf("%d", i+1)
+		}
+	}
+	return presets, nil
+}
+
+// parseShard parses the k/n shard of -input-shard, k being between 1 and n
+func parseShard(shard string) (int, int, error) {
+	var k, n int
+	if _, err := fmt.Sscanf(shard, "%d/%d", &k, &n); err != nil || fmt.Sprintf("%d/%d", k, n) != shard || k < 1 || k > n {
+		return 0, 0, fmt.Errorf("Input shard (-input-shard) needs to be in the format k/n with k between 1 and n, for example: 2/3")
+	}
+	return k, n, nil
+}
+
 func keywordPresent(keyword string, conf *Config) bool {
 	//Search for keyword from HTTP method, URL and POST data too
 	if strings.Contains(conf.Method, keyword) {
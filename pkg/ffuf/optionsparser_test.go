@@ -1,6 +1,9 @@
 package ffuf
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -178,3 +181,173 @@ func TestReplayProxyParsing(t *testing.T) {
 		t.Errorf("Expected proxy string with unsupported protocol to fail")
 	}
 }
+
+func TestRawRequestKeywordLocations(t *testing.T) {
+	opts := NewConfigOptions()
+	opts.Input.Request = "testdata/raw_request_locations.txt"
+	conf := NewConfig(context.Background(), func() {})
+	for _, kw := range []string{"PATHFUZZ", "QUERYFUZZ", "COOKIEFUZZ", "BODYFUZZ", "MISSINGFUZZ"} {
+		conf.InputProviders = append(conf.InputProviders, InputProviderConfig{Name: "wordlist", Keyword: kw})
+	}
+	if err := parseRawRequest(opts, &conf); err != nil {
+		t.Fatalf("Could not parse the raw request: %s", err)
+	}
+
+	expected := map[string]string{
+		"PATHFUZZ":   "path",
+		"QUERYFUZZ":  "query-value",
+		"COOKIEFUZZ": "header:Cookie",
+		"BODYFUZZ":   "body",
+	}
+	if len(conf.KeywordLocations) != len(expected) {
+		t.Errorf("Expected %d keyword locations, got %v", len(expected), conf.KeywordLocations)
+	}
+	for kw, loc := range expected {
+		if conf.KeywordLocations[kw] != loc {
+			t.Errorf("Keyword %s: expected location %s, got %s", kw, loc, conf.KeywordLocations[kw])
+		}
+	}
+}
+
+func TestKeywordLocationFullUrl(t *testing.T) {
+	conf := &Config{Headers: map[string]string{"Host": "example.com"}}
+	if loc := keywordLocation("FUZZ", "https://FUZZ.example.com/", conf); loc != "" {
+		t.Errorf("Keyword in the host of a full URL should not be reported as path, got %s", loc)
+	}
+	if loc := keywordLocation("FUZZ", "https://example.com/a?b=FUZZ", conf); loc != "query-value" {
+		t.Errorf("Expected query value location in a full URL, got %s", loc)
+	}
+}
+
+func TestQueryKeywordLocation(t *testing.T) {
+	for _, test := range []struct {
+		query    string
+		expected string
+	}{
+		{"FUZZ=1", "query-name"},
+		{"a=1&FUZZ", "query-name"},
+		{"debug=FUZZ", "query-value"},
+		{"a=1&b=x-FUZZ&c", "query-value"},
+		{"a=1", ""},
+	} {
+		if loc := queryKeywordLocation("FUZZ", test.query); loc != test.expected {
+			t.Errorf("%s: expected location %q, got %q", test.query, test.expected, loc)
+		}
+	}
+}
+
+func TestUrlQueryKeywordLocations(t *testing.T) {
+	for _, test := range []struct {
+		url      string
+		expected string
+	}{
+		{"http://example.com/?FUZZ=1", "query-name"},
+		{"http://example.com/?debug=FUZZ", "query-value"},
+		// The path locations of -u are left unset
+		{"http://example.com/FUZZ?debug=1", ""},
+	} {
+		opts := NewConfigOptions()
+		opts.HTTP.URL = test.url
+		opts.Input.Wordlists = []string{"testdata/methods.txt"}
+		conf, err := ConfigFromOptions(opts, context.Background(), func() {})
+		if err != nil {
+			t.Fatalf("%s: could not parse the options: %s", test.url, err)
+		}
+		if loc := conf.KeywordLocations["FUZZ"]; loc != test.expected {
+			t.Errorf("%s: expected location %q, got %q", test.url, test.expected, loc)
+		}
+	}
+}
+
+func TestProxyListParsing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxies.txt")
+	if err := os.WriteFile(path, []byte("# upstream proxies\nhttp://127.0.0.1:8080\n\nsocks5://127.0.0.1:1080\n"), 0600); err != nil {
+		t.Fatalf("Could not write the proxy list: %s", err)
+	}
+	proxies, err := readProxyList(path)
+	if err != nil {
+		t.Fatalf("Could not read the proxy list: %s", err)
+	}
+	if len(proxies) != 2 || proxies[0] != "http://127.0.0.1:8080" || proxies[1] != "socks5://127.0.0.1:1080" {
+		t.Errorf("Unexpected proxies: %v", proxies)
+	}
+
+	if err := os.WriteFile(path, []byte("http://127.0.0.1:8080\nftp://127.0.0.1\n"), 0600); err != nil {
+		t.Fatalf("Could not write the proxy list: %s", err)
+	}
+	if _, err := readProxyList(path); err == nil {
+		t.Errorf("Expected an error for an invalid proxy url")
+	}
+}
+
+func TestHeaderPoolParsing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "headers.json")
+	pool := `[{"name": "firefox", "headers": {"User-Agent": "Mozilla/5.0 Firefox/128.0"}},
+		{"headers": {"User-Agent": "curl/8.0", "Accept-Language": "de"}}]`
+	if err := os.WriteFile(path, []byte(pool), 0600); err != nil {
+		t.Fatalf("Could not write the header pool: %s", err)
+	}
+	presets, err := readHeaderPool(path)
+	if err != nil {
+		t.Fatalf("Could not read the header pool: %s", err)
+	}
+	if len(presets) != 2 || presets[0].Name != "firefox" || presets[1].Name != "2" || presets[1].Headers["Accept-Language"] != "de" {
+		t.Errorf("Unexpected presets: %v", presets)
+	}
+
+	for _, invalid := range []string{`[{"name": "firefox", "headers": {"User-Agent": "Firefox"}}]`, `[{"name": "a"}, {"name": "b"}]`, `{"User-Agent": "curl"}`} {
+		if err := os.WriteFile(path, []byte(invalid), 0600); err != nil {
+			t.Fatalf("Could not write the header pool: %s", err)
+		}
+		if _, err := readHeaderPool(path); err == nil {
+			t.Errorf("Expected an error for the header pool %s", invalid)
+		}
+	}
+}
+
+func TestMethodKeywordLocation(t *testing.T) {
+	opts := NewConfigOptions()
+	opts.HTTP.URL = "http://example.com/FUZZ"
+	opts.HTTP.Method = "METHOD"
+	opts.Input.Wordlists = []string{"testdata/methods.txt:METHOD", "testdata/methods.txt:FUZZ"}
+	conf, _ := ConfigFromOptions(opts, context.Background(), func() {})
+	if conf.KeywordLocations["METHOD"] != "method" {
+		t.Errorf("Expected the METHOD keyword to be located in the method, got %v", conf.KeywordLocations)
+	}
+	if _, ok := conf.KeywordLocations["FUZZ"]; ok {
+		t.Errorf("Expected the FUZZ keyword not to be located in the method, got %v", conf.KeywordLocations)
+	}
+}
+
+func TestShardParsing(t *testing.T) {
+	if k, n, err := parseShard("2/3"); err != nil || k != 2 || n != 3 {
+		t.Errorf("Expected the shard 2/3, got %d/%d (%v)", k, n, err)
+	}
+	for _, invalid := range []string{"0/3", "4/3", "1/0", "2", "a/b", "1/3x", "-1/3"} {
+		if _, _, err := parseShard(invalid); err == nil {
+			t.Errorf("Expected an error for the shard %s", invalid)
+		}
+	}
+}
+
+func TestClientCertificateParsing(t *testing.T) {
+	for _, test := range []struct {
+		cert, key, ca string
+	}{
+		{"testdata/missing.pem", "", ""},
+		{"", "testdata/missing.key", ""},
+		{"", "", "testdata/missing.pem"},
+		{"testdata/missing.pem", "testdata/missing.key", ""},
+	} {
+		opts := NewConfigOptions()
+		opts.HTTP.URL = "http://example.com/FUZZ"
+		opts.Input.Wordlists = []string{"testdata/methods.txt"}
+		opts.HTTP.ClientCert = test.cert
+		opts.HTTP.ClientKey = test.key
+		opts.HTTP.ClientCA = test.ca
+		_, err := ConfigFromOptions(opts, context.Background(), func() {})
+		if err == nil || !strings.Contains(err.Error(), "client") {
+			t.Errorf("Expected a client certificate error for %v, got %v", test, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ffuf
+
+import (
+	"fmt"
+	"path"
+)
+
+// PendingInput is an input waiting to be sent, along with why it is at its place in the queue
+type PendingInput struct {
+	Input    map[string][]byte
+	Priority string
+}
+
+// PendingInputs returns up to n of the inputs the job will send next: the requeued inputs with their origin, then
+// the rest of the current batch of the Markov chain with its decisions. Nil if the Markov chain is not enabled.
+func (j *Job) PendingInputs(n int) []PendingInput {
+	if j.MarkovChain == nil {
+		return nil
+	}
+	pending := make([]PendingInput, 0)
+	j.requeueMutex.Lock()
+	for _, r := range j.requeued {
+		if len(pending) == n {
+			break
+		}
+		pending = append(pending, PendingInput{Input: r.input, Priority: r.origin})
+	}
+	j.requeueMutex.Unlock()
+	inputs, decisions := j.MarkovChain.Pending(n - len(pending))
+	for idx, input := range inputs {
+		pending = append(pending, PendingInput{Input: input, Priority: decisions[idx]})
+	}
+	return pending
+}
+
+// DropPendingInputs drops the requeued inputs and the inputs of the current batch of the Markov chain having a value
+// matching the glob pattern, and returns their number. The inputs dropped from the batch are left out of the total
+// of the progress.
+func (j *Job) DropPendingInputs(pattern string) (int, error) {
+	if j.MarkovChain == nil {
+		return 0, fmt.Errorf("Pending inputs are only queued with -markov")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return 0, fmt.Errorf("Invalid pattern %s: %s", pattern, err)
+	}
+	match := func(input map[string][]byte) bool {
+		for k, v := range input {
+			if k == "FFUFHASH" {
+				continue
+			}
+			if matched, _ := path.Match(pattern, string(v)); matched {
+				return true
+			}
+		}
+		return false
+	}
+	j.requeueMutex.Lock()
+	kept := make([]requeuedInput, 0, len(j.requeued))
+	for _, r := range j.requeued {
+		if !match(r.input) {
+			kept = append(kept, r)
+		}
+	}
+	dropped := len(j.requeued) - len(kept)
+	j.requeued = kept
+	j.requeueMutex.Unlock()
+	return dropped + j.MarkovChain.DropPending(match), nil
+}
@@ -0,0 +1,85 @@
+package ffuf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+func TestDropPendingInputs(t *testing.T) {
+	conf := NewConfig(context.Background(), func() {})
+	conf.Markov = true
+	j := NewJob(&conf)
+	j.Input = &sliceInput{words: []string{"index", "old-admin", "login", "backup.old", "contact"}}
+	j.MarkovChain = markov.NewMarkovInputProvider(j.Input, markov.State{CodeClass: "4xx"}, "", 0)
+
+	if input, _, _, _, ok := j.nextInput(); !ok || string(input["FUZZ"]) != "index" {
+		t.Fatalf("Expected index to be sent first, got %s", input["FUZZ"])
+	}
+	j.requeue(map[string][]byte{"FUZZ": []byte("api_v3.old")}, "generated-pattern")
+	j.requeue(map[string][]byte{"FUZZ": []byte("api_v4")}, "generated-pattern")
+
+	pending := j.PendingInputs(10)
+	expected := []string{"api_v3.old", "api_v4", "old-admin", "login", "backup.old", "contact"}
+	if len(pending) != len(expected) {
+		t.Fatalf("Expected %d pending inputs, got %d", len(expected), len(pending))
+	}
+	for idx, p := range pending {
+		if string(p.Input["FUZZ"]) != expected[idx] {
+			t.Errorf("Expected %s pending at %d, got %s", expected[idx], idx, p.Input["FUZZ"])
+		}
+	}
+	if pending[0].Priority != "generated-pattern" || pending[2].Priority != "wordlist order" {
+		t.Errorf("Unexpected priorities %q and %q", pending[0].Priority, pending[2].Priority)
+	}
+	if len(j.PendingInputs(3)) != 3 {
+		t.Errorf("Expected the pending inputs to be limited to 3")
+	}
+
+	if _, err := j.DropPendingInputs("[a-"); err == nil {
+		t.Errorf("Expected an error for an invalid pattern")
+	}
+	dropped, err := j.DropPendingInputs("*.old")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if dropped != 2 {
+		t.Errorf("Expected 2 pending inputs to be dropped, got %d", dropped)
+	}
+	// Only the input dropped from the wordlist is left out of the total
+	if total := j.inputSource().Total(); total != 4 {
+		t.Errorf("Expected a total of 4 inputs after dropping, got %d", total)
+	}
+
+	sent := make([]string, 0)
+	for {
+		input, _, _, _, ok := j.nextInput()
+		if !ok {
+			break
+		}
+		sent = append(sent, string(input["FUZZ"]))
+	}
+	expected = []string{"api_v4", "old-admin", "login", "contact"}
+	if len(sent) != len(expected) {
+		t.Fatalf("Expected the inputs %v to be sent, got %v", expected, sent)
+	}
+	for idx := range sent {
+		if sent[idx] != expected[idx] {
+			t.Errorf("Expected the inputs %v to be sent, got %v", expected, sent)
+			break
+		}
+	}
+}
+
+func TestPendingInputsWithoutMarkov(t *testing.T) {
+	conf := NewConfig(context.Background(), func() {})
+	j := NewJob(&conf)
+	j.Input = &sliceInput{words: []string{"index"}}
+	if pending := j.PendingInputs(10); pending != nil {
+		t.Errorf("Expected no pending inputs without the Markov chain, got %v", pending)
+	}
+	if _, err := j.DropPendingInputs("*"); err == nil {
+		t.Errorf("Expected an error when dropping without the Markov chain")
+	}
+}
@@ -0,0 +1,35 @@
+package ffuf
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// handlePrefixSuggestions reports the path prefixes newly suggested by the Markov chain as recursion roots, queueing
+// a job fuzzing under each of them with -markov-prefix-recursion
+func (j *Job) handlePrefixSuggestions() {
+	for _, s := range j.MarkovChain.NewPrefixSuggestions() {
+		msg := fmt.Sprintf("Markov prefix suggestion: fuzz under %s", s)
+		j.Output.Info(msg)
+		log.Printf("%s", msg)
+		if j.Config.MarkovPrefixRecursion {
+			j.queuePrefixJob(s.Prefix)
+		}
+	}
+}
+
+// queuePrefixJob adds a job fuzzing under a path prefix to the queue, if the maximum recursion depth was not reached
+func (j *Job) queuePrefixJob(prefix string) {
+	if !strings.Contains(j.Config.Url, "FUZZ") {
+		return
+	}
+	recUrl := strings.Replace(j.Config.Url, "FUZZ", prefix+"/FUZZ", 1)
+	if j.Config.RecursionDepth > 0 && j.currentDepth >= j.Config.RecursionDepth {
+		j.Output.Warning(fmt.Sprintf("Maximum recursion depth reached. Ignoring the prefix suggestion: %s", recUrl))
+		return
+	}
+	newJob := QueueJob{Url: recUrl, depth: j.currentDepth + 1, req: RecursionRequest(j.Config, recUrl)}
+	j.enqueue(newJob)
+	j.Output.Info(fmt.Sprintf("Adding a new job to the queue: %s", recUrl))
+}
@@ -0,0 +1,235 @@
+//go:build integration
+// +build integration
+
+package ffuf_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+	"github.com/ffuf/ffuf/v2/pkg/filter"
+	"github.com/ffuf/ffuf/v2/pkg/input"
+	"github.com/ffuf/ffuf/v2/pkg/runner"
+)
+
+// The prioritization harness runs the same wordlist against a deterministic tree with and without the Markov chain,
+// and checks that the chain finds the existing paths earlier than the wordlist order. Run it with:
+//
+//	go test -tags integration ./pkg/ffuf -run TestPrioritization -args -prioritization-margin 0.2
+
+var (
+	prioritizationMargin = flag.Float64("prioritization-margin", 0.25, "Share of the sequential median discovery index the Markov chain needs to improve it by")
+	prioritizationOut    = flag.String("prioritization-out", filepath.Join(os.TempDir(), "ffuf-prioritization"), "Directory to write the discovery curves to, as CSV")
+)
+
+const (
+	treeStems     = 300 // stems of the wordlist, each with all the variants
+	treeFamilies  = 12  // stems existing on the target, with all their variants
+	treeWordsSeed = 1
+)
+
+// treeVariants are the variants of each stem in the wordlist
+var treeVariants = []string{"%s", "%s.php", "%s.bak", "%s_old", "%s2", "%s.txt", "%s-backup", "%s.old", "%s_new", "%s1"}
+
+// treeStem returns a pronounceable stem of the tree
+func treeStem(r *rand.Rand) string {
+	consonants, vowels := "bcdfghklmnprstvz", "aeiou"
+	var b strings.Builder
+	for i := 0; i < 3; i++ {
+		b.WriteByte(consonants[r.Intn(len(consonants))])
+		b.WriteByte(vowels[r.Intn(len(vowels))])
+	}
+	return b.String()
+}
+
+// deterministicTree returns the shuffled wordlist of the tree and the paths existing on the target
+func deterministicTree() ([]string, map[string]bool) {
+	r := rand.New(rand.NewSource(treeWordsSeed))
+	seen := make(map[string]bool)
+	stems := make([]string, 0, treeStems)
+	for len(stems) < treeStems {
+		if s := treeStem(r); !seen[s] {
+			seen[s] = true
+			stems = append(stems, s)
+		}
+	}
+	words := make([]string, 0, treeStems*len(treeVariants))
+	existing := make(map[string]bool)
+	for i, stem := range stems {
+		for _, v := range treeVariants {
+			word := fmt.Sprintf(v, stem)
+			words = append(words, word)
+			if i < treeFamilies {
+				existing[word] = true
+			}
+		}
+	}
+	r.Shuffle(len(words), func(i, j int) { words[i], words[j] = words[j], words[i] })
+	return words, existing
+}
+
+// treeServer serves the existing paths of the tree and logs the order of the requests
+type treeServer struct {
+	existing map[string]bool
+	log      []string
+	mutex    sync.Mutex
+}
+
+func (s *treeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	s.mutex.Lock()
+	s.log = append(s.log, path)
+	s.mutex.Unlock()
+	if !s.existing[path] {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "not found")
+		return
+	}
+	fmt.Fprintf(w, "<html><body><h1>%s</h1>%s</body></html>", path, strings.Repeat("content ", len(path)*20))
+}
+
+// discardOutput is an OutputProvider discarding everything
+type discardOutput struct{}
+
+func (o discardOutput) Banner()                                 {}
+func (o discardOutput) Finalize() error                         { return nil }
+func (o discardOutput) Progress(status ffuf.Progress)           {}
+func (o discardOutput) Info(infostring string)                  {}
+func (o discardOutput) Error(errstring string)                  {}
+func (o discardOutput) Raw(output string)                       {}
+func (o discardOutput) Warning(warnstring string)               {}
+func (o discardOutput) Result(resp ffuf.Response)               {}
+func (o discardOutput) PrintResult(res ffuf.Result)             {}
+func (o discardOutput) SaveFile(filename, format string) error  { return nil }
+func (o discardOutput) GetCurrentResults() []ffuf.Result        { return nil }
+func (o discardOutput) SetCurrentResults(results []ffuf.Result) {}
+func (o discardOutput) Reset()                                  {}
+func (o discardOutput) Cycle()                                  {}
+
+// runTree runs the wordlist against a fresh tree server and returns its request log
+func runTree(t *testing.T, wordlist string, existing map[string]bool, markov bool) []string {
+	server := &treeServer{existing: existing}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	opts := ffuf.NewConfigOptions()
+	opts.HTTP.URL = ts.URL + "/FUZZ"
+	opts.Input.Wordlists = []string{wordlist}
+	opts.General.Threads = 1
+	opts.General.Quiet = true
+	opts.General.Noninteractive = true
+	opts.Markov.Enabled = markov
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conf, err := ffuf.ConfigFromOptions(opts, ctx, cancel)
+	if err != nil {
+		t.Fatalf("Could not create the configuration: %s", err)
+	}
+	conf.MatcherManager = filter.NewMatcherManager()
+	if err := conf.MatcherManager.AddMatcher("status", "200"); err != nil {
+		t.Fatalf("Could not add the matcher: %s", err)
+	}
+
+	job := ffuf.NewJob(conf)
+	var errs ffuf.Multierror
+	job.Input, errs = input.NewInputProvider(conf)
+	if errs.ErrorOrNil() != nil {
+		t.Fatalf("Could not create the input provider: %s", errs.ErrorOrNil())
+	}
+	job.Runner = runner.NewRunnerByName("http", conf, false)
+	job.Output = discardOutput{}
+	job.Start()
+	return server.log
+}
+
+// discoveryCurve returns the number of existing paths found after each request of the log, and the request index of
+// the first request of each of them
+func discoveryCurve(log []string, existing map[string]bool) ([]int, []int) {
+	found := make(map[string]bool)
+	curve := make([]int, len(log))
+	indexes := make([]int, 0, len(existing))
+	for i, path := range log {
+		if existing[path] && !found[path] {
+			found[path] = true
+			indexes = append(indexes, i+1)
+		}
+		curve[i] = len(found)
+	}
+	return curve, indexes
+}
+
+// medianIndex returns the median of the discovery indexes, the paths never found counting as found after the last
+// request
+func medianIndex(indexes []int, total int, requests int) float64 {
+	all := append([]int{}, indexes...)
+	for len(all) < total {
+		all = append(all, requests+1)
+	}
+	sort.Ints(all)
+	if len(all)%2 == 1 {
+		return float64(all[len(all)/2])
+	}
+	return float64(all[len(all)/2-1]+all[len(all)/2]) / 2
+}
+
+// writeCurves writes the discovery curves of both runs to a CSV file
+func writeCurves(t *testing.T, sequential, markov []int) string {
+	if err := os.MkdirAll(*prioritizationOut, 0750); err != nil {
+		t.Fatalf("Could not create the output directory: %s", err)
+	}
+	filename := filepath.Join(*prioritizationOut, "discovery.csv")
+	var b strings.Builder
+	b.WriteString("request,sequential,markov\n")
+	for i := 0; i < len(sequential) || i < len(markov); i++ {
+		fmt.Fprintf(&b, "%d,%s,%s\n", i+1, curvePoint(sequential, i), curvePoint(markov, i))
+	}
+	if err := os.WriteFile(filename, []byte(b.String()), 0640); err != nil {
+		t.Fatalf("Could not write the discovery curves: %s", err)
+	}
+	return filename
+}
+
+func curvePoint(curve []int, i int) string {
+	if i >= len(curve) {
+		return ""
+	}
+	return fmt.Sprint(curve[i])
+}
+
+func TestPrioritizationBeatsSequentialOrder(t *testing.T) {
+	words, existing := deterministicTree()
+	wordlist := filepath.Join(t.TempDir(), "wordlist.txt")
+	if err := os.WriteFile(wordlist, []byte(strings.Join(words, "\n")+"\n"), 0640); err != nil {
+		t.Fatalf("Could not write the wordlist: %s", err)
+	}
+	ffuf.HISTORYDIR = t.TempDir()
+
+	sequentialLog := runTree(t, wordlist, existing, false)
+	markovLog := runTree(t, wordlist, existing, true)
+	sequentialCurve, sequentialIndexes := discoveryCurve(sequentialLog, existing)
+	markovCurve, markovIndexes := discoveryCurve(markovLog, existing)
+	t.Logf("Discovery curves written to %s", writeCurves(t, sequentialCurve, markovCurve))
+
+	if len(sequentialIndexes) != len(existing) {
+		t.Fatalf("Expected the sequential run to find the %d paths, found %d", len(existing), len(sequentialIndexes))
+	}
+	sequential := medianIndex(sequentialIndexes, len(existing), len(sequentialLog))
+	markov := medianIndex(markovIndexes, len(existing), len(markovLog))
+	t.Logf("Median discovery index: sequential %.1f over %d requests, markov %.1f over %d requests, %d of %d paths found",
+		sequential, len(sequentialLog), markov, len(markovLog), len(markovIndexes), len(existing))
+	if markov > sequential*(1-*prioritizationMargin) {
+		t.Errorf("Expected the Markov chain to improve the median discovery index %.1f by %.0f%%, got %.1f",
+			sequential, *prioritizationMargin*100, markov)
+	}
+}
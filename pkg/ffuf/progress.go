@@ -12,4 +12,13 @@ type Progress struct {
 	QueuePos   int
 	QueueTotal int
 	ErrorCount int
+	Allocation string // current allocation of the requests across the wordlists in bandit mode
+	Duplicates int64  // inputs skipped as they were sent before
+	// FinalPass and FinalPassTotal are the number of requests of the Markov final pass sent and queued
+	FinalPass      int
+	FinalPassTotal int
+	// Stall is the stall score of the latest requests, see DiscoveryRates
+	Stall float64
+	// Phase is the phase of the Markov chain with -markov-phases, empty without phases
+	Phase string
 }
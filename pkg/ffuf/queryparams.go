@@ -0,0 +1,16 @@
+package ffuf
+
+// requeueQueryValues queues the value candidates of the Markov chain ahead of the wordlist when FUZZ is the value of
+// a query parameter, like ?debug=FUZZ, for the chain to learn how the parameter takes the flags and the privileged
+// values a wordlist of names does not have. The names of the parameters, like ?FUZZ=1, only get the wordlist.
+func (j *Job) requeueQueryValues() {
+	if j.MarkovChain == nil || j.Config.KeywordLocations["FUZZ"] != "query-value" {
+		return
+	}
+	if keywords := j.Input.Keywords(); len(keywords) != 1 || keywords[0] != "FUZZ" {
+		return
+	}
+	for _, value := range j.MarkovChain.ValueCandidates() {
+		j.requeue(map[string][]byte{"FUZZ": []byte(value)}, "query-value")
+	}
+}
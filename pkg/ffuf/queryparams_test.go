@@ -0,0 +1,27 @@
+package ffuf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+func TestRequeueQueryValues(t *testing.T) {
+	for location, expected := range map[string]int{"query-value": len(markov.QueryValueCandidates), "query-name": 0, "path": 0} {
+		conf := NewConfig(context.Background(), func() {})
+		conf.Markov = true
+		conf.KeywordLocations["FUZZ"] = location
+		j := NewJob(&conf)
+		j.Input = &sliceInput{words: []string{"admin"}}
+		j.MarkovChain = markov.NewMarkovInputProvider(j.Input, markov.State{CodeClass: "4xx"}, "", 0)
+		j.MarkovChain.SetKeywordLocations(conf.KeywordLocations)
+		j.requeueQueryValues()
+		pending := j.PendingInputs(100)
+		if len(pending) != expected {
+			t.Errorf("%s: expected %d pending inputs, got %d", location, expected, len(pending))
+		} else if len(pending) > 0 && (string(pending[0].Input["FUZZ"]) != markov.QueryValueCandidates[0] || pending[0].Priority != "query-value") {
+			t.Errorf("%s: expected the value candidates to be queued, got %v", location, pending[0])
+		}
+	}
+}
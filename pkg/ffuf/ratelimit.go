@@ -0,0 +1,151 @@
+package ffuf
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitEvent is a 429 response telling the job when to come back, in a Retry-After or X-RateLimit-Reset header
+type RateLimitEvent struct {
+	Time   time.Time
+	Header string        // header the wait was read from
+	Value  string        // value of the header
+	Wait   time.Duration // wait the header asks for, before the -rate-limit-max-wait cap
+	Paused time.Duration // pause of the job, capped by -rate-limit-max-wait
+}
+
+func (e RateLimitEvent) String() string {
+	return fmt.Sprintf("%s: %s asking to wait %s, paused for %s", e.Header, e.Value, e.Wait, e.Paused)
+}
+
+// rateLimitPause is the pause of the job honoring the rate limit headers, shared by its workers. It is kept apart
+// from the interactive pause, which it neither ends nor overrides.
+type rateLimitPause struct {
+	mutex sync.Mutex
+	until time.Time // end of the current pause
+}
+
+// wait blocks until the end of the current pause, if any, or until the context is done
+func (p *rateLimitPause) wait(ctx context.Context) {
+	for {
+		p.mutex.Lock()
+		remaining := time.Until(p.until)
+		p.mutex.Unlock()
+		if remaining <= 0 || ctx.Err() != nil {
+			return
+		}
+		select {
+		case <-time.After(remaining):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// RecordRateLimit records a 429 response the job paused for, for the rate adjustments to take into account
+func (r *RateThrottle) RecordRateLimit(event RateLimitEvent) {
+	r.RateMutex.Lock()
+	defer r.RateMutex.Unlock()
+	r.rateLimitEvents = append(r.rateLimitEvents, event)
+}
+
+// RateLimitEvents returns the 429 responses the job paused for, oldest first
+func (r *RateThrottle) RateLimitEvents() []RateLimitEvent {
+	r.RateMutex.Lock()
+	defer r.RateMutex.Unlock()
+	return append([]RateLimitEvent(nil), r.rateLimitEvents...)
+}
+
+// epochThreshold tells the Unix timestamps from the numbers of seconds in an X-RateLimit-Reset header: a wait of
+// more than a year is a timestamp
+const epochThreshold = 365 * 24 * 60 * 60
+
+// rateLimitWait returns the wait a response asks for in its Retry-After header, in seconds or as an HTTP-date, or in
+// its X-RateLimit-Reset header, in seconds or as a Unix timestamp in seconds or milliseconds, along with the header
+// it was read from. The dates are compared to the Date header of the response when present, the clock of the server
+// being the one they are relative to, and to now otherwise. A date already past is no wait.
+func rateLimitWait(headers map[string][]string, now time.Time) (time.Duration, string, string, bool) {
+	h := http.Header(headers)
+	if date, err := http.ParseTime(h.Get("Date")); err == nil {
+		now = date
+	}
+	if value := strings.TrimSpace(h.Get("Retry-After")); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			return secondsDuration(seconds), "Retry-After", value, true
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return positive(at.Sub(now)), "Retry-After", value, true
+		}
+	}
+	if value := strings.TrimSpace(h.Get("X-RateLimit-Reset")); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			if seconds < epochThreshold {
+				return secondsDuration(seconds), "X-RateLimit-Reset", value, true
+			}
+			if seconds > 1000*float64(now.Unix()) {
+				// Milliseconds since the epoch
+				seconds /= 1000
+			}
+			at := time.Unix(0, int64(seconds*float64(time.Second)))
+			return positive(at.Sub(now)), "X-RateLimit-Reset", value, true
+		}
+	}
+	return 0, "", "", false
+}
+
+// secondsDuration converts a number of seconds to a duration, the ones too large for a duration to the largest
+func secondsDuration(seconds float64) time.Duration {
+	if seconds >= float64(1<<63-1)/float64(time.Second) {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func positive(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// honorRateLimit pauses the requests until the time a 429 response asks to come back at, up to -rate-limit-max-wait.
+// The workers getting a 429 while the requests are paused extend the pause rather than pausing again.
+func (j *Job) honorRateLimit(resp Response) {
+	wait, header, value, ok := rateLimitWait(resp.Headers, time.Now())
+	if !ok {
+		return
+	}
+	paused := wait
+	if paused > j.Config.RateLimitMaxWait {
+		paused = j.Config.RateLimitMaxWait
+	}
+	event := RateLimitEvent{Time: time.Now(), Header: header, Value: value, Wait: wait, Paused: paused}
+	j.Rate.RecordRateLimit(event)
+	j.metrics.incRateLimited(paused)
+	if j.Config.Verbose {
+		j.Output.Info(fmt.Sprintf("Rate limited on %s, %s", resp.Request.Url, event))
+	}
+	if paused <= 0 {
+		return
+	}
+
+	until := event.Time.Add(paused)
+	j.rateLimit.mutex.Lock()
+	pausing := j.rateLimit.until.After(event.Time)
+	if until.After(j.rateLimit.until) {
+		j.rateLimit.until = until
+	}
+	j.rateLimit.mutex.Unlock()
+	if pausing {
+		// The worker pausing the job waits until the extended end of the pause
+		return
+	}
+
+	j.Output.Warning(fmt.Sprintf("Rate limited by the target, pausing for %s as asked by its %s header", paused, header))
+	j.rateLimit.wait(j.Config.Context)
+	j.Output.Info("Rate limit pause over, resuming the requests")
+}
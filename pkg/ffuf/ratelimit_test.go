@@ -0,0 +1,135 @@
+package ffuf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitWait(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	// The clock of the server is an hour ahead of the local one
+	server := now.Add(time.Hour)
+	tests := []struct {
+		path     string
+		headers  map[string]string
+		expected time.Duration
+		header   string
+	}{
+		{"/seconds", map[string]string{"Retry-After": "2"}, 2 * time.Second, "Retry-After"},
+		{"/date", map[string]string{"Retry-After": server.Add(3 * time.Second).Format(http.TimeFormat), "Date": server.Format(http.TimeFormat)}, 3 * time.Second, "Retry-After"},
+		{"/date-nodate", map[string]string{"Retry-After": now.Add(4 * time.Second).Format(http.TimeFormat)}, 4 * time.Second, "Retry-After"},
+		{"/date-past", map[string]string{"Retry-After": server.Add(-time.Minute).Format(http.TimeFormat), "Date": server.Format(http.TimeFormat)}, 0, "Retry-After"},
+		{"/reset-delta", map[string]string{"X-RateLimit-Reset": "5"}, 5 * time.Second, "X-RateLimit-Reset"},
+		{"/reset-epoch", map[string]string{"X-RateLimit-Reset": strconv.FormatInt(server.Unix()+6, 10), "Date": server.Format(http.TimeFormat)}, 6 * time.Second, "X-RateLimit-Reset"},
+		{"/reset-epoch-ms", map[string]string{"X-RateLimit-Reset": strconv.FormatInt(now.UnixMilli()+7500, 10)}, 7500 * time.Millisecond, "X-RateLimit-Reset"},
+		{"/both", map[string]string{"Retry-After": "8", "X-RateLimit-Reset": "60"}, 8 * time.Second, "Retry-After"},
+		{"/bogus", map[string]string{"Retry-After": "soon", "X-RateLimit-Reset": "9"}, 9 * time.Second, "X-RateLimit-Reset"},
+		{"/none", nil, 0, ""},
+	}
+	byPath := make(map[string]map[string]string)
+	for _, tt := range tests {
+		byPath[tt.path] = tt.headers
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server adds a Date header of the local clock unless told not to
+		w.Header()["Date"] = nil
+		for k, v := range byPath[r.URL.Path] {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	for _, tt := range tests {
+		resp, err := http.Get(ts.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		wait, header, _, ok := rateLimitWait(resp.Header, now)
+		if ok != (tt.header != "") || wait != tt.expected || header != tt.header {
+			t.Errorf("%s: expected a wait of %s from %q, got %s from %q", tt.path, tt.expected, tt.header, wait, header)
+		}
+	}
+}
+
+func TestRateLimitPause(t *testing.T) {
+	tests := []struct {
+		name     string
+		verbose  bool
+		maxWait  time.Duration
+		headers  []map[string][]string
+		messages []string
+		wait     time.Duration
+	}{
+		// A 429 without a header telling when to come back does not pause the job
+		{"capped", true, 50 * time.Millisecond, []map[string][]string{{"Retry-After": {"3600"}}, {}},
+			[]string{"Retry-After: 3600 asking to wait 1h0m0s, paused for 50ms", "pausing for 50ms", "resuming the requests"}, time.Hour},
+		{"no-pause", false, 0, []map[string][]string{{"X-Ratelimit-Reset": {"30"}}}, nil, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		conf := NewConfig(context.Background(), func() {})
+		conf.MatcherManager = &fakeMatcherManager{}
+		conf.Verbose = tt.verbose
+		conf.RateLimitMaxWait = tt.maxWait
+		j := NewJob(&conf)
+		out := &logOutput{}
+		j.Output = out
+		// The target answers every request with a 429 and the headers of its turn
+		sent := 0
+		j.Runner = newFakeRunner(func(req *Request, resp *Response) error {
+			resp.StatusCode, resp.Headers = 429, tt.headers[sent]
+			sent++
+			return nil
+		})
+		j.queuejobs = append(j.queuejobs, QueueJob{req: BaseRequest(&conf)})
+		j.queuepos = 1
+
+		start := time.Now()
+		for i := range tt.headers {
+			j.runTask(map[string][]byte{"FUZZ": []byte("word")}, i+1, "", "")
+		}
+		if elapsed := time.Since(start); elapsed < tt.maxWait {
+			t.Errorf("%s: expected the job paused for the -rate-limit-max-wait of %s, got %s", tt.name, tt.maxWait, elapsed)
+		}
+		messages := strings.Join(out.messages, "\n")
+		if len(tt.messages) == 0 && len(out.messages) != 0 {
+			t.Errorf("%s: expected no pause, got %v", tt.name, out.messages)
+		}
+		for _, e := range tt.messages {
+			if !strings.Contains(messages, e) {
+				t.Errorf("%s: expected %q shown, got %v", tt.name, e, out.messages)
+			}
+		}
+		events := j.Rate.RateLimitEvents()
+		if len(events) != 1 || events[0].Wait != tt.wait || events[0].Paused != tt.maxWait {
+			t.Errorf("%s: expected the rate limited response recorded with the capped pause, got %v", tt.name, events)
+		}
+		if j.metrics.rateLimited != 1 || time.Duration(j.metrics.rateLimitWait) != tt.maxWait {
+			t.Errorf("%s: expected a single rate limited response counted, got %d for %s", tt.name, j.metrics.rateLimited, time.Duration(j.metrics.rateLimitWait))
+		}
+	}
+}
+
+func TestRateLimitPauseKeepsInteractivePause(t *testing.T) {
+	conf := NewConfig(context.Background(), func() {})
+	conf.RateLimitMaxWait = 20 * time.Millisecond
+	j := NewJob(&conf)
+	j.Output = &logOutput{}
+	// Paused by the user before the 429 comes in
+	j.Pause()
+	req := BaseRequest(&conf)
+	j.honorRateLimit(Response{StatusCode: 429, Headers: map[string][]string{"Retry-After": {"1"}}, Request: &req})
+	if !j.Paused {
+		t.Errorf("Expected the job to stay paused by the user after the rate limit pause")
+	}
+	j.Resume()
+	if j.Paused {
+		t.Errorf("Expected the user to resume the job")
+	}
+}
@@ -0,0 +1,39 @@
+package ffuf
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	// ReflectionMinLength is the length a fuzzed value needs for its presence in a response to be taken as a
+	// reflection, the shorter values being found in most responses by chance
+	ReflectionMinLength = 4
+	// ReflectionBodyLimit is the number of bytes of the body searched for the reflections
+	ReflectionBodyLimit = 1 << 20
+)
+
+// Reflects reports whether a fuzzed value of the input, of at least ReflectionMinLength bytes, appears verbatim in the
+// body or in a header value of the response. Only the first ReflectionBodyLimit bytes of the body are searched.
+func (resp *Response) Reflects(input map[string][]byte) bool {
+	body := resp.Data
+	if len(body) > ReflectionBodyLimit {
+		body = body[:ReflectionBodyLimit]
+	}
+	for keyword, value := range input {
+		if keyword == "FFUFHASH" || len(value) < ReflectionMinLength {
+			continue
+		}
+		if bytes.Contains(body, value) {
+			return true
+		}
+		for _, values := range resp.Headers {
+			for _, v := range values {
+				if strings.Contains(v, string(value)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
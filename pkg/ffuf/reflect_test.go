@@ -0,0 +1,30 @@
+package ffuf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResponseReflects(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		input    map[string][]byte
+		body     string
+		headers  map[string][]string
+		expected bool
+	}{
+		{"body", map[string][]byte{"FUZZ": []byte("x7Kq2")}, "<p>No results for x7Kq2</p>", nil, true},
+		{"header", map[string][]byte{"FUZZ": []byte("x7Kq2")}, "", map[string][]string{"Location": {"/search?q=x7Kq2"}}, true},
+		{"none", map[string][]byte{"FUZZ": []byte("x7Kq2")}, "<p>No results</p>", map[string][]string{"Server": {"nginx"}}, false},
+		// The short values are in most responses by chance
+		{"short", map[string][]byte{"FUZZ": []byte("p")}, "<p>No results</p>", nil, false},
+		{"hash", map[string][]byte{"FUZZ": []byte("none"), "FFUFHASH": []byte("abcdef")}, "abcdef", nil, false},
+		// The body is only searched up to the limit
+		{"limit", map[string][]byte{"FUZZ": []byte("x7Kq2")}, strings.Repeat("a", ReflectionBodyLimit) + "x7Kq2", nil, false},
+	} {
+		resp := Response{Data: []byte(test.body), Headers: test.headers}
+		if got := resp.Reflects(test.input); got != test.expected {
+			t.Errorf("%s: expected reflection %t, got %t", test.name, test.expected, got)
+		}
+	}
+}
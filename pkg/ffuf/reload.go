@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package ffuf
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// reloadMonitor merges the chain file given with -markov-load into the live Markov chain on SIGUSR1
+func (j *Job) reloadMonitor() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGUSR1)
+	go func() {
+		for range sigChan {
+			if len(j.Config.MarkovLoad) == 0 {
+				j.Output.Warning("Caught SIGUSR1 but no Markov chain file was given with -markov-load")
+				continue
+			}
+			j.ReloadMarkovChain(j.Config.MarkovLoad)
+		}
+	}()
+}
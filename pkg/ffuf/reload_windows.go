@@ -0,0 +1,7 @@
+//go:build windows
+// +build windows
+
+package ffuf
+
+// reloadMonitor is a no-op on Windows, which has no SIGUSR1. The chain can be reloaded from the interactive console.
+func (j *Job) reloadMonitor() {}
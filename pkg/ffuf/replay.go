@@ -0,0 +1,113 @@
+package ffuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ReplayReport is the part of the JSON report of a previous run needed to replay its results with -replay-from
+type ReplayReport struct {
+	Config struct {
+		Url          string            `json:"url"`
+		Method       string            `json:"method"`
+		Headers      map[string]string `json:"headers"`
+		Data         string            `json:"postdata"`
+		InputMode    string            `json:"inputmode"`
+		RequestProto string            `json:"requestproto"`
+	} `json:"config"`
+	Results []struct {
+		Input  map[string]string `json:"input"`
+		Reward float64           `json:"reward"`
+	} `json:"results"`
+}
+
+// ReadReplayReport reads a report written with -of json
+func ReadReplayReport(path string) (*ReplayReport, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report ReplayReport
+	if err := json.Unmarshal(content, &report); err != nil {
+		return nil, fmt.Errorf("Could not parse the JSON report %s: %s", path, err)
+	}
+	return &report, nil
+}
+
+// Inputs returns the inputs of the results rewarded at least minReward, in the order of the report
+func (r *ReplayReport) Inputs(minReward float64) []map[string]string {
+	inputs := make([]map[string]string, 0)
+	for _, res := range r.Results {
+		if res.Reward < minReward {
+			continue
+		}
+		input := make(map[string]string, len(res.Input))
+		for k, v := range res.Input {
+			if k != "FFUFHASH" {
+				input[k] = v
+			}
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+// Url returns the URL of the requests of the report. The URL of a raw request read with -request is rebuilt
+// from its Host header if the report has it relative.
+func (r *ReplayReport) Url() string {
+	if strings.Contains(r.Config.Url, "://") || r.Config.Headers["Host"] == "" {
+		return r.Config.Url
+	}
+	proto := r.Config.RequestProto
+	if proto == "" {
+		proto = "https"
+	}
+	return proto + "://" + r.Config.Headers["Host"] + r.Config.Url
+}
+
+// prepareReplay sets up the input providers replaying the results of a previous run rewarded at least
+// -replay-min-reward, along with the request of the run unless -u or -request is given
+func prepareReplay(parseOpts *ConfigOptions, conf *Config) error {
+	if len(parseOpts.Input.Wordlists) > 0 || len(parseOpts.Input.Inputcommands) > 0 {
+		return fmt.Errorf("Replay (-replay-from) cannot be combined with -w or -input-cmd")
+	}
+	report, err := ReadReplayReport(parseOpts.Input.ReplayFrom)
+	if err != nil {
+		return err
+	}
+	if report.Config.InputMode == "sniper" {
+		return fmt.Errorf("Replay (-replay-from) of a sniper mode run is not supported")
+	}
+	inputs := report.Inputs(parseOpts.Input.ReplayMinReward)
+	if len(inputs) == 0 {
+		return fmt.Errorf("No result of %s has a reward of at least %g to replay", parseOpts.Input.ReplayFrom, parseOpts.Input.ReplayMinReward)
+	}
+	conf.ReplayFrom = parseOpts.Input.ReplayFrom
+	conf.ReplayMinReward = parseOpts.Input.ReplayMinReward
+	if len(parseOpts.HTTP.URL) == 0 && parseOpts.Input.Request == "" {
+		conf.Url = report.Url()
+		conf.Method = report.Config.Method
+		conf.Data = report.Config.Data
+		for k, v := range report.Config.Headers {
+			conf.Headers[k] = v
+		}
+	}
+	// The keywords of each result are replayed together
+	conf.InputMode = "pitchfork"
+	keywords := make([]string, 0, len(inputs[0]))
+	for k := range inputs[0] {
+		keywords = append(keywords, k)
+	}
+	sort.Strings(keywords)
+	for _, k := range keywords {
+		conf.InputProviders = append(conf.InputProviders, InputProviderConfig{
+			Name:    "replay",
+			Value:   parseOpts.Input.ReplayFrom,
+			Keyword: k,
+		})
+	}
+	return nil
+}
@@ -7,16 +7,25 @@ import (
 
 // Request holds the meaningful data that is passed for runner for making the query
 type Request struct {
-	Method    string
-	Host      string
-	Url       string
-	Headers   map[string]string
-	Data      []byte
-	Input     map[string][]byte
-	Position  int
-	Raw       string
-	Error     string
-	Timestamp time.Time
+	Method   string
+	Host     string
+	Url      string
+	Headers  map[string]string
+	Data     []byte
+	Input    map[string][]byte
+	Position int
+	Raw      string
+	Error    string
+	// ErrorClass is the class of the Error of a request that failed without a response, written along with it to the
+	// audit log. Empty when the request got a response.
+	ErrorClass ErrorClass
+	Timestamp  time.Time
+	Preset     string // name of the -header-pool preset the request was sent with
+	Origin     string // why the job queued the input itself, like "generated-pattern". Empty for the input provider
+	Decision   string // why the Markov chain sent an input of the input provider at its place, like "wordlist order"
+	// AllowRetry is called before each retry of a request failing on a transport error, the request not being
+	// retried when it returns false. Nil retries up to -retries times.
+	AllowRetry func() bool `json:"-"`
 }
 
 func NewRequest(conf *Config) Request {
@@ -0,0 +1,140 @@
+package ffuf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+// requeuedInput is an input queued by the job itself, like the tokens generated from the patterns of the matches
+type requeuedInput struct {
+	input    map[string][]byte
+	position int // position in the input provider, 0 for the inputs not drawn from it
+	origin   string
+}
+
+// requeue queues an input to be sent before the next one of the input provider. The origin is recorded in the
+// request and shown in the results. The reward of the input is credited to the matched tokens it was derived from,
+// the seeds, if any. Inputs known to have been sent already are dropped.
+func (j *Job) requeue(input map[string][]byte, origin string, seeds ...string) {
+	if !j.markSent(input, true) {
+		return
+	}
+	j.requeueMutex.Lock()
+	defer j.requeueMutex.Unlock()
+	j.requeued = append(j.requeued, requeuedInput{input: input, origin: origin})
+	if len(seeds) > 0 {
+		if j.derivedSeeds == nil {
+			j.derivedSeeds = make(map[uint64][]string)
+		}
+		j.derivedSeeds[j.sentKey(input)] = seeds
+	}
+}
+
+// creditSeeds credits the reward of an input to the seeds it was derived from, logging the seeds retired in
+// verbose mode
+func (j *Job) creditSeeds(input map[string][]byte, reward float64) {
+	key := j.sentKey(input)
+	j.requeueMutex.Lock()
+	seeds, ok := j.derivedSeeds[key]
+	delete(j.derivedSeeds, key)
+	j.requeueMutex.Unlock()
+	if !ok {
+		return
+	}
+	for _, r := range j.MarkovChain.RecordSeedUse(seeds, reward) {
+		if j.Config.Verbose {
+			j.Output.Info(fmt.Sprintf("Markov seed retired: %s", r))
+		}
+	}
+}
+
+// nextInput returns the next input to send along with its position, origin and the decision of the Markov chain,
+// the requeued inputs coming first. Requeued inputs have no position in the input provider and return 0, unless
+// restored from a checkpoint.
+func (j *Job) nextInput() (map[string][]byte, int, string, string, bool) {
+	j.requeueMutex.Lock()
+	if len(j.requeued) > 0 {
+		next := j.requeued[0]
+		j.requeued = j.requeued[1:]
+		j.requeueMutex.Unlock()
+		return next.input, next.position, next.origin, "", true
+	}
+	j.requeueMutex.Unlock()
+	source := j.inputSource()
+	for source.Next() {
+		input := source.Value()
+		// Skip the words already sent ahead of the wordlist
+		if j.markSent(input, false) {
+			decision := ""
+			if j.MarkovChain != nil {
+				decision = j.MarkovChain.Decision()
+				j.logColdState(decision)
+			}
+			return input, source.Position(), "", decision, true
+		}
+	}
+	return nil, 0, "", "", false
+}
+
+// logColdState logs the Markov chain switching between the cold state policy, the inputs being sent in wordlist
+// order while a never seen state is explored, and the reordering of the inputs
+func (j *Job) logColdState(decision string) {
+	cold := strings.HasPrefix(decision, markov.ColdStateDecision)
+	if cold == j.markovCold {
+		return
+	}
+	j.markovCold = cold
+	if cold {
+		j.Output.Info(fmt.Sprintf("Markov chain in a never seen state, sending up to %d inputs in wordlist order", j.Config.MarkovColdBudget))
+	} else {
+		j.Output.Info("Markov chain done exploring the new state, reordering the inputs")
+	}
+}
+
+// inputSource is the part of an input provider the job draws its inputs from
+type inputSource interface {
+	Next() bool
+	Value() map[string][]byte
+	Position() int
+	Reset()
+	Total() int
+}
+
+// inputSource returns the provider the inputs are drawn from, the Markov chain reordering the batches of the
+// input provider when enabled
+func (j *Job) inputSource() inputSource {
+	if j.MarkovChain != nil {
+		return j.MarkovChain
+	}
+	return j.Input
+}
+
+// requeuePatterns records the FUZZ token of a match, and requeues the tokens generated from the patterns of the
+// best matches that were not expanded yet. Only the token is recorded during the final pass, or for the final pass
+// alone without -markov-pattern-max.
+func (j *Job) requeuePatterns(input map[string][]byte, reward float64) {
+	token, ok := input["FUZZ"]
+	if !ok {
+		return
+	}
+	j.MarkovChain.RecordMatch(string(token), reward)
+	if j.Config.MarkovPatternMax == 0 || j.inFinalPass() {
+		return
+	}
+	patterns, candidates := j.MarkovChain.PatternCandidates(j.Config.MarkovPatternMax)
+	for _, p := range patterns {
+		j.Output.Info(fmt.Sprintf("Markov pattern %s induced from the matches", p))
+	}
+	for _, candidate := range candidates {
+		generated := make(map[string][]byte, len(input))
+		for k, v := range input {
+			if k != "FFUFHASH" {
+				generated[k] = v
+			}
+		}
+		generated["FUZZ"] = []byte(candidate)
+		j.requeue(generated, "generated-pattern", j.MarkovChain.PatternSeeds(candidate)...)
+	}
+}
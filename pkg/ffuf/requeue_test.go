@@ -0,0 +1,48 @@
+package ffuf
+
+import (
+	"fmt"
+	"testing"
+)
+
+// answerVersions returns a handler answering the api_v0 to api_v5 tokens with a 200
+func answerVersions() fakeHandler {
+	return func(req *Request, resp *Response) error {
+		var version int
+		if _, err := fmt.Sscanf(fuzzToken(req), "api_v%d", &version); err == nil && version <= 5 {
+			resp.StatusCode = 200
+		}
+		return nil
+	}
+}
+
+func TestRequeueGeneratedPatterns(t *testing.T) {
+	runner := newFakeRunner(answerVersions())
+	j := newFakeJob(t, runner, []string{"admin", "api_v1", "index", "api_v2", "login"}, func(conf *Config) {
+		conf.MarkovPatternMax = 8
+	})
+	j.Start()
+	origins := runner.origins()
+
+	// api_v\d+ is induced from api_v1 and api_v2, and expanded from api_v0 on
+	for _, token := range []string{"api_v0", "api_v3", "api_v4", "api_v5", "api_v6", "api_v9"} {
+		if origin, ok := origins[token]; !ok || origin != "generated-pattern" {
+			t.Errorf("Expected %s to be generated from the pattern, got %q (sent: %t)", token, origin, ok)
+		}
+	}
+	for _, token := range []string{"admin", "api_v1", "login"} {
+		if origins[token] != "" {
+			t.Errorf("Expected %s to come from the wordlist, got %q", token, origins[token])
+		}
+	}
+	if _, ok := origins["api_v10"]; ok {
+		t.Errorf("Expected the expansion to stop at -markov-pattern-max tokens")
+	}
+	matched := make(map[string]string)
+	for _, resp := range j.Output.(*recordingOutput).responses {
+		matched[string(resp.Request.Input["FUZZ"])] = resp.Request.Origin
+	}
+	if len(matched) != 6 || matched["api_v3"] != "generated-pattern" || matched["api_v2"] != "" {
+		t.Errorf("Unexpected matches: %v", matched)
+	}
+}
@@ -1,8 +1,12 @@
 package ffuf
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -15,13 +19,49 @@ type Response struct {
 	ContentWords  int64
 	ContentLines  int64
 	ContentType   string
+	Encoding      string // Content-Encoding of the body, empty for identity. The counts are the ones of the decoded body
+	WireLength    int64  // bytes of the body read from the connection, before decoding
 	Cancelled     bool
+	Truncated     bool
+	Complete      bool // the whole body was read, not cut off by the size limit or by the server closing early
+	BodyHash      string
 	Request       *Request
 	Raw           string
 	ResultFile    string
 	ScraperData   map[string][]string
-	Duration      time.Duration
+	Duration      time.Duration // time to the first byte of the response, the same as Timing.TTFB
+	Timing        Timing
 	Timestamp     time.Time
+	Proto         string
+	Retries       int
+	Reward        float64
+	CertNames     []string
+	CertHash      string        // SHA-256 of the TLS certificate, empty for plain HTTP
+	Redirects     []RedirectHop // redirects followed to get the response with -r, oldest first
+	Reflected     bool          // a fuzzed value appears in the body or the headers, see Reflects
+}
+
+// RedirectHop is a redirect response followed on the way to the final response
+type RedirectHop struct {
+	StatusCode int64  `json:"status"`
+	Location   string `json:"location"`
+}
+
+// RedirectChain formats the redirects followed to get a response, like "302 /login -> 301 /login/"
+func RedirectChain(hops []RedirectHop) string {
+	parts := make([]string, 0, len(hops))
+	for _, hop := range hops {
+		parts = append(parts, fmt.Sprintf("%d %s", hop.StatusCode, hop.Location))
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// Timing splits the time taken by a request into its phases. The phases skipped by a reused connection are zero.
+type Timing struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration // from the request being written to the first byte of the response
 }
 
 // GetRedirectLocation returns the redirect location for a 3xx redirect HTTP response
@@ -56,6 +96,63 @@ func (resp *Response) GetRedirectLocation(absolute bool) string {
 	return redirectLocation
 }
 
+// InternalRedirects returns true if the response was reached through redirects staying on the host of the request
+func (resp *Response) InternalRedirects() bool {
+	if len(resp.Redirects) == 0 || resp.Request == nil {
+		return false
+	}
+	current, err := url.Parse(resp.Request.Url)
+	if err != nil {
+		return false
+	}
+	host := current.Hostname()
+	for _, hop := range resp.Redirects {
+		next, err := url.Parse(hop.Location)
+		if err != nil {
+			return false
+		}
+		current = current.ResolveReference(next)
+		if !strings.EqualFold(current.Hostname(), host) {
+			return false
+		}
+	}
+	return true
+}
+
+// CertMismatch returns true if the response was served over TLS with a certificate whose DNS names
+// do not cover the requested host, which often means the request was routed to a different backend
+func (resp *Response) CertMismatch() bool {
+	if len(resp.CertNames) == 0 || resp.Request == nil || resp.Request.Host == "" {
+		return false
+	}
+	host := resp.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, name := range resp.CertNames {
+		if certNameMatches(name, host) {
+			return false
+		}
+	}
+	return true
+}
+
+// certNameMatches checks if a certificate DNS name, possibly a wildcard, matches the hostname
+func certNameMatches(name, host string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if name == host {
+		return true
+	}
+	// Wildcards only cover a single leftmost label
+	if strings.HasPrefix(name, "*.") {
+		if i := strings.Index(host, "."); i > 0 {
+			return host[i:] == name[1:]
+		}
+	}
+	return false
+}
+
 func UrlEqual(url1, url2 *url.URL) bool {
 	if url1.Hostname() != url2.Hostname() {
 		return false
@@ -83,6 +180,11 @@ func NewResponse(httpresp *http.Response, req *Request) Response {
 	var resp Response
 	resp.Request = req
 	resp.StatusCode = int64(httpresp.StatusCode)
+	resp.Proto = httpresp.Proto
+	if httpresp.TLS != nil && len(httpresp.TLS.PeerCertificates) > 0 {
+		resp.CertNames = httpresp.TLS.PeerCertificates[0].DNSNames
+		resp.CertHash = fmt.Sprintf("%x", sha256.Sum256(httpresp.TLS.PeerCertificates[0].Raw))
+	}
 	resp.ContentType = httpresp.Header.Get("Content-Type")
 	resp.Headers = httpresp.Header
 	resp.Cancelled = false
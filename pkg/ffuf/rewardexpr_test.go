@@ -0,0 +1,36 @@
+package ffuf
+
+import (
+	"testing"
+)
+
+func TestMarkovRewardExpr(t *testing.T) {
+	// The target answers every request with a 404, the internal paths with an X-Internal header
+	runner := newFakeRunner(func(req *Request, resp *Response) error {
+		resp.ContentLength, resp.Complete = 100, true
+		if fuzzToken(req) == "internal" {
+			resp.Headers["X-Internal"] = []string{"yes"}
+		}
+		return nil
+	})
+	j := newFakeJob(t, runner, []string{"public", "internal", "static"}, func(conf *Config) {
+		conf.MarkovRewardExpr = `header("X-Internal") == "yes" ? 2 + builtin : 0`
+		conf.MatcherManager = matchAll()
+	})
+	j.Start()
+	out := j.Output.(*recordingOutput)
+
+	if len(out.responses) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(out.responses))
+	}
+	for _, resp := range out.responses {
+		expected := 0.0
+		if string(resp.Request.Input["FUZZ"]) == "internal" {
+			// The built-in reward of a 404 like the baseline is 0
+			expected = 2
+		}
+		if resp.Reward != expected {
+			t.Errorf("Expected a reward of %f for %s, got %f", expected, resp.Request.Input["FUZZ"], resp.Reward)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package ffuf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// recursionRoot returns the URL of a queue job up to the first keyword, like http://example.com/a/ for
+// http://example.com/a/FUZZ, the recursion jobs under it having it as a prefix
+func recursionRoot(url string) string {
+	if idx := strings.Index(url, "FUZZ"); idx >= 0 {
+		return url[:idx]
+	}
+	return url
+}
+
+// RecursionRoot returns the recursion root of the current queue job, the scope of the filters added for it
+func (j *Job) RecursionRoot() string {
+	return j.recursionRoot
+}
+
+// ScopedFilterSummary lists the filters scoped to recursion roots along with their root
+func (j *Job) ScopedFilterSummary() []string {
+	scoped := j.Config.MatcherManager.ScopedFilters()
+	roots := make([]string, 0, len(scoped))
+	for root := range scoped {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+	lines := make([]string, 0)
+	for _, root := range roots {
+		names := make([]string, 0, len(scoped[root]))
+		for name := range scoped[root] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			lines = append(lines, fmt.Sprintf("Filter %s scoped to %s: %s", name, root, scoped[root][name].Repr()))
+		}
+	}
+	return lines
+}
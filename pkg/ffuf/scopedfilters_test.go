@@ -0,0 +1,122 @@
+package ffuf
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// answerWildcards returns a handler serving a tree where every path under /a/ answers the same, while only /b/x does
+// so under /b/
+func answerWildcards() fakeHandler {
+	return func(req *Request, resp *Response) error {
+		resp.Complete = true
+		path := strings.TrimPrefix(req.Url, "http://localhost/")
+		switch {
+		case path == "a" || path == "b":
+			resp.StatusCode = 200
+			resp.ContentLength = 10
+		case strings.HasPrefix(path, "a/") || path == "b/x":
+			resp.StatusCode = 200
+			resp.ContentLength = 1234
+		}
+		return nil
+	}
+}
+
+// scopedMatcherManager matches the 200 responses, keeping the size filters scoped to recursion roots
+type scopedMatcherManager struct {
+	fakeMatcherManager
+	filters map[string]map[string]FilterProvider
+	mutex   sync.Mutex
+}
+
+type sizeFilter struct {
+	size int64
+}
+
+func (f sizeFilter) Filter(response *Response) (bool, error) {
+	return response.ContentLength == f.size, nil
+}
+func (f sizeFilter) Repr() string        { return strconv.FormatInt(f.size, 10) }
+func (f sizeFilter) ReprVerbose() string { return f.Repr() }
+
+func (m *scopedMatcherManager) AddScopedFilter(root string, name string, option string, replace bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	size, err := strconv.ParseInt(option, 10, 64)
+	if err != nil {
+		return err
+	}
+	if m.filters[root] == nil {
+		m.filters[root] = make(map[string]FilterProvider)
+	}
+	m.filters[root][name] = sizeFilter{size: size}
+	return nil
+}
+func (m *scopedMatcherManager) FiltersForRoot(root string) map[string]FilterProvider {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	filters := make(map[string]FilterProvider)
+	for r, rf := range m.filters {
+		if strings.HasPrefix(root, r) {
+			for name, f := range rf {
+				filters[name+" "+r] = f
+			}
+		}
+	}
+	return filters
+}
+func (m *scopedMatcherManager) ScopedFilters() map[string]map[string]FilterProvider {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.filters
+}
+
+func TestRecursionRoot(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost/FUZZ":        "http://localhost/",
+		"http://localhost/a/FUZZ":      "http://localhost/a/",
+		"http://localhost/a/FUZZ.php":  "http://localhost/a/",
+		"http://localhost/?q=FUZZ&x=1": "http://localhost/?q=",
+		"http://localhost/no-keyword/": "http://localhost/no-keyword/",
+	}
+	for url, expected := range tests {
+		if got := recursionRoot(url); got != expected {
+			t.Errorf("Expected the recursion root of %s to be %s, got %s", url, expected, got)
+		}
+	}
+}
+
+func TestPerRootAutocalibration(t *testing.T) {
+	mm := &scopedMatcherManager{fakeMatcherManager: *matchStatus(200), filters: make(map[string]map[string]FilterProvider)}
+	j := newFakeJob(t, newFakeRunner(answerWildcards()), []string{"a", "b", "x", "y"}, func(conf *Config) {
+		conf.Markov = false
+		conf.Recursion = true
+		conf.RecursionStrategy = "greedy"
+		conf.RecursionDepth = 1
+		conf.AutoCalibration = true
+		conf.AutoCalibrationPerRoot = true
+		conf.AutoCalibrationStrings = []string{"calib1", "calib2"}
+		conf.MatcherManager = mm
+	})
+	j.Start()
+
+	results := make([]string, 0)
+	for _, resp := range j.Output.(*recordingOutput).responses {
+		results = append(results, resp.Request.Url)
+	}
+	// The wildcard responses of /a/ are filtered, the identical response of /b/x is not
+	expected := "http://localhost/a,http://localhost/b,http://localhost/b/x"
+	if strings.Join(results, ",") != expected {
+		t.Errorf("Expected the results %s, got %s", expected, strings.Join(results, ","))
+	}
+	if len(mm.filters) != 1 || mm.filters["http://localhost/a/"]["size"] == nil {
+		t.Errorf("Expected a size filter scoped to http://localhost/a/ only, got %v", mm.filters)
+	}
+	summary := j.ScopedFilterSummary()
+	if len(summary) != 1 || summary[0] != "Filter size scoped to http://localhost/a/: 1234" {
+		t.Errorf("Unexpected scoped filter summary %v", summary)
+	}
+}
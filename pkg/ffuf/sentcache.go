@@ -0,0 +1,230 @@
+package ffuf
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// sentCacheExact is the number of inputs the sent cache tracks exactly, a few MB of hashes
+	sentCacheExact = 1 << 18
+	// sentCacheBloomBits is the size of the bloom filter tracking the inputs past sentCacheExact, 2MB. It keeps
+	// the false positive rate, dropping generated inputs never sent, under 0.3% for two million more inputs.
+	sentCacheBloomBits = 1 << 24
+	// sentCacheBloomHashes is the number of bits set in the bloom filter for each input
+	sentCacheBloomHashes = 4
+	// sentCacheEntryBytes is the approximate memory used by a hash of the exact set, with the overhead of the map
+	sentCacheEntryBytes = 40
+)
+
+// sentCache remembers the inputs sent by a job, so that the inputs queued by the feedback of the Markov chain and
+// the ones of the wordlist are sent only once. The hashes of the inputs are kept exactly up to a limit, and in a
+// bloom filter past it to bound the memory use. The false positives of the bloom filter only drop generated inputs,
+// never the ones of the wordlist.
+type sentCache struct {
+	exact     map[uint64]struct{}
+	maxExact  int
+	bloom     []uint64
+	bloomBits uint64
+	noBloom   bool // set once the bloom filter was dropped to bound the memory use, the inputs past maxExact going untracked
+	mutex     sync.Mutex
+}
+
+func newSentCache(maxExact int, bloomBits uint64) *sentCache {
+	return &sentCache{exact: make(map[uint64]struct{}), maxExact: maxExact, bloomBits: bloomBits}
+}
+
+// bloomBit returns the bit of the bloom filter for the i-th hash of an input, by double hashing
+func (c *sentCache) bloomBit(h uint64, i uint64) uint64 {
+	return (h + i*(h>>32|1)) % c.bloomBits
+}
+
+// seen returns true if the hash of an input was recorded as sent, in the exact set or, if trustBloom is set, in the
+// bloom filter. Must be called with the mutex held.
+func (c *sentCache) seen(h uint64, trustBloom bool) bool {
+	if _, ok := c.exact[h]; ok {
+		return true
+	}
+	if !trustBloom || c.bloom == nil {
+		return false
+	}
+	for i := uint64(0); i < sentCacheBloomHashes; i++ {
+		bit := c.bloomBit(h, i)
+		if c.bloom[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// contains returns true if the hash of a generated input was recorded as sent, possibly a false positive of the
+// bloom filter
+func (c *sentCache) contains(h uint64) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.seen(h, true)
+}
+
+// add records the hash of a generated input as sent. Returns false if it was sent before, possibly a false positive
+// of the bloom filter.
+func (c *sentCache) add(h uint64) bool {
+	return c.record(h, true)
+}
+
+// addWordlist records the hash of an input of the wordlist as sent. Returns false only if the exact set holds it:
+// a word of the wordlist dropped on a false positive of the bloom filter would never be sent.
+func (c *sentCache) addWordlist(h uint64) bool {
+	return c.record(h, false)
+}
+
+// record records the hash of an input as sent, returning false if it was seen before
+func (c *sentCache) record(h uint64, trustBloom bool) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.seen(h, trustBloom) {
+		return false
+	}
+	if len(c.exact) < c.maxExact {
+		c.exact[h] = struct{}{}
+		return true
+	}
+	if c.noBloom {
+		return true
+	}
+	if c.bloom == nil {
+		c.bloom = make([]uint64, c.bloomBits/64+1)
+	}
+	for i := uint64(0); i < sentCacheBloomHashes; i++ {
+		bit := c.bloomBit(h, i)
+		c.bloom[bit/64] |= 1 << (bit % 64)
+	}
+	return true
+}
+
+// reset forgets the inputs that were sent
+func (c *sentCache) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.exact = make(map[uint64]struct{})
+	c.bloom = nil
+}
+
+// bytes returns the approximate memory used by the cache, in bytes
+func (c *sentCache) bytes() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return int64(len(c.exact))*sentCacheEntryBytes + int64(len(c.bloom))*8
+}
+
+// dropBloom drops the bloom filter for good, the inputs past the exact limit going untracked and possibly sent
+// twice. Returns the number of bytes freed, 0 if it was dropped before.
+func (c *sentCache) dropBloom() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.noBloom {
+		return 0
+	}
+	freed := int64(len(c.bloom)) * 8
+	c.bloom = nil
+	c.noBloom = true
+	return freed
+}
+
+// snapshot returns the content of the cache, for a checkpoint
+func (c *sentCache) snapshot() CheckpointSentCache {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	snap := CheckpointSentCache{Hashes: make([]uint64, 0, len(c.exact))}
+	for h := range c.exact {
+		snap.Hashes = append(snap.Hashes, h)
+	}
+	sort.Slice(snap.Hashes, func(a, b int) bool { return snap.Hashes[a] < snap.Hashes[b] })
+	if c.bloom != nil {
+		snap.Bloom = append(snap.Bloom, c.bloom...)
+	}
+	return snap
+}
+
+// restore replaces the content of the cache with the one of a checkpoint. A bloom filter of another size is left
+// out, the inputs it tracked may be sent again.
+func (c *sentCache) restore(snap CheckpointSentCache) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.exact = make(map[uint64]struct{}, len(snap.Hashes))
+	for _, h := range snap.Hashes {
+		c.exact[h] = struct{}{}
+	}
+	c.bloom = nil
+	if !c.noBloom && uint64(len(snap.Bloom)) == c.bloomBits/64+1 {
+		c.bloom = append([]uint64{}, snap.Bloom...)
+	}
+}
+
+// sentKey returns the hash of an input along with the method and URL of the request of the current queue job,
+// which differ between the positions of sniper mode and the recursion jobs. The values of the path keywords are
+// hashed in lower case once the target was found case-insensitive.
+func (j *Job) sentKey(input map[string][]byte) uint64 {
+	fold := j.MarkovChain != nil && j.MarkovChain.CaseInsensitive()
+	h := fnv.New64a()
+	if j.queuepos > 0 && j.queuepos <= len(j.queuejobs) {
+		req := j.queuejobs[j.queuepos-1].req
+		_, _ = h.Write([]byte(req.Method + "\x00" + req.Url + "\x00"))
+	}
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		if k != "FFUFHASH" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		_, _ = h.Write([]byte(k + "\x00"))
+		if fold && j.Config.KeywordLocations[k] == "path" {
+			_, _ = h.Write(bytes.ToLower(input[k]))
+		} else {
+			_, _ = h.Write(input[k])
+		}
+		_, _ = h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// markSent records an input as sent. Returns false if it was sent before, in which case it should not be sent
+// again. Inputs are only tracked with the Markov chain, whose feedback queues inputs of its own. The inputs of the
+// wordlist are only dropped when known for sure to have been sent, unlike the generated ones.
+func (j *Job) markSent(input map[string][]byte, generated bool) bool {
+	if j.MarkovChain == nil {
+		return true
+	}
+	var added bool
+	if generated {
+		added = j.sent.add(j.sentKey(input))
+	} else {
+		added = j.sent.addWordlist(j.sentKey(input))
+	}
+	if !added {
+		if j.MarkovChain.CaseInsensitive() && j.caseFolded(input) {
+			atomic.AddInt64(&j.metrics.caseDuplicates, 1)
+		} else {
+			atomic.AddInt64(&j.metrics.duplicates, 1)
+		}
+		return false
+	}
+	// Sent words are never suggested as neighbors
+	if keyword, value, ok := inputKeyword(input); ok {
+		if ix := j.neighborIndex(keyword); ix != nil {
+			ix.MarkSent(value)
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package ffuf
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSentCacheBloomTier(t *testing.T) {
+	c := newSentCache(10, 1<<16)
+	for i := uint64(0); i < 100; i++ {
+		if !c.add(i * 7919) {
+			t.Errorf("Expected %d to be new", i)
+		}
+	}
+	if len(c.exact) != 10 || c.bloom == nil {
+		t.Fatalf("Expected the inputs past the limit to go to the bloom filter")
+	}
+	for i := uint64(0); i < 100; i++ {
+		if c.add(i * 7919) {
+			t.Errorf("Expected %d to be known, in the exact set or the bloom filter", i)
+		}
+	}
+	c.reset()
+	if !c.add(7919) {
+		t.Errorf("Expected the cache to be empty after a reset")
+	}
+}
+
+func TestSentKey(t *testing.T) {
+	conf := NewConfig(context.Background(), func() {})
+	j := NewJob(&conf)
+	get := Request{Method: "GET", Url: "http://localhost/§FUZZ§/a"}
+	post := Request{Method: "POST", Url: "http://localhost/§FUZZ§/a"}
+	sniper := Request{Method: "GET", Url: "http://localhost/x/§FUZZ§"}
+	j.queuejobs = []QueueJob{{req: get}, {req: post}, {req: sniper}}
+
+	input := map[string][]byte{"FUZZ": []byte("admin"), "FFUFHASH": []byte("1")}
+	keys := make(map[uint64]bool)
+	for pos := 1; pos <= len(j.queuejobs); pos++ {
+		j.queuepos = pos
+		keys[j.sentKey(input)] = true
+	}
+	if len(keys) != 3 {
+		t.Errorf("Expected the method and URL of the queue job to be part of the key")
+	}
+	if j.sentKey(input) != j.sentKey(map[string][]byte{"FUZZ": []byte("admin"), "FFUFHASH": []byte("2")}) {
+		t.Errorf("Expected FFUFHASH to be left out of the key")
+	}
+	a := j.sentKey(map[string][]byte{"FUZZ": []byte("ab"), "HOST": []byte("c")})
+	b := j.sentKey(map[string][]byte{"FUZZ": []byte("a"), "HOST": []byte("bc")})
+	if a == b {
+		t.Errorf("Expected the values of the keywords to be delimited in the key")
+	}
+}
+
+func TestOverlappingSourcesSentOnce(t *testing.T) {
+	runner := newFakeRunner(answerTokens(map[string]int64{"backup": 200, "api_v1": 200, "api_v2": 200}))
+	j := newFakeJob(t, runner, nil, func(conf *Config) {
+		conf.MarkovNeighbors = 3
+		conf.MarkovPatternMax = 8
+	})
+	// The patterns of api_v1 and api_v2 and the neighbors of backup overlap with the wordlist, which lists admin twice
+	words := []string{"admin", "backup", "api_v1", "api_v2", "backups", "api_v3", "admin", "db-backup", "api_v4", "api_v5"}
+	j.Input = &wordsInput{sliceInput{words: words}}
+	j.Start()
+
+	counts := runner.counts(false)
+	for token, n := range counts {
+		if n != 1 {
+			t.Errorf("Expected %s to be sent once, got %d", token, n)
+		}
+	}
+	for _, word := range words {
+		if counts[word] != 1 {
+			t.Errorf("Expected %s of the wordlist to be sent, got %d", word, counts[word])
+		}
+	}
+	if counts["api_v6"] != 1 {
+		t.Errorf("Expected the pattern candidates to be sent, got %v", runner.tokens())
+	}
+	if j.metrics.duplicates == 0 {
+		t.Errorf("Expected the skipped duplicates to be counted")
+	}
+}
+
+func TestSentCacheBloomCollision(t *testing.T) {
+	// A single bit bloom filter, every input past the exact set colliding with the first one
+	c := newSentCache(1, 1)
+	c.add(1)
+	c.add(2)
+	if c.add(3) {
+		t.Errorf("Expected a generated input hitting the bloom filter to be dropped")
+	}
+	if !c.addWordlist(4) {
+		t.Errorf("Expected an input of the wordlist hitting only the bloom filter to be kept")
+	}
+	if c.addWordlist(1) {
+		t.Errorf("Expected an input of the wordlist in the exact set to be dropped")
+	}
+}
+
+func TestWordlistSentDespiteBloomCollision(t *testing.T) {
+	runner := newFakeRunner(nil)
+	words := []string{"admin", "backup", "login", "index", "config"}
+	j := newFakeJob(t, runner, words, nil)
+	j.sent = newSentCache(1, 1)
+	j.Start()
+
+	counts := runner.counts(false)
+	for _, word := range words {
+		if counts[word] != 1 {
+			t.Errorf("Expected %s of the wordlist to be sent once despite the bloom filter collisions, got %d", word, counts[word])
+		}
+	}
+}
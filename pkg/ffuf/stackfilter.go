@@ -0,0 +1,40 @@
+package ffuf
+
+import (
+	"fmt"
+	"sync"
+)
+
+// stackFilter is the platform of the technology guess handed to the Markov chain with -markov-stack-filter
+type stackFilter struct {
+	platform   string
+	confidence float64
+	mutex      sync.Mutex
+}
+
+// applyStackFilter hands the platform of the technology guess and its confidence to the Markov chain when they
+// change, for the chain to send the tokens of the other stacks later. The tokens are no longer deprioritized once the
+// guess falls under TechGuessMinConfidence.
+func (j *Job) applyStackFilter() {
+	if !j.Config.MarkovStackFilter || j.MarkovChain == nil {
+		return
+	}
+	guess, ok := j.techFingerprint.Guess()
+	if !ok {
+		guess = TechGuess{}
+	}
+	j.stackFilter.mutex.Lock()
+	defer j.stackFilter.mutex.Unlock()
+	if guess.Platform == j.stackFilter.platform && guess.Confidence == j.stackFilter.confidence {
+		return
+	}
+	if guess.Platform != j.stackFilter.platform {
+		if guess.Platform != "" {
+			j.Output.Info(fmt.Sprintf("Target %s, deprioritizing the tokens of the other stacks", guess))
+		} else {
+			j.Output.Info("Technology guess no longer confident, the tokens of the other stacks are not deprioritized")
+		}
+	}
+	j.stackFilter.platform, j.stackFilter.confidence = guess.Platform, guess.Confidence
+	j.MarkovChain.SetStackPreference(guess.Platform, guess.Confidence)
+}
@@ -0,0 +1,92 @@
+package ffuf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+// answerASPNET returns a handler answering like an ASP.NET application on IIS, the .aspx pages found and the other
+// paths not
+func answerASPNET() fakeHandler {
+	return func(req *Request, resp *Response) error {
+		resp.BodyHash = "notfound"
+		resp.Headers = map[string][]string{
+			"Server":       {"Microsoft-IIS/10.0"},
+			"X-Powered-By": {"ASP.NET"},
+			"Set-Cookie":   {"ASP.NET_SessionId=abc; path=/; HttpOnly"},
+		}
+		if token := fuzzToken(req); strings.HasSuffix(token, ".aspx") {
+			resp.StatusCode, resp.BodyHash = 200, token
+		}
+		return nil
+	}
+}
+
+// runStackJob returns the job, the paths in the order they were requested in, and the messages shown
+func runStackJob(t *testing.T, filter bool, words []string) (*Job, []string, *logOutput) {
+	runner := newFakeRunner(answerASPNET())
+	j := newFakeJob(t, runner, words, func(conf *Config) {
+		conf.Quiet = false
+		conf.MarkovStackFilter = filter
+	})
+	out := &logOutput{}
+	j.Output = out
+	j.Start()
+	return j, runner.tokens(), out
+}
+
+// otherStackPosition returns the mean position of the paths of another stack than ASP.NET in the requests sent
+func otherStackPosition(sent []string) float64 {
+	sum, n := 0, 0
+	for i, token := range sent {
+		if stack := markov.TokenStack(token); stack != "" && stack != markov.StackASPNET {
+			sum += i
+			n++
+		}
+	}
+	return float64(sum) / float64(n)
+}
+
+func TestStackFilter(t *testing.T) {
+	others := []string{"wp-admin/%d", "index%d.php", "login%d.jsp", "node_modules/%d"}
+	words := make([]string, 0, 400)
+	for i := 0; i < 400; i++ {
+		switch {
+		case i%5 == 0:
+			words = append(words, fmt.Sprintf("page%d.aspx", i))
+		case i%4 == 3:
+			words = append(words, fmt.Sprintf(others[i/4%len(others)], i))
+		default:
+			words = append(words, fmt.Sprintf("word%d", i))
+		}
+	}
+
+	_, plain, _ := runStackJob(t, false, words)
+	j, filtered, out := runStackJob(t, true, words)
+
+	// Nothing is left out
+	sent := make(map[string]int)
+	for _, token := range filtered {
+		sent[token]++
+	}
+	for _, w := range words {
+		if sent[w] == 0 {
+			t.Errorf("Expected %s sent", w)
+		}
+	}
+	if before, after := otherStackPosition(plain), otherStackPosition(filtered); after <= before {
+		t.Errorf("Expected the paths of the other stacks sent later once the target looks like ASP.NET, got a mean position of %.1f without the filter and %.1f with", before, after)
+	}
+	if total, _ := j.MarkovChain.StackDeprioritized(); total == 0 {
+		t.Errorf("Expected tokens deprioritized")
+	}
+	messages := strings.Join(out.messages, "\n")
+	for _, e := range []string{"Target looks like ASP.NET/IIS", "Markov deprioritized "} {
+		if !strings.Contains(messages, e) {
+			t.Errorf("Expected %q shown, got %v", e, out.messages)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package ffuf
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// TechGuessMinConfidence is the confidence a technology guess needs to be shown
+	TechGuessMinConfidence = 0.6
+	// techMinExtensionMatches is the number of matched inputs with an extension needed for it to count as evidence
+	techMinExtensionMatches = 2
+)
+
+// techSignal is a piece of evidence about the technology of the target, and how much it says about it
+type techSignal struct {
+	key    string // the evidence as recorded by techFingerprint, like "ext:php" or "cookie:phpsessid"
+	label  string // the evidence as shown to the user
+	weight float64
+}
+
+// techRule is a platform and the evidence pointing to it
+type techRule struct {
+	name    string
+	signals []techSignal
+}
+
+// techRules is the rule table of the technology guess. The confidence of a platform is the sum of the weights of
+// its signals observed, up to 1, times its share of the scores of all the platforms so that conflicting evidence
+// lowers it.
+var techRules = []techRule{
+	{name: "PHP", signals: []techSignal{
+		{"ext:php", ".php matches", 0.5},
+		{"powered:php", "X-Powered-By: PHP", 0.6},
+		{"cookie:phpsessid", "PHPSESSID cookie", 0.6},
+	}},
+	{name: "ASP.NET", signals: []techSignal{
+		{"ext:aspx", ".aspx matches", 0.5},
+		{"ext:asp", ".asp matches", 0.4},
+		{"ext:ashx", ".ashx matches", 0.5},
+		{"ext:asmx", ".asmx matches", 0.5},
+		{"powered:asp.net", "X-Powered-By: ASP.NET", 0.6},
+		{"header:x-aspnet-version", "X-AspNet-Version header", 0.6},
+		{"cookie:asp.net_sessionid", "ASP.NET_SessionId cookie", 0.6},
+		{"server:iis", "Server: Microsoft-IIS", 0.3},
+	}},
+	{name: "Java", signals: []techSignal{
+		{"ext:jsp", ".jsp matches", 0.5},
+		{"ext:do", ".do matches", 0.4},
+		{"ext:action", ".action matches", 0.4},
+		{"powered:servlet", "X-Powered-By: Servlet", 0.6},
+		{"cookie:jsessionid", "JSESSIONID cookie", 0.6},
+	}},
+	{name: "Node/Express", signals: []techSignal{
+		{"powered:express", "X-Powered-By: Express", 0.6},
+		{"cookie:connect.sid", "connect.sid cookie", 0.6},
+	}},
+}
+
+// techServers are the web servers named after the platform, by the product of their Server header
+var techServers = map[string]string{
+	"apache":        "Apache",
+	"nginx":         "nginx",
+	"microsoft-iis": "IIS",
+	"openresty":     "nginx",
+	"apache-coyote": "Tomcat",
+}
+
+// TechGuess is a guess of the technology of the target
+type TechGuess struct {
+	Name       string
+	Platform   string // platform of the guess, without the web server and API annotations of its name
+	Confidence float64
+	Evidence   []string
+}
+
+// String returns the guess in a human readable format
+func (g TechGuess) String() string {
+	return fmt.Sprintf("looks like %s (confidence %.0f%%: %s)", g.Name, 100*g.Confidence, strings.Join(g.Evidence, ", "))
+}
+
+// extensionStat is the number of requests sent with an extension and of the matches among them
+type extensionStat struct {
+	requests int
+	matches  int
+}
+
+// techFingerprint gathers the evidence about the technology of the target: the extensions of the matched inputs, the
+// platform headers and session cookies, the Server header and the content types of the matches
+type techFingerprint struct {
+	extensions  map[string]*extensionStat
+	signals     map[string]bool
+	servers     map[string]int
+	matches     int
+	jsonMatches int
+	mutex       sync.Mutex
+}
+
+// record adds the evidence of a response to the fingerprint
+func (f *techFingerprint) record(resp *Response, input map[string][]byte, matched bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.signals == nil {
+		f.extensions = make(map[string]*extensionStat)
+		f.signals = make(map[string]bool)
+		f.servers = make(map[string]int)
+	}
+	if token, ok := input["FUZZ"]; ok {
+		if ext := strings.ToLower(strings.TrimPrefix(path.Ext(string(token)), ".")); ext != "" && !strings.ContainsAny(ext, "/?") {
+			stat, ok := f.extensions[ext]
+			if !ok {
+				stat = &extensionStat{}
+				f.extensions[ext] = stat
+			}
+			stat.requests++
+			if matched {
+				stat.matches++
+			}
+		}
+	}
+	if matched {
+		f.matches++
+		if strings.Contains(strings.ToLower(resp.ContentType), "json") {
+			f.jsonMatches++
+		}
+	}
+	for name, values := range resp.Headers {
+		for _, value := range values {
+			f.recordHeader(strings.ToLower(name), strings.ToLower(value))
+		}
+	}
+}
+
+// recordHeader records the evidence of a response header. Must be called with the mutex held.
+func (f *techFingerprint) recordHeader(name string, value string) {
+	switch name {
+	case "x-powered-by":
+		if product := strings.Fields(strings.SplitN(value, "/", 2)[0]); len(product) > 0 {
+			f.signals["powered:"+product[0]] = true
+		}
+	case "x-aspnet-version", "x-aspnetmvc-version":
+		f.signals["header:x-aspnet-version"] = true
+	case "set-cookie":
+		cookie := strings.TrimSpace(strings.SplitN(value, "=", 2)[0])
+		f.signals["cookie:"+cookie] = true
+	case "server":
+		product := strings.TrimSpace(strings.SplitN(value, "/", 2)[0])
+		if product == "microsoft-iis" {
+			f.signals["server:iis"] = true
+		}
+		if _, ok := techServers[product]; ok {
+			f.servers[product]++
+		}
+	}
+}
+
+// Guess returns the technology guess of the target, false when no platform reaches TechGuessMinConfidence
+func (f *techFingerprint) Guess() (TechGuess, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	observed := make(map[string]bool, len(f.signals))
+	for key := range f.signals {
+		observed[key] = true
+	}
+	for ext, stat := range f.extensions {
+		if stat.matches >= techMinExtensionMatches {
+			observed["ext:"+ext] = true
+		}
+	}
+
+	best := TechGuess{}
+	bestScore, total := 0.0, 0.0
+	for _, rule := range techRules {
+		score := 0.0
+		evidence := make([]string, 0)
+		for _, s := range rule.signals {
+			if observed[s.key] {
+				score += s.weight
+				evidence = append(evidence, s.label)
+			}
+		}
+		if score > 1 {
+			score = 1
+		}
+		total += score
+		if score > bestScore {
+			best, bestScore = TechGuess{Name: rule.name, Platform: rule.name, Evidence: evidence}, score
+		}
+	}
+	if bestScore == 0 {
+		return TechGuess{}, false
+	}
+	best.Confidence = bestScore * bestScore / total
+	if best.Confidence < TechGuessMinConfidence {
+		return TechGuess{}, false
+	}
+
+	// The most common web server
+	server, count := "", 0
+	for product, n := range f.servers {
+		if n > count || (n == count && product < server) {
+			server, count = product, n
+		}
+	}
+	if server != "" {
+		// IIS is already part of the evidence of ASP.NET
+		if server != "microsoft-iis" || best.Name != "ASP.NET" {
+			best.Evidence = append(best.Evidence, "Server: "+techServers[server])
+		}
+		best.Name += "/" + techServers[server]
+	}
+	if f.matches > 0 && 2*f.jsonMatches >= f.matches {
+		best.Name += " API"
+		best.Evidence = append(best.Evidence, fmt.Sprintf("%d of %d matches JSON", f.jsonMatches, f.matches))
+	}
+	return best, true
+}
+
+// ExtensionSummary returns the success statistics of the extensions of the FUZZ inputs, the most matched first
+func (f *techFingerprint) ExtensionSummary() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	exts := make([]string, 0, len(f.extensions))
+	for ext := range f.extensions {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		a, b := f.extensions[exts[i]], f.extensions[exts[j]]
+		if a.matches != b.matches {
+			return a.matches > b.matches
+		}
+		return exts[i] < exts[j]
+	})
+	lines := make([]string, 0, len(exts))
+	for _, ext := range exts {
+		stat := f.extensions[ext]
+		lines = append(lines, fmt.Sprintf("Extension .%s: %d of %d requests matched", ext, stat.matches, stat.requests))
+	}
+	return lines
+}
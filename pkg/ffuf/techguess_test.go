@@ -0,0 +1,92 @@
+package ffuf
+
+import (
+	"strings"
+	"testing"
+)
+
+// techResponse is a response with the given headers, as pairs of name and value
+func techResponse(contentType string, headers ...string) *Response {
+	resp := &Response{StatusCode: 200, ContentType: contentType, Headers: make(map[string][]string)}
+	for i := 0; i+1 < len(headers); i += 2 {
+		resp.Headers[headers[i]] = append(resp.Headers[headers[i]], headers[i+1])
+	}
+	return resp
+}
+
+func TestTechGuess(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		responses []*Response
+		matched   []string
+		want      string
+	}{
+		{
+			name:      "php",
+			responses: []*Response{techResponse("text/html", "Server", "Apache/2.4.57 (Debian)", "X-Powered-By", "PHP/8.2.7"), techResponse("text/html", "Server", "Apache/2.4.57 (Debian)", "Set-Cookie", "PHPSESSID=abc; path=/")},
+			matched:   []string{"index.php", "login.php"},
+			want:      "PHP/Apache",
+		},
+		{
+			name:      "aspnet",
+			responses: []*Response{techResponse("text/html", "Server", "Microsoft-IIS/10.0", "X-AspNet-Version", "4.0.30319"), techResponse("text/html", "Server", "Microsoft-IIS/10.0", "X-Powered-By", "ASP.NET")},
+			matched:   []string{"default.aspx", "admin.aspx"},
+			want:      "ASP.NET/IIS",
+		},
+		{
+			name:      "java",
+			responses: []*Response{techResponse("text/html", "Server", "Apache-Coyote/1.1", "Set-Cookie", "JSESSIONID=F00; Path=/; HttpOnly")},
+			matched:   []string{"index.jsp", "login.jsp"},
+			want:      "Java/Tomcat",
+		},
+		{
+			name:      "express",
+			responses: []*Response{techResponse("application/json; charset=utf-8", "X-Powered-By", "Express"), techResponse("application/json", "Set-Cookie", "connect.sid=s%3Aabc; Path=/")},
+			matched:   []string{"users", "health"},
+			want:      "Node/Express API",
+		},
+	} {
+		var f techFingerprint
+		for _, resp := range tc.responses {
+			for _, token := range tc.matched {
+				f.record(resp, map[string][]byte{"FUZZ": []byte(token)}, true)
+			}
+		}
+		guess, ok := f.Guess()
+		if !ok || guess.Name != tc.want {
+			t.Errorf("%s: expected a guess of %s, got %q (%t)", tc.name, tc.want, guess.Name, ok)
+			continue
+		}
+		if guess.Confidence < TechGuessMinConfidence || len(guess.Evidence) == 0 || !strings.HasPrefix(guess.String(), "looks like "+tc.want) {
+			t.Errorf("%s: expected the guess with its confidence and evidence, got %s", tc.name, guess)
+		}
+	}
+}
+
+func TestTechGuessConfidenceFloor(t *testing.T) {
+	// A single matched .php input and a Server header are not enough
+	var f techFingerprint
+	f.record(techResponse("text/html", "Server", "nginx"), map[string][]byte{"FUZZ": []byte("info.php")}, true)
+	f.record(techResponse("text/html", "Server", "nginx"), map[string][]byte{"FUZZ": []byte("notes.php")}, false)
+	if guess, ok := f.Guess(); ok {
+		t.Errorf("Expected no guess from weak evidence, got %s", guess)
+	}
+
+	// Conflicting platforms lower the confidence under the floor
+	f.record(techResponse("text/html", "X-Powered-By", "PHP/7.4"), map[string][]byte{"FUZZ": []byte("a")}, false)
+	f.record(techResponse("text/html", "X-Powered-By", "ASP.NET"), map[string][]byte{"FUZZ": []byte("b")}, false)
+	if guess, ok := f.Guess(); ok {
+		t.Errorf("Expected no guess from conflicting evidence, got %s", guess)
+	}
+}
+
+func TestTechExtensionSummary(t *testing.T) {
+	var f techFingerprint
+	for i, token := range []string{"a.php", "b.php", "c.php", "d.bak", "e"} {
+		f.record(techResponse("text/html"), map[string][]byte{"FUZZ": []byte(token)}, i%2 == 0)
+	}
+	lines := f.ExtensionSummary()
+	if len(lines) != 2 || lines[0] != "Extension .php: 2 of 3 requests matched" || lines[1] != "Extension .bak: 0 of 1 requests matched" {
+		t.Errorf("Unexpected extension statistics: %v", lines)
+	}
+}
@@ -0,0 +1,54 @@
+package ffuf
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// TLSHandshakeError is returned by the runner when the TLS handshake with the target fails, like when the server
+// requires a client certificate
+type TLSHandshakeError struct {
+	Err error
+}
+
+func (e *TLSHandshakeError) Error() string {
+	return fmt.Sprintf("TLS handshake failed, the server may require a client certificate (-cc, -ck): %s", e.Err)
+}
+
+func (e *TLSHandshakeError) Unwrap() error {
+	return e.Err
+}
+
+// ClientCertificate loads the client certificate of -cc and -ck, the certificates of -client-ca following it in the
+// chain sent to the server
+func ClientCertificate(conf *Config) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(conf.ClientCert, conf.ClientKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if conf.ClientCA == "" {
+		return cert, nil
+	}
+	data, err := os.ReadFile(conf.ClientCA)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	count := 0
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+			count++
+		}
+	}
+	if count == 0 {
+		return tls.Certificate{}, fmt.Errorf("no certificate found in %s", conf.ClientCA)
+	}
+	return cert, nil
+}
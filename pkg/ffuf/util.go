@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -144,3 +145,36 @@ func mergeMaps(m1 map[string][]string, m2 map[string][]string) map[string][]stri
 	}
 	return merged
 }
+
+// byteSizeUnits are the units of the sizes parsed by parseByteSize, largest first
+var byteSizeUnits = []struct {
+	suffix string
+	size   int64
+}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+// parseByteSize parses a size in bytes with an optional unit, like 256MB or 1GB. Sizes without a unit are bytes.
+func parseByteSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range byteSizeUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value, unit = strings.TrimSpace(strings.TrimSuffix(value, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return n * unit, nil
+}
+
+// formatByteSize formats a size in bytes in the largest unit of parseByteSize dividing it
+func formatByteSize(n int64) string {
+	for _, u := range byteSizeUnits {
+		if n >= u.size && n%u.size == 0 {
+			return fmt.Sprintf("%d%s", n/u.size, u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", n)
+}
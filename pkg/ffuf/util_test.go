@@ -24,3 +24,30 @@ func TestUniqStringSlice(t *testing.T) {
 		t.Errorf("Length of slice was %d, was expecting %d", len(uniqSlice), expectedLength)
 	}
 }
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value string
+		size  int64
+	}{
+		{"256MB", 256 << 20},
+		{"1gb", 1 << 30},
+		{"64 KB", 64 << 10},
+		{"1000", 1000},
+		{"10B", 10},
+	}
+	for _, tt := range tests {
+		size, err := parseByteSize(tt.value)
+		if err != nil || size != tt.size {
+			t.Errorf("Expected %s to be %d bytes, got %d (%v)", tt.value, tt.size, size, err)
+		}
+		if back, _ := parseByteSize(formatByteSize(size)); back != size {
+			t.Errorf("Expected %s to parse back to %d, got %d", formatByteSize(size), size, back)
+		}
+	}
+	for _, value := range []string{"", "MB", "-1MB", "1.5GB", "12TB"} {
+		if _, err := parseByteSize(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
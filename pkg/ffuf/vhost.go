@@ -0,0 +1,58 @@
+package ffuf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+// vhostCalibrationHosts is the number of random hosts requested to learn the responses of the default virtual host
+const vhostCalibrationHosts = 3
+
+// fuzzesHost returns true if FUZZ is in the Host header, given with -H or in the raw request
+func (j *Job) fuzzesHost() bool {
+	for name, value := range j.Config.Headers {
+		if strings.EqualFold(name, "Host") && strings.Contains(value, "FUZZ") {
+			return true
+		}
+	}
+	return false
+}
+
+// calibrateVhost requests random hosts to learn the responses of the default virtual host, the Markov chain
+// rewarding the deviation from them in certificate, status or size instead of the usual decision table. A wildcard
+// certificate or a default virtual host answering with several statuses are then part of the baseline.
+func (j *Job) calibrateVhost() {
+	if keywords := j.Input.Keywords(); len(keywords) != 1 || keywords[0] != "FUZZ" {
+		j.Output.Warning("The virtual host calibration is only run with the single keyword FUZZ")
+		return
+	}
+	basereq := BaseRequest(j.Config)
+	responses := make([]*markov.Response, 0, vhostCalibrationHosts)
+	for i := 0; i < vhostCalibrationHosts; i++ {
+		input := map[string][]byte{"FUZZ": []byte(strings.ToLower(RandomString(16)))}
+		req, err := j.Runner.Prepare(input, &basereq)
+		if err != nil || !j.spendBudget(&req, budgetCalibration) {
+			continue
+		}
+		resp, err := j.Runner.Execute(&req)
+		if err != nil {
+			j.Output.Warning(fmt.Sprintf("Virtual host calibration request failed: %s", err))
+			continue
+		}
+		responses = append(responses, j.markovResponse(resp))
+	}
+	if len(responses) == 0 {
+		j.Output.Warning("Could not calibrate the default virtual host, the usual Markov rewards are used")
+		return
+	}
+	j.MarkovChain.SetVhostBaselines(responses)
+	if !j.Config.Quiet {
+		baselines := make([]string, 0)
+		for _, b := range j.MarkovChain.VhostBaselines() {
+			baselines = append(baselines, b.String())
+		}
+		j.Output.Info(fmt.Sprintf("Default virtual host calibrated from %d random hosts: %s", len(responses), strings.Join(baselines, ", ")))
+	}
+}
@@ -0,0 +1,92 @@
+package ffuf
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+// answerVhosts returns a handler serving a default virtual host with a wildcard certificate, answering the unknown
+// hosts with a 404 or a 421 in turn, and a few known virtual hosts
+func answerVhosts() fakeHandler {
+	var unknown int32
+	return func(req *Request, resp *Response) error {
+		resp.ContentLength, resp.CertHash, resp.Complete = 100, "wildcard", true
+		switch req.Headers["Host"] {
+		case "admin.example.test":
+			// Served with a certificate of its own
+			resp.StatusCode = 200
+			resp.CertHash = "admin"
+		case "staging.example.test":
+			resp.StatusCode = 200
+			resp.ContentLength = 5000
+		case "dev.example.test":
+			resp.ContentLength = 8000
+		case "www.example.test":
+		default:
+			if atomic.AddInt32(&unknown, 1)%2 == 0 {
+				resp.StatusCode = 421
+				resp.ContentLength = 50
+			}
+		}
+		return nil
+	}
+}
+
+func TestVhostCalibration(t *testing.T) {
+	runner := newFakeRunner(answerVhosts())
+	j := newFakeJob(t, runner, []string{"www", "admin", "staging", "dev"}, func(conf *Config) {
+		conf.Url = "https://127.0.0.1/"
+		conf.Headers["Host"] = "FUZZ.example.test"
+		conf.MatcherManager = matchAll()
+	})
+	j.Start()
+	out := j.Output.(*recordingOutput)
+	expected := map[string]float64{
+		"www":     0,   // the default virtual host
+		"admin":   3.0, // another certificate and status
+		"staging": 1.5, // another status, the wildcard certificate
+		"dev":     1.0, // another size
+	}
+
+	// The random hosts are the tokens out of the wordlist
+	calibrations := 0
+	for token, n := range runner.counts(false) {
+		if _, ok := expected[token]; !ok {
+			calibrations += n
+		}
+	}
+	if calibrations != vhostCalibrationHosts {
+		t.Errorf("Expected %d random hosts to be requested, got %d", vhostCalibrationHosts, calibrations)
+	}
+	// Both statuses of the default virtual host are part of the baseline
+	if baselines := j.MarkovChain.VhostBaselines(); len(baselines) != 2 {
+		t.Errorf("Expected the 404 and 421 baselines, got %v", baselines)
+	}
+	for _, resp := range out.responses {
+		token := string(resp.Request.Input["FUZZ"])
+		if resp.Reward != expected[token] {
+			t.Errorf("Expected a reward of %f for %s, got %f", expected[token], token, resp.Reward)
+		}
+	}
+	if len(out.responses) != len(expected) {
+		t.Errorf("Expected %d results, got %d", len(expected), len(out.responses))
+	}
+}
+
+func TestFuzzesHost(t *testing.T) {
+	tests := []struct {
+		headers  map[string]string
+		expected bool
+	}{
+		{map[string]string{"Host": "FUZZ.example.com"}, true},
+		{map[string]string{"host": "FUZZ"}, true},
+		{map[string]string{"Host": "example.com", "X-Forwarded-Host": "FUZZ"}, false},
+		{map[string]string{}, false},
+	}
+	for _, tt := range tests {
+		j := &Job{Config: &Config{Headers: tt.headers}}
+		if got := j.fuzzesHost(); got != tt.expected {
+			t.Errorf("Headers %v: expected %t, got %t", tt.headers, tt.expected, got)
+		}
+	}
+}
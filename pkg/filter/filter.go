@@ -2,6 +2,7 @@ package filter
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/ffuf/ffuf/v2/pkg/ffuf"
@@ -14,6 +15,7 @@ type MatcherManager struct {
 	Matchers         map[string]ffuf.FilterProvider
 	Filters          map[string]ffuf.FilterProvider
 	PerDomainFilters map[string]*PerDomainFilter
+	RootFilters      map[string]map[string]ffuf.FilterProvider // filters scoped to a recursion root, by root
 }
 
 type PerDomainFilter struct {
@@ -35,6 +37,7 @@ func NewMatcherManager() ffuf.MatcherManager {
 		Matchers:         make(map[string]ffuf.FilterProvider),
 		Filters:          make(map[string]ffuf.FilterProvider),
 		PerDomainFilters: make(map[string]*PerDomainFilter),
+		RootFilters:      make(map[string]map[string]ffuf.FilterProvider),
 	}
 }
 
@@ -71,6 +74,12 @@ func NewFilterByName(name string, value string) (ffuf.FilterProvider, error) {
 	if name == "time" {
 		return NewTimeFilter(value)
 	}
+	if name == "hash" {
+		return NewHashFilter(value)
+	}
+	if name == "reflect" {
+		return NewReflectFilter()
+	}
 	return nil, fmt.Errorf("Could not create filter with name %s", name)
 }
 
@@ -121,6 +130,73 @@ func (f *MatcherManager) AddPerDomainFilter(domain string, name string, option s
 	return err
 }
 
+// AddScopedFilter adds a filter scoped to a recursion root, applying to the recursion jobs under it as well
+func (f *MatcherManager) AddScopedFilter(root string, name string, option string, replace bool) error {
+	f.Mutex.Lock()
+	defer f.Mutex.Unlock()
+	newf, err := NewFilterByName(name, option)
+	if err != nil {
+		return err
+	}
+	if f.RootFilters == nil {
+		f.RootFilters = make(map[string]map[string]ffuf.FilterProvider)
+	}
+	if f.RootFilters[root] == nil {
+		f.RootFilters[root] = make(map[string]ffuf.FilterProvider)
+	}
+	if f.RootFilters[root][name] == nil || replace {
+		f.RootFilters[root][name] = newf
+	} else {
+		newoption := f.RootFilters[root][name].Repr() + "," + option
+		newerf, err := NewFilterByName(name, newoption)
+		if err == nil {
+			f.RootFilters[root][name] = newerf
+		}
+	}
+	return nil
+}
+
+// RemoveScopedFilter removes a filter of a given type scoped to the recursion root
+func (f *MatcherManager) RemoveScopedFilter(root string, name string) {
+	f.Mutex.Lock()
+	defer f.Mutex.Unlock()
+	delete(f.RootFilters[root], name)
+	if len(f.RootFilters[root]) == 0 {
+		delete(f.RootFilters, root)
+	}
+}
+
+// FiltersForRoot returns the scoped filters applying to a recursion root: the ones of the root and of the roots
+// above it, keyed by the filter name and the root they were added to
+func (f *MatcherManager) FiltersForRoot(root string) map[string]ffuf.FilterProvider {
+	f.Mutex.Lock()
+	defer f.Mutex.Unlock()
+	filters := make(map[string]ffuf.FilterProvider)
+	for r, rf := range f.RootFilters {
+		if !strings.HasPrefix(root, r) {
+			continue
+		}
+		for name, filter := range rf {
+			filters[name+" "+r] = filter
+		}
+	}
+	return filters
+}
+
+// ScopedFilters returns the filters scoped to recursion roots, by root
+func (f *MatcherManager) ScopedFilters() map[string]map[string]ffuf.FilterProvider {
+	f.Mutex.Lock()
+	defer f.Mutex.Unlock()
+	scoped := make(map[string]map[string]ffuf.FilterProvider, len(f.RootFilters))
+	for root, rf := range f.RootFilters {
+		scoped[root] = make(map[string]ffuf.FilterProvider, len(rf))
+		for name, filter := range rf {
+			scoped[root][name] = filter
+		}
+	}
+	return scoped
+}
+
 //RemoveFilter removes a filter of a given type
 func (f *MatcherManager) RemoveFilter(name string) {
 	f.Mutex.Lock()
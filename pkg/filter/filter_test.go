@@ -2,6 +2,8 @@ package filter
 
 import (
 	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
 )
 
 func TestNewFilterByName(t *testing.T) {
@@ -49,3 +51,38 @@ func TestNewFilterByNameNotFound(t *testing.T) {
 		t.Errorf("Was expecing an error with invalid filter name")
 	}
 }
+
+func TestScopedFilters(t *testing.T) {
+	m := NewMatcherManager()
+	_ = m.AddScopedFilter("http://example.com/a/", "size", "1234", false)
+	_ = m.AddScopedFilter("http://example.com/a/b/", "size", "42", false)
+	resp := &ffuf.Response{ContentLength: 1234}
+	for _, test := range []struct {
+		root     string
+		filtered bool
+		count    int
+	}{
+		{"http://example.com/a/", true, 1},
+		{"http://example.com/a/b/", true, 2}, // inherits the filter of /a/ and extends it
+		{"http://example.com/b/", false, 0},
+		{"http://example.com/", false, 0},
+	} {
+		filters := m.FiltersForRoot(test.root)
+		if len(filters) != test.count {
+			t.Errorf("Expected %d filters for %s, got %d", test.count, test.root, len(filters))
+		}
+		filtered := false
+		for _, f := range filters {
+			if match, _ := f.Filter(resp); match {
+				filtered = true
+			}
+		}
+		if filtered != test.filtered {
+			t.Errorf("Expected the response under %s filtered: %t, got %t", test.root, test.filtered, filtered)
+		}
+	}
+	m.RemoveScopedFilter("http://example.com/a/", "size")
+	if scoped := m.ScopedFilters(); len(scoped) != 1 || scoped["http://example.com/a/b/"] == nil {
+		t.Errorf("Expected only the filter of /a/b/ left, got %v", scoped)
+	}
+}
@@ -0,0 +1,51 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// HashFilter matches the responses by the hash of their body, structural with -structural-hash
+type HashFilter struct {
+	Value []string
+}
+
+func NewHashFilter(value string) (ffuf.FilterProvider, error) {
+	var hashes []string
+	for _, sv := range strings.Split(value, ",") {
+		sv = strings.ToLower(strings.TrimSpace(sv))
+		if sv == "" {
+			return &HashFilter{}, fmt.Errorf("Hash filter or matcher (-fhash / -mhash): invalid value: %s", value)
+		}
+		hashes = append(hashes, sv)
+	}
+	return &HashFilter{Value: hashes}, nil
+}
+
+func (f *HashFilter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Value string `json:"value"`
+	}{
+		Value: f.Repr(),
+	})
+}
+
+func (f *HashFilter) Filter(response *ffuf.Response) (bool, error) {
+	for _, h := range f.Value {
+		if response.BodyHash == h {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *HashFilter) Repr() string {
+	return strings.Join(f.Value, ",")
+}
+
+func (f *HashFilter) ReprVerbose() string {
+	return fmt.Sprintf("Response body hash: %s", f.Repr())
+}
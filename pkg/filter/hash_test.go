@@ -0,0 +1,36 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+func TestNewHashFilter(t *testing.T) {
+	f, _ := NewHashFilter("A1B2, c3d4")
+	if f.Repr() != "a1b2,c3d4" {
+		t.Errorf("Unexpected hash filter value: %s", f.Repr())
+	}
+	if _, err := NewHashFilter("a1b2,"); err == nil {
+		t.Errorf("Was expecting an error from an empty hash")
+	}
+}
+
+func TestHashFiltering(t *testing.T) {
+	f, _ := NewHashFilter("a1b2,c3d4")
+	for i, test := range []struct {
+		input  string
+		output bool
+	}{
+		{"a1b2", true},
+		{"c3d4", true},
+		{"a1b3", false},
+		{"", false},
+	} {
+		resp := ffuf.Response{BodyHash: test.input}
+		filterReturn, _ := f.Filter(&resp)
+		if filterReturn != test.output {
+			t.Errorf("Filter test %d: Was expecing filter return value of %t but got %t", i, test.output, filterReturn)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package filter
+
+import (
+	"encoding/json"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// ReflectFilter matches the responses reflecting a fuzzed value in their body or headers, see ffuf.Response.Reflects
+type ReflectFilter struct{}
+
+func NewReflectFilter() (ffuf.FilterProvider, error) {
+	return &ReflectFilter{}, nil
+}
+
+func (f *ReflectFilter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Value string `json:"value"`
+	}{
+		Value: f.Repr(),
+	})
+}
+
+func (f *ReflectFilter) Filter(response *ffuf.Response) (bool, error) {
+	return response.Reflected, nil
+}
+
+func (f *ReflectFilter) Repr() string {
+	return "true"
+}
+
+func (f *ReflectFilter) ReprVerbose() string {
+	return "Response reflects the fuzzed input"
+}
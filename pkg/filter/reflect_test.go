@@ -0,0 +1,17 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+func TestReflectFiltering(t *testing.T) {
+	f, _ := NewFilterByName("reflect", "")
+	for _, reflected := range []bool{true, false} {
+		resp := ffuf.Response{Reflected: reflected}
+		if match, _ := f.Filter(&resp); match != reflected {
+			t.Errorf("Expected the reflect matcher to return %t, got %t", reflected, match)
+		}
+	}
+}
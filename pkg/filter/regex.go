@@ -31,23 +31,33 @@ func (f *RegexpFilter) MarshalJSON() ([]byte, error) {
 }
 
 func (f *RegexpFilter) Filter(response *ffuf.Response) (bool, error) {
-	matchheaders := ""
-	for k, v := range response.Headers {
-		for _, iv := range v {
-			matchheaders += k + ": " + iv + "\r\n"
-		}
-	}
-	matchdata := []byte(matchheaders)
+	matchdata := matchHeaders(response)
 	matchdata = append(matchdata, response.Data...)
+	matched, err := regexp.Match(f.pattern(response), matchdata)
+	if err != nil {
+		return false, nil
+	}
+	return matched, nil
+}
+
+// pattern returns the regexp with the keywords replaced by the input of the request
+func (f *RegexpFilter) pattern(response *ffuf.Response) string {
 	pattern := f.valueRaw
 	for keyword, inputitem := range response.Request.Input {
 		pattern = strings.ReplaceAll(pattern, keyword, regexp.QuoteMeta(string(inputitem)))
 	}
-	matched, err := regexp.Match(pattern, matchdata)
-	if err != nil {
-		return false, nil
+	return pattern
+}
+
+// matchHeaders returns the headers of the response as matched ahead of the body
+func matchHeaders(response *ffuf.Response) []byte {
+	matchheaders := ""
+	for k, v := range response.Headers {
+		for _, iv := range v {
+			matchheaders += k + ": " + iv + "\r\n"
+		}
 	}
-	return matched, nil
+	return []byte(matchheaders)
 }
 
 func (f *RegexpFilter) Repr() string {
@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// maxStreamOverlap bounds the bytes kept from a chunk of the body to match the next one with. The regexps able to
+// match more bytes than this are matched on the whole body.
+const maxStreamOverlap = 4096
+
+// regexpScanner matches a regexp on the body as it is read. Each chunk is matched along with the end of the
+// previous ones, long enough to hold any match of the regexp, for the matches spanning chunks not to be missed.
+type regexpScanner struct {
+	re      *regexp.Regexp
+	overlap int // bytes of the previous chunks matched along with the next chunk
+	tail    []byte
+	matched bool
+}
+
+// Scan matches the regexp on the next chunk of the body. Returns true once the regexp matched.
+func (s *regexpScanner) Scan(p []byte) bool {
+	if s.matched {
+		return true
+	}
+	window := make([]byte, 0, len(s.tail)+len(p))
+	window = append(window, s.tail...)
+	window = append(window, p...)
+	if s.re.Match(window) {
+		s.matched = true
+		return true
+	}
+	if len(window) > s.overlap {
+		window = window[len(window)-s.overlap:]
+	}
+	s.tail = window
+	return false
+}
+
+// BodyScanner returns a scanner matching the regexp on the body of the response as it is read, the headers
+// included like Filter does. Returns nil if the regexp cannot be matched a chunk at a time.
+func (f *RegexpFilter) BodyScanner(response *ffuf.Response) ffuf.BodyScanner {
+	pattern := f.pattern(response)
+	length, ok := streamableLength(pattern)
+	if !ok || length > maxStreamOverlap+1 {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	overlap := length - 1
+	if overlap < 0 {
+		overlap = 0
+	}
+	s := &regexpScanner{re: re, overlap: overlap}
+	s.Scan(matchHeaders(response))
+	return s
+}
+
+// streamableLength returns the maximum length in bytes of the matches of the regexp. Returns false if the length is
+// unbounded, or the regexp has anchors or word boundaries, which would match at the start of the chunks.
+func streamableLength(pattern string) (int, bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return 0, false
+	}
+	return maxMatchLength(re)
+}
+
+func maxMatchLength(re *syntax.Regexp) (int, bool) {
+	switch re.Op {
+	case syntax.OpNoMatch, syntax.OpEmptyMatch:
+		return 0, true
+	case syntax.OpLiteral:
+		length := 0
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 {
+				// The other cases of a rune may be encoded with more bytes, like K and the Kelvin sign
+				length += utf8.UTFMax
+			} else {
+				length += utf8.RuneLen(r)
+			}
+		}
+		return length, true
+	case syntax.OpCharClass:
+		length := 0
+		for i := 1; i < len(re.Rune); i += 2 {
+			if l := utf8.RuneLen(re.Rune[i]); l > length {
+				length = l
+			}
+		}
+		return length, true
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return utf8.UTFMax, true
+	case syntax.OpCapture, syntax.OpQuest:
+		return maxMatchLength(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Max < 0 {
+			return 0, false
+		}
+		length, ok := maxMatchLength(re.Sub[0])
+		return length * re.Max, ok
+	case syntax.OpConcat:
+		total := 0
+		for _, sub := range re.Sub {
+			length, ok := maxMatchLength(sub)
+			if !ok {
+				return 0, false
+			}
+			total += length
+		}
+		return total, true
+	case syntax.OpAlternate:
+		longest := 0
+		for _, sub := range re.Sub {
+			length, ok := maxMatchLength(sub)
+			if !ok {
+				return 0, false
+			}
+			if length > longest {
+				longest = length
+			}
+		}
+		return longest, true
+	}
+	// Stars, pluses, anchors and word boundaries
+	return 0, false
+}
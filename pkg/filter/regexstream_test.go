@@ -0,0 +1,96 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+func TestStreamableLength(t *testing.T) {
+	for _, test := range []struct {
+		pattern    string
+		length     int
+		streamable bool
+	}{
+		{"secret", 6, true},
+		{"(?i)secret", 24, true},
+		{"err(or|no)", 5, true},
+		{"[0-9]{3}-[a-z]{2,4}", 8, true},
+		{"v[0-9]?\\.[0-9]", 4, true},
+		{"s([a-z]+)arch", 0, false},
+		{"Index of .*", 0, false},
+		{"^HTTP", 0, false},
+		{"end$", 0, false},
+		{"\\badmin\\b", 0, false},
+		{"a{2,}", 0, false},
+	} {
+		length, ok := streamableLength(test.pattern)
+		if ok != test.streamable || (ok && length != test.length) {
+			t.Errorf("%s: expected %d, %t, got %d, %t", test.pattern, test.length, test.streamable, length, ok)
+		}
+	}
+}
+
+// scanChunks scans the body in chunks of the given size, returning the number of bytes scanned until the match
+func scanChunks(s ffuf.BodyScanner, body string, size int) (int, bool) {
+	for i := 0; i < len(body); i += size {
+		end := i + size
+		if end > len(body) {
+			end = len(body)
+		}
+		if s.Scan([]byte(body[i:end])) {
+			return end, true
+		}
+	}
+	return len(body), false
+}
+
+func TestRegexpBodyScanner(t *testing.T) {
+	body := "lorem ipsum dolor sit amet, the secret-token is here, consectetur adipiscing elit"
+	for _, test := range []struct {
+		pattern string
+		matched bool
+	}{
+		{"secret-token", true},
+		{"secret-[a-z]{5}", true},
+		{"(?i)SECRET-TOKEN", true},
+		{"sit amet|nothing", true},
+		{"secret-tokens", false},
+		{"Server: ffuf", true},
+		{"FUZZ is", true},
+	} {
+		f, _ := NewRegexpFilter(test.pattern)
+		resp := ffuf.Response{
+			Headers: map[string][]string{"Server": {"ffuf"}},
+			Data:    []byte(body),
+			Request: &ffuf.Request{Input: map[string][]byte{"FUZZ": []byte("token")}},
+		}
+		expected, _ := f.Filter(&resp)
+		if expected != test.matched {
+			t.Fatalf("%s: expected the filter to return %t", test.pattern, test.matched)
+		}
+		// Every chunk size splits the matches differently, down to a byte at a time
+		for size := 1; size <= len(body); size++ {
+			s := f.(*RegexpFilter).BodyScanner(&resp)
+			if s == nil {
+				t.Fatalf("%s: expected the regexp to be streamed", test.pattern)
+			}
+			if _, matched := scanChunks(s, body, size); matched != test.matched {
+				t.Errorf("%s: chunks of %d bytes: expected %t, got %t", test.pattern, size, test.matched, matched)
+			}
+		}
+	}
+
+	// The scan stops right after the chunk the match ends in
+	f, _ := NewRegexpFilter("secret-token")
+	resp := ffuf.Response{Data: []byte(body), Request: &ffuf.Request{Input: map[string][]byte{}}}
+	if scanned, _ := scanChunks(f.(*RegexpFilter).BodyScanner(&resp), body, 10); scanned != 50 {
+		t.Errorf("Expected the scan to stop after 50 bytes, scanned %d", scanned)
+	}
+
+	// The regexps that cannot be streamed are left to Filter
+	f, _ = NewRegexpFilter("secret.*here")
+	if f.(*RegexpFilter).BodyScanner(&resp) != nil {
+		t.Errorf("Expected no scanner for an unbounded regexp")
+	}
+}
@@ -0,0 +1,134 @@
+package input
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+// banditMinShare is the share of the requests guaranteed to each wordlist in bandit mode, so that a wordlist
+// that has not paid off yet keeps being sampled
+const banditMinShare = 0.05
+
+// banditArm holds the statistics of a wordlist in bandit mode
+type banditArm struct {
+	name      string
+	pulls     int
+	rewardSum float64
+}
+
+// bandit allocates the requests across the wordlists with UCB1, favoring the ones with the highest reward rate
+// while guaranteeing a minimum share to each of them
+type bandit struct {
+	arms     []banditArm
+	pulls    int
+	minShare float64
+	mutex    sync.Mutex
+}
+
+func newBandit(names []string, minShare float64) *bandit {
+	b := &bandit{minShare: minShare}
+	for _, name := range names {
+		b.arms = append(b.arms, banditArm{name: name})
+	}
+	return b
+}
+
+// choose returns the arm to take the next input from, among the available ones. Returns -1 if no arm is available.
+func (b *bandit) choose(available []bool) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	chosen := -1
+	// Arms that have never been pulled, or fell below their guaranteed share, come first
+	for i, arm := range b.arms {
+		if !available[i] {
+			continue
+		}
+		if float64(arm.pulls) < b.minShare*float64(b.pulls) || arm.pulls == 0 {
+			if chosen == -1 || arm.pulls < b.arms[chosen].pulls {
+				chosen = i
+			}
+		}
+	}
+	if chosen == -1 {
+		best := 0.0
+		for i, arm := range b.arms {
+			if !available[i] {
+				continue
+			}
+			score := arm.rewardSum/float64(arm.pulls) + math.Sqrt(2*math.Log(float64(b.pulls))/float64(arm.pulls))
+			if chosen == -1 || score > best {
+				chosen = i
+				best = score
+			}
+		}
+	}
+	if chosen != -1 {
+		b.arms[chosen].pulls++
+		b.pulls++
+	}
+	return chosen
+}
+
+// reward records the reward, between 0 and 1, of an input taken from an arm
+func (b *bandit) reward(arm int, reward float64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.arms[arm].rewardSum += reward
+}
+
+// reset forgets the statistics of the arms
+func (b *bandit) reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for i := range b.arms {
+		b.arms[i].pulls = 0
+		b.arms[i].rewardSum = 0
+	}
+	b.pulls = 0
+}
+
+// allocation returns the share of the requests taken from each arm
+func (b *bandit) allocation() []float64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	shares := make([]float64, len(b.arms))
+	for i, arm := range b.arms {
+		if b.pulls > 0 {
+			shares[i] = float64(arm.pulls) / float64(b.pulls)
+		}
+	}
+	return shares
+}
+
+// String returns the current allocation in a short human readable format
+func (b *bandit) String() string {
+	shares := b.allocation()
+	parts := make([]string, 0, len(shares))
+	for i, share := range shares {
+		parts = append(parts, fmt.Sprintf("%s %.0f%%", b.arms[i].name, share*100))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// summary returns the allocation and reward rate of each arm
+func (b *bandit) summary() []string {
+	shares := b.allocation()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	lines := make([]string, 0, len(b.arms))
+	for i, arm := range b.arms {
+		rate := 0.0
+		if arm.pulls > 0 {
+			rate = arm.rewardSum / float64(arm.pulls)
+		}
+		lines = append(lines, fmt.Sprintf("Wordlist %s: %.1f%% of the requests (%d), match rate %.3f", arm.name, shares[i]*100, arm.pulls, rate))
+	}
+	return lines
+}
@@ -0,0 +1,86 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// newBanditProvider returns a bandit mode input provider over wordlists of the given sizes, the words of each
+// wordlist being prefixed with its name
+func newBanditProvider(t *testing.T, sizes map[string]int) *MainInputProvider {
+	dir := t.TempDir()
+	conf := ffuf.NewConfig(context.Background(), func() {})
+	conf.InputMode = "bandit"
+	for _, name := range []string{"common.txt", "api.txt", "backup-files.txt"} {
+		words := make([]string, sizes[name])
+		for i := range words {
+			words[i] = fmt.Sprintf("%s%d", strings.TrimSuffix(name, ".txt"), i)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(strings.Join(words, "\n")), 0644); err != nil {
+			t.Fatal(err)
+		}
+		conf.InputProviders = append(conf.InputProviders, ffuf.InputProviderConfig{Name: "wordlist", Value: path, Keyword: "FUZZ"})
+	}
+	ip, errs := NewInputProvider(&conf)
+	if errs.ErrorOrNil() != nil {
+		t.Fatal(errs.ErrorOrNil())
+	}
+	return ip.(*MainInputProvider)
+}
+
+func TestBanditConverges(t *testing.T) {
+	mip := newBanditProvider(t, map[string]int{"common.txt": 5000, "api.txt": 5000, "backup-files.txt": 5000})
+	if mip.Total() != 15000 {
+		t.Errorf("Expected the total to be the sum of the wordlists, got %d", mip.Total())
+	}
+	// Every third word of api.txt is a match, the other wordlists never match
+	requests := 3000
+	for n := 0; n < requests && mip.Next(); n++ {
+		val := mip.Value()
+		reward := 0.0
+		var index int
+		if _, err := fmt.Sscanf(string(val["FUZZ"]), "api%d", &index); err == nil && index%3 == 0 {
+			reward = 1
+		}
+		mip.Feedback(val, reward)
+	}
+	shares := mip.bandit.allocation()
+	if shares[1] < 0.7 {
+		t.Errorf("Expected most of the requests to go to the productive wordlist, got %s", mip.Allocation())
+	}
+	for _, i := range []int{0, 2} {
+		if shares[i] < banditMinShare {
+			t.Errorf("Expected the dead wordlists to keep their minimum share, got %s", mip.Allocation())
+		}
+	}
+	if len(mip.banditArms) != 0 {
+		t.Errorf("Expected all the values to have been credited, %d left", len(mip.banditArms))
+	}
+	if summary := mip.Summary(); len(summary) != 3 || !strings.Contains(summary[1], "api.txt") {
+		t.Errorf("Unexpected summary: %v", summary)
+	}
+}
+
+func TestBanditExhaustsWordlists(t *testing.T) {
+	mip := newBanditProvider(t, map[string]int{"common.txt": 3, "api.txt": 50, "backup-files.txt": 1})
+	seen := make(map[string]bool)
+	for mip.Next() {
+		val := mip.Value()
+		word := string(val["FUZZ"])
+		if word == "" || seen[word] {
+			t.Fatalf("Unexpected value %q after %d values", word, len(seen))
+		}
+		seen[word] = true
+		mip.Feedback(val, 0)
+	}
+	if len(seen) != 54 {
+		t.Errorf("Expected every word of every wordlist to be sent once, got %d", len(seen))
+	}
+}
@@ -0,0 +1,163 @@
+package input
+
+import (
+	"regexp"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// extPlaceholder is the extension placeholder of the dirsearch wordlists, expanded in the DirSearch compatibility
+// mode (-D)
+var extPlaceholder = regexp.MustCompile(`(?i)%ext%`)
+
+// extensionExpander expands the inputs having the %EXT% placeholder into one input for each of the extensions of -e,
+// as they are read. The extensions follow the ranking of the ranker once one is set, for the placeholders to stop
+// being expanded with the extensions the target clearly does not serve.
+type extensionExpander struct {
+	extensions   []string
+	ranker       ffuf.ExtensionRanker
+	value        map[string][]byte // input being expanded
+	pending      []string          // extensions left to expand the input with
+	first        bool              // the next expansion is the first of the input
+	extra        int               // inputs returned beyond the first expansion of each placeholder input
+	seen         int               // placeholder inputs expanded so far
+	seenExpanded int               // inputs the placeholder inputs seen so far were expanded to
+	placeholders int               // placeholder inputs of the input provider, -1 until counted
+}
+
+func newExtensionExpander(extensions []string) *extensionExpander {
+	return &extensionExpander{extensions: extensions, placeholders: -1}
+}
+
+// current returns the extensions the next placeholder input is expanded with
+func (e *extensionExpander) current() []string {
+	if e.ranker == nil {
+		return e.extensions
+	}
+	if ranked := e.ranker.RankExtensions(e.extensions); len(ranked) > 0 {
+		return ranked
+	}
+	return e.extensions
+}
+
+// expand starts the expansion of an input if it has the placeholder. Returns false otherwise.
+func (e *extensionExpander) expand(value map[string][]byte) bool {
+	if !hasExtPlaceholder(value) {
+		return false
+	}
+	e.value = value
+	e.pending = e.current()
+	e.first = true
+	e.seen++
+	e.seenExpanded += len(e.pending)
+	return true
+}
+
+// expanding returns true if the input being expanded has extensions left
+func (e *extensionExpander) expanding() bool {
+	return len(e.pending) > 0
+}
+
+// next returns the input being expanded with its next extension
+func (e *extensionExpander) next() map[string][]byte {
+	ext := []byte(e.pending[0])
+	e.pending = e.pending[1:]
+	if e.first {
+		e.first = false
+	} else {
+		e.extra++
+	}
+	values := make(map[string][]byte, len(e.value))
+	for k, v := range e.value {
+		values[k] = extPlaceholder.ReplaceAll(v, ext)
+	}
+	return values
+}
+
+// total returns the number of inputs added by the expansion to the total of the input provider, the placeholder
+// inputs not read yet being expanded with the current extensions
+func (e *extensionExpander) total() int {
+	if e.placeholders < 0 {
+		return 0
+	}
+	return e.seenExpanded - e.seen + (e.placeholders-e.seen)*(len(e.current())-1)
+}
+
+// reset restarts the expansion from the beginning of the input provider
+func (e *extensionExpander) reset() {
+	e.value = nil
+	e.pending = nil
+	e.extra = 0
+	e.seen = 0
+	e.seenExpanded = 0
+	e.placeholders = -1
+}
+
+// hasExtPlaceholder returns true if any value of the input has the placeholder
+func hasExtPlaceholder(value map[string][]byte) bool {
+	for _, v := range value {
+		if extPlaceholder.Match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetExtensionRanker sets the ranker the extensions of the %EXT% placeholder follow
+func (i *MainInputProvider) SetExtensionRanker(ranker ffuf.ExtensionRanker) {
+	if i.extensions != nil {
+		i.extensions.ranker = ranker
+	}
+}
+
+// countPlaceholders returns the number of inputs of the active providers having the placeholder
+func (i *MainInputProvider) countPlaceholders() int {
+	totals := make([]int, 0)
+	placeholders := make([]int, 0)
+	words := make([][][]byte, 0)
+	for _, p := range i.Providers {
+		if !p.Active() {
+			continue
+		}
+		var pw [][]byte
+		if wp, ok := p.(interface{ Words() [][]byte }); ok {
+			pw = wp.Words()
+		}
+		count := 0
+		for _, w := range pw {
+			if extPlaceholder.Match(w) {
+				count++
+			}
+		}
+		totals = append(totals, p.Total())
+		placeholders = append(placeholders, count)
+		words = append(words, pw)
+	}
+	switch i.Config.InputMode {
+	case "pitchfork":
+		// The exhausted wordlists start over until the longest one is exhausted
+		count := 0
+		for idx := 0; idx < i.baseTotal(); idx++ {
+			for _, pw := range words {
+				if len(pw) > 0 && extPlaceholder.Match(pw[idx%len(pw)]) {
+					count++
+					break
+				}
+			}
+		}
+		return count
+	case "bandit":
+		count := 0
+		for _, c := range placeholders {
+			count += c
+		}
+		return count
+	default:
+		all, without := 1, 1
+		for idx := range totals {
+			all *= totals[idx]
+			without *= totals[idx] - placeholders[idx]
+		}
+		return all - without
+	}
+}
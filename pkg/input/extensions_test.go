@@ -0,0 +1,113 @@
+package input
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// newDirSearchInput returns an input provider in DirSearch compatibility mode reading the words as the FUZZ wordlist
+func newDirSearchInput(t *testing.T, words string, extensions []string) *MainInputProvider {
+	path := filepath.Join(t.TempDir(), "wordlist.txt")
+	if err := os.WriteFile(path, []byte(words), 0644); err != nil {
+		t.Fatalf("Could not write the wordlist: %s", err)
+	}
+	conf := ffuf.NewConfig(context.Background(), func() {})
+	conf.InputMode = "clusterbomb"
+	conf.DirSearchCompat = true
+	conf.Extensions = extensions
+	conf.InputProviders = []ffuf.InputProviderConfig{{Name: "wordlist", Value: path, Keyword: "FUZZ"}}
+	ip, errs := NewInputProvider(&conf)
+	if errs.ErrorOrNil() != nil {
+		t.Fatalf("Could not create the input provider: %s", errs.ErrorOrNil())
+	}
+	return ip.(*MainInputProvider)
+}
+
+// readAll reads the remaining FUZZ values of the input provider, checking that the positions follow each other
+func readAll(t *testing.T, ip *MainInputProvider, n int) []string {
+	values := make([]string, 0)
+	start := ip.Position()
+	for len(values) != n && ip.Next() {
+		values = append(values, string(ip.Value()["FUZZ"]))
+		if ip.Position() != start+len(values) {
+			t.Errorf("Expected the position %d after %s, got %d", start+len(values), values[len(values)-1], ip.Position())
+		}
+	}
+	return values
+}
+
+func TestExtPlaceholder(t *testing.T) {
+	for word, expected := range map[string]bool{"admin.%EXT%": true, "admin.%ext%": true, "%Ext%/index": true, "admin.ext": false, "%EXT": false} {
+		if extPlaceholder.MatchString(word) != expected {
+			t.Errorf("%s: expected the placeholder match to be %t", word, expected)
+		}
+	}
+	e := newExtensionExpander([]string{"php"})
+	if !e.expand(map[string][]byte{"FUZZ": []byte("%EXT%/index.%ext%")}) {
+		t.Fatalf("Expected the input to be expanded")
+	}
+	if v := string(e.next()["FUZZ"]); v != "php/index.php" {
+		t.Errorf("Expected every placeholder of the value to be replaced, got %s", v)
+	}
+	if e.expand(map[string][]byte{"FUZZ": []byte("index")}) {
+		t.Errorf("Expected an input without the placeholder not to be expanded")
+	}
+}
+
+func TestMixedWordlistExpansion(t *testing.T) {
+	ip := newDirSearchInput(t, "index\nadmin.%EXT%\nlogin\nbackup.%ext%.bak\n", []string{"php", "jsp", "asp"})
+	if ip.Total() != 8 {
+		t.Errorf("Expected the two placeholder words to be expanded three times each in the total, got %d", ip.Total())
+	}
+	expected := []string{"index", "admin.php", "admin.jsp", "admin.asp", "login", "backup.php.bak", "backup.jsp.bak", "backup.asp.bak"}
+	if values := readAll(t, ip, -1); !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected the values %v, got %v", expected, values)
+	}
+
+	ip.Reset()
+	if values := readAll(t, ip, -1); !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected the same values after a reset, got %v", values)
+	}
+}
+
+// switchRanker keeps the extensions unchanged until it is switched to the learned extensions
+type switchRanker struct {
+	learned []string
+}
+
+func (r *switchRanker) RankExtensions(extensions []string) []string {
+	if r.learned == nil {
+		return extensions
+	}
+	return r.learned
+}
+
+func TestLearnedExtensionExpansion(t *testing.T) {
+	ip := newDirSearchInput(t, "admin.%EXT%\nindex\nlogin.%EXT%\nconfig.%EXT%\n", []string{"php", "jsp", "asp"})
+	ranker := &switchRanker{}
+	ip.SetExtensionRanker(ranker)
+	if ip.Total() != 10 {
+		t.Errorf("Expected a total of 10 with the static extensions, got %d", ip.Total())
+	}
+	if values := readAll(t, ip, 2); !reflect.DeepEqual(values, []string{"admin.php", "admin.jsp"}) {
+		t.Errorf("Unexpected static expansion %v", values)
+	}
+
+	// The input being expanded keeps its extensions, the next ones follow the ranking
+	ranker.learned = []string{"php"}
+	if ip.Total() != 6 {
+		t.Errorf("Expected the total to follow the learned extensions, got %d", ip.Total())
+	}
+	expected := []string{"admin.asp", "index", "login.php", "config.php"}
+	if values := readAll(t, ip, -1); !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected the values %v, got %v", expected, values)
+	}
+	if ip.Position() != ip.Total() {
+		t.Errorf("Expected the total %d to match the inputs read, got %d", ip.Total(), ip.Position())
+	}
+}
@@ -3,7 +3,9 @@ package input
 import (
 	"fmt"
 	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/ffuf/pencode/pkg/pencode"
 )
@@ -14,12 +16,20 @@ type MainInputProvider struct {
 	Config      *ffuf.Config
 	position    int
 	msbIterator int
+	names       []string         // names of the providers, used in the bandit mode statistics
+	bandit      *bandit          // allocates the requests across the providers in bandit mode
+	lastArm     int              // provider of the last value in bandit mode
+	banditArms  map[string][]int // providers of the values waiting for feedback in bandit mode
+	banditMutex sync.Mutex
+	extensions  *extensionExpander // expands the %EXT% placeholder in DirSearch compatibility mode
+	ranker      ffuf.ValueRanker   // ranks the values of the keywords, for the best-first product in clusterbomb mode
+	product     *bestFirstProduct  // combinations of the current pass in clusterbomb mode once a ranker is set
 }
 
 func NewInputProvider(conf *ffuf.Config) (ffuf.InputProvider, ffuf.Multierror) {
 	validmode := false
 	errs := ffuf.NewMultierror()
-	for _, mode := range []string{"clusterbomb", "pitchfork", "sniper"} {
+	for _, mode := range []string{"clusterbomb", "pitchfork", "sniper", "bandit"} {
 		if conf.InputMode == mode {
 			validmode = true
 		}
@@ -36,6 +46,13 @@ func NewInputProvider(conf *ffuf.Config) (ffuf.InputProvider, ffuf.Multierror) {
 			errs.Add(err)
 		}
 	}
+	if conf.InputMode == "bandit" {
+		mainip.bandit = newBandit(mainip.names, banditMinShare)
+		mainip.banditArms = make(map[string][]int)
+	}
+	if conf.DirSearchCompat && len(conf.Extensions) > 0 {
+		mainip.extensions = newExtensionExpander(conf.Extensions)
+	}
 	return &mainip, errs
 }
 
@@ -43,13 +60,28 @@ func (i *MainInputProvider) AddProvider(provider ffuf.InputProviderConfig) error
 	if provider.Name == "command" {
 		newcomm, _ := NewCommandInput(provider.Keyword, provider.Value, i.Config)
 		i.Providers = append(i.Providers, newcomm)
+		i.names = append(i.names, provider.Value)
+	} else if provider.Name == "replay" {
+		newrp, err := NewReplayInput(provider.Keyword, provider.Value, i.Config)
+		if err != nil {
+			return err
+		}
+		if i.sharded() {
+			newrp.shard(i.Config.InputShard, i.Config.InputShards)
+		}
+		i.Providers = append(i.Providers, newrp)
+		i.names = append(i.names, filepath.Base(provider.Value))
 	} else {
 		// Default to wordlist
 		newwl, err := NewWordlistInput(provider.Keyword, provider.Value, i.Config)
 		if err != nil {
 			return err
 		}
+		if i.sharded() {
+			newwl.shard(i.Config.InputShard, i.Config.InputShards)
+		}
 		i.Providers = append(i.Providers, newwl)
+		i.names = append(i.names, filepath.Base(provider.Value))
 	}
 	if len(provider.Encoders) > 0 {
 		chain := pencode.NewChain()
@@ -62,6 +94,16 @@ func (i *MainInputProvider) AddProvider(provider ffuf.InputProviderConfig) error
 	return nil
 }
 
+// sharded returns true if the provider being added takes a shard of its words with -input-shard. Every wordlist is
+// sharded in pitchfork and bandit modes, keeping the words of the wordlists aligned, and only the first one in the
+// other modes, for the shards to split the combinations of the wordlists.
+func (i *MainInputProvider) sharded() bool {
+	if i.Config.InputShards == 0 {
+		return false
+	}
+	return len(i.Providers) == 0 || i.Config.InputMode == "pitchfork" || i.Config.InputMode == "bandit"
+}
+
 // ActivateKeywords enables / disables wordlists based on list of active keywords
 func (i *MainInputProvider) ActivateKeywords(kws []string) {
 	for _, p := range i.Providers {
@@ -71,16 +113,26 @@ func (i *MainInputProvider) ActivateKeywords(kws []string) {
 			p.Disable()
 		}
 	}
+	i.product = nil
+	if i.extensions != nil {
+		i.extensions.reset()
+	}
 }
 
 // Position will return the current position of progress
 func (i *MainInputProvider) Position() int {
+	if i.extensions != nil {
+		return i.position + i.extensions.extra
+	}
 	return i.position
 }
 
 // SetPosition will reset the MainInputProvider to a specific position
 func (i *MainInputProvider) SetPosition(pos int) {
-	if i.Config.InputMode == "clusterbomb" || i.Config.InputMode == "sniper" {
+	if i.extensions != nil {
+		i.extensions.reset()
+	}
+	if i.Config.InputMode == "clusterbomb" || i.Config.InputMode == "sniper" || i.Config.InputMode == "bandit" {
 		i.setclusterbombPosition(pos)
 	} else {
 		i.setpitchforkPosition(pos)
@@ -96,9 +148,28 @@ func (i *MainInputProvider) Keywords() []string {
 	return kws
 }
 
+// Words returns the words of the wordlists of the keyword, nil if the keyword is not backed by wordlists only
+func (i *MainInputProvider) Words(keyword string) [][]byte {
+	var words [][]byte
+	for _, p := range i.Providers {
+		if p.Keyword() != keyword {
+			continue
+		}
+		wp, ok := p.(interface{ Words() [][]byte })
+		if !ok {
+			return nil
+		}
+		words = append(words, wp.Words()...)
+	}
+	return words
+}
+
 // Next will increment the cursor position, and return a boolean telling if there's inputs left
 func (i *MainInputProvider) Next() bool {
-	if i.position >= i.Total() {
+	if i.extensions != nil && i.extensions.expanding() {
+		return true
+	}
+	if i.position >= i.baseTotal() {
 		return false
 	}
 	i.position++
@@ -107,12 +178,15 @@ func (i *MainInputProvider) Next() bool {
 
 // Value returns a map of inputs for keywords
 func (i *MainInputProvider) Value() map[string][]byte {
-	retval := make(map[string][]byte)
-	if i.Config.InputMode == "clusterbomb" || i.Config.InputMode == "sniper" {
-		retval = i.clusterbombValue()
-	}
-	if i.Config.InputMode == "pitchfork" {
-		retval = i.pitchforkValue()
+	var retval map[string][]byte
+	if i.extensions != nil && i.extensions.expanding() {
+		retval = i.extensions.next()
+	} else {
+		retval = i.modeValue()
+		// The placeholder is expanded before the encoders
+		if i.extensions != nil && i.extensions.expand(retval) {
+			retval = i.extensions.next()
+		}
 	}
 	if len(i.Encoders) > 0 {
 		for key, val := range retval {
@@ -126,6 +200,32 @@ func (i *MainInputProvider) Value() map[string][]byte {
 			}
 		}
 	}
+	if i.bandit != nil && i.lastArm != -1 {
+		// Remember the provider of the value as sent, to credit it with the feedback
+		i.banditMutex.Lock()
+		for _, val := range retval {
+			i.banditArms[string(val)] = append(i.banditArms[string(val)], i.lastArm)
+		}
+		i.banditMutex.Unlock()
+	}
+	return retval
+}
+
+// modeValue returns the next input of the providers in the input mode
+func (i *MainInputProvider) modeValue() map[string][]byte {
+	retval := make(map[string][]byte)
+	if i.Config.InputMode == "clusterbomb" || i.Config.InputMode == "sniper" {
+		if values, ok := i.bestFirstValue(); ok {
+			return values
+		}
+		retval = i.clusterbombValue()
+	}
+	if i.Config.InputMode == "pitchfork" {
+		retval = i.pitchforkValue()
+	}
+	if i.Config.InputMode == "bandit" {
+		retval = i.banditValue()
+	}
 	return retval
 }
 
@@ -136,6 +236,66 @@ func (i *MainInputProvider) Reset() {
 	}
 	i.position = 0
 	i.msbIterator = 0
+	i.product = nil
+	if i.extensions != nil {
+		i.extensions.reset()
+	}
+}
+
+// banditValue returns a map with the value of the provider chosen by the bandit. All the providers share the
+// same keyword in this mode.
+func (i *MainInputProvider) banditValue() map[string][]byte {
+	values := make(map[string][]byte)
+	available := make([]bool, len(i.Providers))
+	for idx, p := range i.Providers {
+		available[idx] = p.Active() && p.Next()
+	}
+	i.lastArm = i.bandit.choose(available)
+	if i.lastArm == -1 {
+		return values
+	}
+	p := i.Providers[i.lastArm]
+	values[p.Keyword()] = p.Value()
+	p.IncrementPosition()
+	return values
+}
+
+// Feedback credits the provider of a value sent in bandit mode with the reward of its request, between 0 and 1
+func (i *MainInputProvider) Feedback(input map[string][]byte, reward float64) {
+	if i.bandit == nil || len(i.Providers) == 0 {
+		return
+	}
+	val := string(input[i.Providers[0].Keyword()])
+	i.banditMutex.Lock()
+	arms := i.banditArms[val]
+	if len(arms) == 0 {
+		i.banditMutex.Unlock()
+		return
+	}
+	arm := arms[0]
+	if len(arms) == 1 {
+		delete(i.banditArms, val)
+	} else {
+		i.banditArms[val] = arms[1:]
+	}
+	i.banditMutex.Unlock()
+	i.bandit.reward(arm, reward)
+}
+
+// Allocation returns the current share of the requests taken from each provider in bandit mode
+func (i *MainInputProvider) Allocation() string {
+	if i.bandit == nil {
+		return ""
+	}
+	return i.bandit.String()
+}
+
+// Summary returns the allocation and reward rate of each provider in bandit mode
+func (i *MainInputProvider) Summary() []string {
+	if i.bandit == nil {
+		return []string{}
+	}
+	return i.bandit.summary()
 }
 
 // pitchforkValue returns a map of keyword:value pairs including all inputs.
@@ -203,7 +363,7 @@ func (i *MainInputProvider) clusterbombValue() map[string][]byte {
 
 func (i *MainInputProvider) setclusterbombPosition(pos int) {
 	i.Reset()
-	if pos > i.Total() {
+	if pos > i.baseTotal() {
 		// noop
 		return
 	}
@@ -229,8 +389,20 @@ func (i *MainInputProvider) clusterbombIteratorReset() {
 	}
 }
 
-// Total returns the amount of input combinations available
+// Total returns the amount of input combinations available, along with the inputs added by the expansion of the
+// %EXT% placeholder
 func (i *MainInputProvider) Total() int {
+	if i.extensions == nil {
+		return i.baseTotal()
+	}
+	if i.extensions.placeholders < 0 {
+		i.extensions.placeholders = i.countPlaceholders()
+	}
+	return i.baseTotal() + i.extensions.total()
+}
+
+// baseTotal returns the amount of input combinations of the providers
+func (i *MainInputProvider) baseTotal() int {
 	count := 0
 	if i.Config.InputMode == "pitchfork" {
 		for _, p := range i.Providers {
@@ -242,6 +414,13 @@ func (i *MainInputProvider) Total() int {
 			}
 		}
 	}
+	if i.Config.InputMode == "bandit" {
+		for _, p := range i.Providers {
+			if p.Active() {
+				count += p.Total()
+			}
+		}
+	}
 	if i.Config.InputMode == "clusterbomb" || i.Config.InputMode == "sniper" {
 		count = 1
 		for _, p := range i.Providers {
@@ -0,0 +1,161 @@
+package input
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// productFrontier is the largest number of combinations the best-first product keeps in its frontier. The
+// combinations produced past it wait in the order they were produced, the enumeration staying complete but no
+// longer strictly best first.
+const productFrontier = 4096
+
+// productEntry is a combination of the frontier, the index of the value of each keyword
+type productEntry struct {
+	index []int
+	score float64
+	seq   int // order the combination was produced in, breaking the ties
+}
+
+type productHeap []productEntry
+
+func (h productHeap) Len() int { return len(h) }
+func (h productHeap) Less(a, b int) bool {
+	if h[a].score != h[b].score {
+		return h[a].score > h[b].score
+	}
+	return h[a].seq < h[b].seq
+}
+func (h productHeap) Swap(a, b int)       { h[a], h[b] = h[b], h[a] }
+func (h *productHeap) Push(x interface{}) { *h = append(*h, x.(productEntry)) }
+func (h *productHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// bestFirstProduct enumerates the combinations of the values of the keywords in clusterbomb mode best first, by the
+// sum of the expected rewards of their values. The values of each keyword are sorted by their reward, and the
+// combinations are expanded lazily from the best one: the successors of a combination increment the index of one
+// keyword at or after the last nonzero index, so that each combination is produced exactly once without remembering
+// the ones produced.
+type bestFirstProduct struct {
+	keywords []string
+	values   [][][]byte  // values of each keyword, best first
+	scores   [][]float64 // expected rewards of the values of each keyword, in the order of values
+	frontier productHeap
+	overflow [][]int // combinations produced while the frontier was full
+	limit    int
+	seq      int
+}
+
+// newBestFirstProduct returns the product of the values of the keywords ranked with the expected rewards of the
+// ranker. The values of a keyword with the same reward keep their wordlist order.
+func newBestFirstProduct(keywords []string, values [][][]byte, ranker ffuf.ValueRanker, limit int) *bestFirstProduct {
+	p := &bestFirstProduct{keywords: keywords, limit: limit}
+	for k, keyword := range keywords {
+		rewards := ranker.ValueRewards(keyword, values[k])
+		order := make([]int, len(values[k]))
+		for idx := range order {
+			order[idx] = idx
+		}
+		sort.SliceStable(order, func(a, b int) bool { return rewards[order[a]] > rewards[order[b]] })
+		sorted := make([][]byte, len(order))
+		scores := make([]float64, len(order))
+		for idx, o := range order {
+			sorted[idx], scores[idx] = values[k][o], rewards[o]
+		}
+		p.values = append(p.values, sorted)
+		p.scores = append(p.scores, scores)
+		if len(sorted) == 0 {
+			// An empty wordlist has no combinations
+			return p
+		}
+	}
+	p.push(make([]int, len(keywords)))
+	return p
+}
+
+// push adds a combination to the frontier, or to the overflow when the frontier is full
+func (p *bestFirstProduct) push(index []int) {
+	if len(p.frontier) >= p.limit {
+		p.overflow = append(p.overflow, index)
+		return
+	}
+	score := 0.0
+	for k, idx := range index {
+		score += p.scores[k][idx]
+	}
+	heap.Push(&p.frontier, productEntry{index: index, score: score, seq: p.seq})
+	p.seq++
+}
+
+// next returns the values of the next best combination, false once every combination was returned
+func (p *bestFirstProduct) next() (map[string][]byte, bool) {
+	for len(p.overflow) > 0 && len(p.frontier) < p.limit {
+		index := p.overflow[0]
+		p.overflow = p.overflow[1:]
+		p.push(index)
+	}
+	if len(p.frontier) == 0 {
+		return nil, false
+	}
+	e := heap.Pop(&p.frontier).(productEntry)
+	last := 0
+	for k, idx := range e.index {
+		if idx > 0 {
+			last = k
+		}
+	}
+	for k := last; k < len(e.index); k++ {
+		if e.index[k]+1 < len(p.values[k]) {
+			index := append([]int{}, e.index...)
+			index[k]++
+			p.push(index)
+		}
+	}
+	values := make(map[string][]byte, len(p.keywords))
+	for k, keyword := range p.keywords {
+		values[keyword] = p.values[k][e.index[k]]
+	}
+	return values, true
+}
+
+// SetValueRanker sets the ranker of the values of the keywords, enumerating the combinations of the wordlists best
+// first in clusterbomb mode
+func (i *MainInputProvider) SetValueRanker(ranker ffuf.ValueRanker) {
+	i.ranker = ranker
+	i.product = nil
+}
+
+// bestFirstValue returns the next combination of the best-first product, building it on the first call of each pass
+// over the wordlists. Returns false if the combinations are not ranked: without a ranker, with less than two
+// keywords, or with a keyword not read from wordlists.
+func (i *MainInputProvider) bestFirstValue() (map[string][]byte, bool) {
+	if i.ranker == nil || i.Config.InputMode != "clusterbomb" {
+		return nil, false
+	}
+	if i.product == nil {
+		keywords := make([]string, 0)
+		values := make([][][]byte, 0)
+		for _, p := range i.Providers {
+			if !p.Active() {
+				continue
+			}
+			wp, ok := p.(interface{ Words() [][]byte })
+			if !ok || ffuf.StrInSlice(p.Keyword(), keywords) {
+				return nil, false
+			}
+			keywords = append(keywords, p.Keyword())
+			values = append(values, wp.Words())
+		}
+		if len(keywords) < 2 {
+			return nil, false
+		}
+		i.product = newBestFirstProduct(keywords, values, i.ranker, productFrontier)
+	}
+	return i.product.next()
+}
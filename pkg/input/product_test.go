@@ -0,0 +1,138 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// staticRanker gives the values of each keyword a fixed reward
+type staticRanker map[string]map[string]float64
+
+func (r staticRanker) ValueRewards(keyword string, values [][]byte) []float64 {
+	rewards := make([]float64, len(values))
+	for idx, v := range values {
+		rewards[idx] = r[keyword][string(v)]
+	}
+	return rewards
+}
+
+func words(values ...string) [][]byte {
+	w := make([][]byte, 0, len(values))
+	for _, v := range values {
+		w = append(w, []byte(v))
+	}
+	return w
+}
+
+func TestBestFirstProductOrder(t *testing.T) {
+	ranker := staticRanker{
+		"USER": {"admin": 3, "root": 2, "guest": 0},
+		"PASS": {"secret": 1.5, "admin": 0.5, "123456": 0},
+	}
+	p := newBestFirstProduct([]string{"USER", "PASS"}, [][][]byte{words("guest", "root", "admin"), words("123456", "admin", "secret")}, ranker, productFrontier)
+	expected := []string{
+		"admin:secret", // 4.5
+		"root:secret",  // 3.5
+		"admin:admin",  // 3.5, produced after root:secret
+		"admin:123456", // 3
+		"root:admin",   // 2.5
+		"root:123456",  // 2
+		"guest:secret", // 1.5
+		"guest:admin",  // 0.5
+		"guest:123456", // 0
+	}
+	got := make([]string, 0)
+	for {
+		values, ok := p.next()
+		if !ok {
+			break
+		}
+		got = append(got, string(values["USER"])+":"+string(values["PASS"]))
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected the combinations best first %v, got %v", expected, got)
+	}
+}
+
+func TestBestFirstProductComplete(t *testing.T) {
+	ranker := staticRanker{"A": {}, "B": {}, "C": {}}
+	values := make([][][]byte, 0)
+	for k, keyword := range []string{"A", "B", "C"} {
+		kw := make([][]byte, 0)
+		for idx := 0; idx < 4+k; idx++ {
+			value := fmt.Sprintf("%s%d", keyword, idx)
+			ranker[keyword][value] = float64((idx*7+k*3)%5) / 2
+			kw = append(kw, []byte(value))
+		}
+		values = append(values, kw)
+	}
+	for _, limit := range []int{productFrontier, 3} {
+		p := newBestFirstProduct([]string{"A", "B", "C"}, values, ranker, limit)
+		seen := make(map[string]bool)
+		last := -1.0
+		for {
+			v, ok := p.next()
+			if !ok {
+				break
+			}
+			key := string(v["A"]) + string(v["B"]) + string(v["C"])
+			if seen[key] {
+				t.Errorf("Combination %s produced twice with a frontier of %d", key, limit)
+			}
+			seen[key] = true
+			score := ranker["A"][string(v["A"])] + ranker["B"][string(v["B"])] + ranker["C"][string(v["C"])]
+			if limit == productFrontier && last >= 0 && score > last {
+				t.Errorf("Combination %s of score %f produced after a score of %f", key, score, last)
+			}
+			last = score
+			if limit != productFrontier && len(p.frontier) > limit {
+				t.Errorf("Expected the frontier to stay within %d, got %d", limit, len(p.frontier))
+			}
+		}
+		if len(seen) != 4*5*6 {
+			t.Errorf("Expected every combination to be produced with a frontier of %d, got %d", limit, len(seen))
+		}
+	}
+}
+
+func TestBestFirstClusterbomb(t *testing.T) {
+	dir := t.TempDir()
+	conf := ffuf.NewConfig(context.Background(), func() {})
+	conf.InputMode = "clusterbomb"
+	for keyword, content := range map[string]string{"USER": "guest\nadmin\n", "PASS": "123456\nsecret\n"} {
+		path := filepath.Join(dir, keyword)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Could not write the wordlist: %s", err)
+		}
+		conf.InputProviders = append(conf.InputProviders, ffuf.InputProviderConfig{Name: "wordlist", Value: path, Keyword: keyword})
+	}
+	ip, errs := NewInputProvider(&conf)
+	if errs.ErrorOrNil() != nil {
+		t.Fatalf("Could not create the input provider: %s", errs.ErrorOrNil())
+	}
+	read := func() []string {
+		values := make([]string, 0)
+		for ip.Next() {
+			v := ip.Value()
+			values = append(values, string(v["USER"])+":"+string(v["PASS"]))
+		}
+		return values
+	}
+	// The wordlists are looped over without a ranker
+	nested := read()
+	ip.Reset()
+	ip.(ffuf.ValueOrderer).SetValueRanker(staticRanker{"USER": {"admin": 1}, "PASS": {"secret": 2}})
+	ranked := read()
+	if ranked[0] != "admin:secret" || ranked[len(ranked)-1] != "guest:123456" || len(ranked) != ip.Total() {
+		t.Errorf("Expected the combinations best first, got %v", ranked)
+	}
+	if nested[0] != "guest:123456" || len(nested) != len(ranked) {
+		t.Errorf("Expected the combinations in wordlist order without a ranker, got %v", nested)
+	}
+}
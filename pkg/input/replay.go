@@ -0,0 +1,26 @@
+package input
+
+import (
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// ReplayInput provides the values of a keyword in the results of a previous run replayed with -replay-from, in
+// the order of the report. The keywords of a result are replayed together in pitchfork mode.
+type ReplayInput struct {
+	WordlistInput
+}
+
+func NewReplayInput(keyword string, value string, conf *ffuf.Config) (*ReplayInput, error) {
+	var rp ReplayInput
+	rp.active = true
+	rp.keyword = keyword
+	rp.config = conf
+	report, err := ffuf.ReadReplayReport(value)
+	if err != nil {
+		return &rp, err
+	}
+	for _, input := range report.Inputs(conf.ReplayMinReward) {
+		rp.data = append(rp.data, []byte(input[keyword]))
+	}
+	return &rp, nil
+}
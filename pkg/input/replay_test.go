@@ -0,0 +1,134 @@
+package input
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+	"github.com/ffuf/ffuf/v2/pkg/filter"
+	"github.com/ffuf/ffuf/v2/pkg/output"
+	"github.com/ffuf/ffuf/v2/pkg/runner"
+)
+
+// replayServer answers 200 to /admin and 403 to /backup, recording the paths and the X-Replay header
+type replayServer struct {
+	paths  []string
+	header string
+	mutex  sync.Mutex
+}
+
+func (s *replayServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.paths = append(s.paths, r.URL.Path)
+	s.header = r.Header.Get("X-Replay")
+	s.mutex.Unlock()
+	switch r.URL.Path {
+	case "/admin":
+		w.WriteHeader(http.StatusOK)
+	case "/backup":
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func runReplay(t *testing.T, opts *ffuf.ConfigOptions) *ffuf.Job {
+	t.Helper()
+	ffuf.HISTORYDIR = t.TempDir()
+	conf, err := ffuf.ConfigFromOptions(opts, context.Background(), func() {})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	conf.Quiet = true
+	conf.Noninteractive = true
+	conf.Threads = 1
+	conf.MatcherManager = filter.NewMatcherManager()
+	if err := conf.MatcherManager.AddMatcher("status", "200,403"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	j := ffuf.NewJob(conf)
+	var errs ffuf.Multierror
+	j.Input, errs = NewInputProvider(conf)
+	if errs.ErrorOrNil() != nil {
+		t.Fatalf("Unexpected error: %s", errs.ErrorOrNil())
+	}
+	j.Runner = runner.NewRunnerByName("http", conf, false)
+	j.Output = output.NewOutputProviderByName("stdout", conf)
+	j.Start()
+	return j
+}
+
+func TestReplayFromReport(t *testing.T) {
+	srv := &replayServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	opts := ffuf.NewConfigOptions()
+	opts.HTTP.URL = ts.URL + "/FUZZ"
+	opts.Input.ReplayFrom = filepath.Join("testdata", "replay_report.json")
+	opts.Input.ReplayMinReward = 1.0
+	j := runReplay(t, opts)
+
+	// Only the results rewarded at least 1.0 are replayed, in the order of the report
+	if expected := []string{"/admin", "/backup"}; !reflect.DeepEqual(srv.paths, expected) {
+		t.Errorf("Expected the requests %v, got %v", expected, srv.paths)
+	}
+	if results := j.Output.GetCurrentResults(); len(results) != 2 {
+		t.Errorf("Expected the replayed requests to go through the matchers, got %d results", len(results))
+	}
+	if srv.header != "" {
+		t.Errorf("Expected the request of the report not to be used with -u")
+	}
+}
+
+func TestReplayRawRequestReport(t *testing.T) {
+	srv := &replayServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	// The report of a run with -request has the Host header of the raw request, point it to the test server
+	content, err := os.ReadFile(filepath.Join("testdata", "replay_report.json"))
+	if err != nil {
+		t.Fatalf("Could not read the fixture: %s", err)
+	}
+	report := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(report, []byte(strings.ReplaceAll(string(content), "staging.example.org", u.Host)), 0600); err != nil {
+		t.Fatalf("Could not write the report: %s", err)
+	}
+
+	opts := ffuf.NewConfigOptions()
+	opts.Input.ReplayFrom = report
+	opts.Input.ReplayMinReward = 0
+	runReplay(t, opts)
+
+	if expected := []string{"/admin", "/index", "/backup"}; !reflect.DeepEqual(srv.paths, expected) {
+		t.Errorf("Expected the requests %v, got %v", expected, srv.paths)
+	}
+	if srv.header != "yes" {
+		t.Errorf("Expected the headers of the report to be sent, got %q", srv.header)
+	}
+}
+
+func TestReplayOptions(t *testing.T) {
+	opts := ffuf.NewConfigOptions()
+	opts.Input.ReplayFrom = filepath.Join("testdata", "replay_report.json")
+	opts.Input.ReplayMinReward = 5
+	if _, err := ffuf.ConfigFromOptions(opts, context.Background(), func() {}); err == nil || !strings.Contains(err.Error(), "No result") {
+		t.Errorf("Expected an error when no result has the minimum reward, got %v", err)
+	}
+
+	opts.Input.ReplayMinReward = 0
+	opts.Input.Wordlists = []string{"/dev/null"}
+	if _, err := ffuf.ConfigFromOptions(opts, context.Background(), func() {}); err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+		t.Errorf("Expected an error when combining -replay-from with -w, got %v", err)
+	}
+}
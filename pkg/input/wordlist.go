@@ -3,7 +3,6 @@ package input
 import (
 	"bufio"
 	"os"
-	"regexp"
 	"strings"
 
 	"github.com/ffuf/ffuf/v2/pkg/ffuf"
@@ -82,6 +81,22 @@ func (w *WordlistInput) Total() int {
 	return len(w.data)
 }
 
+// Words returns all the words of the wordlist
+func (w *WordlistInput) Words() [][]byte {
+	return w.data
+}
+
+// shard keeps only the k-th of every n words, k starting from 1
+func (w *WordlistInput) shard(k int, n int) {
+	data := make([][]byte, 0, len(w.data)/n+1)
+	for idx, word := range w.data {
+		if idx%n == k-1 {
+			data = append(data, word)
+		}
+	}
+	w.data = data
+}
+
 // Active returns boolean if the inputprovider is active
 func (w *WordlistInput) Active() bool {
 	return w.active
@@ -128,15 +143,12 @@ func (w *WordlistInput) readFile(path string) error {
 	var data [][]byte
 	var ok bool
 	reader := bufio.NewScanner(file)
-	re := regexp.MustCompile(`(?i)%ext%`)
 	for reader.Scan() {
 		if w.config.DirSearchCompat && len(w.config.Extensions) > 0 {
 			text := []byte(reader.Text())
-			if re.Match(text) {
-				for _, ext := range w.config.Extensions {
-					contnt := re.ReplaceAll(text, []byte(ext))
-					data = append(data, []byte(contnt))
-				}
+			if extPlaceholder.Match(text) {
+				// Expanded by the input provider, for the extensions to follow the ones the target serves
+				data = append(data, text)
 			} else {
 				text := reader.Text()
 
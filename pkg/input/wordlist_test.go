@@ -1,7 +1,12 @@
 package input
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
 )
 
 func TestStripCommentsIgnoresCommentLines(t *testing.T) {
@@ -19,3 +24,31 @@ func TestStripCommentsStripsCommentAfterText(t *testing.T) {
 		t.Errorf("Comment was not stripped or pre-comment text was not returned")
 	}
 }
+
+func TestWordlistShards(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wordlist.txt")
+	if err := os.WriteFile(path, []byte("a\nb\nc\nd\ne\nf\ng\n"), 0644); err != nil {
+		t.Fatalf("Could not write the wordlist: %s", err)
+	}
+	conf := ffuf.NewConfig(context.Background(), func() {})
+	expected := []string{"adg", "be", "cf"}
+	covered := ""
+	for k := 1; k <= 3; k++ {
+		wl, err := NewWordlistInput("FUZZ", path, &conf)
+		if err != nil {
+			t.Fatalf("Could not read the wordlist: %s", err)
+		}
+		wl.shard(k, 3)
+		words := ""
+		for _, w := range wl.Words() {
+			words += string(w)
+		}
+		if words != expected[k-1] {
+			t.Errorf("Expected the shard %d/3 to be %s, got %s", k, expected[k-1], words)
+		}
+		covered += words
+	}
+	if len(covered) != 7 {
+		t.Errorf("Expected the shards to cover the 7 words once, got %s", covered)
+	}
+}
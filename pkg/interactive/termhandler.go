@@ -3,6 +3,7 @@ package interactive
 import (
 	"bufio"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -13,10 +14,11 @@ import (
 type interactive struct {
 	Job    *ffuf.Job
 	paused bool
+	scoped bool // filters are set for the recursion root of the current job rather than globally
 }
 
 func Handle(job *ffuf.Job) error {
-	i := interactive{job, false}
+	i := interactive{Job: job}
 	tty, err := termHandle()
 	if err != nil {
 		return err
@@ -164,6 +166,8 @@ func (i *interactive) handleInput(in []byte) {
 				i.appendFilter("time", args[1])
 				i.Job.Output.Info("New response time filter value set")
 			}
+		case "scope":
+			i.handleScope(args)
 		case "queueshow":
 			i.printQueue()
 		case "queuedel":
@@ -177,6 +181,10 @@ func (i *interactive) handleInput(in []byte) {
 		case "queueskip":
 			i.Job.SkipQueue()
 			i.Job.Output.Info("Skipping to the next queued job")
+		case "queue":
+			i.handleQueue(args)
+		case "markov":
+			i.handleMarkov(args)
 		case "rate":
 			if len(args) < 2 {
 				i.Job.Output.Error("Please define the new rate")
@@ -226,7 +234,14 @@ func (i *interactive) refreshResults() {
 }
 
 func (i *interactive) updateFilter(name, value string, replace bool) {
-	if value == "none" {
+	if i.scoped {
+		root := i.Job.RecursionRoot()
+		if value == "none" {
+			i.Job.Config.MatcherManager.RemoveScopedFilter(root, name)
+		} else {
+			_ = i.Job.Config.MatcherManager.AddScopedFilter(root, name, value, replace)
+		}
+	} else if value == "none" {
 		i.Job.Config.MatcherManager.RemoveFilter(name)
 	} else {
 		_ = i.Job.Config.MatcherManager.AddFilter(name, value, replace)
@@ -234,6 +249,35 @@ func (i *interactive) updateFilter(name, value string, replace bool) {
 	i.refreshResults()
 }
 
+// handleScope sets whether the filters are set for the recursion root of the current job or globally, or lists the
+// filters scoped to recursion roots
+func (i *interactive) handleScope(args []string) {
+	if len(args) == 1 {
+		lines := i.Job.ScopedFilterSummary()
+		if len(lines) == 0 {
+			i.Job.Output.Raw("no filters scoped to a recursion root\n")
+		}
+		for _, line := range lines {
+			i.Job.Output.Raw(line + "\n")
+		}
+		return
+	}
+	if len(args) > 2 {
+		i.Job.Output.Error("Too many arguments for \"scope\"")
+		return
+	}
+	switch args[1] {
+	case "root":
+		i.scoped = true
+		i.Job.Output.Info(fmt.Sprintf("The filters are now set for the recursion root %s", i.Job.RecursionRoot()))
+	case "global":
+		i.scoped = false
+		i.Job.Output.Info("The filters are now set globally")
+	default:
+		i.Job.Output.Error("Usage: scope [root|global]")
+	}
+}
+
 func (i *interactive) appendFilter(name, value string) {
 	i.updateFilter(name, value, false)
 }
@@ -268,6 +312,139 @@ func (i *interactive) deleteQueue(in string) {
 		}
 	}
 }
+
+// queueCommand is a parsed "queue show [n]" or "queue drop <pattern>" command
+type queueCommand struct {
+	action  string
+	count   int
+	pattern string
+}
+
+// defaultQueueShow is the number of pending inputs "queue show" lists by default
+const defaultQueueShow = 10
+
+func parseQueueCommand(args []string) (queueCommand, error) {
+	usage := fmt.Errorf("Usage: queue show [n] | queue drop [pattern]")
+	if len(args) < 2 {
+		return queueCommand{}, usage
+	}
+	switch args[1] {
+	case "show":
+		if len(args) > 3 {
+			return queueCommand{}, usage
+		}
+		cmd := queueCommand{action: "show", count: defaultQueueShow}
+		if len(args) == 3 {
+			count, err := strconv.Atoi(args[2])
+			if err != nil || count < 1 {
+				return queueCommand{}, fmt.Errorf("Not a positive number: %s", args[2])
+			}
+			cmd.count = count
+		}
+		return cmd, nil
+	case "drop":
+		if len(args) != 3 {
+			return queueCommand{}, usage
+		}
+		return queueCommand{action: "drop", pattern: args[2]}, nil
+	}
+	return queueCommand{}, usage
+}
+
+func (i *interactive) handleQueue(args []string) {
+	cmd, err := parseQueueCommand(args)
+	if err != nil {
+		i.Job.Output.Error(err.Error())
+		return
+	}
+	if i.Job.MarkovChain == nil {
+		i.Job.Output.Error("The pending inputs are only queued with -markov")
+		return
+	}
+	if cmd.action == "drop" {
+		dropped, err := i.Job.DropPendingInputs(cmd.pattern)
+		if err != nil {
+			i.Job.Output.Error(err.Error())
+		} else {
+			i.Job.Output.Info(fmt.Sprintf("Dropped %d pending inputs", dropped))
+		}
+		return
+	}
+	pending := i.Job.PendingInputs(cmd.count)
+	if len(pending) == 0 {
+		i.Job.Output.Info("No pending inputs")
+		return
+	}
+	i.Job.Output.Raw("Pending inputs:\n")
+	for index, p := range pending {
+		i.Job.Output.Raw(fmt.Sprintf(" [%d] : %s (%s)\n", index, formatInput(p.Input), p.Priority))
+	}
+}
+
+// markovCommand is a parsed "markov reload <filename>", "markov explain <word>" or "markov set <param> <value>"
+// command
+type markovCommand struct {
+	action string
+	arg    string
+	value  float64
+}
+
+func parseMarkovCommand(args []string) (markovCommand, error) {
+	usage := fmt.Errorf("Usage: markov reload [filename] | markov explain [word] | markov set [param] [value]")
+	if len(args) < 3 {
+		return markovCommand{}, usage
+	}
+	switch args[1] {
+	case "reload", "explain":
+		if len(args) != 3 {
+			return markovCommand{}, usage
+		}
+		return markovCommand{action: args[1], arg: args[2]}, nil
+	case "set":
+		if len(args) != 4 {
+			return markovCommand{}, usage
+		}
+		value, err := strconv.ParseFloat(args[3], 64)
+		if err != nil {
+			return markovCommand{}, fmt.Errorf("Not a number: %s", args[3])
+		}
+		return markovCommand{action: "set", arg: args[2], value: value}, nil
+	}
+	return markovCommand{}, usage
+}
+
+func (i *interactive) handleMarkov(args []string) {
+	cmd, err := parseMarkovCommand(args)
+	if err != nil {
+		i.Job.Output.Error(err.Error())
+		return
+	}
+	switch cmd.action {
+	case "reload":
+		i.Job.ReloadMarkovChain(cmd.arg)
+	case "explain":
+		i.Job.ExplainMarkovRanking(cmd.arg)
+	case "set":
+		i.Job.SetMarkovParam(cmd.arg, cmd.value)
+	}
+}
+
+// formatInput formats the values of an input by keyword, sorted by keyword
+func formatInput(input map[string][]byte) string {
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		if k != "FFUFHASH" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, fmt.Sprintf("%s: %s", k, input[k]))
+	}
+	return strings.Join(values, ", ")
+}
+
 func (i *interactive) printBanner() {
 	i.Job.Output.Raw("entering interactive mode\ntype \"help\" for a list of commands, or ENTER to resume.\n")
 }
@@ -278,7 +455,13 @@ func (i *interactive) printPrompt() {
 
 func (i *interactive) printHelp() {
 	var fc, fl, fs, ft, fw string
-	for name, filter := range i.Job.Config.MatcherManager.GetFilters() {
+	filters := i.Job.Config.MatcherManager.GetFilters()
+	scope := "(active: global)"
+	if i.scoped {
+		filters = i.Job.Config.MatcherManager.ScopedFilters()[i.Job.RecursionRoot()]
+		scope = "(active: " + i.Job.RecursionRoot() + ")"
+	}
+	for name, filter := range filters {
 		switch name {
 		case "status":
 			fc = "(active: " + filter.Repr() + ")"
@@ -306,14 +489,21 @@ available commands:
  aft  [value]             - append to time filter %s
  ft   [value]             - (re)configure time filter %s
  rate [value]             - adjust rate of requests per second %s
+ scope [root|global]      - set the filters for the recursion root of the current job or globally %s
+ scope                    - list the filters set for recursion roots
+ markov reload [filename] - merge a saved Markov chain into the running one
+ markov explain [word]    - explain how the Markov chain scores a word when ranking the inputs
+ markov set [param] [val] - change a learning parameter or a reward weight of the Markov chain, eg. "markov set auth 0.5"
  queueshow                - show job queue
  queuedel [number]        - delete a job in the queue
  queueskip                - advance to the next queued job
+ queue show [n]           - show the next n inputs queued by the Markov chain, 10 by default
+ queue drop [pattern]     - drop the pending inputs having a value matching the glob pattern
  restart                  - restart and resume the current ffuf job
  resume                   - resume current ffuf job (or: ENTER) 
  show                     - show results for the current job
  savejson [filename]      - save current matches to a file
  help                     - you are looking at it
 `
-	i.Job.Output.Raw(fmt.Sprintf(help, fc, fc, fl, fl, fw, fw, fs, fs, ft, ft, rate))
+	i.Job.Output.Raw(fmt.Sprintf(help, fc, fc, fl, fl, fw, fw, fs, fs, ft, ft, rate, scope))
 }
@@ -0,0 +1,68 @@
+package interactive
+
+import (
+	"testing"
+)
+
+func TestParseQueueCommand(t *testing.T) {
+	tests := []struct {
+		in       []string
+		expected queueCommand
+		err      bool
+	}{
+		{[]string{"queue", "show"}, queueCommand{action: "show", count: defaultQueueShow}, false},
+		{[]string{"queue", "show", "25"}, queueCommand{action: "show", count: 25}, false},
+		{[]string{"queue", "drop", "*.bak"}, queueCommand{action: "drop", pattern: "*.bak"}, false},
+		{[]string{"queue"}, queueCommand{}, true},
+		{[]string{"queue", "show", "0"}, queueCommand{}, true},
+		{[]string{"queue", "show", "ten"}, queueCommand{}, true},
+		{[]string{"queue", "show", "1", "2"}, queueCommand{}, true},
+		{[]string{"queue", "drop"}, queueCommand{}, true},
+		{[]string{"queue", "drop", "a", "b"}, queueCommand{}, true},
+		{[]string{"queue", "clear"}, queueCommand{}, true},
+	}
+	for _, tt := range tests {
+		cmd, err := parseQueueCommand(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("%v: expected error %t, got %v", tt.in, tt.err, err)
+		}
+		if cmd != tt.expected {
+			t.Errorf("%v: expected %+v, got %+v", tt.in, tt.expected, cmd)
+		}
+	}
+}
+
+func TestFormatInput(t *testing.T) {
+	input := map[string][]byte{"FUZZ": []byte("admin"), "HOST": []byte("example.org"), "FFUFHASH": []byte("abcd1")}
+	if s := formatInput(input); s != "FUZZ: admin, HOST: example.org" {
+		t.Errorf("Unexpected formatting of the input: %s", s)
+	}
+}
+
+func TestParseMarkovCommand(t *testing.T) {
+	tests := []struct {
+		in       []string
+		expected markovCommand
+		err      bool
+	}{
+		{[]string{"markov", "reload", "chain.gob"}, markovCommand{action: "reload", arg: "chain.gob"}, false},
+		{[]string{"markov", "explain", "admin"}, markovCommand{action: "explain", arg: "admin"}, false},
+		{[]string{"markov", "set", "auth", "0.5"}, markovCommand{action: "set", arg: "auth", value: 0.5}, false},
+		{[]string{"markov", "set", "alpha", "-1"}, markovCommand{action: "set", arg: "alpha", value: -1}, false},
+		{[]string{"markov"}, markovCommand{}, true},
+		{[]string{"markov", "set", "auth"}, markovCommand{}, true},
+		{[]string{"markov", "set", "auth", "low"}, markovCommand{}, true},
+		{[]string{"markov", "set", "auth", "0.5", "1"}, markovCommand{}, true},
+		{[]string{"markov", "reload", "a", "b"}, markovCommand{}, true},
+		{[]string{"markov", "forget", "admin"}, markovCommand{}, true},
+	}
+	for _, tt := range tests {
+		cmd, err := parseMarkovCommand(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("%v: expected error %t, got %v", tt.in, tt.err, err)
+		}
+		if cmd != tt.expected {
+			t.Errorf("%v: expected %+v, got %+v", tt.in, tt.expected, cmd)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package markov
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultUpdateBuffer is the number of transitions queued to the updater goroutine before they are dropped
+	DefaultUpdateBuffer = 4096
+	// asyncPublishBatch is the number of transitions applied by the updater goroutine before it publishes a view
+	asyncPublishBatch = 1024
+	// asyncPublishInterval is the longest time the applied transitions wait to be published
+	asyncPublishInterval = 50 * time.Millisecond
+)
+
+// asyncUpdater applies the transitions queued by Observe in a goroutine of its own
+type asyncUpdater struct {
+	updates chan Transition
+	done    chan struct{}
+	closed  bool
+	mutex   sync.RWMutex // held for reading while queueing, so that the channel is not closed under a sender
+}
+
+// chainView is an immutable copy of the rows the actions are ranked from, published by the updater goroutine
+type chainView struct {
+	rows map[string]stateRows
+}
+
+// StartAsyncUpdates moves the updates of the chain to a goroutine of its own: Observe queues the transitions, up to
+// buffer of them, and the rankings read the rows last published by the goroutine instead of locking the chain. The
+// rows are published every 1024 transitions and at least every 50ms. StopAsyncUpdates must be called to apply the
+// queued transitions.
+func (mc *MarkovChain) StartAsyncUpdates(buffer int) {
+	mc.mutex.Lock()
+	if u, _ := mc.async.Load().(*asyncUpdater); u != nil {
+		mc.mutex.Unlock()
+		return
+	}
+	u := &asyncUpdater{updates: make(chan Transition, buffer), done: make(chan struct{})}
+	mc.dirty = make(map[string]bool)
+	mc.dirtyAll = true
+	mc.publish()
+	mc.async.Store(u)
+	mc.mutex.Unlock()
+
+	go mc.runUpdates(u)
+}
+
+// StopAsyncUpdates applies the queued transitions and returns to the inline updates, the rankings reading the chain
+// itself again
+func (mc *MarkovChain) StopAsyncUpdates() {
+	u, _ := mc.async.Load().(*asyncUpdater)
+	if u == nil {
+		return
+	}
+	u.mutex.Lock()
+	if !u.closed {
+		u.closed = true
+		close(u.updates)
+	}
+	u.mutex.Unlock()
+	<-u.done
+
+	mc.mutex.Lock()
+	mc.async.Store((*asyncUpdater)(nil))
+	mc.view.Store((*chainView)(nil))
+	mc.dirty = nil
+	mc.mutex.Unlock()
+}
+
+// Observe applies a transition to the chain, or queues it when the updates are asynchronous. A transition is dropped
+// and counted in DroppedUpdates when the queue is full.
+func (mc *MarkovChain) Observe(transition Transition) {
+	u, _ := mc.async.Load().(*asyncUpdater)
+	if u == nil {
+		mc.UpdateTransition(transition)
+		return
+	}
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	if u.closed {
+		mc.UpdateTransition(transition)
+		return
+	}
+	select {
+	case u.updates <- transition:
+	default:
+		atomic.AddInt64(&mc.droppedUpdates, 1)
+	}
+}
+
+// DroppedUpdates returns the number of transitions dropped because the queue of the updater goroutine was full
+func (mc *MarkovChain) DroppedUpdates() int64 {
+	return atomic.LoadInt64(&mc.droppedUpdates)
+}
+
+// runUpdates applies the queued transitions until the queue is closed, publishing the rows they changed
+func (mc *MarkovChain) runUpdates(u *asyncUpdater) {
+	defer close(u.done)
+	ticker := time.NewTicker(asyncPublishInterval)
+	defer ticker.Stop()
+
+	pending := 0
+	publish := func() {
+		mc.mutex.Lock()
+		mc.publish()
+		mc.mutex.Unlock()
+		pending = 0
+	}
+	for {
+		select {
+		case transition, ok := <-u.updates:
+			if !ok {
+				publish()
+				return
+			}
+			mc.UpdateTransition(transition)
+			pending++
+			if pending >= asyncPublishBatch {
+				publish()
+			}
+		case <-ticker.C:
+			if pending > 0 {
+				publish()
+			}
+		}
+	}
+}
+
+// markDirty marks the rows of a state as changed since the last published view. Must be called with the lock held.
+func (mc *MarkovChain) markDirty(stateKey string) {
+	if mc.dirty != nil {
+		mc.dirty[stateKey] = true
+	}
+}
+
+// publish publishes a view of the rows of the states, copying the ones changed since the last view. Must be called
+// with the lock held.
+func (mc *MarkovChain) publish() {
+	previous, _ := mc.view.Load().(*chainView)
+	rows := make(map[string]stateRows, len(mc.QTable))
+	for stateKey := range mc.QTable {
+		if previous != nil && !mc.dirtyAll && !mc.dirty[stateKey] {
+			if r, ok := previous.rows[stateKey]; ok {
+				rows[stateKey] = r
+				continue
+			}
+		}
+		r := stateRows{
+			q:             copyFloatMap(mc.QTable[stateKey]),
+			counts:        copyIntMap(mc.ActionCounts[stateKey]),
+			features:      make(map[Feature]float64, len(mc.FeatureQTable[stateKey])),
+			featureCounts: make(map[Feature]int, len(mc.FeatureCounts[stateKey])),
+		}
+		for f, v := range mc.FeatureQTable[stateKey] {
+			r.features[f] = v
+		}
+		for f, n := range mc.FeatureCounts[stateKey] {
+			r.featureCounts[f] = n
+		}
+		rows[stateKey] = r
+	}
+	mc.view.Store(&chainView{rows: rows})
+	mc.dirty = make(map[string]bool)
+	mc.dirtyAll = false
+}
+
+// publishedView returns the view published by the updater goroutine, nil when the updates are inline
+func (mc *MarkovChain) publishedView() *chainView {
+	view, _ := mc.view.Load().(*chainView)
+	return view
+}
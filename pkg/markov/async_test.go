@@ -0,0 +1,125 @@
+package markov
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAsyncUpdatesDrainOnStop(t *testing.T) {
+	mc := NewMarkovChain()
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	to := State{CodeClass: "2xx", SizeBucket: "1000"}
+	mc.StartAsyncUpdates(10000)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				mc.Observe(Transition{FromState: from, Action: Action{Token: fmt.Sprintf("word%d", w)}, ToState: to, Reward: 1.0})
+			}
+		}(w)
+	}
+	wg.Wait()
+	mc.StopAsyncUpdates()
+
+	if dropped := mc.DroppedUpdates(); dropped != 0 {
+		t.Fatalf("No update should be dropped with a large enough buffer, %d were", dropped)
+	}
+	if transitions := mc.Stats().Transitions; transitions != 8000 {
+		t.Errorf("All the queued updates should be applied on stop, got %d transitions of 8000", transitions)
+	}
+	if count := mc.ActionCounts[from.Hash()][Action{Token: "word3"}.Key()]; count != 1000 {
+		t.Errorf("Expected 1000 observations of word3, got %d", count)
+	}
+
+	// Back to the inline updates once stopped
+	mc.Observe(Transition{FromState: from, Action: Action{Token: "late"}, ToState: to, Reward: 1.0})
+	if transitions := mc.Stats().Transitions; transitions != 8001 {
+		t.Errorf("Updates after the stop should be applied inline, got %d transitions", transitions)
+	}
+}
+
+func TestAsyncUpdatesDropWhenFull(t *testing.T) {
+	mc := NewMarkovChain()
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	to := State{CodeClass: "2xx", SizeBucket: "1000"}
+	mc.StartAsyncUpdates(4)
+
+	// Hold the lock so that the updater goroutine cannot apply more than the update it took from the queue
+	mc.mutex.Lock()
+	for i := 0; i < 20; i++ {
+		mc.Observe(Transition{FromState: from, Action: Action{Token: "admin"}, ToState: to, Reward: 1.0})
+	}
+	mc.mutex.Unlock()
+	mc.StopAsyncUpdates()
+
+	dropped := mc.DroppedUpdates()
+	if dropped < 20-5 || dropped > 20-4 {
+		t.Errorf("Expected the updates past the buffer of 4 to be dropped, %d of 20 were", dropped)
+	}
+	if applied := mc.Stats().Transitions; applied+dropped != 20 {
+		t.Errorf("Every update should be applied or dropped, %d applied and %d dropped of 20", applied, dropped)
+	}
+	if !strings.Contains(mc.Summary(), fmt.Sprintf("updates dropped: %d", dropped)) {
+		t.Errorf("The dropped updates should be part of the summary: %s", mc.Summary())
+	}
+}
+
+func TestAsyncUpdatesRankFromPublishedView(t *testing.T) {
+	mc := NewMarkovChain()
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	to := State{CodeClass: "2xx", SizeBucket: "1000"}
+	mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "admin"}, ToState: to, Reward: 1.0})
+	mc.StartAsyncUpdates(100)
+
+	// The rankings read the published view, and do not wait for the lock held by a writer
+	mc.mutex.Lock()
+	best := mc.GetBestActionsForState(from, []string{"login", "admin"}, 1)
+	mc.mutex.Unlock()
+	if len(best) != 1 || best[0] != "admin" {
+		t.Errorf("Expected admin ranked first from the published view, got %v", best)
+	}
+
+	for i := 0; i < 5; i++ {
+		mc.Observe(Transition{FromState: from, Action: Action{Token: "login"}, ToState: to, Reward: 10.0})
+	}
+	mc.StopAsyncUpdates()
+	if best := mc.GetBestActionsForState(from, []string{"admin", "login"}, 1); len(best) != 1 || best[0] != "login" {
+		t.Errorf("Expected login ranked first once its updates were applied, got %v", best)
+	}
+}
+
+func TestPublishCopiesChangedRows(t *testing.T) {
+	mc := NewMarkovChain()
+	a := State{CodeClass: "4xx", SizeBucket: "100"}
+	b := State{CodeClass: "3xx", SizeBucket: "100"}
+	to := State{CodeClass: "2xx", SizeBucket: "1000"}
+	mc.UpdateTransition(Transition{FromState: a, Action: Action{Token: "admin"}, ToState: to, Reward: 1.0})
+	mc.UpdateTransition(Transition{FromState: b, Action: Action{Token: "admin"}, ToState: to, Reward: 1.0})
+	mc.StartAsyncUpdates(100)
+	defer mc.StopAsyncUpdates()
+	first := mc.publishedView()
+	key := Action{Token: "admin"}.Key()
+	before := first.rows[a.Hash()].q[key]
+
+	mc.UpdateTransition(Transition{FromState: a, Action: Action{Token: "admin"}, ToState: to, Reward: 5.0})
+	mc.mutex.Lock()
+	mc.publish()
+	mc.mutex.Unlock()
+	second := mc.publishedView()
+
+	if first.rows[a.Hash()].q[key] != before {
+		t.Errorf("A published view should never change, the Q-value of admin went from %f to %f", before, first.rows[a.Hash()].q[key])
+	}
+	if second.rows[a.Hash()].q[key] <= before {
+		t.Errorf("The changed state should be copied again, got Q-value %f, was %f", second.rows[a.Hash()].q[key], before)
+	}
+	if reflect.ValueOf(second.rows[b.Hash()].q).Pointer() != reflect.ValueOf(first.rows[b.Hash()].q).Pointer() {
+		t.Errorf("The unchanged state should share its rows with the previous view")
+	}
+}
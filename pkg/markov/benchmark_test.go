@@ -0,0 +1,255 @@
+package markov
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newBenchmarkChain returns a chain with the given amount of actions already taken from a single state
+func newBenchmarkChain(actions int) (*MarkovChain, State, []string) {
+	mc := NewMarkovChain()
+	from := State{CodeClass: "4xx", SizeBucket: "100", Depth: 0}
+	to := State{CodeClass: "2xx", SizeBucket: "1000", Depth: 0}
+	words := make([]string, actions)
+	for i := range words {
+		words[i] = fmt.Sprintf("word%d", i)
+		mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: words[i]}, ToState: to, Reward: 1.0})
+	}
+	return mc, from, words
+}
+
+// BenchmarkChainConcurrent mimics the workers of a high thread count run: every worker reads the expected
+// rewards and every tenth operation records a transition
+func BenchmarkChainConcurrent(b *testing.B) {
+	benchmarkChainConcurrent(b, (*MarkovChain).GetExpectedReward)
+}
+
+// BenchmarkChainConcurrentLocked is BenchmarkChainConcurrent with the expected rewards read under the read lock of
+// the chain, like GetExpectedReward did before the lock-free Q-value cells, to compare the two:
+//
+//	go test -run xxx -bench 'ChainConcurrent' -cpu 8 ./pkg/markov
+func BenchmarkChainConcurrentLocked(b *testing.B) {
+	benchmarkChainConcurrent(b, lockedExpectedReward)
+}
+
+// lockedExpectedReward reads the Q-value of an action in a state from the Q-table, under the read lock
+func lockedExpectedReward(mc *MarkovChain, state State, action string) float64 {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+	if q, ok := mc.QTable[state.Hash()][action]; ok {
+		return q
+	}
+	return 0.0
+}
+
+func benchmarkChainConcurrent(b *testing.B, expectedReward func(*MarkovChain, State, string) float64) {
+	mc, from, words := newBenchmarkChain(10000)
+	to := State{CodeClass: "2xx", SizeBucket: "1000", Depth: 0}
+	var counter uint64
+	b.SetParallelism(25) // 200 goroutines on 8 cores
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddUint64(&counter, 1)
+			word := words[i%uint64(len(words))]
+			if i%10 == 0 {
+				mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: word}, ToState: to, Reward: 1.0})
+			} else {
+				expectedReward(mc, from, word)
+			}
+		}
+	})
+}
+
+func BenchmarkProviderValue(b *testing.B) {
+	mip := NewMarkovInputProvider(newSliceProvider("admin", "login", "backup"), State{}, "", 0)
+	mip.Next()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mip.Value()
+	}
+}
+
+func BenchmarkUpdateTransition(b *testing.B) {
+	mc, from, words := newBenchmarkChain(1000)
+	to := State{CodeClass: "2xx", SizeBucket: "1000", Depth: 0}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: words[i%len(words)]}, ToState: to, Reward: 1.0})
+	}
+}
+
+func BenchmarkUpdateTransitionConcurrent(b *testing.B) {
+	const workers = 32
+	mc, from, words := newBenchmarkChain(1000)
+	to := State{CodeClass: "2xx", SizeBucket: "1000", Depth: 0}
+	b.ReportAllocs()
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < b.N; i += workers {
+				mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: words[i%len(words)]}, ToState: to, Reward: 1.0})
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// BenchmarkUpdateWithResponse compares the throughput of the workers learning from their responses with the chain
+// updated inline and from the queue of StartAsyncUpdates, reporting the share of the updates the queue dropped
+func BenchmarkUpdateWithResponse(b *testing.B) {
+	for _, buffer := range []int{0, DefaultUpdateBuffer} {
+		name := "inline"
+		if buffer > 0 {
+			name = "async"
+		}
+		b.Run(name, func(b *testing.B) {
+			words := make([]string, 1000)
+			for i := range words {
+				words[i] = fmt.Sprintf("word%d", i)
+			}
+			mip := newTestProvider(words...)
+			if buffer > 0 {
+				mip.MarkovChain.StartAsyncUpdates(buffer)
+			}
+			var counter uint64
+			b.SetParallelism(4)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := atomic.AddUint64(&counter, 1)
+					resp := &Response{StatusCode: 200 + int64(i%3), ContentLength: int64(i % 5000)}
+					mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(words[i%uint64(len(words))])}, resp)
+				}
+			})
+			b.StopTimer()
+			mip.MarkovChain.StopAsyncUpdates()
+			b.ReportMetric(float64(mip.MarkovChain.DroppedUpdates())/float64(b.N), "dropped/op")
+		})
+	}
+}
+
+func BenchmarkGetBestActionsForState(b *testing.B) {
+	for _, size := range []int{10000, 100000, 1000000} {
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			// The chain knows a hundred of the words, the rest need to be filled in from the wordlist
+			mc, from, _ := newBenchmarkChain(100)
+			wordlist := make([]string, size)
+			for i := range wordlist {
+				wordlist[i] = fmt.Sprintf("word%d", i)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				mc.GetBestActionsForState(from, wordlist, 200)
+			}
+		})
+	}
+}
+
+func BenchmarkStateHash(b *testing.B) {
+	state := State{CodeClass: "2xx", SizeBucket: "1000", Depth: 2, Proto: "HTTP/2.0", Headers: "6-15", Cookie: "cookie"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = state.Hash()
+	}
+}
+
+func BenchmarkSnapshotSaveLoad(b *testing.B) {
+	for _, name := range []string{"chain.gob", "chain.json"} {
+		b.Run(name, func(b *testing.B) {
+			mc, _, _ := newBenchmarkChain(10000)
+			mip := NewMarkovInputProvider(newSliceProvider(), State{}, "", 0)
+			mip.MarkovChain = mc
+			filename := filepath.Join(b.TempDir(), name)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := mip.SaveChain(filename); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := mip.LoadChain(filename); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// benchmarkWordlist returns a wordlist of the given size, of words made of common path segments and numbers
+func benchmarkWordlist(size int) [][]byte {
+	prefixes := []string{"admin", "api", "backup", "config", "db", "dev", "files", "images", "login", "old", "static", "test", "upload", "user", "web"}
+	suffixes := []string{"", "_old", "-backup", ".bak", ".php", ".zip", "s", "_v", "-dev", "/index"}
+	words := make([][]byte, size)
+	for i := range words {
+		words[i] = []byte(fmt.Sprintf("%s%s%d", prefixes[i%len(prefixes)], suffixes[(i/len(prefixes))%len(suffixes)], i/150))
+	}
+	return words
+}
+
+// BenchmarkTrigramIndexBuild builds the trigram index of a wordlist of a million words, which needs to stay
+// within a couple of seconds as it happens at the start of the run
+func BenchmarkTrigramIndexBuild(b *testing.B) {
+	words := benchmarkWordlist(1000000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if NewTrigramIndex(words) == nil {
+			b.Fatal("Expected the wordlist to be indexed")
+		}
+	}
+}
+
+func BenchmarkTrigramSimilar(b *testing.B) {
+	ix := NewTrigramIndex(benchmarkWordlist(1000000))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ix.Similar("backup_old42", 10)
+	}
+}
+
+// TestBenchmarkRegression runs the benchmarks listed in testdata/benchmark_baseline.json and fails if any of them
+// is more than three times slower than its baseline. Only runs when FFUF_BENCH_CHECK is set, as the results
+// depend on the machine.
+func TestBenchmarkRegression(t *testing.T) {
+	if os.Getenv("FFUF_BENCH_CHECK") == "" {
+		t.Skip("set FFUF_BENCH_CHECK to compare the benchmarks against the baseline")
+	}
+	data, err := os.ReadFile("testdata/benchmark_baseline.json")
+	if err != nil {
+		t.Fatalf("Could not read the baseline: %s", err)
+	}
+	baseline := make(map[string]int64)
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		t.Fatalf("Could not parse the baseline: %s", err)
+	}
+	benchmarks := map[string]func(*testing.B){
+		"BenchmarkUpdateTransition":           BenchmarkUpdateTransition,
+		"BenchmarkUpdateTransitionConcurrent": BenchmarkUpdateTransitionConcurrent,
+		"BenchmarkChainConcurrent":            BenchmarkChainConcurrent,
+		"BenchmarkStateHash":                  BenchmarkStateHash,
+		"BenchmarkProviderValue":              BenchmarkProviderValue,
+	}
+	for name, baseNs := range baseline {
+		bench, ok := benchmarks[name]
+		if !ok {
+			t.Errorf("No benchmark for baseline entry %s", name)
+			continue
+		}
+		if ns := testing.Benchmark(bench).NsPerOp(); ns > 3*baseNs {
+			t.Errorf("%s regressed: %d ns/op, baseline %d ns/op", name, ns, baseNs)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package markov
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// blockDominance is the share of the detection window a block signature needs to reach
+	blockDominance = 0.9
+	// blockBackoffMax caps the exponential backoff of consecutive cooldowns
+	blockBackoffMax = 10 * time.Minute
+)
+
+// blockSample is the signature of a response as seen by the block detection: its status code and size bucket,
+// block pages carrying request ids or timestamps differing only slightly in size
+type blockSample struct {
+	status int64
+	size   string
+}
+
+// blockDetector recognizes a WAF or a rate limiter blocking the requests, from the recent responses being
+// dominated by a single block status and body size
+type blockDetector struct {
+	statuses    map[int64]bool
+	backoff     time.Duration
+	window      []blockSample
+	next        int
+	filled      bool
+	consecutive int
+	evidence    string
+}
+
+// ParseStatusSet parses a comma separated list of HTTP status codes
+func ParseStatusSet(list string) ([]int64, error) {
+	statuses := make([]int64, 0)
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		status, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || status < 100 || status > 599 {
+			return nil, fmt.Errorf("invalid status code: %s", s)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// SetBlockDetection enables the detection of blocking, over a window of the given number of responses. The backoff
+// is the cooldown period after a first detection, doubled on each consecutive detection. A zero backoff or
+// window disables the detection.
+func (mip *MarkovInputProvider) SetBlockDetection(statuses []int64, window int, backoff time.Duration) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	if backoff <= 0 || window <= 0 {
+		mip.blocking = nil
+		return
+	}
+	mip.blocking = &blockDetector{
+		statuses: make(map[int64]bool),
+		backoff:  backoff,
+		window:   make([]blockSample, window),
+	}
+	for _, s := range statuses {
+		mip.blocking.statuses[s] = true
+	}
+}
+
+// recordBlockSample adds a response to the block detection window. Must be called with the mutex held.
+func (mip *MarkovInputProvider) recordBlockSample(resp *Response) {
+	if mip.blocking == nil || resp.Error != "" {
+		return
+	}
+	b := mip.blocking
+	b.window[b.next] = blockSample{status: resp.StatusCode, size: quantizeSize(resp.ContentLength)}
+	b.next = (b.next + 1) % len(b.window)
+	if b.next == 0 {
+		b.filled = true
+	}
+}
+
+// DetectBlocking reports whether the recent responses show a block signature: at least 90% of the last responses
+// having the same block status and body size. On detection the window is cleared, so that each detection is
+// reported once, and the cooldown period to pause the requests for is returned. Consecutive detections double it.
+func (mip *MarkovInputProvider) DetectBlocking() (bool, time.Duration) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	b := mip.blocking
+	if b == nil || !b.filled {
+		return false, 0
+	}
+	counts := make(map[blockSample]int)
+	var dominant blockSample
+	for _, s := range b.window {
+		if !b.statuses[s.status] {
+			continue
+		}
+		counts[s]++
+		if counts[s] > counts[dominant] {
+			dominant = s
+		}
+	}
+	if float64(counts[dominant]) < blockDominance*float64(len(b.window)) {
+		// A full window without blocking ends the streak of cooldowns
+		b.consecutive = 0
+		return false, 0
+	}
+
+	b.consecutive++
+	backoff := b.backoff << (b.consecutive - 1)
+	if backoff > blockBackoffMax || backoff <= 0 {
+		backoff = blockBackoffMax
+	}
+	b.evidence = fmt.Sprintf("%d of the last %d responses were %d with a body of about %s bytes, cooldown %d in a row",
+		counts[dominant], len(b.window), dominant.status, dominant.size, b.consecutive)
+	b.next = 0
+	b.filled = false
+	return true, backoff
+}
+
+// BlockingEvidence describes the responses that triggered the last detection of blocking
+func (mip *MarkovInputProvider) BlockingEvidence() string {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	if mip.blocking == nil {
+		return ""
+	}
+	return mip.blocking.evidence
+}
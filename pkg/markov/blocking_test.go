@@ -0,0 +1,81 @@
+package markov
+
+import (
+	"testing"
+	"time"
+)
+
+func blockResponses(mip *MarkovInputProvider, status int64, size int64, n int) {
+	for i := 0; i < n; i++ {
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("word")}, &Response{StatusCode: status, ContentLength: size})
+	}
+}
+
+func TestDetectBlocking(t *testing.T) {
+	mip := newTestProvider("word")
+	mip.SetBlockDetection([]int64{403, 429}, 10, time.Second)
+
+	blockResponses(mip, 200, 1500, 10)
+	if blocked, _ := mip.DetectBlocking(); blocked {
+		t.Errorf("Successful responses should not be detected as blocking")
+	}
+	blockResponses(mip, 403, 1500, 5)
+	if blocked, _ := mip.DetectBlocking(); blocked {
+		t.Errorf("A partial block phase should not be detected as blocking")
+	}
+	blockResponses(mip, 403, 1500, 3)
+	if blocked, _ := mip.DetectBlocking(); blocked {
+		t.Errorf("80%% of the window blocked should not be detected as blocking")
+	}
+	blockResponses(mip, 403, 1500, 1)
+	blocked, backoff := mip.DetectBlocking()
+	if !blocked || backoff != time.Second {
+		t.Errorf("Expected blocking with a backoff of 1s, got %t and %s", blocked, backoff)
+	}
+	if mip.BlockingEvidence() == "" {
+		t.Errorf("Expected evidence of the blocking")
+	}
+	if blocked, _ := mip.DetectBlocking(); blocked {
+		t.Errorf("A detection should only be reported once")
+	}
+
+	// The block phase goes on after the cooldown
+	blockResponses(mip, 403, 1500, 10)
+	if blocked, backoff = mip.DetectBlocking(); !blocked || backoff != 2*time.Second {
+		t.Errorf("Expected the backoff of a consecutive detection to double, got %t and %s", blocked, backoff)
+	}
+
+	// The block is lifted, the next detection starts over
+	blockResponses(mip, 200, 1500, 10)
+	mip.DetectBlocking()
+	blockResponses(mip, 429, 200, 10)
+	if blocked, backoff = mip.DetectBlocking(); !blocked || backoff != time.Second {
+		t.Errorf("Expected the backoff to be reset after a clean window, got %t and %s", blocked, backoff)
+	}
+}
+
+func TestDetectBlockingIgnoresOtherStatuses(t *testing.T) {
+	mip := newTestProvider("word")
+	mip.SetBlockDetection([]int64{429}, 10, time.Second)
+	blockResponses(mip, 403, 1500, 10)
+	if blocked, _ := mip.DetectBlocking(); blocked {
+		t.Errorf("Statuses outside of the block set should not be detected as blocking")
+	}
+	mip.SetBlockDetection(nil, 10, 0)
+	blockResponses(mip, 429, 1500, 10)
+	if blocked, _ := mip.DetectBlocking(); blocked {
+		t.Errorf("Detection should be disabled with a zero backoff")
+	}
+}
+
+func TestParseStatusSet(t *testing.T) {
+	statuses, err := ParseStatusSet("403, 429,503")
+	if err != nil || len(statuses) != 3 || statuses[1] != 429 {
+		t.Errorf("Unexpected statuses %v: %s", statuses, err)
+	}
+	for _, list := range []string{"403,abc", "42", "403,600"} {
+		if _, err := ParseStatusSet(list); err == nil {
+			t.Errorf("Expected an error for %q", list)
+		}
+	}
+}
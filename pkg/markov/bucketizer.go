@@ -0,0 +1,170 @@
+package markov
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// BucketsLog is the name of the log-scale size buckets of QuantizeSize
+	BucketsLog = "log"
+	// BucketsAdaptive is the name of the size buckets learned from the quantiles of the first sizes observed
+	BucketsAdaptive = "adaptive"
+	// AdaptiveWarmup is the number of sizes the adaptive buckets are learned from
+	AdaptiveWarmup = 200
+	// adaptiveBuckets is the number of adaptive buckets, fewer when the sizes observed have many duplicates
+	adaptiveBuckets = 8
+)
+
+// Bucketizer converts the size of a response body to the size bucket of its state
+type Bucketizer interface {
+	// Bucket returns the size bucket of a body size, learning from it for the schemes learning their buckets
+	Bucket(size int64) string
+	// Name returns the scheme of the buckets in the format of ParseBucketizer, including the learned breakpoints,
+	// so that the chains saved with different schemes are not mixed
+	Name() string
+}
+
+// ParseBucketizer returns the bucketizer of a scheme: "log", "adaptive", or "adaptive:" followed by the comma
+// separated breakpoints of adaptive buckets learned already. An empty scheme is the log-scale one.
+func ParseBucketizer(scheme string) (Bucketizer, error) {
+	switch {
+	case scheme == "" || scheme == BucketsLog:
+		return LogBucketizer{}, nil
+	case scheme == BucketsAdaptive:
+		return NewAdaptiveBucketizer(AdaptiveWarmup), nil
+	case strings.HasPrefix(scheme, BucketsAdaptive+":"):
+		breaks := make([]int64, 0)
+		for _, s := range strings.Split(strings.TrimPrefix(scheme, BucketsAdaptive+":"), ",") {
+			if s == "" {
+				continue
+			}
+			b, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || (len(breaks) > 0 && b <= breaks[len(breaks)-1]) {
+				return nil, fmt.Errorf("invalid adaptive size buckets: %s", scheme)
+			}
+			breaks = append(breaks, b)
+		}
+		return &AdaptiveBucketizer{breaks: breaks}, nil
+	}
+	return nil, fmt.Errorf("unknown size buckets: %s, valid values are: %s, %s", scheme, BucketsLog, BucketsAdaptive)
+}
+
+// LogBucketizer is the log-scale bucketizer of QuantizeSize, the default
+type LogBucketizer struct{}
+
+// Bucket returns the log-scale size bucket of a body size
+func (LogBucketizer) Bucket(size int64) string {
+	return QuantizeSize(size)
+}
+
+// Name returns "log"
+func (LogBucketizer) Name() string {
+	return BucketsLog
+}
+
+// AdaptiveBucketizer learns its breakpoints from the quantiles of the first sizes it sees, for targets whose
+// responses all fall in a few log-scale buckets, like APIs answering with bodies of 200 to 600 bytes. The sizes
+// seen while warming up are bucketed in the log scale, their buckets not colliding with the adaptive ones.
+type AdaptiveBucketizer struct {
+	warmup  int
+	samples []int64
+	breaks  []int64 // lower bounds of the buckets but the first, nil while warming up
+	mutex   sync.Mutex
+}
+
+// NewAdaptiveBucketizer returns an adaptive bucketizer learning its breakpoints from the given number of sizes
+func NewAdaptiveBucketizer(warmup int) *AdaptiveBucketizer {
+	return &AdaptiveBucketizer{warmup: warmup, samples: make([]int64, 0, warmup)}
+}
+
+// Bucket returns the adaptive size bucket of a body size, "q0" being the smallest sizes, or its log-scale bucket
+// while warming up
+func (a *AdaptiveBucketizer) Bucket(size int64) string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.breaks == nil {
+		a.samples = append(a.samples, size)
+		if len(a.samples) < a.warmup {
+			return QuantizeSize(size)
+		}
+		a.learn()
+	}
+	i := sort.Search(len(a.breaks), func(i int) bool { return a.breaks[i] > size })
+	return fmt.Sprintf("q%d", i)
+}
+
+// learn sets the breakpoints from the quantiles of the samples. Must be called with the mutex held.
+func (a *AdaptiveBucketizer) learn() {
+	sort.Slice(a.samples, func(i, j int) bool { return a.samples[i] < a.samples[j] })
+	a.breaks = make([]int64, 0, adaptiveBuckets-1)
+	for i := 1; i < adaptiveBuckets; i++ {
+		b := a.samples[i*len(a.samples)/adaptiveBuckets]
+		if b > a.samples[0] && (len(a.breaks) == 0 || b > a.breaks[len(a.breaks)-1]) {
+			a.breaks = append(a.breaks, b)
+		}
+	}
+	a.samples = nil
+}
+
+// Learned reports whether the breakpoints were learned
+func (a *AdaptiveBucketizer) Learned() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.breaks != nil
+}
+
+// Name returns "adaptive" while warming up, and "adaptive:" followed by the breakpoints once learned
+func (a *AdaptiveBucketizer) Name() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.breaks == nil {
+		return BucketsAdaptive
+	}
+	breaks := make([]string, len(a.breaks))
+	for i, b := range a.breaks {
+		breaks[i] = strconv.FormatInt(b, 10)
+	}
+	return BucketsAdaptive + ":" + strings.Join(breaks, ",")
+}
+
+// compatibleBuckets returns the bucketizer to use with a chain saved with the given scheme: the one of the run if
+// the schemes match or the saved chain was still warming up its adaptive buckets, or the learned adaptive buckets of
+// the saved chain when the run has not learned its own yet.
+func compatibleBuckets(run Bucketizer, saved string) (Bucketizer, error) {
+	if saved == "" {
+		saved = BucketsLog
+	}
+	if run.Name() == saved {
+		return run, nil
+	}
+	if a, ok := run.(*AdaptiveBucketizer); ok {
+		if saved == BucketsAdaptive {
+			return run, nil
+		}
+		if !a.Learned() && strings.HasPrefix(saved, BucketsAdaptive+":") {
+			return ParseBucketizer(saved)
+		}
+	}
+	return nil, fmt.Errorf("learned with the %s size buckets, the run uses %s", saved, run.Name())
+}
+
+// adoptBuckets checks that the size buckets of a saved chain can be used with the chain of the run, switching the run
+// to the learned adaptive buckets of the saved chain when it has not learned its own yet
+func (mip *MarkovInputProvider) adoptBuckets(saved string) error {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	b, err := compatibleBuckets(mip.MarkovChain.Buckets, saved)
+	if err != nil {
+		return err
+	}
+	mip.MarkovChain.Buckets = b
+	return nil
+}
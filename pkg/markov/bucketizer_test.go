@@ -0,0 +1,138 @@
+package markov
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogBucketizer(t *testing.T) {
+	b := LogBucketizer{}
+	for _, size := range []int64{0, 42, 139, 999, 4321, 123456} {
+		if got := b.Bucket(size); got != QuantizeSize(size) {
+			t.Errorf("Expected the bucket of %d to be %s, got %s", size, QuantizeSize(size), got)
+		}
+	}
+	if b.Name() != BucketsLog {
+		t.Errorf("Expected the log scheme, got %s", b.Name())
+	}
+}
+
+func TestAdaptiveBucketizerWarmup(t *testing.T) {
+	b := NewAdaptiveBucketizer(100)
+	// API responses of 200 to 599 bytes, falling in 4 log-scale buckets
+	size := func(i int) int64 { return 200 + int64(i*37%400) }
+	for i := 0; i < 99; i++ {
+		if got := b.Bucket(size(i)); got != QuantizeSize(size(i)) {
+			t.Fatalf("Expected the log-scale bucket while warming up, got %s for %d", got, size(i))
+		}
+	}
+	if b.Learned() || b.Name() != BucketsAdaptive {
+		t.Fatalf("Expected the buckets not to be learned before the end of the warm-up")
+	}
+	if got := b.Bucket(size(99)); !strings.HasPrefix(got, "q") {
+		t.Errorf("Expected an adaptive bucket at the end of the warm-up, got %s", got)
+	}
+	if !b.Learned() {
+		t.Fatalf("Expected the buckets to be learned after the warm-up")
+	}
+
+	buckets := make(map[string]bool)
+	for i := 0; i < 400; i++ {
+		buckets[b.Bucket(200+int64(i))] = true
+	}
+	if len(buckets) != adaptiveBuckets {
+		t.Errorf("Expected the sizes to spread over %d buckets, got %v", adaptiveBuckets, buckets)
+	}
+	if b.Bucket(0) != "q0" || b.Bucket(100000) != "q7" {
+		t.Errorf("Expected the sizes out of the warm-up range to go to the extreme buckets")
+	}
+
+	// The learned scheme round trips
+	parsed, err := ParseBucketizer(b.Name())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, s := range []int64{0, 250, 321, 480, 599, 1000} {
+		if parsed.Bucket(s) != b.Bucket(s) {
+			t.Errorf("Expected the parsed buckets to match for %d: %s, got %s", s, b.Bucket(s), parsed.Bucket(s))
+		}
+	}
+}
+
+func TestAdaptiveBucketizerDuplicates(t *testing.T) {
+	b := NewAdaptiveBucketizer(10)
+	for i := 0; i < 10; i++ {
+		b.Bucket(512)
+	}
+	if got := b.Name(); got != BucketsAdaptive+":" {
+		t.Errorf("Expected a single bucket for identical sizes, got %s", got)
+	}
+	if b.Bucket(1) != "q0" || b.Bucket(100000) != "q0" {
+		t.Errorf("Expected every size in the single bucket")
+	}
+}
+
+func TestParseBucketizer(t *testing.T) {
+	for _, scheme := range []string{"", "log", "adaptive", "adaptive:100,200", "adaptive:"} {
+		if _, err := ParseBucketizer(scheme); err != nil {
+			t.Errorf("%s: unexpected error: %s", scheme, err)
+		}
+	}
+	for _, scheme := range []string{"linear", "adaptive:200,100", "adaptive:a"} {
+		if _, err := ParseBucketizer(scheme); err == nil {
+			t.Errorf("%s: expected an error", scheme)
+		}
+	}
+}
+
+func TestSizeBucketsInStates(t *testing.T) {
+	mip := newTestProvider("a")
+	mip.SetBucketizer(NewAdaptiveBucketizer(2))
+	if got := mip.stateFromResponse(&Response{StatusCode: 200, ContentLength: 300}).SizeBucket; got != "300" {
+		t.Errorf("Expected the log-scale bucket for the warm-up response, got %s", got)
+	}
+	if got := mip.stateFromResponse(&Response{StatusCode: 200, ContentLength: 400}).SizeBucket; got != "q1" {
+		t.Errorf("Expected the adaptive bucket once learned, got %s", got)
+	}
+}
+
+func TestSizeBucketsInSavedChains(t *testing.T) {
+	dir := t.TempDir()
+	learned := newTestProvider("a")
+	learned.SetBucketizer(NewAdaptiveBucketizer(2))
+	inputs := map[string][]byte{"FUZZ": []byte("a")}
+	learned.UpdateWithResponse(inputs, &Response{StatusCode: 200, ContentLength: 300})
+	learned.UpdateWithResponse(inputs, &Response{StatusCode: 200, ContentLength: 400})
+	adaptiveChain := filepath.Join(dir, "adaptive.json")
+	if err := learned.SaveChain(adaptiveChain); err != nil {
+		t.Fatalf("Could not save the chain: %s", err)
+	}
+	logChain := filepath.Join(dir, "log.json")
+	if err := newTestProvider("a").SaveChain(logChain); err != nil {
+		t.Fatalf("Could not save the chain: %s", err)
+	}
+
+	// A run still warming up adopts the learned buckets
+	run := newTestProvider("a")
+	run.SetBucketizer(NewAdaptiveBucketizer(AdaptiveWarmup))
+	if _, err := run.LoadChain(adaptiveChain); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got, expected := run.MarkovChain.Buckets.Name(), learned.MarkovChain.Buckets.Name(); got != expected {
+		t.Errorf("Expected the run to adopt the buckets %s, got %s", expected, got)
+	}
+
+	// Chains of another scheme are refused
+	if _, err := newTestProvider("a").LoadChain(adaptiveChain); err == nil || !strings.Contains(err.Error(), "size buckets") {
+		t.Errorf("Expected an adaptive chain to be refused by a log run, got %v", err)
+	}
+	run = newTestProvider("a")
+	run.SetBucketizer(NewAdaptiveBucketizer(AdaptiveWarmup))
+	if _, err := run.LoadChain(logChain); err == nil {
+		t.Errorf("Expected a log chain to be refused by an adaptive run")
+	}
+	if _, _, err := newTestProvider("a").ReloadChain(adaptiveChain); err == nil {
+		t.Errorf("Expected an adaptive chain not to be merged into a log run")
+	}
+}
@@ -0,0 +1,92 @@
+package markov
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// RewardStat keeps the running mean and variance of the rewards observed for an action in a state,
+// using Welford's online algorithm
+type RewardStat struct {
+	Count int
+	Mean  float64
+	M2    float64 // sum of the squared differences from the mean
+}
+
+// Add records a reward
+func (r *RewardStat) Add(reward float64) {
+	r.Count++
+	delta := reward - r.Mean
+	r.Mean += delta / float64(r.Count)
+	r.M2 += delta * (reward - r.Mean)
+}
+
+// Variance returns the sample variance of the rewards, 0 with fewer than two rewards recorded
+func (r RewardStat) Variance() float64 {
+	if r.Count < 2 {
+		return 0
+	}
+	return r.M2 / float64(r.Count-1)
+}
+
+// ActionConfidence is the Q-value of an action in a state along with the number of observations backing it
+// and a 95% confidence interval from the variance of the observed rewards
+type ActionConfidence struct {
+	State         string
+	Action        string
+	Q             float64
+	Count         int
+	Margin        float64 // half width of the confidence interval, Q ± Margin
+	LowConfidence bool    // backed by fewer than MinObservations observations
+}
+
+// String returns the action in a human readable format
+func (a ActionConfidence) String() string {
+	s := fmt.Sprintf("%s -> %s: Q %.3f ± %.3f (n=%d)", a.State, a.Action, a.Q, a.Margin, a.Count)
+	if a.LowConfidence {
+		s += " low confidence"
+	}
+	return s
+}
+
+// TopActions returns the n state and action pairs with the highest Q-values, along with their confidence
+func (mc *MarkovChain) TopActions(n int) []ActionConfidence {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	actions := make([]ActionConfidence, 0)
+	for state, row := range mc.QTable {
+		for action, q := range row {
+			stat := RewardStat{}
+			if s, exists := mc.RewardStats[state][action]; exists {
+				stat = *s
+			}
+			margin := 0.0
+			if stat.Count > 0 {
+				margin = 1.96 * math.Sqrt(stat.Variance()) / math.Sqrt(float64(stat.Count))
+			}
+			actions = append(actions, ActionConfidence{
+				State:         state,
+				Action:        action,
+				Q:             q,
+				Count:         stat.Count,
+				Margin:        margin,
+				LowConfidence: stat.Count < mc.MinObservations,
+			})
+		}
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		if actions[i].Q != actions[j].Q {
+			return actions[i].Q > actions[j].Q
+		}
+		if actions[i].State != actions[j].State {
+			return actions[i].State < actions[j].State
+		}
+		return actions[i].Action < actions[j].Action
+	})
+	if len(actions) > n {
+		actions = actions[:n]
+	}
+	return actions
+}
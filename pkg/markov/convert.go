@@ -0,0 +1,64 @@
+package markov
+
+import (
+	"net/url"
+	"strings"
+)
+
+// StateFromFFUF builds the state of an ffuf response from its fields, so that the job and other integrators
+// construct the states the same way. The depth is derived from the URL, and the header count, Set-Cookie and
+// validator dimensions are filled in from the headers, a nil map meaning no headers. The word and line counts and the
+// content type are not part of the state.
+func StateFromFFUF(status int64, length, words, lines int64, contentType string, headers map[string][]string, rawurl string) State {
+	cookie := "nocookie"
+	if len(setCookieNames(headers)) > 0 {
+		cookie = "cookie"
+	}
+	return State{
+		CodeClass:  codeClass(status),
+		SizeBucket: quantizeSize(length),
+		Depth:      URLDepth(rawurl),
+		Headers:    QuantizeHeaderCount(headerCount(headers)),
+		Cookie:     cookie,
+		Validator:  hasValidator(headers),
+	}
+}
+
+// URLDepth returns the number of directories above the last path segment of a URL, matching the recursion depth
+// of the job that requested it: both "http://example.com/admin" and "http://example.com/FUZZ" are at depth 0,
+// "http://example.com/admin/login" is at depth 1. Unparseable URLs are at depth 0.
+func URLDepth(rawurl string) int {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return 0
+	}
+	depth := -1
+	for _, segment := range strings.Split(strings.TrimSuffix(u.Path, "/"), "/") {
+		if segment != "" {
+			depth++
+		}
+	}
+	if depth < 0 {
+		return 0
+	}
+	return depth
+}
+
+// codeClass returns the status code class of an HTTP status code. The 405 and 501 responses, telling the method is
+// the problem rather than the path, have a class of their own.
+func codeClass(status int64) string {
+	switch {
+	case status == 405 || status == 501:
+		return CodeClassMethod
+	case status >= 200 && status < 300:
+		return "2xx"
+	case status >= 300 && status < 400:
+		return "3xx"
+	case status >= 400 && status < 500:
+		return "4xx"
+	case status >= 500 && status < 600:
+		return "5xx"
+	default:
+		return "unknown"
+	}
+}
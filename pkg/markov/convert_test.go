@@ -0,0 +1,62 @@
+package markov
+
+import (
+	"testing"
+)
+
+func TestURLDepth(t *testing.T) {
+	tests := []struct {
+		url   string
+		depth int
+	}{
+		{"http://example.com/admin", 0},
+		{"http://example.com/FUZZ", 0},
+		{"http://example.com/", 0},
+		{"http://example.com", 0},
+		{"", 0},
+		{"http://example.com/admin/login", 1},
+		{"http://example.com/admin/", 0},
+		{"http://example.com/a/b/c.php?x=/y/z#/frag", 2},
+		{"http://example.com//admin//login", 1},
+		{"/api/v1/users", 2},
+		{"api/users", 1},
+		{"http://example.com/%zz/admin", 0},
+		{"http://[::1]:8080/a/b", 1},
+	}
+	for _, test := range tests {
+		if depth := URLDepth(test.url); depth != test.depth {
+			t.Errorf("Unexpected depth for %q: %d, want %d", test.url, depth, test.depth)
+		}
+	}
+}
+
+func TestStateFromFFUF(t *testing.T) {
+	state := StateFromFFUF(403, 1234, 10, 5, "text/html", nil, "http://example.com/admin/secret")
+	expected := State{CodeClass: "4xx", SizeBucket: "1000", Depth: 1, Headers: "0-5", Cookie: "nocookie", Validator: "novalidator"}
+	if state != expected {
+		t.Errorf("Unexpected state without headers: %+v, want %+v", state, expected)
+	}
+
+	headers := map[string][]string{"Set-Cookie": {"session=abc", "lang=en"}, "Content-Type": {"text/html"}, "Etag": {`"5f3a-1b2"`}}
+	for i := 0; i < 5; i++ {
+		headers[string(rune('a'+i))] = []string{"x"}
+	}
+	state = StateFromFFUF(200, 50, 1, 1, "text/html", headers, "not a url %zz")
+	expected = State{CodeClass: "2xx", SizeBucket: "50", Depth: 0, Headers: "6-15", Cookie: "cookie", Validator: "validator"}
+	if state != expected {
+		t.Errorf("Unexpected state with headers: %+v, want %+v", state, expected)
+	}
+
+	if state := StateFromFFUF(0, -1, 0, 0, "", map[string][]string{}, ""); state.CodeClass != "unknown" || state.SizeBucket != "0" {
+		t.Errorf("Unexpected state for an empty response: %+v", state)
+	}
+}
+
+func TestUpdateWithResponseURLDepth(t *testing.T) {
+	mip := newTestProvider()
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("login")}, &Response{StatusCode: 200, ContentLength: 2000, URL: "http://example.com/admin/login"})
+	states := nextStates(mip, "login")
+	if len(states) != 1 || states[0] != "2xx_2000_1" {
+		t.Errorf("Expected the depth to be derived from the URL, got %v", states)
+	}
+}
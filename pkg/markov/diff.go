@@ -0,0 +1,181 @@
+package markov
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+)
+
+const (
+	// DefaultDiffThreshold is the change of Q-value an action needs to be reported by DiffSnapshots
+	DefaultDiffThreshold = 0.1
+	// DefaultDiffMinReward is the mean reward a new transition needs to be reported by DiffSnapshots
+	DefaultDiffMinReward = 1.0
+)
+
+// QValueChange is the change of the Q-value of an action in a state between two chains
+type QValueChange struct {
+	State  string  `json:"state"`
+	Action string  `json:"action"`
+	Old    float64 `json:"old"`
+	New    float64 `json:"new"`
+}
+
+// NewTransition is a transition of the new chain the old chain has never observed, with the mean reward of its
+// action in the state it was taken from
+type NewTransition struct {
+	From   string  `json:"from"`
+	Action string  `json:"action"`
+	To     string  `json:"to"`
+	Count  int     `json:"count"`
+	Reward float64 `json:"reward"`
+}
+
+// ChainDiff is the difference between two chain snapshots, see DiffSnapshots
+type ChainDiff struct {
+	Granularity    StateGranularity `json:"granularity"`
+	Threshold      float64          `json:"threshold"`
+	MinReward      float64          `json:"min_reward"`
+	OnlyOld        []string         `json:"only_old"` // states observed in the old chain only
+	OnlyNew        []string         `json:"only_new"` // states observed in the new chain only
+	Changed        []QValueChange   `json:"changed"`
+	NewTransitions []NewTransition  `json:"new_transitions"`
+}
+
+// DiffSnapshots compares two chains learned on the same target, typically by two scans a week apart: the states
+// observed by one of them only, the actions known to both whose Q-value changed by more than the threshold, largest
+// change first, and the transitions new to the second chain whose action has a mean reward of minReward or more.
+// The chains must have the same granularity preset and size buckets, their states being keyed differently otherwise.
+func DiffSnapshots(before ChainSnapshot, after ChainSnapshot, threshold float64, minReward float64) (ChainDiff, error) {
+	if before.Granularity != after.Granularity {
+		return ChainDiff{}, fmt.Errorf("cannot compare chains learned with different granularity presets: %s and %s", before.Granularity, after.Granularity)
+	}
+	if before.SizeBuckets != after.SizeBuckets {
+		return ChainDiff{}, fmt.Errorf("cannot compare chains learned with different size buckets: %s and %s", bucketsName(before.SizeBuckets), bucketsName(after.SizeBuckets))
+	}
+	diff := ChainDiff{
+		Granularity:    after.Granularity,
+		Threshold:      threshold,
+		MinReward:      minReward,
+		OnlyOld:        make([]string, 0),
+		OnlyNew:        make([]string, 0),
+		Changed:        make([]QValueChange, 0),
+		NewTransitions: make([]NewTransition, 0),
+	}
+
+	oldStates, newStates := snapshotStates(before), snapshotStates(after)
+	for state := range oldStates {
+		if !newStates[state] {
+			diff.OnlyOld = append(diff.OnlyOld, state)
+		}
+	}
+	for state := range newStates {
+		if !oldStates[state] {
+			diff.OnlyNew = append(diff.OnlyNew, state)
+		}
+	}
+	sort.Strings(diff.OnlyOld)
+	sort.Strings(diff.OnlyNew)
+
+	for state, row := range after.QTable {
+		for action, q := range row {
+			oldQ, ok := before.QTable[state][action]
+			if ok && math.Abs(q-oldQ) > threshold {
+				diff.Changed = append(diff.Changed, QValueChange{State: state, Action: action, Old: oldQ, New: q})
+			}
+		}
+	}
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		a, b := diff.Changed[i], diff.Changed[j]
+		if da, db := math.Abs(a.New-a.Old), math.Abs(b.New-b.Old); da != db {
+			return da > db
+		}
+		if a.State != b.State {
+			return a.State < b.State
+		}
+		return a.Action < b.Action
+	})
+
+	for from, actions := range after.TransitionCounts {
+		for action, next := range actions {
+			stat, ok := after.RewardStats[from][action]
+			if !ok || stat.Mean < minReward {
+				continue
+			}
+			for to, count := range next {
+				if _, seen := before.TransitionCounts[from][action][to]; !seen {
+					diff.NewTransitions = append(diff.NewTransitions, NewTransition{From: from, Action: action, To: to, Count: count, Reward: stat.Mean})
+				}
+			}
+		}
+	}
+	sort.Slice(diff.NewTransitions, func(i, j int) bool {
+		a, b := diff.NewTransitions[i], diff.NewTransitions[j]
+		if a.Reward != b.Reward {
+			return a.Reward > b.Reward
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.Action != b.Action {
+			return a.Action < b.Action
+		}
+		return a.To < b.To
+	})
+	return diff, nil
+}
+
+// snapshotStates returns the states a snapshot observed, the ones actions were taken from and the ones reached
+func snapshotStates(snap ChainSnapshot) map[string]bool {
+	states := make(map[string]bool)
+	for state := range snap.StateCounts {
+		states[state] = true
+	}
+	for from, actions := range snap.TransitionCounts {
+		states[from] = true
+		for _, next := range actions {
+			for to := range next {
+				states[to] = true
+			}
+		}
+	}
+	return states
+}
+
+// bucketsName returns the size bucket scheme of a snapshot, the empty scheme being the log-scale one
+func bucketsName(scheme string) string {
+	if scheme == "" {
+		return BucketsLog
+	}
+	return scheme
+}
+
+// WriteTable writes the diff as human readable tables
+func (d ChainDiff) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "States only in the old chain: %d\n", len(d.OnlyOld))
+	for _, state := range d.OnlyOld {
+		fmt.Fprintf(tw, "  %s\n", state)
+	}
+	fmt.Fprintf(tw, "\nStates only in the new chain: %d\n", len(d.OnlyNew))
+	for _, state := range d.OnlyNew {
+		fmt.Fprintf(tw, "  %s\n", state)
+	}
+	fmt.Fprintf(tw, "\nQ-values changed by more than %.2f: %d\n", d.Threshold, len(d.Changed))
+	if len(d.Changed) > 0 {
+		fmt.Fprintf(tw, "  STATE\tACTION\tOLD\tNEW\tCHANGE\n")
+		for _, c := range d.Changed {
+			fmt.Fprintf(tw, "  %s\t%s\t%.3f\t%.3f\t%+.3f\n", c.State, c.Action, c.Old, c.New, c.New-c.Old)
+		}
+	}
+	fmt.Fprintf(tw, "\nNew transitions with a mean reward of %.2f or more: %d\n", d.MinReward, len(d.NewTransitions))
+	if len(d.NewTransitions) > 0 {
+		fmt.Fprintf(tw, "  FROM\tACTION\tTO\tCOUNT\tREWARD\n")
+		for _, t := range d.NewTransitions {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%d\t%.2f\n", t.From, t.Action, t.To, t.Count, t.Reward)
+		}
+	}
+	return tw.Flush()
+}
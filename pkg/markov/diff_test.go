@@ -0,0 +1,79 @@
+package markov
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func loadDiffFixtures(t *testing.T) (ChainSnapshot, ChainSnapshot) {
+	before, err := LoadSnapshot("testdata/diff_old.json")
+	if err != nil {
+		t.Fatalf("Could not load the old chain: %s", err)
+	}
+	after, err := LoadSnapshot("testdata/diff_new.json")
+	if err != nil {
+		t.Fatalf("Could not load the new chain: %s", err)
+	}
+	return before, after
+}
+
+func TestDiffSnapshotsGolden(t *testing.T) {
+	before, after := loadDiffFixtures(t)
+	diff, err := DiffSnapshots(before, after, DefaultDiffThreshold, DefaultDiffMinReward)
+	if err != nil {
+		t.Fatalf("Could not compare the chains: %s", err)
+	}
+
+	var table bytes.Buffer
+	if err := diff.WriteTable(&table); err != nil {
+		t.Fatalf("Could not write the diff: %s", err)
+	}
+	golden, _ := os.ReadFile("testdata/diff_golden.txt")
+	if table.String() != string(golden) {
+		t.Errorf("Unexpected diff table:\n%s\nexpected:\n%s", table.String(), golden)
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(diff); err != nil {
+		t.Fatalf("Could not encode the diff: %s", err)
+	}
+	golden, _ = os.ReadFile("testdata/diff_golden.json")
+	if out.String() != string(golden) {
+		t.Errorf("Unexpected JSON diff:\n%s\nexpected:\n%s", out.String(), golden)
+	}
+}
+
+func TestDiffSnapshotsThresholds(t *testing.T) {
+	before, after := loadDiffFixtures(t)
+	diff, _ := DiffSnapshots(before, after, 0.5, 2.0)
+	if len(diff.Changed) != 1 || diff.Changed[0].Action != "backup" {
+		t.Errorf("Expected only the change of backup over 0.5, got %v", diff.Changed)
+	}
+	if len(diff.NewTransitions) != 1 || diff.NewTransitions[0].Action != "debug" {
+		t.Errorf("Expected only the new transition of debug with a reward of 2, got %v", diff.NewTransitions)
+	}
+
+	// A chain compared with itself has no difference
+	same, _ := DiffSnapshots(after, after, 0, 0)
+	if len(same.OnlyOld)+len(same.OnlyNew)+len(same.Changed)+len(same.NewTransitions) != 0 {
+		t.Errorf("Expected no difference between a chain and itself, got %+v", same)
+	}
+}
+
+func TestDiffSnapshotsGranularity(t *testing.T) {
+	before, after := loadDiffFixtures(t)
+	after.Granularity = GranularityFine
+	if _, err := DiffSnapshots(before, after, DefaultDiffThreshold, DefaultDiffMinReward); err == nil || !strings.Contains(err.Error(), "granularity") {
+		t.Errorf("Expected the chains of different granularity presets to be refused, got %v", err)
+	}
+	after.Granularity = before.Granularity
+	after.SizeBuckets = "adaptive:100,1000"
+	if _, err := DiffSnapshots(before, after, DefaultDiffThreshold, DefaultDiffMinReward); err == nil || !strings.Contains(err.Error(), "size buckets") {
+		t.Errorf("Expected the chains of different size buckets to be refused, got %v", err)
+	}
+}
@@ -4,19 +4,127 @@ This package implements Markov chain-based prioritization for ffuf.
 The Markov chain logic works as follows:
 
 1. State representation: ⟨code_class, size_bucket, depth⟩
-   - code_class: "2xx", "3xx", "4xx", "5xx" 
+   - code_class: "2xx", "3xx", "4xx", "5xx", "4xx-method" for the 405 and 501 responses, or the
+     terminal "timeout" and "conn-error" states for requests that failed without a response. The
+     connection errors of a known class, like "conn-error-dns", get a state of their own, so that
+     a token causing the failures is told apart from a flaky host
    - size_bucket: quantized response body length
    - depth: path depth
+   Optionally extended with the negotiated protocol, the bucketed header count and the
+   presence of a Set-Cookie header. The StateGranularity presets reduce the state to the
+   code class only (coarse), or include every optional dimension (fine). The fine preset also
+   tells the responses with a strong ETag or a Last-Modified validator, the real files of a
+   static file server, from the rewritten error pages without one, and gives the first
+   validator value seen under a path the SetValidatorReward bonus.
 
-2. Actions: the fuzz tokens/words being tested
+2. Actions: the fuzz tokens/words being tested, along with the HTTP method when it is
+   fuzzed. MethodBreakdown ranks the fuzzed methods by their mean reward. A token injected in
+   a field of a JSON body is keyed by the JSON pointer of the field, and JSONValueCandidates
+   are tried on the fields taking a raw value. A token in a query string is keyed by whether
+   it is the name or the value of a parameter, and QueryValueCandidates are tried in the
+   value position only.
 
 3. Transitions: S_t --(action)--> S_{t+1}, observed from ffuf responses
 
-4. Rewards: 1 for "useful" responses (non-404; preference 2xx/3xx/401/403, "new" content),
-             0 for baseline 404 responses
+4. Rewards: from an ordered decision table of mutually exclusive status tiers, 2xx being
+   the most valuable and baseline-like 4xx responses getting nothing, the 405 and 501
+   responses to a method the path does not allow having a tier of their own, plus bonuses for
+   4xx responses with new content, certificate mismatches and the parse errors of a fuzzed
+   JSON field, and the responses reflecting a fuzzed value with SetReflectionReward.
+   EvaluateReward lists the rules applied to a response. When the Host header is fuzzed,
+   SetVhostBaselines replaces the table with the deviation from the responses of the default
+   virtual host in certificate, status and size, see EvaluateVhostReward. SetParam changes the
+   weights of the rules, and the learning parameters, while the chain learns.
 
 The goal is to learn P(S'|S, action) and prioritize inputs that are most likely 
 to escape the "404 attractor" and return meaningful responses.
+
+Batches
+
+MarkovInputProvider reads the input provider in batches of DefaultBatchSize inputs, and moves
+the inputs the chain expects a positive reward from in the baseline state to the head of each
+batch, best first. SetTopActions caps their number, the rest of the batch keeping the order of
+the input provider. The provider reorders within a batch only, so every input is still sent
+once, and there is no separate budget splitting the requests between chain ranked inputs and
+the wordlist order. Decision tells why the current input is at its place, shown with the
+matches in verbose mode.
+
+Generalization
+
+The exact Q-table only knows the words that have been sent. Alongside it, the chain
+learns a value for each token feature (length bucket, digits, extension, charset class
+and a leading "." or "_") with the same update rule, and ranks the words it has never
+tried by the mean value of their features. Words having a Q-value of their own are
+ranked by their Q-value blended with their feature score by FeatureWeight. RankExtensions
+ranks the extensions the %EXT% placeholder of the dirsearch wordlists is expanded with by
+the learned value of their extension feature, leaving out the ones clearly worth less than
+the best one. TokenStack tags the tokens specific to a stack from a bundled tag map, and the
+tokens it does not know by their extension feature: once SetStackPreference names the stack
+of the target, the tokens of the other stacks are pushed back in the batches by the confidence
+of the guess, never left out.
+
+Chain files
+
+SaveChain writes the learned tables to a versioned ChainSnapshot, gob encoded or JSON when the
+file name ends in .json, and LoadChain continues learning from it. The snapshot records the
+granularity preset and a hash of the reward configuration: a chain learned with a finer
+granularity is downgraded by merging its states, a coarser one is refused, and a different
+reward configuration is loaded with a warning. ReloadChain merges a chain file into a
+running chain instead, summing the counts and averaging the Q-values. The testdata/chain_v1.* fixtures are
+regenerated with:
+
+	FFUF_WRITE_FIXTURES=1 go test -run TestWriteSnapshotFixtures ./pkg/markov
+
+ChainSync shares a chain between instances through a chain file, for example the instances
+fuzzing the shards of a wordlist. Each sync merges the learning since the previous sync into
+the file, Since telling it apart from the learning imported from the others, and merges what
+the others added to the file into the chain, the counts being summed. The file is locked with
+an exclusively created lock file, which works over NFS, and replaced atomically.
+
+Blocking
+
+SetBlockDetection watches a window of the latest responses for a WAF or a rate limiter: when
+90% of them share a status of the configured block set and the same size bucket,
+DetectBlocking reports it once along with the cooldown to pause the requests for, doubled on
+each consecutive detection until a window goes by without blocking.
+
+Patterns
+
+InducePatterns groups tokens sharing their shape and words, like api_v1 and api_v2, and induces
+a pattern with a slot for each differing number or single letter. Patterns without a word,
+with more than two slots or describing more than a thousand tokens are left out.
+PatternCandidates expands the new patterns of the best matches recorded with RecordMatch.
+PatternExpansions expands all of them again for the final pass of ffuf, which sends the tokens
+never sent before once the wordlist is exhausted, ranked by RankTokens.
+
+Neighbors
+
+TrigramIndex indexes the trigrams of a wordlist to find the words closest to a match, like
+backups and db-backup for backup, by the Jaccard similarity of their trigrams. It tracks the
+words that were sent, so that a word sent ahead of the wordlist is never suggested or sent
+again.
+
+Embedding
+
+The package does not depend on ffuf, the conversion of the ffuf responses living in pkg/ffuf.
+Engine is a minimal facade for the tools embedding the feedback loop: Observe learns from the
+response to a word, Suggest orders the words to send next, and Save and Load keep the learning
+across scans. See the example of Engine.
+
+Benchmarks
+
+The benchmarks cover the chain updates, both single-threaded and from concurrent workers,
+GetBestActionsForState over large wordlists, state hashing and the input provider:
+
+	go test -run xxx -bench . -benchmem ./pkg/markov
+
+BenchmarkChainConcurrentLocked reads the expected rewards under the lock of the chain, like before
+the lock-free reads, for BenchmarkChainConcurrent to be compared against it with -cpu 8.
+The output on a reference machine is kept in testdata/benchmark_baseline.txt to compare against.
+TestBenchmarkRegression fails if a benchmark listed in testdata/benchmark_baseline.json is more
+than three times slower than its baseline, and only runs when FFUF_BENCH_CHECK is set:
+
+	FFUF_BENCH_CHECK=1 go test -run TestBenchmarkRegression ./pkg/markov
 */
 package markov
 
@@ -0,0 +1,81 @@
+package markov
+
+import (
+	"log"
+	"math"
+)
+
+const (
+	// DefaultDurationMaxCV is the coefficient of variation of the durations of the baseline responses past which the
+	// durations are considered noise, see SetDurationCheck
+	DefaultDurationMaxCV = 1.0
+	// DurationCheckMinSamples is the number of baseline responses needed before the durations are judged
+	DurationCheckMinSamples = 20
+)
+
+// durationCheck tracks the spread of the durations of the responses equivalent to the baseline with the running
+// mean and variance of Welford
+type durationCheck struct {
+	count      int
+	mean       float64
+	m2         float64
+	unreliable bool // the durations were found too noisy, until the next calibration
+}
+
+// cv returns the coefficient of variation of the durations, 0 without samples
+func (d *durationCheck) cv() float64 {
+	if d.count < 2 || d.mean <= 0 {
+		return 0
+	}
+	return math.Sqrt(d.m2/float64(d.count-1)) / d.mean
+}
+
+// SetDurationCheck sets the coefficient of variation of the durations of the baseline responses past which the
+// durations are too noisy to tell anything, as behind a CDN answering from its cache or not. The duration_ms field of
+// the reward expressions is then 0 for the rest of the run, until the baseline is calibrated again. 0 disables the
+// check.
+func (mip *MarkovInputProvider) SetDurationCheck(maxCV float64) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.durationMaxCV = maxCV
+	mip.durations = durationCheck{}
+}
+
+// DurationReliable returns false once the durations of the baseline responses were found too noisy
+func (mip *MarkovInputProvider) DurationReliable() bool {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	return !mip.durations.unreliable
+}
+
+// recordDuration adds the duration of a response in the baseline state to the check of SetDurationCheck. Must be
+// called with the mutex held.
+func (mip *MarkovInputProvider) recordDuration(resp *Response, state State) {
+	if mip.durationMaxCV <= 0 || mip.durations.unreliable || resp.Error != "" || resp.Incomplete || resp.Elapsed <= 0 {
+		return
+	}
+	if !mip.baselineEquivalent(resp, state) {
+		return
+	}
+	d := &mip.durations
+	seconds := resp.Elapsed.Seconds()
+	d.count++
+	delta := seconds - d.mean
+	d.mean += delta / float64(d.count)
+	d.m2 += delta * (seconds - d.mean)
+	if d.count >= DurationCheckMinSamples && d.cv() > mip.durationMaxCV {
+		d.unreliable = true
+		log.Printf("Markov duration signal disabled: the durations of %d baseline responses vary by %.0f%% of their mean %.1fms, over the %.0f%% of -markov-duration-cv", d.count, 100*d.cv(), 1000*d.mean, 100*mip.durationMaxCV)
+	}
+}
+
+// baselineEquivalent returns whether a response looks like the baseline: the state of the baseline, or one of the
+// responses of the default virtual host when the Host header is fuzzed. Must be called with the mutex held.
+func (mip *MarkovInputProvider) baselineEquivalent(resp *Response, state State) bool {
+	if len(mip.vhostBaselines) > 0 {
+		return containsVhostBaseline(mip.vhostBaselines, NewVhostBaseline(resp))
+	}
+	return state.Hash() == mip.baselineState.WithGranularity(mip.granularity).Hash()
+}
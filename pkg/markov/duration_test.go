@@ -0,0 +1,96 @@
+package markov
+
+import (
+	"testing"
+	"time"
+)
+
+// feedDurations sends baseline-like 404 responses with the given durations in milliseconds
+func feedDurations(mip *MarkovInputProvider, durations ...int) {
+	for _, ms := range durations {
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("nothere")}, &Response{StatusCode: 404, ContentLength: 139, Elapsed: time.Duration(ms) * time.Millisecond})
+	}
+}
+
+func TestDurationCheckHighVariance(t *testing.T) {
+	expr, err := CompileRewardExpr("duration_ms > 1000 ? 5 : 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mip := newTestProvider()
+	mip.SetRewardExpr(expr)
+	slow := &Response{StatusCode: 200, ContentLength: 2000, Elapsed: 2 * time.Second}
+	if reward := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("sleep")}, slow); reward != 5 {
+		t.Fatalf("Expected the slow response to be rewarded before the check, got %f", reward)
+	}
+
+	// Cache hits and misses of a CDN edge
+	for i := 0; i < DurationCheckMinSamples/4; i++ {
+		feedDurations(mip, 2, 3, 2, 1500)
+	}
+	if mip.DurationReliable() {
+		t.Fatalf("Expected the durations varying between cache hits and misses to be found unreliable")
+	}
+	if reward := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("sleep")}, slow); reward != 0 {
+		t.Errorf("Expected duration_ms to be 0 once the durations are unreliable, got a reward of %f", reward)
+	}
+
+	// Steady durations do not turn the signal back on for the rest of the run
+	for i := 0; i < 2*DurationCheckMinSamples; i++ {
+		feedDurations(mip, 100)
+	}
+	if mip.DurationReliable() {
+		t.Errorf("Expected the durations to stay unreliable until the next calibration")
+	}
+
+	// The check runs again after a calibration
+	mip.SetBaseline(mip.baselineState, mip.baselineSizeHash)
+	if !mip.DurationReliable() {
+		t.Errorf("Expected the durations to be checked again after the calibration")
+	}
+}
+
+func TestDurationCheckLowVariance(t *testing.T) {
+	mip := newTestProvider()
+	for i := 0; i < 2*DurationCheckMinSamples; i++ {
+		feedDurations(mip, 95, 100, 110)
+	}
+	if !mip.DurationReliable() {
+		t.Errorf("Expected steady durations to be reliable, the coefficient of variation is %f", mip.durations.cv())
+	}
+}
+
+func TestDurationCheckBaselineOnly(t *testing.T) {
+	mip := newTestProvider()
+	for i := 0; i < DurationCheckMinSamples; i++ {
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("found")}, &Response{StatusCode: 200, ContentLength: 5000, Elapsed: time.Duration(1+i%2*1000) * time.Millisecond})
+	}
+	if !mip.DurationReliable() || mip.durations.count != 0 {
+		t.Errorf("Expected the responses unlike the baseline to be left out of the check, got %d samples", mip.durations.count)
+	}
+}
+
+func TestDurationCheckDisabled(t *testing.T) {
+	mip := newTestProvider()
+	mip.SetDurationCheck(0)
+	for i := 0; i < DurationCheckMinSamples/4; i++ {
+		feedDurations(mip, 2, 3, 2, 1500)
+	}
+	if !mip.DurationReliable() {
+		t.Errorf("Expected the durations to be trusted with the check disabled")
+	}
+}
+
+func TestDurationCheckVhostCalibration(t *testing.T) {
+	mip := newTestProvider()
+	for i := 0; i < DurationCheckMinSamples/4; i++ {
+		feedDurations(mip, 2, 3, 2, 1500)
+	}
+	if mip.DurationReliable() {
+		t.Fatalf("Expected the durations to be found unreliable")
+	}
+	mip.SetVhostBaselines([]*Response{{StatusCode: 404, ContentLength: 139}})
+	if !mip.DurationReliable() {
+		t.Errorf("Expected the durations to be checked again after the default virtual host calibration")
+	}
+}
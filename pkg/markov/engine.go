@@ -0,0 +1,72 @@
+package markov
+
+// Engine drives the feedback loop of the chain for the tools embedding it: it learns from the responses to the words
+// sent with Observe, and ranks the words to send next with Suggest. It has none of the ffuf glue, the input
+// providers and the wordlists staying with the caller.
+type Engine struct {
+	provider *MarkovInputProvider
+}
+
+// NewEngine returns an engine rewarding the responses by how they differ from the baseline, the response to a path
+// known not to exist. A nil baseline stands for a small 404 page.
+func NewEngine(baseline *Response) *Engine {
+	state := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139)}
+	sizeHash := GetSizeHash([]byte("404 not found"))
+	if baseline != nil {
+		state = GetStateFromResponseFromResponseStruct(baseline, 0)
+		sizeHash = baseline.BodyHash
+		if sizeHash == "" {
+			sizeHash = GetSizeHash(baseline.Data)
+		}
+	}
+	return &Engine{provider: NewMarkovInputProvider(nil, state, sizeHash, 0)}
+}
+
+// Observe learns from the response to the request sent with the word, returning the reward it was given
+func (e *Engine) Observe(word string, resp *Response) float64 {
+	return e.provider.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(word)}, resp)
+}
+
+// Suggest returns up to n of the words in the order to send them: the ones the chain expects a positive reward from
+// best first, then the others in their order. Words listed more than once are returned once, and n of 0 returns
+// all of them.
+func (e *Engine) Suggest(words []string, n int) []string {
+	if n <= 0 || n > len(words) {
+		n = len(words)
+	}
+	e.provider.mutex.Lock()
+	state := e.provider.baselineState
+	e.provider.mutex.Unlock()
+
+	suggested := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for _, score := range e.provider.MarkovChain.RankedActionsForState(state, words, n) {
+		seen[score.Action] = true
+		suggested = append(suggested, score.Action)
+	}
+	for _, word := range words {
+		if len(suggested) == n {
+			break
+		}
+		if !seen[word] {
+			seen[word] = true
+			suggested = append(suggested, word)
+		}
+	}
+	return suggested
+}
+
+// Save writes what the engine learned to a file, to be read back with Load
+func (e *Engine) Save(filename string) error {
+	return e.provider.SaveChain(filename)
+}
+
+// Load continues learning from a file written by Save, returning warnings about the parts of it left out
+func (e *Engine) Load(filename string) ([]string, error) {
+	return e.provider.LoadChain(filename)
+}
+
+// Chain returns the chain the engine learns into, for its statistics and the rankings beyond Suggest
+func (e *Engine) Chain() *MarkovChain {
+	return e.provider.MarkovChain
+}
@@ -0,0 +1,54 @@
+package markov_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+func ExampleEngine() {
+	notFound := &markov.Response{StatusCode: 404, ContentLength: 150, Data: []byte("not found")}
+	engine := markov.NewEngine(notFound)
+
+	// Synthetic responses of a target serving an admin panel and a login redirect
+	responses := map[string]*markov.Response{
+		"admin": {StatusCode: 200, ContentLength: 5120, Data: []byte("<h1>admin</h1>")},
+		"login": {StatusCode: 302, ContentLength: 0, Headers: map[string][]string{"Location": {"/login/"}}},
+	}
+	words := []string{"index", "admin", "backup", "login"}
+	for _, word := range words {
+		resp, found := responses[word]
+		if !found {
+			resp = notFound
+		}
+		fmt.Printf("%s: reward %.1f\n", word, engine.Observe(word, resp))
+	}
+	fmt.Println(engine.Suggest(words, 0))
+
+	// What was learned carries over to the next scan
+	dir, err := os.MkdirTemp("", "engine")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "target.chain")
+	if err := engine.Save(filename); err != nil {
+		fmt.Println(err)
+		return
+	}
+	next := markov.NewEngine(notFound)
+	if _, err := next.Load(filename); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(next.Suggest([]string{"backup", "login", "admin"}, 2))
+	// Output:
+	// index: reward 0.0
+	// admin: reward 3.0
+	// backup: reward 0.0
+	// login: reward 2.0
+	// [admin login backup index]
+	// [admin login]
+}
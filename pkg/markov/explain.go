@@ -0,0 +1,136 @@
+package markov
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RankingExplanation details how a word is scored when the inputs are ranked in a state. The Q-value, feature and
+// exploration parts add up to the score.
+type RankingExplanation struct {
+	State        string  // hash of the state the word is ranked in
+	Word         string  // key of the action of the word
+	Q            float64 // Q-value of the word in the state, 0 if it has none
+	Observations int     // times the word was taken in the state
+	Known        bool    // whether the Q-value is used, backed by at least MinObservations observations
+	Features     float64 // score of the token features of the word in the generalization model
+	HasFeatures  bool    // whether the generalization model knows features of the word
+	QPart        float64 // part of the score coming from the Q-value
+	FeaturePart  float64 // part of the score coming from the token features
+	Exploration  float64 // part of the score coming from OptimisticInit, for a word untried in the state
+	Score        float64 // value the word is ranked by
+	Ranked       bool    // false for the words the chain cannot score, keeping their place in the wordlist
+	Source       string  // "replay" of its own Q-value, "features", "wordlist", or "mutation" for generated tokens
+}
+
+// String returns the explanation on a single line
+func (e RankingExplanation) String() string {
+	parts := []string{fmt.Sprintf("%s in state %s: score %.4f from %s", e.Word, e.State, e.Score, e.Source)}
+	parts = append(parts, fmt.Sprintf("q=%.4f (%d observations, used: %t) contributing %.4f", e.Q, e.Observations, e.Known, e.QPart))
+	if e.HasFeatures {
+		parts = append(parts, fmt.Sprintf("features=%.4f contributing %.4f", e.Features, e.FeaturePart))
+	}
+	if e.Exploration != 0 {
+		parts = append(parts, fmt.Sprintf("exploration bonus %.4f", e.Exploration))
+	}
+	if !e.Ranked {
+		parts = append(parts, "not ranked, keeping its wordlist place")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// stateRows are the rows of the tables of a state the actions are scored from
+type stateRows struct {
+	q             map[string]float64
+	counts        map[string]int
+	features      map[Feature]float64
+	featureCounts map[Feature]int
+}
+
+// rows returns the rows of the tables of a state. Must be called with the read lock held.
+func (mc *MarkovChain) rows(stateKey string) stateRows {
+	return stateRows{
+		q:             mc.QTable[stateKey],
+		counts:        mc.ActionCounts[stateKey],
+		features:      mc.FeatureQTable[stateKey],
+		featureCounts: mc.FeatureCounts[stateKey],
+	}
+}
+
+// explainAction scores an action from the rows of a state, its Q-value looked up by key and its features scored on
+// the token. The Q-value of an action is blended with its feature score using FeatureWeight, and actions untried in
+// the state but known from others start at OptimisticInit. Must be called with the read lock held.
+func (mc *MarkovChain) explainAction(rows stateRows, word string, token string) RankingExplanation {
+	e := RankingExplanation{Word: word, Source: "wordlist"}
+	q, exists := rows.q[word]
+	e.Q, e.Observations = q, rows.counts[word]
+	e.Known = exists && e.Observations >= mc.MinObservations
+	optimistic := false
+	if !exists && mc.OptimisticInit != 0 {
+		// Untried actions known from other states start at the optimistic value
+		if _, registered := mc.knownActions.Load(word); registered {
+			optimistic = true
+		}
+	}
+	if len(rows.features) > 0 {
+		e.Features, e.HasFeatures = mc.featureScore(rows.features, rows.featureCounts, token)
+	}
+
+	qWeight, featureWeight := 1.0, 1.0
+	if (e.Known || optimistic) && e.HasFeatures {
+		qWeight, featureWeight = 1-mc.FeatureWeight, mc.FeatureWeight
+	}
+	switch {
+	case e.Known:
+		e.QPart = qWeight * q
+	case optimistic:
+		e.Exploration = qWeight * mc.OptimisticInit
+	}
+	if e.HasFeatures {
+		e.FeaturePart = featureWeight * e.Features
+	}
+	e.Score = e.QPart + e.FeaturePart + e.Exploration
+	e.Ranked = e.Known || optimistic || e.HasFeatures
+	if e.Known || optimistic {
+		e.Source = "replay"
+	} else if e.HasFeatures {
+		e.Source = "features"
+	}
+	return e
+}
+
+// ExplainRanking explains the score of a word of the wordlist in the state, as the chain ranks it in the batches
+func (mc *MarkovChain) ExplainRanking(state State, word string) RankingExplanation {
+	return mc.explainRanking(state, word, word)
+}
+
+// ExplainActionRanking explains the score of an action in the state, as the chain ranks it in the batches
+func (mc *MarkovChain) ExplainActionRanking(state State, action Action) RankingExplanation {
+	return mc.explainRanking(state, action.Key(), action.Token)
+}
+
+func (mc *MarkovChain) explainRanking(state State, key string, token string) RankingExplanation {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	stateKey := state.Hash()
+	e := mc.explainAction(mc.rows(stateKey), key, token)
+	e.State = stateKey
+	return e
+}
+
+// ExplainRanking explains the score of a FUZZ token in the state the inputs are ranked in. Tokens generated from the
+// patterns of the matches have "mutation" as their source.
+func (mip *MarkovInputProvider) ExplainRanking(token string) RankingExplanation {
+	mip.mutex.Lock()
+	state := mip.baselineState
+	action := Action{Token: token, Location: mip.keywordLocations["FUZZ"]}
+	_, generated := mip.generatedTokens[token]
+	mip.mutex.Unlock()
+
+	e := mip.MarkovChain.ExplainActionRanking(state, action)
+	if generated {
+		e.Source = "mutation"
+	}
+	return e
+}
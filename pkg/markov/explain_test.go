@@ -0,0 +1,95 @@
+package markov
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestExplainRanking(t *testing.T) {
+	mc := NewMarkovChain()
+	mc.OptimisticInit = 0.5
+	mc.MinObservations = 2
+	state := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139)}
+	key := state.Hash()
+	mc.QTable[key] = make(map[string]float64)
+	mc.ActionCounts[key] = map[string]int{"admin.php": 3, "backup.zip": 1}
+	mc.setQ(key, "admin.php", 2.0)
+	mc.setQ(key, "backup.zip", 5.0)
+	mc.knownActions.Store("config.php", true)
+	// The .php extension is the only feature the generalization model knows
+	ext := Feature{"ext", "php"}
+	mc.FeatureQTable[key] = map[Feature]float64{ext: 1.0}
+	mc.FeatureCounts[key] = map[Feature]int{ext: 2}
+
+	words := []string{"admin.php", "backup.zip", "config.php", "login.php", "zz"}
+	scores := make(map[string]float64)
+	for _, s := range mc.RankedActionsForState(state, words, len(words)) {
+		scores[s.Action] = s.Value
+	}
+	for _, test := range []struct {
+		word        string
+		source      string
+		ranked      bool
+		exploration bool
+	}{
+		{"admin.php", "replay", true, false},
+		{"backup.zip", "wordlist", false, false}, // too few observations for its Q-value to be used
+		{"config.php", "replay", true, true},     // known from another state, starting at OptimisticInit
+		{"login.php", "features", true, false},
+		{"zz", "wordlist", false, false},
+	} {
+		e := mc.ExplainRanking(state, test.word)
+		if e.Source != test.source || e.Ranked != test.ranked || (e.Exploration != 0) != test.exploration {
+			t.Errorf("Unexpected explanation of %s: %+v", test.word, e)
+		}
+		if sum := e.QPart + e.FeaturePart + e.Exploration; math.Abs(sum-e.Score) > 1e-12 {
+			t.Errorf("Expected the components of %s to add up to the score %f, got %f", test.word, e.Score, sum)
+		}
+		if score, ok := scores[test.word]; ok != test.ranked || (ok && score != e.Score) {
+			t.Errorf("Expected the score of %s to be the ranking score %f, got %f", test.word, score, e.Score)
+		}
+		if e.State != key || !strings.HasPrefix(e.String(), test.word+" in state "+key) {
+			t.Errorf("Unexpected explanation of %s: %s", test.word, e)
+		}
+	}
+	if e := mc.ExplainRanking(state, "admin.php"); e.Observations != 3 || e.Q != 2.0 || math.Abs(e.QPart-(1-mc.FeatureWeight)*2.0) > 1e-12 {
+		t.Errorf("Expected the Q-value blended with the features, got %+v", e)
+	}
+}
+
+func TestExplainRankingMutation(t *testing.T) {
+	mip := newTestProvider("api_v1", "api_v2")
+	mip.RecordMatch("api_v1", 1)
+	mip.RecordMatch("api_v2", 1)
+	_, candidates := mip.PatternCandidates(1)
+	if len(candidates) != 1 {
+		t.Fatalf("Expected a generated token, got %v", candidates)
+	}
+	if e := mip.ExplainRanking(candidates[0]); e.Source != "mutation" {
+		t.Errorf("Expected a generated token to come from a mutation, got %s", e.Source)
+	}
+	if e := mip.ExplainRanking("api_v1"); e.Source != "wordlist" || e.State != mip.baselineState.Hash() {
+		t.Errorf("Expected an unscored word of the wordlist, got %+v", e)
+	}
+}
+
+func TestRankActionsLocated(t *testing.T) {
+	mc := NewMarkovChain()
+	state := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139)}
+	key := state.Hash()
+	mc.QTable[key] = make(map[string]float64)
+	// Only lowercase tokens are known to pay off, whatever the location they were injected in
+	lower := Feature{"charset", "lower"}
+	mc.FeatureQTable[key] = map[Feature]float64{lower: 1.0}
+	mc.FeatureCounts[key] = map[Feature]int{lower: 2}
+
+	action := Action{Token: "login", Location: "path"}
+	ranked := mc.RankActions(state, []Action{action, {Token: "Login", Location: "path"}}, 2)
+	if len(ranked) != 1 || ranked[0].Action != action.Key() || ranked[0].Value != 1.0 {
+		t.Errorf("Expected the located action to be ranked on the features of its token, got %+v", ranked)
+	}
+	if e := mc.ExplainActionRanking(state, action); e.Source != "features" || e.Word != action.Key() || e.Score != 1.0 {
+		t.Errorf("Expected the located action to be explained from the features of its token, got %+v", e)
+	}
+}
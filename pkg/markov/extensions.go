@@ -0,0 +1,60 @@
+package markov
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// extensionEvidence is the number of observations of the extensions in the baseline state needed before they are
+	// ranked, the extensions being used in their given order until then
+	extensionEvidence = 20
+	// extensionMinObservations is the number of observations of an extension needed to leave it out
+	extensionMinObservations = 5
+	// extensionDropRatio leaves out the extensions learned to be worth less than this share of the best one
+	extensionDropRatio = 0.5
+)
+
+// RankExtensions ranks the extensions by the learned value of their extension token feature in the baseline state,
+// best first, leaving out the ones clearly worth less than the best one. The extensions are returned unchanged until
+// they have been observed extensionEvidence times, and the ones observed too little to tell keep their place after
+// the ones worth more than nothing.
+func (mip *MarkovInputProvider) RankExtensions(extensions []string) []string {
+	mip.mutex.Lock()
+	stateKey := mip.baselineState.WithGranularity(mip.granularity).Hash()
+	mip.mutex.Unlock()
+
+	mc := mip.MarkovChain
+	mc.mutex.RLock()
+	values := make(map[string]float64, len(extensions))
+	counts := make(map[string]int, len(extensions))
+	evidence := 0
+	for _, ext := range extensions {
+		f := Feature{Name: "ext", Value: strings.ToLower(strings.TrimPrefix(ext, "."))}
+		values[ext] = mc.FeatureQTable[stateKey][f]
+		counts[ext] = mc.FeatureCounts[stateKey][f]
+		evidence += counts[ext]
+	}
+	mc.mutex.RUnlock()
+	if evidence < extensionEvidence {
+		return extensions
+	}
+
+	best := 0.0
+	for _, ext := range extensions {
+		if counts[ext] >= extensionMinObservations && values[ext] > best {
+			best = values[ext]
+		}
+	}
+	ranked := make([]string, 0, len(extensions))
+	for _, ext := range extensions {
+		if best > 0 && counts[ext] >= extensionMinObservations && values[ext] < best*extensionDropRatio {
+			continue
+		}
+		ranked = append(ranked, ext)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return values[ranked[i]] > values[ranked[j]]
+	})
+	return ranked
+}
@@ -0,0 +1,35 @@
+package markov
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRankExtensions(t *testing.T) {
+	mip := newTestProvider()
+	baseline := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139), Depth: 0}
+	found := State{CodeClass: "2xx", SizeBucket: QuantizeSize(1000), Depth: 0}
+	extensions := []string{"jsp", ".php", "asp", "html"}
+
+	// Static until enough evidence
+	for i := 0; i < 4; i++ {
+		mip.AddTransition(baseline, Action{Token: fmt.Sprintf("page%d.php", i)}, found, 3.0)
+	}
+	if ranked := mip.RankExtensions(extensions); !reflect.DeepEqual(ranked, extensions) {
+		t.Errorf("Expected the extensions unchanged without enough evidence, got %v", ranked)
+	}
+
+	// A PHP target: the .php pages are found, the .jsp and .asp ones never are, .html is not tried
+	for i := 4; i < 10; i++ {
+		mip.AddTransition(baseline, Action{Token: fmt.Sprintf("page%d.php", i)}, found, 3.0)
+	}
+	for i := 0; i < 8; i++ {
+		mip.AddTransition(baseline, Action{Token: fmt.Sprintf("page%d.jsp", i)}, baseline, 0)
+		mip.AddTransition(baseline, Action{Token: fmt.Sprintf("page%d.asp", i)}, baseline, 0)
+	}
+	expected := []string{".php", "html"}
+	if ranked := mip.RankExtensions(extensions); !reflect.DeepEqual(ranked, expected) {
+		t.Errorf("Expected the learned extensions %v, got %v", expected, ranked)
+	}
+}
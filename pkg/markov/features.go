@@ -0,0 +1,133 @@
+package markov
+
+import (
+	"strings"
+)
+
+// Feature is a single feature of a token, eg. {"ext", "php"}
+type Feature struct {
+	Name  string
+	Value string
+}
+
+// String returns the feature in "name:value" format
+func (f Feature) String() string {
+	return f.Name + ":" + f.Value
+}
+
+// TokenFeatures extracts the features of a token used to generalize the learned values to tokens that have not
+// been sent yet: the length bucket, the presence of digits, the extension, the charset class of the name and
+// a leading "." or "_".
+func TokenFeatures(token string) [5]Feature {
+	name := token
+	ext := "none"
+	if i := strings.LastIndex(token, "."); i > 0 && i < len(token)-1 && len(token)-i <= 6 && isAlnum(token[i+1:]) {
+		name = token[:i]
+		ext = strings.ToLower(token[i+1:])
+	}
+	prefix := "none"
+	if strings.HasPrefix(token, ".") || strings.HasPrefix(token, "_") {
+		prefix = token[:1]
+	}
+	return [5]Feature{
+		{"len", lengthBucket(len(token))},
+		{"digit", boolFeature(strings.ContainsAny(token, "0123456789"))},
+		{"ext", ext},
+		{"charset", charsetClass(name)},
+		{"prefix", prefix},
+	}
+}
+
+// lengthBucket converts the length of a token to a bucket representation
+func lengthBucket(length int) string {
+	switch {
+	case length <= 3:
+		return "1-3"
+	case length <= 7:
+		return "4-7"
+	case length <= 15:
+		return "8-15"
+	default:
+		return "16+"
+	}
+}
+
+func boolFeature(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// charsetClass returns "lower", "upper" or "mixed" for letter-only names, "alnum" for names with letters and
+// digits, "digit" for numbers and "symbol" for anything containing other characters
+func charsetClass(name string) string {
+	lower, upper, digit := false, false, false
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		default:
+			return "symbol"
+		}
+	}
+	switch {
+	case digit && (lower || upper):
+		return "alnum"
+	case digit:
+		return "digit"
+	case lower && upper:
+		return "mixed"
+	case upper:
+		return "upper"
+	default:
+		return "lower"
+	}
+}
+
+// isAlnum returns true if the string only consists of ASCII letters and digits
+func isAlnum(s string) bool {
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// updateFeatures applies the Q-learning update to the values of the features of the token in a state.
+// Must be called with the mutex held.
+func (mc *MarkovChain) updateFeatures(stateKey string, token string, reward float64, maxNextQ float64) {
+	if _, exists := mc.FeatureQTable[stateKey]; !exists {
+		mc.FeatureQTable[stateKey] = make(map[Feature]float64)
+		mc.FeatureCounts[stateKey] = make(map[Feature]int)
+	}
+	row := mc.FeatureQTable[stateKey]
+	counts := mc.FeatureCounts[stateKey]
+	for _, f := range TokenFeatures(token) {
+		row[f] += mc.Alpha * (reward + mc.Gamma*maxNextQ - row[f])
+		counts[f]++
+	}
+}
+
+// featureScore returns the mean learned value of the features of the token from the feature rows of a state,
+// and false if none of its features have been observed at least MinObservations times in the state.
+// Must be called with the mutex held.
+func (mc *MarkovChain) featureScore(row map[Feature]float64, counts map[Feature]int, token string) (float64, bool) {
+	sum := 0.0
+	known := 0
+	for _, f := range TokenFeatures(token) {
+		if v, exists := row[f]; exists && counts[f] >= mc.MinObservations {
+			sum += v
+			known++
+		}
+	}
+	if known == 0 {
+		return 0, false
+	}
+	return sum / float64(known), true
+}
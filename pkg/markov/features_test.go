@@ -0,0 +1,68 @@
+package markov
+
+import (
+	"testing"
+)
+
+func TestTokenFeatures(t *testing.T) {
+	tests := []struct {
+		token    string
+		expected [5]Feature
+	}{
+		{"admin", [5]Feature{{"len", "4-7"}, {"digit", "no"}, {"ext", "none"}, {"charset", "lower"}, {"prefix", "none"}}},
+		{"Backup2.PHP", [5]Feature{{"len", "8-15"}, {"digit", "yes"}, {"ext", "php"}, {"charset", "alnum"}, {"prefix", "none"}}},
+		{".htaccess", [5]Feature{{"len", "8-15"}, {"digit", "no"}, {"ext", "none"}, {"charset", "symbol"}, {"prefix", "."}}},
+		{"_vti_bin", [5]Feature{{"len", "8-15"}, {"digit", "no"}, {"ext", "none"}, {"charset", "symbol"}, {"prefix", "_"}}},
+		{"API", [5]Feature{{"len", "1-3"}, {"digit", "no"}, {"ext", "none"}, {"charset", "upper"}, {"prefix", "none"}}},
+		{"archive.tar.gz", [5]Feature{{"len", "8-15"}, {"digit", "no"}, {"ext", "gz"}, {"charset", "symbol"}, {"prefix", "none"}}},
+		{"2026", [5]Feature{{"len", "4-7"}, {"digit", "yes"}, {"ext", "none"}, {"charset", "digit"}, {"prefix", "none"}}},
+	}
+	for _, test := range tests {
+		if features := TokenFeatures(test.token); features != test.expected {
+			t.Errorf("Unexpected features for %s: %v, want %v", test.token, features, test.expected)
+		}
+	}
+}
+
+func TestUnseenTokensRankedByFeatures(t *testing.T) {
+	mc := NewMarkovChain()
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	for i := 0; i < 5; i++ {
+		for _, token := range []string{"index.php", "login.php", "config.php"} {
+			mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: token}, ToState: State{CodeClass: "2xx", SizeBucket: "1000"}, Reward: 3.0})
+		}
+		for _, token := range []string{"about.html", "help.html", "news.html"} {
+			mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: token}, ToState: State{CodeClass: "4xx", SizeBucket: "100"}, Reward: 0.0})
+		}
+	}
+
+	wordlist := []string{"contact.html", "search.html", "upload.php", "report.php", "export.html", "debug.php"}
+	best := mc.GetBestActionsForState(from, wordlist, 3)
+	for _, word := range best {
+		if word != "upload.php" && word != "report.php" && word != "debug.php" {
+			t.Errorf("Expected the unseen .php words to outrank the unseen .html words, got %v", best)
+			break
+		}
+	}
+}
+
+func TestKnownActionsBlendFeatureScore(t *testing.T) {
+	mc := NewMarkovChain()
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	for i := 0; i < 10; i++ {
+		mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "index.php"}, ToState: State{CodeClass: "2xx"}, Reward: 3.0})
+	}
+	mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "robots.txt"}, ToState: State{CodeClass: "2xx"}, Reward: 1.0})
+
+	// The known words keep their ordering by Q-value, and words listed multiple times are returned once
+	best := mc.GetBestActionsForState(from, []string{"robots.txt", "index.php", "index.php", "index.php"}, 3)
+	if len(best) != 2 || best[0] != "index.php" || best[1] != "robots.txt" {
+		t.Errorf("Unexpected ranking of the known words: %v", best)
+	}
+
+	mc.FeatureWeight = 0
+	best = mc.GetBestActionsForState(from, []string{"robots.txt", "index.php"}, 2)
+	if best[0] != "index.php" {
+		t.Errorf("Expected the exact Q-values to be used when the feature weight is 0, got %v", best)
+	}
+}
@@ -0,0 +1,99 @@
+package markov
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// headerPreset holds the statistics of a header preset of the pool
+type headerPreset struct {
+	name    string
+	pulls   int
+	blocked int
+}
+
+// HeaderPool learns which of a pool of header presets, like different User-Agents or Accept-Language values,
+// gets blocked the least. The presets are chosen with UCB1 over their rate of responses that were not blocked.
+// The preset used never enters the state of the Markov chain: it is a property of the requests, not of the
+// target.
+type HeaderPool struct {
+	presets  []headerPreset
+	pulls    int
+	statuses map[int64]bool
+	mutex    sync.Mutex
+}
+
+// NewHeaderPool returns a header pool over the named presets. Responses with a status of the block set, or any
+// other 4xx status than 404, count as blocked.
+func NewHeaderPool(names []string, blockStatuses []int64) *HeaderPool {
+	hp := &HeaderPool{statuses: make(map[int64]bool)}
+	for _, name := range names {
+		hp.presets = append(hp.presets, headerPreset{name: name})
+	}
+	for _, s := range blockStatuses {
+		hp.statuses[s] = true
+	}
+	return hp
+}
+
+// Choose returns the index of the preset to send the next request with
+func (hp *HeaderPool) Choose() int {
+	hp.mutex.Lock()
+	defer hp.mutex.Unlock()
+
+	chosen := -1
+	best := 0.0
+	for i, p := range hp.presets {
+		if p.pulls == 0 {
+			// Presets without feedback yet are tried first
+			return i
+		}
+		score := 1 - float64(p.blocked)/float64(p.pulls) + math.Sqrt(2*math.Log(float64(hp.pulls))/float64(p.pulls))
+		if chosen == -1 || score > best {
+			chosen = i
+			best = score
+		}
+	}
+	return chosen
+}
+
+// Feedback records the status code of a response to a request sent with the preset
+func (hp *HeaderPool) Feedback(preset int, status int64) {
+	hp.mutex.Lock()
+	defer hp.mutex.Unlock()
+
+	hp.presets[preset].pulls++
+	hp.pulls++
+	if hp.statuses[status] || (status >= 400 && status < 500 && status != 404) {
+		hp.presets[preset].blocked++
+	}
+}
+
+// BlockRate returns the rate of blocked responses of the preset
+func (hp *HeaderPool) BlockRate(preset int) float64 {
+	hp.mutex.Lock()
+	defer hp.mutex.Unlock()
+
+	p := hp.presets[preset]
+	if p.pulls == 0 {
+		return 0
+	}
+	return float64(p.blocked) / float64(p.pulls)
+}
+
+// Summary returns the number of requests and the block rate of each preset
+func (hp *HeaderPool) Summary() []string {
+	hp.mutex.Lock()
+	defer hp.mutex.Unlock()
+
+	lines := make([]string, 0, len(hp.presets))
+	for _, p := range hp.presets {
+		rate := 0.0
+		if p.pulls > 0 {
+			rate = float64(p.blocked) / float64(p.pulls)
+		}
+		lines = append(lines, fmt.Sprintf("Header preset %s: %d requests, block rate %.3f", p.name, p.pulls, rate))
+	}
+	return lines
+}
@@ -0,0 +1,43 @@
+package markov
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderPoolAvoidsBlockedPreset(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == "curl/8.0" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	agents := []string{"curl/8.0", "Mozilla/5.0 (X11; Linux x86_64)", "Mozilla/5.0 (Windows NT 10.0)"}
+	hp := NewHeaderPool([]string{"curl", "linux", "windows"}, []int64{403, 429})
+	counts := make([]int, len(agents))
+	for i := 0; i < 300; i++ {
+		preset := hp.Choose()
+		counts[preset]++
+		req, _ := http.NewRequest("GET", ts.URL, nil)
+		req.Header.Set("User-Agent", agents[preset])
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		hp.Feedback(preset, int64(resp.StatusCode))
+	}
+	if hp.BlockRate(0) != 1 || hp.BlockRate(1) != 0 || hp.BlockRate(2) != 0 {
+		t.Errorf("Expected only the curl preset to be blocked, 404s not counting, got %v", hp.Summary())
+	}
+	if counts[0] > 30 {
+		t.Errorf("Expected the blocked preset to be avoided, got %v requests per preset", counts)
+	}
+	if counts[1] < 100 || counts[2] < 100 {
+		t.Errorf("Expected the requests to be split across the presets that are not blocked, got %v", counts)
+	}
+}
@@ -0,0 +1,228 @@
+package markov
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// HistoryEntry is a request-response pair recorded by an intercepting proxy
+type HistoryEntry struct {
+	URL           string
+	StatusCode    int64
+	ContentLength int64
+	Data          []byte // response body, empty if it was not recorded
+}
+
+// ReadHistoryFile reads the entries of a proxy history export, calling fn for each of them as they are parsed.
+// Files with the .har extension are read as HAR, anything else as a Burp XML export.
+func ReadHistoryFile(filename string, fn func(HistoryEntry) error) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if strings.EqualFold(path.Ext(filename), ".har") {
+		return ReadHAR(f, fn)
+	}
+	return ReadBurpXML(f, fn)
+}
+
+// burpItem is a single item of a Burp XML export
+type burpItem struct {
+	URL            string `xml:"url"`
+	Status         int64  `xml:"status"`
+	ResponseLength int64  `xml:"responselength"`
+	Response       struct {
+		Base64 bool   `xml:"base64,attr"`
+		Data   string `xml:",chardata"`
+	} `xml:"response"`
+}
+
+// ReadBurpXML streams the items of a Burp XML export. Items without a response status are skipped.
+func ReadBurpXML(r io.Reader, fn func(HistoryEntry) error) error {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "item" {
+			continue
+		}
+		var item burpItem
+		if err := dec.DecodeElement(&item, &start); err != nil {
+			return err
+		}
+		if item.Status == 0 {
+			continue
+		}
+		entry := HistoryEntry{URL: strings.TrimSpace(item.URL), StatusCode: item.Status, ContentLength: item.ResponseLength}
+		raw := []byte(item.Response.Data)
+		if item.Response.Base64 {
+			raw, err = base64.StdEncoding.DecodeString(strings.TrimSpace(item.Response.Data))
+			if err != nil {
+				raw = nil
+			}
+		}
+		// The recorded response includes the headers, only keep the body
+		if i := bytes.Index(raw, []byte("\r\n\r\n")); i >= 0 {
+			entry.Data = raw[i+4:]
+			entry.ContentLength = int64(len(entry.Data))
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// harEntry is a single entry of a HAR file
+type harEntry struct {
+	Request struct {
+		URL string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		Status   int64 `json:"status"`
+		BodySize int64 `json:"bodySize"`
+		Content  struct {
+			Size     int64  `json:"size"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+// ReadHAR streams the entries of a HAR file, without reading the whole file in memory.
+// Entries without a response status are skipped.
+func ReadHAR(r io.Reader, fn func(HistoryEntry) error) error {
+	dec := json.NewDecoder(r)
+	return readJSONObject(dec, func(key string) error {
+		if key != "log" {
+			return skipJSONValue(dec)
+		}
+		return readJSONObject(dec, func(key string) error {
+			if key != "entries" {
+				return skipJSONValue(dec)
+			}
+			if err := expectJSONDelim(dec, '['); err != nil {
+				return err
+			}
+			for dec.More() {
+				var e harEntry
+				if err := dec.Decode(&e); err != nil {
+					return err
+				}
+				if e.Response.Status == 0 {
+					continue
+				}
+				entry := HistoryEntry{URL: e.Request.URL, StatusCode: e.Response.Status}
+				entry.Data = []byte(e.Response.Content.Text)
+				if e.Response.Content.Encoding == "base64" {
+					data, err := base64.StdEncoding.DecodeString(e.Response.Content.Text)
+					if err == nil {
+						entry.Data = data
+					}
+				}
+				switch {
+				case e.Response.Content.Size > 0:
+					entry.ContentLength = e.Response.Content.Size
+				case e.Response.BodySize > 0:
+					entry.ContentLength = e.Response.BodySize
+				default:
+					entry.ContentLength = int64(len(entry.Data))
+				}
+				if err := fn(entry); err != nil {
+					return err
+				}
+			}
+			return expectJSONDelim(dec, ']')
+		})
+	})
+}
+
+// readJSONObject reads a JSON object, calling fn for each key with the decoder positioned at its value
+func readJSONObject(dec *json.Decoder, fn func(key string) error) error {
+	if err := expectJSONDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("unexpected JSON token %v", tok)
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return expectJSONDelim(dec, '}')
+}
+
+func skipJSONValue(dec *json.Decoder) error {
+	var raw json.RawMessage
+	return dec.Decode(&raw)
+}
+
+func expectJSONDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("unexpected JSON token %v, expected %s", tok, delim)
+	}
+	return nil
+}
+
+// historyToken returns the last path segment of the URL, used as the action of a history entry
+func historyToken(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	token := path.Base(strings.TrimSuffix(u.Path, "/"))
+	if token == "." || token == "/" {
+		return ""
+	}
+	return token
+}
+
+// SeedFromHistory warm-starts the chain with the entries of a proxy history export, before the run starts.
+// Each entry becomes a transition from the baseline state, with the last path segment of its URL as the
+// action. The rewards are calculated against a null baseline. Returns the number of entries used.
+func (mip *MarkovInputProvider) SeedFromHistory(filename string) (int, error) {
+	mip.mutex.Lock()
+	baseline := mip.baselineState.WithGranularity(mip.granularity)
+	granularity := mip.granularity
+	depth := mip.depth
+	location := mip.keywordLocations["FUZZ"]
+	mip.mutex.Unlock()
+
+	count := 0
+	err := ReadHistoryFile(filename, func(entry HistoryEntry) error {
+		token := historyToken(entry.URL)
+		if token == "" {
+			return nil
+		}
+		resp := &Response{StatusCode: entry.StatusCode, ContentLength: entry.ContentLength, Data: entry.Data}
+		reward := CalculateRewardFromResponseStruct(resp, State{}, "")
+		mip.AddTransition(baseline, Action{Token: token, Location: location}, GetStateFromResponseFromResponseStruct(resp, depth).WithGranularity(granularity), reward)
+		count++
+		return nil
+	})
+	return count, err
+}
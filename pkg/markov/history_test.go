@@ -0,0 +1,89 @@
+package markov
+
+import (
+	"testing"
+)
+
+func readHistoryEntries(t *testing.T, filename string) []HistoryEntry {
+	t.Helper()
+	entries := make([]HistoryEntry, 0)
+	err := ReadHistoryFile(filename, func(e HistoryEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Could not read %s: %s", filename, err)
+	}
+	return entries
+}
+
+func TestReadBurpXML(t *testing.T) {
+	entries := readHistoryEntries(t, "testdata/history.xml")
+	if len(entries) != 2 {
+		t.Fatalf("Expected the item without a response to be skipped, got %d entries", len(entries))
+	}
+	if entries[0].URL != "http://example.com/admin" || entries[0].StatusCode != 200 {
+		t.Errorf("Unexpected first entry: %+v", entries[0])
+	}
+	if string(entries[0].Data) != "<html>admin panel</html>" || entries[0].ContentLength != 24 {
+		t.Errorf("Expected the response body without headers, got %q (%d)", entries[0].Data, entries[0].ContentLength)
+	}
+	// Missing response body falls back to the recorded length
+	if len(entries[1].Data) != 0 || entries[1].ContentLength != 1500 || entries[1].StatusCode != 403 {
+		t.Errorf("Unexpected entry without a response body: %+v", entries[1])
+	}
+}
+
+func TestReadHAR(t *testing.T) {
+	entries := readHistoryEntries(t, "testdata/history.har")
+	if len(entries) != 3 {
+		t.Fatalf("Expected the entry without a response to be skipped, got %d entries", len(entries))
+	}
+	if entries[0].URL != "http://example.com/login?next=/" || string(entries[0].Data) != "<form>login</form>" {
+		t.Errorf("Unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].StatusCode != 404 || entries[1].ContentLength != 2300 {
+		t.Errorf("Expected the body size to be used without content, got %+v", entries[1])
+	}
+	if string(entries[2].Data) != "index" {
+		t.Errorf("Expected base64 content to be decoded, got %q", entries[2].Data)
+	}
+}
+
+func TestHistoryToken(t *testing.T) {
+	tests := map[string]string{
+		"http://example.com/admin":          "admin",
+		"http://example.com/static/backup/": "backup",
+		"http://example.com/login?next=/":   "login",
+		"http://example.com/":               "",
+		"http://example.com":                "",
+	}
+	for u, expected := range tests {
+		if token := historyToken(u); token != expected {
+			t.Errorf("historyToken(%s) = %q, want %q", u, token, expected)
+		}
+	}
+}
+
+func TestSeedFromHistory(t *testing.T) {
+	mip := newTestProvider()
+	count, err := mip.SeedFromHistory("testdata/history.xml")
+	if err != nil || count != 2 {
+		t.Fatalf("Expected 2 seeded entries, got %d (%v)", count, err)
+	}
+	if states := nextStates(mip, "admin"); len(states) != 1 || states[0] != "2xx_20_0" {
+		t.Errorf("Expected a transition from the baseline for admin, got %v", states)
+	}
+	if mip.MarkovChain.GetExpectedReward(mip.baselineState, "admin") <= mip.MarkovChain.GetExpectedReward(mip.baselineState, "unknown") {
+		t.Errorf("Seeded action should have a higher expected reward than an unknown one")
+	}
+
+	mip = newTestProvider()
+	count, err = mip.SeedFromHistory("testdata/history.har")
+	if err != nil || count != 2 {
+		t.Fatalf("Expected 2 seeded entries, the root url has no token, got %d (%v)", count, err)
+	}
+	if len(nextStates(mip, "login")) != 1 || len(nextStates(mip, "nothere")) != 1 {
+		t.Errorf("Expected transitions for the HAR entries, got %v", mip.MarkovChain.ActionCounts)
+	}
+}
@@ -0,0 +1,182 @@
+package markov
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxTokenKeyLength is the longest token kept as is in the keys of the chain, in bytes. Longer tokens are cut and
+	// suffixed with the hash of the whole token, so that they stay distinct without a megabyte-long line of a
+	// wordlist becoming a key of every table.
+	MaxTokenKeyLength = 256
+	// DefaultUniqueEntropy is the suggested entropy, in bits per character, from which a long alphanumeric run of a
+	// token looks random. Random base64 of 20 characters is around 4, the longest camel case words stay under 3.9.
+	// The unique tokens are only skipped when an entropy is set, see SetTokenHygiene.
+	DefaultUniqueEntropy = 3.9
+	// uniqueRunLength is the length from which an alphanumeric run of a token is checked for its entropy
+	uniqueRunLength = 20
+	// uniqueHexLength is the length from which a run of hex digits mixing digits and letters looks like a hash or an id
+	uniqueHexLength = 16
+)
+
+// CapToken returns the token cut to MaxTokenKeyLength bytes, ending in "#" and the FNV-1a hash of the whole token
+// when it is longer. The cut never splits a UTF-8 sequence.
+func CapToken(token string) string {
+	if len(token) <= MaxTokenKeyLength {
+		return token
+	}
+	h := fnv.New64a()
+	h.Write([]byte(token))
+	suffix := fmt.Sprintf("#%016x", h.Sum64())
+	cut := MaxTokenKeyLength - len(suffix)
+	for cut > 0 && !utf8.RuneStart(token[cut]) {
+		cut--
+	}
+	return token[:cut] + suffix
+}
+
+// NormalizeToken returns the token as valid UTF-8, the invalid bytes replaced by U+FFFD, without control and
+// zero-width characters, and with the fullwidth forms of the ASCII characters folded to ASCII so that "ａｄｍｉｎ"
+// is learned as "admin". It covers the compatibility forms found in wordlists, not the full NFKC normalization.
+func NormalizeToken(token string) string {
+	token = strings.ToValidUTF8(token, "\uFFFD")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 0xFF01 && r <= 0xFF5E:
+			return r - 0xFEE0
+		case r == 0x3000:
+			return ' '
+		case r == 0x200B || r == 0x200C || r == 0x200D || r == 0xFEFF:
+			return -1
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, token)
+}
+
+// LooksUnique returns true if the token contains a part unlikely to be shared by any other token, which the chain
+// could never generalize from: a UUID, a run of at least 16 hex digits mixing digits and letters like a hash, or an
+// alphanumeric run of at least 20 characters with digits and an entropy of at least minEntropy bits per character
+// like a random id.
+func LooksUnique(token string, minEntropy float64) bool {
+	if isUUID(token) {
+		return true
+	}
+	for _, run := range strings.FieldsFunc(token, func(r rune) bool { return !isAlnumRune(r) }) {
+		if len(run) >= uniqueHexLength && isMixedHex(run) {
+			return true
+		}
+		if len(run) >= uniqueRunLength && strings.ContainsAny(run, "0123456789") && charEntropy(run) >= minEntropy {
+			return true
+		}
+	}
+	return false
+}
+
+// isUUID returns true if the token contains a UUID in its 8-4-4-4-12 hex digits form
+func isUUID(token string) bool {
+	groups := []int{8, 4, 4, 4, 12}
+	for start := strings.Index(token, "-"); start >= 0; start = nextIndex(token, "-", start) {
+		if start < 8 {
+			continue
+		}
+		i, ok := start-8, true
+		for g, n := range groups {
+			if i+n > len(token) || !isHex(token[i:i+n]) {
+				ok = false
+				break
+			}
+			i += n
+			if g < len(groups)-1 {
+				if i >= len(token) || token[i] != '-' {
+					ok = false
+					break
+				}
+				i++
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// nextIndex returns the index of the next occurrence of sep in s after the index i, -1 if there is none
+func nextIndex(s string, sep string, i int) int {
+	next := strings.Index(s[i+1:], sep)
+	if next < 0 {
+		return -1
+	}
+	return i + 1 + next
+}
+
+// isHex returns true if the string only consists of hex digits
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') && !(c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// isMixedHex returns true if the string only consists of hex digits, with both decimal digits and letters
+func isMixedHex(s string) bool {
+	return isHex(s) && strings.ContainsAny(s, "0123456789") && strings.ContainsAny(s, "abcdefABCDEF")
+}
+
+func isAlnumRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// charEntropy returns the Shannon entropy of the characters of the string in bits per character
+func charEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// uniqueEntropy returns the entropy from which the tokens looking unique are not learned, read under the read lock
+// so that the entropy of a token is computed outside of the critical section of UpdateTransition
+func (mc *MarkovChain) uniqueEntropy() float64 {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+	return mc.UniqueEntropy
+}
+
+// SkippedTokens returns the number of transitions not learned because their token looked unique
+func (mc *MarkovChain) SkippedTokens() int64 {
+	return atomic.LoadInt64(&mc.skippedTokens)
+}
+
+// SetTokenHygiene sets the entropy from which the tokens looking unique are not learned, 0 learning all of them, and
+// whether the tokens are normalized with NormalizeToken before they are learned and ranked. The skipped tokens are
+// still sent, the chain only does not keep them.
+func (mip *MarkovInputProvider) SetTokenHygiene(uniqueEntropy float64, normalize bool) {
+	mip.MarkovChain.mutex.Lock()
+	mip.MarkovChain.UniqueEntropy = uniqueEntropy
+	mip.MarkovChain.mutex.Unlock()
+
+	if normalize {
+		atomic.StoreInt32(&mip.normalizeTokens, 1)
+	} else {
+		atomic.StoreInt32(&mip.normalizeTokens, 0)
+	}
+}
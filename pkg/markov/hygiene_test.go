@@ -0,0 +1,164 @@
+package markov
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCapToken(t *testing.T) {
+	if token := CapToken("admin"); token != "admin" {
+		t.Errorf("Short tokens should be kept as is, got %s", token)
+	}
+	line := strings.Repeat("a", 1<<20)
+	capped := CapToken(line)
+	if len(capped) > MaxTokenKeyLength {
+		t.Errorf("Expected the token capped to %d bytes, got %d", MaxTokenKeyLength, len(capped))
+	}
+	if other := CapToken(line + "b"); other == capped {
+		t.Errorf("Long tokens differing after the cut should stay distinct")
+	}
+	if CapToken(capped) != capped {
+		t.Errorf("Capping a capped token should not change it")
+	}
+	// A multi-byte character across the cut is not split
+	if multi := CapToken(strings.Repeat("é", 200)); !utf8.ValidString(multi) {
+		t.Errorf("The cut should not split a UTF-8 sequence: %q", multi)
+	}
+}
+
+func TestLooksUnique(t *testing.T) {
+	tests := []struct {
+		token  string
+		unique bool
+	}{
+		{"admin", false},
+		{"backup_2023_final_version", false},
+		{"ResetPasswordController2023", false},
+		{"ChangePasswordConfirmation", false},
+		{"wp-content/uploads/2021", false},
+		{"api/v1/users/12345", false},
+		{"internationalization", false},
+		{"e137e64b-02cd-4fad-958b-9db6c3fced70", true},
+		{"files/E137E64B-02CD-4FAD-958B-9DB6C3FCED70.pdf", true},
+		{"5f4dcc3b5aa765d61d8327deb882cf99", true},
+		{"session_1234567890abcdef", true},
+		{"OJj8PE7mIFWAAIpVihTJ", true},
+		{"deadbeefdeadbeef", false}, // no digits, a word as much as an id
+	}
+	for _, tt := range tests {
+		if got := LooksUnique(tt.token, DefaultUniqueEntropy); got != tt.unique {
+			t.Errorf("LooksUnique(%q) = %t, expected %t", tt.token, got, tt.unique)
+		}
+	}
+}
+
+func TestNormalizeToken(t *testing.T) {
+	tests := map[string]string{
+		"admin":         "admin",
+		"ａｄｍｉｎ":         "admin",
+		"ad\u200bmin":   "admin",
+		"ad\x00min\r\n": "admin",
+		"caf\xe9":       "caf\uFFFD",
+		"über":          "über",
+		"ｉｎｄｅｘ．ｐｈｐ":     "index.php",
+	}
+	for token, expected := range tests {
+		if got := NormalizeToken(token); got != expected {
+			t.Errorf("NormalizeToken(%q) = %q, expected %q", token, got, expected)
+		}
+	}
+}
+
+func TestUpdateTransitionPathologicalTokens(t *testing.T) {
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	to := State{CodeClass: "4xx", SizeBucket: "100"}
+	feed := func(mc *MarkovChain) {
+		for i := 0; i < 10; i++ {
+			mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: strings.Repeat(fmt.Sprintf("%d", i), 1<<20)}, ToState: to})
+		}
+		mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "\x00\xff\xfe\x01binary\x7f"}, ToState: to})
+		for i := 0; i < 10000; i++ {
+			uuid := fmt.Sprintf("%08x-%04x-4%03x-a%03x-%012x", i*2654435761, i%65536, i%4096, (i*7)%4096, i*11400714819)
+			mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: uuid}, ToState: to})
+		}
+	}
+
+	mc := NewMarkovChain()
+	mc.UniqueEntropy = DefaultUniqueEntropy
+	feed(mc)
+	if skipped := mc.SkippedTokens(); skipped != 10000 {
+		t.Errorf("Expected the 10000 UUIDs not learned, %d were skipped", skipped)
+	}
+	row := mc.QTable[from.Hash()]
+	if len(row) != 11 {
+		t.Errorf("Expected the 10 long lines and the binary line learned, got %d actions", len(row))
+	}
+	for key := range row {
+		if len(key) > MaxTokenKeyLength {
+			t.Errorf("Expected the keys capped to %d bytes, got one of %d", MaxTokenKeyLength, len(key))
+		}
+	}
+	for _, r := range mc.GetRecentTransitions(20) {
+		if len(r.Action.Token) > MaxTokenKeyLength {
+			t.Errorf("Expected the recent transitions to keep the capped token, got one of %d bytes", len(r.Action.Token))
+		}
+	}
+	if bytes := mc.MemoryBytes(); bytes > 64<<10 {
+		t.Errorf("Expected the chain to stay under 64KB, it uses %d bytes", bytes)
+	}
+
+	// Every token is learned by default, the UUIDs included
+	all := NewMarkovChain()
+	feed(all)
+	if len(all.QTable[from.Hash()]) != 10011 || all.MemoryBytes() <= mc.MemoryBytes() {
+		t.Errorf("Expected every token learned with the unique check disabled, got %d actions in %d bytes", len(all.QTable[from.Hash()]), all.MemoryBytes())
+	}
+}
+
+func TestLocatedTokenKeyCapped(t *testing.T) {
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	to := State{CodeClass: "2xx", SizeBucket: "1000"}
+	token := strings.Repeat("a", 1<<20)
+	action := Action{Token: token, Location: "query-value"}
+
+	mc := NewMarkovChain()
+	mc.UpdateTransition(Transition{FromState: from, Action: action, ToState: to, Reward: 1})
+	key := action.Key()
+	if len(key) > len("query-value:")+MaxTokenKeyLength {
+		t.Errorf("Expected the key of a located token capped, got %d bytes", len(key))
+	}
+	// The key of the raw token finds the value learned from the capped one
+	if _, ok := mc.QTable[from.Hash()][key]; !ok || len(mc.QTable[from.Hash()]) != 1 {
+		t.Errorf("Expected the located token learned under its capped key")
+	}
+	if _, known := mc.knownActions.Load(key); !known {
+		t.Errorf("Expected the located token known under its capped key")
+	}
+	recent := mc.GetRecentTransitions(1)
+	if len(recent) != 1 || recent[0].Action.Key() != key {
+		t.Errorf("Expected the recent transition keyed like the raw token")
+	}
+}
+
+func TestTokenHygieneNormalize(t *testing.T) {
+	mip := newTestProvider("ａｄｍｉｎ")
+	mip.SetTokenHygiene(DefaultUniqueEntropy, true)
+	resp := &Response{StatusCode: 200, ContentLength: 2000}
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("ａｄｍｉｎ")}, resp)
+	if _, ok := mip.MarkovChain.QTable[mip.baselineState.Hash()]["admin"]; !ok {
+		t.Errorf("Expected the fullwidth token learned as admin, got %v", mip.MarkovChain.QTable[mip.baselineState.Hash()])
+	}
+
+	// The skipped tokens are still sent
+	mip = newTestProvider("e137e64b-02cd-4fad-958b-9db6c3fced70")
+	mip.SetTokenHygiene(DefaultUniqueEntropy, false)
+	if !mip.Next() {
+		t.Fatalf("Expected the UUID to be sent")
+	}
+	mip.UpdateWithResponse(mip.Value(), resp)
+	if len(mip.MarkovChain.QTable[mip.baselineState.Hash()]) != 0 || mip.MarkovChain.SkippedTokens() != 1 {
+		t.Errorf("Expected the UUID sent but not learned")
+	}
+}
@@ -1,7 +1,12 @@
 package markov
 
 import (
+	"fmt"
+	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // InputProvider interface - matches the ffuf input provider interface
@@ -16,17 +21,82 @@ type InputProvider interface {
 	Total() int
 }
 
+// DefaultBatchSize is the number of inputs the provider reorders at once
+const DefaultBatchSize = 100
+
+// ColdStateDecision starts the decision of the inputs sent in wordlist order while a never seen state is explored
+const ColdStateDecision = "cold state"
+
+// ShortRedirectChain is the largest number of redirects of the chains given the reward of SetRedirectReward
+const ShortRedirectChain = 2
+
 // MarkovInputProvider wraps the original InputProvider with Markov chain logic
 type MarkovInputProvider struct {
 	OriginalProvider InputProvider
 	MarkovChain      *MarkovChain
-	previousInputs   map[string][]byte
+	previousInputs   map[string][]byte // inputs returned by the last Value call, shared with the caller
 	currentBatch     []map[string][]byte
+	batchPositions   []int    // positions of the inputs of the batch in the original provider
+	batchDecisions   []string // why each input of the batch is at its place, see Decision
+	dropped          int      // inputs dropped from the batches with DropPending, left out of Total
+	exhausted        bool     // the original provider has no more inputs, until Reset or SetPosition
 	currentIndex     int
 	batchSize        int
+	topActions       int            // chain ranked inputs placed at the head of each batch, 0 for the whole batch
+	coldBudget       int            // inputs sent in wordlist order in a never seen state, 0 to reorder at once
+	coldRemaining    map[string]int // inputs left to send in wordlist order, by the state they were ranked in
 	baselineState    State
 	baselineSizeHash string
 	depth            int
+	protocolState    bool
+	headerState      bool
+	cookieState      bool
+	granularity      StateGranularity
+	keywordLocations map[string]string
+	timeoutReward    float64
+	connErrorReward  float64
+	cookieReward     float64
+	redirectReward   float64 // added to the reward of the responses reached through a short internal redirect chain
+	reflectionReward float64 // added to the reward of the responses reflecting a fuzzed value
+	rewardExpr       *RewardExpr
+	seenCookies      map[string]bool
+	validatorReward  float64         // added to the reward of the first response under a path with a validator value
+	seenValidators   map[string]bool // validator values seen under each path
+	stacks           stackPreference // stack of the target, the inputs of the other stacks being deprioritized
+	vhostBaselines   []VhostBaseline // responses of the default virtual host when the Host header is fuzzed
+	blocking         *blockDetector
+	durationMaxCV    float64       // coefficient of variation of the baseline durations past which they are noise
+	durations        durationCheck // spread of the durations of the baseline responses
+	// Share of the inputs of the learn phase, 0 without phases, the inputs read from the original provider since the
+	// last Reset, the current phase and the phase entered since the last NewPhase
+	learnShare float64
+	read       int
+	phase      string
+	newPhase   string
+	// Mean reward of the tokens of each path prefix, and the prefixes suggested as recursion roots
+	prefixThreshold   float64
+	prefixRewards     map[string]*RewardStat
+	suggestedPrefixes map[string]bool
+	pendingPrefixes   []PrefixSuggestion // suggested since the last NewPrefixSuggestions
+	prefixSuggestions []PrefixSuggestion
+	// Reward statistics of the values of each keyword in the multi keyword inputs
+	valueRewards     map[string]map[string]*RewardStat
+	matchedTokens    map[string]float64 // best reward of the matched tokens, for the induction of patterns
+	matchedOrder     []string           // matched tokens in the order they were first recorded, oldest first
+	expandedPatterns map[string]bool
+	generatedTokens  map[string][]string // tokens generated from the patterns, with the matched tokens they come from
+	seeds            map[string]*seedStats
+	retiredSeeds     map[string]bool
+	retiredOrder     []RetiredSeed
+	latencies        map[string]*actionLatency // response times of each action
+	saving           int32                     // 1 while SaveChain is writing the chain file
+	debugLog         bool                      // write the transitions to the debug log
+	caseInsensitive  int32                     // 1 once the target was found case-insensitive, read without locking
+	normalizeTokens  int32                     // 1 when the tokens are normalized with NormalizeToken, read without locking
+	// Reward decision tables with the weights changed by SetParam, nil for the shared tables, and the changes made
+	rewardRules      []RewardRule
+	vhostRewardRules []RewardRule
+	paramChanges     []ParamChange
 	mutex            sync.Mutex
 }
 
@@ -35,13 +105,21 @@ func NewMarkovInputProvider(original InputProvider, baselineState State, baselin
 	return &MarkovInputProvider{
 		OriginalProvider: original,
 		MarkovChain:      NewMarkovChain(),
-		previousInputs:   make(map[string][]byte),
+		previousInputs:   nil,
 		currentBatch:     make([]map[string][]byte, 0),
 		currentIndex:     0,
-		batchSize:        100, // Process inputs in batches to make better predictions
+		batchSize:        DefaultBatchSize, // Process inputs in batches to make better predictions
 		baselineState:    baselineState,
 		baselineSizeHash: baselineSizeHash,
 		depth:            depth,
+		timeoutReward:    0.2,
+		connErrorReward:  0.0,
+		cookieReward:     0.0,
+		seenCookies:      make(map[string]bool),
+		validatorReward:  DefaultValidatorReward,
+		seenValidators:   make(map[string]bool),
+		granularity:      GranularityDefault,
+		durationMaxCV:    DefaultDurationMaxCV,
 	}
 }
 
@@ -52,15 +130,156 @@ func (mip *MarkovInputProvider) SetBaseline(baselineState State, baselineSizeHas
 	
 	mip.baselineState = baselineState
 	mip.baselineSizeHash = baselineSizeHash
+	mip.durations = durationCheck{}
+}
+
+// SetProtocolState controls whether the negotiated protocol of the responses is included in the state
+func (mip *MarkovInputProvider) SetProtocolState(enabled bool) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.protocolState = enabled
+}
+
+// SetHeaderState controls whether the bucketed number of response headers and the presence of a Set-Cookie
+// header are included in the state
+func (mip *MarkovInputProvider) SetHeaderState(count bool, cookie bool) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.headerState = count
+	mip.cookieState = cookie
+}
+
+// SetGranularity sets the granularity preset of the states recorded in the chain. The fine preset includes
+// all the optional dimensions of the state regardless of them being enabled.
+func (mip *MarkovInputProvider) SetGranularity(g StateGranularity) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.granularity = g
+	mip.MarkovChain.Granularity = g
+}
+
+// SetBucketizer sets the size buckets of the states recorded in the chain
+func (mip *MarkovInputProvider) SetBucketizer(b Bucketizer) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.MarkovChain.Buckets = b
+}
+
+// SetThreshold sets the noise gate of the chain, the smallest temporal difference error updating the Q-value of a
+// known action
+func (mip *MarkovInputProvider) SetThreshold(threshold float64) {
+	mip.MarkovChain.mutex.Lock()
+	defer mip.MarkovChain.mutex.Unlock()
+
+	mip.MarkovChain.Threshold = threshold
+}
+
+// SetDebugLog enables writing each transition to the log, the debug log of ffuf
+func (mip *MarkovInputProvider) SetDebugLog(enabled bool) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.debugLog = enabled
+}
+
+// SetCaseInsensitive sets whether the target was found to be case-insensitive, the words differing only by their case
+// from a word sent before being skipped as duplicates
+func (mip *MarkovInputProvider) SetCaseInsensitive(insensitive bool) {
+	if insensitive {
+		atomic.StoreInt32(&mip.caseInsensitive, 1)
+	} else {
+		atomic.StoreInt32(&mip.caseInsensitive, 0)
+	}
+}
+
+// CaseInsensitive reports whether the target was found to be case-insensitive
+func (mip *MarkovInputProvider) CaseInsensitive() bool {
+	return atomic.LoadInt32(&mip.caseInsensitive) == 1
+}
+
+// SetCookieReward sets the reward bonus given to the first response setting a cookie of a given name under a path
+func (mip *MarkovInputProvider) SetCookieReward(reward float64) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.cookieReward = reward
+}
+
+// SetRedirectReward sets the reward bonus given to the responses reached through an internal redirect chain of up to
+// ShortRedirectChain redirects, negative to rank them below the direct responses
+func (mip *MarkovInputProvider) SetRedirectReward(reward float64) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.redirectReward = reward
+}
+
+// SetReflectionReward sets the reward bonus given to the responses reflecting a fuzzed value in their body or headers,
+// as told by Response.Reflected. 0 leaves the reflections out of the reward.
+func (mip *MarkovInputProvider) SetReflectionReward(reward float64) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.reflectionReward = reward
+}
+
+// SetRewardExpr sets the expression computing the rewards of the responses in place of the built-in decision table,
+// nil to use the decision table
+func (mip *MarkovInputProvider) SetRewardExpr(expr *RewardExpr) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.rewardExpr = expr
+}
+
+// SetErrorRewards sets the rewards given to inputs ending up in the timeout and connection error states
+func (mip *MarkovInputProvider) SetErrorRewards(timeout float64, connError float64) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.timeoutReward = timeout
+	mip.connErrorReward = connError
+}
+
+// SetKeywordLocations sets the injection locations of the fuzz keywords, as detected from a raw request file
+func (mip *MarkovInputProvider) SetKeywordLocations(locations map[string]string) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.keywordLocations = locations
+}
+
+// SeedActions gives the tokens an initial Q-value bias from the baseline state, for tokens discovered from the target
+// before the run starts. Returns the number of unique tokens seeded.
+func (mip *MarkovInputProvider) SeedActions(tokens []string, bias float64) int {
+	mip.mutex.Lock()
+	baseline := mip.baselineState.WithGranularity(mip.granularity)
+	location := mip.keywordLocations["FUZZ"]
+	mip.mutex.Unlock()
+
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		mip.MarkovChain.BiasAction(baseline, Action{Token: token, Location: location}, bias)
+	}
+	return len(seen)
 }
 
 // AddTransition adds a transition to the Markov chain based on a request-response cycle
-func (mip *MarkovInputProvider) AddTransition(fromState State, action string, toState State, reward float64) {
+func (mip *MarkovInputProvider) AddTransition(fromState State, action Action, toState State, reward float64) {
 	transition := Transition{
 		FromState: fromState,
-		Action:    Action{Token: action},
+		Action:    action,
 		ToState:   toState,
 		Reward:    reward,
+		Timestamp: time.Now(),
 	}
 	mip.MarkovChain.UpdateTransition(transition)
 }
@@ -79,107 +298,229 @@ type Response struct {
 	Raw           string
 	ResultFile    string
 	ScraperData   map[string][]string
-	Duration      interface{} // time.Duration
-	Timestamp     interface{} // time.Time
+	// Deprecated: Duration is not used by the chain and will be removed
+	Duration interface{} // time.Duration
+	// Deprecated: Timestamp is not used by the chain and will be removed
+	Timestamp    interface{} // time.Time
+	Proto        string      // negotiated protocol, eg. "HTTP/1.1" or "HTTP/2.0"
+	Error        string      // CodeClassTimeout or a ConnErrorClass when the request failed without a response
+	CertMismatch bool        // served with a TLS certificate not covering the requested host
+	CertHash     string      // SHA-256 of the TLS certificate the response was served with, empty for plain HTTP
+	BodyHash     string      // hash of the body computed while reading it, in the format of GetSizeHash
+	Incomplete   bool        // the body was cut off, its size and counts telling nothing about the response
+	Path         string      // host and directory of the request, used to track the cookies set under each path
+	URL          string      // requested URL, used to derive the depth of the state. The provider depth is used if empty
+	JSONField    bool        // the fuzzed value is a field of a JSON request body, rewarding the responses to a malformed body
+	// Status of the first redirect followed to get the response, 0 for a direct response
+	RedirectStatus    int64
+	Redirects         int  // number of redirects followed to get the response
+	InternalRedirects bool // the redirects stayed on the host of the request
+	// Time to the first byte of the response
+	Elapsed time.Duration
+	// Position of the request in the results, recorded in the transition for the audit of what taught the chain
+	Position int
+	// A fuzzed value appears in the body or the headers, given the reward of SetReflectionReward
+	Reflected bool
 }
 
-// UpdateWithResponse updates the Markov chain with a response
-func (mip *MarkovInputProvider) UpdateWithResponse(inputs map[string][]byte, resp *Response) {
+// UpdateWithResponse updates the Markov chain with a response and returns the reward it was given
+func (mip *MarkovInputProvider) UpdateWithResponse(inputs map[string][]byte, resp *Response) float64 {
 	mip.mutex.Lock()
-	defer mip.mutex.Unlock()
 
 	// Create current state from response
-	currentState := GetStateFromResponseFromResponseStruct(resp, mip.depth)
-	
+	currentState := mip.stateFromResponse(resp)
+	mip.recordBlockSample(resp)
+	if resp.Incomplete && resp.Error == "" {
+		mip.MarkovChain.recordIncomplete()
+	}
+
 	// Get action (the value that was fuzzed, typically the FUZZ keyword)
-	var actionValue string
-	for kw, value := range inputs {
-		// Look for FUZZ keyword which is standard in ffuf
-		if kw == "FUZZ" {
-			actionValue = string(value)
-			break
+	action := mip.actionFromInputs(inputs)
+	mip.recordLatency(action.Key(), resp)
+	mip.recordDuration(resp, currentState)
+
+	// Calculate reward based on the response, failed requests get the configured reward of their terminal state
+	var reward float64
+	switch {
+	case resp.Error == CodeClassTimeout:
+		reward = mip.timeoutReward
+	case IsConnError(resp.Error):
+		reward = mip.connErrorReward
+	default:
+		if mip.rewardExpr != nil {
+			reward = mip.rewardExpr.evaluate(resp, func() float64 { return mip.builtinReward(resp) }, !mip.durations.unreliable)
+		} else {
+			reward = mip.builtinReward(resp)
+		}
+		reward += mip.newCookieReward(resp)
+		reward += mip.newValidatorReward(resp)
+		if resp.Redirects > 0 && resp.Redirects <= ShortRedirectChain && resp.InternalRedirects {
+			reward += mip.redirectReward
+		}
+		if resp.Reflected {
+			reward += mip.reflectionReward
 		}
 	}
-	
-	// Calculate reward based on the response
-	reward := CalculateRewardFromResponseStruct(resp, mip.baselineState, mip.baselineSizeHash)
-	
+	mip.recordValueRewards(inputs, reward)
+	mip.recordPrefixReward(action, reward)
+
 	// Create previous state from context (in a real implementation, we'd store this)
 	// For now, we'll just use the baseline state as the previous state
-	previousState := mip.baselineState
-	
-	// Add transition to Markov chain
-	mip.AddTransition(previousState, actionValue, currentState, reward)
-}
+	previousState := mip.baselineState.WithGranularity(mip.granularity)
+	debugLog := mip.debugLog
+	mip.mutex.Unlock()
 
-// GetStateFromResponseFromResponseStruct creates a state representation from our Response struct
-func GetStateFromResponseFromResponseStruct(resp *Response, depth int) State {
-	// Determine status code class
-	var codeClass string
-	switch {
-	case resp.StatusCode >= 200 && resp.StatusCode < 300:
-		codeClass = "2xx"
-	case resp.StatusCode >= 300 && resp.StatusCode < 400:
-		codeClass = "3xx"
-	case resp.StatusCode >= 400 && resp.StatusCode < 500:
-		codeClass = "4xx"
-	case resp.StatusCode >= 500 && resp.StatusCode < 600:
-		codeClass = "5xx"
-	default:
-		codeClass = "unknown"
+	// Add transition to Markov chain, which does its own locking or queues it when the updates are asynchronous
+	transition := Transition{
+		FromState: previousState,
+		Action:    action,
+		ToState:   currentState,
+		Reward:    reward,
+		Position:  resp.Position,
+		Inputs:    capInputs(inputs),
+		Timestamp: time.Now(),
 	}
+	mip.MarkovChain.Observe(transition)
+	if debugLog {
+		log.Printf("Markov transition %s", transition)
+	}
+	return reward
+}
 
-	// Quantize the content length into buckets
-	sizeBucket := quantizeSize(resp.ContentLength)
+// builtinReward returns the reward of a response from the built-in decision tables. Must be called with the mutex
+// held.
+func (mip *MarkovInputProvider) builtinReward(resp *Response) float64 {
+	rules, vhostRules := mip.rewardTables()
+	if len(mip.vhostBaselines) > 0 {
+		reward, _ := evaluateVhostReward(vhostRules, resp, mip.vhostBaselines)
+		return reward
+	}
+	reward, _ := evaluateReward(rules, resp, mip.baselineState, mip.baselineSizeHash)
+	return reward
+}
 
-	return State{
-		CodeClass:  codeClass,
-		SizeBucket: sizeBucket,
-		Depth:      depth,
+// stateFromResponse returns the state of a response with the optional dimensions enabled, reduced to the
+// granularity preset. Must be called with the mutex held.
+func (mip *MarkovInputProvider) stateFromResponse(resp *Response) State {
+	if resp.Error != "" {
+		return GetStateFromResponseFromResponseStruct(resp, mip.depth).WithGranularity(mip.granularity)
+	}
+	state := StateFromFFUF(resp.StatusCode, resp.ContentLength, resp.ContentWords, resp.ContentLines, resp.ContentType, resp.Headers, resp.URL)
+	if resp.URL == "" {
+		state.Depth = mip.depth
+	}
+	state.SizeBucket = mip.MarkovChain.Buckets.Bucket(resp.ContentLength)
+	// A login page reached through a redirect looks like a direct 200, the class of the first redirect tells them apart
+	if resp.RedirectStatus != 0 {
+		state.CodeClass = codeClass(resp.RedirectStatus)
+	}
+	fine := mip.granularity == GranularityFine
+	if mip.protocolState || fine {
+		state.Proto = resp.Proto
+	}
+	if !mip.headerState && !fine {
+		state.Headers = ""
 	}
+	if !mip.cookieState && !fine {
+		state.Cookie = ""
+	}
+	if !fine {
+		state.Validator = ""
+	}
+	return state.WithGranularity(mip.granularity)
 }
 
-// CalculateRewardFromResponseStruct determines the reward based on our Response struct
-func CalculateRewardFromResponseStruct(resp *Response, baselineState State, baselineSizeHash string) float64 {
-	// Base reward is 0
+// newCookieReward returns the cookie reward bonus if the response sets a cookie not seen before under the request path.
+// Must be called with the mutex held.
+func (mip *MarkovInputProvider) newCookieReward(resp *Response) float64 {
+	if mip.cookieReward == 0 {
+		return 0
+	}
 	reward := 0.0
+	for _, name := range setCookieNames(resp.Headers) {
+		key := resp.Path + " " + name
+		if !mip.seenCookies[key] {
+			mip.seenCookies[key] = true
+			reward = mip.cookieReward
+		}
+	}
+	return reward
+}
 
-	// If not a 404, increase reward
-	if resp.StatusCode < 400 || resp.StatusCode >= 500 || resp.StatusCode == 401 || resp.StatusCode == 403 {
-		// Non-404 responses get a base reward boost
-		reward += 1.0
-	}
-
-	// If it's a 404, check if it's different from baseline
-	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-		currentState := GetStateFromResponseFromResponseStruct(resp, baselineState.Depth)
-		
-		// If this 404 has different characteristics than baseline, it might still be useful
-		if currentState.Hash() != baselineState.Hash() {
-			// Different size or content than baseline - potential for discovering something new
-			currentSizeHash := GetSizeHash(resp.Data)
-			if currentSizeHash != baselineSizeHash {
-				reward += 0.5 // Somewhat interesting if content differs
+// headerCount returns the total number of header lines of the response
+func headerCount(headers map[string][]string) int {
+	count := 0
+	for _, values := range headers {
+		count += len(values)
+	}
+	return count
+}
+
+// setCookieNames returns the names of the cookies set by the response
+func setCookieNames(headers map[string][]string) []string {
+	names := make([]string, 0)
+	for header, values := range headers {
+		if !strings.EqualFold(header, "Set-Cookie") {
+			continue
+		}
+		for _, v := range values {
+			name := strings.TrimSpace(strings.SplitN(v, "=", 2)[0])
+			if name != "" {
+				names = append(names, name)
 			}
 		}
-	} else {
-		// If we got a non-404 response, this is generally good
-		reward += 1.0
 	}
+	return names
+}
 
-	// Prioritize success responses (2xx) and access forbidden (401, 403) over other status codes
-	switch {
-	case resp.StatusCode >= 200 && resp.StatusCode < 300:
-		reward += 1.0 // Success responses are very valuable
-	case resp.StatusCode == 401 || resp.StatusCode == 403:
-		reward += 0.8 // Access forbidden suggests protected resource exists
-	case resp.StatusCode == 404:
-		// 404 gets no additional reward, but may have gotten some above if different from baseline
-	case resp.StatusCode >= 500 && resp.StatusCode < 600:
-		reward += 0.6 // Server errors might indicate something interesting
+// actionFromInputs returns the fuzzed value used as the action, typically the FUZZ keyword, along with
+// its injection location if known. When the HTTP method is fuzzed with another keyword, the method is
+// part of the action, and the method alone is the action if there is no FUZZ keyword.
+func (mip *MarkovInputProvider) actionFromInputs(inputs map[string][]byte) Action {
+	methodKeyword := ""
+	for kw, location := range mip.keywordLocations {
+		if location == "method" {
+			methodKeyword = kw
+		}
+	}
+	// Look for FUZZ keyword which is standard in ffuf
+	if value, ok := inputs["FUZZ"]; ok {
+		action := Action{Token: mip.actionToken(value), Location: mip.keywordLocations["FUZZ"]}
+		if methodKeyword != "" && methodKeyword != "FUZZ" {
+			action.Method = string(inputs[methodKeyword])
+		}
+		return action
+	}
+	if value, ok := inputs[methodKeyword]; ok && methodKeyword != "" {
+		return Action{Token: mip.actionToken(value), Location: "method"}
 	}
+	return Action{}
+}
 
-	return reward
+// actionToken returns the token of an action from the value of a keyword, normalized when SetTokenHygiene enabled it
+func (mip *MarkovInputProvider) actionToken(value []byte) string {
+	if atomic.LoadInt32(&mip.normalizeTokens) == 1 {
+		return NormalizeToken(string(value))
+	}
+	return string(value)
+}
+
+// GetStateFromResponseFromResponseStruct creates a state representation from our Response struct
+func GetStateFromResponseFromResponseStruct(resp *Response, depth int) State {
+	// Requests failing without a response end up in a terminal state of their own
+	if resp.Error != "" {
+		return State{
+			CodeClass:  resp.Error,
+			SizeBucket: quantizeSize(0),
+			Depth:      depth,
+		}
+	}
+
+	return State{
+		CodeClass:  codeClass(resp.StatusCode),
+		SizeBucket: quantizeSize(resp.ContentLength),
+		Depth:      depth,
+	}
 }
 
 // RefreshBatch refreshes the current batch with reordered inputs based on Markov predictions
@@ -187,17 +528,69 @@ func (mip *MarkovInputProvider) RefreshBatch() {
 	mip.mutex.Lock()
 	defer mip.mutex.Unlock()
 
-	// For now we'll just reset the original provider
-	// Full implementation would require access to re-order the original wordlist
-	// which would need deeper integration with the wordlist provider
-	
-	// Reset original provider to start fresh
-	mip.OriginalProvider.Reset()
+	mip.refreshBatch()
+}
+
+// refreshBatch refills the current batch with the next inputs of the original provider, reordered by the chain. The
+// inputs of the current batch not sent yet are kept, ahead of the new ones. In the learn phase the batch keeps the
+// wordlist order, and the order of the exploit phase is not changed. Must be called with the mutex held.
+func (mip *MarkovInputProvider) refreshBatch() {
+	if mip.phase == PhaseExploit {
+		return
+	}
+	size := mip.batchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	if mip.OriginalProvider != nil {
+		// A wordlist smaller than the batch fits in a single batch
+		if total := mip.OriginalProvider.Total(); total > 0 && total < size {
+			size = total
+		}
+	}
+	pending := 0
+	if mip.currentIndex < len(mip.currentBatch) {
+		pending = len(mip.currentBatch) - mip.currentIndex
+	}
+	batch := make([]map[string][]byte, 0, size+pending)
+	positions := make([]int, 0, size+pending)
+	decisions := make([]string, 0, size+pending)
+	if pending > 0 {
+		batch = append(batch, mip.currentBatch[mip.currentIndex:]...)
+		positions = append(positions, mip.batchPositions[mip.currentIndex:]...)
+		for i := 0; i < pending; i++ {
+			decisions = append(decisions, "wordlist order")
+		}
+	}
 	mip.currentIndex = 0
-	mip.currentBatch = make([]map[string][]byte, 0)
+	mip.currentBatch = batch
+	mip.batchPositions = positions
+	mip.batchDecisions = decisions
+
+	if mip.phase == PhaseLearn {
+		learn := mip.learnInputs()
+		if mip.read >= learn {
+			mip.startExploitPhase()
+			return
+		}
+		if pending+learn-mip.read < size {
+			size = pending + learn - mip.read
+		}
+		mip.fillBatch(size)
+		return
+	}
+	mip.fillBatch(size)
+	mip.reorderBatch()
+}
 
-	// Fill the current batch with inputs
-	for len(mip.currentBatch) < mip.batchSize && mip.OriginalProvider.Next() {
+// fillBatch fills the current batch with the inputs of the original provider up to size inputs, or with all of them
+// for a negative size. The original provider is not asked again once exhausted. Must be called with the mutex held.
+func (mip *MarkovInputProvider) fillBatch(size int) {
+	for mip.OriginalProvider != nil && !mip.exhausted && (size < 0 || len(mip.currentBatch) < size) {
+		if !mip.OriginalProvider.Next() {
+			mip.exhausted = true
+			break
+		}
 		inputs := make(map[string][]byte)
 		originalInputs := mip.OriginalProvider.Value()
 		for k, v := range originalInputs {
@@ -205,13 +598,138 @@ func (mip *MarkovInputProvider) RefreshBatch() {
 			copy(inputs[k], v)
 		}
 		mip.currentBatch = append(mip.currentBatch, inputs)
+		mip.batchPositions = append(mip.batchPositions, mip.OriginalProvider.Position())
+		mip.batchDecisions = append(mip.batchDecisions, "wordlist order")
+		mip.read++
+	}
+}
+
+// SetColdStateBudget sets the number of inputs sent in wordlist order when the chain ranks the inputs in a state it
+// has never seen, for the statistics of the state to be established before exploiting them. 0 disables it.
+func (mip *MarkovInputProvider) SetColdStateBudget(n int) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	if n < 0 {
+		n = 0
 	}
+	mip.coldBudget = n
+}
 
-	// Reset position again to start fresh
-	mip.OriginalProvider.Reset()
+// coldInputs returns the number of inputs at the head of a batch of n to send in wordlist order, the state the
+// inputs are ranked in being explored. A state gets the whole cold state budget the first time it is ranked in,
+// if the chain has never seen it. Must be called with the mutex held.
+func (mip *MarkovInputProvider) coldInputs(n int) int {
+	if mip.coldBudget == 0 {
+		return 0
+	}
+	key := mip.baselineState.Hash()
+	if mip.coldRemaining == nil {
+		mip.coldRemaining = make(map[string]int)
+	}
+	remaining, seen := mip.coldRemaining[key]
+	if !seen {
+		remaining = 0
+		if !mip.MarkovChain.HasState(mip.baselineState) {
+			remaining = mip.coldBudget
+		}
+	}
+	if remaining > n {
+		remaining, mip.coldRemaining[key] = n, remaining-n
+	} else {
+		mip.coldRemaining[key] = 0
+	}
+	return remaining
 }
 
-// Next moves to the next input in the current batch or gets a new batch based on Markov predictions
+// reorderBatch moves the inputs the chain expects a positive reward from to the head of the batch, best first
+// and up to topActions of them, the other inputs keeping their original order but the ones of another stack than the
+// target's, see SetStackPreference. The inputs sent while a never seen state is explored keep their place at the head
+// of the batch. Must be called with the mutex held.
+func (mip *MarkovInputProvider) reorderBatch() {
+	state := mip.baselineState.Hash()
+	cold := mip.coldInputs(len(mip.currentBatch))
+	for i := 0; i < cold; i++ {
+		mip.batchDecisions[i] = fmt.Sprintf("%s %s, wordlist order", ColdStateDecision, state)
+	}
+	n := mip.topActions
+	if n == 0 || n > len(mip.currentBatch)-cold {
+		n = len(mip.currentBatch) - cold
+	}
+	ranked := mip.rankBatch(cold, n)
+	mip.deprioritizeStacks(cold + ranked)
+}
+
+// rankBatch moves up to n inputs the chain expects a positive reward from to the head of the batch, after its first
+// cold inputs, best first, and returns their number. Must be called with the mutex held.
+func (mip *MarkovInputProvider) rankBatch(cold int, n int) int {
+	state := mip.baselineState.Hash()
+	if n == 0 {
+		return 0
+	}
+	actions := make([]Action, 0, len(mip.currentBatch))
+	indexes := make(map[string][]int)
+	for i, inputs := range mip.currentBatch {
+		if i < cold {
+			continue
+		}
+		action := mip.actionFromInputs(inputs)
+		if _, seen := indexes[action.Key()]; !seen {
+			actions = append(actions, action)
+		}
+		indexes[action.Key()] = append(indexes[action.Key()], i)
+	}
+	ranked := mip.MarkovChain.RankActions(mip.baselineState, actions, n)
+	if len(ranked) == 0 {
+		return 0
+	}
+
+	batch := append(make([]map[string][]byte, 0, len(mip.currentBatch)), mip.currentBatch[:cold]...)
+	positions := append(make([]int, 0, len(mip.currentBatch)), mip.batchPositions[:cold]...)
+	decisions := append(make([]string, 0, len(mip.currentBatch)), mip.batchDecisions[:cold]...)
+	head := make(map[int]bool)
+	for _, score := range ranked {
+		decision := fmt.Sprintf("token features, q=%.2f, state %s", score.Value, state)
+		if score.Exact {
+			decision = fmt.Sprintf("replay of %s, q=%.2f, state %s", score.Action, score.Value, state)
+		}
+		for _, i := range indexes[score.Action] {
+			if len(head) == n {
+				break
+			}
+			head[i] = true
+			batch = append(batch, mip.currentBatch[i])
+			positions = append(positions, mip.batchPositions[i])
+			decisions = append(decisions, decision)
+		}
+	}
+	for i := cold; i < len(mip.currentBatch); i++ {
+		if !head[i] {
+			batch = append(batch, mip.currentBatch[i])
+			positions = append(positions, mip.batchPositions[i])
+			decisions = append(decisions, mip.batchDecisions[i])
+		}
+	}
+	mip.currentBatch = batch
+	mip.batchPositions = positions
+	mip.batchDecisions = decisions
+	return len(head)
+}
+
+// SetTopActions sets the number of chain ranked inputs placed at the head of each batch, 0 reordering the whole
+// batch. It is capped to the batch size.
+func (mip *MarkovInputProvider) SetTopActions(n int) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	if n < 0 || n > mip.batchSize {
+		n = 0
+	}
+	mip.topActions = n
+}
+
+// Next moves to the next input in the current batch or gets a new batch based on Markov predictions. Each input of
+// the original provider is returned once, and Next returns false right away for an empty provider.
 func (mip *MarkovInputProvider) Next() bool {
 	mip.mutex.Lock()
 	defer mip.mutex.Unlock()
@@ -221,24 +739,16 @@ func (mip *MarkovInputProvider) Next() bool {
 		mip.currentIndex++
 		return true
 	}
-
-	// We need a new batch - first reset the index
-	mip.currentIndex = 0
+	if mip.OriginalProvider == nil || mip.exhausted || mip.OriginalProvider.Total() == 0 {
+		return false
+	}
 
 	// Refresh batch with Markov-driven reordering
-	mip.RefreshBatch()
-
-	// Check if we have items in the new batch
+	mip.refreshBatch()
 	if len(mip.currentBatch) > 0 {
 		mip.currentIndex = 1 // Start at 1 since we return true and will call Value() next
 		return true
 	}
-
-	// Fallback to original provider's Next method if batch refresh failed
-	if mip.OriginalProvider != nil {
-		return mip.OriginalProvider.Next()
-	}
-
 	return false
 }
 
@@ -247,40 +757,62 @@ func (mip *MarkovInputProvider) Value() map[string][]byte {
 	mip.mutex.Lock()
 	defer mip.mutex.Unlock()
 
+	// The inputs are kept by reference only, PreviousInputs copies them if they need to be retained
 	if mip.currentIndex > 0 && mip.currentIndex <= len(mip.currentBatch) {
-		// Store the inputs for potential next transition
-		inputs := mip.currentBatch[mip.currentIndex-1]
-		for k, v := range inputs {
-			mip.previousInputs[k] = make([]byte, len(v))
-			copy(mip.previousInputs[k], v)
-		}
-		return inputs
+		mip.previousInputs = mip.currentBatch[mip.currentIndex-1]
+		return mip.previousInputs
 	}
 
-	// Fallback to original provider if no batch is available
+	// Fallback to original provider if no batch is available, it returns a new map for each call
 	if mip.OriginalProvider != nil {
-		originalInputs := mip.OriginalProvider.Value()
-		result := make(map[string][]byte)
-		for k, v := range originalInputs {
-			result[k] = make([]byte, len(v))
-			copy(result[k], v)
-		}
-		return result
+		mip.previousInputs = mip.OriginalProvider.Value()
+		return mip.previousInputs
 	}
 
 	// Return empty map as fallback
 	return make(map[string][]byte)
 }
 
-// Position returns the current position
+// PreviousInputs returns a copy of the inputs returned by the last Value call, safe to retain
+// even if the caller modifies the values it was given
+func (mip *MarkovInputProvider) PreviousInputs() map[string][]byte {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	result := make(map[string][]byte, len(mip.previousInputs))
+	for k, v := range mip.previousInputs {
+		result[k] = make([]byte, len(v))
+		copy(result[k], v)
+	}
+	return result
+}
+
+// Position returns the position of the current input in the original provider
 func (mip *MarkovInputProvider) Position() int {
-	// We'll return the original provider's position to maintain compatibility
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	if mip.currentIndex > 0 && mip.currentIndex <= len(mip.batchPositions) {
+		return mip.batchPositions[mip.currentIndex-1]
+	}
 	if mip.OriginalProvider != nil {
 		return mip.OriginalProvider.Position()
 	}
 	return 0
 }
 
+// Decision returns why the current input was sent at its place: ranked by the chain, with the expected reward and
+// the state it was ranked in, or in the order of the original provider. Empty if there is no current input.
+func (mip *MarkovInputProvider) Decision() string {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	if mip.currentIndex > 0 && mip.currentIndex <= len(mip.batchDecisions) {
+		return mip.batchDecisions[mip.currentIndex-1]
+	}
+	return ""
+}
+
 // SetPosition sets the position
 func (mip *MarkovInputProvider) SetPosition(pos int) {
 	mip.mutex.Lock()
@@ -291,6 +823,11 @@ func (mip *MarkovInputProvider) SetPosition(pos int) {
 	}
 	mip.currentIndex = 0
 	mip.currentBatch = make([]map[string][]byte, 0)
+	mip.batchPositions = nil
+	mip.batchDecisions = nil
+	mip.exhausted = false
+	mip.read = pos
+	mip.startPhases()
 }
 
 // Keywords returns the keywords
@@ -318,13 +855,22 @@ func (mip *MarkovInputProvider) Reset() {
 	}
 	mip.currentIndex = 0
 	mip.currentBatch = make([]map[string][]byte, 0)
-	mip.previousInputs = make(map[string][]byte)
+	mip.batchPositions = nil
+	mip.batchDecisions = nil
+	mip.exhausted = false
+	mip.dropped = 0
+	mip.previousInputs = nil
+	mip.read = 0
+	mip.startPhases()
 }
 
-// Total returns total number of inputs
+// Total returns total number of inputs, leaving out the inputs dropped from the batches
 func (mip *MarkovInputProvider) Total() int {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
 	if mip.OriginalProvider != nil {
-		return mip.OriginalProvider.Total()
+		return mip.OriginalProvider.Total() - mip.dropped
 	}
 	return 0
 }
\ No newline at end of file
@@ -0,0 +1,537 @@
+package markov
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// sliceProvider is a minimal InputProvider serving the FUZZ keyword from a slice of words
+type sliceProvider struct {
+	words    []string
+	position int
+}
+
+func newSliceProvider(words ...string) *sliceProvider {
+	return &sliceProvider{words: words}
+}
+
+func (s *sliceProvider) Next() bool {
+	if s.position >= len(s.words) {
+		return false
+	}
+	s.position++
+	return true
+}
+
+func (s *sliceProvider) Value() map[string][]byte {
+	return map[string][]byte{"FUZZ": []byte(s.words[s.position-1])}
+}
+
+func (s *sliceProvider) Position() int             { return s.position }
+func (s *sliceProvider) SetPosition(pos int)       { s.position = pos }
+func (s *sliceProvider) Keywords() []string        { return []string{"FUZZ"} }
+func (s *sliceProvider) ActivateKeywords([]string) {}
+func (s *sliceProvider) Reset()                    { s.position = 0 }
+func (s *sliceProvider) Total() int                { return len(s.words) }
+
+func newTestProvider(words ...string) *MarkovInputProvider {
+	baseline := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139), Depth: 0}
+	return NewMarkovInputProvider(newSliceProvider(words...), baseline, GetSizeHash([]byte("404 not found")), 0)
+}
+
+// nextStates returns the hashes of all the states observed after taking the action from the baseline
+func nextStates(mip *MarkovInputProvider, action string) []string {
+	states := make([]string, 0)
+	for next := range mip.MarkovChain.TransitionCounts[mip.baselineState.Hash()][action] {
+		states = append(states, next)
+	}
+	return states
+}
+
+func TestUpdateWithResponseProtocolState(t *testing.T) {
+	resp := &Response{StatusCode: 200, ContentLength: 2000, Proto: "HTTP/2.0"}
+
+	mip := newTestProvider("admin")
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, resp)
+	for _, s := range nextStates(mip, "admin") {
+		if strings.Contains(s, "HTTP/2.0") {
+			t.Errorf("Protocol should not be part of the state unless enabled: %s", s)
+		}
+	}
+
+	mip = newTestProvider("admin")
+	mip.SetProtocolState(true)
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, resp)
+	states := nextStates(mip, "admin")
+	if len(states) != 1 || states[0] != "2xx_2000_0_HTTP/2.0" {
+		t.Errorf("Expected the state to be tagged with the protocol, got %v", states)
+	}
+
+	// The same response over HTTP/1.1 lands in a different state
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, &Response{StatusCode: 200, ContentLength: 2000, Proto: "HTTP/1.1"})
+	if len(nextStates(mip, "admin")) != 2 {
+		t.Errorf("Expected protocol dependent states, got %v", nextStates(mip, "admin"))
+	}
+}
+
+func TestUpdateWithResponseErrorStates(t *testing.T) {
+	mip := newTestProvider("hang", "reset")
+	mip.SetErrorRewards(0.3, 0.0)
+
+	reward := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("hang")}, &Response{Error: CodeClassTimeout})
+	if reward != 0.3 {
+		t.Errorf("Expected the configured timeout reward, got %f", reward)
+	}
+	states := nextStates(mip, "hang")
+	if len(states) != 1 || states[0] != "timeout_0_0" {
+		t.Errorf("Expected a transition to the timeout state, got %v", states)
+	}
+
+	reward = mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("reset")}, &Response{Error: CodeClassConnError})
+	if reward != 0.0 {
+		t.Errorf("Expected the configured connection error reward, got %f", reward)
+	}
+	states = nextStates(mip, "reset")
+	if len(states) != 1 || states[0] != "conn-error_0_0" {
+		t.Errorf("Expected a transition to the connection error state, got %v", states)
+	}
+	if q := mip.MarkovChain.GetExpectedReward(mip.baselineState, "reset"); q != 0.0 {
+		t.Errorf("Connection error should not reward the input, got %f", q)
+	}
+
+	// The connection errors of a known class get the connection error reward in a state of their own
+	reward = mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("reset")}, &Response{Error: ConnErrorClass("dns")})
+	if reward != 0.0 {
+		t.Errorf("Expected the configured connection error reward for a DNS failure, got %f", reward)
+	}
+	if counts := mip.MarkovChain.TransitionCounts[mip.baselineState.Hash()]["reset"]; counts["conn-error-dns_0_0"] != 1 {
+		t.Errorf("Expected a transition to the DNS failure state, got %v", counts)
+	}
+	if summary := mip.MarkovChain.Summary(); !strings.Contains(summary, "connection errors: 2 [dns: 1]") {
+		t.Errorf("Expected the connection errors by class in the summary, got %s", summary)
+	}
+	if q := mip.MarkovChain.GetExpectedReward(mip.baselineState, "hang"); q <= 0.0 {
+		t.Errorf("Timeout should reward the input, got %f", q)
+	}
+}
+
+func TestErrorStatesIgnoreProtocol(t *testing.T) {
+	mip := newTestProvider("hang")
+	mip.SetProtocolState(true)
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("hang")}, &Response{Error: CodeClassTimeout})
+	states := nextStates(mip, "hang")
+	if len(states) != 1 || states[0] != "timeout_0_0" {
+		t.Errorf("Error states should not carry a protocol, got %v", states)
+	}
+}
+
+func TestCertMismatchReward(t *testing.T) {
+	baseline := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139), Depth: 0}
+	baselineHash := GetSizeHash([]byte("404 not found"))
+
+	resp := &Response{StatusCode: 200, ContentLength: 2000}
+	plain := CalculateRewardFromResponseStruct(resp, baseline, baselineHash)
+	resp.CertMismatch = true
+	if r := CalculateRewardFromResponseStruct(resp, baseline, baselineHash); r != plain+0.5 {
+		t.Errorf("Expected certificate mismatch to add to the reward, got %f (without %f)", r, plain)
+	}
+
+	// Baseline responses are not rewarded even when the certificate does not match
+	resp = &Response{StatusCode: 404, ContentLength: 150, Data: []byte("404 not found"), CertMismatch: true}
+	if r := CalculateRewardFromResponseStruct(resp, baseline, baselineHash); r != 0.0 {
+		t.Errorf("Baseline response should not be rewarded, got %f", r)
+	}
+}
+
+func TestUpdateWithResponseLocation(t *testing.T) {
+	resp := &Response{StatusCode: 200, ContentLength: 2000}
+
+	mip := newTestProvider("admin")
+	mip.SetKeywordLocations(map[string]string{"FUZZ": "header:Cookie"})
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, resp)
+	if len(nextStates(mip, "header:Cookie:admin")) != 1 {
+		t.Errorf("Expected the action key to include the location, got %v", mip.MarkovChain.ActionCounts)
+	}
+	if len(nextStates(mip, "admin")) != 0 {
+		t.Errorf("Token injected in a header should not be attributed to the bare token")
+	}
+}
+
+func TestActionKey(t *testing.T) {
+	if k := (Action{Token: "admin"}).Key(); k != "admin" {
+		t.Errorf("Action without location should be keyed by the token, got %s", k)
+	}
+	if k := (Action{Token: "admin", Location: "path"}).Key(); k == (Action{Token: "admin", Location: "body"}).Key() {
+		t.Errorf("Same token in different locations should have different keys: %s", k)
+	}
+}
+
+// headers returns a header map with n X-Header-<i> headers, and a Set-Cookie header for each cookie
+func headers(n int, cookies ...string) map[string][]string {
+	h := make(map[string][]string)
+	for i := 0; i < n; i++ {
+		h[fmt.Sprintf("X-Header-%d", i)] = []string{"value"}
+	}
+	for _, c := range cookies {
+		h["Set-Cookie"] = append(h["Set-Cookie"], c+"=value; Path=/; HttpOnly")
+	}
+	return h
+}
+
+func TestUpdateWithResponseHeaderState(t *testing.T) {
+	mip := newTestProvider("admin")
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, &Response{StatusCode: 200, ContentLength: 2000, Headers: headers(20, "session")})
+	if states := nextStates(mip, "admin"); len(states) != 1 || states[0] != "2xx_2000_0" {
+		t.Errorf("Headers should not be part of the state unless enabled, got %v", states)
+	}
+
+	tests := []struct {
+		headers map[string][]string
+		state   string
+	}{
+		{headers(2), "2xx_2000_0_h0-5_nocookie"},
+		{headers(5, "session"), "2xx_2000_0_h6-15_cookie"},
+		{headers(10), "2xx_2000_0_h6-15_nocookie"},
+		{headers(16, "session", "csrf"), "2xx_2000_0_h16+_cookie"},
+		{nil, "2xx_2000_0_h0-5_nocookie"},
+	}
+	for _, test := range tests {
+		mip = newTestProvider("admin")
+		mip.SetHeaderState(true, true)
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, &Response{StatusCode: 200, ContentLength: 2000, Headers: test.headers})
+		if states := nextStates(mip, "admin"); len(states) != 1 || states[0] != test.state {
+			t.Errorf("Expected state %s, got %v", test.state, states)
+		}
+	}
+
+	// Either of the dimensions can be enabled alone
+	mip = newTestProvider("admin")
+	mip.SetHeaderState(false, true)
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, &Response{StatusCode: 200, ContentLength: 2000, Headers: headers(20, "session")})
+	if states := nextStates(mip, "admin"); len(states) != 1 || states[0] != "2xx_2000_0_cookie" {
+		t.Errorf("Expected only the cookie dimension in the state, got %v", states)
+	}
+
+	// Error states carry neither of the dimensions
+	mip = newTestProvider("hang")
+	mip.SetHeaderState(true, true)
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("hang")}, &Response{Error: CodeClassTimeout})
+	if states := nextStates(mip, "hang"); len(states) != 1 || states[0] != "timeout_0_0" {
+		t.Errorf("Error states should not carry header dimensions, got %v", states)
+	}
+}
+
+func TestCookieReward(t *testing.T) {
+	mip := newTestProvider("login", "logout", "other")
+	plain := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("login")}, &Response{StatusCode: 200, ContentLength: 2000, Headers: headers(3, "session"), Path: "example.com/app"})
+
+	mip = newTestProvider("login", "logout", "other")
+	mip.SetCookieReward(0.4)
+	resp := &Response{StatusCode: 200, ContentLength: 2000, Headers: headers(3, "session"), Path: "example.com/app"}
+	if r := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("login")}, resp); r != plain+0.4 {
+		t.Errorf("Expected the first cookie under the path to be rewarded, got %f (without %f)", r, plain)
+	}
+	if r := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("logout")}, resp); r != plain {
+		t.Errorf("Cookie already seen under the path should not be rewarded again, got %f", r)
+	}
+	resp = &Response{StatusCode: 200, ContentLength: 2000, Headers: headers(3, "session"), Path: "example.com/admin"}
+	if r := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("other")}, resp); r != plain+0.4 {
+		t.Errorf("Expected the cookie under a new path to be rewarded, got %f", r)
+	}
+	resp = &Response{StatusCode: 200, ContentLength: 2000, Headers: headers(3), Path: "example.com/other"}
+	if r := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("other")}, resp); r != plain {
+		t.Errorf("Response without cookies should not be rewarded, got %f", r)
+	}
+}
+
+func TestRedirectChain(t *testing.T) {
+	mip := newTestProvider("admin", "login", "sso", "far")
+	direct := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, &Response{StatusCode: 200, ContentLength: 2000})
+	mip.SetRedirectReward(-1.0)
+
+	twoHops := &Response{StatusCode: 200, ContentLength: 2000, RedirectStatus: 302, Redirects: 2, InternalRedirects: true}
+	if r := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("login")}, twoHops); r != direct-1.0 {
+		t.Errorf("Expected a short internal redirect chain to get the redirect reward, got %f (direct %f)", r, direct)
+	}
+	threeHops := &Response{StatusCode: 200, ContentLength: 2000, RedirectStatus: 302, Redirects: 3, InternalRedirects: true}
+	if r := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("sso")}, threeHops); r != direct {
+		t.Errorf("Expected a longer chain to be rewarded as a direct response, got %f", r)
+	}
+	external := &Response{StatusCode: 200, ContentLength: 2000, RedirectStatus: 302, Redirects: 1}
+	if r := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("far")}, external); r != direct {
+		t.Errorf("Expected a redirect to another host to be rewarded as a direct response, got %f", r)
+	}
+
+	// The state takes the class of the first redirect, telling the login page apart from a direct 200
+	if states := nextStates(mip, "admin"); len(states) != 1 || !strings.HasPrefix(states[0], "2xx") {
+		t.Errorf("Expected the direct response to end up in a 2xx state, got %v", states)
+	}
+	if states := nextStates(mip, "login"); len(states) != 1 || !strings.HasPrefix(states[0], "3xx") {
+		t.Errorf("Expected the redirected response to end up in the state of its first redirect, got %v", states)
+	}
+}
+
+func TestValueFromBatch(t *testing.T) {
+	mip := newTestProvider("admin", "login")
+	for _, expected := range []string{"admin", "login"} {
+		if !mip.Next() {
+			t.Fatalf("Expected an input for %s", expected)
+		}
+		if v := string(mip.Value()["FUZZ"]); v != expected {
+			t.Errorf("Expected %s, got %s", expected, v)
+		}
+	}
+}
+
+func TestPreviousInputsNotAliased(t *testing.T) {
+	mip := newTestProvider("admin", "login")
+	mip.Next()
+	value := mip.Value()
+	retained := mip.PreviousInputs()
+
+	// Mutating the value handed to the caller must not affect the retained copy
+	value["FUZZ"][0] = 'X'
+	value["FFUFHASH"] = []byte("hash")
+	if string(retained["FUZZ"]) != "admin" {
+		t.Errorf("Retained input was aliased to the mutated buffer: %s", retained["FUZZ"])
+	}
+	if _, ok := retained["FFUFHASH"]; ok {
+		t.Errorf("Retained inputs should not see keys added afterwards")
+	}
+
+	mip.Reset()
+	if len(mip.PreviousInputs()) != 0 {
+		t.Errorf("Reset should clear the previous inputs")
+	}
+}
+
+func TestStateGranularity(t *testing.T) {
+	responses := []*Response{
+		{StatusCode: 200, ContentLength: 2000, Proto: "HTTP/1.1"},
+		{StatusCode: 200, ContentLength: 5000, Proto: "HTTP/1.1"},
+		{StatusCode: 200, ContentLength: 5000, Proto: "HTTP/2.0", Headers: map[string][]string{"Set-Cookie": {"session=1"}}},
+		{StatusCode: 404, ContentLength: 139, Proto: "HTTP/1.1"},
+		{StatusCode: 404, ContentLength: 1200, Proto: "HTTP/1.1"},
+		{Error: CodeClassTimeout},
+	}
+	previous := 0
+	for _, g := range []StateGranularity{GranularityCoarse, GranularityDefault, GranularityFine} {
+		mip := newTestProvider()
+		mip.SetGranularity(g)
+		for i, resp := range responses {
+			mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(fmt.Sprintf("word%d", i))}, resp)
+		}
+		// The transitions all start from the baseline, the response stream shows up in the destination states
+		states := make(map[string]bool)
+		for from, actions := range mip.MarkovChain.TransitionCounts {
+			for _, next := range actions {
+				for to := range next {
+					states[to] = true
+				}
+			}
+			if from != mip.baselineState.WithGranularity(g).Hash() {
+				t.Errorf("%s: unexpected from state %s", g, from)
+			}
+		}
+		if len(states) <= previous {
+			t.Errorf("%s: expected more states than the coarser granularity, got %d after %d", g, len(states), previous)
+		}
+		previous = len(states)
+		if mip.MarkovChain.Granularity != g {
+			t.Errorf("%s: expected the granularity to be recorded in the chain, got %s", g, mip.MarkovChain.Granularity)
+		}
+	}
+}
+
+func TestParseStateGranularity(t *testing.T) {
+	if g, err := ParseStateGranularity("fine"); err != nil || g != GranularityFine {
+		t.Errorf("Unexpected result parsing a valid granularity: %s %v", g, err)
+	}
+	if _, err := ParseStateGranularity("extra-fine"); err == nil {
+		t.Errorf("Expected an error for an unknown granularity")
+	}
+}
+
+func TestBatchTopActions(t *testing.T) {
+	words := []string{"a1", "b2", "c3", "d4", "e5", "f6", "g7", "h8", "i9", "j10"}
+	// Three words the chain expects a reward from, best first: e5, j10, h8, and one it expects nothing from
+	values := map[string]float64{"h8": 1, "e5": 3, "j10": 2, "b2": -1}
+	ranked := []string{"e5", "j10", "h8"}
+
+	for _, n := range []int{0, 1, 2, 3, 5} {
+		mip := newTestProvider(words...)
+		key := mip.baselineState.Hash()
+		mip.MarkovChain.QTable[key] = make(map[string]float64)
+		for word, q := range values {
+			mip.MarkovChain.setQ(key, word, q)
+		}
+		mip.SetTopActions(n)
+
+		batch := make([]string, 0)
+		positions := make([]int, 0)
+		for mip.Next() {
+			batch = append(batch, string(mip.Value()["FUZZ"]))
+			positions = append(positions, mip.Position())
+		}
+		if len(batch) != len(words) {
+			t.Fatalf("Expected the %d words in the batch with top %d, got %v", len(words), n, batch)
+		}
+		head := len(ranked)
+		if n > 0 && n < head {
+			head = n
+		}
+		for i := 0; i < head; i++ {
+			if batch[i] != ranked[i] {
+				t.Errorf("Expected %s ranked at %d with top %d, got %v", ranked[i], i, n, batch)
+			}
+		}
+		// The rest of the batch keeps the wordlist order
+		next := 0
+		for _, word := range batch[head:] {
+			for words[next] != word {
+				next++
+				if next == len(words) {
+					t.Fatalf("Expected the rest of the batch in the wordlist order with top %d, got %v", n, batch)
+				}
+			}
+		}
+		for i, word := range batch {
+			if words[positions[i]-1] != word {
+				t.Errorf("Expected the position of %s in the wordlist, got %d", word, positions[i])
+			}
+		}
+	}
+}
+
+func TestColdStateBudget(t *testing.T) {
+	words := make([]string, 0, 250)
+	for i := 0; i < 250; i++ {
+		words = append(words, fmt.Sprintf("w%d", i))
+	}
+	mip := newTestProvider(words...)
+	mip.SetColdStateBudget(120)
+	key := mip.baselineState.Hash()
+	mip.MarkovChain.QTable[key] = make(map[string]float64)
+	mip.MarkovChain.setQ(key, "w50", 1)
+
+	// policies counts the inputs drawn under each policy, calling sent after each of them
+	policies := func(sent func(int)) (map[string]int, []string) {
+		counts := make(map[string]int)
+		order := make([]string, 0)
+		for i := 0; mip.Next(); i++ {
+			order = append(order, string(mip.Value()["FUZZ"]))
+			sent(i)
+			decision := mip.Decision()
+			switch {
+			case strings.HasPrefix(decision, ColdStateDecision):
+				counts["cold"]++
+			case strings.HasPrefix(decision, "replay of"):
+				counts["ranked"]++
+			default:
+				counts["wordlist"]++
+			}
+		}
+		return counts, order
+	}
+
+	// The chain knows the state already, there is no cold start
+	counts, _ := policies(func(int) {})
+	if counts["cold"] != 0 || counts["ranked"] != 1 {
+		t.Errorf("Expected no cold start in a known state, got %v", counts)
+	}
+
+	// Forced state change, the chain learning the new state while the first batch is sent
+	newState := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139), Depth: 1}
+	mip.SetBaseline(newState, GetSizeHash([]byte("404 not found")))
+	mip.Reset()
+	newKey := newState.Hash()
+	counts, order := policies(func(i int) {
+		if i == DefaultBatchSize-1 {
+			mip.MarkovChain.QTable[newKey] = make(map[string]float64)
+			mip.MarkovChain.setQ(newKey, "w50", 1)
+			mip.MarkovChain.setQ(newKey, "w110", 1)
+			mip.MarkovChain.setQ(newKey, "w150", 2)
+		}
+	})
+	if counts["cold"] != 120 || counts["ranked"] != 1 || counts["wordlist"] != 129 {
+		t.Errorf("Expected 120 inputs in the cold state, then the inputs ranked by the chain, got %v", counts)
+	}
+	for i := 0; i < 120; i++ {
+		if order[i] != words[i] {
+			t.Fatalf("Expected the wordlist order in the cold state, got %s at %d", order[i], i)
+		}
+	}
+	if order[120] != "w150" {
+		t.Errorf("Expected the ranked input right after the cold state, got %s", order[120])
+	}
+}
+
+func TestSetTopActionsBounds(t *testing.T) {
+	mip := newTestProvider("admin")
+	mip.SetTopActions(DefaultBatchSize + 1)
+	if mip.topActions != 0 {
+		t.Errorf("Expected a top larger than the batch to reorder the whole batch, got %d", mip.topActions)
+	}
+	mip.SetTopActions(10)
+	if mip.topActions != 10 {
+		t.Errorf("Expected a top of 10, got %d", mip.topActions)
+	}
+}
+
+func TestBatchDecisions(t *testing.T) {
+	mip := newTestProvider("login", "admin")
+	key := mip.baselineState.Hash()
+	mip.MarkovChain.QTable[key] = make(map[string]float64)
+	mip.MarkovChain.setQ(key, "admin", 1.834)
+
+	expected := []string{"replay of admin, q=1.83, state " + key, "wordlist order"}
+	for _, e := range expected {
+		if !mip.Next() {
+			t.Fatalf("Expected an input for %s", e)
+		}
+		if d := mip.Decision(); d != e {
+			t.Errorf("Expected the decision %q, got %q", e, d)
+		}
+	}
+}
+
+func TestProviderEmissionsAroundBatchSize(t *testing.T) {
+	for _, size := range []int{0, 1, DefaultBatchSize - 1, DefaultBatchSize, DefaultBatchSize + 1} {
+		for _, feedback := range []bool{false, true} {
+			words := make([]string, size)
+			for i := range words {
+				words[i] = fmt.Sprintf("word%d", i)
+			}
+			mip := newTestProvider(words...)
+			emitted := make(map[string]int)
+			count := 0
+			for mip.Next() {
+				word := string(mip.Value()["FUZZ"])
+				emitted[word]++
+				count++
+				if count > size {
+					break
+				}
+				if feedback {
+					// Rewards reorder the following batches, and refreshing mid-batch keeps the pending inputs
+					mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(word)}, &Response{StatusCode: 200})
+					if count%7 == 0 {
+						mip.RefreshBatch()
+					}
+				}
+			}
+			if count != size || count != mip.Total() {
+				t.Errorf("%d words, feedback %t: expected %d emissions, got %d", size, feedback, size, count)
+			}
+			for _, word := range words {
+				if emitted[word] != 1 {
+					t.Errorf("%d words, feedback %t: expected %s to be emitted once, got %d", size, feedback, word, emitted[word])
+				}
+			}
+			if mip.Next() {
+				t.Errorf("%d words, feedback %t: expected the provider to stay exhausted", size, feedback)
+			}
+		}
+	}
+}
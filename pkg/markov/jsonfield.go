@@ -0,0 +1,5 @@
+package markov
+
+// JSONValueCandidates are the values sent in addition to the wordlist when the fuzz keyword is the raw value of a
+// field of a JSON request body: numbers around the usual integer and float boundaries, the booleans and null
+var JSONValueCandidates = []string{"0", "1", "-1", "1.5", "2147483647", "-2147483648", "9007199254740993", "1e308", "true", "false", "null"}
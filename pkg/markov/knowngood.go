@@ -0,0 +1,42 @@
+package markov
+
+// KnownGoodReward is the reward of the paths known to exist on the target, the reward of the success tier
+const KnownGoodReward = 3.0
+
+// AddKnownGood teaches the chain the response to a path known to exist on the target, from a previous scan or another
+// tool: a transition from the baseline state with KnownGoodReward, the last path segment of the URL being the action,
+// and the segment recorded as a match for the induction of patterns. A response that failed, was cut off, is a 404 or
+// looks like the baseline teaches nothing, a stale entry of the list would be learned as a success otherwise. Returns
+// the token of the path and whether the response was learned.
+func (mip *MarkovInputProvider) AddKnownGood(rawurl string, resp *Response) (string, bool) {
+	token := historyToken(rawurl)
+	if token == "" || resp.Error != "" || resp.Incomplete || resp.StatusCode == 404 {
+		return token, false
+	}
+	mip.mutex.Lock()
+	state := mip.stateFromResponse(resp)
+	if mip.baselineEquivalent(resp, state) {
+		mip.mutex.Unlock()
+		return token, false
+	}
+	baseline := mip.baselineState.WithGranularity(mip.granularity)
+	location := mip.keywordLocations["FUZZ"]
+	mip.mutex.Unlock()
+
+	mip.AddTransition(baseline, Action{Token: token, Location: location}, state, KnownGoodReward)
+	mip.RecordMatch(token, KnownGoodReward)
+	return token, true
+}
+
+// BiasKnownGood gives the last path segment of a path known to exist on the target the initial Q-value
+// KnownGoodReward from the baseline state, and records it as a match for the induction of patterns, without a
+// response to learn from, for the plans of a dry run. Returns the token of the path, empty if the path has none.
+func (mip *MarkovInputProvider) BiasKnownGood(rawurl string) string {
+	token := historyToken(rawurl)
+	if token == "" {
+		return ""
+	}
+	mip.SeedActions([]string{token}, KnownGoodReward)
+	mip.RecordMatch(token, KnownGoodReward)
+	return token
+}
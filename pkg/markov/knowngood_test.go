@@ -0,0 +1,44 @@
+package markov
+
+import "testing"
+
+func TestAddKnownGood(t *testing.T) {
+	mip := newTestProvider()
+	token, ok := mip.AddKnownGood("https://example.com/api/v1?debug=1", &Response{StatusCode: 200, ContentLength: 3000})
+	if !ok || token != "v1" {
+		t.Fatalf("Expected the last path segment to be learned, got %q, %t", token, ok)
+	}
+	if q := mip.MarkovChain.GetExpectedReward(mip.baselineState, "v1"); q <= 0 {
+		t.Errorf("Expected a positive Q-value for the known path, got %f", q)
+	}
+
+	for _, resp := range []*Response{
+		{StatusCode: 404, ContentLength: 5000},
+		{StatusCode: 410, ContentLength: 139},
+		{Error: CodeClassTimeout},
+		{StatusCode: 200, ContentLength: 3000, Incomplete: true},
+	} {
+		if _, ok := mip.AddKnownGood("https://example.com/gone", resp); ok {
+			t.Errorf("Expected the response %+v to be left out", resp)
+		}
+	}
+	if len(nextStates(mip, "gone")) != 0 || len(mip.MatchedTokens()) != 1 {
+		t.Errorf("Expected the failed paths to teach nothing, got the states %v and the matches %v", nextStates(mip, "gone"), mip.MatchedTokens())
+	}
+}
+
+func TestBiasKnownGood(t *testing.T) {
+	mip := newTestProvider()
+	if token := mip.BiasKnownGood("https://example.com/api/v1"); token != "v1" {
+		t.Fatalf("Expected the last path segment to be biased, got %q", token)
+	}
+	if q := mip.MarkovChain.GetExpectedReward(mip.baselineState, "v1"); q != KnownGoodReward {
+		t.Errorf("Expected the Q-value of the known path to be %f, got %f", KnownGoodReward, q)
+	}
+	if matched := mip.MatchedTokens(); len(matched) != 1 || matched[0] != "v1" {
+		t.Errorf("Expected the known path recorded as a match, got %v", matched)
+	}
+	if token := mip.BiasKnownGood("https://example.com/"); token != "" {
+		t.Errorf("Expected the root to have no token, got %q", token)
+	}
+}
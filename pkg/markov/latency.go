@@ -0,0 +1,187 @@
+package markov
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// latencyActionLimit is the number of actions the latency is tracked for, the actions first seen past it being
+	// left out to bound the memory
+	latencyActionLimit = 1 << 16
+	// SlowActionMinObservations is the number of responses an action needs before being reported by SlowestActions
+	SlowActionMinObservations = 3
+)
+
+// p2Quantile estimates a quantile of a stream of values in constant memory with the P² algorithm of Jain and
+// Chlamtac, moving five markers along the distribution instead of keeping the values
+type p2Quantile struct {
+	p     float64
+	count int
+	q     [5]float64 // heights of the markers, the first values until five of them are seen
+	n     [5]float64 // positions of the markers
+	np    [5]float64 // desired positions of the markers
+	dn    [5]float64 // increments of the desired positions
+}
+
+func newP2Quantile(p float64) *p2Quantile {
+	return &p2Quantile{p: p, dn: [5]float64{0, p / 2, p, (1 + p) / 2, 1}}
+}
+
+// Add records a value
+func (e *p2Quantile) Add(x float64) {
+	if e.count < 5 {
+		e.q[e.count] = x
+		e.count++
+		if e.count == 5 {
+			sort.Float64s(e.q[:])
+			e.n = [5]float64{1, 2, 3, 4, 5}
+			e.np = [5]float64{1, 1 + 2*e.p, 1 + 4*e.p, 3 + 2*e.p, 5}
+		}
+		return
+	}
+	e.count++
+
+	// Find the cell of the value, extending the extreme markers
+	k := 0
+	switch {
+	case x < e.q[0]:
+		e.q[0] = x
+	case x >= e.q[4]:
+		e.q[4] = x
+		k = 3
+	default:
+		for k < 3 && x >= e.q[k+1] {
+			k++
+		}
+	}
+	for i := k + 1; i < 5; i++ {
+		e.n[i]++
+	}
+	for i := range e.np {
+		e.np[i] += e.dn[i]
+	}
+
+	// Move the middle markers towards their desired positions
+	for i := 1; i <= 3; i++ {
+		d := e.np[i] - e.n[i]
+		if (d >= 1 && e.n[i+1]-e.n[i] > 1) || (d <= -1 && e.n[i-1]-e.n[i] < -1) {
+			s := math.Copysign(1, d)
+			q := e.parabolic(i, s)
+			if e.q[i-1] < q && q < e.q[i+1] {
+				e.q[i] = q
+			} else {
+				e.q[i] = e.linear(i, s)
+			}
+			e.n[i] += s
+		}
+	}
+}
+
+func (e *p2Quantile) parabolic(i int, d float64) float64 {
+	return e.q[i] + d/(e.n[i+1]-e.n[i-1])*((e.n[i]-e.n[i-1]+d)*(e.q[i+1]-e.q[i])/(e.n[i+1]-e.n[i])+
+		(e.n[i+1]-e.n[i]-d)*(e.q[i]-e.q[i-1])/(e.n[i]-e.n[i-1]))
+}
+
+func (e *p2Quantile) linear(i int, d float64) float64 {
+	j := i + int(d)
+	return e.q[i] + d*(e.q[j]-e.q[i])/(e.n[j]-e.n[i])
+}
+
+// Value returns the estimate of the quantile, exact until five values are seen
+func (e *p2Quantile) Value() float64 {
+	if e.count == 0 {
+		return 0
+	}
+	if e.count < 5 {
+		values := append([]float64{}, e.q[:e.count]...)
+		sort.Float64s(values)
+		return values[int(math.Round(e.p*float64(e.count-1)))]
+	}
+	return e.q[2]
+}
+
+// actionLatency is the latency of the responses to an action
+type actionLatency struct {
+	count int
+	mean  float64 // in seconds
+	p50   *p2Quantile
+	p95   *p2Quantile
+}
+
+// ActionLatency is the latency of the responses to an action, as reported by SlowestActions
+type ActionLatency struct {
+	Action string
+	Count  int
+	Mean   time.Duration
+	P50    time.Duration
+	P95    time.Duration
+}
+
+// String returns the latency in a human readable format
+func (a ActionLatency) String() string {
+	return fmt.Sprintf("%s: mean %s, p50 %s, p95 %s (n=%d)", a.Action, a.Mean, a.P50, a.P95, a.Count)
+}
+
+// recordLatency adds the time to the first byte of a response to the latency of its action. Must be called with
+// the mutex held.
+func (mip *MarkovInputProvider) recordLatency(action string, resp *Response) {
+	if resp.Error != "" || resp.Elapsed <= 0 {
+		return
+	}
+	if mip.latencies == nil {
+		mip.latencies = make(map[string]*actionLatency)
+	}
+	l, ok := mip.latencies[action]
+	if !ok {
+		if len(mip.latencies) >= latencyActionLimit {
+			return
+		}
+		l = &actionLatency{p50: newP2Quantile(0.5), p95: newP2Quantile(0.95)}
+		mip.latencies[action] = l
+	}
+	seconds := resp.Elapsed.Seconds()
+	l.count++
+	l.mean += (seconds - l.mean) / float64(l.count)
+	l.p50.Add(seconds)
+	l.p95.Add(seconds)
+}
+
+// SlowestActions returns the n actions with the slowest responses by their 95th percentile, among the actions with
+// at least SlowActionMinObservations responses
+func (mip *MarkovInputProvider) SlowestActions(n int) []ActionLatency {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	seconds := func(s float64) time.Duration {
+		return time.Duration(s * float64(time.Second)).Round(time.Microsecond)
+	}
+	actions := make([]ActionLatency, 0)
+	for action, l := range mip.latencies {
+		if l.count < SlowActionMinObservations {
+			continue
+		}
+		actions = append(actions, ActionLatency{
+			Action: action,
+			Count:  l.count,
+			Mean:   seconds(l.mean),
+			P50:    seconds(l.p50.Value()),
+			P95:    seconds(l.p95.Value()),
+		})
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		if actions[i].P95 != actions[j].P95 {
+			return actions[i].P95 > actions[j].P95
+		}
+		if actions[i].Mean != actions[j].Mean {
+			return actions[i].Mean > actions[j].Mean
+		}
+		return actions[i].Action < actions[j].Action
+	})
+	if len(actions) > n {
+		actions = actions[:n]
+	}
+	return actions
+}
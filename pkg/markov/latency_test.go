@@ -0,0 +1,78 @@
+package markov
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestP2Quantile(t *testing.T) {
+	tests := []struct {
+		name     string
+		sample   func(r *rand.Rand) float64
+		p        float64
+		expected float64
+	}{
+		{"uniform median", func(r *rand.Rand) float64 { return r.Float64() }, 0.5, 0.5},
+		{"uniform p95", func(r *rand.Rand) float64 { return r.Float64() }, 0.95, 0.95},
+		{"exponential median", func(r *rand.Rand) float64 { return r.ExpFloat64() }, 0.5, math.Ln2},
+		{"exponential p95", func(r *rand.Rand) float64 { return r.ExpFloat64() }, 0.95, -math.Log(0.05)},
+		{"normal p95", func(r *rand.Rand) float64 { return 100 + 15*r.NormFloat64() }, 0.95, 100 + 15*1.6449},
+	}
+	for _, tt := range tests {
+		r := rand.New(rand.NewSource(1))
+		e := newP2Quantile(tt.p)
+		for i := 0; i < 20000; i++ {
+			e.Add(tt.sample(r))
+		}
+		if got := e.Value(); math.Abs(got-tt.expected) > 0.03*tt.expected {
+			t.Errorf("%s: expected %f within 3%%, got %f", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestP2QuantileFewValues(t *testing.T) {
+	e := newP2Quantile(0.5)
+	if got := e.Value(); got != 0 {
+		t.Errorf("Expected 0 without values, got %f", got)
+	}
+	for _, v := range []float64{9, 1, 5} {
+		e.Add(v)
+	}
+	if got := e.Value(); got != 5 {
+		t.Errorf("Expected the exact median of the first values, got %f", got)
+	}
+}
+
+func TestSlowestActions(t *testing.T) {
+	mip := newTestProvider("slow", "fast", "once")
+	respond := func(word string, elapsed time.Duration) {
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(word)}, &Response{StatusCode: 404, Elapsed: elapsed})
+	}
+	for i := 0; i < 10; i++ {
+		respond("slow", time.Duration(200+i)*time.Millisecond)
+		respond("fast", time.Duration(10+i)*time.Millisecond)
+	}
+	// Too few observations to be reported, however slow
+	respond("once", 5*time.Second)
+	// Failed requests tell nothing about the handler
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("fast")}, &Response{Error: CodeClassTimeout, Elapsed: time.Minute})
+
+	actions := mip.SlowestActions(5)
+	if len(actions) != 2 {
+		t.Fatalf("Expected the 2 actions with enough observations, got %v", actions)
+	}
+	if actions[0].Action != "slow" || actions[1].Action != "fast" {
+		t.Errorf("Expected the slow action first, got %v", actions)
+	}
+	if actions[1].Count != 10 || actions[1].P95 > 20*time.Millisecond {
+		t.Errorf("Expected the failed request to be left out, got %s", actions[1])
+	}
+	if mean := actions[0].Mean; mean != 204500*time.Microsecond {
+		t.Errorf("Expected a mean of 204.5ms, got %s", mean)
+	}
+	if got := mip.SlowestActions(1); len(got) != 1 || got[0].Action != "slow" {
+		t.Errorf("Expected the list to be cut to the slowest action, got %v", got)
+	}
+}
@@ -1,28 +1,129 @@
 package markov
 
 import (
+	"container/heap"
 	"fmt"
 	"hash/fnv"
 	"math"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+const (
+	// CodeClassTimeout is the code class of the terminal state for requests that timed out
+	CodeClassTimeout = "timeout"
+	// CodeClassConnError is the code class of the terminal state for requests failing on a connection error. The
+	// errors of a known class get a state of their own, see ConnErrorClass.
+	CodeClassConnError = "conn-error"
+	// CodeClassMethod is the code class of the 405 and 501 responses, the path existing with another method
+	CodeClassMethod = "4xx-method"
+)
+
+// ConnErrorClass returns the code class of the terminal state for requests failing on the given class of connection
+// error, like "conn-error-dns", telling the inputs failing on their own from the ones failing as the host does. An
+// empty class is the generic conn-error state.
+func ConnErrorClass(class string) string {
+	if class == "" {
+		return CodeClassConnError
+	}
+	return CodeClassConnError + "-" + class
+}
+
+// IsConnError tells whether a code class is the one of a connection error terminal state, of any class
+func IsConnError(codeClass string) bool {
+	return codeClass == CodeClassConnError || strings.HasPrefix(codeClass, CodeClassConnError+"-")
+}
+
 // State represents the state in our Markov chain
 type State struct {
-	CodeClass  string // "2xx", "3xx", "4xx", "5xx"
+	CodeClass  string // "2xx", "3xx", "4xx", "4xx-method", "5xx", "timeout", "conn-error" or "conn-error-<class>"
 	SizeBucket string // quantized/rounded size for body length
 	Depth      int    // depth of path
+	Proto      string // negotiated protocol, eg. "HTTP/2.0". Empty when not part of the state
+	Headers    string // bucketed number of response headers: "0-5", "6-15" or "16+". Empty when not part of the state
+	Cookie     string // "cookie" or "nocookie" depending on a Set-Cookie header being present. Empty when not part of the state
+	// "validator" or "novalidator" depending on a strong ETag or a Last-Modified header being present. Only part of
+	// the state with the fine granularity preset
+	Validator string
 }
 
 // Hash returns a hash representation of the state for use as map key
 func (s State) Hash() string {
-	return fmt.Sprintf("%s_%s_%d", s.CodeClass, s.SizeBucket, s.Depth)
+	hash := fmt.Sprintf("%s_%s_%d", s.CodeClass, s.SizeBucket, s.Depth)
+	if s.Proto != "" {
+		hash += "_" + s.Proto
+	}
+	if s.Headers != "" {
+		hash += "_h" + s.Headers
+	}
+	if s.Cookie != "" {
+		hash += "_" + s.Cookie
+	}
+	if s.Validator != "" {
+		hash += "_" + s.Validator
+	}
+	return hash
+}
+
+// StateGranularity controls which dimensions of the responses make up the states of the chain
+type StateGranularity string
+
+const (
+	// GranularityCoarse only keeps the code class in the state
+	GranularityCoarse StateGranularity = "coarse"
+	// GranularityDefault keeps the code class, size bucket and depth, along with the optional dimensions enabled
+	GranularityDefault StateGranularity = "default"
+	// GranularityFine keeps every dimension of the state, including the optional ones and the presence of a validator
+	GranularityFine StateGranularity = "fine"
+)
+
+// ParseStateGranularity returns the state granularity preset of the given name
+func ParseStateGranularity(name string) (StateGranularity, error) {
+	switch g := StateGranularity(name); g {
+	case GranularityCoarse, GranularityDefault, GranularityFine:
+		return g, nil
+	}
+	return "", fmt.Errorf("unknown state granularity: %s, valid values are: coarse, default, fine", name)
+}
+
+// WithGranularity returns the state reduced to the dimensions of the granularity preset
+func (s State) WithGranularity(g StateGranularity) State {
+	if g == GranularityCoarse {
+		return State{CodeClass: s.CodeClass}
+	}
+	return s
 }
 
 // Action represents the fuzz token/word that was used
 type Action struct {
-	Token string // the actual fuzz word/token used
+	Token    string // the actual fuzz word/token used
+	Location string // where the token was injected: "path", "query-name", "query-value", "header:<name>", "body:<JSON pointer>", "body" or "method". Empty if unknown
+	Method   string // HTTP method of the request when the method is fuzzed with another keyword. Empty otherwise
+}
+
+// Key returns the key of the action for use in the maps, distinguishing the same token injected in different locations
+// and sent with different fuzzed methods
+func (a Action) Key() string {
+	key := CapToken(a.Token)
+	if a.Location != "" {
+		key = a.Location + ":" + CapToken(a.Token)
+	}
+	if a.Method != "" {
+		key = a.Method + " " + key
+	}
+	return key
+}
+
+// HTTPMethod returns the fuzzed HTTP method of the action, either the token itself when it is injected in the method
+// or the method it was sent with. Empty if the method is not fuzzed.
+func (a Action) HTTPMethod() string {
+	if a.Location == "method" {
+		return a.Token
+	}
+	return a.Method
 }
 
 // Transition represents a transition from state S to state S' with an action
@@ -31,10 +132,23 @@ type Transition struct {
 	Action    Action
 	ToState   State
 	Reward    float64
+	// Position of the request in the results, 0 for the transitions not coming from a request of the run
+	Position int
+	// Values of the keywords of the request, each capped to TransitionValueCap bytes
+	Inputs    map[string][]byte
+	Timestamp time.Time
 }
 
 // MarkovChain holds the probability transition matrix and Q-values
 type MarkovChain struct {
+	// Counters for Stats, read without locking. Kept first in the struct for 64-bit alignment on 32-bit platforms.
+	stateCount      int64
+	transitionCount int64
+	rewardSum       uint64 // float64 bits of the sum of all the observed rewards
+	incomplete      int64  // responses cut off before the end of their body
+	droppedUpdates  int64  // transitions dropped because the queue of the updater goroutine was full
+	skippedTokens   int64  // transitions not learned because their token looked unique
+
 	// Q-values table: Q[state][action] = expected reward
 	QTable map[string]map[string]float64
 
@@ -47,17 +161,72 @@ type MarkovChain struct {
 	// State visit counts: how many times each state has been visited
 	StateCounts map[string]int
 
+	// Class counts: how many transitions ended up in a state of each code class
+	ClassCounts map[string]int
+
 	// Available actions cache for each state
 	AvailableActions map[string][]string
 
+	// Reward statistics: stats[state][action] = running mean and variance of the rewards of the action
+	RewardStats map[string]map[string]*RewardStat
+
+	// Feature values: FeatureQ[state][feature] = expected reward of the actions having the token feature,
+	// used to rank the tokens that have not been tried in the state yet
+	FeatureQTable map[string]map[Feature]float64
+
+	// Feature counts: counts[state][feature] = number of observed actions having the token feature
+	FeatureCounts map[string]map[Feature]int
+
+	// Method rewards: stats[method] = running mean and variance of the rewards of the requests sent with the
+	// HTTP method, when the method is fuzzed
+	MethodRewards map[string]*RewardStat
+
 	// Mutex for thread safety
 	mutex sync.RWMutex
 
+	// Q-values mirrored to atomic cells keyed by qKey, so that GetExpectedReward can read them without locking.
+	// Values are *uint64 holding the float64 bits, written only while holding the mutex.
+	qCells sync.Map
+
+	// Keys of the actions taken or biased in any state, read without locking by GetExpectedReward
+	knownActions sync.Map
+
+	// Updater goroutine of StartAsyncUpdates, an *asyncUpdater, and the *chainView of the rows it last published
+	async atomic.Value
+	view  atomic.Value
+	// States changed since the last published view, nil when the updates are inline. dirtyAll marks them all.
+	dirty    map[string]bool
+	dirtyAll bool
+
+	// Ring buffer of the latest transitions, for GetRecentTransitions
+	recent     []Transition
+	recentNext int
+
+	// Granularity of the states recorded in the chain
+	Granularity StateGranularity
+
+	// Size buckets of the states recorded in the chain
+	Buckets Bucketizer
+
 	// Configurable parameters
 	Alpha     float64 // Learning rate
 	Gamma     float64 // Discount factor
 	Epsilon   float64 // Exploration rate
-	Threshold float64 // Minimum threshold to consider as improvement
+	Threshold float64 // Noise gate: known actions are not updated on a temporal difference error smaller than it
+	// Weight of the feature score when ranking actions having a Q-value of their own
+	FeatureWeight float64
+	// Initial Q-value of an action the first time it is taken in a state. A positive value makes the untried
+	// actions look better than the ones that were already tried, until their values decay below the real winners.
+	OptimisticInit float64
+	// Minimum number of observations of an action or a feature in a state before its value influences the
+	// ordering. Values backed by fewer observations are ignored, leaving the words in their base order.
+	MinObservations int
+	// Number of the most visited states of each code class kept when evicting the cold states, so that the states
+	// of a rare class, like the few 2xx among the 404s, are not evicted in favor of the common ones
+	ClassQuota int
+	// Entropy in bits per character from which the tokens looking unique, see LooksUnique, are not learned. 0, the
+	// default, learns every token.
+	UniqueEntropy float64
 }
 
 // NewMarkovChain creates a new MarkovChain instance
@@ -67,16 +236,25 @@ func NewMarkovChain() *MarkovChain {
 		TransitionCounts: make(map[string]map[string]map[string]int),
 		ActionCounts:     make(map[string]map[string]int),
 		StateCounts:      make(map[string]int),
+		ClassCounts:      make(map[string]int),
 		AvailableActions: make(map[string][]string),
+		RewardStats:      make(map[string]map[string]*RewardStat),
+		FeatureQTable:    make(map[string]map[Feature]float64),
+		FeatureCounts:    make(map[string]map[Feature]int),
+		MethodRewards:    make(map[string]*RewardStat),
+		Granularity:      GranularityDefault,
+		Buckets:          LogBucketizer{},
 		Alpha:            0.1,  // Learning rate
 		Gamma:            0.9,  // Discount factor
 		Epsilon:          0.1,  // Exploration rate (10% of the time explore randomly)
-		Threshold:        0.01, // Minimum threshold
+		Threshold:        0.01, // Ignore the updates of less than 0.01 before the learning rate
+		FeatureWeight:    0.25, // Weight of the feature score for the known actions
+		OptimisticInit:   0.0,  // Untried actions start at 0, no optimistic exploration
+		MinObservations:  0,    // Every learned value is used
+		ClassQuota:       4,    // Keep the 4 most visited states of each code class
 	}
 }
 
-
-
 // QuantizeSize converts content length to a bucket representation (exported function)
 func QuantizeSize(size int64) string {
 	if size < 0 {
@@ -100,6 +278,18 @@ func quantizeSize(size int64) string {
 	return QuantizeSize(size)
 }
 
+// QuantizeHeaderCount converts the number of response headers to a bucket representation
+func QuantizeHeaderCount(count int) string {
+	switch {
+	case count <= 5:
+		return "0-5"
+	case count <= 15:
+		return "6-15"
+	default:
+		return "16+"
+	}
+}
+
 // GetSizeHash creates a hash of the response body content for more granular differentiation
 func GetSizeHash(data []byte) string {
 	if len(data) == 0 {
@@ -111,20 +301,34 @@ func GetSizeHash(data []byte) string {
 	return fmt.Sprintf("%x", h.Sum64())
 }
 
-
+// qKey returns the key of the atomic Q-value cell of an action in a state
+func qKey(stateKey string, actionKey string) string {
+	return stateKey + "\x00" + actionKey
+}
 
 // UpdateTransition updates the Q-value based on a state transition and reward
 func (mc *MarkovChain) UpdateTransition(transition Transition) {
-	mc.mutex.Lock()
-	defer mc.mutex.Unlock()
-
+	// Compute the keys before taking the lock to keep the critical section short. The recent transitions keep the
+	// capped token, not a whole megabyte-long line of the wordlist.
+	token := transition.Action.Token
+	transition.Action.Token = CapToken(token)
 	fromStateKey := transition.FromState.Hash()
-	actionKey := transition.Action.Token
+	actionKey := transition.Action.Key()
 	toStateKey := transition.ToState.Hash()
+	// A token unlike any other, like a UUID or a hash, would only add a key the chain never generalizes from. The
+	// whole token is checked, the hash suffix of a capped one looking unique by itself.
+	if entropy := mc.uniqueEntropy(); entropy > 0 && LooksUnique(token, entropy) {
+		atomic.AddInt64(&mc.skippedTokens, 1)
+		return
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
 
 	// Initialize maps if needed
 	if _, exists := mc.QTable[fromStateKey]; !exists {
 		mc.QTable[fromStateKey] = make(map[string]float64)
+		atomic.AddInt64(&mc.stateCount, 1)
 	}
 	if _, exists := mc.TransitionCounts[fromStateKey]; !exists {
 		mc.TransitionCounts[fromStateKey] = make(map[string]map[string]int)
@@ -135,6 +339,22 @@ func (mc *MarkovChain) UpdateTransition(transition Transition) {
 
 	// Update action counts
 	mc.ActionCounts[fromStateKey][actionKey]++
+	mc.markDirty(fromStateKey)
+
+	// Update the reward statistics
+	if _, exists := mc.RewardStats[fromStateKey]; !exists {
+		mc.RewardStats[fromStateKey] = make(map[string]*RewardStat)
+	}
+	if _, exists := mc.RewardStats[fromStateKey][actionKey]; !exists {
+		mc.RewardStats[fromStateKey][actionKey] = &RewardStat{}
+	}
+	mc.RewardStats[fromStateKey][actionKey].Add(transition.Reward)
+	if method := transition.Action.HTTPMethod(); method != "" {
+		if _, exists := mc.MethodRewards[method]; !exists {
+			mc.MethodRewards[method] = &RewardStat{}
+		}
+		mc.MethodRewards[method].Add(transition.Reward)
+	}
 
 	// Update transition counts
 	if _, exists := mc.TransitionCounts[fromStateKey][actionKey]; !exists {
@@ -144,10 +364,17 @@ func (mc *MarkovChain) UpdateTransition(transition Transition) {
 
 	// Update state visit counts
 	mc.StateCounts[fromStateKey]++
+	mc.ClassCounts[transition.ToState.CodeClass]++
+	atomic.AddInt64(&mc.transitionCount, 1)
+	mc.recordRecent(transition)
+	atomic.StoreUint64(&mc.rewardSum, math.Float64bits(math.Float64frombits(atomic.LoadUint64(&mc.rewardSum))+transition.Reward))
 
 	// Update Q-value using Q-learning update rule: Q(s,a) = Q(s,a) + α[r + γmax(Q(s',a')) - Q(s,a)]
-	currentQ := mc.QTable[fromStateKey][actionKey]
-	
+	currentQ, knownAction := mc.QTable[fromStateKey][actionKey]
+	if !knownAction {
+		currentQ = mc.OptimisticInit
+	}
+
 	// Find max Q-value for next state (if there are possible next actions)
 	maxNextQ := 0.0
 	if nextQs, exists := mc.QTable[toStateKey]; exists && len(nextQs) > 0 {
@@ -158,105 +385,339 @@ func (mc *MarkovChain) UpdateTransition(transition Transition) {
 		}
 	}
 
-	// Q-learning update
-	newQ := currentQ + mc.Alpha*(transition.Reward + mc.Gamma*maxNextQ - currentQ)
-	mc.QTable[fromStateKey][actionKey] = newQ
+	// Q-learning update, the known actions keeping their value when the error is below the noise gate so that the
+	// table does not drift on noise
+	delta := transition.Reward + mc.Gamma*maxNextQ - currentQ
+	if !knownAction || math.Abs(delta) >= mc.Threshold {
+		mc.setQ(fromStateKey, actionKey, currentQ+mc.Alpha*delta)
+	}
+	mc.updateFeatures(fromStateKey, transition.Action.Token, transition.Reward, maxNextQ)
 
 	// Update available actions if this is a new action for this state
-	found := false
-	for _, existingAction := range mc.AvailableActions[fromStateKey] {
-		if existingAction == actionKey {
-			found = true
-			break
-		}
-	}
-	if !found {
+	if !knownAction {
 		mc.AvailableActions[fromStateKey] = append(mc.AvailableActions[fromStateKey], actionKey)
+		mc.knownActions.Store(actionKey, true)
 	}
 }
 
-// GetBestActionsForState returns the top N actions for a given state, ordered by expected reward
-func (mc *MarkovChain) GetBestActionsForState(state State, wordlist []string, n int) []string {
-	mc.mutex.RLock()
-	defer mc.mutex.RUnlock()
-
+// BiasAction gives an action an initial Q-value in a state, making it a high prior candidate before it has been
+// observed. Actions already having a higher Q-value are left untouched.
+func (mc *MarkovChain) BiasAction(state State, action Action, q float64) {
 	stateKey := state.Hash()
-	
-	// If we don't have Q-values for this state, return the original wordlist
+	actionKey := action.Key()
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
 	if _, exists := mc.QTable[stateKey]; !exists {
-		// If we've seen this state before but have no Q-values, return random
-		if _, stateSeen := mc.StateCounts[stateKey]; stateSeen {
-			return getRandomSubset(wordlist, n)
-		}
-		// Never seen this state, return original wordlist or random subset
-		return getRandomSubset(wordlist, n)
+		mc.QTable[stateKey] = make(map[string]float64)
+		atomic.AddInt64(&mc.stateCount, 1)
+	}
+	current, known := mc.QTable[stateKey][actionKey]
+	if known && current >= q {
+		return
+	}
+	mc.setQ(stateKey, actionKey, q)
+	mc.markDirty(stateKey)
+	if !known {
+		mc.AvailableActions[stateKey] = append(mc.AvailableActions[stateKey], actionKey)
+		mc.knownActions.Store(actionKey, true)
 	}
+}
 
-	// Create a list of (action, q-value) pairs
-	type actionValue struct {
-		action string
-		value  float64
+// setQ stores the Q-value of an action in a state, and publishes it for the lock-free readers.
+// Must be called with the mutex held, after the QTable row of the state has been initialized.
+func (mc *MarkovChain) setQ(stateKey string, actionKey string, q float64) {
+	mc.QTable[stateKey][actionKey] = q
+	if cell, exists := mc.qCells.Load(qKey(stateKey, actionKey)); exists {
+		atomic.StoreUint64(cell.(*uint64), math.Float64bits(q))
+	} else {
+		bits := math.Float64bits(q)
+		mc.qCells.Store(qKey(stateKey, actionKey), &bits)
 	}
-	var actionValues []actionValue
+}
 
-	// Add all known actions for this state with their Q-values
-	for action, qValue := range mc.QTable[stateKey] {
-		// Only include actions that are actually in the wordlist
-		if containsString(wordlist, action) {
-			actionValues = append(actionValues, actionValue{action: action, value: qValue})
-		}
+// GetBestActionsForState returns the top N actions for a given state, ordered by expected reward. Words without
+// a Q-value of their own are ranked by the learned values of their token features, and the Q-values of the known
+// ones are blended with their feature score using FeatureWeight.
+func (mc *MarkovChain) GetBestActionsForState(state State, wordlist []string, n int) []string {
+	actionValues, remaining, exists := mc.scoreState(state.Hash(), wordlist, wordlist)
+
+	// If we don't have Q-values for this state, return the original wordlist or a random subset
+	if !exists {
+		return getRandomSubset(wordlist, n)
 	}
 
-	// If no known actions are in the wordlist, return random subset
+	// If no words can be scored, return random subset
 	if len(actionValues) == 0 {
 		return getRandomSubset(wordlist, n)
 	}
 
-	// Sort by Q-value in descending order
-	sort.Slice(actionValues, func(i, j int) bool {
-		return actionValues[i].value > actionValues[j].value
-	})
-
-	// Return top N actions (or all if less than N)
+	best, picked := popBestActions(actionValues, n, -math.MaxFloat64)
 	result := make([]string, 0, n)
-	for i := 0; i < len(actionValues) && i < n; i++ {
-		result = append(result, actionValues[i].action)
+	for _, ranked := range best {
+		result = append(result, ranked.action)
 	}
 
-	// If we have fewer than N actions, fill with remaining random words from wordlist
-	// that aren't already in the result
+	// If we have fewer than N actions, fill with the remaining words in random order
 	if len(result) < n {
-		remaining := make([]string, 0)
-		for _, word := range wordlist {
-			if !containsString(result, word) {
-				remaining = append(remaining, word)
-			}
-		}
-		
-		// Shuffle and add up to the remaining needed
 		shuffleStrings(remaining)
 		for i := 0; i < len(remaining) && len(result) < n; i++ {
-			result = append(result, remaining[i])
+			if !picked[remaining[i]] {
+				picked[remaining[i]] = true
+				result = append(result, remaining[i])
+			}
 		}
 	}
 
 	return result
 }
 
-// GetExpectedReward returns the expected reward for taking an action in a state
-func (mc *MarkovChain) GetExpectedReward(state State, action string) float64 {
+// ActionScore is the expected reward of an action ranked by the chain
+type ActionScore struct {
+	Action string
+	Value  float64
+	Exact  bool // scored from a Q-value of its own in the state rather than only from its token features
+}
+
+// HasState returns true if the chain has seen the state, as the origin of a transition or seeded
+func (mc *MarkovChain) HasState(state State) bool {
 	mc.mutex.RLock()
 	defer mc.mutex.RUnlock()
 
-	stateKey := state.Hash()
-	if qValues, exists := mc.QTable[stateKey]; exists {
-		if qValue, actionExists := qValues[action]; actionExists {
-			return qValue
+	_, exists := mc.QTable[state.Hash()]
+	return exists
+}
+
+// RankedActionsForState returns up to N actions of the wordlist the chain expects a positive reward from in the
+// state, best first. Unlike GetBestActionsForState, words the chain cannot score are never returned.
+func (mc *MarkovChain) RankedActionsForState(state State, wordlist []string, n int) []ActionScore {
+	return mc.rankActions(state, wordlist, wordlist, n)
+}
+
+// RankActions returns up to N of the actions the chain expects a positive reward from in the state, best first, by
+// their keys. Their Q-values are looked up by key while their features are scored on the bare token, so that an
+// action injected in a known location ranks on what was learned from the same token elsewhere.
+func (mc *MarkovChain) RankActions(state State, actions []Action, n int) []ActionScore {
+	keys := make([]string, 0, len(actions))
+	tokens := make([]string, 0, len(actions))
+	for _, action := range actions {
+		keys = append(keys, action.Key())
+		tokens = append(tokens, action.Token)
+	}
+	return mc.rankActions(state, keys, tokens, n)
+}
+
+// rankActions ranks the action keys, scoring the features of each on the token at the same index
+func (mc *MarkovChain) rankActions(state State, keys []string, tokens []string, n int) []ActionScore {
+	actionValues, _, exists := mc.scoreState(state.Hash(), keys, tokens)
+	if !exists {
+		return []ActionScore{}
+	}
+	best, _ := popBestActions(actionValues, n, math.SmallestNonzeroFloat64)
+	result := make([]ActionScore, 0, len(best))
+	for _, ranked := range best {
+		result = append(result, ActionScore{Action: ranked.action, Value: ranked.value, Exact: ranked.exact})
+	}
+	return result
+}
+
+// scoreState scores the action keys in a state, the features of each on the token at the same index, from the rows
+// last published by the updater goroutine when the updates are asynchronous so that the ranking never waits for
+// them. Returns false when the state has no Q-values.
+func (mc *MarkovChain) scoreState(stateKey string, keys []string, tokens []string) (rankedActions, []string, bool) {
+	if view := mc.publishedView(); view != nil {
+		rows, exists := view.rows[stateKey]
+		if !exists {
+			return nil, nil, false
 		}
+		actionValues, remaining := mc.scoreActions(rows, keys, tokens)
+		return actionValues, remaining, true
+	}
+
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+	if _, exists := mc.QTable[stateKey]; !exists {
+		return nil, nil, false
+	}
+	actionValues, remaining := mc.scoreActions(mc.rows(stateKey), keys, tokens)
+	return actionValues, remaining, true
+}
+
+// scoreActions scores the action keys the chain knows of from the rows of a state, the features of each on the token
+// at the same index, returning the ones it cannot score separately. The rows must not change while they are scored.
+func (mc *MarkovChain) scoreActions(rows stateRows, keys []string, tokens []string) (rankedActions, []string) {
+	// Create a list of (action, q-value) pairs
+	var actionValues rankedActions
+	remaining := make([]string, 0)
+
+	for i, word := range keys {
+		e := mc.explainAction(rows, word, tokens[i])
+		if e.Ranked {
+			actionValues = append(actionValues, rankedAction{action: word, index: len(actionValues), value: e.Score, exact: e.Source == "replay"})
+		} else {
+			remaining = append(remaining, word)
+		}
+	}
+	return actionValues, remaining
+}
+
+// popBestActions pops the top N actions (or all if less than N) by expected reward, stopping at the first one
+// valued below min and skipping the words listed more than once in the wordlist. Heapifying is linear, so this
+// is cheaper than sorting every scored word of a large wordlist.
+func popBestActions(actionValues rankedActions, n int, min float64) (rankedActions, map[string]bool) {
+	heap.Init(&actionValues)
+	result := make(rankedActions, 0, n)
+	picked := make(map[string]bool, n)
+	for actionValues.Len() > 0 && len(result) < n {
+		ranked := heap.Pop(&actionValues).(rankedAction)
+		if ranked.value < min {
+			break
+		}
+		if !picked[ranked.action] {
+			picked[ranked.action] = true
+			result = append(result, ranked)
+		}
+	}
+	return result, picked
+}
+
+// GetTransitionProbability returns the observed probability of ending up in the next state when taking the action
+// in a state, along with the number of times the action was taken in the state backing the estimate, so that
+// callers can apply their own cutoff.
+func (mc *MarkovChain) GetTransitionProbability(state State, action string, nextState State) (float64, int) {
+	stateKey := state.Hash()
+	nextStateKey := nextState.Hash()
+
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	count := mc.ActionCounts[stateKey][action]
+	if count == 0 {
+		return 0.0, 0
+	}
+	return float64(mc.TransitionCounts[stateKey][action][nextStateKey]) / float64(count), count
+}
+
+// rankedAction is a word scored by GetBestActionsForState, along with its position among the scored words
+type rankedAction struct {
+	action string
+	value  float64
+	index  int
+	exact  bool // scored from a Q-value of its own rather than only from its features
+}
+
+// rankedActions is a heap.Interface popping the actions by descending value. On ties the words having a Q-value
+// of their own come first, then the wordlist order is kept.
+type rankedActions []rankedAction
+
+func (r rankedActions) Len() int { return len(r) }
+func (r rankedActions) Less(i, j int) bool {
+	if r[i].value != r[j].value {
+		return r[i].value > r[j].value
+	}
+	if r[i].exact != r[j].exact {
+		return r[i].exact
+	}
+	return r[i].index < r[j].index
+}
+func (r rankedActions) Swap(i, j int)       { r[i], r[j] = r[j], r[i] }
+func (r *rankedActions) Push(x interface{}) { *r = append(*r, x.(rankedAction)) }
+func (r *rankedActions) Pop() interface{} {
+	old := *r
+	last := old[len(old)-1]
+	*r = old[:len(old)-1]
+	return last
+}
+
+// GetExpectedReward returns the expected reward for taking an action in a state. Actions known to the chain
+// but not yet taken in the state return OptimisticInit. It does not take the lock, so it can be called from
+// the hot path of every worker.
+func (mc *MarkovChain) GetExpectedReward(state State, action string) float64 {
+	if cell, exists := mc.qCells.Load(qKey(state.Hash(), action)); exists {
+		return math.Float64frombits(atomic.LoadUint64(cell.(*uint64)))
+	}
+	if _, registered := mc.knownActions.Load(action); registered {
+		return mc.OptimisticInit
 	}
 	return 0.0 // Default reward if not known
 }
 
+// ChainStats is a snapshot of the size of the chain and of the rewards observed so far
+type ChainStats struct {
+	States      int64   // number of states having at least one known action
+	Transitions int64   // number of transitions observed
+	MeanReward  float64 // mean reward of the observed transitions
+}
+
+// Stats returns the current statistics of the chain. It does not lock the chain, so it can be polled
+// frequently while the chain is being updated.
+func (mc *MarkovChain) Stats() ChainStats {
+	stats := ChainStats{
+		States:      atomic.LoadInt64(&mc.stateCount),
+		Transitions: atomic.LoadInt64(&mc.transitionCount),
+	}
+	if stats.Transitions > 0 {
+		stats.MeanReward = math.Float64frombits(atomic.LoadUint64(&mc.rewardSum)) / float64(stats.Transitions)
+	}
+	return stats
+}
+
+// recordIncomplete counts a response cut off before the end of its body
+func (mc *MarkovChain) recordIncomplete() {
+	atomic.AddInt64(&mc.incomplete, 1)
+}
+
+// Summary returns a short analysis of the observed transitions, counting the destination states by their
+// code class. Timeouts and connection errors are reported separately from the HTTP responses, the connection errors
+// by their class, and so are the responses cut off before the end of their body when there are any.
+func (mc *MarkovChain) Summary() string {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	total, connErrors := 0, 0
+	classes := make([]string, 0)
+	errClasses := make([]string, 0)
+	for class, count := range mc.ClassCounts {
+		total += count
+		switch {
+		case class == CodeClassTimeout:
+		case IsConnError(class):
+			connErrors += count
+			if class != CodeClassConnError {
+				errClasses = append(errClasses, class)
+			}
+		default:
+			classes = append(classes, class)
+		}
+	}
+	sort.Strings(classes)
+	counts := make([]string, 0, len(classes))
+	for _, class := range classes {
+		counts = append(counts, fmt.Sprintf("%s: %d", class, mc.ClassCounts[class]))
+	}
+	summary := fmt.Sprintf("Markov chain: %d transitions [%s], timeouts: %d, connection errors: %d",
+		total, strings.Join(counts, ", "), mc.ClassCounts[CodeClassTimeout], connErrors)
+	if len(errClasses) > 0 {
+		sort.Strings(errClasses)
+		errCounts := make([]string, 0, len(errClasses))
+		for _, class := range errClasses {
+			errCounts = append(errCounts, fmt.Sprintf("%s: %d", strings.TrimPrefix(class, CodeClassConnError+"-"), mc.ClassCounts[class]))
+		}
+		summary += fmt.Sprintf(" [%s]", strings.Join(errCounts, ", "))
+	}
+	if incomplete := atomic.LoadInt64(&mc.incomplete); incomplete > 0 {
+		summary += fmt.Sprintf(", incomplete responses: %d", incomplete)
+	}
+	if skipped := mc.SkippedTokens(); skipped > 0 {
+		summary += fmt.Sprintf(", unique-looking tokens not learned: %d", skipped)
+	}
+	if dropped := mc.DroppedUpdates(); dropped > 0 {
+		summary += fmt.Sprintf(", updates dropped: %d", dropped)
+	}
+	return summary
+}
+
 // containsString checks if a string exists in a slice
 func containsString(slice []string, item string) bool {
 	for _, s := range slice {
@@ -277,7 +738,7 @@ func getRandomSubset(slice []string, n int) []string {
 	result := make([]string, len(slice))
 	copy(result, slice)
 	shuffleStrings(result)
-	
+
 	return result[:n]
 }
 
@@ -290,4 +751,4 @@ func shuffleStrings(slice []string) {
 		j := int(math.Abs(float64(i*31))) % len(slice)
 		slice[i], slice[j] = slice[j], slice[i]
 	}
-}
\ No newline at end of file
+}
@@ -1,6 +1,10 @@
 package markov
 
 import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -94,4 +98,234 @@ func TestMarkovChain(t *testing.T) {
 	if expected <= 0.0 {
 		t.Errorf("Expected reward should be positive after update, got %f", expected)
 	}
-}
\ No newline at end of file
+}
+
+func TestMarkovChainSummary(t *testing.T) {
+	mc := NewMarkovChain()
+	from := State{CodeClass: "4xx", SizeBucket: "100", Depth: 0}
+	for _, to := range []string{"2xx", "4xx", "4xx", CodeClassTimeout, CodeClassConnError, CodeClassConnError} {
+		mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "a"}, ToState: State{CodeClass: to}})
+	}
+	expected := "Markov chain: 6 transitions [2xx: 1, 4xx: 2], timeouts: 1, connection errors: 2"
+	if summary := mc.Summary(); summary != expected {
+		t.Errorf("Unexpected summary: %q, want %q", summary, expected)
+	}
+
+	mc.recordIncomplete()
+	if summary := mc.Summary(); summary != expected+", incomplete responses: 1" {
+		t.Errorf("Unexpected summary with an incomplete response: %q", summary)
+	}
+}
+
+func TestMarkovChainStats(t *testing.T) {
+	mc := NewMarkovChain()
+	if stats := mc.Stats(); stats != (ChainStats{}) {
+		t.Errorf("Expected empty stats for a new chain, got %+v", stats)
+	}
+	mc.UpdateTransition(Transition{FromState: State{CodeClass: "4xx"}, Action: Action{Token: "a"}, ToState: State{CodeClass: "2xx"}, Reward: 3.0})
+	mc.UpdateTransition(Transition{FromState: State{CodeClass: "4xx"}, Action: Action{Token: "b"}, ToState: State{CodeClass: "4xx"}, Reward: 1.0})
+	mc.BiasAction(State{CodeClass: "2xx"}, Action{Token: "c"}, 1.0)
+	expected := ChainStats{States: 2, Transitions: 2, MeanReward: 2.0}
+	if stats := mc.Stats(); stats != expected {
+		t.Errorf("Unexpected stats: %+v, want %+v", stats, expected)
+	}
+}
+
+func TestMarkovChainConcurrent(t *testing.T) {
+	mc := NewMarkovChain()
+	from := State{CodeClass: "4xx", SizeBucket: "100", Depth: 0}
+	to := State{CodeClass: "2xx", SizeBucket: "1000", Depth: 0}
+	var wg sync.WaitGroup
+	for w := 0; w < 16; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				word := fmt.Sprintf("word%d", i%20)
+				mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: word}, ToState: to, Reward: 1.0})
+				mc.GetExpectedReward(from, word)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if len(mc.AvailableActions[from.Hash()]) != 20 {
+		t.Errorf("Expected each action to be available once, got %d", len(mc.AvailableActions[from.Hash()]))
+	}
+	for action, q := range mc.QTable[from.Hash()] {
+		if r := mc.GetExpectedReward(from, action); r != q {
+			t.Errorf("Lock-free read of %s returned %f, Q-table has %f", action, r, q)
+		}
+	}
+}
+
+func TestOptimisticInit(t *testing.T) {
+	mc := NewMarkovChain()
+	mc.OptimisticInit = 5.0
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	other := State{CodeClass: "2xx", SizeBucket: "1000"}
+	// terminal states without known actions, so that the trajectory only depends on the rewards
+	miss := State{CodeClass: "4xx", SizeBucket: "0", Depth: 1}
+	hit := State{CodeClass: "2xx", SizeBucket: "0", Depth: 1}
+
+	// The losing action decays from the optimistic value by Alpha on every zero reward
+	expected := []float64{4.5, 4.05, 3.645, 3.2805}
+	for i, want := range expected {
+		mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "loser"}, ToState: miss, Reward: 0.0})
+		if q := mc.GetExpectedReward(from, "loser"); math.Abs(q-want) > 1e-9 {
+			t.Errorf("Unexpected Q-value after %d updates: %f, want %f", i+1, q, want)
+		}
+	}
+
+	// The winner only moves from the optimistic value toward its reward
+	mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "winner"}, ToState: hit, Reward: 3.0})
+	if q := mc.GetExpectedReward(from, "winner"); math.Abs(q-4.8) > 1e-9 {
+		t.Errorf("Unexpected Q-value for the winner: %f, want 4.8", q)
+	}
+
+	// Registered but untried in the state returns the optimistic value, unknown actions return 0
+	if q := mc.GetExpectedReward(other, "loser"); q != 5.0 {
+		t.Errorf("Expected the optimistic value for an untried action, got %f", q)
+	}
+	if q := mc.GetExpectedReward(from, "unknown"); q != 0.0 {
+		t.Errorf("Expected 0 for an unknown action, got %f", q)
+	}
+
+	// Untried actions are preferred over the decayed loser in the greedy selection
+	mc.UpdateTransition(Transition{FromState: other, Action: Action{Token: "winner"}, ToState: hit, Reward: 3.0})
+	best := mc.GetBestActionsForState(other, []string{"winner", "loser"}, 2)
+	if best[0] != "loser" {
+		t.Errorf("Expected the untried action to be selected first, got %v", best)
+	}
+}
+
+func TestNoOptimisticInit(t *testing.T) {
+	mc := NewMarkovChain()
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "a"}, ToState: State{CodeClass: "2xx"}, Reward: 1.0})
+	if q := mc.GetExpectedReward(from, "a"); math.Abs(q-0.1) > 1e-9 {
+		t.Errorf("Expected the Q-value to start from 0 by default, got %f", q)
+	}
+	if q := mc.GetExpectedReward(State{CodeClass: "2xx"}, "a"); q != 0.0 {
+		t.Errorf("Expected 0 for an untried action without optimistic init, got %f", q)
+	}
+}
+
+func TestMinObservations(t *testing.T) {
+	mc := NewMarkovChain()
+	mc.MinObservations = 3
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	wordlist := []string{"about.html", "admin", "backup.php", "config.php", "index"}
+
+	// An unlucky start: the word hits twice, still not enough to reorder the wordlist
+	for i := 0; i < 2; i++ {
+		mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "backup.php"}, ToState: State{CodeClass: "2xx"}, Reward: 3.0})
+		if best := mc.GetBestActionsForState(from, wordlist, len(wordlist)); strings.Join(best, ",") != strings.Join(wordlist, ",") {
+			t.Errorf("Expected the base ordering after %d observations, got %v", i+1, best)
+		}
+	}
+
+	// Crossing the threshold lets the chain reorder
+	mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "backup.php"}, ToState: State{CodeClass: "2xx"}, Reward: 3.0})
+	if best := mc.GetBestActionsForState(from, wordlist, len(wordlist)); best[0] != "backup.php" {
+		t.Errorf("Expected the observed word to be ranked first once the threshold is crossed, got %v", best)
+	}
+}
+
+func TestThresholdNoiseGate(t *testing.T) {
+	mc := NewMarkovChain()
+	mc.Threshold = 0.5
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	to := State{CodeClass: "2xx", SizeBucket: "0", Depth: 1}
+	mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "a"}, ToState: to, Reward: 2.0})
+	if q := mc.GetExpectedReward(from, "a"); math.Abs(q-0.2) > 1e-9 {
+		t.Fatalf("Expected the first update to set the Q-value, got %f", q)
+	}
+
+	// A reward within the gate of the expected one leaves the Q-value untouched
+	mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "a"}, ToState: to, Reward: 0.5})
+	if q := mc.GetExpectedReward(from, "a"); math.Abs(q-0.2) > 1e-9 {
+		t.Errorf("Expected a reward within the noise gate to leave the Q-value at 0.2, got %f", q)
+	}
+	if count := mc.ActionCounts[from.Hash()]["a"]; count != 2 {
+		t.Errorf("Expected the gated transition to be counted, got %d", count)
+	}
+
+	// A larger one updates it
+	mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "a"}, ToState: to, Reward: 3.2})
+	if q := mc.GetExpectedReward(from, "a"); math.Abs(q-0.5) > 1e-9 {
+		t.Errorf("Expected a reward past the noise gate to update the Q-value to 0.5, got %f", q)
+	}
+
+	// Without a gate every reward updates it
+	mc.Threshold = 0
+	mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "a"}, ToState: to, Reward: 0.6})
+	if q := mc.GetExpectedReward(from, "a"); math.Abs(q-0.51) > 1e-9 {
+		t.Errorf("Expected the Q-value to be updated to 0.51 without a gate, got %f", q)
+	}
+}
+
+func TestGetTransitionProbability(t *testing.T) {
+	mc := NewMarkovChain()
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	hit := State{CodeClass: "2xx", SizeBucket: "1000"}
+	miss := State{CodeClass: "4xx", SizeBucket: "100", Depth: 1}
+	for _, to := range []State{hit, miss, miss, miss} {
+		mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "a"}, ToState: to})
+	}
+	if p, count := mc.GetTransitionProbability(from, "a", hit); p != 0.25 || count != 4 {
+		t.Errorf("Unexpected transition probability: %f backed by %d observations", p, count)
+	}
+	if p, count := mc.GetTransitionProbability(from, "b", hit); p != 0 || count != 0 {
+		t.Errorf("Expected no probability for an unknown action, got %f backed by %d observations", p, count)
+	}
+}
+
+func TestRewardStat(t *testing.T) {
+	// Sample mean 5 and variance 32/7 of the dataset
+	stat := RewardStat{}
+	for _, x := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		stat.Add(x)
+	}
+	if stat.Count != 8 || math.Abs(stat.Mean-5.0) > 1e-9 || math.Abs(stat.Variance()-32.0/7.0) > 1e-9 {
+		t.Errorf("Unexpected running statistics: %+v, variance %f", stat, stat.Variance())
+	}
+
+	// Large offsets do not lose precision to cancellation
+	stat = RewardStat{}
+	for _, x := range []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16} {
+		stat.Add(x)
+	}
+	if math.Abs(stat.Variance()-30.0) > 1e-6 {
+		t.Errorf("Unexpected variance with a large offset: %f, want 30", stat.Variance())
+	}
+
+	if single := (RewardStat{Count: 1, Mean: 3}); single.Variance() != 0 {
+		t.Errorf("Expected no variance for a single reward, got %f", single.Variance())
+	}
+}
+
+func TestTopActions(t *testing.T) {
+	mc := NewMarkovChain()
+	mc.MinObservations = 3
+	from := State{CodeClass: "4xx", SizeBucket: "100"}
+	to := State{CodeClass: "2xx"}
+	for _, r := range []float64{3.0, 1.0, 3.0, 1.0} {
+		mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "steady"}, ToState: to, Reward: r})
+	}
+	mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "lucky"}, ToState: to, Reward: 3.0})
+	mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: "miss"}, ToState: to, Reward: 0.0})
+
+	top := mc.TopActions(2)
+	if len(top) != 2 || top[0].Action != "steady" || top[1].Action != "lucky" {
+		t.Fatalf("Unexpected top actions: %v", top)
+	}
+	// Rewards 3, 1, 3, 1 have a sample variance of 4/3
+	margin := 1.96 * math.Sqrt(4.0/3.0) / 2.0
+	if top[0].Count != 4 || math.Abs(top[0].Margin-margin) > 1e-9 || top[0].LowConfidence {
+		t.Errorf("Unexpected confidence for the steady action: %+v", top[0])
+	}
+	if top[1].Count != 1 || !top[1].LowConfidence || !strings.HasSuffix(top[1].String(), "low confidence") {
+		t.Errorf("Expected the lucky action to be flagged, got %s", top[1])
+	}
+}
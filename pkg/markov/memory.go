@@ -0,0 +1,260 @@
+package markov
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// mapEntryBytes is the approximate overhead of a map entry in the runtime, on top of its key and value
+	mapEntryBytes = 48
+	// stringBytes is the size of a string header, on top of the bytes of the string
+	stringBytes = 16
+	// rewardStatBytes is the size of a RewardStat along with the pointer to it
+	rewardStatBytes = 32
+)
+
+// keyBytes returns the approximate memory used by a string key of a map along with the entry holding it
+func keyBytes(key string) int64 {
+	return mapEntryBytes + stringBytes + int64(len(key))
+}
+
+// stateBytes returns the approximate memory used by the rows of a state in the tables of the chain, the Q-values
+// mirrored to the atomic cells included. Must be called with the read lock held.
+func (mc *MarkovChain) stateBytes(stateKey string) int64 {
+	size := int64(0)
+	if row, ok := mc.QTable[stateKey]; ok {
+		size += keyBytes(stateKey)
+		for action := range row {
+			// The Q-value in the table, and its atomic cell keyed by the state and the action
+			size += keyBytes(action) + 8
+			size += keyBytes(qKey(stateKey, action)) + 8
+		}
+	}
+	if row, ok := mc.TransitionCounts[stateKey]; ok {
+		size += keyBytes(stateKey)
+		for action, next := range row {
+			size += keyBytes(action)
+			for nextKey := range next {
+				size += keyBytes(nextKey) + 8
+			}
+		}
+	}
+	if row, ok := mc.ActionCounts[stateKey]; ok {
+		size += keyBytes(stateKey)
+		for action := range row {
+			size += keyBytes(action) + 8
+		}
+	}
+	if _, ok := mc.StateCounts[stateKey]; ok {
+		size += keyBytes(stateKey) + 8
+	}
+	if actions, ok := mc.AvailableActions[stateKey]; ok {
+		size += keyBytes(stateKey) + int64(len(actions))*stringBytes
+	}
+	if row, ok := mc.RewardStats[stateKey]; ok {
+		size += keyBytes(stateKey)
+		for action := range row {
+			size += keyBytes(action) + rewardStatBytes
+		}
+	}
+	if row, ok := mc.FeatureQTable[stateKey]; ok {
+		size += keyBytes(stateKey)
+		for f := range row {
+			size += keyBytes(f.Name) + stringBytes + int64(len(f.Value)) + 8
+		}
+	}
+	if row, ok := mc.FeatureCounts[stateKey]; ok {
+		size += keyBytes(stateKey)
+		for f := range row {
+			size += keyBytes(f.Name) + stringBytes + int64(len(f.Value)) + 8
+		}
+	}
+	return size
+}
+
+// stateKeys returns the keys of the states having a row in any of the tables of the chain. Must be called with the
+// read lock held.
+func (mc *MarkovChain) stateKeys() map[string]bool {
+	keys := make(map[string]bool, len(mc.StateCounts))
+	for k := range mc.QTable {
+		keys[k] = true
+	}
+	for k := range mc.TransitionCounts {
+		keys[k] = true
+	}
+	for k := range mc.StateCounts {
+		keys[k] = true
+	}
+	for k := range mc.FeatureQTable {
+		keys[k] = true
+	}
+	return keys
+}
+
+// MemoryBytes returns the approximate memory used by the tables of the chain, in bytes. It walks the whole chain,
+// so it is meant to be polled rather than called for each request.
+func (mc *MarkovChain) MemoryBytes() int64 {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	size := int64(0)
+	for k := range mc.stateKeys() {
+		size += mc.stateBytes(k)
+	}
+	for method := range mc.MethodRewards {
+		size += keyBytes(method) + rewardStatBytes
+	}
+	for class := range mc.ClassCounts {
+		size += keyBytes(class) + 8
+	}
+	return size
+}
+
+// evictState removes the rows of a state from the tables of the chain. Must be called with the lock held.
+func (mc *MarkovChain) evictState(stateKey string) {
+	if row, ok := mc.QTable[stateKey]; ok {
+		for action := range row {
+			mc.qCells.Delete(qKey(stateKey, action))
+		}
+		delete(mc.QTable, stateKey)
+		atomic.AddInt64(&mc.stateCount, -1)
+	}
+	delete(mc.TransitionCounts, stateKey)
+	delete(mc.ActionCounts, stateKey)
+	delete(mc.StateCounts, stateKey)
+	delete(mc.AvailableActions, stateKey)
+	delete(mc.RewardStats, stateKey)
+	delete(mc.FeatureQTable, stateKey)
+	delete(mc.FeatureCounts, stateKey)
+}
+
+// stateClass returns the code class of a state from its key
+func stateClass(stateKey string) string {
+	return strings.SplitN(stateKey, "_", 2)[0]
+}
+
+// EvictColdStates removes the least visited states from the chain until at least the given number of bytes were
+// freed, or only the kept state and the ClassQuota most visited states of each code class are left. Returns the
+// number of evicted states and the bytes they used.
+func (mc *MarkovChain) EvictColdStates(bytes int64, keep string) (int, int64) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	keys := make([]string, 0)
+	left := make(map[string]int)
+	for k := range mc.stateKeys() {
+		if k != keep {
+			keys = append(keys, k)
+			left[stateClass(k)]++
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if mc.StateCounts[keys[i]] != mc.StateCounts[keys[j]] {
+			return mc.StateCounts[keys[i]] < mc.StateCounts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	evicted, freed := 0, int64(0)
+	for _, k := range keys {
+		if freed >= bytes {
+			break
+		}
+		if left[stateClass(k)] <= mc.ClassQuota {
+			// Among the most visited states of its class
+			continue
+		}
+		left[stateClass(k)]--
+		freed += mc.stateBytes(k)
+		mc.evictState(k)
+		evicted++
+	}
+	return evicted, freed
+}
+
+// MatchedBytes returns the approximate memory used by the matched input store, in bytes: the matched tokens, the
+// tokens generated from their patterns and their track record as seeds
+func (mip *MarkovInputProvider) MatchedBytes() int64 {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	size := int64(0)
+	for token := range mip.matchedTokens {
+		size += keyBytes(token) + 8
+	}
+	size += int64(len(mip.matchedOrder)) * stringBytes
+	for token, examples := range mip.generatedTokens {
+		size += keyBytes(token) + int64(len(examples))*stringBytes
+	}
+	for pattern := range mip.expandedPatterns {
+		size += keyBytes(pattern) + 1
+	}
+	for token := range mip.seeds {
+		size += keyBytes(token) + 32
+	}
+	for token := range mip.retiredSeeds {
+		size += keyBytes(token) + 1
+	}
+	return size
+}
+
+// EvictMatched removes the oldest matched tokens, along with their track record as seeds and the tokens generated
+// from their patterns, until at least the given number of bytes were freed. Returns the number of evicted tokens
+// and the bytes they used.
+func (mip *MarkovInputProvider) EvictMatched(bytes int64) (int, int64) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	evicted := make(map[string]bool)
+	freed := int64(0)
+	next := 0
+	for ; next < len(mip.matchedOrder) && freed < bytes; next++ {
+		token := mip.matchedOrder[next]
+		freed += stringBytes
+		if _, ok := mip.matchedTokens[token]; !ok {
+			// Retired from the seeds already
+			continue
+		}
+		freed += keyBytes(token) + 8
+		delete(mip.matchedTokens, token)
+		if _, ok := mip.seeds[token]; ok {
+			freed += keyBytes(token) + 32
+			delete(mip.seeds, token)
+		}
+		evicted[token] = true
+	}
+	mip.matchedOrder = append([]string{}, mip.matchedOrder[next:]...)
+	if len(evicted) == 0 {
+		return 0, freed
+	}
+	for token, examples := range mip.generatedTokens {
+		for _, e := range examples {
+			if evicted[e] {
+				freed += keyBytes(token) + int64(len(examples))*stringBytes
+				delete(mip.generatedTokens, token)
+				break
+			}
+		}
+	}
+	return len(evicted), freed
+}
+
+// EvictColdStates removes the least visited states from the chain until at least the given number of bytes were
+// freed, keeping the state the inputs are ranked in and the ClassQuota most visited states of each code class.
+// Returns the number of evicted states and the bytes they used.
+func (mip *MarkovInputProvider) EvictColdStates(bytes int64) (int, int64) {
+	mip.mutex.Lock()
+	keep := mip.baselineState.Hash()
+	mip.mutex.Unlock()
+
+	return mip.MarkovChain.EvictColdStates(bytes, keep)
+}
+
+// SetClassQuota sets the number of the most visited states of each code class kept when evicting the cold states
+func (mip *MarkovInputProvider) SetClassQuota(quota int) {
+	mip.MarkovChain.mutex.Lock()
+	defer mip.MarkovChain.mutex.Unlock()
+
+	mip.MarkovChain.ClassQuota = quota
+}
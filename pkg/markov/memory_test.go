@@ -0,0 +1,117 @@
+package markov
+
+import (
+	"fmt"
+	"testing"
+)
+
+// bucketState returns a 2xx state of the size bucket n
+func bucketState(n int) State {
+	return State{CodeClass: "2xx", SizeBucket: fmt.Sprint(n)}
+}
+
+func TestEvictColdStates(t *testing.T) {
+	baseline := State{CodeClass: "4xx"}
+	mip := NewMarkovInputProvider(nil, baseline, "", 0)
+	mc := mip.MarkovChain
+	for s := 0; s < 50; s++ {
+		from := bucketState(s)
+		for w := 0; w < 20+s; w++ {
+			to := bucketState(s + 1)
+			mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: fmt.Sprintf("w%d", w)}, ToState: to, Reward: 1.0})
+		}
+	}
+	mc.UpdateTransition(Transition{FromState: baseline, Action: Action{Token: "admin"}, ToState: baseline, Reward: 1.0})
+
+	before := mc.MemoryBytes()
+	if before <= 0 {
+		t.Fatalf("Expected the chain to account for its tables")
+	}
+	evicted, freed := mip.EvictColdStates(before / 4)
+	if evicted == 0 || freed < before/4 {
+		t.Fatalf("Expected at least %d bytes to be freed, got %d from %d states", before/4, freed, evicted)
+	}
+	after := mc.MemoryBytes()
+	if diff := before - freed - after; diff < -before/100 || diff > before/100 {
+		t.Errorf("Expected the memory after the eviction to be %d within 1%%, got %d", before-freed, after)
+	}
+	if mc.Stats().States != int64(51-evicted) {
+		t.Errorf("Expected %d states left, got %d", 51-evicted, mc.Stats().States)
+	}
+	// The least visited states go first
+	if mc.HasState(bucketState(0)) || !mc.HasState(bucketState(49)) {
+		t.Errorf("Expected the least visited states to be evicted first")
+	}
+	if got := mc.GetExpectedReward(bucketState(0), Action{Token: "w0"}.Key()); got != mc.OptimisticInit {
+		t.Errorf("Expected the Q-values of the evicted states to be dropped, got %f", got)
+	}
+
+	// Evicting everything keeps the state the inputs are ranked in, and the most visited states of the class
+	mip.EvictColdStates(before)
+	if !mc.HasState(baseline) || mc.Stats().States != int64(1+mc.ClassQuota) {
+		t.Errorf("Expected the baseline and %d 2xx states to be kept, got %d states", mc.ClassQuota, mc.Stats().States)
+	}
+	if !mc.HasState(bucketState(49)) || mc.HasState(bucketState(49-mc.ClassQuota)) {
+		t.Errorf("Expected the most visited 2xx states to be kept")
+	}
+
+	// Without a quota only the baseline is left
+	mc.ClassQuota = 0
+	mip.EvictColdStates(before)
+	if !mc.HasState(baseline) || mc.Stats().States != 1 {
+		t.Errorf("Expected only the baseline state to be kept, got %d states", mc.Stats().States)
+	}
+}
+
+func TestEvictColdStatesKeepsRareClasses(t *testing.T) {
+	baseline := State{CodeClass: "4xx", SizeBucket: "100"}
+	mip := NewMarkovInputProvider(nil, baseline, "", 0)
+	mc := mip.MarkovChain
+	// 10k 404s of a thousand sizes visited over and over, and a single 200
+	for i := 0; i < 10000; i++ {
+		from := State{CodeClass: "4xx", SizeBucket: fmt.Sprint(i % 1000)}
+		mc.UpdateTransition(Transition{FromState: from, Action: Action{Token: fmt.Sprintf("w%d", i)}, ToState: from})
+	}
+	rare := State{CodeClass: "2xx", SizeBucket: "5000"}
+	mc.UpdateTransition(Transition{FromState: rare, Action: Action{Token: "admin"}, ToState: rare, Reward: 1.0})
+
+	mip.EvictColdStates(mc.MemoryBytes())
+	if !mc.HasState(rare) {
+		t.Errorf("Expected the single 2xx state to survive the eviction of the cold states")
+	}
+	if mc.Stats().States != int64(1+1+mc.ClassQuota) {
+		t.Errorf("Expected the baseline, the 2xx state and %d 4xx states to be kept, got %d states", mc.ClassQuota, mc.Stats().States)
+	}
+}
+
+func TestEvictMatched(t *testing.T) {
+	mip := NewMarkovInputProvider(nil, State{CodeClass: "4xx"}, "", 0)
+	mip.RecordMatch("api_v1", 1.0)
+	mip.RecordMatch("api_v2", 1.0)
+	mip.RecordMatch("admin", 5.0)
+	mip.RecordMatch("api_v1", 2.0)
+	_, candidates := mip.PatternCandidates(5)
+	if len(candidates) == 0 {
+		t.Fatalf("Expected tokens to be generated from the matches")
+	}
+
+	before := mip.MatchedBytes()
+	evicted, freed := mip.EvictMatched(1)
+	if evicted != 1 || freed <= 0 {
+		t.Fatalf("Expected a single matched token to be evicted, got %d", evicted)
+	}
+	if tokens := mip.MatchedTokens(); len(tokens) != 2 || tokens[0] != "admin" || tokens[1] != "api_v2" {
+		t.Errorf("Expected the oldest matched token to be evicted, got %v", tokens)
+	}
+	if seeds := mip.PatternSeeds(candidates[0]); len(seeds) != 0 {
+		t.Errorf("Expected the tokens generated from the evicted token to be evicted too, got %v", seeds)
+	}
+	if after := mip.MatchedBytes(); after != before-freed {
+		t.Errorf("Expected %d bytes left, got %d", before-freed, after)
+	}
+
+	evicted, _ = mip.EvictMatched(before)
+	if evicted != 2 || len(mip.MatchedTokens()) != 0 {
+		t.Errorf("Expected every matched token to be evicted, got %d", evicted)
+	}
+}
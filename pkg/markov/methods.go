@@ -0,0 +1,37 @@
+package markov
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MethodStat is the reward of the requests sent with an HTTP method, when the method is fuzzed
+type MethodStat struct {
+	Method     string
+	Count      int
+	MeanReward float64
+}
+
+// String returns the method statistics in a human readable format
+func (m MethodStat) String() string {
+	return fmt.Sprintf("%s: mean reward %.3f (n=%d)", m.Method, m.MeanReward, m.Count)
+}
+
+// MethodBreakdown returns the reward statistics of the fuzzed HTTP methods, the most productive first. The order is
+// the priority of the methods for the paths that remain to be fuzzed.
+func (mc *MarkovChain) MethodBreakdown() []MethodStat {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	methods := make([]MethodStat, 0, len(mc.MethodRewards))
+	for method, stat := range mc.MethodRewards {
+		methods = append(methods, MethodStat{Method: method, Count: stat.Count, MeanReward: stat.Mean})
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		if methods[i].MeanReward != methods[j].MeanReward {
+			return methods[i].MeanReward > methods[j].MeanReward
+		}
+		return methods[i].Method < methods[j].Method
+	})
+	return methods
+}
@@ -0,0 +1,71 @@
+package markov
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMethodBreakdown(t *testing.T) {
+	// The API only accepts PUT, on half of its routes
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var route int
+		fmt.Sscanf(r.URL.Path, "/route%d", &route)
+		if r.Method == http.MethodPut && route%2 == 0 {
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, strings.Repeat("created", 200))
+			return
+		}
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer ts.Close()
+
+	mip := newTestProvider()
+	mip.SetKeywordLocations(map[string]string{"METHOD": "method", "FUZZ": "path"})
+	for _, method := range []string{"GET", "PUT", "OPTIONS", "DELETE"} {
+		for route := 0; route < 10; route++ {
+			path := fmt.Sprintf("route%d", route)
+			req, _ := http.NewRequest(method, ts.URL+"/"+path, nil)
+			httpresp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			httpresp.Body.Close()
+			resp := &Response{StatusCode: int64(httpresp.StatusCode), ContentLength: httpresp.ContentLength, URL: req.URL.String()}
+			mip.UpdateWithResponse(map[string][]byte{"METHOD": []byte(method), "FUZZ": []byte(path)}, resp)
+		}
+	}
+
+	methods := mip.MarkovChain.MethodBreakdown()
+	if len(methods) != 4 {
+		t.Fatalf("Expected a breakdown of the 4 methods, got %v", methods)
+	}
+	if methods[0].Method != "PUT" || methods[0].Count != 10 || methods[0].MeanReward <= methods[1].MeanReward {
+		t.Errorf("Expected PUT to be the most productive method, got %v", methods)
+	}
+	if _, known := mip.MarkovChain.QTable[mip.baselineState.Hash()]["PUT path:route0"]; !known {
+		t.Errorf("Expected the method to be part of the action key, got %v", mip.MarkovChain.AvailableActions)
+	}
+	row := mip.MarkovChain.QTable[mip.baselineState.Hash()]
+	if row["GET path:route0"] >= row["PUT path:route0"] {
+		t.Errorf("Expected GET on the same route to be learned separately, got %f and %f for PUT", row["GET path:route0"], row["PUT path:route0"])
+	}
+}
+
+func TestActionFromInputsMethod(t *testing.T) {
+	mip := newTestProvider()
+	mip.SetKeywordLocations(map[string]string{"FUZZ": "method"})
+	if action := mip.actionFromInputs(map[string][]byte{"FUZZ": []byte("PUT")}); action.Key() != "method:PUT" || action.HTTPMethod() != "PUT" {
+		t.Errorf("Expected the fuzzed method to be the action, got %+v", action)
+	}
+	mip.SetKeywordLocations(map[string]string{"METHOD": "method"})
+	if action := mip.actionFromInputs(map[string][]byte{"METHOD": []byte("PATCH")}); action.Key() != "method:PATCH" {
+		t.Errorf("Expected the method to be the action without a FUZZ keyword, got %+v", action)
+	}
+	mip.SetKeywordLocations(nil)
+	if action := mip.actionFromInputs(map[string][]byte{"FUZZ": []byte("admin")}); action.Key() != "admin" || action.HTTPMethod() != "" {
+		t.Errorf("Expected no method when it is not fuzzed, got %+v", action)
+	}
+}
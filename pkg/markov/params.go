@@ -0,0 +1,161 @@
+package markov
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Names of the learning parameters and of the reward weights outside the decision tables, see SetParam
+const (
+	ParamAlpha         = "alpha"
+	ParamGamma         = "gamma"
+	ParamEpsilon       = "epsilon"
+	ParamMix           = "mix"
+	ParamTimeout       = "timeout"
+	ParamConnError     = "conn-error"
+	ParamCookie        = "cookie"
+	ParamRedirectChain = "redirect-chain"
+	ParamReflection    = "reflection"
+)
+
+// ParamChange is a learning parameter or a reward weight changed while the chain learns, see SetParam
+type ParamChange struct {
+	Time time.Time `json:"time"`
+	Name string    `json:"name"`
+	Old  float64   `json:"old"`
+	New  float64   `json:"new"`
+}
+
+func (c ParamChange) String() string {
+	return fmt.Sprintf("%s changed from %g to %g at %s", c.Name, c.Old, c.New, c.Time.Format("15:04:05"))
+}
+
+// ParamNames returns the names of the parameters SetParam changes: the learning rate alpha, the discount factor
+// gamma, the exploration rate epsilon and mix, the weight of the feature score blended with the Q-values, then the
+// rules of the reward decision tables, like auth for the 401 and 403 responses, and the timeout, conn-error, cookie,
+// redirect-chain and reflection rewards.
+func ParamNames() []string {
+	names := []string{ParamAlpha, ParamGamma, ParamEpsilon, ParamMix}
+	for _, rule := range rewardRules {
+		names = append(names, rule.Name)
+	}
+	for _, rule := range vhostRewardRules {
+		names = append(names, rule.Name)
+	}
+	return append(names, ParamTimeout, ParamConnError, ParamCookie, ParamRedirectChain, ParamReflection)
+}
+
+// validateParam returns an error if the value is out of the range of the parameter
+func validateParam(name string, value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%s must be a finite number", name)
+	}
+	switch name {
+	case ParamAlpha:
+		if value <= 0 || value > 1 {
+			return fmt.Errorf("%s must be in (0,1]", name)
+		}
+	case ParamGamma:
+		if value < 0 || value >= 1 {
+			return fmt.Errorf("%s must be in [0,1)", name)
+		}
+	case ParamEpsilon, ParamMix:
+		if value < 0 || value > 1 {
+			return fmt.Errorf("%s must be in [0,1]", name)
+		}
+	}
+	return nil
+}
+
+// paramField returns the field holding a parameter. The reward decision tables are copied the first time, the
+// shared tables being left untouched. Must be called with the mutexes of the provider and of the chain held.
+func (mip *MarkovInputProvider) paramField(name string) (*float64, error) {
+	mc := mip.MarkovChain
+	switch name {
+	case ParamAlpha:
+		return &mc.Alpha, nil
+	case ParamGamma:
+		return &mc.Gamma, nil
+	case ParamEpsilon:
+		return &mc.Epsilon, nil
+	case ParamMix:
+		return &mc.FeatureWeight, nil
+	case ParamTimeout:
+		return &mip.timeoutReward, nil
+	case ParamConnError:
+		return &mip.connErrorReward, nil
+	case ParamCookie:
+		return &mip.cookieReward, nil
+	case ParamRedirectChain:
+		return &mip.redirectReward, nil
+	case ParamReflection:
+		return &mip.reflectionReward, nil
+	}
+	if mip.rewardRules == nil {
+		mip.rewardRules = append([]RewardRule(nil), rewardRules...)
+		mip.vhostRewardRules = append([]RewardRule(nil), vhostRewardRules...)
+	}
+	for _, rules := range [][]RewardRule{mip.rewardRules, mip.vhostRewardRules} {
+		for i := range rules {
+			if rules[i].Name == name {
+				return &rules[i].Delta, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown parameter %q, expected one of %s", name, strings.Join(ParamNames(), ", "))
+}
+
+// Param returns the current value of a parameter of ParamNames
+func (mip *MarkovInputProvider) Param(name string) (float64, error) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+	mip.MarkovChain.mutex.Lock()
+	defer mip.MarkovChain.mutex.Unlock()
+
+	field, err := mip.paramField(name)
+	if err != nil {
+		return 0, err
+	}
+	return *field, nil
+}
+
+// SetParam changes a learning parameter or a reward weight of ParamNames while the chain learns. The learning
+// parameters are read by every update under the lock of the chain, and the rewards by every Feedback, so the change
+// applies to the next update. The changes are recorded for ParamChanges.
+func (mip *MarkovInputProvider) SetParam(name string, value float64) (ParamChange, error) {
+	if err := validateParam(name, value); err != nil {
+		return ParamChange{}, err
+	}
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+	mip.MarkovChain.mutex.Lock()
+	defer mip.MarkovChain.mutex.Unlock()
+
+	field, err := mip.paramField(name)
+	if err != nil {
+		return ParamChange{}, err
+	}
+	change := ParamChange{Time: time.Now(), Name: name, Old: *field, New: value}
+	*field = value
+	mip.paramChanges = append(mip.paramChanges, change)
+	return change, nil
+}
+
+// ParamChanges returns the changes made with SetParam, oldest first
+func (mip *MarkovInputProvider) ParamChanges() []ParamChange {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	return append([]ParamChange(nil), mip.paramChanges...)
+}
+
+// rewardTables returns the reward decision tables of the provider, the shared ones until a weight is changed with
+// SetParam. Must be called with the mutex held.
+func (mip *MarkovInputProvider) rewardTables() ([]RewardRule, []RewardRule) {
+	if mip.rewardRules == nil {
+		return rewardRules, vhostRewardRules
+	}
+	return mip.rewardRules, mip.vhostRewardRules
+}
@@ -0,0 +1,83 @@
+package markov
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSetParamValidation(t *testing.T) {
+	mip := newTestProvider()
+	tests := []struct {
+		name  string
+		value float64
+		err   bool
+	}{
+		{ParamAlpha, 0.5, false},
+		{ParamAlpha, 0, true},
+		{ParamAlpha, 1.5, true},
+		{ParamGamma, 0, false},
+		{ParamGamma, 1, true},
+		{ParamEpsilon, 1, false},
+		{ParamEpsilon, -0.1, true},
+		{ParamMix, 0.75, false},
+		{ParamMix, 2, true},
+		{"auth", -1, false},
+		{"vhost-size", 2, false},
+		{ParamReflection, 0.4, false},
+		{"auth", math.NaN(), true},
+		{ParamTimeout, math.Inf(1), true},
+		{"lambda", 0.5, true},
+	}
+	for _, tt := range tests {
+		if _, err := mip.SetParam(tt.name, tt.value); (err != nil) != tt.err {
+			t.Errorf("SetParam(%s, %g): expected error %t, got %v", tt.name, tt.value, tt.err, err)
+		}
+	}
+	if changes := mip.ParamChanges(); len(changes) != 7 {
+		t.Errorf("Expected the 7 valid changes recorded, got %v", changes)
+	}
+	for _, name := range ParamNames() {
+		if _, err := mip.Param(name); err != nil {
+			t.Errorf("Expected %s to be a parameter: %s", name, err)
+		}
+	}
+}
+
+func TestSetParamAffectsNextUpdates(t *testing.T) {
+	mip := newTestProvider()
+	// A 403 differing from the baseline gets the auth reward of 1.8 and the new-content bonus of 0.5
+	forbidden := &Response{StatusCode: 403, ContentLength: 2000}
+	if reward := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, forbidden); reward != 2.3 {
+		t.Fatalf("Expected the default reward of 2.3, got %g", reward)
+	}
+
+	change, err := mip.SetParam("auth", 0.5)
+	if err != nil {
+		t.Fatalf("Could not set the auth weight: %s", err)
+	}
+	if change.Old != 1.8 || change.New != 0.5 || change.Name != "auth" {
+		t.Errorf("Unexpected change recorded: %+v", change)
+	}
+	if _, err := mip.SetParam(ParamAlpha, 1); err != nil {
+		t.Fatalf("Could not set alpha: %s", err)
+	}
+	if _, err := mip.SetParam(ParamGamma, 0); err != nil {
+		t.Fatalf("Could not set gamma: %s", err)
+	}
+	if reward := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("private")}, forbidden); reward != 1.0 {
+		t.Errorf("Expected the reward of 1.0 with the new auth weight, got %g", reward)
+	}
+	// With a learning rate of 1 and no discount, the Q-value of the new action is its reward
+	if q := mip.MarkovChain.QTable[mip.baselineState.Hash()]["private"]; q != 1.0 {
+		t.Errorf("Expected the Q-value learned with the new parameters to be 1.0, got %g", q)
+	}
+
+	// The decision table shared by the other providers is untouched
+	other := newTestProvider()
+	if reward := other.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, forbidden); reward != 2.3 {
+		t.Errorf("Expected the other providers to keep the auth reward of 1.8, got %g", reward)
+	}
+	if mip.RewardConfigHash() == other.RewardConfigHash() {
+		t.Errorf("Expected the changed weights in the reward configuration hash")
+	}
+}
@@ -0,0 +1,404 @@
+package markov
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// patternTopK is the number of best matched tokens the patterns are induced from
+	patternTopK = 20
+	// patternMaxSlots is the largest number of varying parts of a pattern
+	patternMaxSlots = 2
+	// patternMaxSet is the largest number of tokens a pattern may describe, patterns describing more are degenerate
+	patternMaxSet = 1000
+	// patternDigitSpread is how far below and above the observed numbers a digit slot reaches
+	patternDigitSpread = 10
+)
+
+// runClass returns the class of a character: 'd' for digits, 'a' for letters and 'o' for anything else
+func runClass(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return 'd'
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return 'a'
+	}
+	return 'o'
+}
+
+// tokenRuns splits a token in runs of digits, letters and other characters, "api_v1" giving "api", "_", "v"
+// and "1"
+func tokenRuns(token string) []string {
+	runs := make([]string, 0)
+	start := 0
+	for i := 1; i <= len(token); i++ {
+		if i == len(token) || runClass(token[i]) != runClass(token[start]) {
+			runs = append(runs, token[start:i])
+			start = i
+		}
+	}
+	return runs
+}
+
+// familyKey returns the key of the family of a token: tokens sharing their shape and their words, and differing
+// only by their numbers and single letters, are in the same family
+func familyKey(runs []string) string {
+	parts := make([]string, 0, len(runs))
+	for _, run := range runs {
+		class := runClass(run[0])
+		if class == 'd' || (class == 'a' && len(run) == 1) {
+			parts = append(parts, string(class))
+		} else {
+			parts = append(parts, string(class)+run)
+		}
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// patternSegment is a part of a token pattern: a literal, or a slot of digits or of a single letter
+type patternSegment struct {
+	literal string
+	slot    byte  // 'd' for a digit slot, 'a' for a letter slot, 0 for a literal
+	values  []int // digit slot: the numbers to enumerate, letter slot: the letters
+	width   int   // digit slot: zero padded width, 0 when not padded
+}
+
+// TokenPattern is a pattern induced from a family of matched tokens, like api_v1 and api_v2 giving api_v\d+
+type TokenPattern struct {
+	segments []patternSegment
+	examples []string
+}
+
+// String returns the pattern as a regular expression
+func (p *TokenPattern) String() string {
+	var sb strings.Builder
+	for _, s := range p.segments {
+		switch {
+		case s.slot == 'd' && s.width > 0:
+			sb.WriteString(fmt.Sprintf(`\d{%d}`, s.width))
+		case s.slot == 'd':
+			sb.WriteString(`\d+`)
+		case s.slot == 'a' && s.values[0] >= 'a':
+			sb.WriteString("[a-z]")
+		case s.slot == 'a':
+			sb.WriteString("[A-Z]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(s.literal))
+		}
+	}
+	return sb.String()
+}
+
+// size returns the number of tokens the pattern describes
+func (p *TokenPattern) size() int {
+	size := 1
+	for _, s := range p.segments {
+		if s.slot != 0 {
+			size *= len(s.values)
+		}
+	}
+	return size
+}
+
+// Expand returns up to max tokens described by the pattern, leaving out the examples it was induced from and the
+// tokens for which skip returns true
+func (p *TokenPattern) Expand(max int, skip func(string) bool) []string {
+	examples := make(map[string]bool, len(p.examples))
+	for _, e := range p.examples {
+		examples[e] = true
+	}
+	tokens := make([]string, 0, max)
+	// Walk the combinations of the slot values like an odometer, the last slot moving fastest
+	indexes := make([]int, len(p.segments))
+	for len(tokens) < max {
+		var sb strings.Builder
+		for i, s := range p.segments {
+			switch s.slot {
+			case 'd':
+				sb.WriteString(fmt.Sprintf("%0*d", s.width, s.values[indexes[i]]))
+			case 'a':
+				sb.WriteByte(byte(s.values[indexes[i]]))
+			default:
+				sb.WriteString(s.literal)
+			}
+		}
+		token := sb.String()
+		if !examples[token] && (skip == nil || !skip(token)) {
+			tokens = append(tokens, token)
+		}
+		i := len(p.segments) - 1
+		for ; i >= 0; i-- {
+			if p.segments[i].slot == 0 {
+				continue
+			}
+			indexes[i]++
+			if indexes[i] < len(p.segments[i].values) {
+				break
+			}
+			indexes[i] = 0
+		}
+		if i < 0 {
+			break
+		}
+	}
+	return tokens
+}
+
+// InducePatterns induces the patterns of the families of tokens, in the order of their first token. Families of
+// a single token, and patterns that are degenerate or describe too many tokens, are left out.
+func InducePatterns(tokens []string) []*TokenPattern {
+	families := make(map[string][][]string)
+	order := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		if token == "" || seen[token] {
+			continue
+		}
+		seen[token] = true
+		runs := tokenRuns(token)
+		key := familyKey(runs)
+		if _, ok := families[key]; !ok {
+			order = append(order, key)
+		}
+		families[key] = append(families[key], runs)
+	}
+	patterns := make([]*TokenPattern, 0)
+	for _, key := range order {
+		if len(families[key]) < 2 {
+			continue
+		}
+		if p := inducePattern(families[key]); p != nil {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// inducePattern induces the pattern of a family of at least two tokens split in runs. Returns nil if the pattern
+// is degenerate.
+func inducePattern(family [][]string) *TokenPattern {
+	p := &TokenPattern{}
+	for _, runs := range family {
+		p.examples = append(p.examples, strings.Join(runs, ""))
+	}
+	slots := 0
+	literalLetters := false
+	for i := range family[0] {
+		values := make([]string, 0, len(family))
+		distinct := make(map[string]bool)
+		for _, runs := range family {
+			values = append(values, runs[i])
+			distinct[runs[i]] = true
+		}
+		if len(distinct) == 1 {
+			if runClass(values[0][0]) == 'a' {
+				literalLetters = true
+			}
+			p.segments = append(p.segments, patternSegment{literal: values[0]})
+			continue
+		}
+		slots++
+		var segment *patternSegment
+		if runClass(values[0][0]) == 'd' {
+			segment = digitSlot(values)
+		} else {
+			segment = letterSlot(values)
+		}
+		if segment == nil {
+			return nil
+		}
+		p.segments = append(p.segments, *segment)
+	}
+	// A pattern needs a word to anchor it, \d+ alone matching anything numeric
+	if slots == 0 || slots > patternMaxSlots || !literalLetters || p.size() > patternMaxSet {
+		return nil
+	}
+	return p
+}
+
+// digitSlot returns the slot of the observed numbers, reaching patternDigitSpread below and above them
+func digitSlot(values []string) *patternSegment {
+	segment := &patternSegment{slot: 'd'}
+	padded := false
+	min, max := -1, -1
+	for _, v := range values {
+		if len(v) > 6 {
+			// Ids and timestamps rather than a sequence
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil
+		}
+		if len(v) > 1 && v[0] == '0' {
+			padded = true
+		}
+		if min == -1 || n < min {
+			min = n
+		}
+		if n > max {
+			max = n
+		}
+	}
+	if padded {
+		// Zero padded numbers all have the same width
+		for _, v := range values {
+			if len(v) != len(values[0]) {
+				return nil
+			}
+		}
+		segment.width = len(values[0])
+	}
+	low, high := min-patternDigitSpread, max+patternDigitSpread
+	if low < 0 {
+		low = 0
+	}
+	if high-low+1 > patternMaxSet {
+		return nil
+	}
+	for n := low; n <= high; n++ {
+		if segment.width > 0 && len(strconv.Itoa(n)) > segment.width {
+			break
+		}
+		segment.values = append(segment.values, n)
+	}
+	return segment
+}
+
+// letterSlot returns the slot of single letters of the same case, nil for anything else
+func letterSlot(values []string) *patternSegment {
+	lower := values[0][0] >= 'a'
+	for _, v := range values {
+		if len(v) != 1 || (v[0] >= 'a') != lower {
+			return nil
+		}
+	}
+	segment := &patternSegment{slot: 'a'}
+	first := 'A'
+	if lower {
+		first = 'a'
+	}
+	for c := first; c < first+26; c++ {
+		segment.values = append(segment.values, int(c))
+	}
+	return segment
+}
+
+// RecordMatch remembers a matched token with its reward, for the induction of patterns. Tokens retired from the
+// seeds are not remembered again.
+func (mip *MarkovInputProvider) RecordMatch(token string, reward float64) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	if mip.retiredSeeds[token] {
+		return
+	}
+	if mip.matchedTokens == nil {
+		mip.matchedTokens = make(map[string]float64)
+	}
+	best, ok := mip.matchedTokens[token]
+	if !ok {
+		mip.matchedOrder = append(mip.matchedOrder, token)
+	}
+	if !ok || reward > best {
+		mip.matchedTokens[token] = reward
+	}
+}
+
+// PatternCandidates induces the patterns of the best matched tokens, and returns the patterns not expanded
+// before along with up to max tokens of each of them. Tokens known to the chain, or generated from another
+// pattern, are left out.
+func (mip *MarkovInputProvider) PatternCandidates(max int) ([]string, []string) {
+	tokens := mip.MatchedTokens()
+	mip.mutex.Lock()
+	location := mip.keywordLocations["FUZZ"]
+	mip.mutex.Unlock()
+
+	patterns := make([]string, 0)
+	candidates := make([]string, 0)
+	for _, p := range InducePatterns(tokens) {
+		mip.mutex.Lock()
+		if mip.expandedPatterns == nil {
+			mip.expandedPatterns = make(map[string]bool)
+			mip.generatedTokens = make(map[string][]string)
+		}
+		if mip.expandedPatterns[p.String()] {
+			mip.mutex.Unlock()
+			continue
+		}
+		mip.expandedPatterns[p.String()] = true
+		expanded := p.Expand(max, func(token string) bool {
+			_, known := mip.MarkovChain.knownActions.Load(Action{Token: token, Location: location}.Key())
+			_, generated := mip.generatedTokens[token]
+			return known || generated
+		})
+		for _, token := range expanded {
+			mip.generatedTokens[token] = p.examples
+		}
+		mip.mutex.Unlock()
+		patterns = append(patterns, p.String())
+		candidates = append(candidates, expanded...)
+	}
+	return patterns, candidates
+}
+
+// MatchedTokens returns the best matched tokens recorded with RecordMatch, best first. The reward of a token is
+// weighted by its weight as a seed, the tokens whose derived requests stopped paying off falling behind.
+func (mip *MarkovInputProvider) MatchedTokens() []string {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	tokens := make([]string, 0, len(mip.matchedTokens))
+	weighted := make(map[string]float64, len(mip.matchedTokens))
+	for token, reward := range mip.matchedTokens {
+		tokens = append(tokens, token)
+		weighted[token] = reward * mip.seedWeight(token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if weighted[tokens[i]] != weighted[tokens[j]] {
+			return weighted[tokens[i]] > weighted[tokens[j]]
+		}
+		return tokens[i] < tokens[j]
+	})
+	if len(tokens) > patternTopK {
+		tokens = tokens[:patternTopK]
+	}
+	return tokens
+}
+
+// PatternExpansions returns every token described by the patterns of the best matched tokens, whether they were
+// expanded before or not, leaving out the tokens for which skip returns true
+func (mip *MarkovInputProvider) PatternExpansions(skip func(string) bool) []string {
+	tokens := make([]string, 0)
+	for _, p := range InducePatterns(mip.MatchedTokens()) {
+		tokens = append(tokens, p.Expand(patternMaxSet, skip)...)
+	}
+	return tokens
+}
+
+// RankTokens returns up to n of the FUZZ tokens the chain expects a positive reward from in the baseline state,
+// best first
+func (mip *MarkovInputProvider) RankTokens(tokens []string, n int) []string {
+	mip.mutex.Lock()
+	location := mip.keywordLocations["FUZZ"]
+	mip.mutex.Unlock()
+
+	actions := make([]Action, 0, len(tokens))
+	byKey := make(map[string]string, len(tokens))
+	for _, token := range tokens {
+		action := Action{Token: token, Location: location}
+		if _, ok := byKey[action.Key()]; !ok {
+			actions = append(actions, action)
+			byKey[action.Key()] = token
+		}
+	}
+	ranked := make([]string, 0, n)
+	for _, score := range mip.MarkovChain.RankActions(mip.baselineState, actions, n) {
+		ranked = append(ranked, byKey[score.Action])
+	}
+	return ranked
+}
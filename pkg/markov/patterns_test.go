@@ -0,0 +1,85 @@
+package markov
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInducePatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		tokens   []string
+		patterns []string
+		first    []string // first tokens of the expansion of the first pattern
+	}{
+		{"version", []string{"api_v1", "api_v2"}, []string{`api_v\d+`}, []string{"api_v0", "api_v3", "api_v4"}},
+		{"year", []string{"backup-2019.zip", "backup-2021.zip"}, []string{`backup-\d+\.zip`}, []string{"backup-2009.zip", "backup-2010.zip"}},
+		{"padded", []string{"img07", "img12"}, []string{`img\d{2}`}, []string{"img00", "img01"}},
+		{"letter", []string{"page_a", "page_c"}, []string{"page_[a-z]"}, []string{"page_b", "page_d"}},
+		{"two slots", []string{"v1_A", "v2_B"}, []string{`v\d+_[A-Z]`}, []string{"v0_A", "v0_B"}},
+		{"families", []string{"api_v1", "admin", "doc_v3", "api_v2", "doc_v4"}, []string{`api_v\d+`, `doc_v\d+`}, []string{"api_v0"}},
+		{"words", []string{"admin", "login", "backup"}, []string{}, nil},
+		{"numbers only", []string{"1", "2", "3"}, []string{}, nil},
+		{"ids", []string{"user12345678", "user87654321"}, []string{}, nil},
+		{"wide range", []string{"item1", "item5000"}, []string{}, nil},
+		{"mixed case", []string{"page_a", "page_B"}, []string{}, nil},
+		{"three slots", []string{"a1b1c1d", "a2b2c2d"}, []string{}, nil},
+		{"single token", []string{"api_v1"}, []string{}, nil},
+	}
+	for _, test := range tests {
+		patterns := InducePatterns(test.tokens)
+		got := make([]string, 0)
+		for _, p := range patterns {
+			got = append(got, p.String())
+		}
+		if !reflect.DeepEqual(got, test.patterns) {
+			t.Errorf("%s: expected the patterns %v, got %v", test.name, test.patterns, got)
+			continue
+		}
+		if len(test.first) > 0 {
+			expanded := patterns[0].Expand(len(test.first), nil)
+			if !reflect.DeepEqual(expanded, test.first) {
+				t.Errorf("%s: expected the expansion %v, got %v", test.name, test.first, expanded)
+			}
+		}
+	}
+}
+
+func TestPatternExpandBounded(t *testing.T) {
+	patterns := InducePatterns([]string{"v1_a", "v2_b"})
+	if len(patterns) != 1 {
+		t.Fatalf("Expected a pattern, got %d", len(patterns))
+	}
+	if size := patterns[0].size(); size != 13*26 {
+		t.Errorf("Expected the pattern to describe 338 tokens, got %d", size)
+	}
+	if expanded := patterns[0].Expand(50, nil); len(expanded) != 50 {
+		t.Errorf("Expected the expansion to stop at 50 tokens, got %d", len(expanded))
+	}
+	all := patterns[0].Expand(1000, func(token string) bool { return strings.HasSuffix(token, "z") })
+	if len(all) != 13*25-2 {
+		t.Errorf("Expected all the tokens but the examples and the skipped ones, got %d", len(all))
+	}
+}
+
+func TestPatternCandidates(t *testing.T) {
+	mip := newTestProvider("api_v1", "api_v2")
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("api_v3")}, &Response{StatusCode: 404, ContentLength: 139})
+	mip.RecordMatch("api_v1", 1)
+	if patterns, candidates := mip.PatternCandidates(10); len(patterns) != 0 || len(candidates) != 0 {
+		t.Errorf("Expected no pattern from a single match, got %v", patterns)
+	}
+	mip.RecordMatch("api_v2", 0.5)
+	patterns, candidates := mip.PatternCandidates(3)
+	if !reflect.DeepEqual(patterns, []string{`api_v\d+`}) || !reflect.DeepEqual(candidates, []string{"api_v0", "api_v4", "api_v5"}) {
+		t.Errorf("Expected the tokens the chain does not know yet, got %v and %v", patterns, candidates)
+	}
+	if seeds := mip.PatternSeeds("api_v4"); !reflect.DeepEqual(seeds, []string{"api_v1", "api_v2"}) {
+		t.Errorf("Expected the generated tokens to come from the matches of the pattern, got %v", seeds)
+	}
+	mip.RecordMatch("api_v4", 1)
+	if patterns, candidates := mip.PatternCandidates(3); len(patterns) != 0 || len(candidates) != 0 {
+		t.Errorf("Expected each pattern to be expanded once, got %v and %v", patterns, candidates)
+	}
+}
@@ -0,0 +1,45 @@
+package markov
+
+// Pending returns copies of up to n inputs of the current batch that were not sent yet, in the order they will be
+// sent, along with their decisions
+func (mip *MarkovInputProvider) Pending(n int) ([]map[string][]byte, []string) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	inputs := make([]map[string][]byte, 0)
+	decisions := make([]string, 0)
+	for i := mip.currentIndex; i < len(mip.currentBatch) && len(inputs) < n; i++ {
+		input := make(map[string][]byte, len(mip.currentBatch[i]))
+		for k, v := range mip.currentBatch[i] {
+			input[k] = make([]byte, len(v))
+			copy(input[k], v)
+		}
+		inputs = append(inputs, input)
+		decisions = append(decisions, mip.batchDecisions[i])
+	}
+	return inputs, decisions
+}
+
+// DropPending drops the inputs of the current batch that were not sent yet and for which match returns true, and
+// returns their number. The dropped inputs are left out of Total.
+func (mip *MarkovInputProvider) DropPending(match func(map[string][]byte) bool) int {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	kept := mip.currentIndex
+	for i := mip.currentIndex; i < len(mip.currentBatch); i++ {
+		if match(mip.currentBatch[i]) {
+			continue
+		}
+		mip.currentBatch[kept] = mip.currentBatch[i]
+		mip.batchPositions[kept] = mip.batchPositions[i]
+		mip.batchDecisions[kept] = mip.batchDecisions[i]
+		kept++
+	}
+	dropped := len(mip.currentBatch) - kept
+	mip.currentBatch = mip.currentBatch[:kept]
+	mip.batchPositions = mip.batchPositions[:kept]
+	mip.batchDecisions = mip.batchDecisions[:kept]
+	mip.dropped += dropped
+	return dropped
+}
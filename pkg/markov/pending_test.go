@@ -0,0 +1,45 @@
+package markov
+
+import (
+	"testing"
+)
+
+func TestDropPending(t *testing.T) {
+	mip := newTestProvider("admin", "admin.bak", "login", "login.bak", "index")
+	mip.Next()
+	mip.Value()
+
+	dropped := mip.DropPending(func(input map[string][]byte) bool {
+		return len(input["FUZZ"]) > 4 && string(input["FUZZ"][len(input["FUZZ"])-4:]) == ".bak"
+	})
+	if dropped != 2 {
+		t.Errorf("Expected 2 inputs to be dropped, got %d", dropped)
+	}
+	if total := mip.Total(); total != 3 {
+		t.Errorf("Expected a total of 3 inputs, got %d", total)
+	}
+
+	inputs, decisions := mip.Pending(10)
+	expected := []string{"login", "index"}
+	if len(inputs) != len(expected) {
+		t.Fatalf("Expected %d pending inputs, got %d", len(expected), len(inputs))
+	}
+	for i, e := range expected {
+		if string(inputs[i]["FUZZ"]) != e || decisions[i] != "wordlist order" {
+			t.Errorf("Expected %s in the wordlist order, got %s (%s)", e, inputs[i]["FUZZ"], decisions[i])
+		}
+	}
+	// The pending inputs are copies
+	inputs[0]["FUZZ"][0] = 'X'
+	if mip.Next(); string(mip.Value()["FUZZ"]) != "login" {
+		t.Errorf("Expected the pending inputs not to alias the batch")
+	}
+	if mip.Next(); mip.Position() != 5 {
+		t.Errorf("Expected index at position 5, got %d", mip.Position())
+	}
+
+	mip.Reset()
+	if total := mip.Total(); total != 5 {
+		t.Errorf("Expected the dropped inputs to be counted again after a reset, got %d", total)
+	}
+}
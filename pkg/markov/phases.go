@@ -0,0 +1,110 @@
+package markov
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Phases of the provider with SetLearnPhase
+const (
+	// PhaseLearn sends the inputs in wordlist order, the chain only learning from their responses
+	PhaseLearn = "learn"
+	// PhaseExploit sends the rest of the inputs in the order the chain ranked them once at the end of the learn phase
+	PhaseExploit = "exploit"
+)
+
+// ParsePhases returns the share of the inputs of the learn phase from phases like "learn=20%,exploit=80%". The shares
+// must add up to 100%, either of them can be left out. An empty string disables the phases and returns 0.
+func ParsePhases(phases string) (float64, error) {
+	if phases == "" {
+		return 0, nil
+	}
+	shares := make(map[string]float64)
+	for _, part := range strings.Split(phases, ",") {
+		nameShare := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(nameShare) != 2 || (nameShare[0] != PhaseLearn && nameShare[0] != PhaseExploit) {
+			return 0, fmt.Errorf("invalid phase %q, expected %s=<percent> or %s=<percent>", part, PhaseLearn, PhaseExploit)
+		}
+		if _, ok := shares[nameShare[0]]; ok {
+			return 0, fmt.Errorf("phase %s given twice", nameShare[0])
+		}
+		share, err := strconv.ParseFloat(strings.TrimSuffix(nameShare[1], "%"), 64)
+		if err != nil || share < 0 || share > 100 {
+			return 0, fmt.Errorf("invalid share of the %s phase: %s", nameShare[0], nameShare[1])
+		}
+		shares[nameShare[0]] = share
+	}
+	learn, hasLearn := shares[PhaseLearn]
+	exploit, hasExploit := shares[PhaseExploit]
+	if !hasLearn {
+		learn = 100 - exploit
+	}
+	if hasExploit && learn+exploit != 100 {
+		return 0, fmt.Errorf("the shares of the phases add up to %g%%, expected 100%%", learn+exploit)
+	}
+	if learn == 0 || learn == 100 {
+		return 0, fmt.Errorf("the learn phase must take between 0%% and 100%% of the inputs, got %g%%", learn)
+	}
+	return learn / 100, nil
+}
+
+// SetLearnPhase runs the provider in two phases: the given share of the inputs is sent in wordlist order while the
+// chain learns from the responses, and the rest of the inputs is then ranked once and sent in that order, without
+// reordering the batches. 0 reorders each batch from the start.
+func (mip *MarkovInputProvider) SetLearnPhase(share float64) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.learnShare = share
+	mip.startPhases()
+}
+
+// startPhases starts the learn phase when the phases are enabled. Must be called with the mutex held.
+func (mip *MarkovInputProvider) startPhases() {
+	mip.phase = ""
+	mip.newPhase = ""
+	if mip.learnShare > 0 {
+		mip.phase = PhaseLearn
+		mip.newPhase = PhaseLearn
+	}
+}
+
+// Phase returns the current phase, PhaseLearn or PhaseExploit, empty if the phases are disabled
+func (mip *MarkovInputProvider) Phase() string {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	return mip.phase
+}
+
+// NewPhase returns the phase entered since the last call, empty if the phase did not change
+func (mip *MarkovInputProvider) NewPhase() string {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	phase := mip.newPhase
+	mip.newPhase = ""
+	return phase
+}
+
+// learnInputs returns the number of inputs of the learn phase. Must be called with the mutex held.
+func (mip *MarkovInputProvider) learnInputs() int {
+	if mip.OriginalProvider == nil {
+		return 0
+	}
+	return int(mip.learnShare * float64(mip.OriginalProvider.Total()))
+}
+
+// startExploitPhase ends the learn phase: the rest of the inputs is read in the current batch and ranked once, the
+// batch being sent in that order to the end. Must be called with the mutex held.
+func (mip *MarkovInputProvider) startExploitPhase() {
+	mip.phase = PhaseExploit
+	mip.newPhase = PhaseExploit
+	learned := mip.read
+	mip.fillBatch(-1)
+	ranked := mip.rankBatch(0, len(mip.currentBatch))
+	mip.deprioritizeStacks(ranked)
+	log.Printf("Markov exploit phase after %d inputs of learning: ranked the %d remaining inputs", learned, len(mip.currentBatch)-mip.currentIndex)
+}
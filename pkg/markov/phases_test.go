@@ -0,0 +1,91 @@
+package markov
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParsePhases(t *testing.T) {
+	for phases, expected := range map[string]float64{"": 0, "learn=20%,exploit=80%": 0.2, "learn=30%": 0.3, "exploit=75%": 0.25, "learn=10": 0.1} {
+		if share, err := ParsePhases(phases); err != nil || share != expected {
+			t.Errorf("%q: expected a learn share of %.2f, got %.2f (%v)", phases, expected, share, err)
+		}
+	}
+	for _, phases := range []string{"learn=20%,exploit=70%", "learn=0%", "exploit=100%", "learn=abc", "warmup=20%", "learn=20%,learn=30%"} {
+		if _, err := ParsePhases(phases); err == nil {
+			t.Errorf("%q: expected an error", phases)
+		}
+	}
+}
+
+func TestLearnThenExploit(t *testing.T) {
+	words := make([]string, 1000)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%03d", i)
+	}
+	words[900] = "admin-panel"
+	mip := newTestProvider(words...)
+	mip.SetLearnPhase(0.2)
+	if phase := mip.NewPhase(); phase != PhaseLearn {
+		t.Fatalf("Expected to start in the learn phase, got %q", phase)
+	}
+	found := State{CodeClass: "2xx", SizeBucket: QuantizeSize(2000)}
+	// Known to the chain from the start, but not reordered while learning
+	mip.AddTransition(mip.baselineState, Action{Token: "w100"}, found, 1.0)
+
+	sent := 0
+	exploitAt := -1
+	var ranked []string
+	for mip.Next() {
+		token := string(mip.Value()["FUZZ"])
+		sent++
+		if phase := mip.NewPhase(); phase == PhaseExploit {
+			if exploitAt >= 0 {
+				t.Fatalf("Expected a single re-rank, got a second one at request %d", sent)
+			}
+			exploitAt = sent
+			pending, _ := mip.Pending(len(words))
+			for _, input := range pending {
+				ranked = append(ranked, string(input["FUZZ"]))
+			}
+		}
+		if exploitAt < 0 {
+			if token != words[sent-1] {
+				t.Fatalf("Expected the wordlist order in the learn phase, got %s at request %d", token, sent)
+			}
+			if sent == 150 {
+				// Learned before the end of the learn phase
+				for i := 0; i < 20; i++ {
+					mip.AddTransition(mip.baselineState, Action{Token: "admin-panel"}, found, 1.0)
+				}
+			}
+			continue
+		}
+		if sent == exploitAt {
+			if token != "admin-panel" {
+				t.Errorf("Expected the learned word first in the exploit phase, got %s", token)
+			}
+			continue
+		}
+		// Learned after the re-rank, the order does not change
+		mip.AddTransition(mip.baselineState, Action{Token: "w999"}, found, 1.0)
+		mip.RefreshBatch()
+		if expected := ranked[sent-exploitAt-1]; token != expected {
+			t.Fatalf("Expected the order of the re-rank in the exploit phase, got %s at request %d instead of %s", token, sent, expected)
+		}
+	}
+	if exploitAt != 201 {
+		t.Errorf("Expected the exploit phase to start at request 201, got %d", exploitAt)
+	}
+	if sent != len(words) {
+		t.Errorf("Expected every word to be sent once, got %d requests", sent)
+	}
+	if mip.Phase() != PhaseExploit {
+		t.Errorf("Expected to end in the exploit phase, got %q", mip.Phase())
+	}
+
+	mip.Reset()
+	if mip.Phase() != PhaseLearn || mip.NewPhase() != PhaseLearn {
+		t.Errorf("Expected Reset to start the learn phase again")
+	}
+}
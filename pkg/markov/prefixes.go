@@ -0,0 +1,100 @@
+package markov
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// PrefixMinSupport is the number of responses a path prefix needs before being suggested
+	PrefixMinSupport = 10
+	// prefixLimit is the number of path prefixes the rewards are tracked for, the prefixes first seen past it being
+	// left out to bound the memory
+	prefixLimit = 1 << 12
+)
+
+// PrefixSuggestion is a path prefix whose tokens get a high mean reward, suggested as a recursion root
+type PrefixSuggestion struct {
+	Prefix string
+	Mean   float64
+	Count  int
+}
+
+// String returns the suggestion in a human readable format
+func (p PrefixSuggestion) String() string {
+	return fmt.Sprintf("%s/ (mean reward %.2f over %d requests)", p.Prefix, p.Mean, p.Count)
+}
+
+// pathPrefix returns the first path segment of a token, empty for the tokens of a single segment
+func pathPrefix(token string) string {
+	token = strings.TrimPrefix(token, "/")
+	i := strings.Index(token, "/")
+	if i <= 0 {
+		return ""
+	}
+	return token[:i]
+}
+
+// SetPrefixThreshold sets the mean reward the tokens of a path prefix need to get for the prefix to be suggested as a
+// recursion root, with PrefixMinSupport responses. 0 disables the suggestions.
+func (mip *MarkovInputProvider) SetPrefixThreshold(threshold float64) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.prefixThreshold = threshold
+}
+
+// recordPrefixReward adds the reward of a response to the path prefix of its action, queueing the prefix as a
+// suggestion the first time its mean reward reaches the threshold. Must be called with the mutex held.
+func (mip *MarkovInputProvider) recordPrefixReward(action Action, reward float64) {
+	if mip.prefixThreshold <= 0 || (action.Location != "" && action.Location != "path") {
+		return
+	}
+	prefix := pathPrefix(action.Token)
+	if prefix == "" {
+		return
+	}
+	if mip.prefixRewards == nil {
+		mip.prefixRewards = make(map[string]*RewardStat)
+	}
+	stat, ok := mip.prefixRewards[prefix]
+	if !ok {
+		if len(mip.prefixRewards) >= prefixLimit {
+			return
+		}
+		stat = &RewardStat{}
+		mip.prefixRewards[prefix] = stat
+	}
+	stat.Add(reward)
+	if stat.Count < PrefixMinSupport || stat.Mean < mip.prefixThreshold || mip.suggestedPrefixes[prefix] {
+		return
+	}
+	if mip.suggestedPrefixes == nil {
+		mip.suggestedPrefixes = make(map[string]bool)
+	}
+	mip.suggestedPrefixes[prefix] = true
+	s := PrefixSuggestion{Prefix: prefix, Mean: stat.Mean, Count: stat.Count}
+	mip.pendingPrefixes = append(mip.pendingPrefixes, s)
+	mip.prefixSuggestions = append(mip.prefixSuggestions, s)
+}
+
+// NewPrefixSuggestions returns the path prefixes suggested since the last call, each prefix being suggested once
+func (mip *MarkovInputProvider) NewPrefixSuggestions() []PrefixSuggestion {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	suggestions := mip.pendingPrefixes
+	mip.pendingPrefixes = nil
+	return suggestions
+}
+
+// PrefixSuggestions returns all the path prefixes suggested, by decreasing mean reward
+func (mip *MarkovInputProvider) PrefixSuggestions() []PrefixSuggestion {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	suggestions := append([]PrefixSuggestion{}, mip.prefixSuggestions...)
+	sort.SliceStable(suggestions, func(i, j int) bool { return suggestions[i].Mean > suggestions[j].Mean })
+	return suggestions
+}
@@ -0,0 +1,54 @@
+package markov
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPathPrefix(t *testing.T) {
+	tests := map[string]string{"api/users": "api", "/api/v1/users": "api", "admin": "", "/admin": "", "api/": "api"}
+	for token, expected := range tests {
+		if got := pathPrefix(token); got != expected {
+			t.Errorf("%s: expected %q, got %q", token, expected, got)
+		}
+	}
+}
+
+func TestPrefixSuggestions(t *testing.T) {
+	mip := newTestProvider("a")
+	mip.SetPrefixThreshold(1.0)
+	fired := 0
+	for i := 0; i < 60; i++ {
+		// The words under api/ are found half the time, the ones under static/ never
+		status := int64(404)
+		if i%2 == 0 {
+			status = 200
+		}
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(fmt.Sprintf("api/word%d", i))}, &Response{StatusCode: status})
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(fmt.Sprintf("static/word%d", i))}, &Response{StatusCode: 404})
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(fmt.Sprintf("word%d", i))}, &Response{StatusCode: 200})
+		for _, s := range mip.NewPrefixSuggestions() {
+			fired++
+			if s.Prefix != "api" || s.Count != PrefixMinSupport {
+				t.Errorf("Expected the api prefix to be suggested with %d requests, got %s", PrefixMinSupport, s)
+			}
+		}
+	}
+	if fired != 1 {
+		t.Errorf("Expected the suggestion to fire exactly once, got %d", fired)
+	}
+	if all := mip.PrefixSuggestions(); len(all) != 1 || all[0].Prefix != "api" {
+		t.Errorf("Expected the api prefix in the suggestions, got %v", all)
+	}
+}
+
+func TestPrefixSuggestionsDisabled(t *testing.T) {
+	mip := newTestProvider("a")
+	mip.SetPrefixThreshold(0)
+	for i := 0; i < 20; i++ {
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(fmt.Sprintf("api/word%d", i))}, &Response{StatusCode: 200})
+	}
+	if got := mip.NewPrefixSuggestions(); len(got) != 0 {
+		t.Errorf("Expected no suggestions with the threshold disabled, got %v", got)
+	}
+}
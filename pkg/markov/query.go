@@ -0,0 +1,24 @@
+package markov
+
+// QueryValueCandidates are the values sent in addition to the wordlist when the fuzz keyword is the value of a query
+// parameter: the booleans and numbers of the feature flags, privileged names and a path traversal
+var QueryValueCandidates = []string{"true", "false", "1", "0", "-1", "yes", "on", "admin", "debug", "null", "../", "../../../../etc/passwd"}
+
+// ValueCandidates returns the QueryValueCandidates the chain has not learned about yet when FUZZ is the value of a
+// query parameter, and none when it is the name of the parameter or anywhere else
+func (mip *MarkovInputProvider) ValueCandidates() []string {
+	mip.mutex.Lock()
+	location := mip.keywordLocations["FUZZ"]
+	mip.mutex.Unlock()
+
+	if location != "query-value" {
+		return nil
+	}
+	candidates := make([]string, 0, len(QueryValueCandidates))
+	for _, value := range QueryValueCandidates {
+		if _, known := mip.MarkovChain.knownActions.Load(Action{Token: value, Location: location}.Key()); !known {
+			candidates = append(candidates, value)
+		}
+	}
+	return candidates
+}
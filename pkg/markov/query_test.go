@@ -0,0 +1,64 @@
+package markov
+
+import "testing"
+
+func TestQueryPositionsLearnedApart(t *testing.T) {
+	name := newTestProvider("debug")
+	name.SetKeywordLocations(map[string]string{"FUZZ": "query-name"})
+	value := newTestProvider("debug")
+	value.SetKeywordLocations(map[string]string{"FUZZ": "query-value"})
+
+	name.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("debug")}, &Response{StatusCode: 200, ContentLength: 2000})
+	value.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("debug")}, &Response{StatusCode: 404, ContentLength: 139})
+
+	if len(nextStates(name, "query-name:debug")) != 1 || len(nextStates(name, "query-value:debug")) != 0 {
+		t.Errorf("Expected the parameter name to be keyed by its position")
+	}
+	if len(nextStates(value, "query-value:debug")) != 1 || len(nextStates(value, "query-name:debug")) != 0 {
+		t.Errorf("Expected the parameter value to be keyed by its position")
+	}
+}
+
+func TestValueCandidates(t *testing.T) {
+	for location, expected := range map[string]int{"query-value": len(QueryValueCandidates), "query-name": 0, "path": 0, "": 0} {
+		mip := newTestProvider("a")
+		mip.SetKeywordLocations(map[string]string{"FUZZ": location})
+		if got := len(mip.ValueCandidates()); got != expected {
+			t.Errorf("%q: expected %d value candidates, got %d", location, expected, got)
+		}
+	}
+
+	// The values the chain learned about are not proposed again
+	mip := newTestProvider("a")
+	mip.SetKeywordLocations(map[string]string{"FUZZ": "query-value"})
+	mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("true")}, &Response{StatusCode: 200})
+	for _, value := range mip.ValueCandidates() {
+		if value == "true" {
+			t.Errorf("Expected the known value to be left out of the candidates")
+		}
+	}
+}
+
+func TestReflectionReward(t *testing.T) {
+	// The bonus is given in every position, not only to the query parameter values
+	for _, location := range []string{"query-value", "query-name", "path", ""} {
+		for _, reflected := range []bool{true, false} {
+			plain := newTestProvider("a")
+			plain.SetKeywordLocations(map[string]string{"FUZZ": location})
+			reflecting := newTestProvider("a")
+			reflecting.SetKeywordLocations(map[string]string{"FUZZ": location})
+			reflecting.SetReflectionReward(0.5)
+
+			input := map[string][]byte{"FUZZ": []byte("x7Kq2")}
+			base := plain.UpdateWithResponse(input, &Response{StatusCode: 200, ContentLength: 2000, Reflected: reflected})
+			reward := reflecting.UpdateWithResponse(input, &Response{StatusCode: 200, ContentLength: 2000, Reflected: reflected})
+			expected := 0.0
+			if reflected {
+				expected = 0.5
+			}
+			if got := reward - base; got != expected {
+				t.Errorf("%q reflected %t: expected a reflection bonus of %.2f, got %.2f", location, reflected, expected, got)
+			}
+		}
+	}
+}
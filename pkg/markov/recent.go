@@ -0,0 +1,86 @@
+package markov
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// RecentTransitionLimit is the number of the latest transitions kept for GetRecentTransitions
+	RecentTransitionLimit = 256
+	// TransitionValueCap is the number of bytes of each keyword value kept in the recent transitions
+	TransitionValueCap = 128
+)
+
+// String returns the transition on a single line, for the debug log
+func (t Transition) String() string {
+	keywords := make([]string, 0, len(t.Inputs))
+	for k := range t.Inputs {
+		keywords = append(keywords, k)
+	}
+	sort.Strings(keywords)
+	inputs := make([]string, len(keywords))
+	for i, k := range keywords {
+		inputs[i] = fmt.Sprintf("%s=%s", k, t.Inputs[k])
+	}
+	return fmt.Sprintf("#%d %s [%s] %s -> %s, reward %.4f at %s", t.Position, t.Action.Key(), strings.Join(inputs, " "),
+		t.FromState.Hash(), t.ToState.Hash(), t.Reward, t.Timestamp.Format(time.RFC3339))
+}
+
+// capInputs returns a copy of the keyword values of a request, each capped to TransitionValueCap bytes
+func capInputs(inputs map[string][]byte) map[string][]byte {
+	capped := make(map[string][]byte, len(inputs))
+	for k, v := range inputs {
+		if len(v) > TransitionValueCap {
+			v = v[:TransitionValueCap]
+		}
+		capped[k] = append([]byte{}, v...)
+	}
+	return capped
+}
+
+// recordRecent adds a transition to the ring buffer of the latest transitions. Must be called with the mutex held.
+func (mc *MarkovChain) recordRecent(transition Transition) {
+	if len(mc.recent) < RecentTransitionLimit {
+		mc.recent = append(mc.recent, transition)
+		return
+	}
+	mc.recent[mc.recentNext] = transition
+	mc.recentNext = (mc.recentNext + 1) % RecentTransitionLimit
+}
+
+// GetRecentTransitions returns up to k of the latest transitions, the most recent first
+func (mc *MarkovChain) GetRecentTransitions(k int) []Transition {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	if k > len(mc.recent) {
+		k = len(mc.recent)
+	}
+	transitions := make([]Transition, 0, k)
+	for i := 1; i <= k; i++ {
+		transitions = append(transitions, mc.recent[(mc.recentNext-i+len(mc.recent))%len(mc.recent)])
+	}
+	return transitions
+}
+
+// RecentTransitionsOf returns up to n of the latest transitions of a FUZZ token, the most recent first, telling which
+// requests taught the chain its score
+func (mip *MarkovInputProvider) RecentTransitionsOf(token string, n int) []Transition {
+	mip.mutex.Lock()
+	key := Action{Token: token, Location: mip.keywordLocations["FUZZ"]}.Key()
+	mip.mutex.Unlock()
+
+	transitions := make([]Transition, 0, n)
+	for _, t := range mip.MarkovChain.GetRecentTransitions(RecentTransitionLimit) {
+		if len(transitions) == n {
+			break
+		}
+		if t.Action.Key() == key {
+			transitions = append(transitions, t)
+		}
+	}
+	return transitions
+}
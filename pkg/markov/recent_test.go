@@ -0,0 +1,67 @@
+package markov
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestRecentTransitionsRing(t *testing.T) {
+	mip := newTestProvider("a")
+	for i := 1; i <= RecentTransitionLimit+44; i++ {
+		word := fmt.Sprintf("word%d", i)
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(word)}, &Response{StatusCode: 404, Position: i})
+	}
+
+	recent := mip.MarkovChain.GetRecentTransitions(RecentTransitionLimit * 2)
+	if len(recent) != RecentTransitionLimit {
+		t.Fatalf("Expected the %d latest transitions to be kept, got %d", RecentTransitionLimit, len(recent))
+	}
+	for i, tr := range recent {
+		position := RecentTransitionLimit + 44 - i
+		if tr.Position != position || string(tr.Inputs["FUZZ"]) != fmt.Sprintf("word%d", position) {
+			t.Fatalf("Expected the transition %d to be the request %d, got %s", i, position, tr)
+		}
+		if tr.Timestamp.IsZero() {
+			t.Fatalf("Expected the transition to be timestamped")
+		}
+	}
+	if got := mip.MarkovChain.GetRecentTransitions(3); len(got) != 3 || got[0].Position != RecentTransitionLimit+44 {
+		t.Errorf("Expected the 3 latest transitions, got %v", got)
+	}
+}
+
+func TestRecentTransitionsCapValues(t *testing.T) {
+	mip := newTestProvider("a")
+	long := bytes.Repeat([]byte("x"), TransitionValueCap*4)
+	inputs := map[string][]byte{"FUZZ": []byte("admin"), "BODY": long}
+	mip.UpdateWithResponse(inputs, &Response{StatusCode: 200, Position: 7})
+	long[0] = 'y'
+
+	recent := mip.MarkovChain.GetRecentTransitions(1)
+	if len(recent) != 1 {
+		t.Fatalf("Expected a transition, got %d", len(recent))
+	}
+	body := recent[0].Inputs["BODY"]
+	if len(body) != TransitionValueCap || body[0] != 'x' {
+		t.Errorf("Expected a copy of the value capped to %d bytes, got %d bytes", TransitionValueCap, len(body))
+	}
+	if string(recent[0].Inputs["FUZZ"]) != "admin" {
+		t.Errorf("Expected the short values to be kept whole, got %s", recent[0].Inputs["FUZZ"])
+	}
+}
+
+func TestRecentTransitionsOf(t *testing.T) {
+	mip := newTestProvider("a")
+	for i := 1; i <= 10; i++ {
+		word := "other"
+		if i%3 == 0 {
+			word = "admin"
+		}
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(word)}, &Response{StatusCode: 200, Position: i})
+	}
+	got := mip.RecentTransitionsOf("admin", 2)
+	if len(got) != 2 || got[0].Position != 9 || got[1].Position != 6 {
+		t.Errorf("Expected the requests 9 and 6 to have taught the chain, got %v", got)
+	}
+}
@@ -0,0 +1,105 @@
+package markov
+
+import (
+	"regexp"
+)
+
+// The reward of a response is calculated from an ordered decision table. The base tiers are mutually exclusive,
+// the first one matching the status code gives the base reward. The bonuses are added on top of it.
+//
+//	tier    success        2xx                                  3.0
+//	tier    method         405, 501                             2.2
+//	tier    server-error   5xx                                  2.6
+//	tier    redirect       3xx                                  2.0
+//	tier    auth           401, 403                             1.8
+//	tier    client-error   any other 4xx                        0.0
+//	tier    other          1xx and non-standard status codes    1.0
+//	bonus   new-content    4xx with a state and body differing from the baseline  +0.5
+//	bonus   cert-mismatch  TLS certificate not covering the host, state differing from the baseline  +0.5
+//	bonus   structured-probing  400 reporting a parse error of the JSON body the fuzzed field is in  +0.3
+//
+// The responses cut off before the end of their body, incomplete, never differ from the baseline.
+
+// RewardRule is a rule of the reward decision table
+type RewardRule struct {
+	Name  string
+	Tier  bool    // base tiers are mutually exclusive, bonuses are added to the tier reward
+	Delta float64 // reward given when the rule matches
+	match func(in rewardInput) bool
+}
+
+// rewardInput is the response along with its comparison to the baseline, as seen by the reward rules
+type rewardInput struct {
+	status     int64
+	certError  bool
+	newState   bool // the state of the response differs from the baseline state
+	newContent bool // both the state and the body hash differ from the baseline
+	parseError bool // the fuzzed value is a field of a JSON body, and the body of the response reports a parse error
+	newCert    bool // the certificate differs from the ones of the default virtual host
+	newStatus  bool // the status differs from the ones of the default virtual host
+	newSize    bool // the size bucket differs from the ones of the default virtual host with the same status
+}
+
+// parseErrorPattern matches the error messages of the usual JSON parsers for a malformed body
+var parseErrorPattern = regexp.MustCompile(`(?i)(pars(e|ing) error|syntax ?error|malformed|unexpected (token|character|end)|invalid (character|json|number|literal|value)|cannot (unmarshal|deserialize))`)
+
+var rewardRules = []RewardRule{
+	{Name: "success", Tier: true, Delta: 3.0, match: func(in rewardInput) bool { return in.status >= 200 && in.status < 300 }},
+	{Name: "method", Tier: true, Delta: 2.2, match: func(in rewardInput) bool { return in.status == 405 || in.status == 501 }},
+	{Name: "server-error", Tier: true, Delta: 2.6, match: func(in rewardInput) bool { return in.status >= 500 && in.status < 600 }},
+	{Name: "redirect", Tier: true, Delta: 2.0, match: func(in rewardInput) bool { return in.status >= 300 && in.status < 400 }},
+	{Name: "auth", Tier: true, Delta: 1.8, match: func(in rewardInput) bool { return in.status == 401 || in.status == 403 }},
+	{Name: "client-error", Tier: true, Delta: 0.0, match: func(in rewardInput) bool { return in.status >= 400 && in.status < 500 }},
+	{Name: "other", Tier: true, Delta: 1.0, match: func(in rewardInput) bool { return true }},
+	{Name: "new-content", Delta: 0.5, match: func(in rewardInput) bool { return in.status >= 400 && in.status < 500 && in.newContent }},
+	{Name: "cert-mismatch", Delta: 0.5, match: func(in rewardInput) bool { return in.certError && in.newState }},
+	{Name: "structured-probing", Delta: 0.3, match: func(in rewardInput) bool { return in.status == 400 && in.parseError }},
+}
+
+// EvaluateReward returns the reward of a response compared to the baseline, along with the rules of the decision
+// table that were applied to get it, for debugging
+func EvaluateReward(resp *Response, baselineState State, baselineSizeHash string) (float64, []RewardRule) {
+	return evaluateReward(rewardRules, resp, baselineState, baselineSizeHash)
+}
+
+// evaluateReward returns the reward of a response compared to the baseline from a decision table
+func evaluateReward(rules []RewardRule, resp *Response, baselineState State, baselineSizeHash string) (float64, []RewardRule) {
+	currentState := GetStateFromResponseFromResponseStruct(resp, baselineState.Depth)
+	// The state of a response cut off is not compared to the baseline, its size and counts being meaningless
+	newState := !resp.Incomplete && currentState.Hash() != baselineState.Hash()
+	bodyHash := resp.BodyHash
+	if bodyHash == "" {
+		bodyHash = GetSizeHash(resp.Data)
+	}
+	in := rewardInput{
+		status:     resp.StatusCode,
+		certError:  resp.CertMismatch,
+		newState:   newState,
+		newContent: newState && bodyHash != baselineSizeHash,
+		parseError: resp.JSONField && parseErrorPattern.Match(resp.Data),
+	}
+
+	return applyRewardRules(rules, in)
+}
+
+// applyRewardRules returns the reward of the rules of a decision table matching the input, and the rules applied
+func applyRewardRules(rules []RewardRule, in rewardInput) (float64, []RewardRule) {
+	reward := 0.0
+	applied := make([]RewardRule, 0)
+	tierFound := false
+	for _, rule := range rules {
+		if (rule.Tier && tierFound) || !rule.match(in) {
+			continue
+		}
+		tierFound = tierFound || rule.Tier
+		reward += rule.Delta
+		applied = append(applied, rule)
+	}
+	return reward, applied
+}
+
+// CalculateRewardFromResponseStruct determines the reward of a response compared to the baseline
+func CalculateRewardFromResponseStruct(resp *Response, baselineState State, baselineSizeHash string) float64 {
+	reward, _ := EvaluateReward(resp, baselineState, baselineSizeHash)
+	return reward
+}
@@ -0,0 +1,133 @@
+package markov
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRewardTruthTable(t *testing.T) {
+	baseline := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139), Depth: 0}
+	baselineHash := GetSizeHash([]byte("404 not found"))
+
+	// Rewards with a body equal to the baseline, a different body, and a different body with a certificate mismatch
+	tests := []struct {
+		status   int64
+		equal    float64
+		differ   float64
+		mismatch float64
+	}{
+		{100, 1.0, 1.0, 1.5},
+		{200, 3.0, 3.0, 3.5},
+		{204, 3.0, 3.0, 3.5},
+		{301, 2.0, 2.0, 2.5},
+		{400, 0.0, 0.5, 1.0},
+		{401, 1.8, 2.3, 2.8},
+		{403, 1.8, 2.3, 2.8},
+		{404, 0.0, 0.5, 1.0},
+		{405, 2.2, 2.7, 3.2},
+		{429, 0.0, 0.5, 1.0},
+		{500, 2.6, 2.6, 3.1},
+		{501, 2.2, 2.2, 2.7},
+		{503, 2.6, 2.6, 3.1},
+		{0, 1.0, 1.0, 1.5},
+		{999, 1.0, 1.0, 1.5},
+	}
+	for _, test := range tests {
+		equal := &Response{StatusCode: test.status, ContentLength: 139, Data: []byte("404 not found")}
+		differ := &Response{StatusCode: test.status, ContentLength: 2000, Data: []byte("something else")}
+		mismatch := &Response{StatusCode: test.status, ContentLength: 2000, Data: []byte("something else"), CertMismatch: true}
+		for _, c := range []struct {
+			name     string
+			resp     *Response
+			expected float64
+		}{{"equal", equal, test.equal}, {"differ", differ, test.differ}, {"mismatch", mismatch, test.mismatch}} {
+			if r := CalculateRewardFromResponseStruct(c.resp, baseline, baselineHash); math.Abs(r-c.expected) > 1e-9 {
+				t.Errorf("%d with %s body: reward %f, want %f", test.status, c.name, r, c.expected)
+			}
+		}
+	}
+}
+
+func TestEvaluateRewardRules(t *testing.T) {
+	baseline := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139), Depth: 0}
+	resp := &Response{StatusCode: 403, ContentLength: 2000, Data: []byte("forbidden"), CertMismatch: true}
+	reward, rules := EvaluateReward(resp, baseline, GetSizeHash([]byte("404 not found")))
+	names := make([]string, 0)
+	sum := 0.0
+	tiers := 0
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+		sum += rule.Delta
+		if rule.Tier {
+			tiers++
+		}
+	}
+	if strings.Join(names, ",") != "auth,new-content,cert-mismatch" {
+		t.Errorf("Unexpected rules applied: %v", names)
+	}
+	if tiers != 1 || math.Abs(sum-reward) > 1e-9 {
+		t.Errorf("Expected exactly one tier and the rule deltas to add up to the reward %f, got %d tiers and %f", reward, tiers, sum)
+	}
+
+	// A precomputed body hash is used instead of hashing the data
+	resp = &Response{StatusCode: 404, ContentLength: 2000, BodyHash: GetSizeHash([]byte("404 not found"))}
+	if reward, rules := EvaluateReward(resp, baseline, GetSizeHash([]byte("404 not found"))); reward != 0 || len(rules) != 1 {
+		t.Errorf("Expected the body hash to match the baseline, got %f from %v", reward, rules)
+	}
+}
+
+func TestIncompleteResponseReward(t *testing.T) {
+	baseline := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139), Depth: 0}
+	resp := &Response{StatusCode: 403, ContentLength: 2000, Data: []byte("forb"), CertMismatch: true, Incomplete: true}
+	reward, rules := EvaluateReward(resp, baseline, GetSizeHash([]byte("404 not found")))
+	if math.Abs(reward-1.8) > 1e-9 || len(rules) != 1 || rules[0].Name != "auth" {
+		t.Errorf("Expected only the status tier for an incomplete response, got %f from %v", reward, rules)
+	}
+}
+
+func TestStructuredProbingReward(t *testing.T) {
+	baseline := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139), Depth: 0}
+	baselineHash := GetSizeHash([]byte("404 not found"))
+	parseError := []byte(`{"error": "JSON parse error: Unexpected character ('a' (code 97))"}`)
+	tests := []struct {
+		name     string
+		resp     *Response
+		expected float64
+	}{
+		{"parse error of a JSON field", &Response{StatusCode: 400, ContentLength: 139, Data: parseError, JSONField: true}, 0.3},
+		{"parse error outside of a JSON field", &Response{StatusCode: 400, ContentLength: 139, Data: parseError}, 0.0},
+		{"other 400 of a JSON field", &Response{StatusCode: 400, ContentLength: 139, Data: []byte(`{"error": "id is required"}`), JSONField: true}, 0.0},
+		{"parse error with another status", &Response{StatusCode: 422, ContentLength: 139, Data: parseError, JSONField: true}, 0.0},
+	}
+	for _, test := range tests {
+		if r := CalculateRewardFromResponseStruct(test.resp, baseline, baselineHash); math.Abs(r-test.expected) > 1e-9 {
+			t.Errorf("%s: reward %f, want %f", test.name, r, test.expected)
+		}
+	}
+}
+
+func TestMethodRewardTier(t *testing.T) {
+	baseline := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139), Depth: 0}
+	baselineHash := GetSizeHash([]byte("404 not found"))
+	for _, status := range []int64{405, 501} {
+		resp := &Response{StatusCode: status, ContentLength: 139, Data: []byte("404 not found")}
+		reward, rules := EvaluateReward(resp, baseline, baselineHash)
+		if len(rules) != 1 || rules[0].Name != "method" || math.Abs(reward-2.2) > 1e-9 {
+			t.Errorf("%d: expected the method tier alone, got %f from %v", status, reward, rules)
+		}
+		if class := codeClass(status); class != CodeClassMethod {
+			t.Errorf("%d: expected the %s code class, got %s", status, CodeClassMethod, class)
+		}
+	}
+	// Below the success tier and above the plain client errors
+	success, _ := EvaluateReward(&Response{StatusCode: 200, ContentLength: 139}, baseline, baselineHash)
+	notFound, _ := EvaluateReward(&Response{StatusCode: 404, ContentLength: 139, Data: []byte("404 not found")}, baseline, baselineHash)
+	method, _ := EvaluateReward(&Response{StatusCode: 405, ContentLength: 139, Data: []byte("404 not found")}, baseline, baselineHash)
+	if !(success > method && method > notFound) {
+		t.Errorf("Expected the method tier between success and client errors, got %f, %f and %f", success, method, notFound)
+	}
+	if class := codeClass(404); class != "4xx" {
+		t.Errorf("Expected the other 4xx in the 4xx class, got %s", class)
+	}
+}
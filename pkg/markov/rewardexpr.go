@@ -0,0 +1,524 @@
+package markov
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A reward expression computes the reward of a response in place of the decision table of EvaluateReward, for
+// example:
+//
+//	status == 200 && body_contains("X-Internal") ? 5 : builtin
+//	builtin + 2 * (header("Server") == "internal")
+//
+// The values are numbers or strings. The comparisons and the logical operators give 1 for true and 0 for false,
+// and any nonzero number is true. The primitives are:
+//
+//	status         status code of the response
+//	size           size of the body in bytes
+//	words          number of words of the body
+//	lines          number of lines of the body
+//	duration_ms    time to the first byte of the response in milliseconds, 0 once found noisy by SetDurationCheck
+//	builtin        reward of the built-in decision table
+//	header("name")         value of the response header, "" when missing
+//	body_contains("text")  1 when the body contains the text, 0 otherwise
+//
+// The operators by increasing precedence are ?:, ||, &&, == and !=, < <= > >=, + and -, * / and %, and the unary
+// - and !. A division by zero gives 0.
+
+// exprType is the static type of an expression
+type exprType int
+
+const (
+	exprNumber exprType = iota
+	exprString
+)
+
+func (t exprType) String() string {
+	if t == exprString {
+		return "string"
+	}
+	return "number"
+}
+
+// exprEnv is the response an expression is evaluated against
+type exprEnv struct {
+	resp      *Response
+	builtin   func() float64
+	durations bool // the durations are reliable, duration_ms being 0 otherwise
+}
+
+// exprNode is a node of the syntax tree of an expression
+type exprNode interface {
+	typ() exprType
+	num(env *exprEnv) float64
+	str(env *exprEnv) string
+}
+
+type numberNode float64
+
+func (n numberNode) typ() exprType            { return exprNumber }
+func (n numberNode) num(env *exprEnv) float64 { return float64(n) }
+func (n numberNode) str(env *exprEnv) string  { return "" }
+
+type stringNode string
+
+func (n stringNode) typ() exprType            { return exprString }
+func (n stringNode) num(env *exprEnv) float64 { return 0 }
+func (n stringNode) str(env *exprEnv) string  { return string(n) }
+
+// fieldNode is a number primitive of the response
+type fieldNode struct {
+	value func(env *exprEnv) float64
+}
+
+func (n fieldNode) typ() exprType            { return exprNumber }
+func (n fieldNode) num(env *exprEnv) float64 { return n.value(env) }
+func (n fieldNode) str(env *exprEnv) string  { return "" }
+
+type headerNode string
+
+func (n headerNode) typ() exprType            { return exprString }
+func (n headerNode) num(env *exprEnv) float64 { return 0 }
+func (n headerNode) str(env *exprEnv) string {
+	for name, values := range env.resp.Headers {
+		if strings.EqualFold(name, string(n)) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+type bodyContainsNode []byte
+
+func (n bodyContainsNode) typ() exprType { return exprNumber }
+func (n bodyContainsNode) num(env *exprEnv) float64 {
+	return boolNumber(bytes.Contains(env.resp.Data, n))
+}
+func (n bodyContainsNode) str(env *exprEnv) string { return "" }
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (n unaryNode) typ() exprType { return exprNumber }
+func (n unaryNode) num(env *exprEnv) float64 {
+	if n.op == "!" {
+		return boolNumber(n.operand.num(env) == 0)
+	}
+	return -n.operand.num(env)
+}
+func (n unaryNode) str(env *exprEnv) string { return "" }
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n binaryNode) typ() exprType { return exprNumber }
+func (n binaryNode) num(env *exprEnv) float64 {
+	switch n.op {
+	case "||":
+		return boolNumber(n.left.num(env) != 0 || n.right.num(env) != 0)
+	case "&&":
+		return boolNumber(n.left.num(env) != 0 && n.right.num(env) != 0)
+	}
+	if n.left.typ() == exprString {
+		equal := n.left.str(env) == n.right.str(env)
+		return boolNumber(equal == (n.op == "=="))
+	}
+	l, r := n.left.num(env), n.right.num(env)
+	switch n.op {
+	case "==":
+		return boolNumber(l == r)
+	case "!=":
+		return boolNumber(l != r)
+	case "<":
+		return boolNumber(l < r)
+	case "<=":
+		return boolNumber(l <= r)
+	case ">":
+		return boolNumber(l > r)
+	case ">=":
+		return boolNumber(l >= r)
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		if r == 0 {
+			return 0
+		}
+		return l / r
+	case "%":
+		if r == 0 {
+			return 0
+		}
+		return math.Mod(l, r)
+	}
+	return 0
+}
+func (n binaryNode) str(env *exprEnv) string { return "" }
+
+type conditionalNode struct {
+	cond, then, otherwise exprNode
+}
+
+func (n conditionalNode) typ() exprType { return n.then.typ() }
+func (n conditionalNode) num(env *exprEnv) float64 {
+	if n.cond.num(env) != 0 {
+		return n.then.num(env)
+	}
+	return n.otherwise.num(env)
+}
+func (n conditionalNode) str(env *exprEnv) string {
+	if n.cond.num(env) != 0 {
+		return n.then.str(env)
+	}
+	return n.otherwise.str(env)
+}
+
+func boolNumber(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// exprFields are the number primitives of the expressions
+var exprFields = map[string]func(env *exprEnv) float64{
+	"status": func(env *exprEnv) float64 { return float64(env.resp.StatusCode) },
+	"size":   func(env *exprEnv) float64 { return float64(env.resp.ContentLength) },
+	"words":  func(env *exprEnv) float64 { return float64(env.resp.ContentWords) },
+	"lines":  func(env *exprEnv) float64 { return float64(env.resp.ContentLines) },
+	"duration_ms": func(env *exprEnv) float64 {
+		if !env.durations {
+			return 0
+		}
+		return float64(env.resp.Elapsed.Microseconds()) / 1000
+	},
+	"builtin": func(env *exprEnv) float64 { return env.builtin() },
+}
+
+// RewardExpr is a compiled reward expression
+type RewardExpr struct {
+	source string
+	root   exprNode
+}
+
+// CompileRewardExpr parses a reward expression, the error giving the position of the first problem found
+func CompileRewardExpr(source string) (*RewardExpr, error) {
+	p := &exprParser{source: source}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	if root.typ() != exprNumber {
+		return nil, fmt.Errorf("the expression gives a %s, a number is needed", root.typ())
+	}
+	return &RewardExpr{source: source, root: root}, nil
+}
+
+// String returns the source of the expression
+func (e *RewardExpr) String() string {
+	return e.source
+}
+
+// Evaluate returns the reward of a response. The builtin function gives the reward of the built-in decision table,
+// only called when the expression uses it.
+func (e *RewardExpr) Evaluate(resp *Response, builtin func() float64) float64 {
+	return e.evaluate(resp, builtin, true)
+}
+
+// evaluate returns the reward of a response, duration_ms being 0 unless the durations are reliable
+func (e *RewardExpr) evaluate(resp *Response, builtin func() float64, durations bool) float64 {
+	reward := e.root.num(&exprEnv{resp: resp, builtin: builtin, durations: durations})
+	if math.IsNaN(reward) || math.IsInf(reward, 0) {
+		return 0
+	}
+	return reward
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type exprToken struct {
+	kind  tokenKind
+	text  string
+	value string // the unquoted value of a string
+	pos   int
+}
+
+func (t exprToken) String() string {
+	if t.kind == tokenEOF {
+		return "end of the expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// exprOperators are the operators of the expressions, the two character ones first
+var exprOperators = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "%", "!", "(", ")", "?", ":"}
+
+// exprParser is a recursive descent parser of the reward expressions
+type exprParser struct {
+	source string
+	tokens []exprToken
+	next   int
+}
+
+func (p *exprParser) errorf(t exprToken, format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), t.pos+1)
+}
+
+func (p *exprParser) tokenize() error {
+	s := p.source
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+				i++
+			}
+			if _, err := strconv.ParseFloat(s[start:i], 64); err != nil {
+				return p.errorf(exprToken{pos: start}, "invalid number %q", s[start:i])
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokenNumber, text: s[start:i], pos: start})
+		case c == '"':
+			start := i
+			i++
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(s) {
+				return p.errorf(exprToken{pos: start}, "unterminated string")
+			}
+			i++
+			value, err := strconv.Unquote(s[start:i])
+			if err != nil {
+				return p.errorf(exprToken{pos: start}, "invalid string %s", s[start:i])
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokenString, text: s[start:i], value: value, pos: start})
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(s) && (s[i] == '_' || s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' || s[i] >= '0' && s[i] <= '9') {
+				i++
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokenIdent, text: s[start:i], pos: start})
+		default:
+			op := ""
+			for _, o := range exprOperators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return p.errorf(exprToken{pos: i}, "unexpected character %q", c)
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	p.tokens = append(p.tokens, exprToken{kind: tokenEOF, pos: len(s)})
+	return nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.next]
+}
+
+// accept consumes the next token if it is one of the operators
+func (p *exprParser) accept(ops ...string) (exprToken, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return t, false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.next++
+			return t, true
+		}
+	}
+	return t, false
+}
+
+func (p *exprParser) expect(op string) error {
+	if t, ok := p.accept(op); !ok {
+		return p.errorf(t, "expected %q, got %s", op, t)
+	}
+	return nil
+}
+
+// needNumber checks that an operand of an operator is a number
+func (p *exprParser) needNumber(op exprToken, operand exprNode) error {
+	if operand.typ() != exprNumber {
+		return p.errorf(op, "operator %s needs numbers, got a string", op.text)
+	}
+	return nil
+}
+
+func (p *exprParser) parseConditional() (exprNode, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("?")
+	if !ok {
+		return cond, nil
+	}
+	if err := p.needNumber(op, cond); err != nil {
+		return nil, err
+	}
+	then, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	if then.typ() != otherwise.typ() {
+		return nil, p.errorf(op, "both branches of ?: need the same type, got a %s and a %s", then.typ(), otherwise.typ())
+	}
+	return conditionalNode{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+// exprPrecedence lists the binary operators by increasing precedence
+var exprPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) parseBinary(level int) (exprNode, error) {
+	if level == len(exprPrecedence) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(exprPrecedence[level]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if op.text == "==" || op.text == "!=" {
+			if left.typ() != right.typ() {
+				return nil, p.errorf(op, "cannot compare a %s to a %s", left.typ(), right.typ())
+			}
+		} else {
+			if err := p.needNumber(op, left); err != nil {
+				return nil, err
+			}
+			if err := p.needNumber(op, right); err != nil {
+				return nil, err
+			}
+		}
+		left = binaryNode{op: op.text, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if op, ok := p.accept("-", "!"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.needNumber(op, operand); err != nil {
+			return nil, err
+		}
+		return unaryNode{op: op.text, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenNumber:
+		p.next++
+		value, _ := strconv.ParseFloat(t.text, 64)
+		return numberNode(value), nil
+	case tokenString:
+		p.next++
+		return stringNode(t.value), nil
+	case tokenIdent:
+		p.next++
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+		if value, ok := exprFields[t.text]; ok {
+			return fieldNode{value: value}, nil
+		}
+		return nil, p.errorf(t, "unknown name %q", t.text)
+	case tokenOperator:
+		if t.text == "(" {
+			p.next++
+			inner, err := p.parseConditional()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	}
+	return nil, p.errorf(t, "unexpected %s", t)
+}
+
+// parseCall parses the argument of a function call, the name and the opening parenthesis being consumed
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	if name.text != "header" && name.text != "body_contains" {
+		return nil, p.errorf(name, "unknown function %q", name.text)
+	}
+	arg := p.peek()
+	if arg.kind != tokenString {
+		return nil, p.errorf(arg, "%s needs a string argument, got %s", name.text, arg)
+	}
+	p.next++
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if name.text == "header" {
+		return headerNode(arg.value), nil
+	}
+	return bodyContainsNode(arg.value), nil
+}
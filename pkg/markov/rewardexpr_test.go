@@ -0,0 +1,124 @@
+package markov
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRewardExprEvaluate(t *testing.T) {
+	resp := &Response{
+		StatusCode:    200,
+		ContentLength: 1234,
+		ContentWords:  56,
+		ContentLines:  7,
+		Elapsed:       1500 * time.Microsecond,
+		Headers:       map[string][]string{"X-Backend": {"internal"}},
+		Data:          []byte("<html>X-Internal admin panel</html>"),
+	}
+	tests := []struct {
+		expr     string
+		expected float64
+	}{
+		{"42", 42},
+		{"1.5 * 2", 3},
+		{"status", 200},
+		{"size + words + lines", 1297},
+		{"duration_ms", 1.5},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"7 % 4", 3},
+		{"-status + 1", -199},
+		{"1 / 0", 0},
+		{"status == 200", 1},
+		{"status != 200", 0},
+		{"size >= 1234 && size < 2000", 1},
+		{"status == 404 || lines > 5", 1},
+		{"!body_contains(\"nothing\")", 1},
+		{"body_contains(\"X-Internal\")", 1},
+		{"2 * body_contains(\"X-Internal\") + 1", 3},
+		{"header(\"x-backend\") == \"internal\"", 1},
+		{"header(\"X-Missing\") == \"\"", 1},
+		{"header(\"X-Backend\") != \"internal\" ? 1 : 5", 5},
+		{"status == 200 ? status == 404 ? 1 : 2 : 3", 2},
+		{"builtin + 1", 4},
+		{"\"a\\\"b\" == \"a\\\"b\"", 1},
+	}
+	for _, tt := range tests {
+		expr, err := CompileRewardExpr(tt.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.expr, err)
+			continue
+		}
+		if got := expr.Evaluate(resp, func() float64 { return 3 }); got != tt.expected {
+			t.Errorf("%s: expected %f, got %f", tt.expr, tt.expected, got)
+		}
+	}
+}
+
+func TestRewardExprBuiltinIsLazy(t *testing.T) {
+	expr, err := CompileRewardExpr("status == 200 ? 1 : builtin")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	calls := 0
+	expr.Evaluate(&Response{StatusCode: 200}, func() float64 { calls++; return 0 })
+	if calls != 0 {
+		t.Errorf("Expected the built-in reward not to be computed when not needed, got %d calls", calls)
+	}
+}
+
+func TestRewardExprErrors(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"", "unexpected end of the expression at position 1"},
+		{"status +", "unexpected end of the expression at position 9"},
+		{"status 200", "unexpected \"200\" at position 8"},
+		{"(status", "expected \")\", got end of the expression at position 8"},
+		{"stauts", "unknown name \"stauts\" at position 1"},
+		{"body(\"a\")", "unknown function \"body\" at position 1"},
+		{"header(Server)", "header needs a string argument, got \"Server\" at position 8"},
+		{"header(\"Server\")", "the expression gives a string, a number is needed"},
+		{"header(\"Server\") + 1", "operator + needs numbers, got a string at position 18"},
+		{"header(\"Server\") == 1", "cannot compare a string to a number at position 18"},
+		{"status > 1 ? \"a\" : 2", "both branches of ?: need the same type, got a string and a number at position 12"},
+		{"status $ 2", "unexpected character '$' at position 8"},
+		{"\"open", "unterminated string at position 1"},
+		{"1.2.3", "invalid number \"1.2.3\" at position 1"},
+		{"status ? 1", "expected \":\", got end of the expression at position 11"},
+	}
+	for _, tt := range tests {
+		_, err := CompileRewardExpr(tt.expr)
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.expr)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error %q, got %q", tt.expr, tt.expected, err)
+		}
+	}
+}
+
+func TestRewardExprReplacesBuiltin(t *testing.T) {
+	mip := newTestProvider("admin", "login")
+	expr, err := CompileRewardExpr("header(\"X-Internal\") == \"1\" ? 5 : 0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	mip.SetRewardExpr(expr)
+	internal := &Response{StatusCode: 404, Headers: map[string][]string{"X-Internal": {"1"}}}
+	if got := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, internal); got != 5 {
+		t.Errorf("Expected the expression to reward the internal response with 5, got %f", got)
+	}
+	if got := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("login")}, &Response{StatusCode: 200}); got != 0 {
+		t.Errorf("Expected the expression to replace the built-in reward of a 200, got %f", got)
+	}
+
+	mip.SetRewardExpr(nil)
+	if got := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("login")}, &Response{StatusCode: 200}); got != 3.0 {
+		t.Errorf("Expected the built-in reward without an expression, got %f", got)
+	}
+}
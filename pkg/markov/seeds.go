@@ -0,0 +1,115 @@
+package markov
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// seedMinUses is the number of derived requests a seed is judged on before its weight may decay
+	seedMinUses = 20
+	// seedMinYield is the reward per derived request below which the weight of a seed decays
+	seedMinYield = 0.5
+	// seedDecay is the factor the weight of a seed is multiplied by on each derived request below seedMinYield
+	seedDecay = 0.5
+	// seedRetireWeight is the weight below which a seed is retired from the matched tokens
+	seedRetireWeight = 0.1
+)
+
+// seedStats is the track record of a matched token used as a seed: how many requests were derived from it, like the
+// tokens of its patterns, its neighbors and directory probes, and the reward they earned
+type seedStats struct {
+	uses   int
+	reward float64
+	weight float64
+}
+
+// RetiredSeed is a matched token retired from the seeds once the requests derived from it stopped paying off
+type RetiredSeed struct {
+	Token  string
+	Uses   int
+	Reward float64
+}
+
+func (r RetiredSeed) String() string {
+	return fmt.Sprintf("%s after %d derived requests, %.2f reward per request", r.Token, r.Uses, r.Reward/float64(r.Uses))
+}
+
+// RecordSeedUse credits the reward of a request derived from the seeds to each of them. The weight of a seed used
+// at least seedMinUses times decays while its reward per derived request is below seedMinYield, and the seed is
+// retired once the weight falls below seedRetireWeight. Returns the seeds retired by this request.
+func (mip *MarkovInputProvider) RecordSeedUse(seeds []string, reward float64) []RetiredSeed {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	if mip.seeds == nil {
+		mip.seeds = make(map[string]*seedStats)
+	}
+	retired := make([]RetiredSeed, 0)
+	for _, seed := range seeds {
+		if mip.retiredSeeds[seed] {
+			continue
+		}
+		s, ok := mip.seeds[seed]
+		if !ok {
+			s = &seedStats{weight: 1.0}
+			mip.seeds[seed] = s
+		}
+		s.uses++
+		s.reward += reward
+		if s.uses < seedMinUses || s.reward/float64(s.uses) >= seedMinYield {
+			continue
+		}
+		s.weight *= seedDecay
+		if s.weight >= seedRetireWeight {
+			continue
+		}
+		if mip.retiredSeeds == nil {
+			mip.retiredSeeds = make(map[string]bool)
+		}
+		mip.retiredSeeds[seed] = true
+		delete(mip.matchedTokens, seed)
+		r := RetiredSeed{Token: seed, Uses: s.uses, Reward: s.reward}
+		mip.retiredOrder = append(mip.retiredOrder, r)
+		retired = append(retired, r)
+	}
+	return retired
+}
+
+// SeedRetired returns true if the token was retired from the seeds, no more requests being derived from it
+func (mip *MarkovInputProvider) SeedRetired(token string) bool {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	return mip.retiredSeeds[token]
+}
+
+// RetiredSeeds returns the seeds retired so far, in the order they were retired
+func (mip *MarkovInputProvider) RetiredSeeds() []RetiredSeed {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	retired := make([]RetiredSeed, len(mip.retiredOrder))
+	copy(retired, mip.retiredOrder)
+	return retired
+}
+
+// PatternSeeds returns the matched tokens a token generated by PatternCandidates was induced from, sorted
+func (mip *MarkovInputProvider) PatternSeeds(token string) []string {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	seeds := make([]string, len(mip.generatedTokens[token]))
+	copy(seeds, mip.generatedTokens[token])
+	sort.Strings(seeds)
+	return seeds
+}
+
+// seedWeight returns the selection weight of a matched token, 1 until the requests derived from it stop paying off.
+// Must be called with the mutex held.
+func (mip *MarkovInputProvider) seedWeight(token string) float64 {
+	if s, ok := mip.seeds[token]; ok {
+		return s.weight
+	}
+	return 1.0
+}
@@ -0,0 +1,55 @@
+package markov
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSeedRetirement(t *testing.T) {
+	mip := NewMarkovInputProvider(nil, State{CodeClass: "4xx"}, "", 0)
+	mip.RecordMatch("api_v1", 3.0)
+	mip.RecordMatch("api_v2", 2.0)
+	mip.RecordMatch("admin", 1.0)
+
+	// The seed pays off early, then goes cold
+	uses := 0
+	for i := 0; i < 10; i++ {
+		uses++
+		if retired := mip.RecordSeedUse([]string{"api_v1"}, 3.0); len(retired) != 0 {
+			t.Fatalf("Expected a seed paying off not to be retired, got %v", retired)
+		}
+	}
+	var retired []RetiredSeed
+	for len(retired) == 0 && uses < 200 {
+		uses++
+		retired = mip.RecordSeedUse([]string{"api_v1"}, 0.0)
+		// The weight decays before the retirement, the other matches overtaking it
+		if len(retired) == 0 && uses > seedMinUses && 30.0/float64(uses) < seedMinYield {
+			if tokens := mip.MatchedTokens(); tokens[0] != "api_v2" {
+				t.Fatalf("Expected the decayed seed to fall behind, got %v", tokens)
+			}
+		}
+	}
+	if len(retired) != 1 || retired[0].Token != "api_v1" || retired[0].Uses != uses || retired[0].Reward != 30.0 {
+		t.Fatalf("Expected the seed to be retired once cold, got %v after %d uses", retired, uses)
+	}
+	// The yield falls below 0.5 at the 61st use, the weight then halving down to 0.0625 by the 64th
+	if uses != 64 {
+		t.Errorf("Expected the seed to be retired at the 64th use, got %d", uses)
+	}
+	if !mip.SeedRetired("api_v1") || mip.SeedRetired("api_v2") {
+		t.Errorf("Unexpected retired seeds")
+	}
+	mip.RecordMatch("api_v1", 5.0)
+	for _, token := range mip.MatchedTokens() {
+		if token == "api_v1" {
+			t.Errorf("Expected the retired seed to be left out of the matched tokens")
+		}
+	}
+	if r := mip.RecordSeedUse([]string{"api_v1", "admin"}, 0.0); len(r) != 0 {
+		t.Errorf("Expected a retired seed not to be retired again, got %v", r)
+	}
+	if r := mip.RetiredSeeds(); len(r) != 1 || r[0].String() != fmt.Sprintf("api_v1 after %d derived requests, %.2f reward per request", uses, 30.0/float64(uses)) {
+		t.Errorf("Unexpected retired seeds: %v", r)
+	}
+}
@@ -0,0 +1,103 @@
+package markov
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// ParseRobots returns the paths of the Allow and Disallow rules of a robots.txt file, along with the urls
+// of the sitemaps it references. Wildcards and end anchors are stripped from the paths.
+func ParseRobots(data []byte) (allowed []string, disallowed []string, sitemaps []string) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch strings.ToLower(strings.TrimSpace(parts[0])) {
+		case "allow":
+			if p := robotsPath(value); p != "" {
+				allowed = append(allowed, p)
+			}
+		case "disallow":
+			if p := robotsPath(value); p != "" {
+				disallowed = append(disallowed, p)
+			}
+		case "sitemap":
+			if value != "" {
+				sitemaps = append(sitemaps, value)
+			}
+		}
+	}
+	return
+}
+
+// robotsPath strips the wildcard part and the end anchor of a robots.txt rule path
+func robotsPath(rule string) string {
+	if i := strings.Index(rule, "*"); i >= 0 {
+		rule = rule[:i]
+	}
+	rule = strings.TrimSuffix(rule, "$")
+	if rule == "/" {
+		return ""
+	}
+	return rule
+}
+
+// ParseSitemap returns the page urls of a sitemap, or the urls of the nested sitemaps for a sitemap index file
+func ParseSitemap(data []byte) (pages []string, sitemaps []string, err error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	parent := ""
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return pages, sitemaps, nil
+		}
+		if err != nil {
+			return pages, sitemaps, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "url", "sitemap":
+			parent = start.Name.Local
+		case "loc":
+			var loc string
+			if err := dec.DecodeElement(&loc, &start); err != nil {
+				return pages, sitemaps, err
+			}
+			loc = strings.TrimSpace(loc)
+			if parent == "sitemap" {
+				sitemaps = append(sitemaps, loc)
+			} else {
+				pages = append(pages, loc)
+			}
+		}
+	}
+}
+
+// PathSegments returns the non-empty segments of the path of a url or of a plain path
+func PathSegments(rawurl string) []string {
+	p := rawurl
+	if u, err := url.Parse(rawurl); err == nil {
+		p = u.Path
+	}
+	segments := make([]string, 0)
+	for _, s := range strings.Split(p, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
@@ -0,0 +1,78 @@
+package markov
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRobots(t *testing.T) {
+	robots := []byte(`# robots for example.com
+User-agent: *
+Allow: /public/
+Disallow: /admin/
+Disallow: /private*.php$
+Disallow: /
+disallow: /backup # old backups
+Sitemap: http://example.com/sitemap_index.xml
+`)
+	allowed, disallowed, sitemaps := ParseRobots(robots)
+	if !reflect.DeepEqual(allowed, []string{"/public/"}) {
+		t.Errorf("Unexpected allowed paths: %v", allowed)
+	}
+	if !reflect.DeepEqual(disallowed, []string{"/admin/", "/private", "/backup"}) {
+		t.Errorf("Unexpected disallowed paths: %v", disallowed)
+	}
+	if !reflect.DeepEqual(sitemaps, []string{"http://example.com/sitemap_index.xml"}) {
+		t.Errorf("Unexpected sitemaps: %v", sitemaps)
+	}
+}
+
+func TestParseSitemap(t *testing.T) {
+	pages, sitemaps, err := ParseSitemap([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>http://example.com/blog/first-post</loc><lastmod>2026-10-01</lastmod></url>
+  <url><loc> http://example.com/about </loc></url>
+</urlset>`))
+	if err != nil || len(sitemaps) != 0 || !reflect.DeepEqual(pages, []string{"http://example.com/blog/first-post", "http://example.com/about"}) {
+		t.Errorf("Unexpected sitemap parse result: %v %v %v", pages, sitemaps, err)
+	}
+
+	pages, sitemaps, err = ParseSitemap([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>http://example.com/sitemap-pages.xml</loc></sitemap>
+</sitemapindex>`))
+	if err != nil || len(pages) != 0 || !reflect.DeepEqual(sitemaps, []string{"http://example.com/sitemap-pages.xml"}) {
+		t.Errorf("Unexpected sitemap index parse result: %v %v %v", pages, sitemaps, err)
+	}
+}
+
+func TestPathSegments(t *testing.T) {
+	if s := PathSegments("http://example.com/blog/2026/post?id=1"); !reflect.DeepEqual(s, []string{"blog", "2026", "post"}) {
+		t.Errorf("Unexpected segments: %v", s)
+	}
+	if s := PathSegments("/admin/"); !reflect.DeepEqual(s, []string{"admin"}) {
+		t.Errorf("Unexpected segments: %v", s)
+	}
+}
+
+func TestSeedActions(t *testing.T) {
+	mip := newTestProvider()
+	mip.MarkovChain.UpdateTransition(Transition{FromState: mip.baselineState, Action: Action{Token: "known"}, ToState: State{CodeClass: "2xx"}, Reward: 30.0})
+	known := mip.MarkovChain.GetExpectedReward(mip.baselineState, "known")
+
+	if n := mip.SeedActions([]string{"admin", "admin", "known"}, 1.5); n != 2 {
+		t.Errorf("Expected 2 unique tokens to be seeded, got %d", n)
+	}
+	if q := mip.MarkovChain.GetExpectedReward(mip.baselineState, "admin"); q != 1.5 {
+		t.Errorf("Expected the seeded token to get the bias, got %f", q)
+	}
+	if q := mip.MarkovChain.GetExpectedReward(mip.baselineState, "known"); q != known {
+		t.Errorf("Bias should not lower a learned Q-value, got %f want %f", q, known)
+	}
+
+	// Taking a seeded action later does not register it twice
+	mip.MarkovChain.UpdateTransition(Transition{FromState: mip.baselineState, Action: Action{Token: "admin"}, ToState: State{CodeClass: "2xx"}, Reward: 1.0})
+	if actions := mip.MarkovChain.AvailableActions[mip.baselineState.Hash()]; len(actions) != 2 {
+		t.Errorf("Expected each action to be available once, got %v", actions)
+	}
+}
@@ -0,0 +1,596 @@
+package markov
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// SnapshotVersion is the version of the chain file format written by SaveSnapshot
+const SnapshotVersion = 1
+
+// ChainSnapshot is the on-disk format of a chain. The state keys are in the format of State.Hash and the
+// action keys in the format of Action.Key.
+//
+// Version 1 holds the granularity preset, the size bucket scheme and the reward configuration hash of the run that
+// learned the chain, along with the Q-values, transition counts, reward statistics and token feature values.
+type ChainSnapshot struct {
+	Version          int                                  `json:"version"`
+	Granularity      StateGranularity                     `json:"granularity"`
+	SizeBuckets      string                               `json:"size_buckets,omitempty"` // scheme in the format of ParseBucketizer, empty for log
+	RewardConfig     string                               `json:"reward_config"`
+	QTable           map[string]map[string]float64        `json:"q_table"`
+	TransitionCounts map[string]map[string]map[string]int `json:"transition_counts"`
+	ActionCounts     map[string]map[string]int            `json:"action_counts"`
+	StateCounts      map[string]int                       `json:"state_counts"`
+	ClassCounts      map[string]int                       `json:"class_counts"`
+	AvailableActions map[string][]string                  `json:"available_actions"`
+	RewardStats      map[string]map[string]RewardStat     `json:"reward_stats"`
+	FeatureQTable    map[string]map[string]float64        `json:"feature_q_table"` // features in "name:value" format
+	FeatureCounts    map[string]map[string]int            `json:"feature_counts"`
+	MethodRewards    map[string]RewardStat                `json:"method_rewards,omitempty"`
+}
+
+// SaveSnapshot writes the snapshot to a file, as JSON if the file has the .json extension and gob otherwise
+func SaveSnapshot(filename string, snap ChainSnapshot) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(snap)
+	} else {
+		err = gob.NewEncoder(f).Encode(snap)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadSnapshot reads a snapshot from a file, as JSON if the file has the .json extension and gob otherwise.
+// Files written by a newer, unknown version of the format are rejected.
+func LoadSnapshot(filename string) (ChainSnapshot, error) {
+	var snap ChainSnapshot
+	f, err := os.Open(filename)
+	if err != nil {
+		return snap, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		err = json.NewDecoder(f).Decode(&snap)
+	} else {
+		err = gob.NewDecoder(f).Decode(&snap)
+	}
+	if err != nil {
+		return snap, fmt.Errorf("could not decode the chain file %s: %s", filename, err)
+	}
+	if snap.Version < 1 || snap.Version > SnapshotVersion {
+		return snap, fmt.Errorf("unsupported chain file version %d in %s, this version of ffuf supports up to version %d", snap.Version, filename, SnapshotVersion)
+	}
+	if snap.Granularity == "" {
+		snap.Granularity = GranularityDefault
+	}
+	return snap, nil
+}
+
+// Snapshot returns a copy of the learned tables of the chain, tagged with the reward configuration hash
+func (mc *MarkovChain) Snapshot(rewardConfig string) ChainSnapshot {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	snap := ChainSnapshot{
+		Version:          SnapshotVersion,
+		Granularity:      mc.Granularity,
+		SizeBuckets:      mc.Buckets.Name(),
+		RewardConfig:     rewardConfig,
+		QTable:           make(map[string]map[string]float64),
+		TransitionCounts: make(map[string]map[string]map[string]int),
+		ActionCounts:     make(map[string]map[string]int),
+		StateCounts:      make(map[string]int),
+		ClassCounts:      make(map[string]int),
+		AvailableActions: make(map[string][]string),
+		RewardStats:      make(map[string]map[string]RewardStat),
+		FeatureQTable:    make(map[string]map[string]float64),
+		FeatureCounts:    make(map[string]map[string]int),
+		MethodRewards:    make(map[string]RewardStat),
+	}
+	for state, row := range mc.QTable {
+		snap.QTable[state] = copyFloatMap(row)
+	}
+	for method, stat := range mc.MethodRewards {
+		snap.MethodRewards[method] = *stat
+	}
+	for state, actions := range mc.TransitionCounts {
+		snap.TransitionCounts[state] = make(map[string]map[string]int)
+		for action, next := range actions {
+			snap.TransitionCounts[state][action] = copyIntMap(next)
+		}
+	}
+	for state, row := range mc.ActionCounts {
+		snap.ActionCounts[state] = copyIntMap(row)
+	}
+	snap.StateCounts = copyIntMap(mc.StateCounts)
+	snap.ClassCounts = copyIntMap(mc.ClassCounts)
+	for state, actions := range mc.AvailableActions {
+		snap.AvailableActions[state] = append([]string{}, actions...)
+	}
+	for state, row := range mc.RewardStats {
+		snap.RewardStats[state] = make(map[string]RewardStat)
+		for action, stat := range row {
+			snap.RewardStats[state][action] = *stat
+		}
+	}
+	for state, row := range mc.FeatureQTable {
+		snap.FeatureQTable[state] = make(map[string]float64)
+		snap.FeatureCounts[state] = make(map[string]int)
+		for f, v := range row {
+			snap.FeatureQTable[state][f.String()] = v
+			snap.FeatureCounts[state][f.String()] = mc.FeatureCounts[state][f]
+		}
+	}
+	return snap
+}
+
+// Restore replaces the learned tables of the chain with the ones of the snapshot, taking ownership of its maps.
+// The granularity of the snapshot is expected to match the one of the chain.
+func (mc *MarkovChain) Restore(snap ChainSnapshot) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.dirtyAll = true
+
+	mc.QTable = nonNilFloatTable(snap.QTable)
+	mc.TransitionCounts = snap.TransitionCounts
+	if mc.TransitionCounts == nil {
+		mc.TransitionCounts = make(map[string]map[string]map[string]int)
+	}
+	mc.ActionCounts = nonNilIntTable(snap.ActionCounts)
+	mc.StateCounts = snap.StateCounts
+	if mc.StateCounts == nil {
+		mc.StateCounts = make(map[string]int)
+	}
+	mc.ClassCounts = snap.ClassCounts
+	if mc.ClassCounts == nil {
+		mc.ClassCounts = make(map[string]int)
+	}
+	mc.AvailableActions = snap.AvailableActions
+	if mc.AvailableActions == nil {
+		mc.AvailableActions = make(map[string][]string)
+	}
+	mc.RewardStats = make(map[string]map[string]*RewardStat)
+	rewardSum := 0.0
+	for state, row := range snap.RewardStats {
+		mc.RewardStats[state] = make(map[string]*RewardStat)
+		for action, stat := range row {
+			stat := stat
+			mc.RewardStats[state][action] = &stat
+			rewardSum += stat.Mean * float64(stat.Count)
+		}
+	}
+	mc.MethodRewards = make(map[string]*RewardStat)
+	for method, stat := range snap.MethodRewards {
+		stat := stat
+		mc.MethodRewards[method] = &stat
+	}
+	mc.FeatureQTable = make(map[string]map[Feature]float64)
+	mc.FeatureCounts = make(map[string]map[Feature]int)
+	for state, row := range snap.FeatureQTable {
+		mc.FeatureQTable[state] = make(map[Feature]float64)
+		mc.FeatureCounts[state] = make(map[Feature]int)
+		for key, v := range row {
+			parts := strings.SplitN(key, ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			f := Feature{Name: parts[0], Value: parts[1]}
+			mc.FeatureQTable[state][f] = v
+			mc.FeatureCounts[state][f] = snap.FeatureCounts[state][key]
+		}
+	}
+
+	// Republish the Q-values and counters for the lock-free readers
+	mc.qCells.Range(func(key, _ interface{}) bool {
+		mc.qCells.Delete(key)
+		return true
+	})
+	mc.knownActions.Range(func(key, _ interface{}) bool {
+		mc.knownActions.Delete(key)
+		return true
+	})
+	transitions := int64(0)
+	for state, row := range mc.QTable {
+		for action, q := range row {
+			mc.setQ(state, action, q)
+			mc.knownActions.Store(action, true)
+		}
+		for _, count := range mc.ActionCounts[state] {
+			transitions += int64(count)
+		}
+	}
+	atomic.StoreInt64(&mc.stateCount, int64(len(mc.QTable)))
+	atomic.StoreInt64(&mc.transitionCount, transitions)
+	atomic.StoreUint64(&mc.rewardSum, math.Float64bits(rewardSum))
+}
+
+// Merge adds the learned tables of the snapshot to the ones of the chain. Counts are summed, Q-values and feature
+// values are averaged weighted by their observation counts and the reward statistics are combined. The granularity
+// of the snapshot is expected to match the one of the chain. Returns the number of states new to the chain and the
+// number of transitions added.
+func (mc *MarkovChain) Merge(snap ChainSnapshot) (int, int) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.dirtyAll = true
+
+	states := 0
+	transitions := 0
+	for state, row := range snap.QTable {
+		if _, exists := mc.QTable[state]; !exists {
+			mc.QTable[state] = make(map[string]float64)
+			mc.ActionCounts[state] = make(map[string]int)
+			states++
+		}
+		for action, q := range row {
+			current, known := mc.QTable[state][action]
+			weight := snap.ActionCounts[state][action]
+			if weight == 0 {
+				weight = 1
+			}
+			currentWeight := mc.ActionCounts[state][action]
+			if known && currentWeight == 0 {
+				currentWeight = 1
+			}
+			mc.setQ(state, action, mergeMean(current, currentWeight, q, weight))
+			mc.knownActions.Store(action, true)
+		}
+	}
+	for state, row := range snap.ActionCounts {
+		if mc.ActionCounts[state] == nil {
+			mc.ActionCounts[state] = make(map[string]int)
+		}
+		for action, count := range row {
+			mc.ActionCounts[state][action] += count
+			transitions += count
+		}
+	}
+	for state, actions := range snap.TransitionCounts {
+		if mc.TransitionCounts[state] == nil {
+			mc.TransitionCounts[state] = make(map[string]map[string]int)
+		}
+		for action, next := range actions {
+			if mc.TransitionCounts[state][action] == nil {
+				mc.TransitionCounts[state][action] = make(map[string]int)
+			}
+			for nextState, count := range next {
+				mc.TransitionCounts[state][action][nextState] += count
+			}
+		}
+	}
+	for state, count := range snap.StateCounts {
+		mc.StateCounts[state] += count
+	}
+	for class, count := range snap.ClassCounts {
+		mc.ClassCounts[class] += count
+	}
+	for state, actions := range snap.AvailableActions {
+		for _, action := range actions {
+			if !containsString(mc.AvailableActions[state], action) {
+				mc.AvailableActions[state] = append(mc.AvailableActions[state], action)
+			}
+		}
+	}
+	rewardSum := math.Float64frombits(atomic.LoadUint64(&mc.rewardSum))
+	for state, row := range snap.RewardStats {
+		if mc.RewardStats[state] == nil {
+			mc.RewardStats[state] = make(map[string]*RewardStat)
+		}
+		for action, stat := range row {
+			current := RewardStat{}
+			if s, exists := mc.RewardStats[state][action]; exists {
+				current = *s
+			}
+			merged := current.merge(stat)
+			mc.RewardStats[state][action] = &merged
+			rewardSum += stat.Mean * float64(stat.Count)
+		}
+	}
+	for method, stat := range snap.MethodRewards {
+		current := RewardStat{}
+		if s, exists := mc.MethodRewards[method]; exists {
+			current = *s
+		}
+		merged := current.merge(stat)
+		mc.MethodRewards[method] = &merged
+	}
+	for state, row := range snap.FeatureQTable {
+		if mc.FeatureQTable[state] == nil {
+			mc.FeatureQTable[state] = make(map[Feature]float64)
+			mc.FeatureCounts[state] = make(map[Feature]int)
+		}
+		for key, v := range row {
+			parts := strings.SplitN(key, ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			f := Feature{Name: parts[0], Value: parts[1]}
+			count := snap.FeatureCounts[state][key]
+			mc.FeatureQTable[state][f] = mergeMean(mc.FeatureQTable[state][f], mc.FeatureCounts[state][f], v, count)
+			mc.FeatureCounts[state][f] += count
+		}
+	}
+	atomic.AddInt64(&mc.stateCount, int64(states))
+	atomic.AddInt64(&mc.transitionCount, int64(transitions))
+	atomic.StoreUint64(&mc.rewardSum, math.Float64bits(rewardSum))
+	return states, transitions
+}
+
+// granularityLevel orders the granularity presets from the coarsest to the finest
+func granularityLevel(g StateGranularity) int {
+	switch g {
+	case GranularityCoarse:
+		return 0
+	case GranularityFine:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// projectStateKey reduces a state key in the format of State.Hash to a coarser granularity. The default
+// granularity keeps the code class, size bucket and depth, dropping the optional dimensions.
+func projectStateKey(key string, g StateGranularity) string {
+	parts := strings.Split(key, "_")
+	switch g {
+	case GranularityCoarse:
+		return State{CodeClass: parts[0]}.Hash()
+	case GranularityDefault:
+		if len(parts) > 3 {
+			return strings.Join(parts[:3], "_")
+		}
+	}
+	return key
+}
+
+// WithGranularity returns the snapshot downgraded to a coarser granularity, merging the states that become
+// identical. Counts are summed, Q-values and feature values are averaged weighted by their observation counts
+// and the reward statistics are combined. Snapshots can not be upgraded to a finer granularity.
+func (snap ChainSnapshot) WithGranularity(g StateGranularity) (ChainSnapshot, error) {
+	if granularityLevel(g) > granularityLevel(snap.Granularity) {
+		return snap, fmt.Errorf("a chain learned with the %s state granularity can not be used with the finer %s granularity", snap.Granularity, g)
+	}
+	if granularityLevel(g) == granularityLevel(snap.Granularity) {
+		return snap, nil
+	}
+	out := ChainSnapshot{
+		Version:          snap.Version,
+		Granularity:      g,
+		SizeBuckets:      snap.SizeBuckets,
+		RewardConfig:     snap.RewardConfig,
+		QTable:           make(map[string]map[string]float64),
+		TransitionCounts: make(map[string]map[string]map[string]int),
+		ActionCounts:     make(map[string]map[string]int),
+		StateCounts:      make(map[string]int),
+		ClassCounts:      copyIntMap(snap.ClassCounts),
+		AvailableActions: make(map[string][]string),
+		RewardStats:      make(map[string]map[string]RewardStat),
+		FeatureQTable:    make(map[string]map[string]float64),
+		FeatureCounts:    make(map[string]map[string]int),
+		MethodRewards:    snap.MethodRewards,
+	}
+	weights := make(map[string]map[string]int)
+	for state, row := range snap.QTable {
+		to := projectStateKey(state, g)
+		if out.QTable[to] == nil {
+			out.QTable[to] = make(map[string]float64)
+			weights[to] = make(map[string]int)
+		}
+		for action, q := range row {
+			// Actions biased without being observed still weigh in the average
+			weight := snap.ActionCounts[state][action]
+			if weight == 0 {
+				weight = 1
+			}
+			out.QTable[to][action] = mergeMean(out.QTable[to][action], weights[to][action], q, weight)
+			weights[to][action] += weight
+		}
+	}
+	for state, row := range snap.ActionCounts {
+		to := projectStateKey(state, g)
+		if out.ActionCounts[to] == nil {
+			out.ActionCounts[to] = make(map[string]int)
+		}
+		for action, count := range row {
+			out.ActionCounts[to][action] += count
+		}
+	}
+	for state, actions := range snap.TransitionCounts {
+		to := projectStateKey(state, g)
+		if out.TransitionCounts[to] == nil {
+			out.TransitionCounts[to] = make(map[string]map[string]int)
+		}
+		for action, next := range actions {
+			if out.TransitionCounts[to][action] == nil {
+				out.TransitionCounts[to][action] = make(map[string]int)
+			}
+			for nextState, count := range next {
+				out.TransitionCounts[to][action][projectStateKey(nextState, g)] += count
+			}
+		}
+	}
+	for state, count := range snap.StateCounts {
+		out.StateCounts[projectStateKey(state, g)] += count
+	}
+	for state, actions := range snap.AvailableActions {
+		to := projectStateKey(state, g)
+		for _, action := range actions {
+			if !containsString(out.AvailableActions[to], action) {
+				out.AvailableActions[to] = append(out.AvailableActions[to], action)
+			}
+		}
+	}
+	for state, row := range snap.RewardStats {
+		to := projectStateKey(state, g)
+		if out.RewardStats[to] == nil {
+			out.RewardStats[to] = make(map[string]RewardStat)
+		}
+		for action, stat := range row {
+			out.RewardStats[to][action] = out.RewardStats[to][action].merge(stat)
+		}
+	}
+	for state, row := range snap.FeatureQTable {
+		to := projectStateKey(state, g)
+		if out.FeatureQTable[to] == nil {
+			out.FeatureQTable[to] = make(map[string]float64)
+			out.FeatureCounts[to] = make(map[string]int)
+		}
+		for f, v := range row {
+			count := snap.FeatureCounts[state][f]
+			out.FeatureQTable[to][f] = mergeMean(out.FeatureQTable[to][f], out.FeatureCounts[to][f], v, count)
+			out.FeatureCounts[to][f] += count
+		}
+	}
+	return out, nil
+}
+
+// merge combines the statistics of two sets of rewards
+func (r RewardStat) merge(other RewardStat) RewardStat {
+	n := r.Count + other.Count
+	if n == 0 {
+		return r
+	}
+	delta := other.Mean - r.Mean
+	return RewardStat{
+		Count: n,
+		Mean:  r.Mean + delta*float64(other.Count)/float64(n),
+		M2:    r.M2 + other.M2 + delta*delta*float64(r.Count)*float64(other.Count)/float64(n),
+	}
+}
+
+// mergeMean returns the weighted mean of two values
+func mergeMean(a float64, weightA int, b float64, weightB int) float64 {
+	if weightA+weightB == 0 {
+		return b
+	}
+	return (a*float64(weightA) + b*float64(weightB)) / float64(weightA+weightB)
+}
+
+// RewardConfigHash returns a hash of the reward configuration of the provider, stored in the saved chains to
+// detect chains learned with different rewards
+func (mip *MarkovInputProvider) RewardConfigHash() string {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	rules, vhostRules := mip.rewardTables()
+	h := fnv.New64a()
+	for _, rule := range rules {
+		fmt.Fprintf(h, "%s=%g;", rule.Name, rule.Delta)
+	}
+	fmt.Fprintf(h, "timeout=%g;conn-error=%g;cookie=%g", mip.timeoutReward, mip.connErrorReward, mip.cookieReward)
+	// The chains learned against the default virtual host are rewarded differently
+	if len(mip.vhostBaselines) > 0 {
+		for _, rule := range vhostRules {
+			fmt.Fprintf(h, ";%s=%g", rule.Name, rule.Delta)
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+// SaveChain writes the chain to a file, as JSON if the file has the .json extension and gob otherwise
+func (mip *MarkovInputProvider) SaveChain(filename string) error {
+	if !atomic.CompareAndSwapInt32(&mip.saving, 0, 1) {
+		return fmt.Errorf("the chain is already being saved")
+	}
+	defer atomic.StoreInt32(&mip.saving, 0)
+	return SaveSnapshot(filename, mip.MarkovChain.Snapshot(mip.RewardConfigHash()))
+}
+
+// ReloadChain merges the chain saved in a file into the live chain, for example one merged from the runs against
+// sibling hosts. The reload is rejected while the chain is being saved, and when the saved chain was learned with a
+// different state granularity. Returns the number of states new to the chain and the number of transitions added.
+func (mip *MarkovInputProvider) ReloadChain(filename string) (int, int, error) {
+	if atomic.LoadInt32(&mip.saving) == 1 {
+		return 0, 0, fmt.Errorf("the chain is being saved, try again later")
+	}
+	snap, err := LoadSnapshot(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	mip.mutex.Lock()
+	granularity := mip.granularity
+	mip.mutex.Unlock()
+	if snap.Granularity != granularity {
+		return 0, 0, fmt.Errorf("the chain in %s was learned with the %s state granularity, the run uses %s", filename, snap.Granularity, granularity)
+	}
+	if err := mip.adoptBuckets(snap.SizeBuckets); err != nil {
+		return 0, 0, fmt.Errorf("the chain in %s was %s", filename, err)
+	}
+	states, transitions := mip.MarkovChain.Merge(snap)
+	return states, transitions, nil
+}
+
+// LoadChain replaces the chain with the one saved in a file. Chains learned with a finer state granularity are
+// downgraded to the granularity of the run, while coarser ones are rejected. Returns warnings about the
+// differences between the saved chain and the run.
+func (mip *MarkovInputProvider) LoadChain(filename string) ([]string, error) {
+	snap, err := LoadSnapshot(filename)
+	if err != nil {
+		return nil, err
+	}
+	warnings := make([]string, 0)
+	mip.mutex.Lock()
+	granularity := mip.granularity
+	mip.mutex.Unlock()
+	if snap.Granularity != granularity {
+		snap, err = snap.WithGranularity(granularity)
+		if err != nil {
+			return nil, err
+		}
+		warnings = append(warnings, fmt.Sprintf("The chain in %s was learned with a finer state granularity, downgraded it to %s", filename, granularity))
+	}
+	if err := mip.adoptBuckets(snap.SizeBuckets); err != nil {
+		return nil, fmt.Errorf("the chain in %s was %s", filename, err)
+	}
+	if snap.RewardConfig != mip.RewardConfigHash() {
+		warnings = append(warnings, fmt.Sprintf("The chain in %s was learned with a different reward configuration, its Q-values may not be comparable", filename))
+	}
+	mip.MarkovChain.Restore(snap)
+	return warnings, nil
+}
+
+func copyFloatMap(m map[string]float64) map[string]float64 {
+	c := make(map[string]float64, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func copyIntMap(m map[string]int) map[string]int {
+	c := make(map[string]int, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func nonNilFloatTable(m map[string]map[string]float64) map[string]map[string]float64 {
+	if m == nil {
+		return make(map[string]map[string]float64)
+	}
+	return m
+}
+
+func nonNilIntTable(m map[string]map[string]int) map[string]map[string]int {
+	if m == nil {
+		return make(map[string]map[string]int)
+	}
+	return m
+}
@@ -0,0 +1,276 @@
+package markov
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newSnapshotProvider returns a provider with a fine granularity chain that has learned from a few transitions
+// out of two states differing only by their protocol
+func newSnapshotProvider() *MarkovInputProvider {
+	mip := newTestProvider()
+	mip.SetGranularity(GranularityFine)
+	h1 := State{CodeClass: "4xx", SizeBucket: "100", Depth: 0, Proto: "HTTP/1.1", Headers: "0-5", Cookie: "nocookie"}
+	h2 := State{CodeClass: "4xx", SizeBucket: "100", Depth: 0, Proto: "HTTP/2.0", Headers: "0-5", Cookie: "nocookie"}
+	found := State{CodeClass: "2xx", SizeBucket: "1000", Depth: 0, Proto: "HTTP/1.1", Headers: "6-15", Cookie: "cookie"}
+	mip.AddTransition(h1, Action{Token: "admin"}, found, 3.0)
+	mip.AddTransition(h1, Action{Token: "admin"}, found, 3.0)
+	mip.AddTransition(h2, Action{Token: "admin"}, h2, 0)
+	mip.AddTransition(h2, Action{Token: "login.php"}, found, 3.5)
+	return mip
+}
+
+// TestWriteSnapshotFixtures regenerates the chain file fixtures in testdata, and only runs when
+// FFUF_WRITE_FIXTURES is set
+func TestWriteSnapshotFixtures(t *testing.T) {
+	if os.Getenv("FFUF_WRITE_FIXTURES") == "" {
+		t.Skip("set FFUF_WRITE_FIXTURES to regenerate the chain file fixtures")
+	}
+	mip := newSnapshotProvider()
+	for _, name := range []string{"chain_v1.json", "chain_v1.gob"} {
+		if err := mip.SaveChain(filepath.Join("testdata", name)); err != nil {
+			t.Fatalf("Could not write %s: %s", name, err)
+		}
+	}
+}
+
+func TestLoadChainFixtures(t *testing.T) {
+	expected := newSnapshotProvider().MarkovChain
+	for _, name := range []string{"chain_v1.json", "chain_v1.gob"} {
+		mip := newTestProvider()
+		mip.SetGranularity(GranularityFine)
+		warnings, err := mip.LoadChain(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatalf("%s: could not load the chain: %s", name, err)
+		}
+		if len(warnings) > 0 {
+			t.Errorf("%s: expected no warnings, got %v", name, warnings)
+		}
+		mc := mip.MarkovChain
+		if got, want := mc.Stats(), expected.Stats(); got.States != want.States || got.Transitions != want.Transitions || math.Abs(got.MeanReward-want.MeanReward) > 1e-9 {
+			t.Errorf("%s: expected the stats %+v, got %+v", name, want, got)
+		}
+		for state, row := range expected.QTable {
+			for action, q := range row {
+				if got := mc.QTable[state][action]; math.Abs(got-q) > 1e-9 {
+					t.Errorf("%s: expected Q(%s, %s) to be %f, got %f", name, state, action, q, got)
+				}
+			}
+		}
+		h1 := State{CodeClass: "4xx", SizeBucket: "100", Depth: 0, Proto: "HTTP/1.1", Headers: "0-5", Cookie: "nocookie"}
+		if got := mc.GetExpectedReward(h1, "admin"); math.Abs(got-expected.QTable[h1.Hash()]["admin"]) > 1e-9 {
+			t.Errorf("%s: expected the lock-free Q-values to be restored, got %f", name, got)
+		}
+		if features := mc.FeatureQTable[h1.Hash()]; len(features) == 0 {
+			t.Errorf("%s: expected the feature values to be restored", name)
+		}
+	}
+}
+
+func TestSaveChainRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	mip := newSnapshotProvider()
+	for _, name := range []string{"chain.json", "chain.JSON", "chain.gob", "chain"} {
+		filename := filepath.Join(dir, name)
+		if err := mip.SaveChain(filename); err != nil {
+			t.Fatalf("%s: could not save the chain: %s", name, err)
+		}
+		data, _ := os.ReadFile(filename)
+		if isJSON := strings.HasPrefix(string(data), "{"); isJSON != strings.EqualFold(filepath.Ext(name), ".json") {
+			t.Errorf("%s: unexpected encoding, JSON: %t", name, isJSON)
+		}
+		snap, err := LoadSnapshot(filename)
+		if err != nil {
+			t.Fatalf("%s: could not load the chain: %s", name, err)
+		}
+		if snap.Version != SnapshotVersion || snap.Granularity != GranularityFine || snap.RewardConfig != mip.RewardConfigHash() {
+			t.Errorf("%s: unexpected snapshot header: %d %s %s", name, snap.Version, snap.Granularity, snap.RewardConfig)
+		}
+	}
+}
+
+func TestLoadSnapshotVersions(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		content string
+		valid   bool
+	}{
+		{`{"version": 1, "q_table": {}}`, true},
+		{`{"version": 2, "q_table": {}}`, false},
+		{`{"q_table": {}}`, false},
+		{`not a chain`, false},
+	} {
+		filename := filepath.Join(dir, "chain.json")
+		if err := os.WriteFile(filename, []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		snap, err := LoadSnapshot(filename)
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid to be %t, got error %v", tc.content, tc.valid, err)
+		}
+		if err == nil && snap.Granularity != GranularityDefault {
+			t.Errorf("%s: expected a missing granularity to be the default one, got %s", tc.content, snap.Granularity)
+		}
+	}
+}
+
+func TestLoadChainDowngrade(t *testing.T) {
+	mip := newTestProvider()
+	mip.SetGranularity(GranularityDefault)
+	warnings, err := mip.LoadChain(filepath.Join("testdata", "chain_v1.json"))
+	if err != nil {
+		t.Fatalf("Could not load the chain: %s", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "downgraded") {
+		t.Errorf("Expected a downgrade warning, got %v", warnings)
+	}
+	mc := mip.MarkovChain
+	merged := "4xx_100_0"
+	if len(mc.QTable) != 1 || mc.QTable[merged] == nil {
+		t.Fatalf("Expected the two protocol states to be merged into %s, got %v", merged, mc.QTable)
+	}
+	if count := mc.ActionCounts[merged]["admin"]; count != 3 {
+		t.Errorf("Expected the action counts to be summed to 3, got %d", count)
+	}
+	stat := mc.RewardStats[merged]["admin"]
+	if stat.Count != 3 || math.Abs(stat.Mean-2.0) > 1e-9 || math.Abs(stat.Variance()-3.0) > 1e-9 {
+		t.Errorf("Expected the reward statistics of 3, 3 and 0, got %+v", stat)
+	}
+	fine := newSnapshotProvider().MarkovChain.QTable
+	want := (fine["4xx_100_0_HTTP/1.1_h0-5_nocookie"]["admin"]*2 + fine["4xx_100_0_HTTP/2.0_h0-5_nocookie"]["admin"]) / 3
+	if got := mc.QTable[merged]["admin"]; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Expected the Q-value to be the count weighted mean %f, got %f", want, got)
+	}
+	if count := mc.TransitionCounts[merged]["admin"]["2xx_1000_0"]; count != 2 {
+		t.Errorf("Expected the next states to be projected too, got %v", mc.TransitionCounts[merged]["admin"])
+	}
+	if actions := mc.AvailableActions[merged]; len(actions) != 2 {
+		t.Errorf("Expected the union of the available actions, got %v", actions)
+	}
+}
+
+func TestLoadChainRefusesUpgrade(t *testing.T) {
+	dir := t.TempDir()
+	coarse := newTestProvider()
+	coarse.SetGranularity(GranularityCoarse)
+	coarse.AddTransition(State{CodeClass: "4xx"}, Action{Token: "admin"}, State{CodeClass: "2xx"}, 3.0)
+	filename := filepath.Join(dir, "coarse.gob")
+	if err := coarse.SaveChain(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	mip := newTestProvider()
+	mip.SetGranularity(GranularityFine)
+	if _, err := mip.LoadChain(filename); err == nil {
+		t.Errorf("Expected a coarse chain to be refused by a fine run")
+	}
+	if stats := mip.MarkovChain.Stats(); stats.States != 0 {
+		t.Errorf("Expected the chain to be left untouched, got %+v", stats)
+	}
+}
+
+func TestLoadChainRewardConfig(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "chain.json")
+	if err := newSnapshotProvider().SaveChain(filename); err != nil {
+		t.Fatal(err)
+	}
+	mip := newTestProvider()
+	mip.SetGranularity(GranularityFine)
+	mip.SetErrorRewards(1.0, 0)
+	warnings, err := mip.LoadChain(filename)
+	if err != nil {
+		t.Fatalf("Could not load the chain: %s", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "reward configuration") {
+		t.Errorf("Expected a reward configuration warning, got %v", warnings)
+	}
+	if mip.MarkovChain.Stats().Transitions != 4 {
+		t.Errorf("Expected the chain to be loaded anyway, got %+v", mip.MarkovChain.Stats())
+	}
+}
+
+func TestReloadChainConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "sibling.json")
+	if err := newSnapshotProvider().SaveChain(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	// The live provider keeps learning from responses while the sibling chain is merged into it
+	mip := newTestProvider()
+	mip.SetGranularity(GranularityFine)
+	var wg sync.WaitGroup
+	updates := 200
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				token := fmt.Sprintf("word%d", i%20)
+				mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(token)}, &Response{StatusCode: 200, ContentLength: 1000})
+			}
+		}(w)
+	}
+	reloads := 5
+	for i := 0; i < reloads; i++ {
+		states, transitions, err := mip.ReloadChain(filename)
+		if err != nil {
+			t.Fatalf("Could not reload the chain: %s", err)
+		}
+		if transitions != 4 || (i == 0 && states != 2) || (i > 0 && states != 0) {
+			t.Errorf("Reload %d: unexpected %d new states and %d transitions added", i, states, transitions)
+		}
+	}
+	wg.Wait()
+
+	mc := mip.MarkovChain
+	total := 0
+	for _, row := range mc.ActionCounts {
+		for _, count := range row {
+			total += count
+		}
+	}
+	if stats := mc.Stats(); int(stats.Transitions) != total || total != 4*updates+4*reloads {
+		t.Errorf("Expected the counters to add up to %d transitions, got %+v and %d counted", 4*updates+4*reloads, stats, total)
+	}
+	h1 := State{CodeClass: "4xx", SizeBucket: "100", Depth: 0, Proto: "HTTP/1.1", Headers: "0-5", Cookie: "nocookie"}
+	if count := mc.RewardStats[h1.Hash()]["admin"].Count; count != 2*reloads {
+		t.Errorf("Expected the reward statistics to be merged, got %d rewards", count)
+	}
+	want := newSnapshotProvider().MarkovChain.QTable[h1.Hash()]["admin"]
+	if got := mc.GetExpectedReward(h1, "admin"); math.Abs(got-want) > 1e-9 {
+		t.Errorf("Expected merging the same values to keep the Q-value %f, got %f", want, got)
+	}
+}
+
+func TestReloadChainRejected(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "chain.gob")
+	if err := newSnapshotProvider().SaveChain(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	mip := newTestProvider()
+	if _, _, err := mip.ReloadChain(filename); err == nil {
+		t.Errorf("Expected a chain with a different granularity to be rejected")
+	}
+	mip.SetGranularity(GranularityFine)
+	atomic.StoreInt32(&mip.saving, 1)
+	if _, _, err := mip.ReloadChain(filename); err == nil {
+		t.Errorf("Expected the reload to be rejected while saving")
+	}
+	if err := mip.SaveChain(filepath.Join(dir, "other.gob")); err == nil {
+		t.Errorf("Expected a concurrent save to be rejected")
+	}
+	atomic.StoreInt32(&mip.saving, 0)
+	if _, _, err := mip.ReloadChain(filename); err != nil {
+		t.Errorf("Expected the reload to succeed, got %s", err)
+	}
+}
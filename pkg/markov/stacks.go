@@ -0,0 +1,200 @@
+package markov
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Stacks of the tokens of the big combined wordlists, named after the platforms of the technology guess of ffuf
+const (
+	StackPHP    = "PHP"
+	StackASPNET = "ASP.NET"
+	StackJava   = "Java"
+	StackNode   = "Node/Express"
+)
+
+// stackMaxPush is the share of the batch the inputs of another stack than the target's are pushed back by at full
+// confidence
+const stackMaxPush = 1.0
+
+// stackTokens are the tokens specific to a stack, lower case. A token made of several path segments is tagged by its
+// first segment.
+var stackTokens = map[string]string{
+	// PHP, WordPress, Drupal and Joomla
+	"wp-admin": StackPHP, "wp-content": StackPHP, "wp-includes": StackPHP, "wp-json": StackPHP,
+	"wp-login.php": StackPHP, "wp-config.php": StackPHP, "xmlrpc.php": StackPHP, "wp-cron.php": StackPHP,
+	"phpmyadmin": StackPHP, "phpinfo.php": StackPHP, "composer.json": StackPHP, "composer.lock": StackPHP,
+	"drupal": StackPHP, "joomla": StackPHP, "wordpress": StackPHP, "artisan": StackPHP,
+	// ASP.NET
+	"web.config": StackASPNET, "app_data": StackASPNET, "app_code": StackASPNET, "app_themes": StackASPNET,
+	"aspnet_client": StackASPNET, "trace.axd": StackASPNET, "elmah.axd": StackASPNET, "global.asax": StackASPNET,
+	"webresource.axd": StackASPNET, "_vti_bin": StackASPNET,
+	// Java
+	"web-inf": StackJava, "meta-inf": StackJava, "actuator": StackJava, "jolokia": StackJava, "struts": StackJava,
+	"j_security_check": StackJava, "invoker": StackJava, "jmx-console": StackJava, "web-console": StackJava,
+	"pom.xml": StackJava, "servlet": StackJava,
+	// Node/Express
+	"node_modules": StackNode, "package.json": StackNode, "package-lock.json": StackNode, ".npmrc": StackNode,
+	"yarn.lock": StackNode,
+}
+
+// stackExtensions are the extensions specific to a stack, lower case
+var stackExtensions = map[string]string{
+	"php": StackPHP, "php3": StackPHP, "php4": StackPHP, "php5": StackPHP, "php7": StackPHP, "phtml": StackPHP,
+	"aspx": StackASPNET, "asp": StackASPNET, "ashx": StackASPNET, "asmx": StackASPNET, "axd": StackASPNET,
+	"cshtml": StackASPNET, "svc": StackASPNET,
+	"jsp": StackJava, "jspx": StackJava, "do": StackJava, "action": StackJava, "jsf": StackJava, "faces": StackJava,
+}
+
+// TokenStack returns the stack a token is specific to, empty for the tokens of any stack. The tokens unknown to the
+// bundled tag map are tagged by their extension feature, the way the learned values generalize to the tokens never
+// sent.
+func TokenStack(token string) string {
+	token = strings.ToLower(strings.TrimPrefix(token, "/"))
+	if stack, ok := stackTokens[token]; ok {
+		return stack
+	}
+	if i := strings.Index(token, "/"); i > 0 {
+		if stack, ok := stackTokens[token[:i]]; ok {
+			return stack
+		}
+	}
+	segment := token[strings.LastIndex(token, "/")+1:]
+	for _, f := range TokenFeatures(segment) {
+		if f.Name == "ext" {
+			return stackExtensions[f.Value]
+		}
+	}
+	return ""
+}
+
+// stackPreference is the stack of the target, the inputs of the other stacks being sent later
+type stackPreference struct {
+	stack         string
+	confidence    float64
+	deprioritized map[string]bool // tokens pushed back
+	byStack       map[string]int  // number of tokens pushed back by the stack they are tagged to
+}
+
+// SetStackPreference deprioritizes the inputs tagged to another stack than the one the target is running, by the
+// confidence of the guess from 0 to 1: the inputs of another stack are pushed back in the batches by that share of the
+// batch, the ones at full confidence being sent at the end of their batch. They are never left out. An empty stack
+// stops deprioritizing.
+func (mip *MarkovInputProvider) SetStackPreference(stack string, confidence float64) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	if confidence < 0 {
+		confidence = 0
+	} else if confidence > 1 {
+		confidence = 1
+	}
+	mip.stacks.stack = stack
+	mip.stacks.confidence = confidence
+}
+
+// StackDeprioritized returns the number of tokens deprioritized as they are specific to another stack than the
+// target's, and the number of them by stack
+func (mip *MarkovInputProvider) StackDeprioritized() (int, map[string]int) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	byStack := make(map[string]int, len(mip.stacks.byStack))
+	for s, n := range mip.stacks.byStack {
+		byStack[s] = n
+	}
+	return len(mip.stacks.deprioritized), byStack
+}
+
+// StackSummary returns the number of tokens deprioritized by stack, the stacks with the most first. Empty if none was.
+func (mip *MarkovInputProvider) StackSummary() string {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	byStack := mip.stacks.byStack
+	if len(mip.stacks.deprioritized) == 0 {
+		return ""
+	}
+	stacks := make([]string, 0, len(byStack))
+	for s := range byStack {
+		stacks = append(stacks, s)
+	}
+	sort.Slice(stacks, func(i, j int) bool {
+		if byStack[stacks[i]] != byStack[stacks[j]] {
+			return byStack[stacks[i]] > byStack[stacks[j]]
+		}
+		return stacks[i] < stacks[j]
+	})
+	parts := make([]string, 0, len(stacks))
+	for _, s := range stacks {
+		parts = append(parts, fmt.Sprintf("%d %s", byStack[s], s))
+	}
+	return fmt.Sprintf("deprioritized %d tokens of other stacks than %s: %s", len(mip.stacks.deprioritized), mip.stacks.stack, strings.Join(parts, ", "))
+}
+
+// deprioritizeStacks pushes the inputs of the batch from the given index on tagged to another stack than the target's
+// back by the confidence of the stack preference, the other inputs keeping their order. Must be called with the mutex
+// held.
+func (mip *MarkovInputProvider) deprioritizeStacks(from int) {
+	pref := &mip.stacks
+	n := len(mip.currentBatch) - from
+	if pref.stack == "" || pref.confidence == 0 || n <= 1 {
+		return
+	}
+	push := int(pref.confidence * stackMaxPush * float64(n))
+	if push == 0 {
+		return
+	}
+	keys := make([]int, n)
+	tagged := make([]string, n)
+	moved := false
+	for i := range keys {
+		keys[i] = i
+		token := mip.actionFromInputs(mip.currentBatch[from+i]).Token
+		if stack := TokenStack(token); stack != "" && stack != pref.stack {
+			keys[i] += push
+			tagged[i] = stack
+			moved = true
+			if pref.deprioritized == nil {
+				pref.deprioritized = make(map[string]bool)
+				pref.byStack = make(map[string]int)
+			}
+			if !pref.deprioritized[token] {
+				pref.deprioritized[token] = true
+				pref.byStack[stack]++
+			}
+		}
+	}
+	if !moved {
+		return
+	}
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	// The inputs of another stack go after the inputs of their pushed back position
+	sort.SliceStable(order, func(a, b int) bool {
+		ka, kb := keys[order[a]], keys[order[b]]
+		if ka != kb {
+			return ka < kb
+		}
+		return tagged[order[a]] == "" && tagged[order[b]] != ""
+	})
+	batch := append([]map[string][]byte(nil), mip.currentBatch[from:]...)
+	positions := append([]int(nil), mip.batchPositions[from:]...)
+	decisions := append([]string(nil), mip.batchDecisions[from:]...)
+	for i, o := range order {
+		mip.currentBatch[from+i] = batch[o]
+		mip.batchPositions[from+i] = positions[o]
+		mip.batchDecisions[from+i] = stackDecision(decisions[o], tagged[o], pref)
+	}
+}
+
+// stackDecision returns the decision of an input pushed back as it is tagged to another stack than the target's
+func stackDecision(decision string, stack string, pref *stackPreference) string {
+	if stack == "" {
+		return decision
+	}
+	return fmt.Sprintf("%s, deprioritized as %s while the target looks like %s (confidence %.0f%%)", decision, stack, pref.stack, 100*pref.confidence)
+}
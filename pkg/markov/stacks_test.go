@@ -0,0 +1,130 @@
+package markov
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTokenStack(t *testing.T) {
+	tests := map[string]string{
+		"wp-admin":                  StackPHP,
+		"WP-Content/uploads":        StackPHP,
+		"index.php":                 StackPHP,
+		"default.aspx":              StackASPNET,
+		"/Web.config":               StackASPNET,
+		"WEB-INF/web.xml":           StackJava,
+		"login.do":                  StackJava,
+		"admin/login.jsp":           StackJava,
+		"node_modules":              StackNode,
+		"admin":                     "",
+		"index.html":                "",
+		"backup.tar.gz":             "",
+		"":                          "",
+		"api/v1/users.php.bak":      "",
+		"assets/app.js":             "",
+		"static/wp-admin/index.htm": "",
+	}
+	for token, expected := range tests {
+		if got := TokenStack(token); got != expected {
+			t.Errorf("%q: expected %q, got %q", token, expected, got)
+		}
+	}
+}
+
+// stackWords returns a combined wordlist of n words, every fourth one of another stack than ASP.NET
+func stackWords(n int) []string {
+	others := []string{"wp-admin/%d", "index%d.php", "login%d.jsp", "node_modules/%d", "xmlrpc%d.php", "WEB-INF/%d", "action%d.do"}
+	words := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		switch {
+		case i%4 == 3:
+			words = append(words, fmt.Sprintf(others[i/4%len(others)], i))
+		case i%8 == 1:
+			words = append(words, fmt.Sprintf("page%d.aspx", i))
+		default:
+			words = append(words, fmt.Sprintf("word%d", i))
+		}
+	}
+	return words
+}
+
+// sentOrder returns the inputs of the provider in the order it returns them
+func sentOrder(mip *MarkovInputProvider) []string {
+	sent := make([]string, 0)
+	for mip.Next() {
+		sent = append(sent, string(mip.Value()["FUZZ"]))
+	}
+	return sent
+}
+
+// meanPosition returns the mean position of the tokens of another stack than ASP.NET in the sent order
+func meanPosition(sent []string) float64 {
+	sum, n := 0, 0
+	for i, token := range sent {
+		if s := TokenStack(token); s != "" && s != StackASPNET {
+			sum += i
+			n++
+		}
+	}
+	return float64(sum) / float64(n)
+}
+
+func TestStackPreference(t *testing.T) {
+	words := stackWords(200)
+	plain := sentOrder(newTestProvider(words...))
+	positions := make([]float64, 0)
+	for _, confidence := range []float64{0.6, 1} {
+		mip := newTestProvider(words...)
+		mip.SetStackPreference(StackASPNET, confidence)
+		sent := sentOrder(mip)
+
+		// Nothing is left out
+		if len(sent) != len(words) {
+			t.Fatalf("%.1f: expected the %d inputs sent, got %d", confidence, len(words), len(sent))
+		}
+		sorted, expected := append([]string(nil), sent...), append([]string(nil), words...)
+		sort.Strings(sorted)
+		sort.Strings(expected)
+		if strings.Join(sorted, ",") != strings.Join(expected, ",") {
+			t.Errorf("%.1f: expected every input sent once", confidence)
+		}
+		positions = append(positions, meanPosition(sent))
+
+		total, byStack := mip.StackDeprioritized()
+		if total != 50 || byStack[StackASPNET] != 0 || byStack[StackPHP] == 0 || byStack[StackJava] == 0 || byStack[StackNode] == 0 {
+			t.Errorf("%.1f: expected the 50 tokens of the other stacks deprioritized, got %d: %v", confidence, total, byStack)
+		}
+		if summary := mip.StackSummary(); !strings.HasPrefix(summary, "deprioritized 50 tokens of other stacks than ASP.NET: ") {
+			t.Errorf("Unexpected summary: %s", summary)
+		}
+	}
+	before := meanPosition(plain)
+	if !(positions[0] > before && positions[1] > positions[0]) {
+		t.Errorf("Expected the other stacks sent later as the confidence grows, got mean positions %.1f without, %v with", before, positions)
+	}
+
+	// The ASP.NET tokens keep their order among the other ones
+	mip := newTestProvider(words...)
+	mip.SetStackPreference(StackASPNET, 1)
+	last := -1
+	for i, token := range sentOrder(mip) {
+		if strings.HasSuffix(token, ".aspx") {
+			if i < last {
+				t.Errorf("Expected the tokens of the target stack not pushed back")
+			}
+			last = i
+		}
+	}
+
+	// No preference, no change
+	mip = newTestProvider(words...)
+	mip.SetStackPreference("", 1)
+	if sent := sentOrder(mip); strings.Join(sent, ",") != strings.Join(plain, ",") {
+		t.Errorf("Expected the order unchanged without a stack preference")
+	}
+	if total, _ := mip.StackDeprioritized(); total != 0 || mip.StackSummary() != "" {
+		t.Errorf("Expected nothing deprioritized without a stack preference, got %d", total)
+	}
+}
@@ -0,0 +1,52 @@
+package markov
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+
+	"golang.org/x/net/html"
+)
+
+// structuralHashLimit is the number of body bytes read by GetStructuralHash, the rest of the body being ignored
+const structuralHashLimit = 1024 * 1024
+
+// GetStructuralHash creates a hash of the skeleton of an HTML body in the format of GetSizeHash. The text, comments
+// and attribute values are left out, keeping the tag names, the attribute names and the values of the id and class
+// attributes only, so that the pages of a template differing by a CSRF token or a timestamp get the same hash.
+func GetStructuralHash(data []byte) string {
+	if len(data) == 0 {
+		return "0"
+	}
+
+	h := fnv.New64a()
+	z := html.NewTokenizer(io.LimitReader(bytes.NewReader(data), structuralHashLimit))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return fmt.Sprintf("%x", h.Sum64())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			h.Write([]byte{'<'})
+			h.Write(name)
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				h.Write([]byte{' '})
+				h.Write(key)
+				if string(key) == "id" || string(key) == "class" {
+					h.Write([]byte{'='})
+					h.Write(val)
+				}
+			}
+			h.Write([]byte{'>'})
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			h.Write([]byte("</"))
+			h.Write(name)
+			h.Write([]byte{'>'})
+		}
+	}
+}
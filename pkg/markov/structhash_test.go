@@ -0,0 +1,32 @@
+package markov
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStructuralHash(t *testing.T) {
+	page := `<html><head><title>Login</title></head><body><form id="login" class="form">` +
+		`<input type="hidden" name="csrf" value="%s"><input name="user"><p>Generated at %s</p></form></body></html>`
+	first := []byte(fmt.Sprintf(page, "a1b2c3", "10:00:01"))
+	second := []byte(fmt.Sprintf(page, "ffee99887766", "10:00:02"))
+	if GetSizeHash(first) == GetSizeHash(second) {
+		t.Fatalf("Expected the full body hashes of the pages to differ")
+	}
+	if GetStructuralHash(first) != GetStructuralHash(second) {
+		t.Errorf("Expected the pages differing only by a CSRF token and a timestamp to have the same structural hash")
+	}
+
+	other := []byte(`<html><head><title>Login</title></head><body><div id="error" class="alert">Not found</div></body></html>`)
+	if GetStructuralHash(first) == GetStructuralHash(other) {
+		t.Errorf("Expected different templates to have different structural hashes")
+	}
+	renamed := []byte(fmt.Sprintf(`<html><head><title>Login</title></head><body><form id="signup" class="form">`+
+		`<input type="hidden" name="csrf" value="%s"><input name="user"><p>Generated at %s</p></form></body></html>`, "a1b2c3", "10:00:01"))
+	if GetStructuralHash(first) == GetStructuralHash(renamed) {
+		t.Errorf("Expected the ids to be part of the structural hash")
+	}
+	if GetStructuralHash(nil) != "0" {
+		t.Errorf("Expected the hash of an empty body to match GetSizeHash")
+	}
+}
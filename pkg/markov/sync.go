@@ -0,0 +1,276 @@
+package markov
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSyncLockTimeout is how long a sync waits for the lock of the shared chain file before giving up
+	DefaultSyncLockTimeout = 30 * time.Second
+	// DefaultSyncStaleLock is the age after which the lock of the shared chain file is considered left over by a
+	// crashed instance and broken
+	DefaultSyncStaleLock = 5 * time.Minute
+)
+
+// ChainSync shares the learning of the chain of a provider with the other instances syncing through the same chain
+// file, for example the instances fuzzing the shards of a wordlist on several machines. Each sync merges what the
+// chain learned since the previous sync into the shared file, and merges what the other instances added to the file
+// since then into the chain. The chain the provider starts with, like a chain loaded from a file, is not shared.
+type ChainSync struct {
+	filename string
+	mip      *MarkovInputProvider
+	synced   ChainSnapshot // the chain as of the last sync, the learning imported by it included
+	shared   ChainSnapshot // the shared chain as written by the last sync
+	mutex    sync.Mutex
+	// LockTimeout and StaleLock bound the wait for the lock file next to the shared chain file. The lock is a
+	// file created exclusively rather than an advisory lock, which NFS does not reliably support.
+	LockTimeout time.Duration
+	StaleLock   time.Duration
+}
+
+// NewChainSync returns a sync of the chain of the provider through the shared chain file
+func NewChainSync(filename string, mip *MarkovInputProvider) *ChainSync {
+	return &ChainSync{
+		filename:    filename,
+		mip:         mip,
+		synced:      mip.MarkovChain.Snapshot(mip.RewardConfigHash()),
+		LockTimeout: DefaultSyncLockTimeout,
+		StaleLock:   DefaultSyncStaleLock,
+	}
+}
+
+// Sync merges the learning of the chain since the last sync into the shared chain file, and the learning added to
+// the file by the other instances into the chain. The chain is only locked while the snapshot is taken and the
+// learning of the others is merged, never while waiting for the file. Returns the number of states new to the
+// chain and the number of transitions imported.
+func (s *ChainSync) Sync() (int, int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current := s.mip.MarkovChain.Snapshot(s.mip.RewardConfigHash())
+	own := current.Since(s.synced)
+
+	unlock, err := lockFile(s.filename+".lock", s.LockTimeout, s.StaleLock)
+	if err != nil {
+		return 0, 0, err
+	}
+	shared := ChainSnapshot{Version: SnapshotVersion, Granularity: current.Granularity, SizeBuckets: current.SizeBuckets, RewardConfig: current.RewardConfig}
+	if _, err := os.Stat(s.filename); err == nil {
+		shared, err = LoadSnapshot(s.filename)
+		if err != nil {
+			unlock()
+			return 0, 0, err
+		}
+	}
+	if shared.Granularity != current.Granularity {
+		unlock()
+		return 0, 0, fmt.Errorf("the shared chain in %s was learned with the %s state granularity, the run uses %s", s.filename, shared.Granularity, current.Granularity)
+	}
+	if err := s.mip.adoptBuckets(shared.SizeBuckets); err != nil {
+		unlock()
+		return 0, 0, fmt.Errorf("the shared chain in %s was %s", s.filename, err)
+	}
+	others := shared.Since(s.shared)
+	merged := MergeSnapshots(shared, own)
+	err = saveSnapshotAtomic(s.filename, merged)
+	unlock()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	states, transitions := s.mip.MarkovChain.Merge(others)
+	s.synced = MergeSnapshots(current, others)
+	s.shared = merged
+	return states, transitions, nil
+}
+
+// MergeSnapshots returns a snapshot of the learning of both snapshots, merged like Merge does. The granularity and
+// reward configuration are the ones of the first snapshot.
+func MergeSnapshots(a ChainSnapshot, b ChainSnapshot) ChainSnapshot {
+	mc := NewMarkovChain()
+	mc.Granularity = a.Granularity
+	// A chain still warming up its adaptive buckets takes the ones learned by the other
+	scheme := a.SizeBuckets
+	if scheme == BucketsAdaptive && strings.HasPrefix(b.SizeBuckets, BucketsAdaptive+":") {
+		scheme = b.SizeBuckets
+	}
+	if buckets, err := ParseBucketizer(scheme); err == nil {
+		mc.Buckets = buckets
+	}
+	mc.Merge(a)
+	mc.Merge(b)
+	return mc.Snapshot(a.RewardConfig)
+}
+
+// Since returns the learning of the snapshot that is not in base, an earlier snapshot of the same chain: the
+// counts and reward statistics added since, along with the current values of the actions and features observed
+// since. Merging it into base gives back the snapshot, the values being merged as weighted means.
+func (snap ChainSnapshot) Since(base ChainSnapshot) ChainSnapshot {
+	out := ChainSnapshot{
+		Version:          SnapshotVersion,
+		Granularity:      snap.Granularity,
+		SizeBuckets:      snap.SizeBuckets,
+		RewardConfig:     snap.RewardConfig,
+		QTable:           make(map[string]map[string]float64),
+		TransitionCounts: make(map[string]map[string]map[string]int),
+		ActionCounts:     make(map[string]map[string]int),
+		StateCounts:      make(map[string]int),
+		ClassCounts:      make(map[string]int),
+		AvailableActions: make(map[string][]string),
+		RewardStats:      make(map[string]map[string]RewardStat),
+		FeatureQTable:    make(map[string]map[string]float64),
+		FeatureCounts:    make(map[string]map[string]int),
+		MethodRewards:    make(map[string]RewardStat),
+	}
+	for state, row := range snap.QTable {
+		for action, q := range row {
+			_, known := base.QTable[state][action]
+			added := snap.ActionCounts[state][action] - base.ActionCounts[state][action]
+			if known && added <= 0 {
+				continue
+			}
+			if out.QTable[state] == nil {
+				out.QTable[state] = make(map[string]float64)
+				out.ActionCounts[state] = make(map[string]int)
+			}
+			out.QTable[state][action] = q
+			if added > 0 {
+				out.ActionCounts[state][action] = added
+			}
+		}
+	}
+	for state, actions := range snap.TransitionCounts {
+		for action, next := range actions {
+			for nextState, count := range next {
+				added := count - base.TransitionCounts[state][action][nextState]
+				if added <= 0 {
+					continue
+				}
+				if out.TransitionCounts[state] == nil {
+					out.TransitionCounts[state] = make(map[string]map[string]int)
+				}
+				if out.TransitionCounts[state][action] == nil {
+					out.TransitionCounts[state][action] = make(map[string]int)
+				}
+				out.TransitionCounts[state][action][nextState] = added
+			}
+		}
+	}
+	for state, count := range snap.StateCounts {
+		if added := count - base.StateCounts[state]; added > 0 {
+			out.StateCounts[state] = added
+		}
+	}
+	for class, count := range snap.ClassCounts {
+		if added := count - base.ClassCounts[class]; added > 0 {
+			out.ClassCounts[class] = added
+		}
+	}
+	for state, actions := range snap.AvailableActions {
+		for _, action := range actions {
+			if !containsString(base.AvailableActions[state], action) {
+				out.AvailableActions[state] = append(out.AvailableActions[state], action)
+			}
+		}
+	}
+	for state, row := range snap.RewardStats {
+		for action, stat := range row {
+			if added := stat.without(base.RewardStats[state][action]); added.Count > 0 {
+				if out.RewardStats[state] == nil {
+					out.RewardStats[state] = make(map[string]RewardStat)
+				}
+				out.RewardStats[state][action] = added
+			}
+		}
+	}
+	for method, stat := range snap.MethodRewards {
+		if added := stat.without(base.MethodRewards[method]); added.Count > 0 {
+			out.MethodRewards[method] = added
+		}
+	}
+	for state, row := range snap.FeatureQTable {
+		for f, v := range row {
+			added := snap.FeatureCounts[state][f] - base.FeatureCounts[state][f]
+			if added <= 0 {
+				continue
+			}
+			if out.FeatureQTable[state] == nil {
+				out.FeatureQTable[state] = make(map[string]float64)
+				out.FeatureCounts[state] = make(map[string]int)
+			}
+			out.FeatureQTable[state][f] = v
+			out.FeatureCounts[state][f] = added
+		}
+	}
+	return out
+}
+
+// without returns the statistics of the rewards of r that are not in base, base being a part of them
+func (r RewardStat) without(base RewardStat) RewardStat {
+	n := r.Count - base.Count
+	if n <= 0 {
+		return RewardStat{}
+	}
+	mean := (r.Mean*float64(r.Count) - base.Mean*float64(base.Count)) / float64(n)
+	delta := mean - base.Mean
+	m2 := r.M2 - base.M2 - delta*delta*float64(base.Count)*float64(n)/float64(r.Count)
+	if m2 < 0 {
+		m2 = 0
+	}
+	return RewardStat{Count: n, Mean: mean, M2: m2}
+}
+
+// saveSnapshotAtomic writes the snapshot to a temporary file next to the file and renames it over the file, for the
+// readers to never see a partly written chain
+func saveSnapshotAtomic(filename string, snap ChainSnapshot) error {
+	ext := filepath.Ext(filename)
+	tmp, err := os.CreateTemp(filepath.Dir(filename), strings.TrimSuffix(filepath.Base(filename), ext)+".*"+ext)
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	if err := SaveSnapshot(tmp.Name(), snap); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// lockFile takes the lock by creating the lock file exclusively, retrying with a backoff until the timeout. A lock
+// file older than stale is assumed to be left over by a crashed instance and removed. Returns the function
+// releasing the lock.
+func lockFile(path string, timeout time.Duration, stale time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+	backoff := 10 * time.Millisecond
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > stale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for the lock %s", path)
+		}
+		time.Sleep(backoff)
+		if backoff < time.Second {
+			backoff *= 2
+		}
+	}
+}
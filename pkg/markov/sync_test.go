@@ -0,0 +1,96 @@
+package markov
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChainSyncWorkers(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "shared.json")
+	baseline := State{CodeClass: "4xx", SizeBucket: QuantizeSize(139), Depth: 0}
+	found := State{CodeClass: "2xx", SizeBucket: QuantizeSize(1000), Depth: 0}
+	const workers = 3
+	const rounds = 5
+
+	syncs := make([]*ChainSync, workers)
+	for w := range syncs {
+		syncs[w] = NewChainSync(filename, newTestProvider())
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds)
+	for w := range syncs {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			mip := syncs[w].mip
+			for r := 0; r < rounds; r++ {
+				// Every worker learns admin, and a token of its own
+				mip.AddTransition(baseline, Action{Token: "admin"}, found, 3.0)
+				mip.AddTransition(baseline, Action{Token: fmt.Sprintf("shard%d", w)}, baseline, 0)
+				if _, _, err := syncs[w].Sync(); err != nil {
+					errs <- err
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Unexpected sync error: %s", err)
+	}
+	// A last round for every worker to import the learning synced after its own last sync
+	for _, s := range syncs {
+		if _, _, err := s.Sync(); err != nil {
+			t.Fatalf("Unexpected sync error: %s", err)
+		}
+	}
+
+	shared, err := LoadSnapshot(filename)
+	if err != nil {
+		t.Fatalf("Could not load the shared chain: %s", err)
+	}
+	state := baseline.Hash()
+	admin := Action{Token: "admin"}.Key()
+	if count := shared.ActionCounts[state][admin]; count != workers*rounds {
+		t.Errorf("Expected the counts of admin to be summed to %d in the shared chain, got %d", workers*rounds, count)
+	}
+	if stat := shared.RewardStats[state][admin]; stat.Count != workers*rounds || math.Abs(stat.Mean-3.0) > 1e-9 {
+		t.Errorf("Unexpected reward statistics of admin in the shared chain: %+v", stat)
+	}
+	for w, s := range syncs {
+		counts := s.mip.MarkovChain.ActionCounts[state]
+		if counts[admin] != workers*rounds {
+			t.Errorf("Worker %d: expected admin to be counted %d times, got %d", w, workers*rounds, counts[admin])
+		}
+		for other := 0; other < workers; other++ {
+			if key := (Action{Token: fmt.Sprintf("shard%d", other)}).Key(); counts[key] != rounds {
+				t.Errorf("Worker %d: expected shard%d to be counted %d times, got %d", w, other, rounds, counts[key])
+			}
+		}
+	}
+}
+
+func TestChainSyncStaleLock(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "shared.gob")
+	unlock, err := lockFile(filename+".lock", time.Second, time.Hour)
+	if err != nil {
+		t.Fatalf("Could not take the lock: %s", err)
+	}
+	s := NewChainSync(filename, newTestProvider())
+	s.LockTimeout = 50 * time.Millisecond
+	if _, _, err := s.Sync(); err == nil {
+		t.Errorf("Expected the sync to time out while the lock is held")
+	}
+	// A lock older than StaleLock is broken
+	s.StaleLock = 10 * time.Millisecond
+	time.Sleep(20 * time.Millisecond)
+	if _, _, err := s.Sync(); err != nil {
+		t.Errorf("Expected the stale lock to be broken, got %s", err)
+	}
+	unlock()
+}
@@ -0,0 +1,189 @@
+package markov
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+const (
+	// trigramMaxWords is the largest wordlist the trigram index is built for, bounding its memory use
+	trigramMaxWords = 1 << 21
+	// trigramMaxPostings is the largest number of words of a trigram taken into account when searching the
+	// index. Trigrams shared by more words are too common to tell the neighbors apart.
+	trigramMaxPostings = 1 << 15
+	// trigramMinSimilarity is the smallest Jaccard similarity of the trigrams of two words to be neighbors
+	trigramMinSimilarity = 0.3
+)
+
+// TrigramIndex finds the words of a wordlist lexically close to a token, like backups and db-backup for
+// backup, from the trigrams they share. It keeps track of the words that were sent so that they are never
+// suggested, or sent by the wordlist, again.
+type TrigramIndex struct {
+	words    [][]byte
+	postings map[uint32][]int32 // words of each trigram
+	sizes    []uint8            // number of distinct trigrams of each word
+	ids      map[uint64]int32   // word by hash of its value
+	sent     []uint64           // bitset of the words that were sent
+	mutex    sync.Mutex
+}
+
+// trigrams returns the distinct trigrams of a word, lowercased and padded with a marker at both ends
+func trigrams(word []byte, buf []uint32) []uint32 {
+	buf = buf[:0]
+	padded := make([]byte, 0, len(word)+2)
+	padded = append(padded, 0)
+	for _, c := range word {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		padded = append(padded, c)
+	}
+	padded = append(padded, 0)
+	for i := 0; i+3 <= len(padded); i++ {
+		t := uint32(padded[i])<<16 | uint32(padded[i+1])<<8 | uint32(padded[i+2])
+		duplicate := false
+		for _, seen := range buf {
+			if seen == t {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			buf = append(buf, t)
+		}
+	}
+	return buf
+}
+
+func wordHash(word []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(word)
+	return h.Sum64()
+}
+
+// NewTrigramIndex builds the trigram index of a wordlist. Returns nil if the wordlist is too large to index.
+// Duplicate words are indexed once.
+func NewTrigramIndex(words [][]byte) *TrigramIndex {
+	if len(words) > trigramMaxWords {
+		return nil
+	}
+	ix := &TrigramIndex{
+		words:    make([][]byte, 0, len(words)),
+		postings: make(map[uint32][]int32),
+		sizes:    make([]uint8, 0, len(words)),
+		ids:      make(map[uint64]int32, len(words)),
+	}
+	buf := make([]uint32, 0, 32)
+	for _, word := range words {
+		if len(word) == 0 {
+			continue
+		}
+		h := wordHash(word)
+		other, exists := ix.ids[h]
+		if exists && bytes.Equal(ix.words[other], word) {
+			continue
+		}
+		id := int32(len(ix.words))
+		ix.words = append(ix.words, word)
+		if !exists {
+			// Words colliding with another one are still suggested, but not tracked as sent
+			ix.ids[h] = id
+		}
+		buf = trigrams(word, buf)
+		size := len(buf)
+		if size > 255 {
+			size = 255
+		}
+		ix.sizes = append(ix.sizes, uint8(size))
+		for _, t := range buf {
+			ix.postings[t] = append(ix.postings[t], id)
+		}
+	}
+	ix.sent = make([]uint64, len(ix.words)/64+1)
+	return ix
+}
+
+// lookup returns the id of a word of the index, -1 if it is not part of it. Must be called with the mutex held.
+func (ix *TrigramIndex) lookup(word []byte) int32 {
+	if id, exists := ix.ids[wordHash(word)]; exists && bytes.Equal(ix.words[id], word) {
+		return id
+	}
+	return -1
+}
+
+// MarkSent records a word as sent. Returns false if the word was sent before, true otherwise, including for words
+// that are not part of the index.
+func (ix *TrigramIndex) MarkSent(word []byte) bool {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	id := ix.lookup(word)
+	if id < 0 {
+		return true
+	}
+	if ix.sent[id/64]&(1<<(uint(id)%64)) != 0 {
+		return false
+	}
+	ix.sent[id/64] |= 1 << (uint(id) % 64)
+	return true
+}
+
+// ResetSent forgets the words that were sent, when starting over with the wordlist
+func (ix *TrigramIndex) ResetSent() {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	for i := range ix.sent {
+		ix.sent[i] = 0
+	}
+}
+
+// Similar returns up to max words that were not sent yet, most similar to the token first, by the Jaccard
+// similarity of their trigrams
+func (ix *TrigramIndex) Similar(token string, max int) []string {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	query := trigrams([]byte(token), nil)
+	self := ix.lookup([]byte(token))
+	shared := make(map[int32]int)
+	for _, t := range query {
+		postings := ix.postings[t]
+		if len(postings) > trigramMaxPostings {
+			continue
+		}
+		for _, id := range postings {
+			if id != self && ix.sent[id/64]&(1<<(uint(id)%64)) == 0 {
+				shared[id]++
+			}
+		}
+	}
+
+	type neighbor struct {
+		id         int32
+		similarity float64
+	}
+	neighbors := make([]neighbor, 0)
+	for id, n := range shared {
+		similarity := float64(n) / float64(len(query)+int(ix.sizes[id])-n)
+		if similarity >= trigramMinSimilarity {
+			neighbors = append(neighbors, neighbor{id: id, similarity: similarity})
+		}
+	}
+	sort.Slice(neighbors, func(i, j int) bool {
+		if neighbors[i].similarity != neighbors[j].similarity {
+			return neighbors[i].similarity > neighbors[j].similarity
+		}
+		return neighbors[i].id < neighbors[j].id
+	})
+	if len(neighbors) > max {
+		neighbors = neighbors[:max]
+	}
+	similar := make([]string, 0, len(neighbors))
+	for _, n := range neighbors {
+		similar = append(similar, string(ix.words[n.id]))
+	}
+	return similar
+}
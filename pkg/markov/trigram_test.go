@@ -0,0 +1,53 @@
+package markov
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestIndex(words ...string) *TrigramIndex {
+	data := make([][]byte, 0, len(words))
+	for _, w := range words {
+		data = append(data, []byte(w))
+	}
+	return NewTrigramIndex(data)
+}
+
+func TestTrigramSimilar(t *testing.T) {
+	ix := newTestIndex("admin", "backup", "index.php", "backups", "db-backup", "backup_old", "Backup", "login", "bak", "backup")
+	similar := ix.Similar("backup", 10)
+	expected := []string{"Backup", "backups", "db-backup", "backup_old"}
+	if !reflect.DeepEqual(similar, expected) {
+		t.Errorf("Expected the neighbors %v, got %v", expected, similar)
+	}
+	if similar := ix.Similar("backup", 2); len(similar) != 2 || similar[1] != "backups" {
+		t.Errorf("Expected the two closest neighbors, got %v", similar)
+	}
+	if similar := ix.Similar("zzz", 10); len(similar) != 0 {
+		t.Errorf("Expected no neighbors for an unrelated token, got %v", similar)
+	}
+}
+
+func TestTrigramSentWords(t *testing.T) {
+	ix := newTestIndex("backup", "backups", "db-backup", "backup_old", "backup")
+	if !ix.MarkSent([]byte("backups")) {
+		t.Errorf("Expected a word not sent yet to be marked")
+	}
+	if ix.MarkSent([]byte("backups")) {
+		t.Errorf("Expected a word sent before to be reported")
+	}
+	if !ix.MarkSent([]byte("backup")) || ix.MarkSent([]byte("backup")) {
+		t.Errorf("Expected duplicate words of the wordlist to be sent once")
+	}
+	if !ix.MarkSent([]byte("unknown")) || !ix.MarkSent([]byte("unknown")) {
+		t.Errorf("Expected words outside of the wordlist never to be reported as sent")
+	}
+	similar := ix.Similar("backup", 10)
+	if !reflect.DeepEqual(similar, []string{"db-backup", "backup_old"}) {
+		t.Errorf("Expected the sent words never to be suggested, got %v", similar)
+	}
+	ix.ResetSent()
+	if len(ix.Similar("backup", 10)) != 3 {
+		t.Errorf("Expected all the neighbors after a reset")
+	}
+}
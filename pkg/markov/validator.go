@@ -0,0 +1,69 @@
+package markov
+
+import (
+	"strings"
+)
+
+// DefaultValidatorReward is the reward bonus of the first response under a path carrying a validator value, see
+// SetValidatorReward
+const DefaultValidatorReward = 0.3
+
+// validatorValue returns the validator of a response: its strong ETag, or its Last-Modified header without one.
+// Static file servers send them for the files they serve, not for the error pages a rewrite rule serves instead.
+// A weak ETag is computed from a generated body by the frameworks, the error pages included, and is no validator.
+func validatorValue(headers map[string][]string) string {
+	lastModified := ""
+	for header, values := range headers {
+		if len(values) == 0 {
+			continue
+		}
+		value := strings.TrimSpace(values[0])
+		switch {
+		case strings.EqualFold(header, "ETag"):
+			if value != "" && !strings.HasPrefix(value, "W/") {
+				return "etag " + value
+			}
+		case strings.EqualFold(header, "Last-Modified"):
+			if value != "" {
+				lastModified = "last-modified " + value
+			}
+		}
+	}
+	return lastModified
+}
+
+// hasValidator returns the validator dimension of the state of a response
+func hasValidator(headers map[string][]string) string {
+	if validatorValue(headers) != "" {
+		return "validator"
+	}
+	return "novalidator"
+}
+
+// SetValidatorReward sets the reward bonus given to the first response carrying a validator value under a path,
+// with the fine granularity preset only
+func (mip *MarkovInputProvider) SetValidatorReward(reward float64) {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	mip.validatorReward = reward
+}
+
+// newValidatorReward returns the validator reward bonus if the response carries a validator value not seen before
+// under the request path. The rewritten pages served for every path with the same validator get it once. Must be
+// called with the mutex held.
+func (mip *MarkovInputProvider) newValidatorReward(resp *Response) float64 {
+	if mip.granularity != GranularityFine || mip.validatorReward == 0 {
+		return 0
+	}
+	value := validatorValue(resp.Headers)
+	if value == "" {
+		return 0
+	}
+	key := resp.Path + " " + value
+	if mip.seenValidators[key] {
+		return 0
+	}
+	mip.seenValidators[key] = true
+	return mip.validatorReward
+}
@@ -0,0 +1,97 @@
+package markov
+
+import (
+	"testing"
+)
+
+func TestValidatorValue(t *testing.T) {
+	tests := []struct {
+		headers  map[string][]string
+		expected string
+	}{
+		{nil, ""},
+		{map[string][]string{"Content-Type": {"text/html"}}, ""},
+		{map[string][]string{"Etag": {`"5f3a-1b2"`}}, `etag "5f3a-1b2"`},
+		{map[string][]string{"ETag": {`"5f3a-1b2"`}, "Last-Modified": {"Mon, 12 Oct 2026 08:00:00 GMT"}}, `etag "5f3a-1b2"`},
+		{map[string][]string{"Last-Modified": {"Mon, 12 Oct 2026 08:00:00 GMT"}}, "last-modified Mon, 12 Oct 2026 08:00:00 GMT"},
+		// The weak ETags of the generated pages are no validators, the Last-Modified header still is
+		{map[string][]string{"Etag": {`W/"2a-9f3b"`}}, ""},
+		{map[string][]string{"Etag": {`W/"2a-9f3b"`}, "Last-Modified": {"Mon, 12 Oct 2026 08:00:00 GMT"}}, "last-modified Mon, 12 Oct 2026 08:00:00 GMT"},
+		{map[string][]string{"Etag": {""}}, ""},
+	}
+	for _, tt := range tests {
+		if got := validatorValue(tt.headers); got != tt.expected {
+			t.Errorf("%v: expected %q, got %q", tt.headers, tt.expected, got)
+		}
+	}
+}
+
+func TestValidatorState(t *testing.T) {
+	tests := []struct {
+		headers map[string][]string
+		state   string
+	}{
+		{map[string][]string{"Etag": {`"5f3a-1b2"`}}, "2xx_2000_0_h0-5_nocookie_validator"},
+		{map[string][]string{"Last-Modified": {"Mon, 12 Oct 2026 08:00:00 GMT"}}, "2xx_2000_0_h0-5_nocookie_validator"},
+		{map[string][]string{"Etag": {`W/"2a-9f3b"`}}, "2xx_2000_0_h0-5_nocookie_novalidator"},
+		{nil, "2xx_2000_0_h0-5_nocookie_novalidator"},
+	}
+	for _, tt := range tests {
+		mip := newTestProvider("index.html")
+		mip.SetHeaderState(true, true)
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("index.html")}, &Response{StatusCode: 200, ContentLength: 2000, Headers: tt.headers})
+		if states := nextStates(mip, "index.html"); len(states) != 1 || states[0] != "2xx_2000_0_h0-5_nocookie" {
+			t.Errorf("%v: the validator should only be part of the state with the fine preset, got %v", tt.headers, states)
+		}
+
+		mip = newTestProvider("index.html")
+		mip.SetGranularity(GranularityFine)
+		mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("index.html")}, &Response{StatusCode: 200, ContentLength: 2000, Headers: tt.headers})
+		if states := nextStates(mip, "index.html"); len(states) != 1 || states[0] != tt.state {
+			t.Errorf("%v: expected the state %s, got %v", tt.headers, tt.state, states)
+		}
+	}
+}
+
+func TestValidatorReward(t *testing.T) {
+	etag := func(value string) map[string][]string { return map[string][]string{"Etag": {value}} }
+	update := func(mip *MarkovInputProvider, token string, headers map[string][]string, path string) float64 {
+		return mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte(token)}, &Response{StatusCode: 200, ContentLength: 2000, Headers: headers, Path: path})
+	}
+	plain := update(newTestProvider("a"), "a", nil, "example.com/static")
+
+	// The bonus is gated behind the fine preset
+	mip := newTestProvider("a")
+	if r := update(mip, "a", etag(`"1"`), "example.com/static"); r != plain {
+		t.Errorf("Expected no validator bonus without the fine preset, got %f (without %f)", r, plain)
+	}
+
+	mip = newTestProvider("a", "b", "c", "d", "e", "f")
+	mip.SetGranularity(GranularityFine)
+	if r := update(mip, "a", etag(`"1"`), "example.com/static"); r != plain+DefaultValidatorReward {
+		t.Errorf("Expected the first validator value under the path rewarded, got %f (without %f)", r, plain)
+	}
+	// A rewritten page served for every path with the same ETag gets the bonus once
+	if r := update(mip, "b", etag(`"1"`), "example.com/static"); r != plain {
+		t.Errorf("Expected a validator value seen under the path not rewarded again, got %f", r)
+	}
+	if r := update(mip, "c", etag(`"2"`), "example.com/static"); r != plain+DefaultValidatorReward {
+		t.Errorf("Expected a new validator value rewarded, got %f", r)
+	}
+	if r := update(mip, "d", etag(`"1"`), "example.com/assets"); r != plain+DefaultValidatorReward {
+		t.Errorf("Expected the validator value rewarded under another path, got %f", r)
+	}
+	if r := update(mip, "e", etag(`W/"3"`), "example.com/static"); r != plain {
+		t.Errorf("Expected a weak ETag not rewarded, got %f", r)
+	}
+	if r := update(mip, "f", nil, "example.com/static"); r != plain {
+		t.Errorf("Expected a response without validator not rewarded, got %f", r)
+	}
+
+	mip = newTestProvider("a")
+	mip.SetGranularity(GranularityFine)
+	mip.SetValidatorReward(0)
+	if r := update(mip, "a", etag(`"1"`), "example.com/static"); r != plain {
+		t.Errorf("Expected no bonus with a validator reward of 0, got %f", r)
+	}
+}
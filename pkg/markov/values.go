@@ -0,0 +1,47 @@
+package markov
+
+// recordValueRewards credits the values of the keywords of a multi keyword input with the reward of its request,
+// for ValueRewards to rank the values of each keyword on their own. Must be called with the mutex held.
+func (mip *MarkovInputProvider) recordValueRewards(inputs map[string][]byte, reward float64) {
+	keywords := 0
+	for keyword := range inputs {
+		if keyword != "FFUFHASH" {
+			keywords++
+		}
+	}
+	if keywords < 2 {
+		return
+	}
+	if mip.valueRewards == nil {
+		mip.valueRewards = make(map[string]map[string]*RewardStat)
+	}
+	for keyword, value := range inputs {
+		if keyword == "FFUFHASH" {
+			continue
+		}
+		if _, ok := mip.valueRewards[keyword]; !ok {
+			mip.valueRewards[keyword] = make(map[string]*RewardStat)
+		}
+		stat, ok := mip.valueRewards[keyword][string(value)]
+		if !ok {
+			stat = &RewardStat{}
+			mip.valueRewards[keyword][string(value)] = stat
+		}
+		stat.Add(reward)
+	}
+}
+
+// ValueRewards returns the mean reward of the requests sent with each of the values of a keyword in the multi
+// keyword inputs, 0 for the values never sent
+func (mip *MarkovInputProvider) ValueRewards(keyword string, values [][]byte) []float64 {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	rewards := make([]float64, len(values))
+	for idx, value := range values {
+		if stat, ok := mip.valueRewards[keyword][string(value)]; ok {
+			rewards[idx] = stat.Mean
+		}
+	}
+	return rewards
+}
@@ -0,0 +1,33 @@
+package markov
+
+import "testing"
+
+func TestValueRewards(t *testing.T) {
+	mip := newTestProvider()
+	send := func(user, pass string, status int64) {
+		inputs := map[string][]byte{"USER": []byte(user), "PASS": []byte(pass), "FFUFHASH": []byte("1")}
+		mip.UpdateWithResponse(inputs, &Response{StatusCode: status, ContentLength: 2000})
+	}
+	send("admin", "secret", 200)
+	send("admin", "123456", 403)
+	send("guest", "123456", 404)
+
+	rewards := mip.ValueRewards("USER", [][]byte{[]byte("guest"), []byte("admin"), []byte("root")})
+	if rewards[1] <= rewards[0] || rewards[2] != 0 {
+		t.Errorf("Expected admin to be ranked over guest and root to be unknown, got %v", rewards)
+	}
+	rewards = mip.ValueRewards("PASS", [][]byte{[]byte("123456"), []byte("secret")})
+	if rewards[1] <= rewards[0] {
+		t.Errorf("Expected secret to be ranked over 123456, got %v", rewards)
+	}
+	if rewards := mip.ValueRewards("FFUFHASH", [][]byte{[]byte("1")}); rewards[0] != 0 {
+		t.Errorf("Expected FFUFHASH to be left out, got %v", rewards)
+	}
+
+	// The values of the single keyword inputs are ranked by the chain itself
+	single := newTestProvider()
+	single.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, &Response{StatusCode: 200, ContentLength: 2000})
+	if rewards := single.ValueRewards("FUZZ", [][]byte{[]byte("admin")}); rewards[0] != 0 {
+		t.Errorf("Expected the single keyword inputs not to be recorded, got %v", rewards)
+	}
+}
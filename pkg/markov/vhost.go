@@ -0,0 +1,129 @@
+package markov
+
+import (
+	"fmt"
+)
+
+// When the Host header is fuzzed, the baseline is the default virtual host: the response served for the hosts the
+// target does not know. The reward of each response is then its deviation from the responses of the default virtual
+// host, from a decision table of bonuses only. A response matching a baseline on all of status, size bucket and
+// certificate gets nothing, and the size bucket of an incomplete response is not compared.
+//
+//	bonus   vhost-cert     TLS certificate served to none of the baseline hosts            +1.5
+//	bonus   vhost-status   status served to none of the baseline hosts                      +1.5
+//	bonus   vhost-size     size bucket served to none of the baseline hosts with the status  +1.0
+//	bonus   vhost-other    a combination of the above no baseline host was served with      +0.5
+var vhostRewardRules = []RewardRule{
+	{Name: "vhost-cert", Delta: 1.5, match: func(in rewardInput) bool { return in.newCert }},
+	{Name: "vhost-status", Delta: 1.5, match: func(in rewardInput) bool { return in.newStatus }},
+	{Name: "vhost-size", Delta: 1.0, match: func(in rewardInput) bool { return in.newSize }},
+	{Name: "vhost-other", Delta: 0.5, match: func(in rewardInput) bool {
+		return in.newState && !in.newCert && !in.newStatus && !in.newSize
+	}},
+}
+
+// VhostBaseline is the key of a response of the default virtual host
+type VhostBaseline struct {
+	StatusCode int64
+	SizeBucket string
+	CertHash   string // empty for plain HTTP
+}
+
+// NewVhostBaseline returns the key of a response of the default virtual host
+func NewVhostBaseline(resp *Response) VhostBaseline {
+	return VhostBaseline{StatusCode: resp.StatusCode, SizeBucket: QuantizeSize(resp.ContentLength), CertHash: resp.CertHash}
+}
+
+// String returns the baseline in a human readable format
+func (b VhostBaseline) String() string {
+	if b.CertHash == "" {
+		return fmt.Sprintf("%d_%s", b.StatusCode, b.SizeBucket)
+	}
+	hash := b.CertHash
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	return fmt.Sprintf("%d_%s_%s", b.StatusCode, b.SizeBucket, hash)
+}
+
+// EvaluateVhostReward returns the reward of a response of a fuzzed virtual host compared to the responses of the
+// default virtual host, along with the rules of the decision table that were applied to get it
+func EvaluateVhostReward(resp *Response, baselines []VhostBaseline) (float64, []RewardRule) {
+	return evaluateVhostReward(vhostRewardRules, resp, baselines)
+}
+
+// evaluateVhostReward returns the reward of a response of a fuzzed virtual host from a decision table
+func evaluateVhostReward(rules []RewardRule, resp *Response, baselines []VhostBaseline) (float64, []RewardRule) {
+	key := NewVhostBaseline(resp)
+	in := rewardInput{status: resp.StatusCode, newCert: true, newStatus: true, newSize: true, newState: true}
+	for _, b := range baselines {
+		if b == key {
+			in.newState = false
+		}
+		if b.CertHash == key.CertHash {
+			in.newCert = false
+		}
+		if b.StatusCode == key.StatusCode {
+			in.newStatus = false
+			if b.SizeBucket == key.SizeBucket {
+				in.newSize = false
+			}
+		}
+	}
+	// The size only tells the hosts apart within a status, and not at all for a response cut off
+	if in.newStatus || resp.Incomplete {
+		in.newSize = false
+	}
+	if resp.Incomplete {
+		in.newState = false
+	}
+	return applyRewardRules(rules, in)
+}
+
+// SetVhostBaselines sets the responses of the default virtual host, requested with random hosts, the rewards being
+// the deviation from them from then on. The first response becomes the baseline state. Returns the number of
+// distinct baselines. The incomplete responses are left out.
+func (mip *MarkovInputProvider) SetVhostBaselines(all []*Response) int {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+
+	responses := make([]*Response, 0, len(all))
+	for _, resp := range all {
+		if !resp.Incomplete {
+			responses = append(responses, resp)
+		}
+	}
+	mip.vhostBaselines = nil
+	for _, resp := range responses {
+		key := NewVhostBaseline(resp)
+		if !containsVhostBaseline(mip.vhostBaselines, key) {
+			mip.vhostBaselines = append(mip.vhostBaselines, key)
+		}
+	}
+	if len(responses) > 0 {
+		bodyHash := responses[0].BodyHash
+		if bodyHash == "" {
+			bodyHash = GetSizeHash(responses[0].Data)
+		}
+		mip.baselineState = mip.stateFromResponse(responses[0])
+		mip.baselineSizeHash = bodyHash
+	}
+	mip.durations = durationCheck{}
+	return len(mip.vhostBaselines)
+}
+
+// VhostBaselines returns the responses of the default virtual host the rewards are the deviation from
+func (mip *MarkovInputProvider) VhostBaselines() []VhostBaseline {
+	mip.mutex.Lock()
+	defer mip.mutex.Unlock()
+	return append([]VhostBaseline{}, mip.vhostBaselines...)
+}
+
+func containsVhostBaseline(baselines []VhostBaseline, key VhostBaseline) bool {
+	for _, b := range baselines {
+		if b == key {
+			return true
+		}
+	}
+	return false
+}
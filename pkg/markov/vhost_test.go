@@ -0,0 +1,61 @@
+package markov
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateVhostReward(t *testing.T) {
+	baselines := []VhostBaseline{
+		{StatusCode: 404, SizeBucket: QuantizeSize(100), CertHash: "wildcard"},
+		{StatusCode: 421, SizeBucket: QuantizeSize(50), CertHash: "wildcard"},
+	}
+	tests := []struct {
+		name     string
+		resp     *Response
+		expected float64
+		rules    string
+	}{
+		{"default virtual host", &Response{StatusCode: 404, ContentLength: 100, CertHash: "wildcard"}, 0, ""},
+		{"other status of the default virtual host", &Response{StatusCode: 421, ContentLength: 50, CertHash: "wildcard"}, 0, ""},
+		{"own certificate", &Response{StatusCode: 404, ContentLength: 100, CertHash: "admin"}, 1.5, "vhost-cert"},
+		{"new status", &Response{StatusCode: 200, ContentLength: 100, CertHash: "wildcard"}, 1.5, "vhost-status"},
+		{"new size", &Response{StatusCode: 404, ContentLength: 8000, CertHash: "wildcard"}, 1.0, "vhost-size"},
+		{"new certificate and status", &Response{StatusCode: 200, ContentLength: 8000, CertHash: "admin"}, 3.0, "vhost-cert,vhost-status"},
+		{"size of the other status", &Response{StatusCode: 404, ContentLength: 50, CertHash: "wildcard"}, 1.0, "vhost-size"},
+	}
+	for _, test := range tests {
+		reward, rules := EvaluateVhostReward(test.resp, baselines)
+		names := make([]string, 0)
+		for _, rule := range rules {
+			names = append(names, rule.Name)
+		}
+		if math.Abs(reward-test.expected) > 1e-9 || strings.Join(names, ",") != test.rules {
+			t.Errorf("%s: reward %f from %v, want %f from %s", test.name, reward, names, test.expected, test.rules)
+		}
+	}
+}
+
+func TestSetVhostBaselines(t *testing.T) {
+	mip := newTestProvider()
+	hash := mip.RewardConfigHash()
+	count := mip.SetVhostBaselines([]*Response{
+		{StatusCode: 404, ContentLength: 100, CertHash: "wildcard"},
+		{StatusCode: 404, ContentLength: 100, CertHash: "wildcard"},
+		{StatusCode: 421, ContentLength: 50, CertHash: "wildcard"},
+	})
+	if count != 2 {
+		t.Errorf("Expected 2 distinct baselines, got %d", count)
+	}
+	if mip.RewardConfigHash() == hash {
+		t.Errorf("Expected the reward configuration hash to change with the virtual host rewards")
+	}
+	// The default virtual host is rewarded nothing, unlike the 404 tier would
+	if reward := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("www")}, &Response{StatusCode: 421, ContentLength: 50, CertHash: "wildcard"}); reward != 0 {
+		t.Errorf("Expected no reward for the default virtual host, got %f", reward)
+	}
+	if reward := mip.UpdateWithResponse(map[string][]byte{"FUZZ": []byte("admin")}, &Response{StatusCode: 421, ContentLength: 50, CertHash: "admin"}); reward != 1.5 {
+		t.Errorf("Expected the certificate bonus for a virtual host with its own certificate, got %f", reward)
+	}
+}
@@ -0,0 +1,146 @@
+package markov
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Aggregations of the Q-values of a token across the states for ActionWeights
+const (
+	WeightsMax  = "max"
+	WeightsMean = "mean"
+)
+
+// TokenWeight is the value of a token to the chain normalized to [0,1], as written by ExportWeights
+type TokenWeight struct {
+	Token  string
+	Weight float64
+}
+
+// ActionWeights returns the tokens of all the actions of the chain with their Q-values aggregated across the states,
+// and the locations of the run, by their max or their mean, normalized to [0,1] from the lowest to the highest of
+// them. The tokens are sorted by weight, best first, and then by token, so that the same chain always gives the same
+// weights.
+func (mip *MarkovInputProvider) ActionWeights(aggregate string) ([]TokenWeight, error) {
+	if aggregate != WeightsMax && aggregate != WeightsMean {
+		return nil, fmt.Errorf("invalid aggregation %q, expected %s or %s", aggregate, WeightsMax, WeightsMean)
+	}
+	mip.mutex.Lock()
+	locations := make([]string, 0, len(mip.keywordLocations))
+	for _, location := range mip.keywordLocations {
+		if location != "" {
+			locations = append(locations, location+":")
+		}
+	}
+	method := false
+	for kw, location := range mip.keywordLocations {
+		method = method || (location == "method" && kw != "FUZZ")
+	}
+	mip.mutex.Unlock()
+	// The longest locations first, "body:/a" being stripped before "body"
+	sort.Slice(locations, func(i, j int) bool {
+		if len(locations[i]) != len(locations[j]) {
+			return len(locations[i]) > len(locations[j])
+		}
+		return locations[i] < locations[j]
+	})
+
+	values := make(map[string]float64)
+	counts := make(map[string]int)
+	mc := mip.MarkovChain
+	mc.mutex.RLock()
+	for _, row := range mc.QTable {
+		for key, q := range row {
+			token := actionToken(key, locations, method)
+			value, seen := values[token]
+			switch {
+			case !seen:
+				values[token] = q
+			case aggregate == WeightsMean:
+				values[token] += q
+			case q > value:
+				values[token] = q
+			}
+			counts[token]++
+		}
+	}
+	mc.mutex.RUnlock()
+
+	weights := make([]TokenWeight, 0, len(values))
+	for token, value := range values {
+		if aggregate == WeightsMean {
+			value /= float64(counts[token])
+		}
+		weights = append(weights, TokenWeight{Token: token, Weight: value})
+	}
+	if len(weights) == 0 {
+		return weights, nil
+	}
+	low, high := weights[0].Weight, weights[0].Weight
+	for _, w := range weights {
+		if w.Weight < low {
+			low = w.Weight
+		}
+		if w.Weight > high {
+			high = w.Weight
+		}
+	}
+	for i := range weights {
+		if high > low {
+			weights[i].Weight = (weights[i].Weight - low) / (high - low)
+		} else {
+			weights[i].Weight = 1
+		}
+	}
+	sort.Slice(weights, func(i, j int) bool {
+		if weights[i].Weight != weights[j].Weight {
+			return weights[i].Weight > weights[j].Weight
+		}
+		return weights[i].Token < weights[j].Token
+	})
+	return weights, nil
+}
+
+// actionToken returns the token of an action key, without the fuzzed method and the location of the keyword
+func actionToken(key string, locations []string, method bool) string {
+	if method {
+		if i := strings.Index(key, " "); i > 0 {
+			key = key[i+1:]
+		}
+	}
+	for _, location := range locations {
+		if strings.HasPrefix(key, location) {
+			return key[len(location):]
+		}
+	}
+	return key
+}
+
+// ExportWeights writes the weights of ActionWeights to a file as a weighted wordlist for other tools, one
+// token<TAB>weight line per token
+func (mip *MarkovInputProvider) ExportWeights(filename string, aggregate string) error {
+	weights, err := mip.ActionWeights(aggregate)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, tw := range weights {
+		// The tokens are written raw, a tab or a newline in one would break the line
+		if strings.ContainsAny(tw.Token, "\t\r\n") || tw.Token == "" {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%.6f\n", tw.Token, tw.Weight)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
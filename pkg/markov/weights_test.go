@@ -0,0 +1,111 @@
+package markov
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newWeightsProvider returns a provider with the Q-values of the tokens in two states, FUZZ being in the path
+func newWeightsProvider() *MarkovInputProvider {
+	mip := newTestProvider()
+	mip.SetKeywordLocations(map[string]string{"FUZZ": "path"})
+	mip.MarkovChain.QTable["4xx_1-1k_0"] = map[string]float64{"path:admin": 3.0, "path:login": 1.0, "path:a:b": -1.0}
+	mip.MarkovChain.QTable["2xx_1k-10k_1"] = map[string]float64{"path:admin": 1.0, "path:login": 2.0}
+	return mip
+}
+
+// readWeights reads a weighted wordlist written by ExportWeights
+func readWeights(t *testing.T, filename string) []TokenWeight {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	weights := make([]TokenWeight, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), "\t")
+		if len(parts) != 2 {
+			t.Fatalf("Expected token<TAB>weight lines, got %q", scanner.Text())
+		}
+		weight, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		weights = append(weights, TokenWeight{Token: parts[0], Weight: weight})
+	}
+	return weights
+}
+
+func TestActionWeights(t *testing.T) {
+	mip := newWeightsProvider()
+	for _, tc := range []struct {
+		aggregate string
+		want      []TokenWeight
+	}{
+		// max: admin 3, login 2, a:b -1
+		{WeightsMax, []TokenWeight{{"admin", 1}, {"login", 0.75}, {"a:b", 0}}},
+		// mean: admin 2, login 1.5, a:b -1
+		{WeightsMean, []TokenWeight{{"admin", 1}, {"login", 2.5 / 3}, {"a:b", 0}}},
+	} {
+		got, err := mip.ActionWeights(tc.aggregate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.aggregate, tc.want, got)
+		}
+		for i := range got {
+			if got[i].Token != tc.want[i].Token || math.Abs(got[i].Weight-tc.want[i].Weight) > 1e-9 {
+				t.Errorf("%s: expected %v, got %v", tc.aggregate, tc.want, got)
+				break
+			}
+		}
+	}
+	if _, err := mip.ActionWeights("median"); err == nil {
+		t.Errorf("Expected an unknown aggregation to be refused")
+	}
+}
+
+func TestExportWeightsRoundTrip(t *testing.T) {
+	mip := newWeightsProvider()
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.tsv"), filepath.Join(dir, "second.tsv")
+	if err := mip.ExportWeights(first, WeightsMean); err != nil {
+		t.Fatal(err)
+	}
+	if err := mip.ExportWeights(second, WeightsMean); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := os.ReadFile(first)
+	b, _ := os.ReadFile(second)
+	if !bytes.Equal(a, b) {
+		t.Errorf("Expected the same chain to be exported to the same file, got\n%s\nand\n%s", a, b)
+	}
+
+	want, _ := mip.ActionWeights(WeightsMean)
+	got := readWeights(t, first)
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d tokens, got %v", len(want), got)
+	}
+	for i := range got {
+		if got[i].Token != want[i].Token || math.Abs(got[i].Weight-want[i].Weight) > 1e-6 {
+			t.Errorf("Expected the weight %v to survive the export, got %v", want[i], got[i])
+		}
+	}
+}
+
+func TestActionWeightsEqualValues(t *testing.T) {
+	mip := newTestProvider()
+	mip.MarkovChain.QTable["4xx_1-1k_0"] = map[string]float64{"one": 0.5, "two": 0.5}
+	weights, _ := mip.ActionWeights(WeightsMax)
+	if len(weights) != 2 || weights[0] != (TokenWeight{"one", 1}) || weights[1] != (TokenWeight{"two", 1}) {
+		t.Errorf("Expected the tokens of equal value to weigh 1 in token order, got %v", weights)
+	}
+}
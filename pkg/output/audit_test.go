@@ -51,8 +51,8 @@ func TestAuditLogger(t *testing.T) {
 }
 
 func TestAuditLogWrite(t *testing.T) {
-	expected := `{"Type":"ffuf.Config","Data":{"auditlog":"","autocalibration":false,"autocalibration_keyword":"","autocalibration_perhost":false,"autocalibration_strategies":null,"autocalibration_strings":null,"colors":false,"cmdline":"","configfile":"","postdata":"{\"quote\":\"I'll still be here tomorrow to high five you yesterday, my friend. Peace.\"}","debuglog":"","delay":{"Min":0,"Max":0,"IsRange":false,"HasDelay":false},"dirsearch_compatibility":false,"encoders":null,"extensions":null,"fmode":"","follow_redirects":false,"headers":{"Content-Type":"application/json","baz":"wibble","foo":"bar"},"ignorebody":false,"ignore_wordlist_comments":false,"inputmode":"","cmd_inputnum":0,"inputproviders":null,"inputshell":"","json":false,"matchers":null,"mmode":"","maxtime":0,"maxtime_job":0,"method":"POST","noninteractive":false,"outputdirectory":"","outputfile":"","outputformat":"","OutputSkipEmptyFile":false,"proxyurl":"","quiet":false,"rate":0,"raw":false,"recursion":false,"recursion_depth":0,"recursion_strategy":"","replayproxyurl":"","requestfile":"","requestproto":"","scraperfile":"","scrapers":"","sni":"","stop_403":false,"stop_all":false,"stop_errors":false,"threads":0,"timeout":0,"url":"http://example.com/aaaa","verbose":false,"wordlists":null,"http2":false,"client-cert":"","client-key":""}}
-{"Type":"ffuf.Request","Data":{"Method":"POST","Host":"","Url":"http://example.com/aaaa","Headers":{"Content-Type":"application/json","baz":"wibble","foo":"bar"},"Data":"eyJxdW90ZSI6IkknbGwgc3RpbGwgYmUgaGVyZSB0b21vcnJvdyB0byBoaWdoIGZpdmUgeW91IHllc3RlcmRheSwgbXkgZnJpZW5kLiBQZWFjZS4ifQ==","Input":null,"Position":0,"Raw":"","Error":"","Timestamp":"0001-01-01T00:00:00Z"}}
+	expected := `{"Type":"ffuf.Config","Data":{"auditlog":"","autocalibration":false,"autocalibration_keyword":"","autocalibration_perhost":false,"autocalibration_perroot":false,"autocalibration_strategies":null,"autocalibration_strings":null,"colors":false,"cmdline":"","configfile":"","postdata":"{\"quote\":\"I'll still be here tomorrow to high five you yesterday, my friend. Peace.\"}","debuglog":"","delay":{"Min":0,"Max":0,"IsRange":false,"HasDelay":false},"dirsearch_compatibility":false,"encoders":null,"extensions":null,"fmode":"","follow_redirects":false,"headers":{"Content-Type":"application/json","baz":"wibble","foo":"bar"},"header_pool":null,"ignorebody":false,"ignore_wordlist_comments":false,"inputmode":"","cmd_inputnum":0,"inputproviders":null,"inputshell":"","input_shard":0,"input_shards":0,"json":false,"matchers":null,"mmode":"","maxtime":0,"maxtime_job":0,"method":"POST","noninteractive":false,"outputdirectory":"","outputdirectory_maxfiles":0,"outputdirectory_minreward":0,"outputdirectory_status":"","outputfile":"","outputformat":"","OutputSkipEmptyFile":false,"proxyurl":"","proxylist":null,"quiet":false,"rate":0,"raw":false,"recursion":false,"recursion_depth":0,"recursion_strategy":"","replayproxyurl":"","replay_from":"","replay_min_reward":0,"request_budget":0,"request_budget_explore":0,"requestfile":"","requestproto":"","keyword_locations":null,"scraperfile":"","scrapers":"","sni":"","status_addr":"","stop_403":false,"stop_all":false,"stop_errors":false,"threads":0,"timeout":0,"url":"http://example.com/aaaa","verbose":false,"wordlists":null,"http2":false,"client-cert":"","client-key":"","client-ca":"","cookie_jar":"","markov":false,"markov_proto":false,"markov_headers":false,"markov_cookie":false,"markov_cookie_reward":0,"markov_granularity":"","markov_wordlist_out":"","markov_seed_history":"","markov_seed_target":false,"markov_timeout_reward":0,"markov_conn_error_reward":0,"markov_save":"","markov_load":"","markov_cooldown":0,"markov_cooldown_status":"","markov_cooldown_ua":"","markov_pattern_max":0,"markov_neighbors":0,"markov_top":0,"markov_final_pass":0,"markov_sync":"","markov_sync_interval":0,"markov_cold_budget":0,"markov_max_memory":0,"markov_redirect_reward":0,"markov_class_quota":0,"markov_reward_expr":"","markov_size_buckets":"","markov_prefix_threshold":0,"markov_prefix_recursion":false,"markov_threshold":0,"markov_reflect_reward":0,"markov_phases":"","markov_duration_cv":0,"markov_known_good":"","markov_export_weights":"","markov_export_aggregate":"","markov_update_buffer":0,"markov_unique_entropy":0,"markov_normalize":false,"markov_dry_run":0,"markov_dry_run_out":"","markov_stack_filter":false,"sort":"","retries":0,"retry_delay":0,"rate_limit_max_wait":0,"response_size_limit":0,"checkpoint_dir":"","checkpoint_interval":0,"resume_checkpoint":"","structural_hash":false}}
+{"Type":"ffuf.Request","Data":{"Method":"POST","Host":"","Url":"http://example.com/aaaa","Headers":{"Content-Type":"application/json","baz":"wibble","foo":"bar"},"Data":"eyJxdW90ZSI6IkknbGwgc3RpbGwgYmUgaGVyZSB0b21vcnJvdyB0byBoaWdoIGZpdmUgeW91IHllc3RlcmRheSwgbXkgZnJpZW5kLiBQZWFjZS4ifQ==","Input":null,"Position":0,"Raw":"","Error":"","ErrorClass":"","Timestamp":"0001-01-01T00:00:00Z","Preset":"","Origin":"","Decision":""}}
 `
 
 	headers := make(map[string]string)
@@ -0,0 +1,72 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// maxClustersShown is the number of the largest clusters listed in the terminal summary
+const maxClustersShown = 10
+
+// ResultCluster is a group of results with the same status and body hash, usually a single page answered to many
+// inputs. The body hash is structural with -structural-hash, so that the pages of a template differing by a token
+// are grouped too.
+type ResultCluster struct {
+	BodyHash  string  `json:"body_hash"`
+	Status    int64   `json:"status"`
+	Url       string  `json:"url"` // first result of the cluster, in the discovery order
+	Count     int     `json:"count"`
+	AvgReward float64 `json:"avg_reward"`
+}
+
+// resultSummary is the "summary" block of the JSON reports
+type resultSummary struct {
+	Clusters []ResultCluster `json:"clusters"`
+}
+
+// clusterResults groups the results by status and body hash, largest cluster first. Clusters of the same size keep
+// the order their first result was discovered in.
+func clusterResults(res []ffuf.Result) []ResultCluster {
+	clusters := make([]ResultCluster, 0)
+	index := make(map[string]int)
+	for _, r := range res {
+		key := fmt.Sprintf("%d/%s", r.StatusCode, r.BodyHash)
+		i, ok := index[key]
+		if !ok {
+			i = len(clusters)
+			index[key] = i
+			clusters = append(clusters, ResultCluster{BodyHash: r.BodyHash, Status: r.StatusCode, Url: r.Url})
+		}
+		// The sum of the rewards until the average is taken below
+		clusters[i].Count++
+		clusters[i].AvgReward += r.Reward
+	}
+	for i := range clusters {
+		clusters[i].AvgReward /= float64(clusters[i].Count)
+	}
+	sort.SliceStable(clusters, func(i, j int) bool { return clusters[i].Count > clusters[j].Count })
+	return clusters
+}
+
+// clusterSummary returns the lines of the terminal summary of the clusters, the rewards being listed with -markov.
+// Nothing is listed when every result has a body of its own.
+func clusterSummary(clusters []ResultCluster, total int, markov bool) []string {
+	if len(clusters) == total {
+		return nil
+	}
+	lines := []string{fmt.Sprintf("%d results in %d clusters of the same status and body hash:", total, len(clusters))}
+	for i, c := range clusters {
+		if i == maxClustersShown {
+			lines = append(lines, fmt.Sprintf("  ... and %d more clusters", len(clusters)-maxClustersShown))
+			break
+		}
+		line := fmt.Sprintf("  [%d] status %d, body hash %s, e.g. %s", c.Count, c.Status, c.BodyHash, c.Url)
+		if markov {
+			line += fmt.Sprintf(", avg reward %.2f", c.AvgReward)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,110 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// clusterFixture returns the 901 results of a permissive matcher, really 3 pages answered to most of the inputs
+func clusterFixture() []ffuf.Result {
+	res := make([]ffuf.Result, 0, 901)
+	for i := 0; i < 900; i++ {
+		r := ffuf.Result{Url: fmt.Sprintf("http://example.com/%d", i), StatusCode: 403, BodyHash: "aaaa", Reward: 1.8}
+		switch i % 3 {
+		case 1:
+			r.BodyHash = "bbbb"
+		case 2:
+			r.StatusCode, r.BodyHash, r.Reward = 404, "cccc", 0.0
+		}
+		// The same body with another status is another page
+		if i == 450 {
+			r.StatusCode, r.Reward = 401, 1.0
+		}
+		res = append(res, r)
+	}
+	return append(res, ffuf.Result{Url: "http://example.com/admin", StatusCode: 200, BodyHash: "dddd", Reward: 3.0})
+}
+
+func TestClusterResults(t *testing.T) {
+	clusters := clusterResults(clusterFixture())
+	// Largest first, the clusters of the same size in discovery order
+	expected := []ResultCluster{
+		{BodyHash: "bbbb", Status: 403, Url: "http://example.com/1", Count: 300, AvgReward: 1.8},
+		{BodyHash: "cccc", Status: 404, Url: "http://example.com/2", Count: 300, AvgReward: 0},
+		{BodyHash: "aaaa", Status: 403, Url: "http://example.com/0", Count: 299, AvgReward: 1.8},
+		{BodyHash: "aaaa", Status: 401, Url: "http://example.com/450", Count: 1, AvgReward: 1.0},
+		{BodyHash: "dddd", Status: 200, Url: "http://example.com/admin", Count: 1, AvgReward: 3.0},
+	}
+	if len(clusters) != len(expected) {
+		t.Fatalf("Expected %d clusters, got %+v", len(expected), clusters)
+	}
+	for i := range expected {
+		c := clusters[i]
+		c.AvgReward = float64(int(c.AvgReward*100+0.5)) / 100
+		if c != expected[i] {
+			t.Errorf("Cluster %d: expected %+v, got %+v", i, expected[i], clusters[i])
+		}
+	}
+}
+
+func TestClusterSummary(t *testing.T) {
+	res := clusterFixture()
+	lines := clusterSummary(clusterResults(res), len(res), true)
+	if len(lines) != 6 || !strings.HasPrefix(lines[0], "901 results in 5 clusters") {
+		t.Fatalf("Unexpected summary: %v", lines)
+	}
+	if lines[1] != "  [300] status 403, body hash bbbb, e.g. http://example.com/1, avg reward 1.80" {
+		t.Errorf("Unexpected cluster line: %q", lines[1])
+	}
+	if lines := clusterSummary(clusterResults(res), len(res), false); strings.Contains(lines[1], "reward") {
+		t.Errorf("Expected no rewards listed without -markov: %q", lines[1])
+	}
+	// Results all different are not summarized
+	distinct := sortFixture()
+	for i := range distinct {
+		distinct[i].BodyHash = fmt.Sprintf("%x", i)
+	}
+	if lines := clusterSummary(clusterResults(distinct), len(distinct), true); lines != nil {
+		t.Errorf("Expected no summary for distinct results, got %v", lines)
+	}
+	// Only the largest clusters are listed
+	many := make([]ffuf.Result, 0)
+	for i := 0; i < 2*maxClustersShown; i++ {
+		many = append(many, ffuf.Result{BodyHash: fmt.Sprintf("%x", i)}, ffuf.Result{BodyHash: fmt.Sprintf("%x", i)})
+	}
+	lines = clusterSummary(clusterResults(many), len(many), false)
+	if len(lines) != maxClustersShown+2 || lines[len(lines)-1] != "  ... and 10 more clusters" {
+		t.Errorf("Expected the %d largest clusters listed, got %v", maxClustersShown, lines)
+	}
+}
+
+func TestFinalizeWritesClusters(t *testing.T) {
+	for _, format := range []string{"json", "ejson"} {
+		outfile := filepath.Join(t.TempDir(), "results."+format)
+		conf := &ffuf.Config{Quiet: true, OutputFile: outfile, OutputFormat: format}
+		s := NewStdoutput(conf)
+		s.Results = clusterFixture()
+		if err := s.Finalize(); err != nil {
+			t.Fatalf("Finalize returned an error: %s", err)
+		}
+		data, err := os.ReadFile(outfile)
+		if err != nil {
+			t.Fatalf("Could not read the output file: %s", err)
+		}
+		var out struct {
+			Summary resultSummary `json:"summary"`
+		}
+		if err := json.Unmarshal(data, &out); err != nil {
+			t.Fatalf("Could not parse the output file: %s", err)
+		}
+		if len(out.Summary.Clusters) != 5 || out.Summary.Clusters[0].Count != 300 || out.Summary.Clusters[0].Url != "http://example.com/1" {
+			t.Errorf("%s: unexpected clusters in the summary block: %+v", format, out.Summary.Clusters)
+		}
+	}
+}
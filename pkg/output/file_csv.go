@@ -4,12 +4,13 @@ import (
 	"encoding/base64"
 	"encoding/csv"
 	"os"
+	"path"
 	"strconv"
 
 	"github.com/ffuf/ffuf/v2/pkg/ffuf"
 )
 
-var staticheaders = []string{"url", "redirectlocation", "position", "status_code", "content_length", "content_words", "content_lines", "content_type", "duration", "resultfile", "Ffufhash"}
+var staticheaders = []string{"url", "redirectlocation", "position", "status_code", "content_length", "content_words", "content_lines", "content_type", "duration", "resultfile", "Ffufhash", "duration_ms", "redirectlocation_resolved", "resultfile_path", "reward", "origin", "allow"}
 
 func writeCSV(filename string, config *ffuf.Config, res []ffuf.Result, encode bool) error {
 	header := make([]string, 0)
@@ -39,7 +40,7 @@ func writeCSV(filename string, config *ffuf.Config, res []ffuf.Result, encode bo
 			r.Input = inputs
 		}
 
-		err := w.Write(toCSV(r))
+		err := w.Write(toCSV(config, r))
 		if err != nil {
 			return err
 		}
@@ -51,15 +52,11 @@ func base64encode(in []byte) string {
 	return base64.StdEncoding.EncodeToString(in)
 }
 
-func toCSV(r ffuf.Result) []string {
+// toCSV returns the columns of a result, the inputs in the order of the keywords of the header
+func toCSV(config *ffuf.Config, r ffuf.Result) []string {
 	res := make([]string, 0)
-	ffufhash := ""
-	for k, v := range r.Input {
-		if k == "FFUFHASH" {
-			ffufhash = string(v)
-		} else {
-			res = append(res, string(v))
-		}
+	for _, inputprovider := range config.InputProviders {
+		res = append(res, string(r.Input[inputprovider.Keyword]))
 	}
 	res = append(res, r.Url)
 	res = append(res, r.RedirectLocation)
@@ -71,6 +68,29 @@ func toCSV(r ffuf.Result) []string {
 	res = append(res, r.ContentType)
 	res = append(res, r.Duration.String())
 	res = append(res, r.ResultFile)
-	res = append(res, ffufhash)
+	res = append(res, string(r.Input["FFUFHASH"]))
+	res = append(res, strconv.FormatInt(r.Duration.Milliseconds(), 10))
+	res = append(res, resolveRedirectLocation(r))
+	resultFilePath := ""
+	if r.ResultFile != "" {
+		resultFilePath = path.Join(config.OutputDirectory, r.ResultFile)
+	}
+	res = append(res, resultFilePath)
+	res = append(res, strconv.FormatFloat(r.Reward, 'f', -1, 64))
+	res = append(res, r.Origin)
+	res = append(res, r.Allow)
 	return res
 }
+
+// resolveRedirectLocation returns the redirect location of the result resolved against its URL
+func resolveRedirectLocation(r ffuf.Result) string {
+	if r.RedirectLocation == "" {
+		return ""
+	}
+	resp := ffuf.Response{
+		StatusCode: r.StatusCode,
+		Headers:    map[string][]string{"Location": {r.RedirectLocation}},
+		Request:    &ffuf.Request{Url: r.Url},
+	}
+	return resp.GetRedirectLocation(true)
+}
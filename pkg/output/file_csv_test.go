@@ -1,6 +1,9 @@
 package output
 
 import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -9,36 +12,117 @@ import (
 )
 
 func TestToCSV(t *testing.T) {
+	config := &ffuf.Config{
+		InputProviders:  []ffuf.InputProviderConfig{{Keyword: "x"}, {Keyword: "y"}},
+		OutputDirectory: "/tmp/results",
+	}
 	result := ffuf.Result{
-		Input:            map[string][]byte{"x": {66}, "FFUFHASH": {65}},
+		Input:            map[string][]byte{"x": {66}, "y": {67}, "FFUFHASH": {65}},
 		Position:         1,
-		StatusCode:       200,
+		StatusCode:       301,
 		ContentLength:    3,
 		ContentWords:     4,
 		ContentLines:     5,
 		ContentType:      "application/json",
-		RedirectLocation: "http://no.pe",
-		Url:              "http://as.df",
-		Duration:         time.Duration(123),
+		RedirectLocation: "/no.pe/",
+		Url:              "http://as.df/no.pe",
+		Duration:         1500 * time.Millisecond,
 		ResultFile:       "resultfile",
 		Host:             "host",
+		Reward:           1.5,
+		Origin:           "dir-probe",
 	}
 
-	csv := toCSV(result)
+	csv := toCSV(config, result)
 
 	if !reflect.DeepEqual(csv, []string{
 		"B",
-		"http://as.df",
-		"http://no.pe",
+		"C",
+		"http://as.df/no.pe",
+		"/no.pe/",
 		"1",
-		"200",
+		"301",
 		"3",
 		"4",
 		"5",
 		"application/json",
-		"123ns",
+		"1.5s",
 		"resultfile",
-		"A"}) {
-		t.Errorf("CSV was not generated in expected format")
+		"A",
+		"1500",
+		"http://as.df/no.pe/",
+		"/tmp/results/resultfile",
+		"1.5",
+		"dir-probe",
+		""}) {
+		t.Errorf("CSV was not generated in expected format: %v", csv)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	config := &ffuf.Config{InputProviders: []ffuf.InputProviderConfig{{Keyword: "FUZZ"}, {Keyword: "USER"}}}
+	results := []ffuf.Result{
+		{
+			Input:            map[string][]byte{"FUZZ": []byte("a,b"), "USER": []byte("line\nbreak"), "FFUFHASH": []byte("1")},
+			StatusCode:       302,
+			RedirectLocation: "/login?next=a,b&msg=\"quoted\"",
+			Url:              "http://example.com/a,b",
+			Origin:           "neighbor",
+			Allow:            "GET, POST",
+		},
+		{
+			Input:      map[string][]byte{"FUZZ": []byte("plain")},
+			StatusCode: 200,
+			Url:        "http://example.com/plain",
+			Reward:     0.25,
+		},
+	}
+	filename := filepath.Join(t.TempDir(), "results.csv")
+	if err := writeCSV(filename, config, results, false); err != nil {
+		t.Fatalf("Writing the CSV failed: %s", err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Opening the CSV failed: %s", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("Reading the CSV back failed: %s", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %d records", len(records))
+	}
+	header := append([]string{"FUZZ", "USER"}, staticheaders...)
+	if !reflect.DeepEqual(records[0], header) {
+		t.Errorf("Unexpected header %v", records[0])
+	}
+	column := make(map[string]int)
+	for i, name := range records[0] {
+		column[name] = i
+	}
+	for _, record := range records[1:] {
+		if len(record) != len(header) {
+			t.Errorf("Expected %d columns like the header, got %d", len(header), len(record))
+		}
+	}
+	first := records[1]
+	for name, expected := range map[string]string{
+		"FUZZ":                      "a,b",
+		"USER":                      "line\nbreak",
+		"url":                       "http://example.com/a,b",
+		"redirectlocation":          "/login?next=a,b&msg=\"quoted\"",
+		"redirectlocation_resolved": "http://example.com/login?next=a,b&msg=\"quoted\"",
+		"Ffufhash":                  "1",
+		"origin":                    "neighbor",
+		"allow":                     "GET, POST",
+	} {
+		if first[column[name]] != expected {
+			t.Errorf("Expected %q in column %s, got %q", expected, name, first[column[name]])
+		}
+	}
+	second := records[2]
+	if second[column["USER"]] != "" || second[column["redirectlocation_resolved"]] != "" || second[column["reward"]] != "0.25" {
+		t.Errorf("Unexpected row %v", second)
 	}
 }
@@ -0,0 +1,100 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// writeCurl writes a shell script sending the request of each result again with curl, by descending reward
+func writeCurl(filename string, config *ffuf.Config, res []ffuf.Result) error {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n# Requests of the ffuf results, by descending reward\n")
+	for _, r := range replayOrder(res) {
+		b.WriteString("\n" + resultComment(r) + "\n")
+		b.WriteString(curlCommand(config, r) + "\n")
+	}
+	if err := os.WriteFile(filename, []byte(b.String()), 0750); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(filename, 0750)
+}
+
+// replayOrder returns a copy of the results ordered by descending reward, the results sharing a reward keeping the
+// order they were discovered in
+func replayOrder(res []ffuf.Result) []ffuf.Result {
+	ordered := make([]ffuf.Result, len(res))
+	copy(ordered, res)
+	sortResults(ordered, "reward", true)
+	return ordered
+}
+
+// resultComment returns the comment line preceding the request of a result
+func resultComment(r ffuf.Result) string {
+	return fmt.Sprintf("# Status: %d, Size: %d, Words: %d, Lines: %d, Reward: %s", r.StatusCode, r.ContentLength, r.ContentWords, r.ContentLines, strconv.FormatFloat(r.Reward, 'f', -1, 64))
+}
+
+// sortedHeaders returns the names of the request headers of a result in a stable order
+func sortedHeaders(r ffuf.Result) []string {
+	names := make([]string, 0, len(r.RequestHeaders))
+	for name := range r.RequestHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func curlCommand(config *ffuf.Config, r ffuf.Result) string {
+	args := []string{"curl", "-i", "-s", "-k", "--path-as-is"}
+	if config.Http2 {
+		args = append(args, "--http2")
+	}
+	method := r.Method
+	if method == "" {
+		method = "GET"
+	}
+	args = append(args, "-X", shellQuote(method))
+	for _, name := range sortedHeaders(r) {
+		args = append(args, "-H", shellQuote(name+": "+r.RequestHeaders[name]))
+	}
+	prefix := ""
+	if len(r.RequestData) > 0 {
+		if strings.IndexByte(string(r.RequestData), 0) >= 0 {
+			// A NUL byte cannot be passed in an argument, the body is piped to curl instead
+			prefix = "printf " + shellQuote(printfEscape(r.RequestData)) + " | "
+			args = append(args, "--data-binary", "@-")
+		} else {
+			args = append(args, "--data-binary", shellQuote(string(r.RequestData)))
+		}
+	}
+	args = append(args, shellQuote(r.Url))
+	return prefix + strings.Join(args, " ")
+}
+
+// shellQuote quotes a string for the POSIX shell, nothing being expanded in single quotes
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// printfEscape escapes data for the format of printf, the bytes other than the printable ASCII ones as octal escapes
+func printfEscape(data []byte) string {
+	var b strings.Builder
+	for _, c := range data {
+		switch {
+		case c == '%':
+			b.WriteString("%%")
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c >= 0x20 && c < 0x7f:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, `\%03o`, c)
+		}
+	}
+	return b.String()
+}
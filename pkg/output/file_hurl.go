@@ -0,0 +1,74 @@
+package output
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// writeHurl writes the request of each result in the Hurl format, by descending reward, asserting the status code
+// of the result
+func writeHurl(filename string, config *ffuf.Config, res []ffuf.Result) error {
+	var b strings.Builder
+	b.WriteString("# Requests of the ffuf results, by descending reward. Run with: hurl --test --insecure\n")
+	for _, r := range replayOrder(res) {
+		b.WriteString("\n" + resultComment(r) + "\n")
+		b.WriteString(hurlEntry(r))
+	}
+	return os.WriteFile(filename, []byte(b.String()), 0640)
+}
+
+func hurlEntry(r ffuf.Result) string {
+	var b strings.Builder
+	method := r.Method
+	if method == "" {
+		method = "GET"
+	}
+	b.WriteString(method + " " + hurlEscape(r.Url, "") + "\n")
+	for _, name := range sortedHeaders(r) {
+		b.WriteString(hurlEscape(name, ":") + ": " + hurlEscape(r.RequestHeaders[name], "") + "\n")
+	}
+	if len(r.RequestData) > 0 {
+		b.WriteString(hurlBody(r.RequestData) + "\n")
+	}
+	b.WriteString(fmt.Sprintf("HTTP %d\n", r.StatusCode))
+	return b.String()
+}
+
+// hurlEscape escapes a string for a line of a Hurl file: the comments, templates and control characters, along with
+// the extra characters given
+func hurlEscape(s string, extra string) string {
+	var b strings.Builder
+	for i, c := range s {
+		switch {
+		case c == '\\' || c == '#' || strings.ContainsRune(extra, c):
+			b.WriteString(`\` + string(c))
+		case c == '{' && strings.HasPrefix(s[i+1:], "{"):
+			// Would start a template
+			b.WriteString(`\u{7b}`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20 || c == 0x7f || c == utf8.RuneError:
+			fmt.Fprintf(&b, `\u{%x}`, c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// hurlBody returns the body of a request as a oneline string, or base64 encoded when it is not valid UTF-8
+func hurlBody(data []byte) string {
+	if utf8.Valid(data) {
+		return "`" + hurlEscape(string(data), "`") + "`"
+	}
+	return "base64," + base64.StdEncoding.EncodeToString(data) + ";"
+}
@@ -8,11 +8,22 @@ import (
 	"github.com/ffuf/ffuf/v2/pkg/ffuf"
 )
 
+// reportNotes are the findings of the run written to the JSON reports along with the results
+type reportNotes struct {
+	Technology   string
+	ParamChanges []ffuf.ParamChange
+	Errors       map[string]int64 // failed requests by error class
+}
+
 type ejsonFileOutput struct {
-	CommandLine string        `json:"commandline"`
-	Time        string        `json:"time"`
-	Results     []ffuf.Result `json:"results"`
-	Config      *ffuf.Config  `json:"config"`
+	CommandLine  string             `json:"commandline"`
+	Time         string             `json:"time"`
+	Technology   string             `json:"technology,omitempty"`
+	ParamChanges []ffuf.ParamChange `json:"markov_param_changes,omitempty"`
+	Errors       map[string]int64   `json:"errors,omitempty"`
+	Summary      resultSummary      `json:"summary"`
+	Results      []ffuf.Result      `json:"results"`
+	Config       *ffuf.Config       `json:"config"`
 }
 
 type JsonResult struct {
@@ -23,27 +34,45 @@ type JsonResult struct {
 	ContentWords     int64               `json:"words"`
 	ContentLines     int64               `json:"lines"`
 	ContentType      string              `json:"content-type"`
+	ContentEncoding  string              `json:"content-encoding"`
+	WireLength       int64               `json:"wire_length"`
 	RedirectLocation string              `json:"redirectlocation"`
 	ScraperData      map[string][]string `json:"scraper"`
 	Duration         time.Duration       `json:"duration"`
+	DNSMs            int64               `json:"dns_ms"`
+	ConnectMs        int64               `json:"connect_ms"`
+	TLSMs            int64               `json:"tls_ms"`
+	TTFBMs           int64               `json:"ttfb_ms"`
+	BodyHash         string              `json:"body_hash"`
+	Redirects        []ffuf.RedirectHop  `json:"redirects,omitempty"`
 	ResultFile       string              `json:"resultfile"`
 	Url              string              `json:"url"`
 	Host             string              `json:"host"`
+	Reward           float64             `json:"reward"`
+	Allow            string              `json:"allow,omitempty"`
 }
 
 type jsonFileOutput struct {
-	CommandLine string       `json:"commandline"`
-	Time        string       `json:"time"`
-	Results     []JsonResult `json:"results"`
-	Config      *ffuf.Config `json:"config"`
+	CommandLine  string             `json:"commandline"`
+	Time         string             `json:"time"`
+	Technology   string             `json:"technology,omitempty"`
+	ParamChanges []ffuf.ParamChange `json:"markov_param_changes,omitempty"`
+	Errors       map[string]int64   `json:"errors,omitempty"`
+	Summary      resultSummary      `json:"summary"`
+	Results      []JsonResult       `json:"results"`
+	Config       *ffuf.Config       `json:"config"`
 }
 
-func writeEJSON(filename string, config *ffuf.Config, res []ffuf.Result) error {
+func writeEJSON(filename string, config *ffuf.Config, res []ffuf.Result, notes reportNotes) error {
 	t := time.Now()
 	outJSON := ejsonFileOutput{
-		CommandLine: config.CommandLine,
-		Time:        t.Format(time.RFC3339),
-		Results:     res,
+		CommandLine:  config.CommandLine,
+		Time:         t.Format(time.RFC3339),
+		Technology:   notes.Technology,
+		ParamChanges: notes.ParamChanges,
+		Errors:       notes.Errors,
+		Summary:      resultSummary{Clusters: clusterResults(res)},
+		Results:      res,
 	}
 
 	outBytes, err := json.Marshal(outJSON)
@@ -57,7 +86,7 @@ func writeEJSON(filename string, config *ffuf.Config, res []ffuf.Result) error {
 	return nil
 }
 
-func writeJSON(filename string, config *ffuf.Config, res []ffuf.Result) error {
+func writeJSON(filename string, config *ffuf.Config, res []ffuf.Result, notes reportNotes) error {
 	t := time.Now()
 	jsonRes := make([]JsonResult, 0)
 	for _, r := range res {
@@ -73,19 +102,33 @@ func writeJSON(filename string, config *ffuf.Config, res []ffuf.Result) error {
 			ContentWords:     r.ContentWords,
 			ContentLines:     r.ContentLines,
 			ContentType:      r.ContentType,
+			ContentEncoding:  r.ContentEncoding,
+			WireLength:       r.WireLength,
 			RedirectLocation: r.RedirectLocation,
 			ScraperData:      r.ScraperData,
 			Duration:         r.Duration,
+			DNSMs:            r.DNSMs,
+			ConnectMs:        r.ConnectMs,
+			TLSMs:            r.TLSMs,
+			TTFBMs:           r.TTFBMs,
+			BodyHash:         r.BodyHash,
+			Redirects:        r.Redirects,
 			ResultFile:       r.ResultFile,
 			Url:              r.Url,
 			Host:             r.Host,
+			Reward:           r.Reward,
+			Allow:            r.Allow,
 		})
 	}
 	outJSON := jsonFileOutput{
-		CommandLine: config.CommandLine,
-		Time:        t.Format(time.RFC3339),
-		Results:     jsonRes,
-		Config:      config,
+		CommandLine:  config.CommandLine,
+		Time:         t.Format(time.RFC3339),
+		Technology:   notes.Technology,
+		ParamChanges: notes.ParamChanges,
+		Errors:       notes.Errors,
+		Summary:      resultSummary{Clusters: clusterResults(res)},
+		Results:      jsonRes,
+		Config:       config,
 	}
 	outBytes, err := json.Marshal(outJSON)
 	if err != nil {
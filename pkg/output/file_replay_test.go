@@ -0,0 +1,84 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// replayResults have headers and bodies with quotes, unicode and control characters
+func replayResults() []ffuf.Result {
+	return []ffuf.Result{
+		{
+			Method:         "GET",
+			Url:            "http://example.com/it's",
+			StatusCode:     200,
+			ContentLength:  10,
+			ContentWords:   2,
+			ContentLines:   1,
+			Reward:         0.5,
+			RequestHeaders: map[string]string{"User-Agent": `"quoted" 'single' $HOME`, "X-Name": "Grüße #1 {{var}}"},
+		},
+		{
+			Method:         "POST",
+			Url:            "http://example.com/login?next=a,b",
+			StatusCode:     302,
+			ContentLength:  0,
+			Reward:         1.5,
+			RequestHeaders: map[string]string{"Content-Type": "application/json"},
+			RequestData:    []byte("{\"user\":\"adm'in\",\"note\":\"back\\\\slash `tick` ☃\"}\n"),
+		},
+		{
+			Method:         "PUT",
+			Url:            "http://example.com/upload",
+			StatusCode:     201,
+			ContentLength:  3,
+			Reward:         0.5,
+			RequestHeaders: map[string]string{},
+			RequestData:    []byte{'a', 0, '%', 0xff},
+		},
+	}
+}
+
+// checkGolden compares the file written to the golden file of testdata, updated instead when FFUF_WRITE_FIXTURES is set
+func checkGolden(t *testing.T, written string, golden string) {
+	t.Helper()
+	got, err := os.ReadFile(written)
+	if err != nil {
+		t.Fatalf("Could not read the written file: %s", err)
+	}
+	golden = filepath.Join("testdata", golden)
+	if os.Getenv("FFUF_WRITE_FIXTURES") != "" {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatalf("Could not update the golden file: %s", err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Could not read the golden file: %s", err)
+	}
+	if string(got) != string(expected) {
+		t.Errorf("%s does not match, got:\n%s", golden, got)
+	}
+}
+
+func TestWriteCurl(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "replay.sh")
+	if err := writeCurl(filename, &ffuf.Config{}, replayResults()); err != nil {
+		t.Fatalf("Writing the curl script failed: %s", err)
+	}
+	checkGolden(t, filename, "replay.sh")
+	if info, err := os.Stat(filename); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("Expected the curl script to be executable")
+	}
+}
+
+func TestWriteHurl(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "replay.hurl")
+	if err := writeHurl(filename, &ffuf.Config{}, replayResults()); err != nil {
+		t.Fatalf("Writing the Hurl file failed: %s", err)
+	}
+	checkGolden(t, filename, "replay.hurl")
+}
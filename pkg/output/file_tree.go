@@ -0,0 +1,205 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+const (
+	// treeMaxDepth is the depth below the hosts the nodes of the text tree are shown to
+	treeMaxDepth = 8
+	// treeMaxNodes is the number of nodes shown in the text tree
+	treeMaxNodes = 500
+)
+
+// treeNode is a path segment of the discovered URLs, with the result found at it if any
+type treeNode struct {
+	Name      string      `json:"name"`
+	Result    *treeResult `json:"result,omitempty"`
+	MaxReward float64     `json:"max_reward"` // highest reward of the results of the node and the nodes beneath it
+	Children  []*treeNode `json:"children,omitempty"`
+	children  map[string]*treeNode
+}
+
+type treeResult struct {
+	Url        string  `json:"url"`
+	StatusCode int64   `json:"status"`
+	Size       int64   `json:"length"`
+	Reward     float64 `json:"reward"`
+}
+
+type treeFileOutput struct {
+	CommandLine string      `json:"commandline"`
+	Hosts       []*treeNode `json:"hosts"`
+}
+
+func writeTree(filename string, config *ffuf.Config, res []ffuf.Result) error {
+	var b strings.Builder
+	r := treeRenderer{maxDepth: treeMaxDepth, maxNodes: treeMaxNodes}
+	for _, host := range buildTree(res) {
+		r.render(&b, host)
+	}
+	return os.WriteFile(filename, []byte(b.String()), 0644)
+}
+
+func writeTreeJSON(filename string, config *ffuf.Config, res []ffuf.Result) error {
+	outJSON := treeFileOutput{
+		CommandLine: config.CommandLine,
+		Hosts:       buildTree(res),
+	}
+	outBytes, err := json.MarshalIndent(outJSON, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, outBytes, 0644)
+}
+
+// buildTree merges the URLs of the results, of all the recursion jobs, into a tree per host. The children are
+// ordered by descending maximum reward, the hot branches first.
+func buildTree(res []ffuf.Result) []*treeNode {
+	hosts := &treeNode{children: make(map[string]*treeNode)}
+	for _, r := range res {
+		host, segments := treePath(r.Url)
+		node := hosts.child(host)
+		for _, segment := range segments {
+			node = node.child(segment)
+		}
+		// The results normalized to the same URL keep the one with the highest reward
+		if node.Result == nil || r.Reward > node.Result.Reward {
+			node.Result = &treeResult{Url: r.Url, StatusCode: r.StatusCode, Size: r.ContentLength, Reward: r.Reward}
+		}
+	}
+	hosts.finish()
+	return hosts.Children
+}
+
+// treePath normalizes a URL to its host and path segments: the scheme and host are lowercased, the default ports and
+// the empty and dot segments are removed. The query string is kept in the last segment.
+func treePath(rawurl string) (string, []string) {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return rawurl, nil
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Host)
+	if (scheme == "http" && strings.HasSuffix(host, ":80")) || (scheme == "https" && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	segments := make([]string, 0)
+	for _, segment := range strings.Split(u.EscapedPath(), "/") {
+		switch segment {
+		case "", ".":
+		case "..":
+			if len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+		default:
+			segments = append(segments, segment)
+		}
+	}
+	if u.RawQuery != "" {
+		if len(segments) == 0 {
+			segments = append(segments, "")
+		}
+		segments[len(segments)-1] += "?" + u.RawQuery
+	}
+	return scheme + "://" + host, segments
+}
+
+func (n *treeNode) child(name string) *treeNode {
+	c, ok := n.children[name]
+	if !ok {
+		c = &treeNode{Name: name, children: make(map[string]*treeNode)}
+		n.children[name] = c
+	}
+	return c
+}
+
+// finish computes the maximum rewards and orders the children
+func (n *treeNode) finish() {
+	n.MaxReward = 0
+	if n.Result != nil {
+		n.MaxReward = n.Result.Reward
+	}
+	n.Children = make([]*treeNode, 0, len(n.children))
+	for _, c := range n.children {
+		c.finish()
+		if c.MaxReward > n.MaxReward {
+			n.MaxReward = c.MaxReward
+		}
+		n.Children = append(n.Children, c)
+	}
+	sort.Slice(n.Children, func(i, j int) bool {
+		if n.Children[i].MaxReward != n.Children[j].MaxReward {
+			return n.Children[i].MaxReward > n.Children[j].MaxReward
+		}
+		return n.Children[i].Name < n.Children[j].Name
+	})
+}
+
+// size returns the number of nodes beneath the node
+func (n *treeNode) size() int {
+	size := len(n.Children)
+	for _, c := range n.Children {
+		size += c.size()
+	}
+	return size
+}
+
+func (n *treeNode) label() string {
+	name := n.Name
+	if len(n.Children) > 0 {
+		name += "/"
+	}
+	reward := strconv.FormatFloat(n.MaxReward, 'f', -1, 64)
+	if n.Result == nil {
+		return fmt.Sprintf("%s [Max reward: %s]", name, reward)
+	}
+	return fmt.Sprintf("%s [Status: %d, Size: %d, Reward: %s, Max reward: %s]", name, n.Result.StatusCode, n.Result.Size, strconv.FormatFloat(n.Result.Reward, 'f', -1, 64), reward)
+}
+
+// treeRenderer renders the tree of a host as text, down to maxDepth and up to maxNodes nodes
+type treeRenderer struct {
+	maxDepth int
+	maxNodes int
+	shown    int
+}
+
+func (r *treeRenderer) render(b *strings.Builder, host *treeNode) {
+	b.WriteString(host.label() + "\n")
+	r.renderChildren(b, host, "", 1)
+}
+
+func (r *treeRenderer) renderChildren(b *strings.Builder, n *treeNode, prefix string, depth int) {
+	for i, c := range n.Children {
+		branch, indent := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		if r.shown >= r.maxNodes {
+			hidden := len(n.Children) - i
+			for _, rest := range n.Children[i:] {
+				hidden += rest.size()
+			}
+			fmt.Fprintf(b, "%s└── ... %d more nodes, the limit of %d nodes is reached\n", prefix, hidden, r.maxNodes)
+			return
+		}
+		r.shown++
+		b.WriteString(prefix + branch + c.label() + "\n")
+		if len(c.Children) == 0 {
+			continue
+		}
+		if depth >= r.maxDepth {
+			fmt.Fprintf(b, "%s%s└── ... %d more nodes beneath\n", prefix, indent, c.size())
+			continue
+		}
+		r.renderChildren(b, c, prefix+indent, depth+1)
+	}
+}
@@ -0,0 +1,56 @@
+package output
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// treeResults are the results of a recursive run on two hosts, some URLs normalizing to the same node
+func treeResults() []ffuf.Result {
+	return []ffuf.Result{
+		{Url: "http://example.com/admin", StatusCode: 301, Reward: 1.0},
+		{Url: "http://example.com/admin/", StatusCode: 403, ContentLength: 199, Reward: 0.5},
+		{Url: "http://EXAMPLE.com:80/admin/config.php", StatusCode: 200, ContentLength: 1234, Reward: 2.5},
+		{Url: "http://example.com/admin//backup/db.sql", StatusCode: 200, ContentLength: 99999, Reward: 1.5},
+		{Url: "http://example.com/login", StatusCode: 200, ContentLength: 512, Reward: 0.25},
+		{Url: "http://example.com/static/./css/../js/app.js", StatusCode: 200, ContentLength: 80, Reward: 0},
+		{Url: "http://example.com/search?q=test", StatusCode: 200, ContentLength: 40, Reward: 0.75},
+		{Url: "https://api.example.com:443/v1/users", StatusCode: 401, ContentLength: 20, Reward: 1.25},
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "results.tree")
+	if err := writeTree(filename, &ffuf.Config{}, treeResults()); err != nil {
+		t.Fatalf("Writing the tree failed: %s", err)
+	}
+	checkGolden(t, filename, "results.tree")
+}
+
+func TestWriteTreeJSON(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "results.tree.json")
+	if err := writeTreeJSON(filename, &ffuf.Config{}, treeResults()); err != nil {
+		t.Fatalf("Writing the tree failed: %s", err)
+	}
+	checkGolden(t, filename, "results.tree.json")
+}
+
+func TestTreeLimits(t *testing.T) {
+	hosts := buildTree(treeResults())
+	var b strings.Builder
+	r := treeRenderer{maxDepth: 1, maxNodes: 3}
+	r.render(&b, hosts[0])
+	expected := `http://example.com/ [Max reward: 2.5]
+├── admin/ [Status: 301, Size: 0, Reward: 1, Max reward: 2.5]
+│   └── ... 3 more nodes beneath
+├── search?q=test [Status: 200, Size: 40, Reward: 0.75, Max reward: 0.75]
+├── login [Status: 200, Size: 512, Reward: 0.25, Max reward: 0.25]
+└── ... 3 more nodes, the limit of 3 nodes is reached
+`
+	if b.String() != expected {
+		t.Errorf("Unexpected limited tree:\n%s", b.String())
+	}
+}
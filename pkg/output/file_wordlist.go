@@ -0,0 +1,82 @@
+package output
+
+import (
+	"bufio"
+	"os"
+	"sort"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// wordlistToken is a unique matched token of a keyword, with the highest reward it was given
+type wordlistToken struct {
+	keyword string
+	token   string
+	reward  float64
+}
+
+// writeWordlist writes the unique tokens of the matched results, one per line and sorted by reward, to be used
+// as a wordlist for the next run. When more than one keyword is in use the tokens are grouped by keyword under
+// "# KEYWORD" comment lines, which ffuf skips with -ic.
+func writeWordlist(filename string, keywords []string, res []ffuf.Result) error {
+	seen := make(map[string]*wordlistToken)
+	tokens := make([]*wordlistToken, 0)
+	for _, r := range res {
+		for kw, v := range r.Input {
+			if kw == "FFUFHASH" || len(v) == 0 {
+				continue
+			}
+			key := kw + "\x00" + string(v)
+			if t, ok := seen[key]; ok {
+				if r.Reward > t.reward {
+					t.reward = r.Reward
+				}
+				continue
+			}
+			seen[key] = &wordlistToken{keyword: kw, token: string(v), reward: r.Reward}
+			tokens = append(tokens, seen[key])
+		}
+	}
+	sort.SliceStable(tokens, func(i, j int) bool {
+		if tokens[i].reward != tokens[j].reward {
+			return tokens[i].reward > tokens[j].reward
+		}
+		return tokens[i].token < tokens[j].token
+	})
+
+	if len(keywords) == 0 {
+		// Keywords are not known when not configured through the input providers, take them from the results
+		for _, t := range tokens {
+			if !ffuf.StrInSlice(t.keyword, keywords) {
+				keywords = append(keywords, t.keyword)
+			}
+		}
+		sort.Strings(keywords)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+
+	grouped := len(keywords) > 1
+	for _, kw := range keywords {
+		if grouped {
+			if _, err := w.WriteString("# " + kw + "\n"); err != nil {
+				return err
+			}
+		}
+		for _, t := range tokens {
+			if t.keyword != kw {
+				continue
+			}
+			if _, err := w.WriteString(t.token + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+func TestWriteWordlist(t *testing.T) {
+	res := []ffuf.Result{
+		{Input: map[string][]byte{"FUZZ": []byte("login"), "FFUFHASH": []byte("abc")}, Reward: 1.0},
+		{Input: map[string][]byte{"FUZZ": []byte("admin"), "FFUFHASH": []byte("abd")}, Reward: 2.0},
+		{Input: map[string][]byte{"FUZZ": []byte("backup"), "FFUFHASH": []byte("abe")}, Reward: 1.0},
+		// duplicate token keeps its highest reward
+		{Input: map[string][]byte{"FUZZ": []byte("login"), "FFUFHASH": []byte("abf")}, Reward: 3.0},
+	}
+	outfile := filepath.Join(t.TempDir(), "found.txt")
+	if err := writeWordlist(outfile, []string{"FUZZ"}, res); err != nil {
+		t.Fatalf("Could not write the wordlist: %s", err)
+	}
+	data, _ := os.ReadFile(outfile)
+	if expected := "login\nadmin\nbackup\n"; string(data) != expected {
+		t.Errorf("Unexpected wordlist: %q, want %q", data, expected)
+	}
+}
+
+func TestWriteWordlistKeywords(t *testing.T) {
+	res := []ffuf.Result{
+		{Input: map[string][]byte{"USER": []byte("admin"), "PASS": []byte("admin")}, Reward: 1.0},
+		{Input: map[string][]byte{"USER": []byte("root"), "PASS": []byte("toor")}, Reward: 2.0},
+	}
+	outfile := filepath.Join(t.TempDir(), "found.txt")
+	if err := writeWordlist(outfile, []string{}, res); err != nil {
+		t.Fatalf("Could not write the wordlist: %s", err)
+	}
+	data, _ := os.ReadFile(outfile)
+	if expected := "# PASS\ntoor\nadmin\n# USER\nroot\nadmin\n"; string(data) != expected {
+		t.Errorf("Unexpected wordlist: %q, want %q", data, expected)
+	}
+}
+
+func TestFinalizeWritesWordlist(t *testing.T) {
+	outfile := filepath.Join(t.TempDir(), "found.txt")
+	conf := &ffuf.Config{Quiet: true, MarkovWordlistOut: outfile}
+	conf.InputProviders = []ffuf.InputProviderConfig{{Name: "wordlist", Keyword: "FUZZ"}}
+	s := NewStdoutput(conf)
+	s.Results = []ffuf.Result{{Input: map[string][]byte{"FUZZ": []byte("admin")}, Reward: 1.0}}
+	s.CurrentResults = []ffuf.Result{{Input: map[string][]byte{"FUZZ": []byte("config")}, Reward: 2.0}}
+	if err := s.Finalize(); err != nil {
+		t.Fatalf("Finalize returned an error: %s", err)
+	}
+	data, err := os.ReadFile(outfile)
+	if err != nil {
+		t.Fatalf("Could not read the wordlist: %s", err)
+	}
+	if expected := "config\nadmin\n"; string(data) != expected {
+		t.Errorf("Unexpected wordlist: %q, want %q", data, expected)
+	}
+}
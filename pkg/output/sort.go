@@ -0,0 +1,33 @@
+package output
+
+import (
+	"sort"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// sortResults orders the results in place by the given key. The sort is stable, so results
+// sharing the same value keep the order they were discovered in. Reward sorting is only
+// meaningful when the Markov chain is enabled, so it falls back to status code otherwise.
+// The key that was actually used is returned.
+func sortResults(res []ffuf.Result, key string, markov bool) string {
+	if key == "reward" && !markov {
+		key = "status"
+	}
+	var less func(i, j int) bool
+	switch key {
+	case "reward":
+		// Highest reward first
+		less = func(i, j int) bool { return res[i].Reward > res[j].Reward }
+	case "status":
+		less = func(i, j int) bool { return res[i].StatusCode < res[j].StatusCode }
+	case "size":
+		less = func(i, j int) bool { return res[i].ContentLength < res[j].ContentLength }
+	case "url":
+		less = func(i, j int) bool { return res[i].Url < res[j].Url }
+	default:
+		return ""
+	}
+	sort.SliceStable(res, less)
+	return key
+}
@@ -0,0 +1,124 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+func sortFixture() []ffuf.Result {
+	return []ffuf.Result{
+		{Url: "http://example.com/c", StatusCode: 403, ContentLength: 30, Reward: 1.8},
+		{Url: "http://example.com/a", StatusCode: 200, ContentLength: 10, Reward: 2.0},
+		{Url: "http://example.com/d", StatusCode: 200, ContentLength: 30, Reward: 0.5},
+		{Url: "http://example.com/b", StatusCode: 301, ContentLength: 20, Reward: 2.0},
+	}
+}
+
+func resultUrls(res []ffuf.Result) []string {
+	urls := make([]string, 0, len(res))
+	for _, r := range res {
+		urls = append(urls, r.Url)
+	}
+	return urls
+}
+
+func TestSortResults(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected []string
+	}{
+		// ties keep the discovery order
+		{"reward", []string{"http://example.com/a", "http://example.com/b", "http://example.com/c", "http://example.com/d"}},
+		{"status", []string{"http://example.com/a", "http://example.com/d", "http://example.com/b", "http://example.com/c"}},
+		{"size", []string{"http://example.com/a", "http://example.com/b", "http://example.com/c", "http://example.com/d"}},
+		{"url", []string{"http://example.com/a", "http://example.com/b", "http://example.com/c", "http://example.com/d"}},
+	}
+	for _, test := range tests {
+		res := sortFixture()
+		key := sortResults(res, test.key, true)
+		if key != test.key {
+			t.Errorf("Sorting by %s used key %s", test.key, key)
+		}
+		urls := resultUrls(res)
+		for i := range urls {
+			if urls[i] != test.expected[i] {
+				t.Errorf("Sorting by %s: got %v, want %v", test.key, urls, test.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestSortResultsRewardFallback(t *testing.T) {
+	res := sortFixture()
+	key := sortResults(res, "reward", false)
+	if key != "status" {
+		t.Errorf("Reward sorting without markov should fall back to status, got %s", key)
+	}
+	if res[0].StatusCode != 200 || res[3].StatusCode != 403 {
+		t.Errorf("Results were not sorted by status in the fallback path: %v", resultUrls(res))
+	}
+}
+
+func TestFinalizeWritesSortedJSON(t *testing.T) {
+	outfile := filepath.Join(t.TempDir(), "results.json")
+	conf := &ffuf.Config{Sort: "reward", Markov: true, Quiet: true, OutputFile: outfile, OutputFormat: "json"}
+	s := NewStdoutput(conf)
+	s.Results = sortFixture()[:2]
+	s.CurrentResults = sortFixture()[2:]
+	if err := s.Finalize(); err != nil {
+		t.Fatalf("Finalize returned an error: %s", err)
+	}
+	data, err := os.ReadFile(outfile)
+	if err != nil {
+		t.Fatalf("Could not read the output file: %s", err)
+	}
+	var out jsonFileOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Could not parse the output file: %s", err)
+	}
+	expected := []string{"http://example.com/a", "http://example.com/b", "http://example.com/c", "http://example.com/d"}
+	if len(out.Results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(out.Results))
+	}
+	for i, r := range out.Results {
+		if r.Url != expected[i] {
+			t.Errorf("JSON results not sorted by reward: position %d is %s, want %s", i, r.Url, expected[i])
+		}
+	}
+}
+
+func TestFinalizeWritesTechnology(t *testing.T) {
+	outfile := filepath.Join(t.TempDir(), "results.json")
+	conf := &ffuf.Config{Quiet: true, OutputFile: outfile, OutputFormat: "json"}
+	s := NewStdoutput(conf)
+	s.Results = sortFixture()
+	s.SetTechnology("looks like PHP/Apache (confidence 100%: X-Powered-By: PHP, PHPSESSID cookie, Server: Apache)")
+	s.SetParamChanges([]ffuf.ParamChange{{Time: "2024-01-01T10:00:00Z", Name: "auth", Old: 1.8, New: 0.5}})
+	s.SetErrorClasses(map[string]int64{"dns": 2, "timeout": 5})
+	if err := s.Finalize(); err != nil {
+		t.Fatalf("Finalize returned an error: %s", err)
+	}
+	data, err := os.ReadFile(outfile)
+	if err != nil {
+		t.Fatalf("Could not read the output file: %s", err)
+	}
+	var out jsonFileOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Could not parse the output file: %s", err)
+	}
+	if !strings.HasPrefix(out.Technology, "looks like PHP/Apache") {
+		t.Errorf("Expected the technology guess in the report, got %q", out.Technology)
+	}
+	if len(out.ParamChanges) != 1 || out.ParamChanges[0].Name != "auth" || out.ParamChanges[0].New != 0.5 {
+		t.Errorf("Expected the Markov parameter changes in the report, got %+v", out.ParamChanges)
+	}
+	if len(out.Errors) != 2 || out.Errors["dns"] != 2 || out.Errors["timeout"] != 5 {
+		t.Errorf("Expected the failed requests by error class in the report, got %v", out.Errors)
+	}
+}
@@ -9,9 +9,11 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+	"github.com/ffuf/ffuf/v2/pkg/filter"
 )
 
 const (
@@ -31,6 +33,10 @@ type Stdoutput struct {
 	fuzzkeywords   []string
 	Results        []ffuf.Result
 	CurrentResults []ffuf.Result
+	storeStatus    ffuf.FilterProvider // status codes of the results stored with -od when Markov is not enabled
+	storedFiles    int                 // number of results stored with -od
+	storeMutex     sync.Mutex
+	notes          reportNotes // findings of the run written to the JSON reports
 }
 
 func NewStdoutput(conf *ffuf.Config) *Stdoutput {
@@ -43,6 +49,9 @@ func NewStdoutput(conf *ffuf.Config) *Stdoutput {
 		outp.fuzzkeywords = append(outp.fuzzkeywords, ip.Keyword)
 	}
 	sort.Strings(outp.fuzzkeywords)
+	if status, err := filter.NewStatusFilter(conf.OutputDirectoryStatus); err == nil {
+		outp.storeStatus = status
+	}
 	return &outp
 }
 
@@ -58,6 +67,12 @@ func (s *Stdoutput) Banner() {
 			printOption([]byte("Wordlist"), []byte(provider.Keyword+": "+provider.Value))
 		}
 	}
+	if len(s.config.ReplayFrom) > 0 {
+		printOption([]byte("Replay"), []byte(fmt.Sprintf("%s (reward >= %g)", s.config.ReplayFrom, s.config.ReplayMinReward)))
+	}
+	if s.config.InputShards > 0 {
+		printOption([]byte("Shard"), []byte(fmt.Sprintf("%d/%d", s.config.InputShard, s.config.InputShards)))
+	}
 
 	// Print headers
 	if len(s.config.Headers) > 0 {
@@ -156,6 +171,13 @@ func (s *Stdoutput) GetCurrentResults() []ffuf.Result {
 	return s.CurrentResults
 }
 
+// GetResults returns the results of the previous queue jobs along with the current ones
+func (s *Stdoutput) GetResults() []ffuf.Result {
+	results := make([]ffuf.Result, 0, len(s.Results)+len(s.CurrentResults))
+	results = append(results, s.Results...)
+	return append(results, s.CurrentResults...)
+}
+
 // SetResults sets the result slice
 func (s *Stdoutput) SetCurrentResults(results []ffuf.Result) {
 	s.CurrentResults = results
@@ -183,6 +205,21 @@ func (s *Stdoutput) Progress(status ffuf.Progress) {
 	secs := dur / time.Second
 
 	fmt.Fprintf(os.Stderr, "%s:: Progress: [%d/%d] :: Job [%d/%d] :: %d req/sec :: Duration: [%d:%02d:%02d] :: Errors: %d ::", TERMINAL_CLEAR_LINE, status.ReqCount, status.ReqTotal, status.QueuePos, status.QueueTotal, reqRate, hours, mins, secs, status.ErrorCount)
+	if len(status.Allocation) > 0 {
+		fmt.Fprintf(os.Stderr, " Wordlists: %s ::", status.Allocation)
+	}
+	if status.Duplicates > 0 {
+		fmt.Fprintf(os.Stderr, " Duplicates: %d ::", status.Duplicates)
+	}
+	if status.FinalPassTotal > 0 {
+		fmt.Fprintf(os.Stderr, " Final pass: [%d/%d] ::", status.FinalPass, status.FinalPassTotal)
+	}
+	if status.Stall >= 0.01 {
+		fmt.Fprintf(os.Stderr, " Stall: %.2f ::", status.Stall)
+	}
+	if status.Phase != "" {
+		fmt.Fprintf(os.Stderr, " Phase: %s ::", status.Phase)
+	}
 }
 
 func (s *Stdoutput) Info(infostring string) {
@@ -233,13 +270,13 @@ func (s *Stdoutput) writeToAll(filename string, config *ffuf.Config, res []ffuf.
 	// the suffix to each output file.
 
 	s.config.OutputFile = BaseFilename + ".json"
-	err = writeJSON(s.config.OutputFile, s.config, res)
+	err = writeJSON(s.config.OutputFile, s.config, res, s.notes)
 	if err != nil {
 		s.Error(err.Error())
 	}
 
 	s.config.OutputFile = BaseFilename + ".ejson"
-	err = writeEJSON(s.config.OutputFile, s.config, res)
+	err = writeEJSON(s.config.OutputFile, s.config, res, s.notes)
 	if err != nil {
 		s.Error(err.Error())
 	}
@@ -268,10 +305,49 @@ func (s *Stdoutput) writeToAll(filename string, config *ffuf.Config, res []ffuf.
 		s.Error(err.Error())
 	}
 
+	s.config.OutputFile = BaseFilename + ".sh"
+	err = writeCurl(s.config.OutputFile, s.config, res)
+	if err != nil {
+		s.Error(err.Error())
+	}
+
+	s.config.OutputFile = BaseFilename + ".hurl"
+	err = writeHurl(s.config.OutputFile, s.config, res)
+	if err != nil {
+		s.Error(err.Error())
+	}
+
+	s.config.OutputFile = BaseFilename + ".tree"
+	err = writeTree(s.config.OutputFile, s.config, res)
+	if err != nil {
+		s.Error(err.Error())
+	}
+
+	s.config.OutputFile = BaseFilename + ".tree.json"
+	err = writeTreeJSON(s.config.OutputFile, s.config, res)
+	if err != nil {
+		s.Error(err.Error())
+	}
+
 	return nil
 
 }
 
+// SetTechnology sets the technology guess of the target written to the JSON reports
+func (s *Stdoutput) SetTechnology(guess string) {
+	s.notes.Technology = guess
+}
+
+// SetErrorClasses sets the number of failed requests by error class, written to the JSON reports
+func (s *Stdoutput) SetErrorClasses(counts map[string]int64) {
+	s.notes.Errors = counts
+}
+
+// SetParamChanges sets the Markov parameters changed during the run, written to the JSON reports
+func (s *Stdoutput) SetParamChanges(changes []ffuf.ParamChange) {
+	s.notes.ParamChanges = changes
+}
+
 // SaveFile saves the current results to a file of a given type
 func (s *Stdoutput) SaveFile(filename, format string) error {
 	var err error
@@ -283,9 +359,9 @@ func (s *Stdoutput) SaveFile(filename, format string) error {
 	case "all":
 		err = s.writeToAll(filename, s.config, append(s.Results, s.CurrentResults...))
 	case "json":
-		err = writeJSON(filename, s.config, append(s.Results, s.CurrentResults...))
+		err = writeJSON(filename, s.config, append(s.Results, s.CurrentResults...), s.notes)
 	case "ejson":
-		err = writeEJSON(filename, s.config, append(s.Results, s.CurrentResults...))
+		err = writeEJSON(filename, s.config, append(s.Results, s.CurrentResults...), s.notes)
 	case "html":
 		err = writeHTML(filename, s.config, append(s.Results, s.CurrentResults...))
 	case "md":
@@ -294,6 +370,14 @@ func (s *Stdoutput) SaveFile(filename, format string) error {
 		err = writeCSV(filename, s.config, append(s.Results, s.CurrentResults...), false)
 	case "ecsv":
 		err = writeCSV(filename, s.config, append(s.Results, s.CurrentResults...), true)
+	case "curl":
+		err = writeCurl(filename, s.config, append(s.Results, s.CurrentResults...))
+	case "hurl":
+		err = writeHurl(filename, s.config, append(s.Results, s.CurrentResults...))
+	case "tree":
+		err = writeTree(filename, s.config, append(s.Results, s.CurrentResults...))
+	case "tree-json":
+		err = writeTreeJSON(filename, s.config, append(s.Results, s.CurrentResults...))
 	}
 	return err
 }
@@ -301,12 +385,38 @@ func (s *Stdoutput) SaveFile(filename, format string) error {
 // Finalize gets run after all the ffuf jobs are completed
 func (s *Stdoutput) Finalize() error {
 	var err error
+	if s.config.Sort != "" {
+		// Gather the results of all the jobs before sorting
+		s.Cycle()
+		key := sortResults(s.Results, s.config.Sort, s.config.Markov)
+		if key != s.config.Sort {
+			s.Warning(fmt.Sprintf("Sorting by %s requires -markov, sorting results by %s instead", s.config.Sort, key))
+		}
+		if !s.config.Quiet && !s.config.Json && len(s.Results) > 0 {
+			s.Info(fmt.Sprintf("All results, sorted by %s:", key))
+			for _, r := range s.Results {
+				s.PrintResult(r)
+			}
+		}
+	}
+	if !s.config.Quiet && !s.config.Json {
+		res := append(s.Results, s.CurrentResults...)
+		for _, line := range clusterSummary(clusterResults(res), len(res), s.config.Markov) {
+			s.Info(line)
+		}
+	}
 	if s.config.OutputFile != "" {
 		err = s.SaveFile(s.config.OutputFile, s.config.OutputFormat)
 		if err != nil {
 			s.Error(err.Error())
 		}
 	}
+	if s.config.MarkovWordlistOut != "" {
+		err = writeWordlist(s.config.MarkovWordlistOut, s.fuzzkeywords, append(s.Results, s.CurrentResults...))
+		if err != nil {
+			s.Error(err.Error())
+		}
+	}
 	if !s.config.Quiet {
 		fmt.Fprintf(os.Stderr, "\n")
 	}
@@ -315,13 +425,20 @@ func (s *Stdoutput) Finalize() error {
 
 func (s *Stdoutput) Result(resp ffuf.Response) {
 	// Do we want to write request and response to a file
-	if len(s.config.OutputDirectory) > 0 {
+	if len(s.config.OutputDirectory) > 0 && s.storeResult(resp) {
 		resp.ResultFile = s.writeResultToFile(resp)
 	}
 
 	inputs := make(map[string][]byte, len(resp.Request.Input))
+	var locations map[string]string
 	for k, v := range resp.Request.Input {
 		inputs[k] = v
+		if loc, ok := s.config.KeywordLocations[k]; ok {
+			if locations == nil {
+				locations = make(map[string]string)
+			}
+			locations[k] = loc
+		}
 	}
 	sResult := ffuf.Result{
 		Input:            inputs,
@@ -331,18 +448,62 @@ func (s *Stdoutput) Result(resp ffuf.Response) {
 		ContentWords:     resp.ContentWords,
 		ContentLines:     resp.ContentLines,
 		ContentType:      resp.ContentType,
+		ContentEncoding:  resp.Encoding,
+		WireLength:       resp.WireLength,
 		RedirectLocation: resp.GetRedirectLocation(false),
 		ScraperData:      resp.ScraperData,
 		Url:              resp.Request.Url,
 		Duration:         resp.Duration,
+		DNSMs:            resp.Timing.DNS.Milliseconds(),
+		ConnectMs:        resp.Timing.Connect.Milliseconds(),
+		TLSMs:            resp.Timing.TLS.Milliseconds(),
+		TTFBMs:           resp.Timing.TTFB.Milliseconds(),
+		BodyHash:         resp.BodyHash,
+		Redirects:        resp.Redirects,
 		ResultFile:       resp.ResultFile,
 		Host:             resp.Request.Host,
+		Retries:          resp.Retries,
+		Reward:           resp.Reward,
+		CertMismatch:     resp.CertMismatch(),
+		Reflected:        resp.Reflected,
+		Locations:        locations,
+		HeaderPreset:     resp.Request.Preset,
+		Origin:           resp.Request.Origin,
+		Allow:            resp.AllowedMethods(),
+		Method:           resp.Request.Method,
+		RequestHeaders:   resp.Request.Headers,
+		RequestData:      resp.Request.Data,
+		Decision:         resp.Request.Decision,
 	}
 	s.CurrentResults = append(s.CurrentResults, sResult)
 	// Output the result
 	s.PrintResult(sResult)
 }
 
+// storeResult decides if the request and response of a result are stored with -od: the results having the Markov
+// reward of -od-min-reward, or the status codes of -od-status without Markov, up to -od-max-files results
+func (s *Stdoutput) storeResult(resp ffuf.Response) bool {
+	if s.config.Markov {
+		if resp.Reward < s.config.OutputDirectoryMinReward {
+			return false
+		}
+	} else if s.storeStatus != nil {
+		if match, _ := s.storeStatus.Filter(&resp); !match {
+			return false
+		}
+	}
+	s.storeMutex.Lock()
+	defer s.storeMutex.Unlock()
+	if s.config.OutputDirectoryMaxFiles > 0 && s.storedFiles >= s.config.OutputDirectoryMaxFiles {
+		return false
+	}
+	s.storedFiles++
+	if s.storedFiles == s.config.OutputDirectoryMaxFiles {
+		s.Warning(fmt.Sprintf("Stored %d results to %s, the further results are not stored (-od-max-files)", s.storedFiles, s.config.OutputDirectory))
+	}
+	return true
+}
+
 func (s *Stdoutput) writeResultToFile(resp ffuf.Response) string {
 	var fileContent, fileName, filePath string
 	// Create directory if needed
@@ -413,7 +574,7 @@ func (s *Stdoutput) resultQuiet(res ffuf.Result) {
 func (s *Stdoutput) resultMultiline(res ffuf.Result) {
 	var res_hdr, res_str string
 	res_str = "%s%s    * %s: %s\n"
-	res_hdr = fmt.Sprintf("%s%s[Status: %d, Size: %d, Words: %d, Lines: %d, Duration: %dms]%s", TERMINAL_CLEAR_LINE, s.colorize(res.StatusCode), res.StatusCode, res.ContentLength, res.ContentWords, res.ContentLines, res.Duration.Milliseconds(), ANSI_CLEAR)
+	res_hdr = fmt.Sprintf("%s%s[Status: %d, Size: %d, Words: %d, Lines: %d, Duration: %dms]%s%s", TERMINAL_CLEAR_LINE, s.colorize(res.StatusCode), res.StatusCode, res.ContentLength, res.ContentWords, res.ContentLines, res.Duration.Milliseconds(), s.markovAnnotation(res), ANSI_CLEAR)
 	reslines := ""
 	if s.config.Verbose {
 		reslines = fmt.Sprintf("%s%s| URL | %s\n", reslines, TERMINAL_CLEAR_LINE, res.Url)
@@ -421,6 +582,22 @@ func (s *Stdoutput) resultMultiline(res ffuf.Result) {
 		if redirectLocation != "" {
 			reslines = fmt.Sprintf("%s%s| --> | %s\n", reslines, TERMINAL_CLEAR_LINE, redirectLocation)
 		}
+		if len(res.Redirects) > 0 {
+			reslines = fmt.Sprintf("%s%s| RDR | %s -> %d\n", reslines, TERMINAL_CLEAR_LINE, ffuf.RedirectChain(res.Redirects), res.StatusCode)
+		}
+		if res.Retries > 0 {
+			reslines = fmt.Sprintf("%s%s| RTY | %d\n", reslines, TERMINAL_CLEAR_LINE, res.Retries)
+		}
+		reslines = fmt.Sprintf("%s%s| TIM | DNS: %dms, Connect: %dms, TLS: %dms, TTFB: %dms\n", reslines, TERMINAL_CLEAR_LINE, res.DNSMs, res.ConnectMs, res.TLSMs, res.TTFBMs)
+		if res.CertMismatch {
+			reslines = fmt.Sprintf("%s%s| SAN | TLS certificate does not cover host %s\n", reslines, TERMINAL_CLEAR_LINE, res.Host)
+		}
+		if res.Reflected {
+			reslines = fmt.Sprintf("%s%s| RFL | The fuzzed input is reflected in the response\n", reslines, TERMINAL_CLEAR_LINE)
+		}
+	}
+	if res.Allow != "" {
+		reslines = fmt.Sprintf("%s%s| ALW | %s\n", reslines, TERMINAL_CLEAR_LINE, res.Allow)
 	}
 	if res.ResultFile != "" {
 		reslines = fmt.Sprintf("%s%s| RES | %s\n", reslines, TERMINAL_CLEAR_LINE, res.ResultFile)
@@ -433,6 +610,9 @@ func (s *Stdoutput) resultMultiline(res ffuf.Result) {
 			// Wordlist input
 			reslines = fmt.Sprintf(res_str, reslines, TERMINAL_CLEAR_LINE, k, res.Input[k])
 		}
+		if loc, ok := res.Locations[k]; ok {
+			reslines = fmt.Sprintf("%s%s      in %s\n", reslines, TERMINAL_CLEAR_LINE, loc)
+		}
 	}
 	if len(res.ScraperData) > 0 {
 		reslines = fmt.Sprintf("%s%s| SCR |\n", reslines, TERMINAL_CLEAR_LINE)
@@ -445,8 +625,27 @@ func (s *Stdoutput) resultMultiline(res ffuf.Result) {
 	fmt.Printf("%s\n%s\n", res_hdr, reslines)
 }
 
+// markovAnnotation returns why the Markov chain sent the input of a result, shown in verbose mode only
+func (s *Stdoutput) markovAnnotation(res ffuf.Result) string {
+	if !s.config.Verbose || !s.config.Markov {
+		return ""
+	}
+	reason := res.Decision
+	if res.Origin != "" {
+		reason = res.Origin
+	}
+	if reason == "" {
+		return ""
+	}
+	return fmt.Sprintf(" [markov: %s]", reason)
+}
+
 func (s *Stdoutput) resultNormal(res ffuf.Result) {
-	resnormal := fmt.Sprintf("%s%s%-23s [Status: %d, Size: %d, Words: %d, Lines: %d, Duration: %dms]%s", TERMINAL_CLEAR_LINE, s.colorize(res.StatusCode), s.prepareInputsOneLine(res), res.StatusCode, res.ContentLength, res.ContentWords, res.ContentLines, res.Duration.Milliseconds(), ANSI_CLEAR)
+	allow := ""
+	if res.Allow != "" {
+		allow = fmt.Sprintf(", Allow: %s", res.Allow)
+	}
+	resnormal := fmt.Sprintf("%s%s%-23s [Status: %d, Size: %d, Words: %d, Lines: %d, Duration: %dms%s]%s", TERMINAL_CLEAR_LINE, s.colorize(res.StatusCode), s.prepareInputsOneLine(res), res.StatusCode, res.ContentLength, res.ContentWords, res.ContentLines, res.Duration.Milliseconds(), allow, ANSI_CLEAR)
 	fmt.Println(resnormal)
 }
 
@@ -0,0 +1,163 @@
+package output
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// captureStdout returns what f prints to the standard output
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Could not create a pipe: %s", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestMarkovAnnotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		verbose  bool
+		markov   bool
+		res      ffuf.Result
+		expected string
+	}{
+		{"ranked", true, true, ffuf.Result{Decision: "replay of admin, q=1.83, state 4xx_100_0"}, "[markov: replay of admin, q=1.83, state 4xx_100_0]"},
+		{"wordlist", true, true, ffuf.Result{Decision: "wordlist order"}, "[markov: wordlist order]"},
+		{"requeued", true, true, ffuf.Result{Origin: "trigram-neighbor"}, "[markov: trigram-neighbor]"},
+		{"not verbose", false, true, ffuf.Result{Decision: "wordlist order"}, ""},
+		{"no markov", true, false, ffuf.Result{Decision: "wordlist order"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := ffuf.NewConfig(nil, nil)
+			conf.Verbose = tt.verbose
+			conf.Markov = tt.markov
+			conf.InputProviders = []ffuf.InputProviderConfig{{Name: "wordlist", Keyword: "FUZZ"}}
+			s := NewStdoutput(&conf)
+
+			tt.res.Input = map[string][]byte{"FUZZ": []byte("admin")}
+			tt.res.StatusCode = 200
+			out := captureStdout(t, func() { s.PrintResult(tt.res) })
+			if tt.expected == "" {
+				if strings.Contains(out, "[markov:") {
+					t.Errorf("Expected no annotation, got %q", out)
+				}
+				return
+			}
+			header := strings.SplitN(out, "\n", 2)[0]
+			if !strings.HasSuffix(header, "Duration: 0ms] "+tt.expected+ANSI_CLEAR) {
+				t.Errorf("Expected the result line to end with %q, got %q", tt.expected, header)
+			}
+		})
+	}
+}
+
+func TestMarkovAnnotationNotInNormalOutput(t *testing.T) {
+	conf := ffuf.NewConfig(nil, nil)
+	conf.Markov = true
+	conf.InputProviders = []ffuf.InputProviderConfig{{Name: "wordlist", Keyword: "FUZZ"}}
+	s := NewStdoutput(&conf)
+
+	res := ffuf.Result{Input: map[string][]byte{"FUZZ": []byte("admin")}, StatusCode: 200, Decision: "wordlist order"}
+	out := captureStdout(t, func() { s.PrintResult(res) })
+	expected := TERMINAL_CLEAR_LINE + "admin                   [Status: 200, Size: 0, Words: 0, Lines: 0, Duration: 0ms]" + ANSI_CLEAR + "\n"
+	if out != expected {
+		t.Errorf("Expected the normal output to be unchanged, got %q", out)
+	}
+}
+
+// storedResults sends the responses to the output as results, returning the result files by input
+func storedResults(t *testing.T, conf *ffuf.Config, responses []ffuf.Response) map[string]string {
+	t.Helper()
+	conf.Quiet = true
+	conf.InputProviders = []ffuf.InputProviderConfig{{Name: "wordlist", Keyword: "FUZZ"}}
+	s := NewStdoutput(conf)
+	captureStdout(t, func() {
+		for _, resp := range responses {
+			s.Result(resp)
+		}
+	})
+	files := make(map[string]string)
+	for _, res := range s.CurrentResults {
+		files[string(res.Input["FUZZ"])] = res.ResultFile
+		if res.ResultFile == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(conf.OutputDirectory, res.ResultFile)); err != nil {
+			t.Errorf("Result file of %s was not written: %s", res.Input["FUZZ"], err)
+		}
+	}
+	return files
+}
+
+func resultResponse(token string, status int64, reward float64) ffuf.Response {
+	req := &ffuf.Request{Url: "http://localhost/" + token, Input: map[string][]byte{"FUZZ": []byte(token)}, Raw: "GET /" + token}
+	return ffuf.Response{StatusCode: status, Reward: reward, Request: req, Raw: token}
+}
+
+func TestResultFileMinReward(t *testing.T) {
+	conf := ffuf.NewConfig(nil, nil)
+	conf.OutputDirectory = t.TempDir()
+	conf.Markov = true
+	conf.OutputDirectoryMinReward = 1.0
+	files := storedResults(t, &conf, []ffuf.Response{
+		resultResponse("low", 200, 0.5),
+		resultResponse("exact", 200, 1.0),
+		resultResponse("high", 404, 2.5),
+	})
+	if files["low"] != "" {
+		t.Errorf("Expected the result below the reward threshold not to be stored")
+	}
+	if files["exact"] == "" || files["high"] == "" {
+		t.Errorf("Expected the results of the reward threshold and above to be stored, got %v", files)
+	}
+	entries, _ := os.ReadDir(conf.OutputDirectory)
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 stored results, got %d", len(entries))
+	}
+}
+
+func TestResultFileMaxFiles(t *testing.T) {
+	conf := ffuf.NewConfig(nil, nil)
+	conf.OutputDirectory = t.TempDir()
+	conf.OutputDirectoryMaxFiles = 2
+	files := storedResults(t, &conf, []ffuf.Response{
+		resultResponse("one", 200, 0),
+		resultResponse("two", 200, 0),
+		resultResponse("three", 200, 0),
+	})
+	if files["one"] == "" || files["two"] == "" || files["three"] != "" {
+		t.Errorf("Expected the first 2 results to be stored, got %v", files)
+	}
+	entries, _ := os.ReadDir(conf.OutputDirectory)
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 stored results, got %d", len(entries))
+	}
+}
+
+func TestResultFileStatus(t *testing.T) {
+	conf := ffuf.NewConfig(nil, nil)
+	conf.OutputDirectory = t.TempDir()
+	conf.OutputDirectoryStatus = "200-299,401"
+	files := storedResults(t, &conf, []ffuf.Response{
+		resultResponse("ok", 204, 0),
+		resultResponse("auth", 401, 0),
+		resultResponse("forbidden", 403, 0),
+	})
+	if files["ok"] == "" || files["auth"] == "" || files["forbidden"] != "" {
+		t.Errorf("Expected the results of the allowed status codes to be stored, got %v", files)
+	}
+}
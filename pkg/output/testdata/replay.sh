@@ -0,0 +1,12 @@
+#!/bin/sh
+# Requests of the ffuf results, by descending reward
+
+# Status: 302, Size: 0, Words: 0, Lines: 0, Reward: 1.5
+curl -i -s -k --path-as-is -X 'POST' -H 'Content-Type: application/json' --data-binary '{"user":"adm'\''in","note":"back\\slash `tick` ☃"}
+' 'http://example.com/login?next=a,b'
+
+# Status: 200, Size: 10, Words: 2, Lines: 1, Reward: 0.5
+curl -i -s -k --path-as-is -X 'GET' -H 'User-Agent: "quoted" '\''single'\'' $HOME' -H 'X-Name: Grüße #1 {{var}}' 'http://example.com/it'\''s'
+
+# Status: 201, Size: 3, Words: 0, Lines: 0, Reward: 0.5
+printf 'a\000%%\377' | curl -i -s -k --path-as-is -X 'PUT' --data-binary @- 'http://example.com/upload'
@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// errBodyFiltered stops reading the body of a response known to be filtered
+var errBodyFiltered = errors.New("response filtered")
+
+// bodyCounter is an io.Writer keeping the response body along with its hash, word and line counts,
+// so that they do not need to be computed from the full body afterwards
+type bodyCounter struct {
+	data   bytes.Buffer
+	hash   hash.Hash64
+	size   int64
+	spaces int64
+	lines  int64
+}
+
+func newBodyCounter() *bodyCounter {
+	return &bodyCounter{hash: fnv.New64a()}
+}
+
+func (b *bodyCounter) Write(p []byte) (int, error) {
+	b.data.Write(p)
+	b.hash.Write(p)
+	b.size += int64(len(p))
+	b.spaces += int64(bytes.Count(p, []byte(" ")))
+	b.lines += int64(bytes.Count(p, []byte("\n")))
+	return len(p), nil
+}
+
+// Hash returns the hash of the body in the same format as markov.GetSizeHash
+func (b *bodyCounter) Hash() string {
+	if b.size == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%x", b.hash.Sum64())
+}
+
+// Words returns the word count, matching the amount of space separated fields
+func (b *bodyCounter) Words() int64 {
+	return b.spaces + 1
+}
+
+// Lines returns the line count, matching the amount of newline separated fields
+func (b *bodyCounter) Lines() int64 {
+	return b.lines + 1
+}
+
+// scanningWriter writes the body to the counter and the scanners of the filters, failing with errBodyFiltered once
+// a filter matches
+type scanningWriter struct {
+	counter  *bodyCounter
+	scanners []ffuf.BodyScanner
+}
+
+func (w *scanningWriter) Write(p []byte) (int, error) {
+	w.counter.Write(p)
+	for _, s := range w.scanners {
+		if s.Scan(p) {
+			return len(p), errBodyFiltered
+		}
+	}
+	return len(p), nil
+}
+
+// readBody streams the body to the counter, up to limit bytes. A limit of 0 reads the whole body. The body stops
+// being read once one of the scanners matches. Returns whether the body was longer than the limit, the rest of it
+// being discarded, and whether it was stopped by a scanner.
+func readBody(body io.Reader, counter *bodyCounter, limit int64, scanners ...ffuf.BodyScanner) (truncated bool, filtered bool, err error) {
+	var sink io.Writer = counter
+	if len(scanners) > 0 {
+		sink = &scanningWriter{counter: counter, scanners: scanners}
+	}
+	if limit <= 0 {
+		_, err := io.Copy(sink, body)
+		if err == errBodyFiltered {
+			return false, true, nil
+		}
+		return false, false, err
+	}
+	if _, err := io.Copy(sink, io.LimitReader(body, limit)); err != nil {
+		if err == errBodyFiltered {
+			return false, true, nil
+		}
+		return false, false, err
+	}
+	// peek for any remaining data
+	n, _ := io.ReadFull(body, make([]byte, 1))
+	return n > 0, false, nil
+}
+
+// countingReader counts the bytes read from the underlying reader, the size of a body on the wire
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// cappedBuffer is an io.Writer storing only the first limit bytes written to it
+type cappedBuffer struct {
+	bytes.Buffer
+	limit int64
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if c.limit > 0 {
+		if room := c.limit - int64(c.Len()); room < int64(len(p)) {
+			if room > 0 {
+				c.Buffer.Write(p[:room])
+			}
+			return len(p), nil
+		}
+	}
+	return c.Buffer.Write(p)
+}
@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// testCA is a certificate authority issuing the certificates of the client certificate tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue returns a certificate signed by the CA, self-signed if the CA is nil, along with its key
+func (ca *testCA) issue(t *testing.T, name string, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	parent, parentKey := template, key
+	if ca != nil {
+		parent, parentKey = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Could not create certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Could not parse certificate: %s", err)
+	}
+	return cert, key
+}
+
+func writePEM(t *testing.T, name string, blockType string, der []byte) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("Could not write %s: %s", name, err)
+	}
+	return filename
+}
+
+// newMTLSServer starts a TLS server requiring a client certificate issued by the root CA
+func newMTLSServer(t *testing.T, root *x509.Certificate) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "client: %s", r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(root)
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	ts.StartTLS()
+	return ts
+}
+
+func TestExecuteClientCertificate(t *testing.T) {
+	rootCert, rootKey := (*testCA)(nil).issue(t, "root", true)
+	root := &testCA{rootCert, rootKey}
+	intermediateCert, intermediateKey := root.issue(t, "intermediate", true)
+	intermediate := &testCA{intermediateCert, intermediateKey}
+	ts := newMTLSServer(t, rootCert)
+	defer ts.Close()
+
+	clientCert, clientKey := root.issue(t, "client", false)
+	keyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatalf("Could not marshal key: %s", err)
+	}
+	conf := newTestConfig(ts.URL + "/")
+	conf.ClientCert = writePEM(t, "client.pem", "CERTIFICATE", clientCert.Raw)
+	conf.ClientKey = writePEM(t, "client.key", "EC PRIVATE KEY", keyDER)
+	if resp := executeTestRequest(t, conf, ""); string(resp.Data) != "client: client" {
+		t.Errorf("Expected the client certificate to be accepted, got %q", resp.Data)
+	}
+
+	// A certificate of the intermediate CA is accepted once the intermediate certificate is sent along with it
+	leafCert, leafKey := intermediate.issue(t, "leaf", false)
+	keyDER, err = x509.MarshalECPrivateKey(leafKey)
+	if err != nil {
+		t.Fatalf("Could not marshal key: %s", err)
+	}
+	conf = newTestConfig(ts.URL + "/")
+	conf.ClientCert = writePEM(t, "leaf.pem", "CERTIFICATE", leafCert.Raw)
+	conf.ClientKey = writePEM(t, "leaf.key", "EC PRIVATE KEY", keyDER)
+	conf.ClientCA = writePEM(t, "intermediate.pem", "CERTIFICATE", intermediateCert.Raw)
+	if resp := executeTestRequest(t, conf, ""); string(resp.Data) != "client: leaf" {
+		t.Errorf("Expected the client certificate chain to be accepted, got %q", resp.Data)
+	}
+}
+
+func TestExecuteClientCertificateRequired(t *testing.T) {
+	rootCert, _ := (*testCA)(nil).issue(t, "root", true)
+	ts := newMTLSServer(t, rootCert)
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/")
+	r := NewSimpleRunner(conf, false)
+	basereq := ffuf.BaseRequest(conf)
+	req, _ := r.Prepare(map[string][]byte{"FUZZ": []byte("")}, &basereq)
+	_, err := r.Execute(&req)
+	var handshakeErr *ffuf.TLSHandshakeError
+	if !errors.As(err, &handshakeErr) {
+		t.Fatalf("Expected a TLS handshake error without a client certificate, got %v", err)
+	}
+	// Reported to the Markov chain as a connection error rather than a timeout
+	if os.IsTimeout(err) {
+		t.Errorf("Expected the handshake failure not to be a timeout")
+	}
+}
@@ -0,0 +1,132 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// proxyBenchBase is the time an unhealthy proxy is benched for after its first consecutive failure
+	proxyBenchBase = time.Second
+	// proxyBenchMax caps the exponential backoff of an unhealthy proxy
+	proxyBenchMax = 5 * time.Minute
+)
+
+// proxyContextKey is the request context key holding the proxy selected for the request
+type proxyContextKey struct{}
+
+// proxyState holds the health statistics of a single upstream proxy
+type proxyState struct {
+	url                 *url.URL
+	requests            int
+	failures            int
+	clientErrors        int
+	rateLimited         int
+	benched             int
+	consecutiveFailures int
+	benchedUntil        time.Time
+}
+
+// proxyPool round-robins the requests across multiple upstream proxies. Proxies failing on transport
+// level, or responding with 407 or 429, are temporarily benched with an exponential backoff.
+type proxyPool struct {
+	proxies []*proxyState
+	next    int
+	now     func() time.Time
+	mutex   sync.Mutex
+}
+
+func newProxyPool(proxies []string) (*proxyPool, error) {
+	pool := &proxyPool{now: time.Now}
+	for _, p := range proxies {
+		u, err := url.Parse(p)
+		if err != nil {
+			return nil, err
+		}
+		pool.proxies = append(pool.proxies, &proxyState{url: u})
+	}
+	if len(pool.proxies) == 0 {
+		return nil, fmt.Errorf("no proxies defined")
+	}
+	return pool, nil
+}
+
+// pick returns the next healthy proxy in the rotation. If all the proxies are benched, the one
+// returning from the bench first is used.
+func (p *proxyPool) pick() *proxyState {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := p.now()
+	var earliest *proxyState
+	for i := 0; i < len(p.proxies); i++ {
+		proxy := p.proxies[(p.next+i)%len(p.proxies)]
+		if !proxy.benchedUntil.After(now) {
+			p.next = (p.next + i + 1) % len(p.proxies)
+			proxy.requests++
+			return proxy
+		}
+		if earliest == nil || proxy.benchedUntil.Before(earliest.benchedUntil) {
+			earliest = proxy
+		}
+	}
+	earliest.requests++
+	return earliest
+}
+
+// report feeds the outcome of a request back to the health statistics of the proxy it was sent through
+func (p *proxyPool) report(proxy *proxyState, statusCode int, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	unhealthy := false
+	switch {
+	case err != nil:
+		proxy.failures++
+		unhealthy = true
+	case statusCode == http.StatusTooManyRequests:
+		proxy.rateLimited++
+		unhealthy = true
+	case statusCode == http.StatusProxyAuthRequired:
+		proxy.clientErrors++
+		unhealthy = true
+	case statusCode >= 400 && statusCode < 500:
+		proxy.clientErrors++
+	}
+
+	if !unhealthy {
+		proxy.consecutiveFailures = 0
+		return
+	}
+	proxy.consecutiveFailures++
+	bench := proxyBenchBase << (proxy.consecutiveFailures - 1)
+	if bench > proxyBenchMax || bench <= 0 {
+		bench = proxyBenchMax
+	}
+	proxy.benchedUntil = p.now().Add(bench)
+	proxy.benched++
+}
+
+// proxyFunc returns the proxy selected for the request, to be used as http.Transport Proxy
+func (p *proxyPool) proxyFunc(req *http.Request) (*url.URL, error) {
+	if proxy, ok := req.Context().Value(proxyContextKey{}).(*proxyState); ok {
+		return proxy.url, nil
+	}
+	return nil, nil
+}
+
+// Summary returns the per-proxy statistics
+func (p *proxyPool) Summary() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	lines := make([]string, 0, len(p.proxies))
+	for _, proxy := range p.proxies {
+		lines = append(lines, fmt.Sprintf("Proxy %s: %d requests, %d failures, %d client errors, %d rate limited, benched %d times",
+			proxy.url.Redacted(), proxy.requests, proxy.failures, proxy.clientErrors, proxy.rateLimited, proxy.benched))
+	}
+	return lines
+}
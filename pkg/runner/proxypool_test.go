@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// proxyRoundTripper fakes the upstream proxies, failing every request sent through the dead one
+type proxyRoundTripper struct {
+	dead     string
+	status   int
+	requests map[string]int
+}
+
+func (rt *proxyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	proxy := req.Context().Value(proxyContextKey{}).(*proxyState)
+	rt.requests[proxy.url.Host]++
+	if proxy.url.Host == rt.dead {
+		return nil, errors.New("proxyconnect tcp: connection refused")
+	}
+	status := rt.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("ok")), Request: req}, nil
+}
+
+func newProxyTestRunner(dead string) (*SimpleRunner, *proxyRoundTripper, *time.Time) {
+	conf := newTestConfig("http://example.com/FUZZ")
+	conf.ProxyList = []string{"http://proxy1:8080", "http://proxy2:8080", "http://proxy3:8080"}
+	conf.Retries = 1
+	r := NewSimpleRunner(conf, false).(*SimpleRunner)
+	rt := &proxyRoundTripper{dead: dead, requests: make(map[string]int)}
+	r.client.Transport = rt
+	now := time.Now()
+	r.proxies.now = func() time.Time { return now }
+	return r, rt, &now
+}
+
+// executeProxyRequest runs a request with the runner using the fake proxies
+func executeProxyRequest(t *testing.T, r *SimpleRunner) ffuf.Response {
+	t.Helper()
+	basereq := ffuf.BaseRequest(r.config)
+	req, _ := r.Prepare(map[string][]byte{"FUZZ": []byte("foo")}, &basereq)
+	resp, err := r.Execute(&req)
+	if err != nil {
+		t.Fatalf("Could not execute request: %s", err)
+	}
+	return resp
+}
+
+func TestProxyPoolDeadProxy(t *testing.T) {
+	r, rt, now := newProxyTestRunner("proxy2:8080")
+
+	for i := 0; i < 30; i++ {
+		resp := executeProxyRequest(t, r)
+		if resp.StatusCode != 200 {
+			t.Fatalf("Request should have been retried through a healthy proxy, got status %d", resp.StatusCode)
+		}
+	}
+	if rt.requests["proxy2:8080"] != 1 {
+		t.Errorf("Dead proxy should have been benched after the first failure, got %d requests", rt.requests["proxy2:8080"])
+	}
+	if rt.requests["proxy1:8080"]+rt.requests["proxy3:8080"] != 30 {
+		t.Errorf("Traffic was not redistributed to the healthy proxies: %v", rt.requests)
+	}
+	if diff := rt.requests["proxy1:8080"] - rt.requests["proxy3:8080"]; diff > 1 || diff < -1 {
+		t.Errorf("Traffic should be balanced between the healthy proxies: %v", rt.requests)
+	}
+
+	// The proxy gets another chance after the bench, and the backoff doubles on another failure
+	*now = now.Add(2 * time.Second)
+	executeProxyRequest(t, r)
+	executeProxyRequest(t, r)
+	if rt.requests["proxy2:8080"] != 2 {
+		t.Errorf("Dead proxy should be retried after the bench, got %d requests", rt.requests["proxy2:8080"])
+	}
+	dead := r.proxies.proxies[1]
+	if bench := dead.benchedUntil.Sub(*now); bench != 2*time.Second {
+		t.Errorf("Expected exponential backoff of 2s, got %s", bench)
+	}
+}
+
+func TestProxyPoolRateLimited(t *testing.T) {
+	r, rt, _ := newProxyTestRunner("")
+	rt.status = http.StatusTooManyRequests
+	for i := 0; i < 3; i++ {
+		executeProxyRequest(t, r)
+	}
+	// All proxies are benched, the one returning first is used
+	executeProxyRequest(t, r)
+	if rt.requests["proxy1:8080"] != 2 {
+		t.Errorf("Expected the earliest benched proxy to be reused, got %v", rt.requests)
+	}
+
+	summary := r.Summary()
+	if len(summary) != 3 || summary[0] != "Proxy http://proxy1:8080: 2 requests, 0 failures, 0 client errors, 2 rate limited, benched 2 times" {
+		t.Errorf("Unexpected proxy summary: %v", summary)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"compress/flate"
 	"compress/gzip"
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -18,16 +20,16 @@ import (
 	"time"
 
 	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+	"github.com/ffuf/ffuf/v2/pkg/markov"
 
 	"github.com/andybalholm/brotli"
 )
 
-// Download results < 5MB
-const MAX_DOWNLOAD_SIZE = 5242880
-
 type SimpleRunner struct {
-	config *ffuf.Config
-	client *http.Client
+	config    *ffuf.Config
+	client    *http.Client
+	sniclient *http.Client // client of the requests sending their Host header as the TLS server name
+	proxies   *proxyPool
 }
 
 func NewSimpleRunner(conf *ffuf.Config, replay bool) ffuf.RunnerProvider {
@@ -46,10 +48,17 @@ func NewSimpleRunner(conf *ffuf.Config, replay bool) ffuf.RunnerProvider {
 			proxyURL = http.ProxyURL(pu)
 		}
 	}
+	if !replay && len(conf.ProxyList) > 0 {
+		pool, err := newProxyPool(conf.ProxyList)
+		if err == nil {
+			simplerunner.proxies = pool
+			proxyURL = pool.proxyFunc
+		}
+	}
 	cert := []tls.Certificate{}
 
 	if conf.ClientCert != "" && conf.ClientKey != "" {
-		tmp, _ := tls.LoadX509KeyPair(conf.ClientCert, conf.ClientKey)
+		tmp, _ := ffuf.ClientCertificate(conf)
 		cert = []tls.Certificate{tmp}
 	}
 
@@ -67,6 +76,8 @@ func NewSimpleRunner(conf *ffuf.Config, replay bool) ffuf.RunnerProvider {
 				Timeout: time.Duration(time.Duration(conf.Timeout) * time.Second),
 			}).DialContext,
 			TLSHandshakeTimeout: time.Duration(time.Duration(conf.Timeout) * time.Second),
+			// The bodies are decoded by the runner, to know their size on the wire
+			DisableCompression: true,
 			TLSClientConfig: &tls.Config{
 				InsecureSkipVerify: true,
 				MinVersion:         tls.VersionTLS10,
@@ -79,6 +90,9 @@ func NewSimpleRunner(conf *ffuf.Config, replay bool) ffuf.RunnerProvider {
 	if conf.FollowRedirects {
 		simplerunner.client.CheckRedirect = nil
 	}
+	if fuzzesHost(conf) {
+		simplerunner.sniclient = newSNIClient(simplerunner.client)
+	}
 	return &simplerunner
 }
 
@@ -107,17 +121,7 @@ func (r *SimpleRunner) Execute(req *ffuf.Request) (ffuf.Response, error) {
 	var rawreq []byte
 	data := bytes.NewReader(req.Data)
 
-	var start time.Time
-	var firstByteTime time.Duration
-
-	trace := &httptrace.ClientTrace{
-		WroteRequest: func(wri httptrace.WroteRequestInfo) {
-			start = time.Now() // begin the timer after the request is fully written
-		},
-		GotFirstResponseByte: func() {
-			firstByteTime = time.Since(start) // record when the first byte of the response was received
-		},
-	}
+	timer := &requestTimer{}
 
 	httpreq, err = http.NewRequestWithContext(r.config.Context, req.Method, req.Url, data)
 
@@ -136,7 +140,10 @@ func (r *SimpleRunner) Execute(req *ffuf.Request) (ffuf.Response, error) {
 	}
 
 	req.Host = httpreq.Host
-	httpreq = httpreq.WithContext(httptrace.WithClientTrace(r.config.Context, trace))
+	httpreq = httpreq.WithContext(httptrace.WithClientTrace(r.config.Context, timer.trace()))
+	if r.sniclient != nil {
+		httpreq = withSNI(httpreq)
+	}
 
 	if r.config.Raw {
 		httpreq.URL.Opaque = req.Url
@@ -145,75 +152,206 @@ func (r *SimpleRunner) Execute(req *ffuf.Request) (ffuf.Response, error) {
 	for k, v := range req.Headers {
 		httpreq.Header.Set(k, v)
 	}
+	// Ask for a gzip body like the transport does by default, the runner decoding it
+	if httpreq.Header.Get("Accept-Encoding") == "" && httpreq.Header.Get("Range") == "" && httpreq.Method != http.MethodHead {
+		httpreq.Header.Set("Accept-Encoding", "gzip")
+	}
 
 	if len(r.config.OutputDirectory) > 0 || len(r.config.AuditLog) > 0 {
 		rawreq, _ = httputil.DumpRequestOut(httpreq, true)
 		req.Raw = string(rawreq)
 	}
 
-	httpresp, err := r.client.Do(httpreq)
+	httpresp, retries, err := r.doWithRetries(httpreq, req.AllowRetry)
 	if err != nil {
-		return ffuf.Response{}, err
+		return ffuf.Response{}, handshakeError(err)
 	}
 
+	start, timing := timer.result()
 	req.Timestamp = start
 
 	resp := ffuf.NewResponse(httpresp, req)
+	resp.Retries = retries
+	resp.Redirects = redirectChain(httpresp)
 	defer httpresp.Body.Close()
 
 	// Check if we should download the resource or not
 	size, err := strconv.Atoi(httpresp.Header.Get("Content-Length"))
 	if err == nil {
 		resp.ContentLength = int64(size)
-		if (r.config.IgnoreBody) || (size > MAX_DOWNLOAD_SIZE) {
+		if r.config.IgnoreBody {
+			// The size is the one announced, the body being left unread on purpose rather than cut off
 			resp.Cancelled = true
+			resp.Complete = true
 			return resp, nil
 		}
 	}
 
+	// Keep a copy of the raw body for the dump, capped to the same size as the decoded one
+	var rawbody *cappedBuffer
+	wire := &countingReader{r: httpresp.Body}
+	var bodySource io.Reader = wire
 	if len(r.config.OutputDirectory) > 0 || len(r.config.AuditLog) > 0 {
-		rawresp, _ := httputil.DumpResponse(httpresp, true)
-		resp.Request.Raw = string(rawreq)
-		resp.Raw = string(rawresp)
+		rawbody = &cappedBuffer{limit: r.config.ResponseSizeLimit}
+		bodySource = io.TeeReader(wire, rawbody)
 	}
-	var bodyReader io.ReadCloser
-	if httpresp.Header.Get("Content-Encoding") == "gzip" {
-		bodyReader, err = gzip.NewReader(httpresp.Body)
-		if err != nil {
-			// fallback to raw data
-			bodyReader = httpresp.Body
-		}
-	} else if httpresp.Header.Get("Content-Encoding") == "br" {
-		bodyReader = io.NopCloser(brotli.NewReader(httpresp.Body))
-		if err != nil {
-			// fallback to raw data
-			bodyReader = httpresp.Body
-		}
-	} else if httpresp.Header.Get("Content-Encoding") == "deflate" {
-		bodyReader = flate.NewReader(httpresp.Body)
+
+	var bodyReader io.Reader
+	encoding := strings.ToLower(strings.TrimSpace(httpresp.Header.Get("Content-Encoding")))
+	if encoding == "gzip" {
+		bodyReader, err = gzip.NewReader(bodySource)
 		if err != nil {
 			// fallback to raw data
-			bodyReader = httpresp.Body
+			bodyReader = bodySource
 		}
+	} else if encoding == "br" {
+		bodyReader = brotli.NewReader(bodySource)
+	} else if encoding == "deflate" {
+		bodyReader = flate.NewReader(bodySource)
 	} else {
-		bodyReader = httpresp.Body
+		bodyReader = bodySource
+	}
+	if encoding != "identity" {
+		resp.Encoding = encoding
 	}
 
-	if respbody, err := io.ReadAll(bodyReader); err == nil {
-		resp.ContentLength = int64(len(string(respbody)))
-		resp.Data = respbody
+	// Stream the body through the counter, discarding everything past the size limit
+	counter := newBodyCounter()
+	truncated, filtered, err := readBody(bodyReader, counter, r.config.ResponseSizeLimit, r.bodyScanners(&resp)...)
+	// A server closing the connection before the end of the body fails the read with io.ErrUnexpectedEOF rather
+	// than ending it cleanly. The part of the body read is kept, the response being marked incomplete. A body left
+	// unread once filtered is neither.
+	resp.Truncated = truncated
+	resp.Complete = !truncated && err == nil
+	// Size matching relies on the Content-Length header for the responses cut off or filtered, unless the body is
+	// encoded: the header is then the size on the wire, the decoded size being the one of the responses sent without
+	// encoding
+	if (resp.Complete && !filtered) || resp.ContentLength == 0 || resp.Encoding != "" {
+		resp.ContentLength = counter.size
+	}
+	// The bytes read are counted rather than taken from the Content-Length header, that some servers get wrong
+	resp.WireLength = wire.n
+	resp.Data = counter.data.Bytes()
+	resp.BodyHash = counter.Hash()
+	if r.config.StructuralHash && strings.Contains(resp.ContentType, "html") {
+		resp.BodyHash = markov.GetStructuralHash(resp.Data)
 	}
 
-	wordsSize := len(strings.Split(string(resp.Data), " "))
-	linesSize := len(strings.Split(string(resp.Data), "\n"))
-	resp.ContentWords = int64(wordsSize)
-	resp.ContentLines = int64(linesSize)
-	resp.Duration = firstByteTime
-	resp.Timestamp = start.Add(firstByteTime)
+	if rawbody != nil {
+		rawresp, _ := httputil.DumpResponse(httpresp, false)
+		resp.Request.Raw = string(rawreq)
+		resp.Raw = string(rawresp) + rawbody.String()
+	}
+
+	resp.ContentWords = counter.Words()
+	resp.ContentLines = counter.Lines()
+	resp.Timing = timing
+	resp.Duration = timing.TTFB
+	resp.Timestamp = start.Add(timing.TTFB)
 
 	return resp, nil
 }
 
+// redirectChain returns the redirects followed by the client to get the response, oldest first. Each request of a
+// redirect keeps the response that caused it.
+func redirectChain(httpresp *http.Response) []ffuf.RedirectHop {
+	var hops []ffuf.RedirectHop
+	for r := httpresp.Request; r != nil && r.Response != nil; r = r.Response.Request {
+		hop := ffuf.RedirectHop{StatusCode: int64(r.Response.StatusCode), Location: r.Response.Header.Get("Location")}
+		hops = append([]ffuf.RedirectHop{hop}, hops...)
+	}
+	return hops
+}
+
+// bodyScanners returns the scanners of the filters able to stop reading the body once they match. Only a filter of
+// the "or" filter mode decides alone that a response is filtered, the matchers and the other filters needing the
+// whole body.
+func (r *SimpleRunner) bodyScanners(resp *ffuf.Response) []ffuf.BodyScanner {
+	if r.config.MatcherManager == nil || r.config.FilterMode != "or" {
+		return nil
+	}
+	filters := r.config.MatcherManager.GetFilters()
+	if r.config.AutoCalibrationPerHost {
+		filters = r.config.MatcherManager.FiltersForDomain(ffuf.HostURLFromRequest(*resp.Request))
+	}
+	scanners := make([]ffuf.BodyScanner, 0)
+	for _, f := range filters {
+		if sf, ok := f.(ffuf.StreamingFilter); ok {
+			if s := sf.BodyScanner(resp); s != nil {
+				scanners = append(scanners, s)
+			}
+		}
+	}
+	return scanners
+}
+
+// doWithRetries sends the request, retrying up to the configured amount of times on transport level errors, as long
+// as allow, if set, allows it. HTTP error status codes are not retried. Returns the response and the number of
+// retries it took.
+func (r *SimpleRunner) doWithRetries(httpreq *http.Request, allow func() bool) (*http.Response, int, error) {
+	retries := 0
+	for {
+		httpresp, err := r.do(httpreq)
+		if err == nil || retries >= r.config.Retries || r.config.Context.Err() != nil || (allow != nil && !allow()) {
+			return httpresp, retries, err
+		}
+		// makes the delay cancellable by context
+		select {
+		case <-r.config.Context.Done():
+			return httpresp, retries, err
+		case <-time.After(r.config.RetryDelay):
+		}
+		if httpreq.GetBody != nil {
+			// rewind the request body for the next attempt
+			httpreq.Body, err = httpreq.GetBody()
+			if err != nil {
+				return nil, retries, err
+			}
+		}
+		retries++
+	}
+}
+
+// handshakeError wraps the errors of a failed TLS handshake, other than timeouts, in a ffuf.TLSHandshakeError
+func handshakeError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return err
+	}
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &recordErr) || strings.Contains(err.Error(), "tls: ") {
+		return &ffuf.TLSHandshakeError{Err: err}
+	}
+	return err
+}
+
+// do sends a single request attempt, through the next proxy in the rotation if a proxy list is in use
+func (r *SimpleRunner) do(httpreq *http.Request) (*http.Response, error) {
+	client := r.client
+	if _, ok := httpreq.Context().Value(sniContextKey{}).(string); ok {
+		client = r.sniclient
+	}
+	if r.proxies == nil {
+		return client.Do(httpreq)
+	}
+	proxy := r.proxies.pick()
+	httpresp, err := client.Do(httpreq.WithContext(context.WithValue(httpreq.Context(), proxyContextKey{}, proxy)))
+	statusCode := 0
+	if err == nil {
+		statusCode = httpresp.StatusCode
+	}
+	r.proxies.report(proxy, statusCode, err)
+	return httpresp, err
+}
+
+// Summary returns the per-proxy statistics when a proxy list is in use
+func (r *SimpleRunner) Summary() []string {
+	if r.proxies == nil {
+		return []string{}
+	}
+	return r.proxies.Summary()
+}
+
 func (r *SimpleRunner) Dump(req *ffuf.Request) ([]byte, error) {
 	var httpreq *http.Request
 	var err error
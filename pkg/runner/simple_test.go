@@ -0,0 +1,682 @@
+package runner
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+	"github.com/ffuf/ffuf/v2/pkg/filter"
+	"github.com/ffuf/ffuf/v2/pkg/markov"
+)
+
+func newTestConfig(url string) *ffuf.Config {
+	ctx, cancel := context.WithCancel(context.Background())
+	conf := ffuf.NewConfig(ctx, cancel)
+	conf.Url = url
+	return &conf
+}
+
+func executeTestRequest(t *testing.T, conf *ffuf.Config, input string) ffuf.Response {
+	t.Helper()
+	r := NewSimpleRunner(conf, false)
+	basereq := ffuf.BaseRequest(conf)
+	req, err := r.Prepare(map[string][]byte{"FUZZ": []byte(input)}, &basereq)
+	if err != nil {
+		t.Fatalf("Could not prepare request: %s", err)
+	}
+	resp, err := r.Execute(&req)
+	if err != nil {
+		t.Fatalf("Could not execute request: %s", err)
+	}
+	return resp
+}
+
+func newHTTP2Server() *httptest.Server {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "proto: %s", r.Proto)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	return ts
+}
+
+func TestExecuteHTTP2(t *testing.T) {
+	ts := newHTTP2Server()
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/FUZZ")
+	conf.Http2 = true
+	resp := executeTestRequest(t, conf, "foo")
+	if resp.Proto != "HTTP/2.0" {
+		t.Errorf("Expected HTTP/2.0 to be negotiated, got %s", resp.Proto)
+	}
+	if string(resp.Data) != "proto: HTTP/2.0" {
+		t.Errorf("Unexpected response body: %s", resp.Data)
+	}
+
+	conf = newTestConfig(ts.URL + "/FUZZ")
+	resp = executeTestRequest(t, conf, "foo")
+	if resp.Proto != "HTTP/1.1" {
+		t.Errorf("Expected HTTP/1.1 without -http2, got %s", resp.Proto)
+	}
+}
+
+func TestExecuteHTTP2Fallback(t *testing.T) {
+	// Server without h2 support, the runner should gracefully fall back to HTTP/1.1
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "proto: %s", r.Proto)
+	}))
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/FUZZ")
+	conf.Http2 = true
+	resp := executeTestRequest(t, conf, "foo")
+	if resp.Proto != "HTTP/1.1" {
+		t.Errorf("Expected fallback to HTTP/1.1, got %s", resp.Proto)
+	}
+}
+
+func TestExecuteHTTP2RawOutput(t *testing.T) {
+	ts := newHTTP2Server()
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/FUZZ")
+	conf.Http2 = true
+	conf.OutputDirectory = t.TempDir()
+	resp := executeTestRequest(t, conf, "foo")
+	if !strings.HasPrefix(resp.Raw, "HTTP/2.0 200 OK") {
+		t.Errorf("Raw response not rendered for HTTP/2: %q", resp.Raw)
+	}
+	if !strings.Contains(resp.Raw, "proto: HTTP/2.0") {
+		t.Errorf("Raw response is missing the body: %q", resp.Raw)
+	}
+	if !strings.HasPrefix(resp.Request.Raw, "GET /foo HTTP/1.1") {
+		t.Errorf("Raw request not rendered: %q", resp.Request.Raw)
+	}
+}
+
+// flakyHandler drops the connection without a response for the first n requests
+func flakyHandler(n int) http.Handler {
+	var mu sync.Mutex
+	attempts := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		fail := attempts <= n
+		mu.Unlock()
+		if fail {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		fmt.Fprint(w, "ok")
+	})
+}
+
+func TestExecuteRetries(t *testing.T) {
+	ts := httptest.NewServer(flakyHandler(1))
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/FUZZ")
+	conf.Retries = 2
+	conf.RetryDelay = 10 * time.Millisecond
+	resp := executeTestRequest(t, conf, "foo")
+	if resp.StatusCode != 200 || string(resp.Data) != "ok" {
+		t.Errorf("Expected the retried request to succeed, got %d: %s", resp.StatusCode, resp.Data)
+	}
+	if resp.Retries != 1 {
+		t.Errorf("Expected 1 retry, got %d", resp.Retries)
+	}
+}
+
+func TestExecuteRetriesExhausted(t *testing.T) {
+	ts := httptest.NewServer(flakyHandler(1))
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/FUZZ")
+	conf.Retries = 0
+	r := NewSimpleRunner(conf, false)
+	basereq := ffuf.BaseRequest(conf)
+	req, _ := r.Prepare(map[string][]byte{"FUZZ": []byte("foo")}, &basereq)
+	if _, err := r.Execute(&req); err == nil {
+		t.Errorf("Expected a transport error without retries")
+	}
+}
+
+func TestExecuteRetriesDenied(t *testing.T) {
+	ts := httptest.NewServer(flakyHandler(2))
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/FUZZ")
+	conf.Retries = 3
+	conf.RetryDelay = 10 * time.Millisecond
+	r := NewSimpleRunner(conf, false)
+	basereq := ffuf.BaseRequest(conf)
+	req, _ := r.Prepare(map[string][]byte{"FUZZ": []byte("foo")}, &basereq)
+	asked := 0
+	req.AllowRetry = func() bool {
+		asked++
+		return asked == 1
+	}
+	if _, err := r.Execute(&req); err == nil {
+		t.Errorf("Expected a transport error once the retries are denied")
+	}
+	if asked != 2 {
+		t.Errorf("Expected the retry hook asked before each retry, asked %d times", asked)
+	}
+}
+
+func TestExecuteRetriesStatusCode(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/FUZZ")
+	conf.Retries = 3
+	resp := executeTestRequest(t, conf, "foo")
+	if resp.StatusCode != 503 || resp.Retries != 0 || requests != 1 {
+		t.Errorf("HTTP error status codes should not be retried, got status %d after %d requests", resp.StatusCode, requests)
+	}
+}
+
+func TestExecuteRetriesRewindBody(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		first := attempts == 1
+		mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		if first {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/")
+	conf.Method = "POST"
+	conf.Data = "value=FUZZ"
+	resp := executeTestRequest(t, conf, "foo")
+	if string(resp.Data) != "value=foo" {
+		t.Errorf("Request body was not resent on retry, got: %q", resp.Data)
+	}
+}
+
+// newTestCertificate returns a self-signed certificate valid for the DNS names and 127.0.0.1
+func newTestCertificate(t *testing.T, names ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Could not create certificate: %s", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// newCertServer starts a TLS server with a certificate valid for example.com and *.example.com
+func newCertServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "host: %s", r.Host)
+	}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{newTestCertificate(t, "example.com", "*.example.com")}}
+	ts.StartTLS()
+	return ts
+}
+
+func TestExecuteCertNames(t *testing.T) {
+	ts := newCertServer(t)
+	defer ts.Close()
+
+	tests := []struct {
+		host     string
+		mismatch bool
+	}{
+		{"example.com", false},
+		{"admin.example.com", false},
+		{"admin.internal", true},
+		{"a.b.example.com", true},
+	}
+	for _, test := range tests {
+		conf := newTestConfig(ts.URL + "/")
+		conf.Headers = map[string]string{"Host": test.host}
+		resp := executeTestRequest(t, conf, "")
+		if len(resp.CertNames) != 2 || resp.CertNames[0] != "example.com" {
+			t.Errorf("Certificate names not captured: %v", resp.CertNames)
+		}
+		if resp.CertMismatch() != test.mismatch {
+			t.Errorf("Host %s: expected certificate mismatch to be %t", test.host, test.mismatch)
+		}
+	}
+
+	// No certificate names are captured for plain HTTP
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+	conf := newTestConfig(plain.URL + "/")
+	conf.Headers = map[string]string{"Host": "admin.internal"}
+	resp := executeTestRequest(t, conf, "")
+	if len(resp.CertNames) != 0 || resp.CertMismatch() {
+		t.Errorf("Plain HTTP response should not have certificate names: %v", resp.CertNames)
+	}
+}
+
+// largeBodyHandler serves 100MB of data, optionally without a Content-Length header
+func largeBodyHandler(withLength bool) http.Handler {
+	const size = 100 * 1024 * 1024
+	chunk := []byte(strings.Repeat("lorem ipsum\n", 1024))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if withLength {
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+		}
+		for written := 0; written < size; written += len(chunk) {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func TestExecuteResponseSizeLimit(t *testing.T) {
+	for _, withLength := range []bool{true, false} {
+		ts := httptest.NewServer(largeBodyHandler(withLength))
+
+		conf := newTestConfig(ts.URL + "/FUZZ")
+		conf.ResponseSizeLimit = 1024 * 1024
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		resp := executeTestRequest(t, conf, "foo")
+		runtime.ReadMemStats(&after)
+		ts.Close()
+
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16*1024*1024 {
+			t.Errorf("Reading a truncated response allocated %d bytes", allocated)
+		}
+		if !resp.Truncated {
+			t.Errorf("Response should have been truncated")
+		}
+		if int64(len(resp.Data)) != conf.ResponseSizeLimit {
+			t.Errorf("Expected %d bytes of data, got %d", conf.ResponseSizeLimit, len(resp.Data))
+		}
+		expectedLength := conf.ResponseSizeLimit
+		if withLength {
+			expectedLength = 100 * 1024 * 1024
+		}
+		if resp.ContentLength != expectedLength {
+			t.Errorf("Expected content length %d, got %d", expectedLength, resp.ContentLength)
+		}
+		if resp.ContentLines != conf.ResponseSizeLimit/12+1 {
+			t.Errorf("Unexpected line count for the read prefix: %d", resp.ContentLines)
+		}
+		if resp.ContentWords != conf.ResponseSizeLimit/12+1 {
+			t.Errorf("Unexpected word count for the read prefix: %d", resp.ContentWords)
+		}
+		if resp.BodyHash != markov.GetSizeHash(resp.Data) {
+			t.Errorf("Body hash does not match the read prefix")
+		}
+	}
+}
+
+func TestExecuteResponseCounts(t *testing.T) {
+	body := "foo bar baz\nfoo\n\nbar"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/FUZZ")
+	conf.OutputDirectory = t.TempDir()
+	resp := executeTestRequest(t, conf, "foo")
+	if resp.Truncated {
+		t.Errorf("Response under the size limit should not be truncated")
+	}
+	if resp.ContentLength != int64(len(body)) || string(resp.Data) != body {
+		t.Errorf("Unexpected response body: %q", resp.Data)
+	}
+	if resp.ContentWords != int64(len(strings.Split(body, " "))) || resp.ContentLines != int64(len(strings.Split(body, "\n"))) {
+		t.Errorf("Unexpected counts: %d words, %d lines", resp.ContentWords, resp.ContentLines)
+	}
+	if resp.BodyHash != markov.GetSizeHash([]byte(body)) {
+		t.Errorf("Body hash does not match the body")
+	}
+	if !strings.HasSuffix(resp.Raw, "\r\n\r\n"+body) {
+		t.Errorf("Raw response is missing the body: %q", resp.Raw)
+	}
+}
+
+func TestExecuteStructuralHash(t *testing.T) {
+	token := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<html><body><form id="login"><input name="csrf" value="%d"></form></body></html>`, token)
+	}))
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/FUZZ")
+	if executeTestRequest(t, conf, "foo").BodyHash == executeTestRequest(t, conf, "foo").BodyHash {
+		t.Errorf("Expected the body hashes to differ without -structural-hash")
+	}
+	conf.StructuralHash = true
+	first := executeTestRequest(t, conf, "foo")
+	if second := executeTestRequest(t, conf, "foo"); first.BodyHash != second.BodyHash {
+		t.Errorf("Expected the structural hashes of the pages differing by a token to match")
+	}
+	if first.BodyHash != markov.GetStructuralHash(first.Data) {
+		t.Errorf("Expected the body hash to be the structural hash")
+	}
+}
+
+// truncatingHandler announces a body of size bytes, and closes the connection after writing only half of it
+func truncatingHandler(size int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", size, strings.Repeat("a ", size/4))
+		buf.Flush()
+	})
+}
+
+func TestExecuteIncompleteResponse(t *testing.T) {
+	ts := httptest.NewServer(truncatingHandler(100))
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/FUZZ")
+	resp := executeTestRequest(t, conf, "foo")
+	if resp.Complete || resp.Truncated {
+		t.Errorf("Expected a response cut off by the server to be incomplete, not truncated")
+	}
+	if string(resp.Data) != strings.Repeat("a ", 25) || resp.ContentWords != 26 {
+		t.Errorf("Expected the part of the body read to be kept, got %q and %d words", resp.Data, resp.ContentWords)
+	}
+	if resp.ContentLength != 100 {
+		t.Errorf("Expected the announced content length, got %d", resp.ContentLength)
+	}
+
+	conf.ResponseSizeLimit = 10
+	if resp := executeTestRequest(t, conf, "foo"); resp.Complete || !resp.Truncated {
+		t.Errorf("Expected a response over the size limit to be truncated and incomplete")
+	}
+
+	complete := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "foo")
+	}))
+	defer complete.Close()
+	if resp := executeTestRequest(t, newTestConfig(complete.URL+"/FUZZ"), "foo"); !resp.Complete {
+		t.Errorf("Expected a fully read response to be complete")
+	}
+}
+
+func TestExecuteIgnoreBodyCalibration(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "not found here")
+	}))
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/FUZZ")
+	conf.IgnoreBody = true
+	conf.AutoCalibration = true
+	conf.AutoCalibrationStrings = []string{"calib1", "calib2"}
+	conf.MatcherManager = filter.NewMatcherManager()
+	if err := conf.MatcherManager.AddMatcher("status", "200"); err != nil {
+		t.Fatalf("Could not add the matcher: %s", err)
+	}
+	resp := executeTestRequest(t, conf, "foo")
+	if !resp.Cancelled || !resp.Complete || resp.ContentLength != 14 {
+		t.Errorf("Expected the unread body to be complete with the announced size, got %+v", resp)
+	}
+
+	j := ffuf.NewJob(conf)
+	j.Runner = NewSimpleRunner(conf, false)
+	if err := j.Calibrate(map[string][]byte{}); err != nil {
+		t.Fatalf("Could not calibrate: %s", err)
+	}
+	if f, ok := conf.MatcherManager.GetFilters()["size"]; !ok || f.Repr() != "14" {
+		t.Errorf("Expected the responses with their body ignored to calibrate a size filter, got %v", conf.MatcherManager.GetFilters())
+	}
+}
+
+// markedBodyHandler serves size bytes of data with a marker split across two writes after the first 64kB
+func markedBodyHandler(size int) http.Handler {
+	chunk := []byte(strings.Repeat("x", 32*1024))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		written := 0
+		for written < size {
+			n, err := w.Write(chunk)
+			if err != nil {
+				return
+			}
+			written += n
+			if written == 64*1024 {
+				w.Write([]byte("secret-"))
+				w.(http.Flusher).Flush()
+				w.Write([]byte("token"))
+			}
+		}
+	})
+}
+
+func TestExecuteStreamingRegexpFilter(t *testing.T) {
+	const size = 100 * 1024 * 1024
+	ts := httptest.NewServer(markedBodyHandler(size))
+	defer ts.Close()
+
+	for _, test := range []struct {
+		filter  string
+		mode    string
+		stopped bool
+	}{
+		{"secret-token", "or", true},
+		{"secret-[a-z]+", "or", false}, // unbounded, matched on the whole body
+		{"secret-token", "and", false},
+		{"not-there", "or", false},
+	} {
+		conf := newTestConfig(ts.URL + "/FUZZ")
+		conf.MatcherManager = filter.NewMatcherManager()
+		conf.FilterMode = test.mode
+		if err := conf.MatcherManager.AddFilter("regexp", test.filter, false); err != nil {
+			t.Fatalf("Could not add the filter: %s", err)
+		}
+		if !test.stopped {
+			// Keep the full reads of the other cases small
+			conf.ResponseSizeLimit = 1024 * 1024
+		}
+		resp := executeTestRequest(t, conf, "foo")
+		if test.stopped {
+			if resp.Truncated || !resp.Complete || len(resp.Data) >= 1024*1024 {
+				t.Errorf("%s: expected the body to stop being read after the match, read %d bytes", test.filter, len(resp.Data))
+			}
+			if !strings.Contains(string(resp.Data), "secret-token") {
+				t.Errorf("%s: expected the read body to hold the match", test.filter)
+			}
+		} else if int64(len(resp.Data)) != conf.ResponseSizeLimit {
+			t.Errorf("%s (%s): expected the body to be read up to the size limit, read %d bytes", test.filter, test.mode, len(resp.Data))
+		}
+	}
+}
+
+// redirectHandler serves a login page at /login/, reached through two redirects from /two and three from /three
+func redirectHandler() http.Handler {
+	redirects := map[string]string{
+		"/two":   "/login",
+		"/three": "/a",
+		"/a":     "/b",
+		"/b":     "/login/",
+		"/login": "/login/",
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if location, ok := redirects[r.URL.Path]; ok {
+			status := http.StatusFound
+			if r.URL.Path == "/login" {
+				status = http.StatusMovedPermanently
+			}
+			http.Redirect(w, r, location, status)
+			return
+		}
+		fmt.Fprint(w, "<form>login</form>")
+	})
+}
+
+func TestExecuteRedirectChain(t *testing.T) {
+	ts := httptest.NewServer(redirectHandler())
+	defer ts.Close()
+	conf := newTestConfig(ts.URL + "/FUZZ")
+
+	// The redirects are not followed by default
+	if resp := executeTestRequest(t, conf, "two"); resp.StatusCode != 302 || len(resp.Redirects) != 0 {
+		t.Errorf("Expected the redirect itself without -r, got %d with %v", resp.StatusCode, resp.Redirects)
+	}
+
+	conf.FollowRedirects = true
+	resp := executeTestRequest(t, conf, "two")
+	expected := "302 /login -> 301 /login/"
+	if resp.StatusCode != 200 || ffuf.RedirectChain(resp.Redirects) != expected || !resp.InternalRedirects() {
+		t.Errorf("Expected the internal chain %q, got %d with %q", expected, resp.StatusCode, ffuf.RedirectChain(resp.Redirects))
+	}
+	resp = executeTestRequest(t, conf, "three")
+	expected = "302 /a -> 302 /b -> 302 /login/"
+	if resp.StatusCode != 200 || ffuf.RedirectChain(resp.Redirects) != expected || !resp.InternalRedirects() {
+		t.Errorf("Expected the internal chain %q, got %d with %q", expected, resp.StatusCode, ffuf.RedirectChain(resp.Redirects))
+	}
+	if resp := executeTestRequest(t, conf, "login/"); len(resp.Redirects) != 0 || resp.InternalRedirects() {
+		t.Errorf("Expected no redirects for a direct response, got %v", resp.Redirects)
+	}
+
+	// A redirect to another host is not internal
+	away := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)+"/login/", http.StatusFound)
+	}))
+	defer away.Close()
+	conf = newTestConfig(away.URL + "/FUZZ")
+	conf.FollowRedirects = true
+	resp = executeTestRequest(t, conf, "foo")
+	if len(resp.Redirects) != 1 || resp.InternalRedirects() {
+		t.Errorf("Expected a single redirect to another host, got %v", resp.Redirects)
+	}
+}
+
+// encodedHandler serves the body encoded with the encoding, with the Content-Length of the encoded body
+func encodedHandler(encoding string, body []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var encoded bytes.Buffer
+		switch encoding {
+		case "gzip":
+			zw := gzip.NewWriter(&encoded)
+			zw.Write(body)
+			zw.Close()
+		case "br":
+			bw := brotli.NewWriter(&encoded)
+			bw.Write(body)
+			bw.Close()
+		default:
+			encoded.Write(body)
+		}
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(encoded.Len()))
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		w.Write(encoded.Bytes())
+	})
+}
+
+func TestExecuteContentEncoding(t *testing.T) {
+	body := []byte(strings.Repeat("not found ", 1000))
+	for _, encoding := range []string{"gzip", "br", ""} {
+		ts := httptest.NewServer(encodedHandler(encoding, body))
+		conf := newTestConfig(ts.URL + "/FUZZ")
+		if encoding == "br" {
+			conf.Headers["Accept-Encoding"] = "br"
+		}
+		resp := executeTestRequest(t, conf, "foo")
+		ts.Close()
+
+		if resp.Encoding != encoding {
+			t.Errorf("%q: expected the content encoding to be recorded, got %q", encoding, resp.Encoding)
+		}
+		if resp.ContentLength != int64(len(body)) || string(resp.Data) != string(body) {
+			t.Errorf("%q: expected the decoded body of %d bytes, got %d bytes", encoding, len(body), resp.ContentLength)
+		}
+		if encoding == "" && resp.WireLength != int64(len(body)) {
+			t.Errorf("Expected the identity body to have the same size on the wire, got %d", resp.WireLength)
+		}
+		if encoding != "" && (resp.WireLength == 0 || resp.WireLength >= int64(len(body))) {
+			t.Errorf("%q: expected the compressed size on the wire, got %d", encoding, resp.WireLength)
+		}
+		if encoding == "gzip" && resp.Headers["X-Accept-Encoding"][0] != "gzip" {
+			t.Errorf("Expected gzip to be accepted by default, got %v", resp.Headers["X-Accept-Encoding"])
+		}
+	}
+}
+
+func TestExecuteContentEncodingWrongLength(t *testing.T) {
+	var encoded bytes.Buffer
+	zw := gzip.NewWriter(&encoded)
+	zw.Write([]byte(strings.Repeat("not found ", 1000)))
+	zw.Close()
+	// The server announces twice the size of the gzip body it sends
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Encoding: gzip\r\nContent-Length: %d\r\n\r\n", encoded.Len()*2)
+		buf.Write(encoded.Bytes())
+		buf.Flush()
+	}))
+	defer ts.Close()
+
+	resp := executeTestRequest(t, newTestConfig(ts.URL+"/FUZZ"), "foo")
+	if resp.Complete {
+		t.Errorf("Expected the response shorter than its Content-Length to be incomplete")
+	}
+	if resp.ContentLength != 10000 {
+		t.Errorf("Expected the decoded size rather than the announced one, got %d", resp.ContentLength)
+	}
+	if resp.WireLength != int64(encoded.Len()) {
+		t.Errorf("Expected the %d bytes received on the wire, got %d", encoded.Len(), resp.WireLength)
+	}
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// sniContextKey carries the TLS server name of a request in its context
+type sniContextKey struct{}
+
+// fuzzesHost returns true if a keyword is in the Host header and no SNI is set, the server name of the TLS handshake
+// following the Host header then, for the virtual hosts served with a certificate of their own to be told apart
+func fuzzesHost(conf *ffuf.Config) bool {
+	if conf.SNI != "" {
+		return false
+	}
+	for name, value := range conf.Headers {
+		if !strings.EqualFold(name, "Host") {
+			continue
+		}
+		for _, provider := range conf.InputProviders {
+			if strings.Contains(value, provider.Keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newSNIClient returns a client sending the server name carried in the context of each request in the TLS
+// handshake. The connections are not reused, as the server name is only sent when connecting. Requests through a
+// proxy keep the server name of the URL.
+func newSNIClient(client *http.Client) *http.Client {
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = true
+	tlsConfig := transport.TLSClientConfig
+	dialer := &net.Dialer{Timeout: transport.TLSHandshakeTimeout}
+	transport.DialTLSContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		config := tlsConfig.Clone()
+		if name, ok := ctx.Value(sniContextKey{}).(string); ok {
+			config.ServerName = name
+		} else if host, _, err := net.SplitHostPort(addr); err == nil {
+			config.ServerName = host
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+	return &http.Client{
+		CheckRedirect: client.CheckRedirect,
+		Timeout:       client.Timeout,
+		Transport:     transport,
+	}
+}
+
+// withSNI sets the server name of the TLS handshake of the request to its Host header
+func withSNI(httpreq *http.Request) *http.Request {
+	if httpreq.URL.Scheme != "https" || httpreq.Host == "" {
+		return httpreq
+	}
+	name := httpreq.Host
+	if host, _, err := net.SplitHostPort(name); err == nil {
+		name = host
+	}
+	return httpreq.WithContext(context.WithValue(httpreq.Context(), sniContextKey{}, name))
+}
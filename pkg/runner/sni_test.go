@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// newSNIServer starts a TLS server serving admin.example.test with a certificate of its own, and the other hosts
+// with a wildcard certificate, both picked from the server name of the handshake
+func newSNIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	wildcard := newTestCertificate(t, "*.example.test")
+	admin := newTestCertificate(t, "admin.example.test")
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "host: %s, server name: %s", r.Host, r.TLS.ServerName)
+	}))
+	ts.TLS = &tls.Config{GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if hello.ServerName == "admin.example.test" {
+			return &admin, nil
+		}
+		return &wildcard, nil
+	}}
+	ts.StartTLS()
+	return ts
+}
+
+func TestExecuteVhostSNI(t *testing.T) {
+	ts := newSNIServer(t)
+	defer ts.Close()
+
+	conf := newTestConfig(ts.URL + "/")
+	conf.Headers = map[string]string{"Host": "FUZZ.example.test"}
+	conf.InputProviders = []ffuf.InputProviderConfig{{Name: "wordlist", Keyword: "FUZZ"}}
+	admin := executeTestRequest(t, conf, "admin")
+	other := executeTestRequest(t, conf, "random")
+	if string(admin.Data) != "host: admin.example.test, server name: admin.example.test" {
+		t.Errorf("Expected the Host header to be sent as the server name, got %q", admin.Data)
+	}
+	if len(admin.CertNames) != 1 || admin.CertNames[0] != "admin.example.test" {
+		t.Errorf("Expected the certificate of admin.example.test, got %v", admin.CertNames)
+	}
+	if len(other.CertNames) != 1 || other.CertNames[0] != "*.example.test" {
+		t.Errorf("Expected the wildcard certificate, got %v", other.CertNames)
+	}
+	if admin.CertHash == "" || admin.CertHash == other.CertHash {
+		t.Errorf("Expected the certificates to have different hashes, got %q and %q", admin.CertHash, other.CertHash)
+	}
+
+	// The server name is the one of the URL when the Host header is not fuzzed
+	conf = newTestConfig(ts.URL + "/")
+	conf.Headers = map[string]string{"Host": "admin.example.test"}
+	resp := executeTestRequest(t, conf, "")
+	if string(resp.Data) != "host: admin.example.test, server name: " {
+		t.Errorf("Expected no server name to be sent for the IP address of the URL, got %q", resp.Data)
+	}
+}
@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+// requestTimer records the phases of a request with httptrace. Each attempt of a retried request starts over, the
+// timing being the one of the last attempt.
+type requestTimer struct {
+	mutex        sync.Mutex
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wrote        time.Time
+	timing       ffuf.Timing
+}
+
+func (t *requestTimer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.dnsStart, t.connectStart, t.tlsStart, t.wrote = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+			t.timing = ffuf.Timing{}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.timing.DNS = time.Since(t.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			// The addresses of a host may be dialed in parallel, the first dial starts the phase
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_ string, _ string, err error) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			if err == nil {
+				t.timing.Connect = time.Since(t.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.timing.TLS = time.Since(t.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused {
+				return
+			}
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.timing.DNS, t.timing.Connect, t.timing.TLS = 0, 0, 0
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.wrote = time.Now() // begin the timer after the request is fully written
+		},
+		GotFirstResponseByte: func() {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.timing.TTFB = time.Since(t.wrote)
+		},
+	}
+}
+
+// result returns the time the request was written at and the timing of its phases
+func (t *requestTimer) result() (time.Time, ffuf.Timing) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.wrote, t.timing
+}
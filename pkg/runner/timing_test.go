@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ffuf/ffuf/v2/pkg/ffuf"
+)
+
+const slowPhase = 150 * time.Millisecond
+
+// slowListener delays the first read of each accepted connection, which slows down the TLS handshake
+type slowListener struct {
+	net.Listener
+}
+
+func (l slowListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &slowConn{Conn: conn}, nil
+}
+
+type slowConn struct {
+	net.Conn
+	delayed bool
+}
+
+func (c *slowConn) Read(b []byte) (int, error) {
+	if !c.delayed {
+		c.delayed = true
+		time.Sleep(slowPhase)
+	}
+	return c.Conn.Read(b)
+}
+
+func TestExecuteTiming(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(slowPhase)
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	ts.Listener = slowListener{ts.Listener}
+	ts.StartTLS()
+	defer ts.Close()
+
+	// A host name rather than the address for the DNS lookup to be traced
+	conf := newTestConfig(strings.Replace(ts.URL, "127.0.0.1", "localhost", 1) + "/FUZZ")
+	r := NewSimpleRunner(conf, false)
+	execute := func(input string) ffuf.Response {
+		basereq := ffuf.BaseRequest(conf)
+		req, _ := r.Prepare(map[string][]byte{"FUZZ": []byte(input)}, &basereq)
+		resp, err := r.Execute(&req)
+		if err != nil {
+			t.Fatalf("Could not execute request: %s", err)
+		}
+		return resp
+	}
+
+	// The slow handshake of a new connection is not part of the time to the first byte
+	resp := execute("fast")
+	if resp.Timing.TLS < slowPhase {
+		t.Errorf("Expected the TLS handshake to take at least %s, got %s", slowPhase, resp.Timing.TLS)
+	}
+	if resp.Timing.TTFB >= slowPhase || resp.Duration != resp.Timing.TTFB {
+		t.Errorf("Expected a fast time to the first byte as the duration, got %s and %s", resp.Timing.TTFB, resp.Duration)
+	}
+	if resp.Timing.Connect <= 0 || resp.Timing.Connect >= slowPhase {
+		t.Errorf("Expected a fast connection, got %s", resp.Timing.Connect)
+	}
+	if resp.Timing.DNS <= 0 {
+		t.Errorf("Expected the DNS lookup to be timed, got %s", resp.Timing.DNS)
+	}
+
+	// The reused connection skips the DNS lookup, the connection and the handshake
+	resp = execute("slow")
+	if resp.Timing.DNS != 0 || resp.Timing.Connect != 0 || resp.Timing.TLS != 0 {
+		t.Errorf("Expected zero timings for the reused connection, got %+v", resp.Timing)
+	}
+	if resp.Timing.TTFB < slowPhase {
+		t.Errorf("Expected the time to the first byte to include the slow handler, got %s", resp.Timing.TTFB)
+	}
+}